@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/app"
+	"github.com/florianilch/claudine-proxy/internal/audit"
+)
+
+// errVerificationFailed is returned if an upstream request digest fails verification.
+var errVerificationFailed = errors.New("verification failed")
+
+// auditCommand returns the 'audit' subcommand for working with the audit log.
+func auditCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "audit",
+		Usage: "Work with the audit log",
+		Commands: []*cli.Command{
+			auditVerifyCommand(),
+		},
+	}
+}
+
+// auditVerifyCommand returns the 'audit verify' subcommand.
+func auditVerifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Verify the signed digest of the upstream request recorded for a request ID",
+		ArgsUsage: "<request-id>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "body",
+				Usage: "file with a request body to check against the recorded digest",
+			},
+		},
+		Action: auditVerifyAction,
+	}
+}
+
+// auditVerifyAction checks the signatures of the upstream requests recorded for a
+// request ID and, if given, whether one of them was sent with the body file.
+func auditVerifyAction(ctx context.Context, cmd *cli.Command) error {
+	requestID := cmd.Args().First()
+	if requestID == "" {
+		return errors.New("request ID required")
+	}
+
+	cfg, err := loadConfig(cmd.String("config"), cmd, os.Environ)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Audit.SigningKey == "" {
+		return errors.New("audit.signing_key not configured")
+	}
+
+	var body []byte
+	if path := cmd.String("body"); path != "" {
+		if body, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+	}
+
+	events, err := app.FindAuditEvents(ctx, cfg, audit.ActionUpstreamRequest, requestID)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no upstream request recorded for request ID %q", requestID)
+	}
+
+	// A request ID covers retries and fallbacks, each sent upstream separately
+	w := cmd.Root().Writer
+	valid, bodyMatched := true, false
+	for _, e := range events {
+		var digest audit.RequestDigest
+		if err := json.Unmarshal([]byte(e.Detail), &digest); err != nil {
+			return fmt.Errorf("invalid digest in audit event %s: %w", e.ID, err)
+		}
+
+		signature := "valid"
+		if !digest.Verify([]byte(cfg.Audit.SigningKey)) {
+			signature, valid = "INVALID", false
+		}
+		_, _ = fmt.Fprintf(w, "%s %s %s\n", e.Timestamp.Local().Format(time.RFC3339), digest.Method, digest.URL)
+		_, _ = fmt.Fprintf(w, "  signature: %s\n", signature)
+		_, _ = fmt.Fprintf(w, "  body:      %d bytes, sha256 %s\n", digest.BodySize, digest.BodySHA256)
+		if body != nil {
+			match := "differs"
+			if digest.MatchesBody(body) {
+				match, bodyMatched = "matches", true
+			}
+			_, _ = fmt.Fprintf(w, "  body file: %s\n", match)
+		}
+		for _, name := range slices.Sorted(maps.Keys(digest.Headers)) {
+			_, _ = fmt.Fprintf(w, "  %s: %s\n", name, digest.Headers[name])
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: signature mismatch, the audit log was altered or another key signed it", errVerificationFailed)
+	}
+	if body != nil && !bodyMatched {
+		return fmt.Errorf("%w: body file differs from every request sent", errVerificationFailed)
+	}
+	return nil
+}
@@ -3,14 +3,18 @@ package commands
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/user"
 
 	"github.com/urfave/cli/v3"
 	"golang.org/x/oauth2"
 	"golang.org/x/term"
 
 	"github.com/florianilch/claudine-proxy/internal/app"
-	"github.com/florianilch/claudine-proxy/internal/tokensource"
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/tokenstore"
+	"github.com/florianilch/claudine-proxy/pkg/tokensource"
 )
 
 // authCommand returns the 'auth' subcommand for managing provider authentication.
@@ -21,6 +25,7 @@ func authCommand() *cli.Command {
 		Commands: []*cli.Command{
 			authLoginCommand(),
 			authLogoutCommand(),
+			authRestoreCommand(),
 		},
 	}
 }
@@ -30,6 +35,7 @@ func authLoginCommand() *cli.Command {
 	return &cli.Command{
 		Name:   "login",
 		Usage:  "Login to Anthropic Claude and save credentials",
+		Flags:  append(authFlags(), tenantFlag()),
 		Action: authLoginAction,
 	}
 }
@@ -39,10 +45,21 @@ func authLogoutCommand() *cli.Command {
 	return &cli.Command{
 		Name:   "logout",
 		Usage:  "Logout from Anthropic Claude and clear credentials",
+		Flags:  append(authFlags(), tenantFlag()),
 		Action: authLogoutAction,
 	}
 }
 
+// authRestoreCommand returns the 'auth restore' subcommand.
+func authRestoreCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "restore",
+		Usage:  "Replace the stored token with the previous one kept as backup",
+		Flags:  append(authFlags(), tenantFlag()),
+		Action: authRestoreAction,
+	}
+}
+
 // authLoginAction implements the OAuth login flow for Anthropic Claude.
 func authLoginAction(ctx context.Context, cmd *cli.Command) error {
 	cfg, err := loadConfig(cmd.String("config"), cmd, os.Environ)
@@ -50,11 +67,16 @@ func authLoginAction(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if cfg.Auth.Storage == app.TokenStorageTypeEnv {
+	auth, err := tenantAuth(cfg, cmd.String("tenant"))
+	if err != nil {
+		return err
+	}
+
+	if auth.Storage == app.TokenStorageTypeEnv {
 		return fmt.Errorf("cannot login with env storage (read-only). Configure file or keyring storage")
 	}
 
-	store, err := cfg.Auth.NewTokenStore()
+	store, err := auth.NewTokenStore()
 	if err != nil {
 		return fmt.Errorf("failed to create token store: %w", err)
 	}
@@ -67,6 +89,7 @@ func authLoginAction(ctx context.Context, cmd *cli.Command) error {
 	if err := store.Write(ctx, token); err != nil {
 		return fmt.Errorf("failed to write token: %w", err)
 	}
+	recordAuthEvent(ctx, cfg, audit.ActionLogin, cmd.String("tenant"))
 
 	fmt.Println()
 	fmt.Println("=== Login Successful ===")
@@ -83,11 +106,16 @@ func authLogoutAction(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if cfg.Auth.Storage == app.TokenStorageTypeEnv {
+	auth, err := tenantAuth(cfg, cmd.String("tenant"))
+	if err != nil {
+		return err
+	}
+
+	if auth.Storage == app.TokenStorageTypeEnv {
 		return fmt.Errorf("cannot logout with env storage (read-only). Configure file or keyring storage")
 	}
 
-	store, err := cfg.Auth.NewTokenStore()
+	store, err := auth.NewTokenStore()
 	if err != nil {
 		return fmt.Errorf("failed to create token store: %w", err)
 	}
@@ -96,6 +124,7 @@ func authLogoutAction(ctx context.Context, cmd *cli.Command) error {
 	if err := store.Write(ctx, ""); err != nil {
 		return fmt.Errorf("failed to clear token: %w", err)
 	}
+	recordAuthEvent(ctx, cfg, audit.ActionLogout, cmd.String("tenant"))
 
 	fmt.Println()
 	fmt.Println("=== Logout Successful ===")
@@ -104,6 +133,81 @@ func authLogoutAction(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+// authRestoreAction restores the token backed up by the last token write, e.g.
+// after a corrupted write or a rotation race.
+func authRestoreAction(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := loadConfig(cmd.String("config"), cmd, os.Environ)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	auth, err := tenantAuth(cfg, cmd.String("tenant"))
+	if err != nil {
+		return err
+	}
+
+	store, err := auth.NewTokenStore()
+	if err != nil {
+		return fmt.Errorf("failed to create token store: %w", err)
+	}
+	backupStore, ok := store.(tokenstore.BackupStore)
+	if !ok {
+		return fmt.Errorf("%s storage keeps no backup token", auth.Storage)
+	}
+
+	if err := backupStore.Restore(ctx); err != nil {
+		return fmt.Errorf("failed to restore backup token: %w", err)
+	}
+	recordAuthEvent(ctx, cfg, audit.ActionTokenRestore, cmd.String("tenant"))
+
+	fmt.Println("Previous token restored from backup")
+
+	return nil
+}
+
+// recordAuthEvent records a login, logout or restore of the tenant's account, ""
+// for the default account, to the configured audit log. The credentials are
+// already written, so audit failures are only logged.
+func recordAuthEvent(ctx context.Context, cfg *app.Config, action, tenant string) {
+	auditLog, closeAudit, err := app.OpenAuditLog(ctx, cfg)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to open audit log", "error", err)
+		return
+	}
+	defer func() { _ = closeAudit() }()
+
+	auditLog.Record(ctx, audit.Event{Action: action, Actor: osUser(), Target: tenant})
+}
+
+// osUser returns the name of the user running the command.
+func osUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// tenantFlag selects a tenant's account for auth commands.
+func tenantFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "tenant",
+		Usage: "manage the credentials of this tenant instead of the default account",
+	}
+}
+
+// tenantAuth returns the auth config of the named tenant, or the default one if name is empty.
+func tenantAuth(cfg *app.Config, name string) (*app.AuthConfig, error) {
+	if name == "" {
+		return &cfg.Auth, nil
+	}
+	for i := range cfg.Tenants {
+		if cfg.Tenants[i].Name == name {
+			return &cfg.Tenants[i].Auth, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown tenant %q", name)
+}
+
 // readSecureInput reads user input with hidden display and context cancellation support.
 // Goroutine+select pattern required because term.ReadPassword has no native context support.
 func readSecureInput(ctx context.Context, prompt string) (string, error) {
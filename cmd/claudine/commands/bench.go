@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/bench"
+)
+
+// benchCommand returns the 'bench' command measuring proxy overhead.
+func benchCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "bench",
+		Usage: "Measure the latency and allocations added by the proxy against a built-in mock upstream",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "requests",
+				Usage: "requests per path and payload size",
+				Value: 1000,
+			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "requests in flight",
+				Value: 8,
+			},
+			&cli.StringSliceFlag{
+				Name:  "sizes",
+				Usage: "request payload sizes (e.g. 512, 4k, 1m)",
+				Value: []string{"1k", "64k", "1m"},
+			},
+			&cli.BoolFlag{
+				Name:  "stream",
+				Usage: "request streaming responses",
+			},
+		},
+		Action: benchAction,
+	}
+}
+
+func benchAction(ctx context.Context, cmd *cli.Command) error {
+	opts := bench.Options{
+		Requests:    int(cmd.Int("requests")),
+		Concurrency: int(cmd.Int("concurrency")),
+		Stream:      cmd.Bool("stream"),
+	}
+	for _, s := range cmd.StringSlice("sizes") {
+		size, err := bench.ParseSize(s)
+		if err != nil {
+			return err
+		}
+		opts.Sizes = append(opts.Sizes, size)
+	}
+
+	// Request logging would dominate the measurement; only report failures
+	slog.SetDefault(slog.New(slog.NewTextHandler(cmd.Root().ErrWriter, &slog.HandlerOptions{Level: slog.LevelError})))
+
+	results, err := bench.Run(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(w, "path\tsize\trequests\terrors\tp50\tp90\tp99\tmax\tallocs/op\tB/op\t")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%d\t%d\t\n",
+			r.Path, r.Size, r.Requests, r.Errors,
+			round(r.P50), round(r.P90), round(r.P99), round(r.Max),
+			r.AllocsPerOp, r.BytesPerOp)
+	}
+	return w.Flush()
+}
+
+// round trims durations to a readable precision.
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/cache"
+)
+
+// errCacheDisabled is returned by cache operations if response caching is off.
+var errCacheDisabled = errors.New("response cache disabled")
+
+// responseCache returns the response cache of the running application.
+func (i *instance) responseCache() (*cache.Counting, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if c := i.app.Cache(); c != nil {
+		return c, nil
+	}
+	return nil, errCacheDisabled
+}
+
+// CacheStats implements control.Controller.
+func (i *instance) CacheStats(ctx context.Context) (cache.Stats, error) {
+	c, err := i.responseCache()
+	if err != nil {
+		return cache.Stats{}, err
+	}
+	return c.Stats(ctx)
+}
+
+// CacheKeys implements control.Controller.
+func (i *instance) CacheKeys(ctx context.Context) ([]cache.Info, error) {
+	c, err := i.responseCache()
+	if err != nil {
+		return nil, err
+	}
+	return c.Keys(ctx)
+}
+
+// PurgeCache implements control.Controller.
+func (i *instance) PurgeCache(ctx context.Context, pattern string) (int, error) {
+	c, err := i.responseCache()
+	if err != nil {
+		return 0, err
+	}
+	purged, err := cache.Purge(ctx, c, pattern)
+	if purged > 0 {
+		i.mu.Lock()
+		auditLog := i.app.Audit()
+		i.mu.Unlock()
+		target := pattern
+		if target == "" {
+			target = "*"
+		}
+		auditLog.Record(ctx, audit.Event{
+			Action: audit.ActionCachePurge,
+			Actor:  "control_socket",
+			Target: target,
+			Detail: fmt.Sprintf("%d entries", purged),
+		})
+	}
+	return purged, err
+}
+
+// cacheCommand returns the 'cache' subcommand for inspecting the response cache.
+func cacheCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "cache",
+		Usage: "Inspect and purge the response cache of the running proxy",
+		Commands: []*cli.Command{
+			cacheStatsCommand(),
+			cacheKeysCommand(),
+			cachePurgeCommand(),
+		},
+	}
+}
+
+// cacheStatsCommand returns the 'cache stats' subcommand.
+func cacheStatsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "stats",
+		Usage: "Show entries and hit rate of the response cache",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print stats as JSON",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+
+			stats, err := client.CacheStats(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := cmd.Root().Writer
+			if cmd.Bool("json") {
+				return json.NewEncoder(w).Encode(stats)
+			}
+			_, _ = fmt.Fprintf(w, "entries:  %d\n", stats.Entries)
+			_, _ = fmt.Fprintf(w, "size:     %d bytes\n", stats.Size)
+			_, _ = fmt.Fprintf(w, "hits:     %d\n", stats.Hits)
+			_, _ = fmt.Fprintf(w, "misses:   %d\n", stats.Misses)
+			_, _ = fmt.Fprintf(w, "hit rate: %.1f%%\n", 100*stats.HitRate)
+			return nil
+		},
+	}
+}
+
+// cacheKeysCommand returns the 'cache keys' subcommand.
+func cacheKeysCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "keys",
+		Usage: "List the entries of the response cache",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print entries as JSON",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+
+			keys, err := client.CacheKeys(ctx)
+			if err != nil {
+				return err
+			}
+
+			if cmd.Bool("json") {
+				return json.NewEncoder(cmd.Root().Writer).Encode(keys)
+			}
+			w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "KEY\tPATH\tCLIENT KEY\tMODEL\tSIZE\tEXPIRES IN")
+			for _, k := range keys {
+				expires := "never"
+				if !k.Expires.IsZero() {
+					expires = time.Until(k.Expires).Round(time.Second).String()
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", k.Key, k.Path, k.KeyID, k.Model, k.Size, expires)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// cachePurgeCommand returns the 'cache purge' subcommand.
+func cachePurgeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "purge",
+		Usage:     "Delete response cache entries whose key matches a pattern, or all entries",
+		ArgsUsage: "[pattern]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "all",
+				Usage: "delete all entries",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			pattern := cmd.Args().First()
+			if (pattern == "") == !cmd.Bool("all") {
+				return fmt.Errorf("expected either a key pattern or --all")
+			}
+
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+
+			purged, err := client.PurgeCache(ctx, pattern)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.Root().Writer, "purged %d entries\n", purged)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/compat"
+)
+
+// verifyCompatCommand returns the 'verify-compat' command.
+func verifyCompatCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "verify-compat",
+		Usage: "Check which OpenAI SDK features the running proxy supports with the current config",
+		Description: "Sends OpenAI SDK-style requests (tools, streaming, images, json mode, ...) to the running\n" +
+			"proxy and reports which features pass, fail or degrade. Requests reach the upstream and\n" +
+			"cost a few small completions.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "url",
+				Usage: "OpenAI base URL of the proxy (default: derived from the config)",
+			},
+			&cli.StringFlag{
+				Name:  "api-key",
+				Usage: "key to authenticate with, for key policies",
+			},
+			&cli.StringFlag{
+				Name:  "model",
+				Usage: "model to request",
+				Value: "claude-haiku-4-5",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "timeout per check",
+				Value: time.Minute,
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print results as JSON",
+			},
+		},
+		Action: verifyCompatAction,
+	}
+}
+
+func verifyCompatAction(ctx context.Context, cmd *cli.Command) error {
+	baseURL := cmd.String("url")
+	if baseURL == "" {
+		cfg, err := mergeConfig(cmd.String("config"), cmd, os.Environ)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		address := cfg.OpenAI.Listen
+		if address == "" {
+			address = net.JoinHostPort(cfg.Server.Host, strconv.FormatUint(uint64(cfg.Server.Port), 10))
+		}
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+				address = net.JoinHostPort("localhost", port)
+			}
+		}
+		baseURL = "http://" + address + "/v1"
+	}
+
+	results, err := compat.Run(ctx, compat.Options{
+		BaseURL: baseURL,
+		APIKey:  cmd.String("api-key"),
+		Model:   cmd.String("model"),
+		Timeout: cmd.Duration("timeout"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") {
+		if err := json.NewEncoder(cmd.Root().Writer).Encode(results); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(cmd.Root().Writer, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "FEATURE\tSTATUS\tTIME\tDETAIL")
+		for _, r := range results {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Feature, r.Status, r.Duration.Round(time.Millisecond), r.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var failed int
+	for _, r := range results {
+		if r.Status == compat.StatusFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed against %s", failed, len(results), baseURL)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/knadh/koanf/parsers/toml/v2"
@@ -17,15 +18,39 @@ import (
 // envPrefix is stripped from environment variables during config loading (e.g., CLAUDINE_SERVER__HOST → server.host)
 const envPrefix = "CLAUDINE_"
 
-// loadConfig loads application configuration from various sources with precedence:
+// loadConfig loads and validates application configuration from various sources with precedence:
 // config file → environment variables → CLI flags → defaults
+//
+// The config file may include further files that are merged over it (see configFiles).
+// String values in config files may reference environment variables as ${VAR}
+// or ${VAR:-default}.
 func loadConfig(configPath string, cmd *cli.Command, environFunc func() []string) (*app.Config, error) {
+	config, err := mergeConfig(configPath, cmd, environFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return config, nil
+}
+
+// mergeConfig merges all configuration sources like loadConfig without validating the result.
+func mergeConfig(configPath string, cmd *cli.Command, environFunc func() []string) (*app.Config, error) {
 	k := koanf.New(".")
 
-	// 1. Load from config file if provided
+	// 1. Load from config file if provided, followed by the files it includes
 	if configPath != "" {
-		if err := k.Load(file.Provider(configPath), toml.Parser()); err != nil {
-			return nil, fmt.Errorf("loading config file: %w", err)
+		files, err := configFiles(configPath, environFunc())
+		if err != nil {
+			return nil, err
+		}
+		for _, values := range files {
+			if err := k.Load(confmap.Provider(values, ""), nil); err != nil {
+				return nil, fmt.Errorf("loading config file: %w", err)
+			}
 		}
 	}
 
@@ -60,11 +85,87 @@ func loadConfig(configPath string, cmd *cli.Command, environFunc func() []string
 		return nil, fmt.Errorf("applying defaults: %w", err)
 	}
 
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+	return config, nil
+}
+
+// configFiles reads the config file at path and the files matched by its top-level
+// "include" globs (relative to its directory, sorted per glob), in merge order.
+// Included files overlay the base file; they cannot include further files.
+func configFiles(path string, environ []string) ([]map[string]any, error) {
+	base, err := readConfigFile(path, environ)
+	if err != nil {
+		return nil, err
 	}
 
-	return config, nil
+	patterns, err := includePatterns(base)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	delete(base, includeKey)
+	files := []map[string]any{base}
+
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid include %q: %w", path, pattern, err)
+		}
+		// Glob returns matches in lexical order, so config.d/10-*.toml precedes 20-*.toml
+		for _, match := range matches {
+			values, err := readConfigFile(match, environ)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := values[includeKey]; ok {
+				return nil, fmt.Errorf("%s: include is only supported in the main config file", match)
+			}
+			files = append(files, values)
+		}
+	}
+
+	return files, nil
+}
+
+// includeKey lists additional config files in the main config file.
+const includeKey = "include"
+
+// includePatterns returns the include globs of a parsed config file.
+func includePatterns(values map[string]any) ([]string, error) {
+	raw, ok := values[includeKey]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("include must be a list of file patterns")
+	}
+	patterns := make([]string, 0, len(list))
+	for _, item := range list {
+		pattern, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("include must be a list of file patterns")
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// readConfigFile parses a TOML config file and expands ${VAR} references in its values.
+func readConfigFile(path string, environ []string) (map[string]any, error) {
+	raw, err := file.Provider(path).ReadBytes()
+	if err != nil {
+		return nil, fmt.Errorf("loading config file: %w", err)
+	}
+	values, err := toml.Parser().Unmarshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if err := expandEnv(values, environ); err != nil {
+		return nil, fmt.Errorf("expanding config file %s: %w", path, err)
+	}
+	return values, nil
 }
 
 // extractAndTransformFlags transforms CLI flag names to match config structure.
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/knadh/koanf/parsers/toml/v2"
+	"github.com/urfave/cli/v3"
+)
+
+// configCommand returns the 'config' subcommand for inspecting configuration.
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Inspect configuration",
+		Commands: []*cli.Command{
+			configShowCommand(),
+		},
+	}
+}
+
+// configShowCommand returns the 'config show' subcommand.
+func configShowCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "show",
+		Usage:  "Print the effective configuration (file, env, flags and defaults) with secrets masked",
+		Flags:  proxyFlags(),
+		Action: configShowAction,
+	}
+}
+
+// configShowAction prints the merged configuration as TOML. Invalid configurations
+// are printed before the validation error is returned, as they are what needs debugging.
+func configShowAction(_ context.Context, cmd *cli.Command) error {
+	cfg, err := mergeConfig(cmd.String("config"), cmd, os.Environ)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	out, err := toml.Parser().Marshal(cfg.Redacted())
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if _, err := cmd.Root().Writer.Write(out); err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
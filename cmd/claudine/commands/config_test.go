@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/app"
+)
+
+func TestMergeConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("config.toml", `
+include = ["config.d/*.toml"]
+
+[auth]
+storage = "file"
+file = "${AUTH_DIR}/auth"
+
+[server]
+host = "0.0.0.0"
+port = 4100
+`)
+	write("config.d/20-host.toml", "[server]\nport = 6000\n")
+	write("config.d/10-team.toml", "[server]\nport = 5000\n\n[upstream]\npassthrough = [\"/v1/skills/*\"]\n")
+
+	environ := func() []string { return []string{"AUTH_DIR=/srv/claudine"} }
+
+	cfg, err := mergeConfig(filepath.Join(dir, "config.toml"), nil, environ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 6000 {
+		t.Errorf("server.port = %d, want 6000 (last include wins)", cfg.Server.Port)
+	}
+	if cfg.Server.Host != "0.0.0.0" {
+		t.Errorf("server.host = %q, want value from base file", cfg.Server.Host)
+	}
+	if len(cfg.Upstream.Passthrough) != 1 || cfg.Upstream.Passthrough[0] != "/v1/skills/*" {
+		t.Errorf("upstream.passthrough = %v", cfg.Upstream.Passthrough)
+	}
+	if cfg.Auth.File != "/srv/claudine/auth" {
+		t.Errorf("auth.file = %q, want expanded path", cfg.Auth.File)
+	}
+
+	// Environment variables take precedence over all files
+	cfg, err = mergeConfig(filepath.Join(dir, "config.toml"), nil, func() []string {
+		return append(environ(), "CLAUDINE_SERVER__PORT=7000")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 7000 {
+		t.Errorf("server.port = %d, want 7000 from env", cfg.Server.Port)
+	}
+
+	// Nested includes are rejected
+	write("config.d/30-nested.toml", "include = [\"other.toml\"]\n")
+	if _, err := mergeConfig(filepath.Join(dir, "config.toml"), nil, environ); err == nil || !strings.Contains(err.Error(), "only supported in the main config file") {
+		t.Errorf("nested include error = %v", err)
+	}
+}
+
+func TestAuthFlags(t *testing.T) {
+	var cfg *app.Config
+	cmd := &cli.Command{
+		Name:  "start",
+		Flags: proxyFlags(),
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			var err error
+			cfg, err = mergeConfig("", cmd, func() []string { return []string{"CLAUDINE_AUTH__STORAGE=env"} })
+			return err
+		},
+	}
+
+	args := []string{"start", "--auth-method", "static", "--auth-storage", "file", "--auth--file", "/tmp/token", "--auth-keyring-user", "ci"}
+	if err := cmd.Run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Auth.Method != app.AuthenticationMethodStatic {
+		t.Errorf("auth.method = %q, want static", cfg.Auth.Method)
+	}
+	if cfg.Auth.Storage != app.TokenStorageTypeFile {
+		t.Errorf("auth.storage = %q, want flag to override env", cfg.Auth.Storage)
+	}
+	if cfg.Auth.File != "/tmp/token" || cfg.Auth.KeyringUser != "ci" {
+		t.Errorf("auth = %+v", cfg.Auth)
+	}
+}
+
+func TestTenantConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`
+[auth]
+storage = "file"
+file = "/srv/claudine/auth"
+
+[[tenants]]
+name = "acme"
+keys = ["vk-acme"]
+model_aliases = { fast = "claude-haiku-4-5" }
+auth = { storage = "file", file = "/srv/claudine/acme" }
+pacing = { requests_per_minute = 50 }
+
+[[tenants]]
+name = "globex"
+hosts = ["globex.example.com"]
+auth = { storage = "keyring" }
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(path, nil, func() []string { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Tenants[1].Auth.KeyringUser; got != "tenant-globex" {
+		t.Errorf("keyring_user = %q, want tenant default", got)
+	}
+	if got := cfg.Tenants[0].Auth.Method; got != app.DefaultConfigAuthMethod {
+		t.Errorf("method = %q, want default", got)
+	}
+
+	auth, err := tenantAuth(cfg, "acme")
+	if err != nil || auth.File != "/srv/claudine/acme" {
+		t.Errorf("tenantAuth(acme) = %+v, %v", auth, err)
+	}
+	if _, err := tenantAuth(cfg, "initech"); err == nil {
+		t.Error("tenantAuth(initech) succeeded for unknown tenant")
+	}
+
+	cfg.Tenants[1].Hosts = nil
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() accepted tenant without keys or hosts")
+	}
+}
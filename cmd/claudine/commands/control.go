@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/app"
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/control"
+	"github.com/florianilch/claudine-proxy/internal/observability"
+)
+
+// controlTimeout bounds requests to a running instance. Reloads wait for in-flight
+// requests to drain, so this covers the shutdown timeout.
+const controlTimeout = 2 * time.Minute
+
+// instance runs the application until stopped and restarts it with fresh
+// configuration on reload. It implements control.Controller.
+type instance struct {
+	cmd       *cli.Command
+	stop      context.CancelFunc
+	startedAt time.Time
+
+	mu         sync.Mutex
+	cfg        *app.Config
+	app        *app.App
+	next       *app.App
+	restart    context.CancelFunc
+	lastReload time.Time
+}
+
+// Compile-time check that instance implements control.Controller
+var _ control.Controller = (*instance)(nil)
+
+// run starts the application and blocks until it stops without pending reload.
+func (i *instance) run(ctx context.Context) error {
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		i.mu.Lock()
+		application := i.app
+		i.restart = cancel
+		i.mu.Unlock()
+
+		err := application.Start(runCtx)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		i.mu.Lock()
+		next := i.next
+		i.next = nil
+		if next != nil {
+			i.app = next
+		}
+		i.mu.Unlock()
+
+		if next == nil || ctx.Err() != nil {
+			return nil
+		}
+		slog.InfoContext(ctx, "restarting with reloaded configuration")
+	}
+}
+
+// Status implements control.Controller.
+func (i *instance) Status() control.Status {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	status := control.Status{
+		PID:           os.Getpid(),
+		Version:       i.cmd.Root().Version,
+		Address:       i.cfg.Server.Host + ":" + strconv.FormatUint(uint64(i.cfg.Server.Port), 10),
+		StartedAt:     i.startedAt,
+		Uptime:        time.Since(i.startedAt).Round(time.Second).String(),
+		ActiveStreams: i.app.ActiveStreams(),
+		LogLevel:      observability.Level().String(),
+		Degraded:      i.app.Degraded(),
+	}
+	if expiry := i.app.TokenExpiry(); !expiry.IsZero() {
+		status.TokenExpiry = &expiry
+	}
+	if !i.lastReload.IsZero() {
+		lastReload := i.lastReload
+		status.LastReload = &lastReload
+	}
+	return status
+}
+
+// Stop implements control.Controller.
+func (i *instance) Stop() {
+	slog.Info("stop requested via control socket")
+	i.stop()
+}
+
+// SetLogLevel implements control.Controller.
+func (i *instance) SetLogLevel(level slog.Level) {
+	setLogLevel(context.Background(), level)
+}
+
+// Reload implements control.Controller. Logging, pid_file and control_socket
+// settings keep their startup values.
+func (i *instance) Reload(ctx context.Context) error {
+	cfg, err := loadConfig(i.cmd.String("config"), i.cmd, os.Environ)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if isDaemon() {
+		if err := applyDaemonDefaults(cfg); err != nil {
+			return err
+		}
+	}
+
+	next, err := app.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create app: %w", err)
+	}
+
+	i.mu.Lock()
+	i.cfg = cfg
+	i.next = next
+	i.lastReload = time.Now()
+	restart := i.restart
+	i.mu.Unlock()
+
+	slog.InfoContext(ctx, "reloading configuration")
+	next.Audit().Record(ctx, audit.Event{Action: audit.ActionConfigReload, Actor: "control_socket"})
+	restart()
+	return nil
+}
+
+// controlClient returns a client for the control socket configured for cmd.
+func controlClient(cmd *cli.Command) (*control.Client, error) {
+	cfg, err := mergeConfig(cmd.String("config"), cmd, os.Environ)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.ControlSocket == "" || cfg.ControlSocket == app.ControlSocketDisabled {
+		return nil, fmt.Errorf("control socket disabled")
+	}
+	return control.NewClient(cfg.ControlSocket), nil
+}
+
+// statusCommand returns the 'status' command.
+func statusCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "status",
+		Usage: "Show the status of the running proxy",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print status as JSON",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+
+			status, err := client.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := cmd.Root().Writer
+			if cmd.Bool("json") {
+				return json.NewEncoder(w).Encode(status)
+			}
+			_, _ = fmt.Fprintf(w, "pid:            %d\n", status.PID)
+			_, _ = fmt.Fprintf(w, "version:        %s\n", status.Version)
+			_, _ = fmt.Fprintf(w, "address:        %s\n", status.Address)
+			_, _ = fmt.Fprintf(w, "uptime:         %s\n", status.Uptime)
+			_, _ = fmt.Fprintf(w, "active streams: %d\n", status.ActiveStreams)
+			_, _ = fmt.Fprintf(w, "log level:      %s\n", status.LogLevel)
+			if status.TokenExpiry != nil {
+				_, _ = fmt.Fprintf(w, "token expires:  %s (in %s)\n",
+					status.TokenExpiry.Format(time.RFC3339), time.Until(*status.TokenExpiry).Round(time.Second))
+			}
+			if status.LastReload != nil {
+				_, _ = fmt.Fprintf(w, "last reload:    %s\n", status.LastReload.Format(time.RFC3339))
+			}
+			for _, component := range slices.Sorted(maps.Keys(status.Degraded)) {
+				_, _ = fmt.Fprintf(w, "degraded:       %s: %s\n", component, status.Degraded[component])
+			}
+			return nil
+		},
+	}
+}
+
+// stopCommand returns the 'stop' command.
+func stopCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "stop",
+		Usage: "Gracefully stop the running proxy",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+			return client.Stop(ctx)
+		},
+	}
+}
+
+// reloadCommand returns the 'reload' command.
+func reloadCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "reload",
+		Usage: "Reload the configuration of the running proxy",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+			return client.Reload(ctx)
+		},
+	}
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/app"
+)
+
+// daemonEnv marks the background process started by start --daemon. It lacks the
+// CLAUDINE_ prefix so it doesn't end up in the config.
+const daemonEnv = "_CLAUDINE_DAEMON"
+
+// daemonStartTimeout bounds how long start --daemon waits for the pidfile.
+const daemonStartTimeout = 10 * time.Second
+
+// isDaemon reports whether this process was started by start --daemon.
+func isDaemon() bool {
+	return os.Getenv(daemonEnv) == "1"
+}
+
+// applyDaemonDefaults places pidfile and log file in the user's cache directory
+// unless configured, as a detached process has nowhere else to log.
+func applyDaemonDefaults(cfg *app.Config) error {
+	if cfg.PidFile != "" && cfg.LogFile != "" {
+		return nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return fmt.Errorf("pid_file and log_file required (auto-detect failed: %w)", err)
+	}
+	if cfg.PidFile == "" {
+		cfg.PidFile = filepath.Join(cacheDir, "claudine-proxy", "claudine.pid")
+	}
+	if cfg.LogFile == "" {
+		cfg.LogFile = filepath.Join(cacheDir, "claudine-proxy", "claudine.log")
+	}
+	return nil
+}
+
+// startDaemon runs the current command again as a detached background process with
+// output redirected to the log file, and returns once it has written its pidfile.
+func startDaemon(cmd *cli.Command, cfg *app.Config) error {
+	if pid, running := runningPid(cfg.PidFile); running {
+		return fmt.Errorf("already running (pid %d, %s)", pid, cfg.PidFile)
+	}
+
+	logFile, err := openLogFile(cfg.LogFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logFile.Close() }()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	child := exec.Command(executable, slices.DeleteFunc(slices.Clone(os.Args[1:]), isDaemonFlag)...)
+	child.Env = append(os.Environ(), daemonEnv+"=1")
+	child.Stdout = logFile
+	child.Stderr = logFile
+	child.SysProcAttr = detachedProcAttr()
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(daemonStartTimeout)
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("daemon exited during startup (%v), see %s", err, cfg.LogFile)
+		case <-timeout:
+			return fmt.Errorf("daemon did not start within %s, see %s", daemonStartTimeout, cfg.LogFile)
+		case <-ticker.C:
+			if pid, _ := readPidFile(cfg.PidFile); pid == child.Process.Pid {
+				_, _ = fmt.Fprintf(cmd.Root().Writer, "claudine started in background (pid %d), logging to %s\n", pid, cfg.LogFile)
+				return nil
+			}
+		}
+	}
+}
+
+// isDaemonFlag matches --daemon in any of its accepted spellings.
+func isDaemonFlag(arg string) bool {
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+	return strings.HasPrefix(arg, "-") && name == "daemon"
+}
+
+// openLogFile opens path for appending, creating parent directories as needed.
+func openLogFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return f, nil
+}
+
+// writePidFile records the current process in path. Fails if another running
+// process owns the pidfile unless takeover is set; stale pidfiles are replaced.
+func writePidFile(path string, takeover bool) error {
+	if pid, running := runningPid(path); running && pid != os.Getpid() && !takeover {
+		return fmt.Errorf("already running (pid %d, %s)", pid, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create pidfile directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to write pidfile: %w", err)
+	}
+	return nil
+}
+
+// removePidFile deletes path if it still belongs to the current process.
+func removePidFile(path string) error {
+	if pid, _ := readPidFile(path); pid != os.Getpid() {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove pidfile: %w", err)
+	}
+	return nil
+}
+
+// readPidFile returns the process ID stored in path.
+func readPidFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// runningPid returns the process ID in path and whether that process is alive.
+func runningPid(path string) (int, bool) {
+	pid, err := readPidFile(path)
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, processAlive(pid)
+}
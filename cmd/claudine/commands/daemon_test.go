@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestPidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "claudine.pid")
+
+	if err := writePidFile(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if pid, running := runningPid(path); !running || pid != os.Getpid() {
+		t.Errorf("runningPid() = %d, %v; want own pid", pid, running)
+	}
+
+	// Another live process owning the pidfile blocks startup
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePidFile(path, false); err == nil {
+		t.Error("writePidFile() succeeded while another process owns the pidfile")
+	}
+	if err := removePidFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("removePidFile() removed a pidfile of another process")
+	}
+
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := removePidFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("pidfile still exists after removePidFile(): %v", err)
+	}
+}
+
+func TestIsDaemonFlag(t *testing.T) {
+	for arg, want := range map[string]bool{
+		"--daemon":       true,
+		"-daemon":        true,
+		"--daemon=true":  true,
+		"daemon":         false,
+		"--daemonize":    false,
+		"--server--port": false,
+	} {
+		if got := isDaemonFlag(arg); got != want {
+			t.Errorf("isDaemonFlag(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
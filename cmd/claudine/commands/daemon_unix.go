@@ -0,0 +1,24 @@
+//go:build !windows
+
+package commands
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// detachedProcAttr starts the daemon in a new session, detached from the terminal.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+// detachedProcess runs the daemon without a console (DETACHED_PROCESS).
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the daemon without console, in its own process group.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
+
+// processAlive reports whether a process with pid exists.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
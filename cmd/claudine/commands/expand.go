@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// envReference matches ${VAR} and ${VAR:-default} references, and the $${ escape.
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} references in all string values of a parsed config file,
+// including strings in nested tables and arrays. ${VAR:-default} falls back to default
+// if VAR is unset or empty; $${ produces a literal "${".
+// References to unset variables without default are an error to surface typos early.
+func expandEnv(values map[string]any, environ []string) error {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	for key, value := range values {
+		expanded, err := expandValue(value, env)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		values[key] = expanded
+	}
+	return nil
+}
+
+func expandValue(value any, env map[string]string) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return expandString(v, env)
+	case map[string]any:
+		for key, item := range v {
+			expanded, err := expandValue(item, env)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = expanded
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			expanded, err := expandValue(item, env)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			v[i] = expanded
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+func expandString(s string, env map[string]string) (string, error) {
+	var err error
+	expanded := envReference.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		groups := envReference.FindStringSubmatch(match)
+		name, fallback := groups[1], groups[2]
+		if value := env[name]; value != "" {
+			return value
+		}
+		if strings.Contains(match, ":-") {
+			return fallback
+		}
+		if _, ok := env[name]; !ok && err == nil {
+			err = fmt.Errorf("undefined environment variable %s", name)
+		}
+		return ""
+	})
+	return expanded, err
+}
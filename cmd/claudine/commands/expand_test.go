@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	environ := []string{"HOME=/home/me", "REGION=eu", "EMPTY="}
+
+	tests := []struct {
+		name    string
+		value   any
+		want    any
+		wantErr bool
+	}{
+		{name: "plain", value: "no refs", want: "no refs"},
+		{name: "reference", value: "${HOME}/.config/claudine", want: "/home/me/.config/claudine"},
+		{name: "multiple", value: "https://${REGION}.example.com${HOME}", want: "https://eu.example.com/home/me"},
+		{name: "default", value: "${UNSET:-fallback}", want: "fallback"},
+		{name: "default for empty", value: "${EMPTY:-fallback}", want: "fallback"},
+		{name: "empty without default", value: "${EMPTY}", want: ""},
+		{name: "escape", value: "$${HOME}", want: "${HOME}"},
+		{name: "dollar without braces", value: "pa$$word$HOME", want: "pa$$word$HOME"},
+		{name: "nested", value: []any{map[string]any{"path": "${HOME}"}}, want: []any{map[string]any{"path": "/home/me"}}},
+		{name: "non-string", value: int64(4000), want: int64(4000)},
+		{name: "undefined", value: "${UNSET}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]any{"key": tt.value}
+			err := expandEnv(values, environ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := values["key"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandEnv() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/observability"
+)
+
+// watchLogLevelSignals toggles between debug and the configured log level on
+// logLevelSignals (SIGUSR1) until ctx is done.
+func watchLogLevelSignals(ctx context.Context, configured slog.Level) {
+	if len(logLevelSignals) == 0 {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, logLevelSignals...)
+
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				setLogLevel(ctx, toggledLogLevel(observability.Level(), configured))
+			}
+		}
+	}()
+}
+
+// toggledLogLevel returns debug unless already logging at debug, in which case it
+// returns the configured level (info if debug was configured).
+func toggledLogLevel(current, configured slog.Level) slog.Level {
+	if current > slog.LevelDebug {
+		return slog.LevelDebug
+	}
+	if configured > slog.LevelDebug {
+		return configured
+	}
+	return slog.LevelInfo
+}
+
+// setLogLevel changes the global log level, logging the change at the more
+// verbose of both levels so it shows up either way.
+func setLogLevel(ctx context.Context, level slog.Level) {
+	previous := observability.Level()
+	observability.SetLevel(min(level, previous))
+	slog.InfoContext(ctx, "log level changed", "from", previous, "to", level)
+	observability.SetLevel(level)
+}
+
+// logLevelCommand returns the 'log-level' command.
+func logLevelCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "log-level",
+		Usage:     "Show or change the log level of the running proxy until it restarts",
+		ArgsUsage: "[debug|info|warn|error]",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+
+			if cmd.Args().Len() == 0 {
+				status, err := client.Status(ctx)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(cmd.Root().Writer, status.LogLevel)
+				return nil
+			}
+
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(cmd.Args().First())); err != nil {
+				return fmt.Errorf("invalid log level %q", cmd.Args().First())
+			}
+			return client.SetLogLevel(ctx, level)
+		},
+	}
+}
@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestToggledLogLevel(t *testing.T) {
+	tests := []struct {
+		current, configured, want slog.Level
+	}{
+		{current: slog.LevelInfo, configured: slog.LevelInfo, want: slog.LevelDebug},
+		{current: slog.LevelDebug, configured: slog.LevelWarn, want: slog.LevelWarn},
+		{current: slog.LevelDebug, configured: slog.LevelDebug, want: slog.LevelInfo},
+		{current: slog.LevelError, configured: slog.LevelDebug, want: slog.LevelDebug},
+	}
+	for _, tt := range tests {
+		if got := toggledLogLevel(tt.current, tt.configured); got != tt.want {
+			t.Errorf("toggledLogLevel(%v, %v) = %v, want %v", tt.current, tt.configured, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package commands
+
+import (
+	"os"
+	"syscall"
+)
+
+// logLevelSignals toggle debug logging of a running instance.
+var logLevelSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package commands
+
+import "os"
+
+// logLevelSignals is empty; Windows has no user signals. Use 'claudine log-level'.
+var logLevelSignals []os.Signal
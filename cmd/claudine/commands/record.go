@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/app"
+	"github.com/florianilch/claudine-proxy/internal/observability"
+	"github.com/florianilch/claudine-proxy/internal/proxy"
+	"github.com/florianilch/claudine-proxy/internal/record"
+)
+
+// defaultFixtureDir holds the adapter's golden test fixtures, relative to the repository root.
+const defaultFixtureDir = "pkg/openaiadapter/anthropicclaude/testdata"
+
+// fixtureName restricts fixture names to what fits the existing testdata files.
+var fixtureName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// recordCommand returns the 'record' command.
+func recordCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "record",
+		Usage: "Proxy a session and write its OpenAI chat completions as adapter test fixtures",
+		Flags: append(proxyFlags(),
+			&cli.StringFlag{
+				Name:     "name",
+				Usage:    "fixture name (lowercase letters, digits and underscores)",
+				Required: true,
+				Validator: func(name string) error {
+					if !fixtureName.MatchString(name) {
+						return fmt.Errorf("invalid fixture name %q", name)
+					}
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:  "dir",
+				Usage: "testdata directory receiving buffered/ and streaming/ fixtures",
+				Value: defaultFixtureDir,
+			},
+		),
+		Action: recordAction,
+	}
+}
+
+// recordAction runs the proxy in the foreground until interrupted, then writes
+// the recorded turns.
+func recordAction(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := loadConfig(cmd.String("config"), cmd, os.Environ)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	otelShutdown, err := observability.Instrument(ctx, observability.LogConfig{
+		Level:           cfg.LogLevel,
+		ComponentLevels: cfg.LogLevels,
+		Format:          string(cfg.LogFormat),
+		Output:          string(cfg.LogOutput),
+		Writer:          cmd.Root().ErrWriter,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up observability layer: %w", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = otelShutdown(shutdownCtx)
+	}()
+
+	recorder := record.New()
+	application, err := app.New(cfg, proxy.WithRecorder(recorder))
+	if err != nil {
+		return fmt.Errorf("failed to create app: %w", err)
+	}
+
+	w := cmd.Root().Writer
+	address := cfg.Server.Host + ":" + strconv.FormatUint(uint64(cfg.Server.Port), 10)
+	_, _ = fmt.Fprintf(w, "recording OpenAI chat completions at http://%s/v1, press Ctrl+C to write fixtures\n", address)
+
+	if err := application.Start(ctx); err != nil {
+		return fmt.Errorf("app failed to start: %w", err)
+	}
+
+	if recorder.Len() == 0 {
+		_, _ = fmt.Fprintln(w, "no chat completions recorded")
+		return nil
+	}
+	paths, err := recorder.WriteFixtures(cmd.String("dir"), cmd.String("name"))
+	for _, path := range paths {
+		_, _ = fmt.Fprintf(w, "wrote %s\n", filepath.ToSlash(path))
+	}
+	return err
+}
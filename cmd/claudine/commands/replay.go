@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/app"
+	"github.com/florianilch/claudine-proxy/internal/bench"
+	"github.com/florianilch/claudine-proxy/internal/capture"
+	"github.com/florianilch/claudine-proxy/internal/observability"
+	"github.com/florianilch/claudine-proxy/internal/proxy"
+)
+
+// replayCommand returns the 'replay' command.
+func replayCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "replay",
+		Usage: "Re-send a captured request through the proxy pipeline and print the response",
+		Flags: append(proxyFlags(),
+			&cli.StringFlag{
+				Name:     "request-id",
+				Usage:    "ID of the captured request, as logged and returned in X-Request-ID",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "mock",
+				Usage: "answer from a built-in mock upstream instead of Anthropic, without spending quota",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "client key to send, as credentials are not captured",
+			},
+		),
+		Action: replayAction,
+	}
+}
+
+// replayAction loads a captured request and serves it in-process with the
+// configured pipeline, writing the response to stdout.
+func replayAction(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := loadConfig(cmd.String("config"), cmd, os.Environ)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Capture.Dir == "" {
+		return errors.New("capture.dir not configured")
+	}
+
+	captured, err := capture.Load(cfg.Capture.Dir, cmd.String("request-id"))
+	if err != nil {
+		return err
+	}
+	req, err := captured.HTTPRequest()
+	if err != nil {
+		return err
+	}
+	// A separate ID keeps replays apart from the original in logs and captures
+	req.Header.Set("X-Request-ID", captured.ID+"-replay")
+	if key := cmd.String("key"); key != "" {
+		req.Header.Set("X-Api-Key", key)
+	}
+
+	// Every route is served by the handler, none needs a listener of its own
+	cfg.Capture.Dir = ""
+	cfg.OpenAI.Listen = ""
+	cfg.Native.Listen = ""
+
+	var opts []proxy.Option
+	if cmd.Bool("mock") {
+		cleanup, err := useMockAuth(cfg)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		opts = append(opts, proxy.WithTransport(bench.MockUpstream))
+	}
+
+	otelShutdown, err := observability.Instrument(ctx, observability.LogConfig{
+		Level:           cfg.LogLevel,
+		ComponentLevels: cfg.LogLevels,
+		Format:          string(cfg.LogFormat),
+		Output:          string(cfg.LogOutput),
+		Writer:          cmd.Root().ErrWriter,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up observability layer: %w", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = otelShutdown(shutdownCtx)
+	}()
+
+	application, err := app.New(cfg, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create app: %w", err)
+	}
+
+	w := &replayWriter{w: cmd.Root().Writer, header: make(http.Header)}
+	application.Handler().ServeHTTP(w, req.WithContext(ctx))
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return nil
+}
+
+// useMockAuth replaces the configured credentials with a static token, so
+// replays against the mock upstream work without logging in. The returned
+// function removes the token.
+func useMockAuth(cfg *app.Config) (func(), error) {
+	dir, err := os.MkdirTemp("", "claudine-replay-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("replay"), 0o600); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	cfg.Auth.Storage, cfg.Auth.File, cfg.Auth.Method = app.TokenStorageTypeFile, tokenFile, app.AuthenticationMethodStatic
+	for i := range cfg.Tenants {
+		t := &cfg.Tenants[i].Auth
+		t.Storage, t.File, t.Method = app.TokenStorageTypeFile, tokenFile, app.AuthenticationMethodStatic
+	}
+	return cleanup, nil
+}
+
+// replayWriter prints the response status and headers, then streams the body.
+type replayWriter struct {
+	w           io.Writer
+	header      http.Header
+	wroteHeader bool
+}
+
+func (rw *replayWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *replayWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	_, _ = fmt.Fprintf(rw.w, "HTTP %d %s\n", code, http.StatusText(code))
+	_ = rw.header.Write(rw.w)
+	_, _ = fmt.Fprintln(rw.w)
+}
+
+func (rw *replayWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.w.Write(p)
+}
+
+// Flush implements http.Flusher for streaming handlers.
+func (rw *replayWriter) Flush() {}
@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"time"
@@ -10,6 +11,9 @@ import (
 	"github.com/urfave/cli/v3"
 
 	"github.com/florianilch/claudine-proxy/internal/app"
+	"github.com/florianilch/claudine-proxy/internal/control"
+	"github.com/florianilch/claudine-proxy/internal/handover"
+	"github.com/florianilch/claudine-proxy/internal/logfile"
 	"github.com/florianilch/claudine-proxy/internal/observability"
 )
 
@@ -38,6 +42,19 @@ func Execute(ctx context.Context, args []string, version, commit string) error {
 		Commands: []*cli.Command{
 			proxyStartCommand(),
 			authCommand(),
+			configCommand(),
+			statusCommand(),
+			stopCommand(),
+			reloadCommand(),
+			upgradeCommand(),
+			logLevelCommand(),
+			cacheCommand(),
+			auditCommand(),
+			serviceCommand(),
+			benchCommand(),
+			verifyCompatCommand(),
+			recordCommand(),
+			replayCommand(),
 		},
 	}
 
@@ -47,40 +64,121 @@ func Execute(ctx context.Context, args []string, version, commit string) error {
 func proxyStartCommand() *cli.Command {
 	return &cli.Command{
 		Name: "start",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:  "log-format",
-				Usage: "log format (text|json)",
-				Value: string(app.DefaultConfigLogFormat),
-			},
-			&cli.StringFlag{
-				Name:  "server--host",
-				Usage: "server host",
-				Value: app.DefaultConfigServerHost,
-			},
-			&cli.IntFlag{
-				Name:  "server--port",
-				Usage: "server port",
-				Value: int(app.DefaultConfigServerPort),
-			},
-			&cli.StringFlag{
-				Name:  "upstream--base-url",
-				Usage: "upstream API base URL",
-				Value: app.DefaultConfigUpstreamBaseURL,
+		Flags: append(proxyFlags(),
+			&cli.BoolFlag{
+				Name:  "daemon",
+				Usage: "run in the background, writing pid_file and logging to log_file",
 			},
-		},
+		),
 		Action: proxyStartAction,
 	}
 }
 
+// proxyFlags returns the config flags of the proxy server, shared by commands
+// that load the server configuration.
+func proxyFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "log-format",
+			Usage: "log format (text|json)",
+			Value: string(app.DefaultConfigLogFormat),
+		},
+		&cli.StringFlag{
+			Name:  "server--host",
+			Usage: "server host",
+			Value: app.DefaultConfigServerHost,
+		},
+		&cli.IntFlag{
+			Name:  "server--port",
+			Usage: "server port",
+			Value: int(app.DefaultConfigServerPort),
+		},
+		&cli.StringFlag{
+			Name:  "upstream--base-url",
+			Usage: "upstream API base URL",
+			Value: app.DefaultConfigUpstreamBaseURL,
+		},
+	}, authFlags()...)
+}
+
+// authFlags returns the flags selecting token storage and authentication method.
+// Single-hyphen aliases keep one-off invocations short.
+func authFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "auth--method",
+			Aliases: []string{"auth-method"},
+			Usage:   "authentication method (oauth|static)",
+			Value:   string(app.DefaultConfigAuthMethod),
+		},
+		&cli.StringFlag{
+			Name:    "auth--storage",
+			Aliases: []string{"auth-storage"},
+			Usage:   "token storage (keyring|file|env)",
+			Value:   string(app.DefaultConfigAuthStorage),
+		},
+		&cli.StringFlag{
+			Name:    "auth--file",
+			Aliases: []string{"auth-file"},
+			Usage:   "token file path for file storage",
+		},
+		&cli.StringFlag{
+			Name:    "auth--env-key",
+			Aliases: []string{"auth-env-key"},
+			Usage:   "environment variable holding the token for env storage",
+		},
+		&cli.StringFlag{
+			Name:    "auth--keyring-user",
+			Aliases: []string{"auth-keyring-user"},
+			Usage:   "keyring user for keyring storage",
+		},
+	}
+}
+
 func proxyStartAction(ctx context.Context, cmd *cli.Command) error {
+	return runService(ctx, func(ctx context.Context) error {
+		return proxyStart(ctx, cmd)
+	})
+}
+
+// proxyStart runs the proxy until ctx is canceled or it is stopped via the control socket.
+func proxyStart(ctx context.Context, cmd *cli.Command) error {
 	cfg, err := loadConfig(cmd.String("config"), cmd, os.Environ)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	if cmd.Bool("daemon") || isDaemon() {
+		if err := applyDaemonDefaults(cfg); err != nil {
+			return err
+		}
+	}
+	if cmd.Bool("daemon") {
+		return startDaemon(cmd, cfg)
+	}
+
+	var logOutput io.Writer = os.Stdout
+	if cfg.LogFile != "" {
+		logFile, err := logfile.Open(cfg.LogFile, logfile.Options{
+			MaxSize:    int64(cfg.LogRotation.MaxSize) << 20,
+			MaxAge:     cfg.LogRotation.MaxAge,
+			MaxBackups: cfg.LogRotation.MaxBackups,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = logFile.Close() }()
+		logOutput = logFile
+	}
+
 	// Set up observability before creating app
-	otelShutdown, err := observability.Instrument(ctx, cfg.LogLevel, string(cfg.LogFormat))
+	otelShutdown, err := observability.Instrument(ctx, observability.LogConfig{
+		Level:           cfg.LogLevel,
+		ComponentLevels: cfg.LogLevels,
+		Format:          string(cfg.LogFormat),
+		Output:          string(cfg.LogOutput),
+		Writer:          logOutput,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to set up observability layer: %w", err)
 	}
@@ -97,9 +195,62 @@ func proxyStartAction(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("failed to create app: %w", err)
 	}
 
+	// A process started by upgrade serves the listeners of the previous one
+	inherited, err := handover.Inherited()
+	if err != nil {
+		return err
+	}
+	takeover := inherited != nil
+	if takeover {
+		application.UseListeners(inherited)
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	inst := &instance{
+		cmd:       cmd,
+		stop:      stop,
+		startedAt: time.Now(),
+		cfg:       cfg,
+		app:       application,
+	}
+
+	if cfg.PidFile != "" {
+		if err := writePidFile(cfg.PidFile, takeover); err != nil {
+			return err
+		}
+		defer func() {
+			if err := removePidFile(cfg.PidFile); err != nil {
+				slog.ErrorContext(ctx, "failed to remove pidfile", "error", err)
+			}
+		}()
+	}
+
+	if cfg.ControlSocket != "" && cfg.ControlSocket != app.ControlSocketDisabled {
+		listen := control.Listen
+		if takeover {
+			listen = control.Takeover
+		}
+		controlServer, err := listen(cfg.ControlSocket, inst)
+		if err != nil {
+			slog.WarnContext(ctx, "control socket unavailable", "error", err)
+		} else {
+			defer func() {
+				closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = controlServer.Close(closeCtx)
+			}()
+		}
+	}
+
+	watchLogLevelSignals(ctx, cfg.LogLevel)
+	if takeover {
+		go reportHandoverReady(ctx, application)
+	}
+
 	slog.InfoContext(ctx, "starting")
 
-	if err := application.Start(ctx); err != nil {
+	if err := inst.run(ctx); err != nil {
 		return fmt.Errorf("app failed to start: %w", err)
 	}
 
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// serviceName identifies the Windows service.
+	serviceName = "claudine"
+
+	// serviceDisplayName is shown in the Windows services console.
+	serviceDisplayName = "Claudine Proxy"
+)
+
+// serviceCommand returns the 'service' subcommand for managing the Windows service.
+func serviceCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "service",
+		Usage: "Manage the Windows service",
+		Commands: []*cli.Command{
+			{
+				Name:  "install",
+				Usage: "Install claudine as a Windows service starting with the system",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "user",
+						Usage: `account the service runs as (e.g. ".\\alice"), defaults to LocalSystem`,
+					},
+					&cli.StringFlag{
+						Name:  "password",
+						Usage: "password of the service account",
+					},
+				},
+				Action: serviceInstallAction,
+			},
+			{
+				Name:  "uninstall",
+				Usage: "Remove the Windows service",
+				Action: func(context.Context, *cli.Command) error {
+					return uninstallService()
+				},
+			},
+			{
+				Name:  "start",
+				Usage: "Start the Windows service",
+				Action: func(context.Context, *cli.Command) error {
+					return startService()
+				},
+			},
+			{
+				Name:  "stop",
+				Usage: "Stop the Windows service gracefully",
+				Action: func(context.Context, *cli.Command) error {
+					return stopService()
+				},
+			},
+		},
+	}
+}
+
+// serviceInstallAction installs the service, passing the config file of this
+// invocation on to the service.
+func serviceInstallAction(_ context.Context, cmd *cli.Command) error {
+	args := []string{"start"}
+	if configPath := cmd.String("config"); configPath != "" {
+		abs, err := filepath.Abs(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to resolve config path: %w", err)
+		}
+		args = append([]string{"--config", abs}, args...)
+	}
+
+	if err := installService(args, cmd.String("user"), cmd.String("password")); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.Root().Writer, "service %q installed, start it with: claudine service start\n", serviceName)
+	return nil
+}
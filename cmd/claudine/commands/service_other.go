@@ -0,0 +1,21 @@
+//go:build !windows
+
+package commands
+
+import (
+	"context"
+	"errors"
+)
+
+// errServiceUnsupported is returned by service commands outside Windows.
+var errServiceUnsupported = errors.New("services are only supported on Windows; use systemd, launchd or start --daemon")
+
+func installService([]string, string, string) error { return errServiceUnsupported }
+func uninstallService() error                       { return errServiceUnsupported }
+func startService() error                           { return errServiceUnsupported }
+func stopService() error                            { return errServiceUnsupported }
+
+// runService runs fn directly; only Windows has a service manager to attach to.
+func runService(ctx context.Context, fn func(context.Context) error) error {
+	return fn(ctx)
+}
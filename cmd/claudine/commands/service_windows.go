@@ -0,0 +1,166 @@
+//go:build windows
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceStopTimeout bounds how long 'service stop' waits for graceful shutdown.
+const serviceStopTimeout = time.Minute
+
+// installService registers the current executable as an automatically started
+// service invoked with args.
+func installService(args []string, user, password string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager (run as administrator): %w", err)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		_ = s.Close()
+		return fmt.Errorf("service %q already installed", serviceName)
+	}
+
+	s, err := m.CreateService(serviceName, executable, mgr.Config{
+		DisplayName:      serviceDisplayName,
+		Description:      "Anthropic OAuth Ambassador",
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: user,
+		Password:         password,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+	return nil
+}
+
+// uninstallService stops and removes the service.
+func uninstallService() error {
+	return withService(func(s *mgr.Service) error {
+		if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+			if err := stopAndWait(s); err != nil {
+				return err
+			}
+		}
+		if err := s.Delete(); err != nil {
+			return fmt.Errorf("failed to delete service: %w", err)
+		}
+		return nil
+	})
+}
+
+// startService starts the installed service.
+func startService() error {
+	return withService(func(s *mgr.Service) error {
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("failed to start service: %w", err)
+		}
+		return nil
+	})
+}
+
+// stopService stops the service and waits for graceful shutdown.
+func stopService() error {
+	return withService(stopAndWait)
+}
+
+func withService(fn func(*mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager (run as administrator): %w", err)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %q not installed: %w", serviceName, err)
+	}
+	defer func() { _ = s.Close() }()
+	return fn(s)
+}
+
+func stopAndWait(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service: %w", err)
+		}
+	}
+	return nil
+}
+
+// runService runs fn under the Windows service manager if started by it, and
+// directly otherwise. Stop and shutdown requests cancel fn's context, triggering
+// graceful shutdown.
+func runService(ctx context.Context, fn func(context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return fn(ctx)
+	}
+
+	h := &serviceHandler{ctx: ctx, run: fn}
+	if err := svc.Run(serviceName, h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+// serviceHandler implements svc.Handler.
+type serviceHandler struct {
+	ctx context.Context
+	run func(context.Context) error
+	err error
+}
+
+// Execute implements svc.Handler.
+func (h *serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- h.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				h.err = err
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
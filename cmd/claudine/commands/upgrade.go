@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/florianilch/claudine-proxy/internal/app"
+	"github.com/florianilch/claudine-proxy/internal/handover"
+)
+
+// upgradeTimeout bounds how long the running instance waits for the new process
+// to become ready. Covers credential verification at startup.
+const upgradeTimeout = time.Minute
+
+// Upgrade implements control.Controller. The new process runs the executable now
+// found at this process's path with the same arguments and environment.
+func (i *instance) Upgrade(ctx context.Context) error {
+	i.mu.Lock()
+	application := i.app
+	i.mu.Unlock()
+
+	listeners, err := application.ListenerFiles()
+	if err != nil {
+		return fmt.Errorf("failed to hand over listeners: %w", err)
+	}
+	defer func() {
+		for _, f := range listeners {
+			_ = f.Close()
+		}
+	}()
+
+	executable, err := upgradeExecutable()
+	if err != nil {
+		return err
+	}
+
+	child, err := handover.Start(executable, slices.DeleteFunc(slices.Clone(os.Args[1:]), isDaemonFlag), listeners)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "upgrade started, waiting for new process", "pid", child.Pid())
+
+	ctx, cancel := context.WithTimeout(ctx, upgradeTimeout)
+	defer cancel()
+	if err := child.WaitReady(ctx); err != nil {
+		return fmt.Errorf("upgrade failed, still running: %w", err)
+	}
+
+	slog.InfoContext(ctx, "new process ready, draining", "pid", child.Pid())
+	i.stop()
+	return nil
+}
+
+// upgradeExecutable returns the path this process was started from. On Linux,
+// os.Executable reports a replaced binary with a " (deleted)" suffix.
+func upgradeExecutable() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate executable: %w", err)
+	}
+	return strings.TrimSuffix(executable, " (deleted)"), nil
+}
+
+// reportHandoverReady tells the previous process to stop once application is ready.
+func reportHandoverReady(ctx context.Context, application *app.App) {
+	select {
+	case <-application.Ready():
+		if err := handover.Ready(); err != nil {
+			slog.ErrorContext(ctx, "failed to complete upgrade", "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "took over from previous process")
+	case <-ctx.Done():
+	}
+}
+
+// upgradeCommand returns the 'upgrade' command.
+func upgradeCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "upgrade",
+		Usage: "Restart the running proxy with the current binary without dropping connections",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			client, err := controlClient(cmd)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, controlTimeout)
+			defer cancel()
+			return client.Upgrade(ctx)
+		},
+	}
+}
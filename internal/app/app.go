@@ -4,26 +4,61 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/cache"
+	"github.com/florianilch/claudine-proxy/internal/capability"
+	"github.com/florianilch/claudine-proxy/internal/capture"
+	"github.com/florianilch/claudine-proxy/internal/dashboard"
+	"github.com/florianilch/claudine-proxy/internal/grpcapi"
+	"github.com/florianilch/claudine-proxy/internal/metrics"
+	"github.com/florianilch/claudine-proxy/internal/pacing"
+	"github.com/florianilch/claudine-proxy/internal/plugin"
+	"github.com/florianilch/claudine-proxy/internal/policy"
 	"github.com/florianilch/claudine-proxy/internal/proxy"
-	anthropictokensource "github.com/florianilch/claudine-proxy/internal/tokensource"
+	"github.com/florianilch/claudine-proxy/internal/routing"
+	"github.com/florianilch/claudine-proxy/internal/shadow"
+	"github.com/florianilch/claudine-proxy/internal/storage"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+	"github.com/florianilch/claudine-proxy/internal/webhook"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/anthropicclaude"
+	anthropictokensource "github.com/florianilch/claudine-proxy/pkg/tokensource"
 )
 
 // App orchestrates the lifecycle of the proxy server and related services.
 type App struct {
-	cfg    *Config
-	proxy  *proxy.Proxy
-	health *Health
+	cfg      *Config
+	tokens   *PersistentTokenSource
+	proxy    *proxy.Proxy
+	health   *Health
+	plugins  []plugin.Filter
+	webhooks []*webhook.Dispatcher
+	shadow   *shadow.Mirror
+	cache    *cache.Counting
+	storage  *storage.Store
+	audit    *audit.Log
+
+	listeners []net.Listener // served instead of binding the server addresses, if set
+	ready     chan struct{}  // closed once Start reports ready
 }
 
-// New creates a new App instance.
-func New(cfg *Config) (*App, error) {
+// New creates a new App instance. Extra proxy options apply after those derived
+// from cfg.
+func New(cfg *Config, extra ...proxy.Option) (*App, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -36,34 +71,401 @@ func New(cfg *Config) (*App, error) {
 		return nil, fmt.Errorf("failed to create token source: %w", err)
 	}
 
-	proxyServer, err := proxy.New(tokenSource, health, proxy.WithBaseURL(cfg.Upstream.BaseURL))
+	plugins, err := newPlugins(context.Background(), cfg.Plugins)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugins: %w", err)
+	}
+
+	webhooks, err := newWebhooks(cfg.Webhooks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhooks: %w", err)
+	}
+	registry := metrics.NewRegistry()
+	errorMetrics := metrics.NewErrorCollector(registry)
+	sinks := []usage.Sink{metrics.NewUsageCollector(registry, cfg.Metrics.Tags)}
+	for _, w := range webhooks {
+		sinks = append(sinks, w)
+	}
+
+	var store *storage.Store
+	if cfg.Storage.Enabled {
+		store, err = storage.Open(storage.Dialect(cfg.Storage.Driver), cfg.Storage.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open storage: %w", err)
+		}
+		sinks = append(sinks, store)
+	}
+
+	auditLog, err := newAuditLog(cfg.Audit, store)
+	if err != nil {
+		return nil, err
+	}
+	tokenSource.audit = auditLog
+
+	var captureStore *capture.Store
+	if cfg.Capture.Dir != "" {
+		if captureStore, err = capture.Open(cfg.Capture.Dir, cfg.Capture.MaxAge); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Spool.Dir != "" {
+		if err := os.MkdirAll(cfg.Spool.Dir, 0o700); err != nil {
+			return nil, fmt.Errorf("create spool directory: %w", err)
+		}
+	}
+	revoked := &revocations{health: health, metrics: errorMetrics, webhook: cfg.Auth.RevocationWebhook}
+	revoked.watch(tokenSource, "")
+
+	var dash *dashboard.Dashboard
+	if cfg.Dashboard.Enabled {
+		dash = dashboard.New()
+		dash.TokenExpiry = tokenSource.Expiry
+		sinks = append(sinks, dash)
+	}
+
+	router, err := newRouter(cfg.Experiments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create router: %w", err)
+	}
+
+	policies, err := newPolicies(cfg.Policies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policies: %w", err)
+	}
+
+	capabilities, err := newCapabilities(cfg.Capabilities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model capabilities: %w", err)
+	}
+
+	impersonation, err := newImpersonationProfile(cfg.Upstream.Impersonation)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned, err := newPinnedAddresses(cfg.Upstream.BaseURL, cfg.Upstream.Transport.Addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []proxy.Option{
+		proxy.WithBaseURL(cfg.Upstream.BaseURL),
+		proxy.WithEndpoints(cfg.Upstream.Endpoints, cfg.Upstream.EndpointProbeInterval),
+		proxy.WithImpersonationProfile(impersonation),
+		proxy.WithTolerantInjection(cfg.Upstream.Impersonation.Tolerant),
+		proxy.WithBodySpool(cfg.Spool.Dir, cfg.Spool.MemoryLimit, cfg.Spool.MaxSize),
+		proxy.WithPlugins(plugins...),
+		proxy.WithUsageSinks(sinks...),
+		proxy.WithRouter(router),
+		proxy.WithPolicies(policies),
+		proxy.WithAuditLog(auditLog),
+		proxy.WithRequestSigning(signingKey(cfg.Audit)),
+		proxy.WithCapture(captureStore),
+		proxy.WithConversationBudget(cfg.Conversations.MaxTokens, cfg.Conversations.OverBudget == "warn"),
+		proxy.WithLoopDetection(proxy.LoopDetection{
+			RepeatedToolCalls: cfg.Conversations.LoopToolCalls,
+			RequestsPerMinute: cfg.Conversations.LoopRequestsPerMinute,
+			Notify:            loopNotifier(cfg.Conversations.LoopWebhook),
+		}),
+		proxy.WithCapabilities(capabilities),
+		proxy.WithAdminToken(cfg.Admin.Token),
+		proxy.WithUsageReports(cfg.Admin.UsageReports, cfg.Admin.APIKey),
+		proxy.WithStreamObservers(cfg.Admin.StreamObservers),
+		proxy.WithErrorMetrics(errorMetrics),
+		proxy.WithConnectionMetrics(metrics.NewConnectionCollector(registry)),
+		proxy.WithPacingMetrics(metrics.NewPacingCollector(registry)),
+		proxy.WithUserIDPrivacy(proxy.UserIDMode(cfg.Privacy.UserID), cfg.Privacy.Salt),
+		proxy.WithPassthrough(cfg.Upstream.Passthrough...),
+		proxy.WithAnthropicVersions(newAnthropicVersions(cfg.Upstream)),
+		proxy.WithRequestFreshness(cfg.Server.MaxRequestAge, cfg.Server.TimestampHeader),
+		proxy.WithClientKeys(cfg.Auth.ClientKeys),
+		proxy.WithFallbackAPIKey(cfg.Auth.FallbackAPIKey),
+		proxy.WithStreamIdleTimeout(cfg.Upstream.StreamIdleTimeout),
+		proxy.WithUpstreamCompression(cfg.Upstream.CompressRequests),
+		proxy.WithTransport(proxy.NewTransport(proxy.TransportSettings{
+			MaxIdleConnsPerHost:   cfg.Upstream.Transport.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       cfg.Upstream.Transport.MaxConnsPerHost,
+			TLSHandshakeTimeout:   cfg.Upstream.Transport.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: cfg.Upstream.Transport.ResponseHeaderTimeout,
+			DisableHTTP2:          cfg.Upstream.Transport.DisableHTTP2,
+			DNSCacheTTL:           cfg.Upstream.Transport.DNSCacheTTL,
+			PinnedAddresses:       pinned,
+		})),
+		proxy.WithAdapterDebug(cfg.OpenAI.DebugLog),
+		proxy.WithAdapterDiff(cfg.OpenAI.DiffEndpoint),
+		proxy.WithDryRun(cfg.OpenAI.DryRun),
+		proxy.WithToolArgumentRepair(cfg.OpenAI.RepairToolArguments),
+		proxy.WithStrictToolRetries(cfg.OpenAI.StrictToolRetries),
+		proxy.WithDeveloperPlacement(cfg.OpenAI.DeveloperMessages),
+		proxy.WithInlineSystemMessages(cfg.OpenAI.InlineSystemMessages),
+		proxy.WithPromptCaching(cfg.OpenAI.PromptCacheSessions),
+		proxy.WithTTFTTrailer(cfg.Server.TTFTTrailer),
+		proxy.WithModelRefresh(cfg.Upstream.ModelRefresh),
+		proxy.WithOpenAIRoutes(!cfg.OpenAI.Disabled),
+		proxy.WithNativeRoutes(!cfg.Native.Disabled),
+		proxy.WithNativeStreamFilter(proxy.StreamFilter{
+			DropPings:       cfg.Native.Stream.DropPings,
+			NormalizeErrors: cfg.Native.Stream.NormalizeErrors,
+			LogEventCounts:  cfg.Native.Stream.LogEventCounts,
+		}),
+		proxy.WithNativeErrorRewrite(cfg.Native.RewriteErrors),
+		proxy.WithMessagesValidation(cfg.Native.Validate),
+		proxy.WithWebSocketOrigins(cfg.OpenAI.WebSocketOrigins...),
+		proxy.WithServerLimits(proxy.ServerLimits{
+			ReadTimeout:    cfg.Server.ReadTimeout,
+			WriteTimeout:   cfg.Server.WriteTimeout,
+			IdleTimeout:    cfg.Server.IdleTimeout,
+			MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+			MaxConnections: cfg.Server.MaxConnections,
+		}),
+	}
+
+	if dash != nil {
+		opts = append(opts, proxy.WithDashboard(dash))
+	}
+
+	// The metrics endpoint is unauthenticated, so it is opt-in
+	if cfg.Metrics.Enabled {
+		opts = append(opts, proxy.WithMetrics(registry))
+	}
+
+	if len(cfg.OpenAI.ReasoningBudgets) > 0 {
+		budgets := make(anthropicclaude.ReasoningBudgets, 0, len(cfg.OpenAI.ReasoningBudgets))
+		for _, b := range cfg.OpenAI.ReasoningBudgets {
+			budgets = append(budgets, anthropicclaude.ReasoningBudget{Model: b.Model, Low: b.Low, Medium: b.Medium, High: b.High})
+		}
+		opts = append(opts, proxy.WithReasoningBudgets(budgets...))
+		// Policies limit the budget the adapter will actually request
+		policies.ReasoningBudget = func(model, effort string) int {
+			return int(budgets.Budget(model, effort))
+		}
+	}
+
+	switch cfg.OpenAI.ReasoningContent {
+	case "full":
+		opts = append(opts, proxy.WithReasoningContent(&anthropicclaude.ReasoningContent{}))
+	case "summary":
+		opts = append(opts, proxy.WithReasoningContent(&anthropicclaude.ReasoningContent{
+			SummaryInterval: cfg.OpenAI.ReasoningSummaryInterval,
+			SummaryLength:   cfg.OpenAI.ReasoningSummaryLength,
+		}))
+	}
+
+	if len(cfg.OpenAI.AzureDeployments) > 0 {
+		deployments := make(map[string]string, len(cfg.OpenAI.AzureDeployments))
+		for _, d := range cfg.OpenAI.AzureDeployments {
+			deployments[d.Name] = d.Model
+		}
+		opts = append(opts, proxy.WithAzureDeployments(deployments))
+	}
+
+	// Surface listeners in the order proxy.Serve expects inherited listeners
+	if cfg.Native.Listen != "" {
+		opts = append(opts, proxy.WithSurfaceListener(proxy.SurfaceNative, cfg.Native.Listen))
+	}
+	if cfg.OpenAI.Listen != "" {
+		opts = append(opts, proxy.WithSurfaceListener(proxy.SurfaceOpenAI, cfg.OpenAI.Listen))
+	}
+
+	for _, f := range cfg.OpenAI.Forward {
+		opts = append(opts, proxy.WithForward(f.Path, f.BaseURL, f.APIKey))
+	}
+
+	if p := cfg.Upstream.Pacing; p.RequestsPerMinute > 0 || p.InputTokensPerMinute > 0 {
+		opts = append(opts, proxy.WithPacing(pacing.Config{
+			RequestsPerMinute:    p.RequestsPerMinute,
+			InputTokensPerMinute: p.InputTokensPerMinute,
+			Burst:                p.Burst,
+			MaxWait:              p.MaxWait,
+		}))
+	}
+
+	if len(cfg.Tenants) > 0 {
+		tenants, err := newTenants(cfg.Tenants, auditLog, revoked, errorMetrics.TokenRefreshFailed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tenants: %w", err)
+		}
+		opts = append(opts, proxy.WithTenants(tenants...))
+	}
+
+	if q := cfg.Upstream.Queue; q.Enabled {
+		opts = append(opts, proxy.WithRateLimitQueue(pacing.QueueConfig{
+			MaxSize: q.MaxSize,
+			MaxWait: q.MaxWait,
+		}))
+	}
+
+	var responseCache *cache.Counting
+	if cfg.Cache.Enabled {
+		store, err := newCache(cfg.Cache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create response cache: %w", err)
+		}
+		responseCache = cache.NewCounting(store)
+		opts = append(opts, proxy.WithCache(responseCache, cfg.Cache.TTL))
+	}
+
+	var mirror *shadow.Mirror
+	if cfg.Shadow.Percent > 0 {
+		mirror, err = shadow.New(shadow.Config{
+			Percent: cfg.Shadow.Percent,
+			BaseURL: cfg.Shadow.BaseURL,
+			Model:   cfg.Shadow.Model,
+			Timeout: cfg.Shadow.Timeout,
+			Store:   cfg.Shadow.Store,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create shadow mirror: %w", err)
+		}
+		opts = append(opts, proxy.WithShadow(mirror))
+	}
+
+	opts = append(opts, extra...)
+
+	proxyServer, err := proxy.New(&countingTokenSource{
+		TokenSource: tokenSource,
+		failed:      errorMetrics.TokenRefreshFailed,
+	}, health, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy: %w", err)
 	}
 
 	return &App{
-		cfg:    cfg,
-		proxy:  proxyServer,
-		health: health,
+		cfg:      cfg,
+		tokens:   tokenSource,
+		proxy:    proxyServer,
+		health:   health,
+		plugins:  plugins,
+		webhooks: webhooks,
+		shadow:   mirror,
+		cache:    responseCache,
+		storage:  store,
+		audit:    auditLog,
+		ready:    make(chan struct{}),
 	}, nil
 }
 
 // Start starts all services and blocks until shutdown is triggered.
 // Uses errgroup for runtime error monitoring and shutdown function collection for coordinated cleanup.
 func (a *App) Start(ctx context.Context) error {
+	// Fail before any service starts, so nothing needs to be cleaned up
+	if a.cfg.Startup.VerifyToken {
+		slog.InfoContext(ctx, "verifying credentials")
+		if err := verifyToken(ctx, a.tokens, a.cfg.Startup.VerifyTimeout); err != nil {
+			return fmt.Errorf("credential verification failed: %w", err)
+		}
+	}
+
 	g, gCtx := errgroup.WithContext(ctx)
 
 	address := a.cfg.Server.Host + ":" + strconv.FormatUint(uint64(a.cfg.Server.Port), 10)
 	var shutdownFuncs []func(context.Context) error
 
+	// Plugin processes are started lazily but must be stopped after the proxy drained
+	shutdownFuncs = append(shutdownFuncs, a.closePlugins)
+
+	if a.cache != nil {
+		shutdownFuncs = append(shutdownFuncs, func(context.Context) error { return a.cache.Close() })
+	}
+
+	// Shadow requests may outlive their primary request
+	if a.shadow != nil {
+		shutdownFuncs = append(shutdownFuncs, a.shadow.Close)
+	}
+
+	// Webhooks drain after the proxy stopped producing completion events
+	for _, w := range a.webhooks {
+		w.Start(gCtx)
+		shutdownFuncs = append(shutdownFuncs, w.Shutdown)
+	}
+
+	// Policy rejections are audited until the proxy stopped
+	if a.audit != nil {
+		shutdownFuncs = append(shutdownFuncs, func(context.Context) error { return a.audit.Close() })
+	}
+
+	// Storage, like webhooks, persists completion events until the proxy stopped
+	if a.storage != nil {
+		if err := a.storage.Migrate(gCtx); err != nil {
+			return fmt.Errorf("storage migration failed: %w", err)
+		}
+		if err := a.storage.SyncKeys(gCtx, virtualKeys(a.cfg)); err != nil {
+			return fmt.Errorf("failed to store virtual keys: %w", err)
+		}
+		a.storage.Start(gCtx)
+		shutdownFuncs = append(shutdownFuncs, a.storage.Shutdown)
+	}
+
 	// Startup phase: Start services
-	slog.InfoContext(gCtx, "starting proxy server", "address", address)
-	proxyErrCh, err := a.proxy.Start(gCtx, address)
+	var proxyErrCh <-chan error
+	var err error
+	if a.listeners != nil {
+		slog.InfoContext(gCtx, "starting proxy server on inherited listeners", "address", a.listeners[0].Addr().String())
+		proxyErrCh, err = a.proxy.Serve(gCtx, a.listeners...)
+	} else {
+		slog.InfoContext(gCtx, "starting proxy server", "address", address)
+		proxyErrCh, err = a.proxy.Start(gCtx, address)
+	}
 	if err != nil {
 		return fmt.Errorf("proxy startup failed: %w", err)
 	}
+	if a.cfg.Native.Listen != "" {
+		slog.InfoContext(gCtx, "serving native API on its own listener", "address", a.cfg.Native.Listen)
+	}
+	if a.cfg.OpenAI.Listen != "" {
+		slog.InfoContext(gCtx, "serving OpenAI API on its own listener", "address", a.cfg.OpenAI.Listen)
+	}
 	shutdownFuncs = append(shutdownFuncs, a.proxy.Shutdown)
 
+	if a.cfg.GRPC.Address != "" {
+		slog.InfoContext(gCtx, "starting gRPC server", "address", a.cfg.GRPC.Address)
+		upstream, err := url.Parse(a.cfg.Upstream.BaseURL)
+		if err != nil {
+			return fmt.Errorf("invalid upstream URL: %w", err)
+		}
+		grpcServer := grpcapi.New(a.proxy.SurfaceHandler(proxy.SurfaceOpenAI), upstream.Path+"/chat/completions")
+		grpcErrCh, err := grpcServer.Start(a.cfg.GRPC.Address)
+		if err != nil {
+			return fmt.Errorf("gRPC server startup failed: %w", err)
+		}
+		shutdownFuncs = append(shutdownFuncs, grpcServer.Shutdown)
+
+		g.Go(func() error {
+			select {
+			case err := <-grpcErrCh:
+				if err != nil {
+					slog.ErrorContext(gCtx, "gRPC server runtime error", "error", err)
+					return fmt.Errorf("gRPC server: %w", err)
+				}
+				return nil
+			case <-gCtx.Done():
+				return nil
+			}
+		})
+	}
+
+	if a.cfg.Debug.Address != "" {
+		slog.InfoContext(gCtx, "starting debug server", "address", a.cfg.Debug.Address)
+		debugServer, debugErrCh, err := startDebugServer(gCtx, a.cfg.Debug.Address, a.cfg)
+		if err != nil {
+			return fmt.Errorf("debug server startup failed: %w", err)
+		}
+		shutdownFuncs = append(shutdownFuncs, debugServer.Shutdown)
+
+		g.Go(func() error {
+			select {
+			case err := <-debugErrCh:
+				if err != nil {
+					slog.ErrorContext(gCtx, "debug server runtime error", "error", err)
+					return fmt.Errorf("debug server: %w", err)
+				}
+				return nil
+			case <-gCtx.Done():
+				return nil
+			}
+		})
+	}
+
 	// Monitor runtime errors - errgroup cancels context on first error
 	g.Go(func() error {
 		select {
@@ -79,6 +481,7 @@ func (a *App) Start(ctx context.Context) error {
 	})
 
 	a.health.SetReady(true)
+	close(a.ready)
 	slog.InfoContext(gCtx, "application ready", "address", address)
 
 	runtimeErr := g.Wait()
@@ -111,6 +514,436 @@ func (a *App) Start(ctx context.Context) error {
 	return nil
 }
 
+// UseListeners makes Start serve on listeners instead of binding the server
+// addresses, e.g. listeners inherited from the previous process during an upgrade.
+// They must be in the order ListenerFiles returns them.
+func (a *App) UseListeners(listeners []net.Listener) {
+	a.listeners = listeners
+}
+
+// ListenerFiles returns duplicates of the proxy's listening sockets for handing
+// them over to a new process. The caller must close them.
+func (a *App) ListenerFiles() ([]*os.File, error) {
+	return a.proxy.ListenerFiles()
+}
+
+// Ready returns a channel that is closed once Start reports the application ready.
+func (a *App) Ready() <-chan struct{} {
+	return a.ready
+}
+
+// ActiveStreams returns the number of streaming responses currently being relayed.
+func (a *App) ActiveStreams() int64 {
+	return a.proxy.ActiveStreams()
+}
+
+// TokenExpiry returns when the current access token expires.
+// Zero if no token was issued yet or the token does not expire.
+func (a *App) TokenExpiry() time.Time {
+	return a.tokens.Expiry()
+}
+
+// Degraded returns the degraded components, such as accounts with a revoked
+// refresh token, and their reasons. Nil if there are none.
+func (a *App) Degraded() map[string]string {
+	return a.health.Degraded()
+}
+
+// Handler returns the handler of the main routes, for serving requests in-process
+// without starting the servers.
+func (a *App) Handler() http.Handler {
+	return a.proxy
+}
+
+// Audit returns the audit log, or nil if auditing is disabled.
+func (a *App) Audit() *audit.Log {
+	return a.audit
+}
+
+// Cache returns the response cache, or nil if caching is disabled.
+func (a *App) Cache() *cache.Counting {
+	return a.cache
+}
+
+// closePlugins terminates all plugin processes.
+func (a *App) closePlugins(context.Context) error {
+	var errs []error
+	for _, p := range a.plugins {
+		if err := p.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", p.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newPlugins creates plugin filters from configuration. No process is started,
+// WebAssembly modules are compiled eagerly to surface errors at startup.
+func newPlugins(ctx context.Context, cfgs []PluginConfig) ([]plugin.Filter, error) {
+	plugins := make([]plugin.Filter, 0, len(cfgs))
+	for _, c := range cfgs {
+		if c.Wasm != "" {
+			m, err := plugin.NewWasm(ctx, plugin.WasmConfig{
+				Name:           c.Name,
+				Path:           c.Wasm,
+				Timeout:        c.Timeout,
+				FailOpen:       c.FailOpen,
+				MaxMemoryPages: c.MaxMemoryMB * 16, // 64KiB pages
+			})
+			if err != nil {
+				return nil, err
+			}
+			plugins = append(plugins, m)
+			continue
+		}
+
+		phases := make([]plugin.Phase, 0, len(c.Phases))
+		for _, ph := range c.Phases {
+			phases = append(phases, plugin.Phase(ph))
+		}
+		p, err := plugin.New(plugin.Config{
+			Name:     c.Name,
+			Command:  c.Command,
+			Args:     c.Args,
+			Phases:   phases,
+			Timeout:  c.Timeout,
+			FailOpen: c.FailOpen,
+		})
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+// newWebhooks creates webhook dispatchers from configuration.
+func newWebhooks(cfgs []WebhookConfig) ([]*webhook.Dispatcher, error) {
+	webhooks := make([]*webhook.Dispatcher, 0, len(cfgs))
+	for _, c := range cfgs {
+		d, err := webhook.New(webhook.Config{
+			URL:        c.URL,
+			Secret:     c.Secret,
+			Timeout:    c.Timeout,
+			MaxRetries: c.MaxRetries,
+		})
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, d)
+	}
+	return webhooks, nil
+}
+
+// newCache creates the response cache: an in-memory LRU, optionally backed by disk or Redis.
+func newCache(cfg CacheConfig) (cache.Store, error) {
+	memory := cache.NewMemory(cfg.MaxEntries)
+
+	switch cfg.Backend {
+	case CacheBackendDisk:
+		disk, err := cache.NewDisk(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		return &cache.Tiered{L1: memory, L2: disk}, nil
+	case CacheBackendRedis:
+		redis, err := cache.NewRedis(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		return &cache.Tiered{L1: memory, L2: redis}, nil
+	default:
+		return memory, nil
+	}
+}
+
+// newRouter creates the A/B model router from configuration.
+func newRouter(cfgs []ExperimentConfig) (*routing.Router, error) {
+	experiments := make([]routing.Experiment, 0, len(cfgs))
+	for _, c := range cfgs {
+		arms := make([]routing.Arm, 0, len(c.Arms))
+		for _, a := range c.Arms {
+			arms = append(arms, routing.Arm{Name: a.Name, Model: a.Model, Weight: a.Weight})
+		}
+		experiments = append(experiments, routing.Experiment{
+			Name:   c.Name,
+			Model:  c.Model,
+			Sticky: routing.Sticky(c.Sticky),
+			Arms:   arms,
+		})
+	}
+	return routing.New(experiments)
+}
+
+// newPolicies creates the per-key policy enforcer from configuration.
+func newPolicies(cfgs []PolicyConfig) (*policy.Enforcer, error) {
+	policies := make([]policy.Policy, 0, len(cfgs))
+	for _, c := range cfgs {
+		priority, err := pacing.ParsePriority(c.Priority)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", c.Name, err)
+		}
+		policies = append(policies, policy.Policy{
+			Name:               c.Name,
+			Keys:               c.Keys,
+			Models:             c.Models,
+			MaxTokens:          c.MaxTokens,
+			MaxReasoningBudget: c.MaxReasoningBudget,
+			Priority:           priority,
+		})
+	}
+	return policy.New(policies)
+}
+
+// virtualKeys returns the client keys configured for tenants and policies as
+// stored by storage. Raw keys are reduced to their usage key IDs.
+func virtualKeys(cfg *Config) []storage.VirtualKey {
+	var keys []storage.VirtualKey
+	index := make(map[string]int)
+	add := func(key, name, tenant string) {
+		if key == "*" {
+			return
+		}
+		id := key
+		if !strings.HasPrefix(key, "key_") {
+			id = usage.KeyIDOf(key)
+		}
+		i, ok := index[id]
+		if !ok {
+			i = len(keys)
+			index[id] = i
+			keys = append(keys, storage.VirtualKey{ID: id})
+		}
+		if name != "" {
+			keys[i].Name = name
+		}
+		if tenant != "" {
+			keys[i].Tenant = tenant
+		}
+	}
+	for _, t := range cfg.Tenants {
+		for _, k := range t.Keys {
+			add(k, "", t.Name)
+		}
+	}
+	for _, p := range cfg.Policies {
+		for _, k := range p.Keys {
+			add(k, p.Name, "")
+		}
+	}
+	return keys
+}
+
+// newCapabilities creates the model capability registry from the built-in models
+// and configured ones, or returns nil if checks are disabled.
+func newCapabilities(cfg CapabilitiesConfig) (*capability.Registry, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	models := capability.Defaults()
+	for _, c := range cfg.Models {
+		models = append(models, capability.Model{
+			ID:              c.ID,
+			Aliases:         c.Aliases,
+			ContextWindow:   c.ContextWindow,
+			MaxOutputTokens: c.MaxOutputTokens,
+			Thinking:        c.Thinking,
+			WebSearch:       c.WebSearch,
+			Vision:          c.Vision,
+		})
+	}
+	return capability.New(models)
+}
+
+// newImpersonationProfile resolves the configured built-in profile and applies overrides.
+func newImpersonationProfile(cfg ImpersonationConfig) (proxy.ImpersonationProfile, error) {
+	profile, ok := proxy.LookupImpersonationProfile(cfg.Profile)
+	if !ok {
+		return profile, fmt.Errorf("unknown impersonation profile %q", cfg.Profile)
+	}
+	if cfg.UserAgent != "" {
+		profile.UserAgent = cfg.UserAgent
+	}
+	if cfg.App != "" {
+		profile.App = cfg.App
+	}
+	if cfg.BetaFeatures != nil {
+		profile.BetaFeatures = cfg.BetaFeatures
+	}
+	if cfg.UserID != "" {
+		profile.UserID = cfg.UserID
+	}
+	for key, value := range cfg.Headers {
+		if profile.Headers == nil {
+			profile.Headers = make(map[string]string)
+		}
+		key = http.CanonicalHeaderKey(key)
+		if value == "" {
+			delete(profile.Headers, key)
+		} else {
+			profile.Headers[key] = value
+		}
+	}
+	return profile, nil
+}
+
+// newPinnedAddresses pins the host of baseURL to addresses, if any.
+func newPinnedAddresses(baseURL string, addresses []string) (map[string][]netip.Addr, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream URL: %w", err)
+	}
+	addrs := make([]netip.Addr, 0, len(addresses))
+	for _, a := range addresses {
+		addr, err := netip.ParseAddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream address: %w", err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return map[string][]netip.Addr{u.Hostname(): addrs}, nil
+}
+
+// newTenants creates the tenants' token sources and rate limits from configuration.
+// Like the default token source, no I/O is performed until first use.
+func newTenants(cfgs []TenantConfig, auditLog *audit.Log, revoked *revocations, failed func()) ([]proxy.Tenant, error) {
+	tenants := make([]proxy.Tenant, 0, len(cfgs))
+	for _, c := range cfgs {
+		tokenSource, err := newTokenSource(c.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", c.Name, err)
+		}
+		tokenSource.audit, tokenSource.account = auditLog, c.Name
+		revoked.watch(tokenSource, c.Name)
+		tenant := proxy.Tenant{
+			Name:         c.Name,
+			Keys:         c.Keys,
+			Hosts:        c.Hosts,
+			TokenSource:  &countingTokenSource{TokenSource: tokenSource, failed: failed},
+			ModelAliases: c.ModelAliases,
+			Pool:         c.Pool,
+		}
+		if p := c.Pacing; p.RequestsPerMinute > 0 || p.InputTokensPerMinute > 0 {
+			tenant.Pacing = &pacing.Config{
+				RequestsPerMinute:    p.RequestsPerMinute,
+				InputTokensPerMinute: p.InputTokensPerMinute,
+				Burst:                p.Burst,
+				MaxWait:              p.MaxWait,
+			}
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, nil
+}
+
+// newAuditLog opens the configured audit log, recording to store if enabled.
+// Returns nil if auditing is disabled.
+func newAuditLog(cfg AuditConfig, store *storage.Store) (*audit.Log, error) {
+	var recorder audit.Recorder
+	if cfg.Storage && store != nil {
+		recorder = store
+	}
+	auditLog, err := audit.Open(cfg.File, recorder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return auditLog, nil
+}
+
+// OpenAuditLog opens the audit log configured in cfg for commands running outside
+// the proxy. The returned function closes the log and its database, if any.
+func OpenAuditLog(ctx context.Context, cfg *Config) (*audit.Log, func() error, error) {
+	var store *storage.Store
+	if cfg.Audit.Storage && cfg.Storage.Enabled {
+		var err error
+		store, err = storage.Open(storage.Dialect(cfg.Storage.Driver), cfg.Storage.DSN)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open storage: %w", err)
+		}
+		if err := store.Migrate(ctx); err != nil {
+			_ = store.Close()
+			return nil, nil, fmt.Errorf("storage migration failed: %w", err)
+		}
+	}
+
+	auditLog, err := newAuditLog(cfg.Audit, store)
+	if err != nil {
+		if store != nil {
+			_ = store.Close()
+		}
+		return nil, nil, err
+	}
+	return auditLog, func() error {
+		err := auditLog.Close()
+		if store != nil {
+			err = errors.Join(err, store.Close())
+		}
+		return err
+	}, nil
+}
+
+// signingKey returns the key signing upstream request digests, or nil if signing is disabled.
+func signingKey(cfg AuditConfig) []byte {
+	if cfg.SigningKey == "" {
+		return nil
+	}
+	return []byte(cfg.SigningKey)
+}
+
+// FindAuditEvents returns the events with action and target in the audit log
+// configured in cfg, from its file and database.
+func FindAuditEvents(ctx context.Context, cfg *Config, action, target string) ([]audit.Event, error) {
+	var events []audit.Event
+	if cfg.Audit.File != "" {
+		fileEvents, err := audit.ReadFile(cfg.Audit.File)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		events = fileEvents
+	}
+	if cfg.Audit.Storage && cfg.Storage.Enabled {
+		store, err := storage.Open(storage.Dialect(cfg.Storage.Driver), cfg.Storage.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open storage: %w", err)
+		}
+		defer func() { _ = store.Close() }()
+		if err := store.Migrate(ctx); err != nil {
+			return nil, fmt.Errorf("storage migration failed: %w", err)
+		}
+		stored, err := store.Audit(ctx, time.Time{}, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range stored {
+			// Events recorded to both are listed once
+			if !slices.ContainsFunc(events, func(f audit.Event) bool { return f.ID == e.ID }) {
+				events = append(events, audit.Event{ID: e.ID, Timestamp: e.Timestamp, Action: e.Action, Actor: e.Actor, Target: e.Target, Detail: e.Detail})
+			}
+		}
+	}
+	return slices.DeleteFunc(events, func(e audit.Event) bool {
+		return e.Action != action || e.Target != target
+	}), nil
+}
+
+// newAnthropicVersions returns the configured Anthropic API versions, warning
+// about versions this release wasn't tested with.
+func newAnthropicVersions(cfg UpstreamConfig) proxy.AnthropicVersions {
+	warn := func(v string) {
+		if v != "" && !proxy.KnownAnthropicVersion(v) {
+			slog.Warn("Anthropic API version not known to this release, responses may not be translated correctly",
+				"version", v, "known", proxy.KnownAnthropicVersions)
+		}
+	}
+	warn(cfg.AnthropicVersion)
+	for _, v := range cfg.AnthropicVersions {
+		warn(v)
+	}
+	return proxy.AnthropicVersions{Default: cfg.AnthropicVersion, Routes: cfg.AnthropicVersions}
+}
+
 // newTokenSource creates a PersistentTokenSource from application configuration.
 // No I/O is performed - TokenSource creation is deferred to first Token() call.
 func newTokenSource(cfg AuthConfig) (*PersistentTokenSource, error) {
@@ -126,6 +959,9 @@ func newTokenSource(cfg AuthConfig) (*PersistentTokenSource, error) {
 		factory = func(token string) oauth2.TokenSource {
 			return anthropictokensource.NewTokenSource(token, anthropictokensource.Endpoint)
 		}
+		if cfg.LockedMemory {
+			factory = lockedFactory(factory)
+		}
 	case AuthenticationMethodStatic:
 		factory = func(token string) oauth2.TokenSource {
 			return oauth2.StaticTokenSource(&oauth2.Token{
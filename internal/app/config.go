@@ -6,13 +6,25 @@ import (
 	"log/slog"
 	"os"
 	"os/user"
+	"path"
 	"path/filepath"
 	"time"
 
+	"github.com/florianilch/claudine-proxy/internal/proxy"
+	"github.com/florianilch/claudine-proxy/internal/secret"
 	"github.com/florianilch/claudine-proxy/internal/tokenstore"
 	"github.com/go-playground/validator/v10"
 )
 
+// LogOutput represents where logs are written.
+type LogOutput string
+
+const (
+	LogOutputStdout   LogOutput = "stdout"
+	LogOutputSyslog   LogOutput = "syslog"
+	LogOutputJournald LogOutput = "journald"
+)
+
 // LogFormat represents the logging output format.
 type LogFormat string
 
@@ -30,6 +42,32 @@ const (
 	TokenStorageTypeKeyring TokenStorageType = "keyring"
 )
 
+// UserIDMode represents how end-user identifiers are forwarded upstream.
+type UserIDMode string
+
+const (
+	UserIDModePassthrough UserIDMode = "passthrough"
+	UserIDModeHash        UserIDMode = "hash"
+	UserIDModeDrop        UserIDMode = "drop"
+)
+
+// CacheBackend represents the storage used for cached responses.
+type CacheBackend string
+
+const (
+	CacheBackendMemory CacheBackend = "memory"
+	CacheBackendDisk   CacheBackend = "disk"
+	CacheBackendRedis  CacheBackend = "redis"
+)
+
+// StorageDriver represents the database used for persistent accounting data.
+type StorageDriver string
+
+const (
+	StorageDriverSQLite   StorageDriver = "sqlite"
+	StorageDriverPostgres StorageDriver = "postgres"
+)
+
 // AuthenticationMethod represents the different authentication methods supported.
 type AuthenticationMethod string
 
@@ -38,21 +76,77 @@ const (
 	AuthenticationMethodOAuth  AuthenticationMethod = "oauth"
 )
 
+// ControlSocketDisabled turns off the control socket.
+const ControlSocketDisabled = "none"
+
 // Default configuration values
 const (
 	DefaultConfigLogFormat       = LogFormatText
+	DefaultConfigLogOutput       = LogOutputStdout
 	DefaultConfigServerHost      = "127.0.0.1"
 	DefaultConfigServerPort      = 4000
 	DefaultConfigShutdownTimeout = 5 * time.Second
+	DefaultConfigReadTimeout     = 30 * time.Second
+	DefaultConfigWriteTimeout    = 15 * time.Minute
+	DefaultConfigIdleTimeout     = 90 * time.Second
+	DefaultConfigMaxHeaderBytes  = 1 << 20
 	DefaultConfigAuthStorage     = TokenStorageTypeKeyring
 	DefaultConfigAuthMethod      = AuthenticationMethodOAuth
 	DefaultConfigUpstreamBaseURL = "https://api.anthropic.com/v1"
+	DefaultConfigImpersonation   = "minimal"
+	DefaultConfigPluginTimeout   = 5 * time.Second
+	DefaultConfigWebhookTimeout  = 5 * time.Second
+	DefaultConfigShadowTimeout   = 5 * time.Minute
+	DefaultConfigCacheTTL        = 10 * time.Minute
+	DefaultConfigCacheMaxEntries = 1000
+	DefaultConfigCacheBackend    = CacheBackendMemory
+	DefaultConfigStorageDriver   = StorageDriverSQLite
+	DefaultConfigPacingMaxWait   = 30 * time.Second
+	DefaultConfigQueueMaxSize    = 100
+	DefaultConfigQueueMaxWait    = time.Minute
+	DefaultConfigPrivacyUserID   = UserIDModePassthrough
+	DefaultConfigCaptureMaxAge   = 24 * time.Hour
+	DefaultConfigSpoolMemory     = 1 << 20
+
+	DefaultConfigStreamIdleTimeout = 2 * time.Minute
+	DefaultConfigVerifyTimeout     = 30 * time.Second
+
+	DefaultConfigReasoningSummaryInterval = 500
+	DefaultConfigReasoningSummaryLength   = 120
 )
 
 // ServerConfig holds server-specific configuration.
 type ServerConfig struct {
 	Host string `json:"host" validate:"hostname_rfc1123|ip"`
 	Port uint16 `json:"port"` // Port range 0-65535 handled by uint16 type
+
+	// ReadTimeout bounds reading an entire client request. Negative disables it.
+	ReadTimeout time.Duration `json:"read_timeout"`
+
+	// WriteTimeout bounds writing an entire response, including SSE streams. Negative disables it.
+	WriteTimeout time.Duration `json:"write_timeout"`
+
+	// IdleTimeout is the keep-alive wait for a client's next request. Negative disables it.
+	IdleTimeout time.Duration `json:"idle_timeout"`
+
+	// MaxHeaderBytes limits the size of request headers.
+	MaxHeaderBytes int `json:"max_header_bytes" validate:"min=0"`
+
+	// MaxConnections limits concurrently accepted connections (0 = unlimited).
+	MaxConnections int `json:"max_connections" validate:"min=0"`
+
+	// TTFTTrailer ends streamed responses with an X-Claudine-Ttft trailer holding
+	// the time to first token in milliseconds.
+	TTFTTrailer bool `json:"ttft_trailer"`
+
+	// MaxRequestAge rejects API requests whose TimestampHeader is missing or further
+	// than this from the proxy's clock, e.g. replays of requests signed at the edge.
+	// Zero disables the check.
+	MaxRequestAge time.Duration `json:"max_request_age" validate:"min=0"`
+
+	// TimestampHeader holds the request time checked against MaxRequestAge: an HTTP
+	// date, an RFC 3339 timestamp or Unix seconds. Defaults to Date.
+	TimestampHeader string `json:"timestamp_header"`
 }
 
 // ShutdownConfig holds shutdown behavior configuration.
@@ -66,7 +160,527 @@ type ShutdownConfig struct {
 
 // UpstreamConfig holds upstream API configuration.
 type UpstreamConfig struct {
+	BaseURL string       `json:"base_url" validate:"required,url"`
+	Pacing  PacingConfig `json:"pacing"`
+	Queue   QueueConfig  `json:"queue"`
+
+	// Passthrough lists additional request paths forwarded to the upstream as-is
+	// (e.g., "/v1/files/*"). A trailing "/*" matches the path and everything below it.
+	Passthrough []string `json:"passthrough" validate:"dive,startswith=/"`
+
+	// Endpoints are additional base URLs serving the same API, such as regional
+	// gateways, with the same path as BaseURL. Requests go to the healthy endpoint
+	// with the lowest latency, probed every EndpointProbeInterval (default 30s).
+	Endpoints             []string      `json:"endpoints" validate:"dive,url"`
+	EndpointProbeInterval time.Duration `json:"endpoint_probe_interval" validate:"gte=0"`
+
+	// AnthropicVersion is sent upstream as Anthropic-Version header (default 2023-06-01).
+	// Versions not known to this release are accepted with a warning.
+	AnthropicVersion string `json:"anthropic_version"`
+
+	// AnthropicVersions overrides AnthropicVersion for upstream path patterns,
+	// matched like Passthrough patterns (e.g., "/v1/messages/count_tokens" or "/v1/files/*").
+	AnthropicVersions map[string]string `json:"anthropic_versions" validate:"dive,keys,startswith=/,endkeys"`
+
+	// StreamIdleTimeout ends streams that receive no upstream events for this long
+	// with an error event. Negative disables it.
+	StreamIdleTimeout time.Duration `json:"stream_idle_timeout"`
+
+	// CompressRequests gzip-compresses request bodies sent upstream.
+	CompressRequests bool `json:"compress_requests"`
+
+	// ModelRefresh revalidates the model list served on /v1/models against the
+	// upstream in the background once it is this old. Requires auth.fallback_api_key,
+	// as the upstream doesn't list models for OAuth tokens. Zero disables it.
+	ModelRefresh time.Duration `json:"model_refresh" validate:"gte=0"`
+
+	Transport TransportConfig `json:"transport"`
+
+	Impersonation ImpersonationConfig `json:"impersonation"`
+}
+
+// TransportConfig tunes connection pooling and timeouts of upstream requests.
+// Zero values keep the defaults; negative timeouts disable the respective timeout.
+type TransportConfig struct {
+	// MaxIdleConnsPerHost keeps this many idle connections for reuse. Raise it
+	// towards the expected concurrency to avoid reconnecting under load.
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host" validate:"min=0"`
+
+	// MaxConnsPerHost limits upstream connections including those in use (0 = unlimited).
+	MaxConnsPerHost int `json:"max_conns_per_host" validate:"min=0"`
+
+	TLSHandshakeTimeout   time.Duration `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `json:"response_header_timeout"`
+
+	// DisableHTTP2 restricts upstream connections to HTTP/1.1.
+	DisableHTTP2 bool `json:"disable_http2"`
+
+	// DNSCacheTTL caches resolved upstream addresses (0 = no caching). Cached
+	// addresses outlive the TTL while DNS fails.
+	DNSCacheTTL time.Duration `json:"dns_cache_ttl" validate:"gte=0"`
+
+	// Addresses pins the upstream host to these IPs, tried in order, instead of
+	// resolving it.
+	Addresses []string `json:"addresses" validate:"dive,ip"`
+}
+
+// ImpersonationConfig selects the client fingerprint presented to Anthropic.
+// Set fields override those of the selected profile, e.g. to follow a new
+// Claude Code release before the built-in profile is updated.
+type ImpersonationConfig struct {
+	// Profile is a built-in profile: "minimal" (only what OAuth requires) or
+	// "claude-code" (headers and beta features of the Claude Code CLI).
+	Profile string `json:"profile" validate:"oneof=minimal claude-code"`
+
+	UserAgent    string            `json:"user_agent"`
+	App          string            `json:"x_app"`
+	BetaFeatures []string          `json:"beta_features"` // Replace the profile's additional beta features
+	Headers      map[string]string `json:"headers"`       // Merged with the profile's headers; empty values remove them
+	UserID       string            `json:"user_id"`       // Default metadata.user_id
+
+	// Tolerant forwards bodies unchanged with a warning when the system prompt
+	// cannot be injected (e.g. malformed JSON) instead of failing the request.
+	Tolerant bool `json:"tolerant"`
+}
+
+// QueueConfig holds non-streaming requests while the upstream is rate limited
+// instead of passing 429s through to clients.
+type QueueConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// MaxSize of the queue; requests beyond it receive 429 immediately.
+	MaxSize int `json:"max_size" validate:"min=0"`
+
+	// MaxWait a single request waits for the limit window to reset.
+	MaxWait time.Duration `json:"max_wait"`
+}
+
+// PacingConfig smooths upstream traffic below the account's per-minute limits.
+// Disabled unless at least one limit is set.
+type PacingConfig struct {
+	// RequestsPerMinute allowed upstream (0 = unlimited).
+	RequestsPerMinute int `json:"requests_per_minute" validate:"min=0"`
+
+	// InputTokensPerMinute allowed upstream, estimated from request size (0 = unlimited).
+	InputTokensPerMinute int `json:"input_tokens_per_minute" validate:"min=0"`
+
+	// Burst of requests passed without delay.
+	Burst int `json:"burst" validate:"min=0"`
+
+	// MaxWait a request is delayed before the proxy answers 429 itself.
+	MaxWait time.Duration `json:"max_wait"`
+}
+
+// PluginConfig describes an external request/response filter: either an executable
+// speaking the stdin/stdout protocol or a WebAssembly module (see package plugin).
+type PluginConfig struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command" validate:"required_without=Wasm,excluded_with=Wasm"`
+	Args    []string `json:"args"`
+
+	// Wasm is the path to a WebAssembly filter module (alternative to Command).
+	// Phases are derived from the module's exports and the file is reloaded on change.
+	Wasm string `json:"wasm"`
+
+	// MaxMemoryMB caps the linear memory of WebAssembly modules (0 = no explicit limit).
+	MaxMemoryMB uint32 `json:"max_memory_mb"`
+
+	// Phases the plugin is invoked for (request, response). Defaults to request only.
+	Phases []string `json:"phases" validate:"dive,oneof=request response"`
+
+	// Timeout for a single plugin call. The plugin is restarted when exceeded.
+	Timeout time.Duration `json:"timeout"`
+
+	// FailOpen passes traffic through unmodified when the plugin fails instead of rejecting it.
+	FailOpen bool `json:"fail_open"`
+}
+
+// WebhookConfig describes an endpoint notified after each completed request.
+type WebhookConfig struct {
+	URL string `json:"url" validate:"required,url"`
+
+	// Secret signs payloads with HMAC-SHA256 (X-Claudine-Signature header). Optional.
+	Secret string `json:"secret" secret:"true"`
+
+	// Timeout for a single delivery attempt.
+	Timeout time.Duration `json:"timeout"`
+
+	// MaxRetries for failed deliveries (exponential backoff starting at 500ms).
+	MaxRetries int `json:"max_retries" validate:"min=0,max=10"`
+}
+
+// ShadowConfig mirrors a sample of requests to a second model or upstream.
+// Disabled when Percent is 0.
+type ShadowConfig struct {
+	// Percent of Messages requests to mirror.
+	Percent float64 `json:"percent" validate:"min=0,max=100"`
+
+	// BaseURL of the shadow upstream (defaults to upstream.base_url).
+	BaseURL string `json:"base_url" validate:"omitempty,url"`
+
+	// Model used for mirrored requests (defaults to the requested model).
+	Model string `json:"model"`
+
+	// Timeout for a single shadow request.
+	Timeout time.Duration `json:"timeout"`
+
+	// Store is a JSONL file receiving primary/shadow response pairs. Empty discards shadow responses.
+	Store string `json:"store"`
+}
+
+// ExperimentConfig splits requests for a model across weighted arms (A/B routing).
+type ExperimentConfig struct {
+	Name string `json:"name"`
+
+	// Model is the requested model routed by this experiment.
+	Model string `json:"model" validate:"required"`
+
+	// Sticky selects the identity for stable assignment: key (default), user or none.
+	Sticky string `json:"sticky" validate:"omitempty,oneof=key user none"`
+
+	Arms []ExperimentArmConfig `json:"arms" validate:"required,min=1,dive"`
+}
+
+// ExperimentArmConfig is a target model with a relative weight.
+type ExperimentArmConfig struct {
+	Name   string `json:"name"`
+	Model  string `json:"model" validate:"required"`
+	Weight uint   `json:"weight" validate:"required,min=1"`
+}
+
+// PolicyConfig restricts the models and token limits available to client keys.
+type PolicyConfig struct {
+	Name string `json:"name"`
+
+	// Keys are client credentials or their usage key IDs ("key_…"); "*" matches keys without a policy.
+	Keys []string `json:"keys" validate:"required,min=1" secret:"true"`
+
+	// Models are allowed model patterns (e.g. "claude-haiku-*"). Empty allows all.
+	Models []string `json:"models"`
+
+	MaxTokens          int `json:"max_tokens" validate:"min=0"`
+	MaxReasoningBudget int `json:"max_reasoning_budget" validate:"min=0"`
+
+	// Priority is "interactive" (default) or "batch"; batch requests yield to
+	// interactive ones while upstream pacing delays requests.
+	Priority string `json:"priority" validate:"omitempty,oneof=interactive batch"`
+}
+
+// CapabilitiesConfig enables checking requests against what their model supports.
+type CapabilitiesConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Models add to or replace the built-in models, e.g. for new releases.
+	Models []ModelCapabilityConfig `json:"models" validate:"dive"`
+}
+
+// ModelCapabilityConfig describes what a model supports. It replaces a built-in
+// model with the same ID entirely.
+type ModelCapabilityConfig struct {
+	ID      string   `json:"id" validate:"required"`
+	Aliases []string `json:"aliases"`
+
+	ContextWindow   int `json:"context_window" validate:"min=0"`
+	MaxOutputTokens int `json:"max_output_tokens" validate:"min=0"` // 0 = no limit
+
+	Thinking  bool `json:"thinking"`
+	WebSearch bool `json:"web_search"`
+	Vision    bool `json:"vision"`
+}
+
+// TenantConfig defines an additional account served by the same process, selected
+// by the client's virtual key or the Host header.
+type TenantConfig struct {
+	Name string `json:"name" validate:"required"`
+
+	// Keys are virtual keys or their usage key IDs ("key_…").
+	Keys []string `json:"keys" validate:"required_without_all=Hosts Pool" secret:"true"`
+
+	// Hosts are Host header values (without port).
+	Hosts []string `json:"hosts" validate:"required_without_all=Keys Pool,dive,hostname"`
+
+	// Pool shares requests matching no tenant between the default account and all
+	// pooled tenants, keeping each conversation on one account. Pooled tenants need
+	// no keys or hosts.
+	Pool bool `json:"pool"`
+
+	// Auth holds the tenant's token store and method. client_keys, fallback_api_key and
+	// revocation_webhook are global.
+	Auth AuthConfig `json:"auth"`
+
+	// ModelAliases maps requested models to Anthropic models.
+	ModelAliases map[string]string `json:"model_aliases"`
+
+	// Pacing limits the tenant's upstream traffic.
+	Pacing PacingConfig `json:"pacing"`
+}
+
+// PrivacyConfig controls which identifying data leaves the proxy.
+type PrivacyConfig struct {
+	// UserID mode for OpenAI user/safety_identifier and Anthropic metadata.user_id.
+	UserID UserIDMode `json:"user_id" validate:"oneof=passthrough hash drop"`
+
+	// Salt keys hashed user IDs. Keep it secret and stable to preserve per-user grouping upstream.
+	Salt string `json:"salt" secret:"true"`
+}
+
+// OpenAIConfig holds OpenAI compatibility layer configuration.
+type OpenAIConfig struct {
+	// Disabled removes the OpenAI routes (chat completions and the unsupported or
+	// forwarded OpenAI endpoints), e.g. to expose only the Anthropic API.
+	Disabled bool `json:"disabled"`
+
+	// Listen serves the OpenAI routes on this address (host:port) instead of the
+	// server address. Files, models and health routes are served on both.
+	Listen string `json:"listen" validate:"omitempty,hostname_port"`
+
+	// WebSocketOrigins lists browser origins (e.g., "https://app.example.com")
+	// allowed to stream chat completions over WebSocket. Upgrades without Origin
+	// header, i.e. from non-browser clients, are always accepted.
+	WebSocketOrigins []string `json:"websocket_origins" validate:"dive,url"`
+
+	// Forward sends OpenAI endpoints without Anthropic equivalent (embeddings, images,
+	// audio, ...) to an alternate upstream instead of answering 501.
+	Forward []ForwardConfig `json:"forward" validate:"dive"`
+
+	// AzureDeployments maps Azure OpenAI deployment names to models.
+	// Unmapped deployment names are used as the model.
+	AzureDeployments []AzureDeploymentConfig `json:"azure_deployments" validate:"dive"`
+
+	// DebugLog logs translated Anthropic requests with message content hashed and
+	// summaries of their responses, for diagnosing mapping issues.
+	DebugLog bool `json:"debug_log"`
+
+	// DiffEndpoint serves POST /v1/chat/completions/diff, which returns the
+	// translated Anthropic request next to the original, content hashed, without
+	// sending it upstream.
+	DiffEndpoint bool `json:"diff_endpoint"`
+
+	// DryRun answers every chat completion request with the Anthropic request it
+	// would be sent as instead of calling the upstream, as the X-Claudine-Dry-Run
+	// header does per request.
+	DryRun bool `json:"dry_run"`
+
+	// RepairToolArguments completes streamed tool call arguments cut off mid-JSON
+	// and flags unrepairable ones with finish_reason "length".
+	RepairToolArguments bool `json:"repair_tool_arguments"`
+
+	// StrictToolRetries repeats non-streaming requests whose tool call arguments
+	// don't match the schema of a strict tool before failing them.
+	StrictToolRetries int `json:"strict_tool_retries" validate:"min=0"`
+
+	// DeveloperMessages places developer messages "before" or "after" system messages
+	// in the system prompt. By default both keep the order they were sent in.
+	DeveloperMessages string `json:"developer_messages" validate:"omitempty,oneof=before after"`
+
+	// InlineSystemMessages keeps system and developer messages sent mid-conversation
+	// in place as tagged user text instead of hoisting them into the system prompt.
+	InlineSystemMessages bool `json:"inline_system_messages"`
+
+	// PromptCacheSessions is how many conversations, identified by prompt_cache_key,
+	// are shaped for Anthropic's prompt cache: stable system block and tool order plus
+	// cache breakpoints. Zero disables it.
+	PromptCacheSessions int `json:"prompt_cache_sessions" validate:"min=0"`
+
+	// ReasoningBudgets overrides the thinking budgets reasoning_effort maps to, per
+	// model. The first entry matching the model applies.
+	ReasoningBudgets []ReasoningBudgetConfig `json:"reasoning_budgets" validate:"dive"`
+
+	// ReasoningContent streams extended thinking as delta.reasoning_content: "full"
+	// forwards it as is, "summary" sends a progress line every ReasoningSummaryInterval
+	// characters of thinking. Empty drops thinking.
+	ReasoningContent         string `json:"reasoning_content" validate:"omitempty,oneof=full summary"`
+	ReasoningSummaryInterval int    `json:"reasoning_summary_interval" validate:"min=0"`
+	ReasoningSummaryLength   int    `json:"reasoning_summary_length" validate:"min=0"` // Characters per progress line
+}
+
+// NativeConfig holds configuration of the Anthropic Messages API route.
+type NativeConfig struct {
+	// Disabled removes POST /v1/messages, e.g. to expose only the OpenAI compatibility layer.
+	Disabled bool `json:"disabled"`
+
+	// Listen serves the native routes, including passthrough endpoints, on this
+	// address (host:port) instead of the server address.
+	Listen string `json:"listen" validate:"omitempty,hostname_port"`
+
+	// Stream normalizes /v1/messages event streams.
+	Stream NativeStreamConfig `json:"stream"`
+
+	// RewriteErrors normalizes /v1/messages error responses: Anthropic's documented
+	// shape, the proxy's request ID, a friendlier overloaded message and no details
+	// identifying the subscription account.
+	RewriteErrors bool `json:"rewrite_errors"`
+
+	// Validate checks /v1/messages requests against the Messages schema and rejects
+	// malformed ones locally instead of spending upstream rate limits on them.
+	Validate bool `json:"validate"`
+}
+
+// NativeStreamConfig configures the event stream filter of /v1/messages.
+type NativeStreamConfig struct {
+	DropPings       bool `json:"drop_pings"`       // Remove ping events
+	NormalizeErrors bool `json:"normalize_errors"` // Rewrite error events to Anthropic's documented shape
+	LogEventCounts  bool `json:"log_event_counts"` // Log events per type when a stream ends
+}
+
+// GRPCConfig enables the gRPC ChatCompletion service (see package grpcapi).
+// Disabled unless Address is set.
+type GRPCConfig struct {
+	Address string `json:"address" validate:"omitempty,hostname_port"`
+}
+
+// AzureDeploymentConfig maps an Azure OpenAI deployment name to a model.
+type AzureDeploymentConfig struct {
+	Name  string `json:"name" validate:"required"`
+	Model string `json:"model" validate:"required"`
+}
+
+// ReasoningBudgetConfig sets the thinking budgets of reasoning_effort levels for
+// matching models. Unset levels keep their default budget (1024, 8192, 24576).
+type ReasoningBudgetConfig struct {
+	Model  string `json:"model" validate:"required"` // Model name or path.Match pattern, e.g. "claude-opus-*"
+	Low    int64  `json:"low" validate:"omitempty,min=1024"`
+	Medium int64  `json:"medium" validate:"omitempty,min=1024"`
+	High   int64  `json:"high" validate:"omitempty,min=1024"`
+}
+
+// ForwardConfig routes matching requests to an alternate OpenAI-compatible upstream.
+type ForwardConfig struct {
+	// Path to forward (e.g., "/v1/embeddings"). A trailing "/*" matches everything below it.
+	Path string `json:"path" validate:"required,startswith=/,ne=/*"`
+
+	// BaseURL the request path below /v1 is appended to (e.g., "https://api.openai.com/v1").
 	BaseURL string `json:"base_url" validate:"required,url"`
+
+	// APIKey replaces the client's Authorization header (optional).
+	APIKey string `json:"api_key" secret:"true"`
+}
+
+// CacheConfig holds the exact-match response cache configuration.
+type CacheConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// TTL of cached responses.
+	TTL time.Duration `json:"ttl"`
+
+	// MaxEntries bounds the in-memory LRU (also used as first tier for disk and redis).
+	MaxEntries int `json:"max_entries" validate:"min=0"`
+
+	// Backend for shared or persistent caching behind the in-memory LRU.
+	Backend CacheBackend `json:"backend" validate:"oneof=memory disk redis"`
+
+	// Dir for the disk backend.
+	Dir string `json:"dir"`
+
+	// RedisURL for the redis backend (redis://[user:password@]host:port/db).
+	RedisURL string `json:"redis_url" validate:"required_if=Backend redis,omitempty,url" secret:"true"`
+}
+
+// StorageConfig persists virtual keys, usage records and audit events.
+type StorageConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Driver selects the database.
+	Driver StorageDriver `json:"driver" validate:"oneof=sqlite postgres"`
+
+	// DSN of the database (a file path for sqlite, postgres://… for postgres).
+	DSN string `json:"dsn" validate:"required_if=Driver postgres" secret:"true"`
+}
+
+// AuditConfig records logins, logouts, token rotations, config reloads and policy
+// rejections to an append-only audit log.
+type AuditConfig struct {
+	// File appends audit events as JSON lines to this path.
+	File string `json:"file"`
+
+	// Storage records audit events in the storage database. Requires storage.enabled.
+	Storage bool `json:"storage"`
+
+	// SigningKey enables recording an HMAC-SHA256 signed digest of every request
+	// sent upstream, for "claudine audit verify" to prove what was sent for a
+	// request ID. Requires audit.file or audit.storage.
+	SigningKey string `json:"signing_key" secret:"true" validate:"omitempty,min=32"`
+}
+
+// CaptureConfig stores Messages and chat completions requests by request ID, for
+// "claudine replay" to reproduce reported issues.
+type CaptureConfig struct {
+	// Dir receives one file per request, holding its headers and body without
+	// credentials. Empty disables capturing.
+	Dir string `json:"dir"`
+
+	// MaxAge deletes captures once older (default 24h). Negative keeps them.
+	MaxAge time.Duration `json:"max_age"`
+}
+
+// SpoolConfig writes large request bodies the proxy must read more than once to
+// temporary files instead of holding them in memory.
+type SpoolConfig struct {
+	// Dir holds the temporary files. Empty disables spooling.
+	Dir string `json:"dir"`
+
+	// MemoryLimit is the size in bytes up to which bodies stay in memory (default 1 MiB).
+	MemoryLimit int64 `json:"memory_limit" validate:"min=0"`
+
+	// MaxSize bounds the bytes in Dir at once; bodies not fitting stay in memory.
+	// Zero leaves it unbounded.
+	MaxSize int64 `json:"max_size" validate:"min=0"`
+}
+
+// ConversationsConfig guards against runaway agents: it limits the tokens a
+// single conversation, named by prompt_cache_key or metadata.conversation_id,
+// may use and rejects requests of conversations stuck in a loop.
+type ConversationsConfig struct {
+	// MaxTokens is the budget of input, cache and output tokens per conversation.
+	// Zero disables it.
+	MaxTokens int64 `json:"max_tokens" validate:"min=0"`
+
+	// OverBudget is what happens to requests of a conversation over budget:
+	// "reject" (default) or "warn", which only logs it.
+	OverBudget string `json:"over_budget" validate:"omitempty,oneof=reject warn"`
+
+	// LoopToolCalls rejects requests whose history ends with the same tool call,
+	// same name and input, this many times in a row. Zero disables it.
+	LoopToolCalls int `json:"loop_tool_calls" validate:"min=0"`
+
+	// LoopRequestsPerMinute rejects requests of a conversation beyond this many
+	// per minute. Zero disables it.
+	LoopRequestsPerMinute int `json:"loop_requests_per_minute" validate:"min=0"`
+
+	// LoopWebhook is POSTed a notification when a conversation starts looping.
+	LoopWebhook string `json:"loop_webhook" validate:"omitempty,url"`
+}
+
+// AdminConfig holds credentials for administrative endpoints.
+type AdminConfig struct {
+	// Token admins send as bearer token or Basic auth password.
+	Token string `json:"token" secret:"true"`
+
+	// UsageReports forwards Anthropic's usage and cost report endpoints to admins.
+	UsageReports bool `json:"usage_reports"`
+
+	// StreamObservers lets admins watch streams in progress by request ID.
+	StreamObservers bool `json:"stream_observers"`
+
+	// APIKey is an Anthropic Admin API key (sk-ant-admin…) sent with usage report
+	// requests instead of the OAuth credentials.
+	APIKey string `json:"api_key" secret:"true"`
+}
+
+// MetricsConfig configures the Prometheus metrics.
+type MetricsConfig struct {
+	// Enabled serves the metrics at GET /metrics on the API listener. The endpoint
+	// is unauthenticated, so it is off by default.
+	Enabled bool `json:"enabled"`
+
+	// Tags are the request tags reported as metrics labels; other tags are
+	// counted as "other" so clients cannot create series at will.
+	Tags []string `json:"tags"`
+}
+
+// DashboardConfig configures the built-in read-only dashboard at /dashboard.
+type DashboardConfig struct {
+	Enabled bool `json:"enabled"` // Requires admin.token
 }
 
 // AuthConfig represents the configuration for provider authentication.
@@ -82,6 +696,19 @@ type AuthConfig struct {
 
 	// Authentication method - how to convert stored_token to access_token
 	Method AuthenticationMethod `json:"method" validate:"required,oneof=oauth static"`
+	// ClientKeys forwards Anthropic API keys sent by clients instead of the stored token
+	ClientKeys bool `json:"client_keys"`
+
+	// FallbackAPIKey serves requests while the subscription's usage limits are exhausted
+	FallbackAPIKey string `json:"fallback_api_key" secret:"true"`
+
+	// LockedMemory keeps the OAuth refresh token in locked memory outside the Go heap,
+	// zeroed once rotated, instead of in regular memory
+	LockedMemory bool `json:"locked_memory"`
+
+	// RevocationWebhook is POSTed a notification when a refresh token is revoked,
+	// e.g. to prompt someone to log in again
+	RevocationWebhook string `json:"revocation_webhook" validate:"omitempty,url"`
 }
 
 // NewTokenStore creates a TokenStore from the authentication configuration.
@@ -98,15 +725,51 @@ func (a *AuthConfig) NewTokenStore() (tokenstore.TokenStore, error) {
 	}
 }
 
+// LogRotationConfig rotates log_file by size and prunes rotated files.
+// Zero values disable the respective limit.
+type LogRotationConfig struct {
+	MaxSize    int           `json:"max_size" validate:"gte=0"`    // Rotate when the file reaches this many megabytes
+	MaxAge     time.Duration `json:"max_age" validate:"gte=0"`     // Remove rotated files older than this
+	MaxBackups int           `json:"max_backups" validate:"gte=0"` // Rotated files kept
+}
+
 // Config holds the application's configuration.
 type Config struct {
 	// LogLevel for logging output (defaults to Info if unset).
-	LogLevel  slog.Level     `json:"log_level"`
-	LogFormat LogFormat      `json:"log_format" validate:"oneof=text json"`
-	Server    ServerConfig   `json:"server"`
-	Shutdown  ShutdownConfig `json:"shutdown"`
-	Upstream  UpstreamConfig `json:"upstream"`
-	Auth      AuthConfig     `json:"auth"`
+	LogLevel      slog.Level            `json:"log_level"`
+	LogLevels     map[string]slog.Level `json:"log_levels" validate:"dive,keys,oneof=proxy adapter tokensource tokenstore,endkeys"` // Per-component overrides of LogLevel
+	LogFormat     LogFormat             `json:"log_format" validate:"oneof=text json"`
+	LogOutput     LogOutput             `json:"log_output" validate:"oneof=stdout syslog journald"`
+	LogFile       string                `json:"log_file"`       // Appends logs to this file instead of stdout
+	PidFile       string                `json:"pid_file"`       // Written while the proxy runs
+	ControlSocket string                `json:"control_socket"` // Unix socket for status, stop and reload ("none" disables it)
+	LogRotation   LogRotationConfig     `json:"log_rotation"`
+	Server        ServerConfig          `json:"server"`
+	Startup       StartupConfig         `json:"startup"`
+	Shutdown      ShutdownConfig        `json:"shutdown"`
+	Upstream      UpstreamConfig        `json:"upstream"`
+	Auth          AuthConfig            `json:"auth"`
+	Plugins       []PluginConfig        `json:"plugins" validate:"dive"`
+	Webhooks      []WebhookConfig       `json:"webhooks" validate:"dive"`
+	Shadow        ShadowConfig          `json:"shadow"`
+	Experiments   []ExperimentConfig    `json:"experiments" validate:"dive"`
+	Policies      []PolicyConfig        `json:"policies" validate:"dive"`
+	Capabilities  CapabilitiesConfig    `json:"capabilities"`
+	Tenants       []TenantConfig        `json:"tenants" validate:"dive"`
+	Cache         CacheConfig           `json:"cache"`
+	Storage       StorageConfig         `json:"storage"`
+	Audit         AuditConfig           `json:"audit"`
+	Admin         AdminConfig           `json:"admin"`
+	Metrics       MetricsConfig         `json:"metrics"`
+	Dashboard     DashboardConfig       `json:"dashboard"`
+	Privacy       PrivacyConfig         `json:"privacy"`
+	OpenAI        OpenAIConfig          `json:"openai"`
+	Native        NativeConfig          `json:"native"`
+	GRPC          GRPCConfig            `json:"grpc"`
+	Debug         DebugConfig           `json:"debug"`
+	Capture       CaptureConfig         `json:"capture"`
+	Conversations ConversationsConfig   `json:"conversations"`
+	Spool         SpoolConfig           `json:"spool"`
 }
 
 // Default creates a new Config with default values applied.
@@ -123,24 +786,117 @@ func (c *Config) ApplyDefaults() error {
 	if c.LogFormat == "" {
 		c.LogFormat = DefaultConfigLogFormat
 	}
+	if c.LogOutput == "" {
+		c.LogOutput = DefaultConfigLogOutput
+	}
 	if c.Server.Host == "" {
 		c.Server.Host = DefaultConfigServerHost
 	}
 	if c.Server.Port == 0 {
 		c.Server.Port = DefaultConfigServerPort
 	}
+	if c.Server.ReadTimeout == 0 {
+		c.Server.ReadTimeout = DefaultConfigReadTimeout
+	}
+	if c.Server.WriteTimeout == 0 {
+		c.Server.WriteTimeout = DefaultConfigWriteTimeout
+	}
+	if c.Server.IdleTimeout == 0 {
+		c.Server.IdleTimeout = DefaultConfigIdleTimeout
+	}
+	if c.Server.MaxHeaderBytes == 0 {
+		c.Server.MaxHeaderBytes = DefaultConfigMaxHeaderBytes
+	}
+	if c.Startup.VerifyTimeout == 0 {
+		c.Startup.VerifyTimeout = DefaultConfigVerifyTimeout
+	}
 	if c.Shutdown.Timeout == 0 {
 		c.Shutdown.Timeout = DefaultConfigShutdownTimeout
 	}
 	if c.Upstream.BaseURL == "" {
 		c.Upstream.BaseURL = DefaultConfigUpstreamBaseURL
 	}
+	if c.Capture.MaxAge == 0 {
+		c.Capture.MaxAge = DefaultConfigCaptureMaxAge
+	}
+	if c.Spool.MemoryLimit == 0 {
+		c.Spool.MemoryLimit = DefaultConfigSpoolMemory
+	}
+	if c.Upstream.Impersonation.Profile == "" {
+		c.Upstream.Impersonation.Profile = DefaultConfigImpersonation
+	}
+	if c.ControlSocket == "" {
+		// Without a cache directory the control socket stays disabled
+		if cacheDir, err := os.UserCacheDir(); err == nil {
+			c.ControlSocket = filepath.Join(cacheDir, "claudine-proxy", "claudine.sock")
+		}
+	}
 	if c.Auth.Storage == "" {
 		c.Auth.Storage = DefaultConfigAuthStorage
 	}
 	if c.Auth.Method == "" {
 		c.Auth.Method = DefaultConfigAuthMethod
 	}
+	for i := range c.Plugins {
+		if c.Plugins[i].Timeout == 0 {
+			c.Plugins[i].Timeout = DefaultConfigPluginTimeout
+		}
+	}
+	if c.Upstream.Queue.MaxSize == 0 {
+		c.Upstream.Queue.MaxSize = DefaultConfigQueueMaxSize
+	}
+	if c.Upstream.Queue.MaxWait == 0 {
+		c.Upstream.Queue.MaxWait = DefaultConfigQueueMaxWait
+	}
+	if c.Upstream.StreamIdleTimeout == 0 {
+		c.Upstream.StreamIdleTimeout = DefaultConfigStreamIdleTimeout
+	}
+	if c.Upstream.Pacing.MaxWait == 0 {
+		c.Upstream.Pacing.MaxWait = DefaultConfigPacingMaxWait
+	}
+	if c.Privacy.UserID == "" {
+		c.Privacy.UserID = DefaultConfigPrivacyUserID
+	}
+	if c.Cache.TTL == 0 {
+		c.Cache.TTL = DefaultConfigCacheTTL
+	}
+	if c.Cache.MaxEntries == 0 {
+		c.Cache.MaxEntries = DefaultConfigCacheMaxEntries
+	}
+	if c.Cache.Backend == "" {
+		c.Cache.Backend = DefaultConfigCacheBackend
+	}
+	if c.Cache.Backend == CacheBackendDisk && c.Cache.Dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("cache.dir required (auto-detect failed: %w)", err)
+		}
+		c.Cache.Dir = filepath.Join(cacheDir, "claudine-proxy", "responses")
+	}
+	if c.Storage.Driver == "" {
+		c.Storage.Driver = DefaultConfigStorageDriver
+	}
+	if c.Storage.Enabled && c.Storage.Driver == StorageDriverSQLite && c.Storage.DSN == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return fmt.Errorf("storage.dsn required (auto-detect failed: %w)", err)
+		}
+		c.Storage.DSN = filepath.Join(configDir, "claudine-proxy", "claudine.db")
+	}
+	if c.OpenAI.ReasoningSummaryInterval == 0 {
+		c.OpenAI.ReasoningSummaryInterval = DefaultConfigReasoningSummaryInterval
+	}
+	if c.OpenAI.ReasoningSummaryLength == 0 {
+		c.OpenAI.ReasoningSummaryLength = DefaultConfigReasoningSummaryLength
+	}
+	if c.Shadow.Timeout == 0 {
+		c.Shadow.Timeout = DefaultConfigShadowTimeout
+	}
+	for i := range c.Webhooks {
+		if c.Webhooks[i].Timeout == 0 {
+			c.Webhooks[i].Timeout = DefaultConfigWebhookTimeout
+		}
+	}
 
 	// Dynamic defaults based on storage type
 	switch c.Auth.Storage {
@@ -164,6 +920,36 @@ func (c *Config) ApplyDefaults() error {
 		// env_key must be explicitly configured (no sensible default)
 	}
 
+	// Tenants default to their own token file or keyring entry, named after the tenant
+	for i := range c.Tenants {
+		t := &c.Tenants[i]
+		if t.Auth.Storage == "" {
+			t.Auth.Storage = DefaultConfigAuthStorage
+		}
+		if t.Auth.Method == "" {
+			t.Auth.Method = DefaultConfigAuthMethod
+		}
+		if t.Pacing.MaxWait == 0 {
+			t.Pacing.MaxWait = DefaultConfigPacingMaxWait
+		}
+		switch t.Auth.Storage {
+		case TokenStorageTypeFile:
+			if t.Auth.File == "" {
+				configDir, err := os.UserConfigDir()
+				if err != nil {
+					return fmt.Errorf("tenants.%s.auth.file required (auto-detect failed: %w)", t.Name, err)
+				}
+				t.Auth.File = filepath.Join(configDir, "claudine-proxy", "auth-"+t.Name)
+			}
+		case TokenStorageTypeKeyring:
+			if t.Auth.KeyringUser == "" {
+				t.Auth.KeyringUser = "tenant-" + t.Name
+			}
+		case TokenStorageTypeEnv:
+			// env_key must be explicitly configured (no sensible default)
+		}
+	}
+
 	return nil
 }
 
@@ -173,22 +959,89 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if c.OpenAI.Disabled && c.Native.Disabled {
+		return errors.New("openai.disabled and native.disabled leave no API to serve")
+	}
+	if c.OpenAI.Disabled && c.OpenAI.Listen != "" {
+		return errors.New("openai.listen requires the OpenAI routes, which are disabled")
+	}
+	if c.OpenAI.Disabled && c.GRPC.Address != "" {
+		return errors.New("grpc.address requires the OpenAI routes, which are disabled")
+	}
+	if c.Native.Disabled && c.Native.Listen != "" {
+		return errors.New("native.listen requires the native routes, which are disabled")
+	}
+
+	if c.Dashboard.Enabled && c.Admin.Token == "" {
+		return errors.New("dashboard.enabled requires admin.token")
+	}
+	if c.Admin.UsageReports && c.Admin.Token == "" {
+		return errors.New("admin.usage_reports requires admin.token")
+	}
+	for _, b := range c.OpenAI.ReasoningBudgets {
+		if _, err := path.Match(b.Model, ""); err != nil {
+			return fmt.Errorf("openai.reasoning_budgets: invalid model pattern %q: %w", b.Model, err)
+		}
+	}
+	if c.Audit.Storage && !c.Storage.Enabled {
+		return errors.New("audit.storage requires storage.enabled")
+	}
+	if c.Audit.SigningKey != "" && c.Audit.File == "" && !c.Audit.Storage {
+		return errors.New("audit.signing_key requires audit.file or audit.storage")
+	}
+	if v := c.Upstream.AnthropicVersion; v != "" {
+		if err := proxy.ValidateAnthropicVersion(v); err != nil {
+			return fmt.Errorf("upstream.anthropic_version: %w", err)
+		}
+	}
+	for pattern, v := range c.Upstream.AnthropicVersions {
+		if err := proxy.ValidateAnthropicVersion(v); err != nil {
+			return fmt.Errorf("upstream.anthropic_versions[%q]: %w", pattern, err)
+		}
+	}
+	if c.Upstream.ModelRefresh > 0 && c.Auth.FallbackAPIKey == "" {
+		return errors.New("upstream.model_refresh requires auth.fallback_api_key")
+	}
+
+	if err := c.Auth.validate(); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(c.Tenants))
+	for _, t := range c.Tenants {
+		if names[t.Name] {
+			return fmt.Errorf("tenants: duplicate name %q", t.Name)
+		}
+		names[t.Name] = true
+		if err := t.Auth.validate(); err != nil {
+			return fmt.Errorf("tenant %s: %w", t.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the storage settings required by the storage type and method.
+func (a *AuthConfig) validate() error {
 	// OAuth requires writable storage (env is read-only)
-	if c.Auth.Method == AuthenticationMethodOAuth && c.Auth.Storage == TokenStorageTypeEnv {
+	if a.Method == AuthenticationMethodOAuth && a.Storage == TokenStorageTypeEnv {
 		return errors.New("oauth authentication requires writable storage, env is read-only")
 	}
+	if a.LockedMemory && !secret.Supported {
+		return errors.New("locked_memory is not supported on this platform")
+	}
 
-	switch c.Auth.Storage {
+	switch a.Storage {
 	case TokenStorageTypeFile:
-		if c.Auth.File == "" {
+		if a.File == "" {
 			return errors.New("file path required for file storage")
 		}
 	case TokenStorageTypeEnv:
-		if c.Auth.EnvKey == "" {
+		if a.EnvKey == "" {
 			return errors.New("env_key required for env storage")
 		}
 	case TokenStorageTypeKeyring:
-		if c.Auth.KeyringUser == "" {
+		if a.KeyringUser == "" {
 			return errors.New("keyring_user required for keyring storage")
 		}
 	}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+	"time"
+)
+
+// DebugConfig enables a separate listener serving net/http/pprof and expvar.
+// Disabled unless Address is set; keep it on loopback as profiles expose internals.
+type DebugConfig struct {
+	Address string `json:"address" validate:"omitempty,hostname_port"`
+}
+
+// debugHandler serves profiling endpoints under /debug/pprof/ and expvar at
+// /debug/vars, with the configuration added as "config". Secrets are masked in
+// the configuration and the command line.
+func debugHandler(cfg *Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", debugCmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", debugVars(cfg))
+	return mux
+}
+
+// debugCmdline serves the command line like pprof.Cmdline, with secrets masked.
+func debugCmdline(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, strings.Join(redactArgs(os.Args), "\x00"))
+}
+
+// debugVars serves the published expvars like expvar.Handler, replacing the
+// command line with its redacted form and adding the redacted configuration.
+func debugVars(cfg *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		vars := make(map[string]any)
+		expvar.Do(func(kv expvar.KeyValue) {
+			vars[kv.Key] = json.RawMessage(kv.Value.String())
+		})
+		vars["cmdline"] = redactArgs(os.Args)
+		vars["config"] = cfg.Redacted()
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(vars); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// redactArgs masks the values of command line flags named like credentials,
+// as "--flag=value" or "--flag value", and secrets in URL arguments.
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	maskNext := false // Previous argument is a credential flag without value
+	for i, arg := range args {
+		flag := strings.HasPrefix(arg, "-")
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !flag || !hasValue {
+			name, value = "", arg
+		}
+		switch {
+		case maskNext && !flag, name != "" && sensitiveName(name):
+			value = redactedValue
+		case strings.Contains(value, "://"):
+			value = redactSecret(value)
+		}
+		if name != "" {
+			value = name + "=" + value
+		}
+		out[i] = value
+		maskNext = flag && !hasValue && sensitiveName(arg)
+	}
+	return out
+}
+
+// startDebugServer starts the debug listener in the background.
+// Returns the server for shutdown and a channel for runtime errors.
+func startDebugServer(ctx context.Context, address string, cfg *Config) (*http.Server, <-chan error, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	if host, _, _ := net.SplitHostPort(address); !isLoopback(host) {
+		slog.WarnContext(ctx, "debug endpoints exposed beyond loopback", "address", address)
+	}
+
+	server := &http.Server{
+		Handler:     debugHandler(cfg),
+		ReadTimeout: 30 * time.Second,
+		// No WriteTimeout: CPU profiles and traces run for the requested duration
+		IdleTimeout: 90 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	return server, errCh, nil
+}
+
+// isLoopback reports whether host is localhost or a loopback IP.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
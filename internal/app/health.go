@@ -1,6 +1,8 @@
 package app
 
 import (
+	"maps"
+	"sync"
 	"sync/atomic"
 
 	"github.com/florianilch/claudine-proxy/internal/proxy"
@@ -10,10 +12,16 @@ import (
 // All methods are thread-safe.
 type Health struct {
 	ready atomic.Bool
+
+	mu       sync.Mutex
+	degraded map[string]string
 }
 
-// Compile-time check that Health implements proxy.ReadinessChecker interface
-var _ proxy.ReadinessChecker = (*Health)(nil)
+// Compile-time check that Health implements proxy.ReadinessChecker and proxy.DegradationReporter interfaces
+var (
+	_ proxy.ReadinessChecker    = (*Health)(nil)
+	_ proxy.DegradationReporter = (*Health)(nil)
+)
 
 // NewHealth creates a new Health instance initialized as not ready.
 func NewHealth() *Health {
@@ -26,6 +34,35 @@ func (h *Health) SetReady(ready bool) {
 }
 
 // IsReady returns the current readiness state of the application.
+// A degraded application is not ready.
 func (h *Health) IsReady() bool {
-	return h.ready.Load()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ready.Load() && len(h.degraded) == 0
+}
+
+// SetDegraded marks component as degraded for reason, or healthy again if reason is empty.
+func (h *Health) SetDegraded(component, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if reason == "" {
+		delete(h.degraded, component)
+		return
+	}
+	if h.degraded == nil {
+		h.degraded = make(map[string]string)
+	}
+	h.degraded[component] = reason
+}
+
+// Degraded returns the degraded components and their reasons, nil if there are none.
+func (h *Health) Degraded() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.degraded) == 0 {
+		return nil
+	}
+	return maps.Clone(h.degraded)
 }
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"log/slog"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/secret"
+)
+
+// lockedTokenSource keeps the refresh token in locked memory between refreshes.
+//
+// A TokenSource created by the factory holds the refresh token on the heap for
+// its lifetime, so one is created per refresh and discarded right after. Between
+// refreshes only the access token is cached. Returned tokens carry the refresh
+// token only when it was rotated, for PersistentTokenSource to persist it.
+type lockedTokenSource struct {
+	factory TokenSourceFactory
+
+	mu      sync.Mutex
+	refresh *secret.Buffer
+	access  *oauth2.Token // RefreshToken cleared
+}
+
+// Compile-time check that lockedTokenSource implements oauth2.TokenSource
+var _ oauth2.TokenSource = (*lockedTokenSource)(nil)
+
+// lockedFactory wraps factory to create lockedTokenSources.
+func lockedFactory(factory TokenSourceFactory) TokenSourceFactory {
+	return func(token string) oauth2.TokenSource {
+		refresh, err := secret.New(token)
+		if err != nil {
+			return errorTokenSource{err}
+		}
+		return &lockedTokenSource{factory: factory, refresh: refresh}
+	}
+}
+
+// Token implements oauth2.TokenSource.
+func (l *lockedTokenSource) Token() (*oauth2.Token, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.access.Valid() {
+		token := *l.access
+		return &token, nil
+	}
+
+	var token *oauth2.Token
+	var err error
+	if useErr := l.refresh.Use(func(refresh []byte) {
+		token, err = l.factory(string(refresh)).Token()
+	}); useErr != nil {
+		return nil, useErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	access := *token
+	access.RefreshToken = ""
+	l.access = &access
+
+	if token.RefreshToken == "" || l.refresh.Equal(token.RefreshToken) {
+		return &access, nil
+	}
+	rotated, err := secret.New(token.RefreshToken)
+	if err != nil {
+		// The rotated token is still persisted; the next refresh fails and recovers it from the store
+		slog.Error("failed to keep rotated refresh token in locked memory", "error", err)
+		return token, nil
+	}
+	l.refresh.Destroy()
+	l.refresh = rotated
+	return token, nil
+}
+
+// errorTokenSource fails every Token call with err.
+type errorTokenSource struct{ err error }
+
+// Token implements oauth2.TokenSource.
+func (e errorTokenSource) Token() (*oauth2.Token, error) { return nil, e.err }
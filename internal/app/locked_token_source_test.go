@@ -0,0 +1,54 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/secret"
+)
+
+func TestLockedTokenSource(t *testing.T) {
+	if !secret.Supported {
+		t.Skip("locked memory not supported")
+	}
+
+	var refreshedWith []string
+	factory := func(token string) oauth2.TokenSource {
+		return tokenSourceFunc(func() (*oauth2.Token, error) {
+			refreshedWith = append(refreshedWith, token)
+			return &oauth2.Token{AccessToken: "access-" + token, RefreshToken: token + "+", Expiry: time.Now().Add(time.Hour)}, nil
+		})
+	}
+	ts := lockedFactory(factory)("r1").(*lockedTokenSource)
+
+	token, err := ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access-r1" || token.RefreshToken != "r1+" {
+		t.Fatalf("first token = %+v, want the rotated refresh token to persist", token)
+	}
+	if !ts.refresh.Equal("r1+") {
+		t.Error("rotated refresh token not kept")
+	}
+
+	// Cached access token, without the refresh token
+	token, err = ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access-r1" || token.RefreshToken != "" || len(refreshedWith) != 1 {
+		t.Fatalf("cached token = %+v after %d refreshes, want access token only", token, len(refreshedWith))
+	}
+
+	// Expired: refreshed with the rotated token
+	ts.access.Expiry = time.Now().Add(-time.Minute)
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshedWith) != 2 || refreshedWith[1] != "r1+" {
+		t.Fatalf("refreshed with %q, want r1+", refreshedWith)
+	}
+}
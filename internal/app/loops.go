@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/florianilch/claudine-proxy/internal/proxy"
+)
+
+// loopNotice is the body POSTed to conversations.loop_webhook.
+type loopNotice struct {
+	Event string `json:"event"`
+	proxy.AgentLoop
+}
+
+// loopNotifier returns a function POSTing detected agent loops to webhook in the
+// background, or nil without webhook.
+func loopNotifier(webhook string) func(context.Context, proxy.AgentLoop) {
+	if webhook == "" {
+		return nil
+	}
+	return func(_ context.Context, loop proxy.AgentLoop) {
+		go func() {
+			// Not bound to the rejected request, which ends right away
+			ctx, cancel := context.WithTimeout(context.Background(), revocationNotifyTimeout)
+			defer cancel()
+			if err := postNotification(ctx, webhook, loopNotice{Event: "agent_loop", AgentLoop: loop}); err != nil {
+				slog.WarnContext(ctx, "failed to deliver agent loop notification", "conversation", loop.Conversation, "error", err)
+			}
+		}()
+	}
+}
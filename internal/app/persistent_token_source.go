@@ -2,29 +2,74 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/oauth2"
 
+	"github.com/florianilch/claudine-proxy/internal/audit"
 	"github.com/florianilch/claudine-proxy/internal/tokenstore"
 )
 
+// ErrTokenRevoked is returned while the stored refresh token is known to be revoked.
+// It clears once a new token is stored, e.g. by "claudine auth login".
+var ErrTokenRevoked = errors.New("refresh token revoked, log in again with 'claudine auth login'")
+
+// revocationRecheckInterval limits how often the token store is re-read while revoked.
+const revocationRecheckInterval = 5 * time.Second
+
+// Revocation describes a refresh token rejected by the token endpoint.
+type Revocation struct {
+	Time   time.Time
+	Reason string
+}
+
 // TokenSourceFactory creates an oauth2.TokenSource from a stored token string.
 type TokenSourceFactory func(token string) oauth2.TokenSource
 
 // PersistentTokenSource wraps an oauth2.TokenSource with token persistence.
 // Initialization is deferred to avoid I/O during application startup.
+//
+// When a refresh is rejected with invalid_grant, the source enters a revoked state:
+// the cached source is dropped and Token fails fast with ErrTokenRevoked until a
+// different token is found in the store.
 type PersistentTokenSource struct {
 	factory    TokenSourceFactory
 	tokenStore tokenstore.TokenStore
 
-	tokenSource func() (oauth2.TokenSource, error)
+	mu         sync.Mutex
+	source     oauth2.TokenSource
+	generation uint64 // Incremented whenever source is dropped
+	revoked    *revocation
+	recheckAt  time.Time
 
-	lastRefreshToken atomic.Pointer[string]
+	// lastRefreshToken is the digest of the stored refresh token. Only digests are kept,
+	// so the token itself isn't retained on the heap.
+	lastRefreshToken atomic.Pointer[[sha256.Size]byte]
 	writeMu          sync.Mutex
+
+	// expiry of the most recently issued access token (Unix nanoseconds, 0 if unknown)
+	expiry atomic.Int64
+
+	// audit records persisted token rotations for account (a tenant name, "" for the default account)
+	audit   *audit.Log
+	account string
+
+	// onRevoked and onRecovered are called when the revoked state is entered and
+	// left. They must not call back into the source.
+	onRevoked   func(Revocation)
+	onRecovered func()
+}
+
+// revocation is the revoked state, with the rejected token's digest to recognize a re-login.
+type revocation struct {
+	Revocation
+	token [sha256.Size]byte
 }
 
 // Compile-time check to ensure PersistentTokenSource implements oauth2.TokenSource
@@ -40,17 +85,46 @@ func NewPersistentTokenSource(factory TokenSourceFactory, tokenStore tokenstore.
 		return nil, fmt.Errorf("missing token store")
 	}
 
-	p := &PersistentTokenSource{
+	return &PersistentTokenSource{
 		factory:    factory,
 		tokenStore: tokenStore,
-	}
+	}, nil
+}
 
-	p.tokenSource = sync.OnceValues(p.createTokenSource)
+// tokenSource returns the cached TokenSource, creating it on first use and after a
+// revocation once the store holds a new token.
+func (p *PersistentTokenSource) tokenSource() (oauth2.TokenSource, uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	return p, nil
+	if p.source != nil {
+		return p.source, p.generation, nil
+	}
+	if p.revoked != nil {
+		if time.Now().Before(p.recheckAt) {
+			return nil, 0, ErrTokenRevoked
+		}
+		p.recheckAt = time.Now().Add(revocationRecheckInterval)
+		token, err := p.tokenStore.Read(context.Background())
+		if err != nil || sha256.Sum256([]byte(token)) == p.revoked.token {
+			return nil, 0, ErrTokenRevoked
+		}
+		p.revoked = nil
+		slog.Info("new token found after revocation, resuming", "account", p.account)
+		if p.onRecovered != nil {
+			p.onRecovered()
+		}
+	}
+
+	ts, err := p.createTokenSource()
+	if err != nil {
+		return nil, 0, err
+	}
+	p.source = ts
+	return ts, p.generation, nil
 }
 
-// createTokenSource performs one-time initialization of the TokenSource.
+// createTokenSource reads the stored token and creates the TokenSource.
 func (p *PersistentTokenSource) createTokenSource() (oauth2.TokenSource, error) {
 	// oauth2.TokenSource.Token() has no context parameter (legacy interface limitation)
 	// Use background context for initial token read
@@ -62,35 +136,37 @@ func (p *PersistentTokenSource) createTokenSource() (oauth2.TokenSource, error)
 	}
 
 	// Remember the initial token to avoid unnecessary write-back on first call to `Token()`
-	p.lastRefreshToken.Store(&initialToken)
+	digest := sha256.Sum256([]byte(initialToken))
+	p.lastRefreshToken.Store(&digest)
 
 	return p.factory(initialToken), nil
 }
 
 // Token returns a valid token, refreshing if necessary and persisting refresh tokens.
 func (p *PersistentTokenSource) Token() (*oauth2.Token, error) {
-	ts, err := p.tokenSource()
+	ts, generation, err := p.tokenSource()
 	if err != nil {
 		return nil, err
 	}
 
 	freshToken, err := ts.Token()
 	if err != nil {
+		if revokedTokenError(err) && p.revoke(generation, err) {
+			// Retry once with the restored backup, which is never restored twice
+			return p.Token()
+		}
 		return nil, fmt.Errorf("getting token from token source: %w", err)
 	}
 
-	// Hot path: lock-free atomic read for minimal contention
-	lastPtr := p.lastRefreshToken.Load()
-	last := ""
-	if lastPtr != nil {
-		last = *lastPtr
+	if !freshToken.Expiry.IsZero() {
+		p.expiry.Store(freshToken.Expiry.UnixNano())
 	}
 
 	// Persist refresh token if changed
 	// oauth2.TokenSource.Token() is contractually thread-safe, so concurrent calls receive
 	// identical tokens. Worst case: multiple goroutines write the same refresh token value.
 	// Note: Static tokens have empty RefreshToken, so this check naturally skips them
-	if freshToken.RefreshToken != "" && freshToken.RefreshToken != last {
+	if freshToken.RefreshToken != "" && !p.isLastRefreshToken(freshToken.RefreshToken) {
 		p.writeMu.Lock()
 		// Note: oauth2.TokenSource interface has no context parameter (legacy interface)
 		// Use background context for non-critical write-back operation
@@ -100,12 +176,125 @@ func (p *PersistentTokenSource) Token() (*oauth2.Token, error) {
 			// Access token is still valid, but future refreshes will fail without persisted token
 			slog.ErrorContext(ctx, "failed to persist refresh token")
 		} else {
-			// Update cached token only on success - allows retry on next call
-			newToken := freshToken.RefreshToken
-			p.lastRefreshToken.Store(&newToken)
+			// Update cached digest only on success - allows retry on next call
+			digest := sha256.Sum256([]byte(freshToken.RefreshToken))
+			p.lastRefreshToken.Store(&digest)
+			p.audit.Record(ctx, audit.Event{Action: audit.ActionTokenRotation, Actor: "proxy", Target: p.account})
 		}
 		p.writeMu.Unlock()
 	}
 
 	return freshToken, nil
 }
+
+// isLastRefreshToken reports whether token is the stored refresh token.
+// Hot path: lock-free atomic read for minimal contention.
+func (p *PersistentTokenSource) isLastRefreshToken(token string) bool {
+	last := p.lastRefreshToken.Load()
+	return last != nil && *last == sha256.Sum256([]byte(token))
+}
+
+// revoke handles the rejection of the source of generation with err: it restores
+// the store's backup token if there is a different one, and reports whether it
+// did. Otherwise it enters the revoked state. Concurrent failures of the same
+// source are handled once.
+func (p *PersistentTokenSource) revoke(generation uint64, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.source == nil || p.generation != generation {
+		return false
+	}
+	p.source = nil
+	p.generation++
+
+	var token [sha256.Size]byte
+	if last := p.lastRefreshToken.Load(); last != nil {
+		token = *last
+	}
+	if p.restoreBackup(token) {
+		return true
+	}
+	reason := "invalid_grant"
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) && re.ErrorDescription != "" {
+		reason = re.ErrorDescription
+	}
+	p.revoked = &revocation{Revocation: Revocation{Time: time.Now(), Reason: reason}, token: token}
+	p.recheckAt = time.Now().Add(revocationRecheckInterval)
+	p.expiry.Store(0)
+
+	slog.Error("refresh token revoked, requests fail until you log in again with 'claudine auth login'",
+		"account", p.account, "reason", reason)
+	p.audit.Record(context.Background(), audit.Event{Action: audit.ActionTokenRevoked, Actor: "proxy", Target: p.account, Detail: reason})
+	if p.onRevoked != nil {
+		p.onRevoked(p.revoked.Revocation)
+	}
+	return false
+}
+
+// restoreBackup replaces the rejected token in the store with its backup, if the
+// store keeps one that differs, and reports whether it did. This recovers from
+// a corrupted write or a rotation race without logging in again.
+func (p *PersistentTokenSource) restoreBackup(rejected [sha256.Size]byte) bool {
+	store, ok := p.tokenStore.(tokenstore.BackupStore)
+	if !ok {
+		return false
+	}
+	ctx := context.Background()
+	backup, err := store.ReadBackup(ctx)
+	if err != nil || sha256.Sum256([]byte(backup)) == rejected {
+		return false
+	}
+	if err := store.Restore(ctx); err != nil {
+		slog.WarnContext(ctx, "failed to restore backup refresh token", "account", p.account, "error", err)
+		return false
+	}
+
+	slog.WarnContext(ctx, "refresh token rejected, restored the previous one from backup", "account", p.account)
+	p.audit.Record(ctx, audit.Event{Action: audit.ActionTokenRestore, Actor: "proxy", Target: p.account})
+	return true
+}
+
+// Revoked returns the current revocation, or nil if the token is not known to be revoked.
+func (p *PersistentTokenSource) Revoked() *Revocation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.revoked == nil {
+		return nil
+	}
+	r := p.revoked.Revocation
+	return &r
+}
+
+// revokedTokenError reports whether err is the token endpoint rejecting the
+// refresh token itself, which only a new login resolves.
+func revokedTokenError(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && re.ErrorCode == "invalid_grant"
+}
+
+// Expiry returns when the most recently issued access token expires.
+// Zero if no token was issued yet or the token does not expire.
+func (p *PersistentTokenSource) Expiry() time.Time {
+	if ns := p.expiry.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// countingTokenSource reports failed token retrievals, such as rejected refreshes.
+type countingTokenSource struct {
+	oauth2.TokenSource
+	failed func()
+}
+
+// Token implements oauth2.TokenSource.
+func (c *countingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := c.TokenSource.Token()
+	if err != nil {
+		c.failed()
+	}
+	return token, err
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/tokenstore"
+)
+
+// memoryStore is an in-memory tokenstore.TokenStore.
+type memoryStore struct{ token string }
+
+func (s *memoryStore) Read(context.Context) (string, error)        { return s.token, nil }
+func (s *memoryStore) Write(_ context.Context, token string) error { s.token = token; return nil }
+
+func TestPersistentTokenSourceRevocation(t *testing.T) {
+	store := &memoryStore{token: "revoked"}
+	refreshes := 0
+	factory := func(token string) oauth2.TokenSource {
+		return tokenSourceFunc(func() (*oauth2.Token, error) {
+			refreshes++
+			if token == "revoked" {
+				return nil, &oauth2.RetrieveError{
+					Response:         &http.Response{StatusCode: http.StatusBadRequest},
+					ErrorCode:        "invalid_grant",
+					ErrorDescription: "Refresh token not found or invalid",
+				}
+			}
+			return &oauth2.Token{AccessToken: "access", RefreshToken: token, Expiry: time.Now().Add(time.Hour)}, nil
+		})
+	}
+	ts, err := NewPersistentTokenSource(factory, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := NewHealth()
+	health.SetReady(true)
+	(&revocations{health: health}).watch(ts, "acme")
+
+	if _, err := ts.Token(); !revokedTokenError(err) {
+		t.Fatalf("first Token() error = %v, want invalid_grant", err)
+	}
+	if r := ts.Revoked(); r == nil || r.Reason != "Refresh token not found or invalid" {
+		t.Fatalf("Revoked() = %v, want the endpoint's reason", r)
+	}
+	if health.IsReady() || health.Degraded()["token:acme"] == "" {
+		t.Fatalf("health ready with degraded %v, want degraded token:acme", health.Degraded())
+	}
+
+	// While revoked, requests fail fast without hitting the token endpoint
+	ts.recheckAt = time.Time{}
+	if _, err := ts.Token(); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Token() while revoked error = %v, want ErrTokenRevoked", err)
+	}
+	if refreshes != 1 {
+		t.Fatalf("%d refreshes, want 1", refreshes)
+	}
+
+	// A new login is picked up without restart
+	store.token = "fresh"
+	ts.recheckAt = time.Time{}
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token() after login: %v", err)
+	}
+	if ts.Revoked() != nil || !health.IsReady() {
+		t.Fatalf("still revoked after login: %v, degraded %v", ts.Revoked(), health.Degraded())
+	}
+}
+
+func TestPersistentTokenSourceRestoresBackup(t *testing.T) {
+	store, err := tokenstore.NewFileStore(filepath.Join(t.TempDir(), "token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, token := range []string{"good", "corrupted"} {
+		if err := store.Write(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	factory := func(token string) oauth2.TokenSource {
+		return tokenSourceFunc(func() (*oauth2.Token, error) {
+			if token != "good" {
+				return nil, &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, ErrorCode: "invalid_grant"}
+			}
+			return &oauth2.Token{AccessToken: "access", RefreshToken: token}, nil
+		})
+	}
+	ts, err := NewPersistentTokenSource(factory, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.Token(); err != nil {
+		t.Fatalf("Token() = %v, want success with the backup", err)
+	}
+	if ts.Revoked() != nil {
+		t.Error("revoked despite restored backup")
+	}
+	if token, err := store.Read(ctx); err != nil || token != "good" {
+		t.Errorf("stored token = %q, %v; want the restored backup", token, err)
+	}
+
+	// Logout clears the backup
+	if err := store.Write(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ReadBackup(ctx); err == nil {
+		t.Error("backup kept after logout")
+	}
+}
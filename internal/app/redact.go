@@ -0,0 +1,162 @@
+package app
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secrets in redacted configuration output.
+const redactedValue = "********"
+
+// Redacted returns the configuration as a nested map keyed by config file names,
+// with fields tagged `secret:"true"` masked. Durations are rendered as strings
+// (e.g., "5m0s") so the result can be written back as a config file.
+func (c *Config) Redacted() map[string]any {
+	return redactStruct(reflect.ValueOf(*c))
+}
+
+func redactStruct(v reflect.Value) map[string]any {
+	out := make(map[string]any, v.NumField())
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		value := v.Field(i)
+		if field.Tag.Get("secret") == "true" {
+			switch {
+			case value.Kind() == reflect.String:
+				out[name] = redactSecret(value.String())
+				continue
+			case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+				items := make([]any, value.Len())
+				for j := range value.Len() {
+					items[j] = redactSecret(value.Index(j).String())
+				}
+				out[name] = items
+				continue
+			}
+		}
+		out[name] = redactValue(value)
+	}
+	return out
+}
+
+func redactValue(v reflect.Value) any {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return redactStruct(v)
+	case reflect.Slice, reflect.Array:
+		items := make([]any, v.Len())
+		for i := range v.Len() {
+			items[i] = redactValue(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		items := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			items[iter.Key().String()] = redactValue(iter.Value())
+		}
+		return items
+	case reflect.String:
+		return v.String()
+	default:
+		return v.Interface()
+	}
+}
+
+// redactSecret masks a secret value. URLs keep everything but their password
+// and sensitive query parameters, and key=value connection strings everything but
+// sensitive values, so hosts remain visible for debugging. Empty values stay
+// empty to show they are unset.
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" {
+		if u.User != nil {
+			if _, ok := u.User.Password(); !ok {
+				return redactedValue // User name is the credential
+			}
+		}
+		u.RawQuery = redactQuery(u.RawQuery)
+		return u.Redacted()
+	}
+	if dsn, ok := redactDSN(s); ok {
+		return dsn
+	}
+	return redactedValue
+}
+
+// sensitiveWords mark query parameters and connection string keys holding
+// credentials, e.g. password, access_token, client_secret or sig.
+var sensitiveWords = []string{"pass", "pwd", "secret", "token", "key", "auth", "credential", "sig"}
+
+func sensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactQuery masks the values of sensitive parameters in a raw URL query.
+func redactQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		name, _, ok := strings.Cut(param, "=")
+		unescaped, err := url.QueryUnescape(name)
+		if err != nil {
+			unescaped = name
+		}
+		if ok && sensitiveName(unescaped) {
+			params[i] = name + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// dsnPair matches a key=value pair of a libpq or ADO.NET style connection
+// string, such as "host=db password='s3cret'" or "Server=db;Password=s3cret".
+var dsnPair = regexp.MustCompile(`([A-Za-z_][\w.-]*(?: [\w.-]+)*)\s*=\s*('(?:[^'\\]|\\.)*'|[^\s;']+)`)
+
+// redactDSN masks the sensitive values of a key=value connection string. Returns
+// false unless s is one with at least one sensitive value.
+func redactDSN(s string) (string, bool) {
+	if strings.Trim(dsnPair.ReplaceAllString(s, ""), " \t\r\n;") != "" {
+		return "", false
+	}
+	sensitive := false
+	redacted := dsnPair.ReplaceAllStringFunc(s, func(pair string) string {
+		m := dsnPair.FindStringSubmatchIndex(pair)
+		if !sensitiveName(pair[m[2]:m[3]]) {
+			return pair
+		}
+		sensitive = true
+		return pair[:m[4]] + redactedValue
+	})
+	return redacted, sensitive
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/metrics"
+)
+
+// revocationNotifyTimeout bounds delivery of a revocation notification.
+const revocationNotifyTimeout = 10 * time.Second
+
+// revocationNotice is the body POSTed to auth.revocation_webhook.
+type revocationNotice struct {
+	Event   string    `json:"event"`
+	Account string    `json:"account"` // Tenant name, "default" for the default account
+	Time    time.Time `json:"time"`
+	Reason  string    `json:"reason"`
+	Action  string    `json:"action"`
+}
+
+// revocations reports token sources entering and leaving the revoked state:
+// the application is marked degraded, counted and optionally a webhook notified.
+type revocations struct {
+	health  *Health
+	metrics *metrics.ErrorCollector
+	webhook string
+}
+
+// watch reports revocations of ts, the token source of tenant ("" for the default account).
+func (r *revocations) watch(ts *PersistentTokenSource, tenant string) {
+	account, login := tenant, "claudine auth login --tenant "+tenant
+	if tenant == "" {
+		account, login = "default", "claudine auth login"
+	}
+	component := "token:" + account
+
+	ts.onRevoked = func(rev Revocation) {
+		r.health.SetDegraded(component, "refresh token revoked: "+rev.Reason)
+		r.metrics.TokenRevoked(account)
+		if r.webhook != "" {
+			go r.notify(revocationNotice{
+				Event:   "token_revoked",
+				Account: account,
+				Time:    rev.Time,
+				Reason:  rev.Reason,
+				Action:  login,
+			})
+		}
+	}
+	ts.onRecovered = func() {
+		r.health.SetDegraded(component, "")
+	}
+}
+
+// notify POSTs notice to the revocation webhook.
+func (r *revocations) notify(notice revocationNotice) {
+	ctx, cancel := context.WithTimeout(context.Background(), revocationNotifyTimeout)
+	defer cancel()
+
+	if err := postNotification(ctx, r.webhook, notice); err != nil {
+		slog.WarnContext(ctx, "failed to deliver token revocation notification", "account", notice.Account, "error", err)
+	}
+}
+
+// postNotification POSTs v as JSON to url.
+func postNotification(ctx context.Context, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// StartupConfig holds checks performed before the proxy accepts traffic.
+type StartupConfig struct {
+	// VerifyToken acquires an access token before binding the listener, so invalid
+	// credentials fail startup instead of the first request.
+	VerifyToken bool `json:"verify_token"`
+
+	// VerifyTimeout bounds retries of transient token failures.
+	VerifyTimeout time.Duration `json:"verify_timeout" validate:"gte=0"`
+}
+
+// verifyToken calls Token until it succeeds, retrying transient failures with
+// exponential backoff until timeout. Rejected credentials fail immediately.
+func verifyToken(ctx context.Context, ts oauth2.TokenSource, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		_, err := ts.Token()
+		if err == nil {
+			return nil
+		}
+		if !retryableTokenError(err) {
+			return err
+		}
+
+		slog.WarnContext(ctx, "initial token acquisition failed, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (gave up after %d attempts: %w)", err, attempt, context.Cause(ctx))
+		}
+		backoff = min(backoff*2, 10*time.Second)
+	}
+}
+
+// retryableTokenError reports whether a token failure may resolve on its own:
+// network errors and 429 or 5xx token endpoint responses. Anything else, such as
+// a missing stored token or a rejected refresh, needs user action.
+func retryableTokenError(err error) bool {
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) && re.Response != nil {
+		code := re.Response.StatusCode
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+	// Not net.Error: syscall.Errno implements it too, e.g. for a missing token file
+	var urlErr *url.Error
+	var opErr *net.OpError
+	return errors.As(err, &urlErr) || errors.As(err, &opErr)
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenSourceFunc adapts a function to oauth2.TokenSource.
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) { return f() }
+
+func TestVerifyToken(t *testing.T) {
+	transient := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	missing := fmt.Errorf("failed to read initial token: %w", &fs.PathError{Op: "stat", Path: "token", Err: syscall.ENOENT})
+	rejected := &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}, ErrorCode: "invalid_grant"}
+	unavailable := &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}
+
+	tests := []struct {
+		name      string
+		errs      []error // returned by consecutive calls, then success
+		timeout   time.Duration
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", wantCalls: 1, timeout: time.Second},
+		{name: "transient then success", errs: []error{transient, unavailable}, timeout: 5 * time.Second, wantCalls: 3},
+		{name: "missing token fails fast", errs: []error{missing}, timeout: 5 * time.Second, wantCalls: 1, wantErr: missing},
+		{name: "rejected fails fast", errs: []error{rejected}, timeout: 5 * time.Second, wantCalls: 1, wantErr: rejected},
+		{name: "timeout", errs: []error{transient, transient, transient}, timeout: 100 * time.Millisecond, wantCalls: 1, wantErr: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			ts := tokenSourceFunc(func() (*oauth2.Token, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return nil, tt.errs[calls-1]
+				}
+				return &oauth2.Token{AccessToken: "token"}, nil
+			})
+
+			err := verifyToken(context.Background(), ts, tt.timeout)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package audit records administrative and authentication events to an
+// append-only log.
+//
+// Events are written as JSON lines to a file, to the audit_events table of the
+// storage database, or both. Recording never fails the audited action; write
+// errors are logged instead.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/storage"
+)
+
+// Actions recorded by the proxy and the CLI.
+const (
+	ActionLogin         = "auth.login"
+	ActionLogout        = "auth.logout"
+	ActionTokenRotation = "auth.token_rotation"
+	ActionTokenRevoked  = "auth.token_revoked"
+	ActionTokenRestore  = "auth.token_restore"
+	ActionConfigReload  = "config.reload"
+	ActionCachePurge    = "cache.purge"
+	ActionPolicyReject  = "policy.reject"
+	ActionAgentLoop     = "agent.loop"
+
+	// ActionUpstreamRequest targets a request ID; its detail is a signed RequestDigest in JSON
+	ActionUpstreamRequest = "upstream.request"
+)
+
+// Event is an audited action.
+type Event struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`            // Who acted: a client key ID, OS user or "proxy"
+	Target    string    `json:"target,omitempty"` // What was acted on, e.g. an account or key
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Recorder persists audit events, such as storage.Store.
+type Recorder interface {
+	RecordAudit(ctx context.Context, e storage.AuditEvent) error
+}
+
+// Log appends events to a file and a Recorder. A nil *Log discards events.
+// It is safe for concurrent use.
+type Log struct {
+	recorder Recorder
+	path     string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open creates a Log appending to path, if not empty, and recorder, if not nil.
+// The file and its parent directories are created as needed. Open returns nil
+// if there is nowhere to record to.
+func Open(path string, recorder Recorder) (*Log, error) {
+	if path == "" && recorder == nil {
+		return nil, nil
+	}
+
+	l := &Log{recorder: recorder, path: path}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		l.file = file
+	}
+	return l, nil
+}
+
+// Record appends e, filling in its ID and timestamp if unset.
+func (l *Log) Record(ctx context.Context, e Event) {
+	if l == nil {
+		return
+	}
+	if e.ID == "" {
+		e.ID = rand.Text()
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	e.Timestamp = e.Timestamp.UTC()
+
+	if l.path != "" {
+		if err := l.write(e); err != nil {
+			slog.WarnContext(ctx, "failed to write audit event", "action", e.Action, "error", err)
+		}
+	}
+	if l.recorder != nil {
+		err := l.recorder.RecordAudit(ctx, storage.AuditEvent{
+			ID:        e.ID,
+			Timestamp: e.Timestamp,
+			Actor:     e.Actor,
+			Action:    e.Action,
+			Target:    e.Target,
+			Detail:    e.Detail,
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "failed to persist audit event", "action", e.Action, "error", err)
+		}
+	}
+}
+
+// write appends e to the file as a single line.
+func (l *Log) write(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.New("audit log closed")
+	}
+	_, err = l.file.Write(line)
+	return err
+}
+
+// ReadFile returns the events in the audit log file at path, oldest first.
+func ReadFile(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var events []Event
+	dec := json.NewDecoder(file)
+	for {
+		var e Event
+		if err := dec.Decode(&e); errors.Is(err, io.EOF) {
+			return events, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid audit log %s: %w", path, err)
+		}
+		events = append(events, e)
+	}
+}
+
+// Close closes the file. The Recorder is left open.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/storage"
+)
+
+type recorderFunc func(ctx context.Context, e storage.AuditEvent) error
+
+func (f recorderFunc) RecordAudit(ctx context.Context, e storage.AuditEvent) error {
+	return f(ctx, e)
+}
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+
+	var recorded []storage.AuditEvent
+	recorder := recorderFunc(func(_ context.Context, e storage.AuditEvent) error {
+		recorded = append(recorded, e)
+		return nil
+	})
+
+	// Reopening appends to the existing file
+	for _, action := range []string{ActionLogin, ActionTokenRotation} {
+		l, err := Open(path, recorder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Record(ctx, Event{Action: action, Actor: "alice", Target: "team-a"})
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 || events[0].Action != ActionLogin || events[1].Action != ActionTokenRotation {
+		t.Fatalf("file events = %+v, want login then token rotation", events)
+	}
+	if events[0].ID == "" || events[0].Timestamp.IsZero() || events[0].Actor != "alice" || events[0].Target != "team-a" {
+		t.Errorf("file event = %+v, want ID, timestamp, actor and target", events[0])
+	}
+
+	if len(recorded) != 2 || recorded[0].ID != events[0].ID || !recorded[0].Timestamp.Equal(events[0].Timestamp) {
+		t.Errorf("recorded events = %+v, want the events written to the file", recorded)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("audit log mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestLogDisabled(t *testing.T) {
+	l, err := Open("", nil)
+	if err != nil || l != nil {
+		t.Fatalf("Open without destinations = %v, %v; want nil", l, err)
+	}
+
+	// A nil Log discards events
+	l.Record(context.Background(), Event{Action: ActionConfigReload})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
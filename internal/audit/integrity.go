@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// unsignedHeaders are left out of request digests: credentials, and headers
+// set by the HTTP transport after the digest is taken.
+var unsignedHeaders = map[string]bool{
+	"authorization":     true,
+	"x-api-key":         true,
+	"cookie":            true,
+	"content-length":    true,
+	"accept-encoding":   true,
+	"connection":        true,
+	"transfer-encoding": true,
+}
+
+// RequestDigest describes an upstream request without credentials, signed with
+// HMAC-SHA256 so that its record in the audit log proves what was sent.
+type RequestDigest struct {
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	BodySHA256 string            `json:"body_sha256"`
+	BodySize   int64             `json:"body_size"`
+	Signature  string            `json:"signature,omitempty"`
+}
+
+// NewRequestDigest returns the unsigned digest of req, whose body hashed to
+// bodySHA256 over bodySize bytes. Header names are lowercased; repeated header
+// values are joined with commas.
+func NewRequestDigest(req *http.Request, bodySHA256 []byte, bodySize int64) *RequestDigest {
+	d := &RequestDigest{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Headers:    make(map[string]string, len(req.Header)),
+		BodySHA256: hex.EncodeToString(bodySHA256),
+		BodySize:   bodySize,
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if !unsignedHeaders[name] {
+			d.Headers[name] = strings.Join(values, ",")
+		}
+	}
+	return d
+}
+
+// canonical returns the signed form of d:
+//
+//	claudine-request-v1
+//	METHOD
+//	URL
+//	name:value      (one line per header, sorted by name)
+//
+//	body size
+//	body SHA-256 (hex)
+func (d *RequestDigest) canonical() []byte {
+	var b bytes.Buffer
+	b.WriteString("claudine-request-v1\n")
+	b.WriteString(d.Method + "\n")
+	b.WriteString(d.URL + "\n")
+	for _, name := range slices.Sorted(maps.Keys(d.Headers)) {
+		b.WriteString(name + ":" + d.Headers[name] + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(strconv.FormatInt(d.BodySize, 10) + "\n")
+	b.WriteString(d.BodySHA256)
+	return b.Bytes()
+}
+
+// signature returns the hex-encoded HMAC-SHA256 of d's canonical form.
+func (d *RequestDigest) signature(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(d.canonical())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign sets d's signature with key.
+func (d *RequestDigest) Sign(key []byte) {
+	d.Signature = d.signature(key)
+}
+
+// Verify reports whether d is unchanged since it was signed with key.
+func (d *RequestDigest) Verify(key []byte) bool {
+	return d.Signature != "" && hmac.Equal([]byte(d.Signature), []byte(d.signature(key)))
+}
+
+// MatchesBody reports whether body is the request body d was taken of.
+func (d *RequestDigest) MatchesBody(body []byte) bool {
+	sum := sha256.Sum256(body)
+	return int64(len(body)) == d.BodySize && hex.EncodeToString(sum[:]) == d.BodySHA256
+}
@@ -0,0 +1,245 @@
+// Package bench measures the latency and allocations the proxy adds on top of
+// upstream, by serving requests in-process against a mock upstream that answers
+// instantly. Everything measured is therefore proxy overhead.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/proxy"
+)
+
+// Paths benchmarked: the injector path forwarding native Anthropic requests and
+// the adapter path translating OpenAI chat completions.
+const (
+	PathMessages        = "/v1/messages"
+	PathChatCompletions = "/v1/chat/completions"
+)
+
+// Options configures a benchmark run.
+type Options struct {
+	// Requests is the number of measured requests per path and payload size.
+	Requests int
+
+	// Concurrency is the number of requests in flight.
+	Concurrency int
+
+	// Sizes are the request payload sizes in bytes.
+	Sizes []int
+
+	// Stream requests streaming responses.
+	Stream bool
+}
+
+// Result summarizes the requests for one path and payload size.
+type Result struct {
+	Path     string
+	Size     int
+	Requests int
+	Errors   int
+
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+	Mean time.Duration
+
+	// AllocsPerOp and BytesPerOp are heap allocations per request, including
+	// the mock upstream's share.
+	AllocsPerOp uint64
+	BytesPerOp  uint64
+}
+
+// Run benchmarks each path at each payload size.
+func Run(ctx context.Context, opts Options) ([]Result, error) {
+	if opts.Requests <= 0 {
+		return nil, fmt.Errorf("requests must be positive")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	p, err := proxy.New(
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "bench"}),
+		alwaysReady{},
+		proxy.WithTransport(upstream{}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create proxy: %w", err)
+	}
+
+	var results []Result
+	for _, path := range []string{PathMessages, PathChatCompletions} {
+		for _, size := range opts.Sizes {
+			body := requestBody(path, size, opts.Stream)
+
+			// Warm up pools and lazily initialized state before measuring
+			if _, err := measure(ctx, p, path, body, min(opts.Requests, 10), opts.Concurrency); err != nil {
+				return results, err
+			}
+
+			result, err := measure(ctx, p, path, body, opts.Requests, opts.Concurrency)
+			if err != nil {
+				return results, err
+			}
+			result.Size = size
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func measure(ctx context.Context, h http.Handler, path string, body []byte, requests, concurrency int) (Result, error) {
+	latencies := make([]time.Duration, requests)
+	var next, errs atomic.Int64
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= requests || ctx.Err() != nil {
+					return
+				}
+
+				req := httptest.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+
+				start := time.Now()
+				h.ServeHTTP(rec, req)
+				latencies[i] = time.Since(start)
+
+				if rec.Code != http.StatusOK {
+					errs.Add(1)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	runtime.ReadMemStats(&after)
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	slices.Sort(latencies)
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+
+	n := uint64(requests)
+	return Result{
+		Path:        path,
+		Requests:    requests,
+		Errors:      int(errs.Load()),
+		P50:         percentile(latencies, 0.50),
+		P90:         percentile(latencies, 0.90),
+		P99:         percentile(latencies, 0.99),
+		Max:         latencies[len(latencies)-1],
+		Mean:        total / time.Duration(requests),
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / n,
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / n,
+	}, nil
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+// requestBody builds a request for path whose user message pads it to about size bytes.
+func requestBody(path string, size int, stream bool) []byte {
+	var prefix string
+	switch path {
+	case PathMessages:
+		prefix = `{"model":"claude-sonnet-4-5","max_tokens":16,"stream":` + strconv.FormatBool(stream) + `,"messages":[{"role":"user","content":"`
+	default:
+		prefix = `{"model":"claude-sonnet-4-5","stream":` + strconv.FormatBool(stream) + `,"messages":[{"role":"user","content":"`
+	}
+	const suffix = `"}]}`
+
+	padding := max(1, size-len(prefix)-len(suffix))
+	return []byte(prefix + strings.Repeat("a", padding) + suffix)
+}
+
+// ParseSize parses a payload size such as 512, 4k or 1m (binary units).
+func ParseSize(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1<<10, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier, s = 1<<20, strings.TrimSuffix(s, "m")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+type alwaysReady struct{}
+
+func (alwaysReady) IsReady() bool { return true }
+
+// upstream is a mock Anthropic API answering every request with a short message.
+type upstream struct{}
+
+// MockUpstream answers every request instantly with a short message, streamed if
+// the request asks for it. It accepts any credentials.
+var MockUpstream http.RoundTripper = upstream{}
+
+const (
+	messageJSON = `{"id":"msg_bench","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":1}}`
+
+	messageEvents = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_bench\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"claude-sonnet-4-5\",\"content\":[],\"stop_reason\":null,\"stop_sequence\":null,\"usage\":{\"input_tokens\":10,\"output_tokens\":0}}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"ok\"}}\n\n" +
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\",\"stop_sequence\":null},\"usage\":{\"output_tokens\":1}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+)
+
+// RoundTrip implements http.RoundTripper interface.
+func (upstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Read the request like a real upstream would
+	reqBody, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	body, contentType := messageJSON, "application/json"
+	if bytes.Contains(reqBody, []byte(`"stream":true`)) {
+		body, contentType = messageEvents, "text/event-stream"
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package bench
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		results, err := Run(context.Background(), Options{
+			Requests:    20,
+			Concurrency: 4,
+			Sizes:       []int{256, 8 << 10},
+			Stream:      stream,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 4 {
+			t.Fatalf("got %d results, want 4", len(results))
+		}
+		for _, r := range results {
+			if r.Errors != 0 {
+				t.Errorf("%s size %d stream %v: %d errors", r.Path, r.Size, stream, r.Errors)
+			}
+			if r.P50 <= 0 || r.P50 > r.P99 || r.P99 > r.Max {
+				t.Errorf("%s size %d: inconsistent percentiles p50=%v p99=%v max=%v", r.Path, r.Size, r.P50, r.P99, r.Max)
+			}
+			if r.AllocsPerOp == 0 {
+				t.Errorf("%s size %d: no allocations recorded", r.Path, r.Size)
+			}
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "512", want: 512},
+		{in: "4k", want: 4096},
+		{in: "1M", want: 1 << 20},
+		{in: "0", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d, err %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package cache stores upstream responses for identical non-streaming requests.
+//
+// Entries are keyed on a hash of the route, the client key and the normalized
+// (key-sorted) request body, so semantically identical JSON hits the same entry.
+// An in-memory LRU serves as first tier; disk or Redis can be added as shared,
+// persistent second tier.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotFound is returned by Store.Get for missing or expired entries.
+var ErrNotFound = errors.New("cache entry not found")
+
+// Entry is a cached response.
+type Entry struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Expires time.Time   `json:"expires"`
+
+	// Request the response was cached for, to tell entries apart when inspecting
+	Path  string `json:"path,omitempty"`
+	KeyID string `json:"key_id,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+// Info describes a cached entry without its response.
+type Info struct {
+	Key     string    `json:"key"`
+	Path    string    `json:"path,omitempty"`
+	KeyID   string    `json:"key_id,omitempty"`
+	Model   string    `json:"model,omitempty"`
+	Size    int       `json:"size"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// info describes e stored under key.
+func (e *Entry) info(key string) Info {
+	return Info{Key: key, Path: e.Path, KeyID: e.KeyID, Model: e.Model, Size: len(e.Body), Expires: e.Expires}
+}
+
+// expired reports whether the entry is stale at now.
+func (e *Entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+// Store is a cache backend.
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, entry *Entry) error
+	Delete(ctx context.Context, key string) error
+	// Keys lists the unexpired entries.
+	Keys(ctx context.Context) ([]Info, error)
+	Close() error
+}
+
+// Memory is an in-memory LRU store bounded by entry count.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+}
+
+// Compile-time check that Memory implements Store
+var _ Store = (*Memory)(nil)
+
+// NewMemory creates an LRU store holding at most maxEntries responses.
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &Memory{maxEntries: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the entry for key and marks it as recently used.
+func (m *Memory) Get(_ context.Context, key string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	item := el.Value.(*memoryItem)
+	if item.entry.expired(time.Now()) {
+		m.ll.Remove(el)
+		delete(m.items, key)
+		return nil, ErrNotFound
+	}
+	m.ll.MoveToFront(el)
+	return item.entry, nil
+}
+
+// Set stores entry, evicting the least recently used entry when full.
+func (m *Memory) Set(_ context.Context, key string, entry *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		el.Value.(*memoryItem).entry = entry
+		m.ll.MoveToFront(el)
+		return nil
+	}
+	m.items[key] = m.ll.PushFront(&memoryItem{key: key, entry: entry})
+	for m.ll.Len() > m.maxEntries {
+		oldest := m.ll.Back()
+		m.ll.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
+
+// Delete removes the entry for key, if any.
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.ll.Remove(el)
+		delete(m.items, key)
+	}
+	return nil
+}
+
+// Keys lists the unexpired entries, most recently used first.
+func (m *Memory) Keys(context.Context) ([]Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	infos := make([]Info, 0, m.ll.Len())
+	for el := m.ll.Front(); el != nil; el = el.Next() {
+		item := el.Value.(*memoryItem)
+		if !item.entry.expired(now) {
+			infos = append(infos, item.entry.info(item.key))
+		}
+	}
+	return infos, nil
+}
+
+// Close is a no-op.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Tiered reads through a fast first tier backed by a slower shared second tier.
+type Tiered struct {
+	L1 Store
+	L2 Store
+}
+
+// Compile-time check that Tiered implements Store
+var _ Store = (*Tiered)(nil)
+
+// Get checks L1, then L2, promoting L2 hits into L1.
+func (t *Tiered) Get(ctx context.Context, key string) (*Entry, error) {
+	if entry, err := t.L1.Get(ctx, key); err == nil {
+		return entry, nil
+	}
+	entry, err := t.L2.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	_ = t.L1.Set(ctx, key, entry)
+	return entry, nil
+}
+
+// Set writes to both tiers.
+func (t *Tiered) Set(ctx context.Context, key string, entry *Entry) error {
+	_ = t.L1.Set(ctx, key, entry)
+	return t.L2.Set(ctx, key, entry)
+}
+
+// Delete removes the entry from both tiers.
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	return errors.Join(t.L1.Delete(ctx, key), t.L2.Delete(ctx, key))
+}
+
+// Keys lists the entries of both tiers. L1 only holds copies of L2 entries,
+// unless an L2 write failed.
+func (t *Tiered) Keys(ctx context.Context) ([]Info, error) {
+	infos, err := t.L2.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		seen[info.Key] = true
+	}
+	l1, err := t.L1.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range l1 {
+		if !seen[info.Key] {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// Close closes both tiers.
+func (t *Tiered) Close() error {
+	return errors.Join(t.L1.Close(), t.L2.Close())
+}
+
+// Purge deletes the entries whose key matches pattern (see path.Match), or all
+// entries if pattern is empty, and returns the number deleted.
+func Purge(ctx context.Context, store Store, pattern string) (int, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+	}
+	infos, err := store.Keys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var purged int
+	for _, info := range infos {
+		if pattern != "" {
+			if ok, _ := path.Match(pattern, info.Key); !ok {
+				continue
+			}
+		}
+		if err := store.Delete(ctx, info.Key); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Stats summarizes the cache and its lookups since startup.
+type Stats struct {
+	Entries int     `json:"entries"`
+	Size    int     `json:"size"` // Bytes of cached response bodies
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"` // Hits per lookup, 0 without lookups
+}
+
+// Counting wraps a Store to count lookups. Lookups skipped by clients with
+// "Cache-Control: no-cache" are not counted.
+type Counting struct {
+	Store
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Compile-time check that Counting implements Store
+var _ Store = (*Counting)(nil)
+
+// NewCounting wraps store to count lookups.
+func NewCounting(store Store) *Counting {
+	return &Counting{Store: store}
+}
+
+// Get returns the entry for key and counts the lookup as hit or miss.
+func (c *Counting) Get(ctx context.Context, key string) (*Entry, error) {
+	entry, err := c.Store.Get(ctx, key)
+	switch {
+	case err == nil:
+		c.hits.Add(1)
+	case errors.Is(err, ErrNotFound):
+		c.misses.Add(1)
+	}
+	return entry, err
+}
+
+// Stats returns the number and size of entries and the lookups counted so far.
+func (c *Counting) Stats(ctx context.Context) (Stats, error) {
+	infos, err := c.Keys(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{Entries: len(infos), Hits: c.hits.Load(), Misses: c.misses.Load()}
+	for _, info := range infos {
+		stats.Size += info.Size
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats, nil
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var upstreamCalls int
+	handler := Middleware(NewMemory(10), time.Minute, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"claude-sonnet-4-5"}`))
+	}))
+
+	tests := []struct {
+		name         string
+		body         string
+		cacheControl string
+		wantCache    string
+		wantCalls    int
+	}{
+		{name: "first request misses", body: `{"model":"m","max_tokens":1}`, wantCache: "MISS", wantCalls: 1},
+		{name: "reordered fields hit", body: `{ "max_tokens":1, "model":"m" }`, wantCache: "HIT", wantCalls: 1},
+		{name: "no-cache bypasses lookup", body: `{"model":"m","max_tokens":1}`, cacheControl: "no-cache", wantCache: "MISS", wantCalls: 2},
+		{name: "different body misses", body: `{"model":"m","max_tokens":2}`, wantCache: "MISS", wantCalls: 3},
+		{name: "streaming is never cached", body: `{"model":"m","stream":true}`, wantCache: "", wantCalls: 4},
+		{name: "streaming repeat still calls upstream", body: `{"model":"m","stream":true}`, wantCache: "", wantCalls: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(tt.body))
+			if tt.cacheControl != "" {
+				req.Header.Set("Cache-Control", tt.cacheControl)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(HeaderCache); got != tt.wantCache {
+				t.Errorf("%s = %q, want %q", HeaderCache, got, tt.wantCache)
+			}
+			if upstreamCalls != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", upstreamCalls, tt.wantCalls)
+			}
+			if got := rec.Body.String(); got != `{"model":"claude-sonnet-4-5"}` {
+				t.Errorf("body = %s", got)
+			}
+		})
+	}
+}
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+	_ = m.Set(ctx, "a", &Entry{Status: 200})
+	_ = m.Set(ctx, "b", &Entry{Status: 200})
+	_, _ = m.Get(ctx, "a") // a is now most recently used
+	_ = m.Set(ctx, "c", &Entry{Status: 200})
+
+	if _, err := m.Get(ctx, "b"); err != ErrNotFound {
+		t.Errorf("Get(b) error = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Get(ctx, "a"); err != nil {
+		t.Errorf("Get(a) error = %v", err)
+	}
+	_ = m.Set(ctx, "d", &Entry{Status: 200, Expires: time.Now().Add(-time.Second)})
+	if _, err := m.Get(ctx, "d"); err != ErrNotFound {
+		t.Errorf("Get(expired) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	disk, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &Tiered{L1: NewMemory(10), L2: disk}
+	for _, key := range []string{"aa01", "aa02", "bb01"} {
+		_ = store.Set(ctx, key, &Entry{Status: 200, Body: []byte("{}"), Model: "claude-sonnet-4-5"})
+	}
+	_ = disk.Set(ctx, "cc01", &Entry{Status: 200, Expires: time.Now().Add(-time.Second)})
+
+	if keys, err := store.Keys(ctx); err != nil || len(keys) != 3 {
+		t.Fatalf("Keys() = %+v, %v; want 3 unexpired entries", keys, err)
+	}
+	if purged, err := Purge(ctx, store, "aa*"); err != nil || purged != 2 {
+		t.Errorf("Purge(aa*) = %d, %v; want 2", purged, err)
+	}
+	if _, err := store.Get(ctx, "aa01"); err != ErrNotFound {
+		t.Errorf("Get(aa01) after purge error = %v, want ErrNotFound", err)
+	}
+	keys, err := store.Keys(ctx)
+	if err != nil || len(keys) != 1 || keys[0].Key != "bb01" || keys[0].Model != "claude-sonnet-4-5" {
+		t.Errorf("Keys() after purge = %+v, %v; want bb01", keys, err)
+	}
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Disk stores one JSON file per entry in a directory. Expired entries are
+// removed lazily on access.
+type Disk struct {
+	dir string
+}
+
+// Compile-time check that Disk implements Store
+var _ Store = (*Disk)(nil)
+
+// NewDisk creates the cache directory if needed.
+func NewDisk(dir string) (*Disk, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+	return &Disk{dir: dir}, nil
+}
+
+// path maps a key (hex hash) to its file.
+func (d *Disk) path(key string) string {
+	return filepath.Join(d.dir, key+".json")
+}
+
+// Get reads the entry for key.
+func (d *Disk) Get(_ context.Context, key string) (*Entry, error) {
+	data, err := os.ReadFile(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		_ = os.Remove(d.path(key))
+		return nil, ErrNotFound
+	}
+	if entry.expired(time.Now()) {
+		_ = os.Remove(d.path(key))
+		return nil, ErrNotFound
+	}
+	return &entry, nil
+}
+
+// Set writes the entry atomically (temp file + rename).
+func (d *Disk) Set(_ context.Context, key string, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(key))
+}
+
+// Delete removes the entry for key, if any.
+func (d *Disk) Delete(_ context.Context, key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Keys lists the unexpired entries, removing expired ones on the way.
+func (d *Disk) Keys(ctx context.Context) ([]Info, error) {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var infos []Info
+	for _, file := range files {
+		key, ok := strings.CutSuffix(file.Name(), ".json")
+		if !ok || file.IsDir() {
+			continue
+		}
+		entry, err := d.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue // Expired or removed concurrently
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, entry.info(key))
+	}
+	return infos, nil
+}
+
+// Close is a no-op.
+func (d *Disk) Close() error {
+	return nil
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// HeaderCache reports HIT or MISS for cacheable requests.
+const HeaderCache = "X-Claudine-Cache"
+
+// maxEntrySize bounds cached response bodies.
+const maxEntrySize = 4 << 20
+
+// Middleware serves identical non-streaming requests from store for ttl.
+// Only successful JSON responses are cached. Clients can bypass the cache with
+// "Cache-Control: no-cache" (skip lookup) or "no-store" (skip lookup and store).
+// scope, if set, names the account serving r (e.g. its tenant); requests of
+// different scopes never share entries.
+func Middleware(store Store, ttl time.Duration, scope func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if store == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+
+			var account string
+			if scope != nil {
+				account = scope(r)
+			}
+			key, ok := Key(r, account, body)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cacheControl := strings.ToLower(r.Header.Get("Cache-Control"))
+			noStore := strings.Contains(cacheControl, "no-store")
+			noCache := noStore || strings.Contains(cacheControl, "no-cache")
+
+			if !noCache {
+				entry, err := store.Get(ctx, key)
+				switch {
+				case err == nil:
+					var cached struct {
+						Model string `json:"model"`
+					}
+					_ = json.Unmarshal(entry.Body, &cached)
+					usageRec := usage.FromContext(ctx)
+					usageRec.SetModel(cached.Model)
+					usageRec.SetCacheHit()
+					for k, v := range entry.Header {
+						w.Header()[k] = v
+					}
+					w.Header().Set(HeaderCache, "HIT")
+					w.Header().Set("Content-Length", strconv.Itoa(len(entry.Body)))
+					w.WriteHeader(entry.Status)
+					_, _ = w.Write(entry.Body)
+					return
+				case !errors.Is(err, ErrNotFound):
+					slog.WarnContext(ctx, "cache lookup failed", "error", err)
+				}
+			}
+
+			w.Header().Set(HeaderCache, "MISS")
+			if noStore {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if !rec.cacheable() {
+				return
+			}
+
+			var response struct {
+				Model string `json:"model"`
+			}
+			_ = json.Unmarshal(rec.buf.Bytes(), &response)
+			entry := &Entry{
+				Status:  rec.status,
+				Header:  http.Header{"Content-Type": {w.Header().Get("Content-Type")}},
+				Body:    rec.buf.Bytes(),
+				Expires: time.Now().Add(ttl),
+				Path:    r.URL.Path,
+				KeyID:   usage.KeyID(r),
+				Model:   response.Model,
+			}
+			if err := store.Set(ctx, key, entry); err != nil {
+				slog.WarnContext(ctx, "cache store failed", "error", err)
+			}
+		})
+	}
+}
+
+// Key derives the cache key from route, scope, client key and normalized body.
+// Returns false for streaming or non-JSON requests.
+func Key(r *http.Request, scope string, body []byte) (string, bool) {
+	var payload map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return "", false
+	}
+	if stream, _ := payload["stream"].(bool); stream {
+		return "", false
+	}
+
+	// Maps are marshaled with sorted keys, normalizing field order and whitespace
+	normalized, err := json.Marshal(payload)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.URL.Path)
+	_, _ = h.Write([]byte{0})
+	_, _ = io.WriteString(h, scope)
+	_, _ = h.Write([]byte{0})
+	_, _ = io.WriteString(h, usage.KeyID(r))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// recorder tees the response to the client while keeping a copy for the cache.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.overflow {
+		if rec.buf.Len()+len(p) > maxEntrySize {
+			rec.overflow = true
+			rec.buf = bytes.Buffer{}
+		} else {
+			rec.buf.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher for streaming handlers.
+func (rec *recorder) Flush() {
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *recorder) cacheable() bool {
+	if rec.status != http.StatusOK || rec.overflow || rec.buf.Len() == 0 {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	return mediaType == "application/json"
+}
@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix namespaces cache keys in a shared Redis database.
+const redisKeyPrefix = "claudine:cache:"
+
+// Redis stores entries in Redis using GET/SET with expiry.
+// Speaks a minimal subset of RESP over a single connection, reconnecting on errors.
+type Redis struct {
+	addr     string
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// Compile-time check that Redis implements Store
+var _ Store = (*Redis)(nil)
+
+// NewRedis parses a redis:// URL (redis://[user:password@]host:port/db).
+// The connection is established lazily on first use.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported redis URL scheme %q (TLS requires a local tunnel)", u.Scheme)
+	}
+
+	r := &Redis{addr: u.Host}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return r, nil
+}
+
+// Get reads the entry for key.
+func (r *Redis) Get(ctx context.Context, key string) (*Entry, error) {
+	reply, err := r.do(ctx, "GET", redisKeyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, ErrNotFound
+	}
+	return &entry, nil
+}
+
+// Set stores the entry with the remaining TTL.
+func (r *Redis) Set(ctx context.Context, key string, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	args := []string{"SET", redisKeyPrefix + key, string(data)}
+	if !entry.Expires.IsZero() {
+		ttl := time.Until(entry.Expires).Milliseconds()
+		if ttl <= 0 {
+			return nil
+		}
+		args = append(args, "PX", strconv.FormatInt(ttl, 10))
+	}
+	_, err = r.do(ctx, args...)
+	return err
+}
+
+// Delete removes the entry for key, if any.
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", redisKeyPrefix+key)
+	return err
+}
+
+// Keys lists the entries, walking the database with SCAN.
+func (r *Redis) Keys(ctx context.Context) ([]Info, error) {
+	var infos []Info
+	cursor := "0"
+	for {
+		reply, err := r.do(ctx, "SCAN", cursor, "MATCH", redisKeyPrefix+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, fmt.Errorf("redis: unexpected SCAN reply %v", reply)
+		}
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]any)
+		for _, k := range keys {
+			name, _ := k.([]byte)
+			key := strings.TrimPrefix(string(name), redisKeyPrefix)
+			entry, err := r.Get(ctx, key)
+			if errors.Is(err, ErrNotFound) {
+				continue // Expired or removed concurrently
+			}
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, entry.info(key))
+		}
+		if cursor = string(next); cursor == "0" || cursor == "" {
+			return infos, nil
+		}
+	}
+}
+
+// Close closes the connection.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.rw = nil, nil
+	return err
+}
+
+// do sends a command and returns its reply. Connection errors drop the connection.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = r.conn.SetDeadline(deadline)
+	} else {
+		_ = r.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	reply, err := r.roundTrip(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		_ = r.conn.Close()
+		r.conn, r.rw = nil, nil
+	}
+	return reply, err
+}
+
+// connect dials Redis and authenticates. Caller must hold r.mu.
+func (r *Redis) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+	r.conn = conn
+	r.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if r.password != "" {
+		args := []string{"AUTH", r.password}
+		if r.username != "" {
+			args = []string{"AUTH", r.username, r.password}
+		}
+		if _, err := r.roundTrip(args...); err != nil {
+			_ = r.conn.Close()
+			r.conn, r.rw = nil, nil
+			return fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if r.db != 0 {
+		if _, err := r.roundTrip("SELECT", strconv.Itoa(r.db)); err != nil {
+			_ = r.conn.Close()
+			r.conn, r.rw = nil, nil
+			return fmt.Errorf("redis select: %w", err)
+		}
+	}
+	return nil
+}
+
+// roundTrip writes a RESP array command and reads one reply. Caller must hold r.mu.
+func (r *Redis) roundTrip(args ...string) (any, error) {
+	_, _ = fmt.Fprintf(r.rw, "*%d\r\n", len(args))
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(r.rw, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := r.rw.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(r.rw.Reader)
+}
+
+// redisError is an error reply sent by the server; the connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readReply parses simple strings, errors, integers, bulk strings and arrays.
+func readReply(br *bufio.Reader) (any, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(br); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply %q", line)
+	}
+}
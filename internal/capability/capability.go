@@ -0,0 +1,261 @@
+// Package capability knows what each Claude model supports (context window, output
+// tokens, extended thinking, web search, vision) and rejects requests asking a model
+// for more, with an error naming the limit instead of an opaque upstream 400.
+//
+// Models are matched by ID or alias (e.g. "claude-sonnet-4-5" for
+// "claude-sonnet-4-5-20250929"). Requests for unknown models pass unchecked, so new
+// releases work before the registry knows them.
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+)
+
+// Dialect selects the request body shape the middleware checks.
+type Dialect int
+
+const (
+	Anthropic Dialect = iota // Messages API
+	OpenAI                   // chat completions
+)
+
+// Model describes the capabilities of a model.
+type Model struct {
+	ID      string
+	Aliases []string
+
+	ContextWindow   int // Input and output tokens
+	MaxOutputTokens int
+
+	Thinking  bool // Extended thinking (reasoning_effort)
+	WebSearch bool // Server-side web search tool
+	Vision    bool // Image input
+}
+
+// Defaults returns the capabilities of the models listed by GET /v1/models.
+func Defaults() []Model {
+	return []Model{
+		{ID: "claude-opus-4-5-20251101", Aliases: []string{"claude-opus-4-5"}, ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, WebSearch: true, Vision: true},
+		{ID: "claude-haiku-4-5-20251001", Aliases: []string{"claude-haiku-4-5"}, ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, WebSearch: true, Vision: true},
+		{ID: "claude-sonnet-4-5-20250929", Aliases: []string{"claude-sonnet-4-5"}, ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, WebSearch: true, Vision: true},
+		{ID: "claude-opus-4-1-20250805", Aliases: []string{"claude-opus-4-1"}, ContextWindow: 200000, MaxOutputTokens: 32000, Thinking: true, WebSearch: true, Vision: true},
+		{ID: "claude-opus-4-20250514", Aliases: []string{"claude-opus-4-0"}, ContextWindow: 200000, MaxOutputTokens: 32000, Thinking: true, WebSearch: true, Vision: true},
+		{ID: "claude-sonnet-4-20250514", Aliases: []string{"claude-sonnet-4-0"}, ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, WebSearch: true, Vision: true},
+		{ID: "claude-3-7-sonnet-20250219", Aliases: []string{"claude-3-7-sonnet-latest"}, ContextWindow: 200000, MaxOutputTokens: 64000, Thinking: true, WebSearch: true, Vision: true},
+		{ID: "claude-3-5-haiku-20241022", Aliases: []string{"claude-3-5-haiku-latest"}, ContextWindow: 200000, MaxOutputTokens: 8192, WebSearch: true, Vision: true},
+		{ID: "claude-3-haiku-20240307", ContextWindow: 200000, MaxOutputTokens: 4096, Vision: true},
+	}
+}
+
+// Registry indexes models by ID and alias.
+type Registry struct {
+	models map[string]*Model
+}
+
+// New creates a Registry from models. Later models replace earlier ones with the
+// same ID or alias, including their aliases, so configured models can override
+// Defaults.
+func New(models []Model) (*Registry, error) {
+	r := &Registry{models: make(map[string]*Model)}
+	for i := range models {
+		m := models[i]
+		if m.ID == "" {
+			return nil, fmt.Errorf("model #%d: id required", i+1)
+		}
+		if m.ContextWindow < 0 || m.MaxOutputTokens < 0 {
+			return nil, fmt.Errorf("model %s: limits cannot be negative", m.ID)
+		}
+		if prev, ok := r.models[m.ID]; ok {
+			// Keep resolving the replaced model's aliases
+			for name, model := range r.models {
+				if model == prev {
+					r.models[name] = &m
+				}
+			}
+		}
+		for _, name := range append([]string{m.ID}, m.Aliases...) {
+			r.models[name] = &m
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns the capabilities of model, matched by ID or alias.
+func (r *Registry) Lookup(model string) (*Model, bool) {
+	m, ok := r.models[model]
+	return m, ok
+}
+
+// Middleware rejects requests asking for capabilities their model lacks via reject
+// with 400. Requests for unknown models and malformed bodies pass unchecked.
+func Middleware(registry *Registry, dialect Dialect, reject func(w http.ResponseWriter, r *http.Request, status int, message string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if registry == nil || len(registry.models) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(body, &fields) != nil {
+				// Malformed bodies are rejected by the handler
+				next.ServeHTTP(w, r)
+				return
+			}
+			var model string
+			_ = json.Unmarshal(fields["model"], &model)
+			m, ok := registry.Lookup(model)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if msg := m.check(dialect, model, fields); msg != "" {
+				reject(w, r, http.StatusBadRequest, msg)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check returns a description of the first capability the request asks model for
+// beyond m, or "" if m supports the request.
+func (m *Model) check(dialect Dialect, model string, fields map[string]json.RawMessage) string {
+	if m.MaxOutputTokens > 0 {
+		for _, field := range tokenFields(dialect) {
+			var n int
+			if json.Unmarshal(fields[field], &n) == nil && n > m.MaxOutputTokens {
+				return fmt.Sprintf("%s: %d > %d, which is the maximum allowed number of output tokens for %s", field, n, m.MaxOutputTokens, model)
+			}
+		}
+	}
+
+	if field := thinkingField(dialect, fields); field != "" && !m.Thinking {
+		return fmt.Sprintf("%s: %s does not support extended thinking", field, model)
+	}
+	if field := webSearchField(dialect, fields); field != "" && !m.WebSearch {
+		return fmt.Sprintf("%s: %s does not support web search", field, model)
+	}
+	if field := imageField(dialect, fields); field != "" && !m.Vision {
+		return fmt.Sprintf("%s: %s does not support image input", field, model)
+	}
+	return ""
+}
+
+// tokenFields lists the output token limit fields of a dialect.
+func tokenFields(dialect Dialect) []string {
+	if dialect == OpenAI {
+		return []string{"max_completion_tokens", "max_tokens"}
+	}
+	return []string{"max_tokens"}
+}
+
+// thinkingField returns the field enabling extended thinking, or "" if disabled.
+// For OpenAI, extra_body.thinking overrides reasoning_effort as in the adapter.
+func thinkingField(dialect Dialect, fields map[string]json.RawMessage) string {
+	type thinking struct {
+		Type string `json:"type"`
+	}
+
+	if dialect == Anthropic {
+		var t thinking
+		if json.Unmarshal(fields["thinking"], &t) == nil && t.Type == "enabled" {
+			return "thinking"
+		}
+		return ""
+	}
+
+	var extra struct {
+		Thinking thinking `json:"thinking"`
+	}
+	if json.Unmarshal(fields["extra_body"], &extra) == nil {
+		switch extra.Thinking.Type {
+		case "enabled":
+			return "extra_body.thinking"
+		case "disabled":
+			return ""
+		}
+	}
+	var effort string
+	if json.Unmarshal(fields["reasoning_effort"], &effort) == nil {
+		switch effort {
+		case "low", "medium", "high":
+			return "reasoning_effort"
+		}
+	}
+	return ""
+}
+
+// webSearchField returns the path of a web search tool in the request, or "".
+func webSearchField(dialect Dialect, fields map[string]json.RawMessage) string {
+	if dialect == OpenAI {
+		if raw, ok := fields["web_search_options"]; ok && string(raw) != "null" {
+			return "web_search_options"
+		}
+		return ""
+	}
+
+	var tools []struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(fields["tools"], &tools)
+	for i, tool := range tools {
+		if strings.HasPrefix(tool.Type, "web_search_") {
+			return "tools." + strconv.Itoa(i)
+		}
+	}
+	return ""
+}
+
+// imageField returns the path of the first image in the request's messages, or "".
+func imageField(dialect Dialect, fields map[string]json.RawMessage) string {
+	imageType := "image"
+	if dialect == OpenAI {
+		imageType = "image_url"
+	}
+
+	var messages []struct {
+		Content json.RawMessage `json:"content"`
+	}
+	_ = json.Unmarshal(fields["messages"], &messages)
+	for i, msg := range messages {
+		var blocks []struct {
+			Type    string          `json:"type"`
+			Content json.RawMessage `json:"content"`
+		}
+		if json.Unmarshal(msg.Content, &blocks) != nil {
+			continue
+		}
+		for j, block := range blocks {
+			path := "messages." + strconv.Itoa(i) + ".content." + strconv.Itoa(j)
+			if block.Type == imageType {
+				return path
+			}
+			// Tool results carry images as well
+			var nested []struct {
+				Type string `json:"type"`
+			}
+			if block.Type == "tool_result" && json.Unmarshal(block.Content, &nested) == nil {
+				for k, b := range nested {
+					if b.Type == imageType {
+						return path + ".content." + strconv.Itoa(k)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package capability
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		models  []Model
+		wantErr string
+	}{
+		{"no id", []Model{{MaxOutputTokens: 1}}, "id required"},
+		{"negative limit", []Model{{ID: "m", MaxOutputTokens: -1}}, "cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.models)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewOverrides(t *testing.T) {
+	registry, err := New(append(Defaults(), Model{ID: "claude-3-5-haiku-20241022", MaxOutputTokens: 4096, Thinking: true}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The alias resolves to the replacing model
+	m, ok := registry.Lookup("claude-3-5-haiku-latest")
+	if !ok || m.MaxOutputTokens != 4096 || !m.Thinking {
+		t.Errorf("Lookup(alias) = %+v, %v; want override", m, ok)
+	}
+	if _, ok := registry.Lookup("claude-unknown"); ok {
+		t.Error("Lookup() found an unknown model")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	registry, err := New(Defaults())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	const image = `{"type":"image","source":{"type":"base64","media_type":"image/png","data":""}}`
+	tests := []struct {
+		name       string
+		dialect    Dialect
+		body       string
+		wantStatus int
+		wantMsg    string
+	}{
+		{"supported", Anthropic, `{"model":"claude-sonnet-4-5","max_tokens":64000,"thinking":{"type":"enabled","budget_tokens":2048}}`, http.StatusOK, ""},
+		{"unknown model", Anthropic, `{"model":"claude-next","max_tokens":1000000,"thinking":{"type":"enabled"}}`, http.StatusOK, ""},
+		{"malformed body", Anthropic, `{"model":`, http.StatusOK, ""},
+		{"max tokens exceeded", Anthropic, `{"model":"claude-opus-4-1","max_tokens":64000}`, http.StatusBadRequest, "max_tokens: 64000 > 32000, which is the maximum allowed number of output tokens for claude-opus-4-1"},
+		{"max completion tokens exceeded", OpenAI, `{"model":"claude-3-5-haiku-latest","max_completion_tokens":10000}`, http.StatusBadRequest, "max_completion_tokens: 10000 > 8192"},
+		{"native thinking unsupported", Anthropic, `{"model":"claude-3-5-haiku-20241022","max_tokens":100,"thinking":{"type":"enabled","budget_tokens":1024}}`, http.StatusBadRequest, "thinking: claude-3-5-haiku-20241022 does not support extended thinking"},
+		{"native thinking disabled", Anthropic, `{"model":"claude-3-5-haiku-20241022","max_tokens":100,"thinking":{"type":"disabled"}}`, http.StatusOK, ""},
+		{"reasoning effort unsupported", OpenAI, `{"model":"claude-3-haiku-20240307","reasoning_effort":"low"}`, http.StatusBadRequest, "reasoning_effort: claude-3-haiku-20240307 does not support extended thinking"},
+		{"unknown reasoning effort ignored", OpenAI, `{"model":"claude-3-haiku-20240307","reasoning_effort":"none"}`, http.StatusOK, ""},
+		{"extra body disables thinking", OpenAI, `{"model":"claude-3-haiku-20240307","reasoning_effort":"high","extra_body":{"thinking":{"type":"disabled"}}}`, http.StatusOK, ""},
+		{"web search tool unsupported", Anthropic, `{"model":"claude-3-haiku-20240307","max_tokens":1,"tools":[{"name":"t","input_schema":{}},{"type":"web_search_20250305","name":"web_search"}]}`, http.StatusBadRequest, "tools.1: claude-3-haiku-20240307 does not support web search"},
+		{"web search options unsupported", OpenAI, `{"model":"claude-3-haiku-20240307","web_search_options":{}}`, http.StatusBadRequest, "web_search_options"},
+		{"image supported", Anthropic, `{"model":"claude-haiku-4-5","max_tokens":1,"messages":[{"role":"user","content":[` + image + `]}]}`, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded string
+			handler := Middleware(registry, tt.dialect, func(w http.ResponseWriter, _ *http.Request, status int, message string) {
+				http.Error(w, message, status)
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				forwarded = string(body)
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantMsg) {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantMsg)
+			}
+			if tt.wantStatus == http.StatusOK && forwarded != tt.body {
+				t.Errorf("forwarded body = %q, want unchanged", forwarded)
+			}
+		})
+	}
+}
+
+func TestMiddlewareVision(t *testing.T) {
+	registry, err := New([]Model{{ID: "text-only", MaxOutputTokens: 1000}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		body    string
+		wantMsg string
+	}{
+		{"anthropic image", Anthropic, `{"model":"text-only","messages":[{"role":"user","content":"hi"},{"role":"user","content":[{"type":"text","text":"look"},{"type":"image","source":{}}]}]}`, "messages.1.content.1: text-only does not support image input"},
+		{"tool result image", Anthropic, `{"model":"text-only","messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"t","content":[{"type":"image","source":{}}]}]}]}`, "messages.0.content.0.content.0"},
+		{"openai image", OpenAI, `{"model":"text-only","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:"}}]}]}`, "messages.0.content.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Middleware(registry, tt.dialect, func(w http.ResponseWriter, _ *http.Request, status int, message string) {
+				http.Error(w, message, status)
+			})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				t.Error("request was forwarded")
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(tt.body)))
+
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.wantMsg) {
+				t.Errorf("got %d %q, want 400 %q", rec.Code, rec.Body.String(), tt.wantMsg)
+			}
+		})
+	}
+}
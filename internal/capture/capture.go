@@ -0,0 +1,203 @@
+// Package capture stores client requests by request ID, so a request reported
+// from the access logs can be replayed (claudine replay) to reproduce an issue.
+//
+// Each request is written to its own JSON file in a directory, with credentials
+// removed. Bodies are stored verbatim and may contain prompts; files are created
+// with mode 0600 and deleted once older than the retention period.
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// pruneInterval bounds how often expired captures are deleted.
+const pruneInterval = time.Minute
+
+// droppedHeaders carry credentials and are not captured.
+var droppedHeaders = []string{"Authorization", "X-Api-Key", "Api-Key", "Cookie", "Proxy-Authorization"}
+
+// validID restricts request IDs used as file names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// ErrNotFound is returned by Load if no request was captured for an ID.
+var ErrNotFound = errors.New("request not captured")
+
+// Request is a captured client request.
+type Request struct {
+	ID     string      `json:"id"`
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	Target string      `json:"target"` // Path and query
+	Host   string      `json:"host,omitempty"`
+	Header http.Header `json:"header"`
+
+	// Key is the usage key ID of the client credential, which isn't captured.
+	Key string `json:"key,omitempty"`
+
+	// Body holds the request body: the JSON itself if valid, else a JSON string.
+	Body       json.RawMessage `json:"body,omitempty"`
+	BodyIsText bool            `json:"body_is_text,omitempty"`
+}
+
+// HTTPRequest reconstructs r as a request to the proxy.
+func (r *Request) HTTPRequest() (*http.Request, error) {
+	body := []byte(r.Body)
+	if r.BodyIsText {
+		var text string
+		if err := json.Unmarshal(r.Body, &text); err != nil {
+			return nil, fmt.Errorf("invalid captured body: %w", err)
+		}
+		body = []byte(text)
+	}
+	req, err := http.NewRequest(r.Method, r.Target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Host = r.Host
+	return req, nil
+}
+
+// Store writes captured requests to a directory. A nil *Store captures nothing.
+type Store struct {
+	dir    string
+	maxAge time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// Open creates a Store in dir, creating it with mode 0700 if needed. Captures
+// older than maxAge are deleted; zero keeps them.
+func Open(dir string, maxAge time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	return &Store{dir: dir, maxAge: maxAge}, nil
+}
+
+// Load returns the request captured in dir for id.
+func Load(dir, id string) (*Request, error) {
+	if !validID.MatchString(id) {
+		return nil, fmt.Errorf("invalid request ID %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	} else if err != nil {
+		return nil, err
+	}
+	var r Request
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid capture %s: %w", id, err)
+	}
+	return &r, nil
+}
+
+// save writes r to its file, replacing an earlier capture with the same ID.
+func (s *Store) save(r *Request) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, r.ID+".json"), data, 0o600); err != nil {
+		return err
+	}
+	s.prune(r.Time)
+	return nil
+}
+
+// prune deletes captures older than maxAge, at most once per pruneInterval.
+func (s *Store) prune(now time.Time) {
+	if s.maxAge <= 0 {
+		return
+	}
+	s.mu.Lock()
+	if now.Sub(s.lastPrune) < pruneInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > s.maxAge {
+			_ = os.Remove(filepath.Join(s.dir, entry.Name()))
+		}
+	}
+}
+
+// Middleware captures each request before next handles it. It must run after
+// the request ID is assigned. A nil Store disables capturing.
+func Middleware(s *Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if s == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := middleware.RequestIDFromContext(r.Context())
+			if !validID.MatchString(id) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			captured := &Request{
+				ID:     id,
+				Time:   time.Now().UTC(),
+				Method: r.Method,
+				Target: r.URL.RequestURI(),
+				Host:   r.Host,
+				Header: r.Header.Clone(),
+				Key:    usage.KeyID(r),
+			}
+			for _, name := range droppedHeaders {
+				captured.Header.Del(name)
+			}
+			switch {
+			case len(body) == 0:
+			case json.Valid(body):
+				captured.Body = body
+			default:
+				captured.Body, _ = json.Marshal(string(body))
+				captured.BodyIsText = true
+			}
+			if err := s.save(captured); err != nil {
+				slog.WarnContext(r.Context(), "failed to capture request", "error", err)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package capture
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+func TestCapture(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "json", body: `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`},
+		{name: "invalid json", body: `{"model":`},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := Open(dir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var handled string
+			handler := middleware.RequestIDGeneration(Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				handled = string(body)
+			})))
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages?beta=true", strings.NewReader(tt.body))
+			req.Header.Set("X-Request-ID", "req-1")
+			req.Header.Set("X-Api-Key", "vk-secret")
+			req.Header.Set("Anthropic-Beta", "tools-2024")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if handled != tt.body {
+				t.Errorf("handler read %q, want %q", handled, tt.body)
+			}
+
+			captured, err := Load(dir, "req-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if captured.Header.Get("X-Api-Key") != "" || captured.Key == "" {
+				t.Errorf("captured credential %q, key ID %q; want only the key ID", captured.Header.Get("X-Api-Key"), captured.Key)
+			}
+
+			replay, err := captured.HTTPRequest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(replay.Body)
+			if replay.Method != http.MethodPost || replay.URL.RequestURI() != "/v1/messages?beta=true" || string(body) != tt.body {
+				t.Errorf("replay = %s %s %q, want the captured request", replay.Method, replay.URL.RequestURI(), body)
+			}
+			if got := replay.Header.Get("Anthropic-Beta"); got != "tools-2024" {
+				t.Errorf("replay Anthropic-Beta = %q, want tools-2024", got)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := Load(t.TempDir(), "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load(unknown) = %v, want ErrNotFound", err)
+	}
+	if _, err := Load(t.TempDir(), "../etc/passwd"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Load(../etc/passwd) = %v, want invalid ID", err)
+	}
+}
@@ -0,0 +1,279 @@
+package compat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// pixel is a 1×1 PNG, the smallest image to describe.
+const pixel = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAIAAACQd1PeAAAADElEQVR4nGP4z8AAAAMBAQDJ/pLvAAAAAElFTkSuQmCC"
+
+// weatherTool is the function tool offered by the tool call checks.
+var weatherTool = map[string]any{
+	"type": "function",
+	"function": map[string]any{
+		"name":        "get_weather",
+		"description": "Get the current weather for a city",
+		"parameters": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"city": map[string]any{"type": "string"}},
+			"required":   []string{"city"},
+		},
+	},
+}
+
+// weatherRequest asks for a call of weatherTool.
+var weatherRequest = map[string]any{
+	"messages":    []map[string]any{{"role": "user", "content": "What is the weather in Paris?"}},
+	"tools":       []any{weatherTool},
+	"tool_choice": map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}},
+}
+
+func userMessage(content any) []map[string]any {
+	return []map[string]any{{"role": "user", "content": content}}
+}
+
+func checkChat(ctx context.Context, c *client) (string, string) {
+	resp, err := c.complete(ctx, map[string]any{
+		"messages": userMessage("Reply with the single word: pong"),
+	})
+	if err != nil {
+		return failed(err)
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content == nil || *choice.Message.Content == "" {
+		return StatusFail, "no assistant content"
+	}
+	if choice.FinishReason != "stop" {
+		return StatusDegraded, fmt.Sprintf("finish_reason = %q, want stop", choice.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens == 0 {
+		return StatusDegraded, "no usage reported"
+	}
+	return StatusPass, ""
+}
+
+func checkSystemMessages(ctx context.Context, c *client) (string, string) {
+	resp, err := c.complete(ctx, map[string]any{
+		"messages": []map[string]any{
+			{"role": "system", "content": "You only ever answer with the word: banana"},
+			{"role": "developer", "content": "Answer in lowercase."},
+			{"role": "user", "content": "What is your answer?"},
+		},
+	})
+	if err != nil {
+		return failed(err)
+	}
+	content := resp.Choices[0].Message.Content
+	if content == nil || !strings.Contains(strings.ToLower(*content), "banana") {
+		return StatusDegraded, "system message not followed"
+	}
+	return StatusPass, ""
+}
+
+func checkStreaming(ctx context.Context, c *client) (string, string) {
+	s, err := c.completeStream(ctx, map[string]any{
+		"messages":       userMessage("Count from one to five in words."),
+		"stream_options": map[string]any{"include_usage": true},
+	})
+	if err != nil {
+		return failed(err)
+	}
+	switch {
+	case s.chunks == 0 || s.content.Len() == 0:
+		return StatusFail, "no content streamed"
+	case !s.done:
+		return StatusFail, "stream not terminated with [DONE]"
+	case s.role != "assistant":
+		return StatusDegraded, fmt.Sprintf("role = %q, want assistant", s.role)
+	case s.finishReason != "stop":
+		return StatusDegraded, fmt.Sprintf("finish_reason = %q, want stop", s.finishReason)
+	case !s.usage:
+		return StatusDegraded, "no usage chunk despite stream_options.include_usage"
+	}
+	return StatusPass, ""
+}
+
+// checkToolCall judges a call of weatherTool.
+func checkToolCall(call *toolCall, finishReason string) (string, string) {
+	if call.ID == "" || call.Function.Name != "get_weather" {
+		return StatusFail, fmt.Sprintf("tool call id %q, name %q; want an ID and get_weather", call.ID, call.Function.Name)
+	}
+	var args struct {
+		City string `json:"city"`
+	}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		return StatusFail, fmt.Sprintf("arguments are not JSON: %q", truncate(call.Function.Arguments, 100))
+	}
+	if args.City == "" {
+		return StatusDegraded, "required argument city missing"
+	}
+	if finishReason != "tool_calls" {
+		return StatusDegraded, fmt.Sprintf("finish_reason = %q, want tool_calls", finishReason)
+	}
+	return StatusPass, ""
+}
+
+func checkTools(ctx context.Context, c *client) (string, string) {
+	resp, err := c.complete(ctx, weatherRequest)
+	if err != nil {
+		return failed(err)
+	}
+	choice := resp.Choices[0]
+	if len(choice.Message.ToolCalls) == 0 {
+		return StatusFail, "no tool call despite tool_choice"
+	}
+	if choice.Message.ToolCalls[0].Type != "function" {
+		return StatusFail, fmt.Sprintf("tool call type = %q, want function", choice.Message.ToolCalls[0].Type)
+	}
+	return checkToolCall(&choice.Message.ToolCalls[0], choice.FinishReason)
+}
+
+func checkStreamingTools(ctx context.Context, c *client) (string, string) {
+	s, err := c.completeStream(ctx, weatherRequest)
+	if err != nil {
+		return failed(err)
+	}
+	call, ok := s.toolCalls[0]
+	if !ok {
+		return StatusFail, "no tool call at index 0"
+	}
+	return checkToolCall(call, s.finishReason)
+}
+
+func checkImages(ctx context.Context, c *client) (string, string) {
+	resp, err := c.complete(ctx, map[string]any{
+		"messages": userMessage([]map[string]any{
+			{"type": "text", "text": "Describe this image in a few words."},
+			{"type": "image_url", "image_url": map[string]any{"url": pixel}},
+		}),
+	})
+	if err != nil {
+		return failed(err)
+	}
+	if content := resp.Choices[0].Message.Content; content == nil || *content == "" {
+		return StatusFail, "no content"
+	}
+	return StatusPass, ""
+}
+
+// decodeJSON parses content as JSON object. fenced reports whether the object was
+// only found inside a Markdown code fence, which breaks json.loads and JSON.parse.
+func decodeJSON(content *string, v any) (fenced bool, err error) {
+	if content == nil {
+		return false, errors.New("no content")
+	}
+	text := strings.TrimSpace(*content)
+	if json.Unmarshal([]byte(text), v) == nil {
+		return false, nil
+	}
+	if inner, ok := strings.CutPrefix(text, "```"); ok {
+		inner = strings.TrimPrefix(inner, "json")
+		inner = strings.TrimSuffix(strings.TrimSpace(inner), "```")
+		if json.Unmarshal([]byte(inner), v) == nil {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("content is not a JSON object: %q", truncate(text, 100))
+}
+
+func checkJSONMode(ctx context.Context, c *client) (string, string) {
+	resp, err := c.complete(ctx, map[string]any{
+		"messages":        userMessage(`Return a JSON object with the key "color" set to a color name.`),
+		"response_format": map[string]any{"type": "json_object"},
+	})
+	if err != nil {
+		return failed(err)
+	}
+	var out map[string]any
+	fenced, err := decodeJSON(resp.Choices[0].Message.Content, &out)
+	switch {
+	case err != nil:
+		return failed(err)
+	case fenced:
+		return StatusDegraded, "JSON wrapped in a code fence"
+	}
+	return StatusPass, ""
+}
+
+func checkStructuredOutputs(ctx context.Context, c *client) (string, string) {
+	resp, err := c.complete(ctx, map[string]any{
+		"messages": userMessage("What is 6 times 7?"),
+		"response_format": map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "answer",
+				"strict": true,
+				"schema": map[string]any{
+					"type":                 "object",
+					"properties":           map[string]any{"answer": map[string]any{"type": "integer"}},
+					"required":             []string{"answer"},
+					"additionalProperties": false,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return failed(err)
+	}
+	var out map[string]any
+	fenced, err := decodeJSON(resp.Choices[0].Message.Content, &out)
+	if err != nil {
+		return failed(err)
+	}
+	if _, ok := out["answer"].(float64); !ok || len(out) != 1 {
+		return StatusFail, fmt.Sprintf("content does not match the schema: %v", out)
+	}
+	if fenced {
+		return StatusDegraded, "JSON wrapped in a code fence"
+	}
+	return StatusPass, ""
+}
+
+func checkMultipleChoices(ctx context.Context, c *client) (string, string) {
+	resp, err := c.complete(ctx, map[string]any{
+		"messages": userMessage("Name a fruit."),
+		"n":        2,
+	})
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr) && httpErr.openAIError():
+		return StatusDegraded, "rejected with an OpenAI error: " + httpErr.Error()
+	case err != nil:
+		return failed(err)
+	case len(resp.Choices) != 2:
+		return StatusDegraded, fmt.Sprintf("%d choices, want 2", len(resp.Choices))
+	}
+	return StatusPass, ""
+}
+
+func checkModels(ctx context.Context, c *client) (string, string) {
+	resp, err := c.do(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return failed(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var list struct {
+		Object string `json:"object"`
+		Data   []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return failed(fmt.Errorf("malformed response: %w", err))
+	}
+	if list.Object != "list" || len(list.Data) == 0 {
+		return StatusFail, "empty model list"
+	}
+	for _, m := range list.Data {
+		if m.ID == c.model {
+			return StatusPass, ""
+		}
+	}
+	return StatusDegraded, fmt.Sprintf("model %s not listed", c.model)
+}
@@ -0,0 +1,352 @@
+// Package compat checks how well a running proxy serves OpenAI SDK clients, by
+// sending the kinds of requests OpenAI SDKs send for common features and judging
+// the responses the way those SDKs would consume them.
+//
+// Requests reach the real upstream, so each run costs a few small completions.
+package compat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Outcomes of a check.
+const (
+	// StatusPass means the feature works as OpenAI SDKs expect.
+	StatusPass = "pass"
+	// StatusDegraded means the feature works with deviations clients may notice,
+	// or is rejected cleanly with an OpenAI error.
+	StatusDegraded = "degraded"
+	// StatusFail means the feature is broken for OpenAI SDK clients.
+	StatusFail = "fail"
+)
+
+// Options configures a run.
+type Options struct {
+	// BaseURL is the OpenAI base URL of the proxy, e.g. http://localhost:4000/v1.
+	BaseURL string
+
+	// APIKey is sent as bearer token. Defaults to a placeholder, which the proxy
+	// accepts unless key policies restrict it.
+	APIKey string
+
+	// Model is requested by all chat completion checks.
+	Model string
+
+	// Timeout bounds each check. Defaults to one minute.
+	Timeout time.Duration
+
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Feature  string        `json:"feature"`
+	Status   string        `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// check exercises one feature. It returns StatusPass with an empty detail, or
+// another status with the reason.
+type check struct {
+	feature string
+	run     func(ctx context.Context, c *client) (status, detail string)
+}
+
+// checks are run in order. Features build on each other, so basic chat comes first.
+var checks = []check{
+	{"chat completion", checkChat},
+	{"system and developer messages", checkSystemMessages},
+	{"streaming", checkStreaming},
+	{"tool calls", checkTools},
+	{"streaming tool calls", checkStreamingTools},
+	{"image input", checkImages},
+	{"json mode", checkJSONMode},
+	{"structured outputs", checkStructuredOutputs},
+	{"multiple choices", checkMultipleChoices},
+	{"models", checkModels},
+}
+
+// Run performs all checks against the proxy and returns their results in order.
+// It fails only if ctx is canceled; failing checks are reported as results.
+func Run(ctx context.Context, opts Options) ([]Result, error) {
+	c := &client{
+		baseURL: strings.TrimSuffix(opts.BaseURL, "/"),
+		apiKey:  opts.APIKey,
+		model:   opts.Model,
+		http:    opts.Client,
+	}
+	if c.apiKey == "" {
+		c.apiKey = "claudine"
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+
+	results := make([]Result, 0, len(checks))
+	for _, chk := range checks {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		status, detail := chk.run(checkCtx, c)
+		cancel()
+		results = append(results, Result{
+			Feature:  chk.feature,
+			Status:   status,
+			Detail:   detail,
+			Duration: time.Since(start),
+		})
+	}
+	return results, ctx.Err()
+}
+
+// client sends requests to the proxy's OpenAI API.
+type client struct {
+	baseURL string
+	apiKey  string
+	model   string
+	http    *http.Client
+}
+
+// apiError is the OpenAI error envelope.
+type apiError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+	} `json:"error"`
+}
+
+// httpError is a response with a status other than 200.
+type httpError struct {
+	status int
+	body   []byte
+}
+
+func (e *httpError) Error() string {
+	var body apiError
+	if json.Unmarshal(e.body, &body) == nil && body.Error.Message != "" {
+		return fmt.Sprintf("HTTP %d: %s", e.status, body.Error.Message)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.status, truncate(string(e.body), 200))
+}
+
+// openAIError reports whether the response carries an OpenAI error object, as SDKs
+// need to surface the error to the caller.
+func (e *httpError) openAIError() bool {
+	var body apiError
+	return e.status >= 400 && e.status < 500 && json.Unmarshal(e.body, &body) == nil && body.Error.Message != ""
+}
+
+// do sends a request and returns the response if its status is 200.
+func (c *client) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, &httpError{status: resp.StatusCode, body: data}
+	}
+	return resp, nil
+}
+
+// completion is the part of a chat completion the checks inspect.
+type completion struct {
+	Object  string `json:"object"`
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role      string     `json:"role"`
+			Content   *string    `json:"content"`
+			ToolCalls []toolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+type toolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// complete sends a non-streaming chat completion with the given fields added.
+func (c *client) complete(ctx context.Context, fields map[string]any) (*completion, error) {
+	req := map[string]any{"model": c.model, "max_tokens": 256}
+	for k, v := range fields {
+		req[k] = v
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/chat/completions", req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var out completion
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("malformed response: %w", err)
+	}
+	if out.Object != "chat.completion" {
+		return nil, fmt.Errorf("object = %q, want chat.completion", out.Object)
+	}
+	if len(out.Choices) == 0 {
+		return nil, errors.New("response has no choices")
+	}
+	return &out, nil
+}
+
+// chunk is the part of a chat completion chunk the checks inspect.
+type chunk struct {
+	Object  string `json:"object"`
+	Choices []struct {
+		Delta struct {
+			Role      string     `json:"role"`
+			Content   *string    `json:"content"`
+			ToolCalls []toolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// stream is a streamed chat completion, assembled the way SDKs do.
+type stream struct {
+	chunks       int
+	role         string
+	content      strings.Builder
+	toolCalls    map[int]*toolCall
+	finishReason string
+	usage        bool
+	done         bool // Terminated by "data: [DONE]"
+}
+
+// completeStream sends a streaming chat completion with the given fields added.
+func (c *client) completeStream(ctx context.Context, fields map[string]any) (*stream, error) {
+	req := map[string]any{"model": c.model, "max_tokens": 256, "stream": true}
+	for k, v := range fields {
+		req[k] = v
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/chat/completions", req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		return nil, fmt.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	s := &stream{toolCalls: make(map[int]*toolCall)}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			s.done = true
+			break
+		}
+
+		var ch chunk
+		if err := json.Unmarshal([]byte(data), &ch); err != nil {
+			return nil, fmt.Errorf("malformed chunk %q: %w", truncate(data, 100), err)
+		}
+		if ch.Object != "chat.completion.chunk" {
+			// Errors after the stream started come as error objects
+			var apiErr apiError
+			if json.Unmarshal([]byte(data), &apiErr) == nil && apiErr.Error.Message != "" {
+				return nil, fmt.Errorf("stream error: %s", apiErr.Error.Message)
+			}
+			return nil, fmt.Errorf("object = %q, want chat.completion.chunk", ch.Object)
+		}
+		s.chunks++
+		if ch.Usage != nil && ch.Usage.TotalTokens > 0 {
+			s.usage = true
+		}
+		for _, choice := range ch.Choices {
+			if choice.Delta.Role != "" && s.role == "" {
+				s.role = choice.Delta.Role
+			}
+			if choice.Delta.Content != nil {
+				s.content.WriteString(*choice.Delta.Content)
+			}
+			for _, tc := range choice.Delta.ToolCalls {
+				call, ok := s.toolCalls[tc.Index]
+				if !ok {
+					call = &toolCall{Index: tc.Index}
+					s.toolCalls[tc.Index] = call
+				}
+				call.ID += tc.ID
+				call.Function.Name += tc.Function.Name
+				call.Function.Arguments += tc.Function.Arguments
+			}
+			if choice.FinishReason != nil {
+				s.finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading stream: %w", err)
+	}
+	return s, nil
+}
+
+// failed describes a request error as check result.
+func failed(err error) (string, string) {
+	return StatusFail, err.Error()
+}
+
+// truncate shortens s to at most n bytes for details.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
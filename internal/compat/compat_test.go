@@ -0,0 +1,118 @@
+package compat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeProxy answers like the proxy would, except for json mode (fenced) and n > 1
+// (rejected), to cover every outcome.
+func fakeProxy(t *testing.T) *httptest.Server {
+	const model = "claude-haiku-4-5"
+	writeCompletion := func(w http.ResponseWriter, message string, finishReason string) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"object":"chat.completion","model":%q,"choices":[{"index":0,"message":%s,"finish_reason":%q}],`+
+			`"usage":{"prompt_tokens":5,"completion_tokens":5,"total_tokens":10}}`, model, message, finishReason)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"object":"list","data":[{"id":%q,"object":"model"}]}`, model)
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream         bool              `json:"stream"`
+			N              int               `json:"n"`
+			Tools          []any             `json:"tools"`
+			ResponseFormat map[string]any    `json:"response_format"`
+			Messages       []json.RawMessage `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("malformed request: %v", err)
+		}
+
+		switch {
+		case req.N > 1:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"n > 1 is not supported","type":"invalid_request_error","code":null}}`))
+		case req.Stream:
+			w.Header().Set("Content-Type", "text/event-stream")
+			chunk := func(delta, finishReason string) {
+				_, _ = fmt.Fprintf(w, "data: {\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":%s,\"finish_reason\":%s}]}\n\n", delta, finishReason)
+			}
+			chunk(`{"role":"assistant","content":""}`, "null")
+			if req.Tools != nil {
+				chunk(`{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}`, "null")
+				chunk(`{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}`, "null")
+				chunk(`{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}`, `"tool_calls"`)
+			} else {
+				chunk(`{"content":"one two three four five"}`, `"stop"`)
+			}
+			_, _ = fmt.Fprint(w, "data: {\"object\":\"chat.completion.chunk\",\"choices\":[],\"usage\":{\"total_tokens\":10}}\n\n")
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		case req.Tools != nil:
+			writeCompletion(w, `{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}`, "tool_calls")
+		case req.ResponseFormat["type"] == "json_object":
+			writeCompletion(w, `{"role":"assistant","content":"`+"```json\\n{\\\"color\\\":\\\"red\\\"}\\n```"+`"}`, "stop")
+		case req.ResponseFormat["type"] == "json_schema":
+			writeCompletion(w, `{"role":"assistant","content":"{\"answer\":42}"}`, "stop")
+		case len(req.Messages) == 3:
+			writeCompletion(w, `{"role":"assistant","content":"banana"}`, "stop")
+		default:
+			writeCompletion(w, `{"role":"assistant","content":"pong"}`, "stop")
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRun(t *testing.T) {
+	server := fakeProxy(t)
+
+	results, err := Run(context.Background(), Options{BaseURL: server.URL + "/v1/", Model: "claude-haiku-4-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"chat completion":               StatusPass,
+		"system and developer messages": StatusPass,
+		"streaming":                     StatusPass,
+		"tool calls":                    StatusPass,
+		"streaming tool calls":          StatusPass,
+		"image input":                   StatusPass,
+		"json mode":                     StatusDegraded,
+		"structured outputs":            StatusPass,
+		"multiple choices":              StatusDegraded,
+		"models":                        StatusPass,
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for _, r := range results {
+		if r.Status != want[r.Feature] {
+			t.Errorf("%s: status %s (%s), want %s", r.Feature, r.Status, r.Detail, want[r.Feature])
+		}
+	}
+}
+
+func TestRunUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	results, err := Run(context.Background(), Options{BaseURL: server.URL + "/v1", Model: "claude-haiku-4-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Status != StatusFail || r.Detail == "" {
+			t.Errorf("%s: status %s (%s), want fail with detail", r.Feature, r.Status, r.Detail)
+		}
+	}
+}
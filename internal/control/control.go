@@ -0,0 +1,332 @@
+// Package control serves a local control socket for a running proxy and provides
+// the client used by the status, stop, reload, upgrade and cache commands.
+//
+// The protocol is HTTP/1.1 over a Unix domain socket, which keeps it scriptable
+// (e.g., curl --unix-socket) and restricts access to the socket's owner.
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/cache"
+)
+
+// Status describes a running instance.
+type Status struct {
+	PID           int               `json:"pid"`
+	Version       string            `json:"version"`
+	Address       string            `json:"address"`
+	StartedAt     time.Time         `json:"started_at"`
+	Uptime        string            `json:"uptime"`
+	ActiveStreams int64             `json:"active_streams"`
+	TokenExpiry   *time.Time        `json:"token_expiry,omitempty"`
+	Degraded      map[string]string `json:"degraded,omitempty"`
+	LastReload    *time.Time        `json:"last_reload,omitempty"`
+	LogLevel      string            `json:"log_level"`
+}
+
+// Controller is the running instance controlled through the socket.
+type Controller interface {
+	// Status reports the current state.
+	Status() Status
+
+	// Stop triggers graceful shutdown and returns immediately.
+	Stop()
+
+	// Reload re-reads the configuration and restarts the proxy with it.
+	// Returns an error and keeps the current configuration if it is invalid.
+	Reload(ctx context.Context) error
+
+	// SetLogLevel changes the global log level until the next change or restart.
+	SetLogLevel(level slog.Level)
+
+	// Upgrade starts a new process of the current executable with the listener of
+	// this one and stops this one once the new process is ready.
+	Upgrade(ctx context.Context) error
+
+	// CacheStats reports entries and hit rate of the response cache.
+	CacheStats(ctx context.Context) (cache.Stats, error)
+
+	// CacheKeys lists the entries of the response cache.
+	CacheKeys(ctx context.Context) ([]cache.Info, error)
+
+	// PurgeCache deletes the response cache entries whose key matches pattern, or
+	// all entries if pattern is empty, and returns the number deleted.
+	PurgeCache(ctx context.Context, pattern string) (int, error)
+}
+
+// logLevelRequest changes the log level via the control socket.
+type logLevelRequest struct {
+	Level slog.Level `json:"level"`
+}
+
+// purgeResponse reports the entries deleted from the response cache.
+type purgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// errorResponse is returned by the control socket on failure.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server serves the control socket.
+type Server struct {
+	path   string
+	server *http.Server
+}
+
+// Listen creates the control socket at path and serves c in the background.
+// A stale socket left by a crashed instance is replaced; a live one is an error.
+func Listen(path string, c Controller) (*Server, error) {
+	if _, err := NewClient(path).Status(context.Background()); err == nil {
+		return nil, fmt.Errorf("another instance is listening on %s", path)
+	}
+	return listen(path, c)
+}
+
+// Takeover replaces the control socket at path, even if a live instance serves it.
+// Used by a process taking over from the previous one during an upgrade; the
+// previous instance leaves the replaced socket in place when it closes.
+func Takeover(path string, c Controller) (*Server, error) {
+	return listen(path, c)
+}
+
+func listen(path string, c Controller) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to restrict control socket: %w", err)
+	}
+	// Close decides whether to remove the socket, it may belong to a newer instance
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	s := &Server{
+		path: path,
+		server: &http.Server{
+			Handler:     handler(c),
+			ReadTimeout: 10 * time.Second,
+		},
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("control socket failed", "error", err)
+		}
+	}()
+	return s, nil
+}
+
+// Close stops serving and removes the socket unless another instance took it over.
+func (s *Server) Close(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	// This server no longer answers, so any answer comes from the new owner
+	if _, statusErr := NewClient(s.path).Status(ctx); statusErr == nil {
+		return err
+	}
+	if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+	return err
+}
+
+func handler(c Controller) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.Status(), http.StatusOK)
+	})
+	mux.HandleFunc("POST /stop", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		c.Stop()
+	})
+	mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Reload(r.Context()); err != nil {
+			writeJSON(w, errorResponse{Error: err.Error()}, http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("PUT /log-level", func(w http.ResponseWriter, r *http.Request) {
+		var req logLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, errorResponse{Error: fmt.Sprintf("invalid log level: %v", err)}, http.StatusBadRequest)
+			return
+		}
+		c.SetLogLevel(req.Level)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /upgrade", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.Upgrade(r.Context()); err != nil {
+			writeJSON(w, errorResponse{Error: err.Error()}, http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /cache", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := c.CacheStats(r.Context())
+		if err != nil {
+			writeJSON(w, errorResponse{Error: err.Error()}, http.StatusUnprocessableEntity)
+			return
+		}
+		writeJSON(w, stats, http.StatusOK)
+	})
+	mux.HandleFunc("GET /cache/keys", func(w http.ResponseWriter, r *http.Request) {
+		keys, err := c.CacheKeys(r.Context())
+		if err != nil {
+			writeJSON(w, errorResponse{Error: err.Error()}, http.StatusUnprocessableEntity)
+			return
+		}
+		if keys == nil {
+			keys = []cache.Info{}
+		}
+		writeJSON(w, keys, http.StatusOK)
+	})
+	mux.HandleFunc("DELETE /cache/keys", func(w http.ResponseWriter, r *http.Request) {
+		purged, err := c.PurgeCache(r.Context(), r.URL.Query().Get("pattern"))
+		if err != nil {
+			writeJSON(w, errorResponse{Error: err.Error()}, http.StatusUnprocessableEntity)
+			return
+		}
+		writeJSON(w, purgeResponse{Purged: purged}, http.StatusOK)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Client talks to the control socket of a running instance.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client for the control socket at path.
+func NewClient(path string) *Client {
+	return &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Status returns the status of the running instance.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Stop triggers graceful shutdown of the running instance.
+func (c *Client) Stop(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/stop", nil, nil)
+}
+
+// Reload makes the running instance reload its configuration.
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/reload", nil, nil)
+}
+
+// SetLogLevel changes the log level of the running instance.
+func (c *Client) SetLogLevel(ctx context.Context, level slog.Level) error {
+	return c.do(ctx, http.MethodPut, "/log-level", logLevelRequest{Level: level}, nil)
+}
+
+// Upgrade makes the running instance hand over to a new process of its executable.
+func (c *Client) Upgrade(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/upgrade", nil, nil)
+}
+
+// CacheStats returns entries and hit rate of the response cache of the running instance.
+func (c *Client) CacheStats(ctx context.Context) (*cache.Stats, error) {
+	var stats cache.Stats
+	if err := c.do(ctx, http.MethodGet, "/cache", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// CacheKeys lists the entries of the response cache of the running instance.
+func (c *Client) CacheKeys(ctx context.Context) ([]cache.Info, error) {
+	var keys []cache.Info
+	if err := c.do(ctx, http.MethodGet, "/cache/keys", nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// PurgeCache deletes the response cache entries of the running instance whose key
+// matches pattern, or all entries if pattern is empty.
+func (c *Client) PurgeCache(ctx context.Context, pattern string) (int, error) {
+	var resp purgeResponse
+	if err := c.do(ctx, http.MethodDelete, "/cache/keys?pattern="+url.QueryEscape(pattern), nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Purged, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	// The host is ignored; requests are dialed to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://claudine"+path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("no running instance reachable: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 300 {
+		var errResp errorResponse
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return errors.New(errResp.Error)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
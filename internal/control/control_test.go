@@ -0,0 +1,142 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/cache"
+)
+
+type fakeController struct {
+	stopped    bool
+	reloadErr  error
+	logLevel   slog.Level
+	upgradeErr error
+	cache      *cache.Counting
+}
+
+func (f *fakeController) Status() Status { return Status{PID: 42, ActiveStreams: 3} }
+func (f *fakeController) Stop()          { f.stopped = true }
+func (f *fakeController) Reload(context.Context) error {
+	return f.reloadErr
+}
+func (f *fakeController) SetLogLevel(level slog.Level) { f.logLevel = level }
+func (f *fakeController) Upgrade(context.Context) error {
+	return f.upgradeErr
+}
+
+func (f *fakeController) CacheStats(ctx context.Context) (cache.Stats, error) {
+	return f.cache.Stats(ctx)
+}
+func (f *fakeController) CacheKeys(ctx context.Context) ([]cache.Info, error) {
+	return f.cache.Keys(ctx)
+}
+func (f *fakeController) PurgeCache(ctx context.Context, pattern string) (int, error) {
+	return cache.Purge(ctx, f.cache, pattern)
+}
+
+func TestControlSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "claudine.sock")
+	controller := &fakeController{cache: cache.NewCounting(cache.NewMemory(10))}
+
+	server, err := Listen(path, controller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close(context.Background()) })
+
+	if _, err := Listen(path, controller); err == nil {
+		t.Error("second Listen() on a live socket succeeded")
+	}
+
+	client := NewClient(path)
+	ctx := t.Context()
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PID != 42 || status.ActiveStreams != 3 {
+		t.Errorf("Status() = %+v", status)
+	}
+
+	if err := client.Reload(ctx); err != nil {
+		t.Errorf("Reload() error = %v", err)
+	}
+	controller.reloadErr = errors.New("invalid config")
+	if err := client.Reload(ctx); err == nil || err.Error() != "invalid config" {
+		t.Errorf("Reload() error = %v, want invalid config", err)
+	}
+
+	if err := client.SetLogLevel(ctx, slog.LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	if controller.logLevel != slog.LevelDebug {
+		t.Errorf("log level = %v, want DEBUG", controller.logLevel)
+	}
+
+	if err := client.Upgrade(ctx); err != nil {
+		t.Errorf("Upgrade() error = %v", err)
+	}
+	controller.upgradeErr = errors.New("handover unsupported")
+	if err := client.Upgrade(ctx); err == nil || err.Error() != "handover unsupported" {
+		t.Errorf("Upgrade() error = %v, want handover unsupported", err)
+	}
+
+	for _, key := range []string{"aa01", "aa02", "bb01"} {
+		_ = controller.cache.Set(ctx, key, &cache.Entry{Status: 200, Body: []byte("{}"), Path: "/v1/messages"})
+	}
+	_, _ = controller.cache.Get(ctx, "aa01")
+	_, _ = controller.cache.Get(ctx, "cc01")
+	stats, err := client.CacheStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 3 || stats.Size != 6 || stats.Hits != 1 || stats.Misses != 1 || stats.HitRate != 0.5 {
+		t.Errorf("CacheStats() = %+v", stats)
+	}
+	if purged, err := client.PurgeCache(ctx, "aa*"); err != nil || purged != 2 {
+		t.Errorf("PurgeCache(aa*) = %d, %v; want 2", purged, err)
+	}
+	if keys, err := client.CacheKeys(ctx); err != nil || len(keys) != 1 || keys[0].Key != "bb01" || keys[0].Path != "/v1/messages" {
+		t.Errorf("CacheKeys() = %+v, %v; want bb01", keys, err)
+	}
+	if _, err := client.PurgeCache(ctx, "["); err == nil {
+		t.Error("PurgeCache([) succeeded, want invalid pattern error")
+	}
+	if purged, err := client.PurgeCache(ctx, ""); err != nil || purged != 1 {
+		t.Errorf("PurgeCache() = %d, %v; want 1", purged, err)
+	}
+
+	if err := client.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !controller.stopped {
+		t.Error("Stop() did not reach the controller")
+	}
+}
+
+func TestControlSocketTakeover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "claudine.sock")
+
+	previous, err := Listen(path, &fakeController{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := Takeover(path, &fakeController{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = next.Close(context.Background()) })
+
+	// Closing the previous server must leave the new socket in place
+	if err := previous.Close(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(path).Status(t.Context()); err != nil {
+		t.Errorf("Status() after takeover error = %v", err)
+	}
+}
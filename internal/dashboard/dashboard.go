@@ -0,0 +1,189 @@
+// Package dashboard serves a small read-only web dashboard: request rate, token
+// usage by model and key, upstream rate limit state and token expiry.
+//
+// Dashboard aggregates completion events in memory (it is a usage.Sink), so
+// figures cover the time since the process started. For history, use /metrics
+// with a time series database or persistent storage.
+package dashboard
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+//go:embed dashboard.html
+var page []byte
+
+// rateWindow is the number of per-minute buckets kept for the request rate chart.
+const rateWindow = 60
+
+// Totals accumulates requests and tokens.
+type Totals struct {
+	Requests     int64 `json:"requests"`
+	Errors       int64 `json:"errors"`
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	CachedTokens int64 `json:"cached_tokens"`
+}
+
+func (t *Totals) add(e usage.Event) {
+	t.Requests++
+	if e.Status >= http.StatusBadRequest {
+		t.Errors++
+	}
+	t.InputTokens += e.Usage.InputTokens
+	t.OutputTokens += e.Usage.OutputTokens
+	t.CachedTokens += e.Usage.CacheReadInputTokens
+}
+
+// Status is point-in-time proxy state shown next to the aggregates.
+type Status struct {
+	ActiveStreams       int64             `json:"active_streams"`
+	TokenExpiry         time.Time         `json:"token_expiry,omitzero"`
+	RateLimits          map[string]string `json:"rate_limits,omitempty"`
+	RateLimitsUpdatedAt time.Time         `json:"rate_limits_updated_at,omitzero"`
+}
+
+// Snapshot is the dashboard data served as JSON.
+type Snapshot struct {
+	Now     time.Time         `json:"now"`
+	Started time.Time         `json:"started"`
+	Totals  Totals            `json:"totals"`
+	Models  map[string]Totals `json:"models"`
+	Keys    map[string]Totals `json:"keys"`
+
+	// RequestsPerMinute holds the request counts of the last 60 minutes, oldest first.
+	RequestsPerMinute []int64 `json:"requests_per_minute"`
+
+	Status Status `json:"status"`
+}
+
+// Dashboard aggregates usage events for display.
+type Dashboard struct {
+	// TokenExpiry reports when the current access token expires. Optional.
+	TokenExpiry func() time.Time
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	started time.Time
+	totals  Totals
+	models  map[string]*Totals
+	keys    map[string]*Totals
+	minutes [rateWindow]int64
+	last    int64 // unix minute of the newest bucket
+}
+
+// Compile-time check that Dashboard implements usage.Sink
+var _ usage.Sink = (*Dashboard)(nil)
+
+// New creates an empty Dashboard.
+func New() *Dashboard {
+	d := &Dashboard{
+		now:    time.Now,
+		models: make(map[string]*Totals),
+		keys:   make(map[string]*Totals),
+	}
+	d.started = d.now()
+	d.last = d.started.Unix() / 60
+	return d
+}
+
+// Consume implements usage.Sink.
+func (d *Dashboard) Consume(_ context.Context, e usage.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.advance(d.now())
+	d.minutes[d.last%rateWindow]++
+	d.totals.add(e)
+
+	model := e.Model
+	if model == "" {
+		model = "unknown"
+	}
+	if d.models[model] == nil {
+		d.models[model] = &Totals{}
+	}
+	d.models[model].add(e)
+
+	key := e.Key
+	if key == "" {
+		key = "anonymous"
+	}
+	if d.keys[key] == nil {
+		d.keys[key] = &Totals{}
+	}
+	d.keys[key].add(e)
+}
+
+// advance clears buckets of minutes without requests up to now. Callers hold mu.
+func (d *Dashboard) advance(now time.Time) {
+	minute := now.Unix() / 60
+	for m := d.last + 1; m <= minute && m <= d.last+rateWindow; m++ {
+		d.minutes[m%rateWindow] = 0
+	}
+	if minute > d.last {
+		d.last = minute
+	}
+}
+
+// Snapshot returns the current aggregates.
+func (d *Dashboard) Snapshot() Snapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.advance(now)
+
+	s := Snapshot{
+		Now:               now.UTC(),
+		Started:           d.started.UTC(),
+		Totals:            d.totals,
+		Models:            make(map[string]Totals, len(d.models)),
+		Keys:              make(map[string]Totals, len(d.keys)),
+		RequestsPerMinute: make([]int64, rateWindow),
+	}
+	for name, t := range d.models {
+		s.Models[name] = *t
+	}
+	for name, t := range d.keys {
+		s.Keys[name] = *t
+	}
+	for i := range rateWindow {
+		s.RequestsPerMinute[i] = d.minutes[(d.last+1+int64(i))%rateWindow]
+	}
+	return s
+}
+
+// Handler serves the dashboard page at its root and the data at data.json.
+// status is called per data request for live proxy state. The handler must be
+// protected by admin authentication; it exposes key IDs and account limits.
+func Handler(d *Dashboard, status func() Status) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dashboard", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+		_, _ = w.Write(page)
+	})
+	mux.HandleFunc("GET /dashboard/data.json", func(w http.ResponseWriter, _ *http.Request) {
+		snapshot := d.Snapshot()
+		if status != nil {
+			snapshot.Status = status()
+		}
+		if d.TokenExpiry != nil {
+			snapshot.Status.TokenExpiry = d.TokenExpiry().UTC()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(snapshot)
+	})
+	return mux
+}
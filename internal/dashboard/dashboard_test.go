@@ -0,0 +1,83 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+func TestSnapshot(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+	d := New()
+	d.now = func() time.Time { return now }
+	d.started = now
+	d.last = now.Unix() / 60
+
+	ctx := context.Background()
+	d.Consume(ctx, usage.Event{Model: "claude-sonnet-4-5", Key: "key_a", Status: 200, Usage: usage.Tokens{InputTokens: 10, OutputTokens: 5}})
+	d.Consume(ctx, usage.Event{Model: "claude-sonnet-4-5", Status: 529})
+	now = now.Add(2 * time.Minute)
+	d.Consume(ctx, usage.Event{Model: "claude-opus-4-1", Key: "key_a", Status: 200, Usage: usage.Tokens{InputTokens: 1, OutputTokens: 1}})
+
+	s := d.Snapshot()
+	if s.Totals != (Totals{Requests: 3, Errors: 1, InputTokens: 11, OutputTokens: 6}) {
+		t.Errorf("Totals = %+v", s.Totals)
+	}
+	if got := s.Models["claude-sonnet-4-5"]; got.Requests != 2 || got.Errors != 1 {
+		t.Errorf("Models[sonnet] = %+v", got)
+	}
+	if got := s.Keys["key_a"]; got.Requests != 2 || got.InputTokens != 11 {
+		t.Errorf("Keys[key_a] = %+v", got)
+	}
+	if got := s.Keys["anonymous"]; got.Requests != 1 {
+		t.Errorf("Keys[anonymous] = %+v", got)
+	}
+
+	rpm := s.RequestsPerMinute
+	if len(rpm) != rateWindow || rpm[rateWindow-1] != 1 || rpm[rateWindow-2] != 0 || rpm[rateWindow-3] != 2 {
+		t.Errorf("RequestsPerMinute tail = %v", rpm[rateWindow-3:])
+	}
+
+	// Buckets older than the window are cleared
+	now = now.Add(2 * time.Hour)
+	for _, n := range d.Snapshot().RequestsPerMinute {
+		if n != 0 {
+			t.Fatalf("RequestsPerMinute after idle hour = %v", d.Snapshot().RequestsPerMinute)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	expiry := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+	d := New()
+	d.TokenExpiry = func() time.Time { return expiry }
+	d.Consume(context.Background(), usage.Event{Model: "claude-sonnet-4-5", Status: 200})
+	h := Handler(d, func() Status {
+		return Status{ActiveStreams: 2, RateLimits: map[string]string{"requests-remaining": "49"}}
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard/data.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Totals.Requests != 1 || s.Status.ActiveStreams != 2 || !s.Status.TokenExpiry.Equal(expiry) ||
+		s.Status.RateLimits["requests-remaining"] != "49" {
+		t.Errorf("snapshot = %+v", s)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusOK || ct != "text/html; charset=utf-8" {
+		t.Errorf("page status = %d, Content-Type = %q", rec.Code, ct)
+	}
+}
@@ -0,0 +1,267 @@
+// Package grpcapi serves OpenAI chat completions over gRPC, for internal services
+// that prefer protobuf to SSE over HTTP.
+//
+// The ChatCompletion service (see chat_completion.proto) calls the proxy's chat
+// completions route in-process, so requests pass the same middleware as HTTP
+// requests: client keys, usage tracking, routing, plugins and the response cache.
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/florianilch/claudine-proxy/internal/inproc"
+)
+
+// ServiceName is the fully qualified name of the ChatCompletion service.
+const ServiceName = "claudine.v1.ChatCompletion"
+
+// chatCompletionServer is the HandlerType of the service, implemented by Server.
+type chatCompletionServer interface {
+	create(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	createStream(req *structpb.Struct, stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*chatCompletionServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Create",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := &structpb.Struct{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			return srv.(chatCompletionServer).create(ctx, req)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "CreateStream",
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			req := &structpb.Struct{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return srv.(chatCompletionServer).createStream(req, stream)
+		},
+	}},
+	Metadata: "chat_completion.proto",
+}
+
+// Server is a gRPC server exposing the ChatCompletion service.
+type Server struct {
+	handler http.Handler
+	path    string
+	server  *grpc.Server
+}
+
+// New creates a server answering chat completions with handler, which serves the
+// OpenAI chat completions route at path (e.g., "/v1/chat/completions").
+func New(handler http.Handler, path string) *Server {
+	s := &Server{handler: handler, path: path, server: grpc.NewServer()}
+	s.server.RegisterService(&serviceDesc, s)
+	return s
+}
+
+// Start starts the gRPC server in the background and returns immediately.
+// Returns a channel for runtime errors and a startup error if any.
+func (s *Server) Start(address string) (<-chan error, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return s.Serve(listener), nil
+}
+
+// Serve starts the gRPC server on an existing listener in the background. See Start.
+func (s *Server) Serve(listener net.Listener) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+	return errCh
+}
+
+// Shutdown stops accepting calls and waits for in-flight calls, including streams,
+// until ctx ends, then cancels the remaining ones.
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return fmt.Errorf("graceful shutdown failed: %w", ctx.Err())
+	}
+}
+
+func (s *Server) create(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	httpReq, err := s.request(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	w := inproc.NewResponseWriter()
+	s.handler.ServeHTTP(w, httpReq)
+	if w.Status() != http.StatusOK {
+		return nil, statusError(w.Status(), w.Body())
+	}
+
+	resp := &structpb.Struct{}
+	if err := protojson.Unmarshal(w.Body(), resp); err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid chat completion response: %v", err)
+	}
+	return resp, nil
+}
+
+func (s *Server) createStream(req *structpb.Struct, stream grpc.ServerStream) error {
+	httpReq, err := s.request(stream.Context(), req, true)
+	if err != nil {
+		return err
+	}
+
+	w := inproc.NewEventWriter(func(event, data string) error {
+		switch {
+		case data == "[DONE]":
+			return nil
+		case event == "error":
+			return streamError([]byte(data))
+		}
+		chunk := &structpb.Struct{}
+		if err := protojson.Unmarshal([]byte(data), chunk); err != nil {
+			return status.Errorf(codes.Internal, "invalid chat completion chunk: %v", err)
+		}
+		return stream.SendMsg(chunk)
+	})
+	s.handler.ServeHTTP(w, httpReq)
+	if w.Status() != http.StatusOK {
+		return statusError(w.Status(), w.Body())
+	}
+	return w.Finish()
+}
+
+// request builds the HTTP request for a call, forwarding incoming metadata as headers.
+func (s *Server) request(ctx context.Context, req *structpb.Struct, stream bool) (*http.Request, error) {
+	fields := req.AsMap()
+	fields["stream"] = stream
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.path, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to build request: %v", err)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		// Pseudo-headers and gRPC transport headers don't apply to the HTTP request
+		if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") || key == "content-type" || key == "te" {
+			continue
+		}
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Del("Accept") // streams are read as SSE
+	if p, ok := peer.FromContext(ctx); ok {
+		httpReq.RemoteAddr = p.Addr.String()
+	}
+	return httpReq, nil
+}
+
+// openAIError is the OpenAI error envelope.
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// statusError converts an HTTP error response to a gRPC status.
+func statusError(httpStatus int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+	var e openAIError
+	if json.Unmarshal(body, &e) == nil && e.Error.Message != "" {
+		message = e.Error.Message
+	}
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+	return status.Error(httpCode(httpStatus), message)
+}
+
+// streamError converts an OpenAI error event received mid-stream to a gRPC status.
+func streamError(data []byte) error {
+	var e openAIError
+	if err := json.Unmarshal(data, &e); err != nil || e.Error.Message == "" {
+		return status.Errorf(codes.Unknown, "stream failed: %s", bytes.TrimSpace(data))
+	}
+	return status.Error(typeCode(e.Error.Type), e.Error.Message)
+}
+
+// httpCode maps an HTTP status to a gRPC code.
+func httpCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable, 529: // 529: Anthropic overloaded
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// typeCode maps an OpenAI error type, as produced by the adapter, to a gRPC code.
+func typeCode(errorType string) codes.Code {
+	switch errorType {
+	case "invalid_request_error":
+		return codes.InvalidArgument
+	case "authentication_error":
+		return codes.Unauthenticated
+	case "permission_denied":
+		return codes.PermissionDenied
+	case "rate_limit_error":
+		return codes.ResourceExhausted
+	case "server_error":
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,152 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeChatCompletions answers like the proxy's chat completions route, depending
+// on the requested model.
+func fakeChatCompletions(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		if r.Header.Get("Authorization") != "Bearer sk-ant-client" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+
+		switch req.Model {
+		case "rate-limited":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"error":{"message":"slow down","type":"rate_limit_error"}}`)
+		case "broken-stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "data: {\"id\":\"1\"}\n\n")
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, "event: error\ndata: {\"error\":{\"message\":\"overloaded\",\"type\":\"server_error\"}}\n\n")
+			w.(http.Flusher).Flush()
+		default:
+			if !req.Stream {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion"}`)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range []string{`{"id":"1"}`, `{"id":"2"}`, "[DONE]"} {
+				_, _ = io.WriteString(w, "data: "+chunk+"\n\n")
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := New(fakeChatCompletions(t), "/v1/chat/completions")
+	server.Serve(listener)
+	t.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	tests := []struct {
+		name       string
+		model      string
+		stream     bool
+		wantIDs    []string
+		wantCode   codes.Code
+		wantErrMsg string
+	}{
+		{name: "create", model: "claude-sonnet-4-5", wantIDs: []string{"chatcmpl-1"}},
+		{name: "create error", model: "rate-limited", wantCode: codes.ResourceExhausted, wantErrMsg: "slow down"},
+		{name: "stream", model: "claude-sonnet-4-5", stream: true, wantIDs: []string{"1", "2"}},
+		{name: "stream error status", model: "rate-limited", stream: true, wantCode: codes.ResourceExhausted, wantErrMsg: "slow down"},
+		{name: "stream error event", model: "broken-stream", stream: true, wantIDs: []string{"1"}, wantCode: codes.Unavailable, wantErrMsg: "overloaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer sk-ant-client")
+			req, err := structpb.NewStruct(map[string]any{
+				"model":    tt.model,
+				"messages": []any{map[string]any{"role": "user", "content": "hi"}},
+				"stream":   !tt.stream, // overridden by the method
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []string
+			var callErr error
+			if tt.stream {
+				stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/CreateStream")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := stream.SendMsg(req); err != nil {
+					t.Fatal(err)
+				}
+				if err := stream.CloseSend(); err != nil {
+					t.Fatal(err)
+				}
+				for {
+					chunk := &structpb.Struct{}
+					if err := stream.RecvMsg(chunk); err != nil {
+						if err != io.EOF {
+							callErr = err
+						}
+						break
+					}
+					ids = append(ids, chunk.Fields["id"].GetStringValue())
+				}
+			} else {
+				resp := &structpb.Struct{}
+				callErr = conn.Invoke(ctx, "/"+ServiceName+"/Create", req, resp)
+				if callErr == nil {
+					ids = append(ids, resp.Fields["id"].GetStringValue())
+				}
+			}
+
+			if got := status.Code(callErr); got != tt.wantCode {
+				t.Fatalf("code = %v, want %v (%v)", got, tt.wantCode, callErr)
+			}
+			if tt.wantErrMsg != "" && status.Convert(callErr).Message() != tt.wantErrMsg {
+				t.Errorf("message = %q, want %q", status.Convert(callErr).Message(), tt.wantErrMsg)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package handover passes the proxy's listening socket to a new process, so a
+// binary upgrade neither refuses connections nor interrupts in-flight requests.
+//
+// The old process starts the new one with the first listener as file descriptor 3,
+// the write end of a pipe as descriptor 4 and further listeners from descriptor 5
+// on. The new process serves on the inherited listeners and writes to the pipe once
+// ready; the old process then stops accepting and drains its connections while the
+// new one takes new connections.
+package handover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// handoverEnv marks a process started by Start and holds the number of inherited
+// listeners. It lacks the CLAUDINE_ prefix so it doesn't end up in the config.
+const handoverEnv = "_CLAUDINE_HANDOVER"
+
+// File descriptors of the first inherited listener and the readiness pipe
+// (ExtraFiles start at 3). Further listeners follow the pipe, which keeps the
+// layout of versions passing a single listener.
+const (
+	listenerFD = 3
+	readyFD    = 4
+)
+
+// Process is a new process started with the listeners of the current one.
+type Process struct {
+	cmd   *exec.Cmd
+	ready *os.File // read end of the readiness pipe
+}
+
+// Pid returns the process ID of the new process.
+func (p *Process) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// WaitReady blocks until the new process reported ready. If it exits first or
+// ctx ends, the new process is killed and an error returned; the current process
+// keeps serving.
+func (p *Process) WaitReady(ctx context.Context) error {
+	defer func() { _ = p.ready.Close() }()
+
+	readyCh := make(chan error, 1)
+	go func() {
+		// A single byte means ready; EOF means the pipe closed without it
+		_, err := p.ready.Read(make([]byte, 1))
+		if errors.Is(err, io.EOF) {
+			err = errors.New("exited before becoming ready")
+		}
+		readyCh <- err
+	}()
+
+	select {
+	case err := <-readyCh:
+		if err == nil {
+			return nil
+		}
+		_ = p.cmd.Process.Kill()
+		return fmt.Errorf("new process %d: %w", p.Pid(), err)
+	case <-ctx.Done():
+		_ = p.cmd.Process.Kill()
+		return fmt.Errorf("new process %d not ready: %w", p.Pid(), context.Cause(ctx))
+	}
+}
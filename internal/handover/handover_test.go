@@ -0,0 +1,106 @@
+//go:build !windows
+
+package handover
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// helperEnv makes the test binary act as the new process in TestHandover.
+const helperEnv = "HANDOVER_TEST_HELPER"
+
+func TestHandover(t *testing.T) {
+	tests := []struct {
+		name      string
+		helper    string
+		wantReady bool
+	}{
+		{name: "ready", helper: "serve", wantReady: true},
+		{name: "exits early", helper: "exit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listeners []net.Listener
+			var files []*os.File
+			for range 2 {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = listener.Close() }()
+				f, err := listener.(*net.TCPListener).File()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = f.Close() }()
+				listeners = append(listeners, listener)
+				files = append(files, f)
+			}
+
+			t.Setenv(helperEnv, tt.helper)
+			p, err := Start(os.Args[0], []string{"-test.run=^TestHelperProcess$"}, files)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.WaitReady(t.Context())
+			if !tt.wantReady {
+				if err == nil {
+					t.Fatal("WaitReady() succeeded for a process that exited")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Stop accepting here; the new process owns the listeners now
+			for i, listener := range listeners {
+				_ = listener.Close()
+				conn, err := net.DialTimeout("tcp", listener.Addr().String(), 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = conn.Close() }()
+				_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := fmt.Sprintf("listener %d served by new process\n", i); line != want {
+					t.Errorf("got %q, want %q", line, want)
+				}
+			}
+		})
+	}
+}
+
+// TestHelperProcess is the new process started by TestHandover.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv(helperEnv) {
+	case "serve":
+		listeners, err := Inherited()
+		if err != nil || len(listeners) != 2 {
+			t.Fatalf("Inherited() = %v, %v", listeners, err)
+		}
+		if err := Ready(); err != nil {
+			t.Fatal(err)
+		}
+		for i, listener := range listeners {
+			conn, err := listener.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = fmt.Fprintf(conn, "listener %d served by new process\n", i)
+			_ = conn.Close()
+		}
+	case "exit":
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,96 @@
+//go:build !windows
+
+package handover
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Start runs executable with args as a new process that inherits listeners.
+// Output goes to the current process's stdout and stderr.
+func Start(executable string, args []string, listeners []*os.File) (*Process, error) {
+	if len(listeners) == 0 {
+		return nil, errors.New("no listener to hand over")
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create readiness pipe: %w", err)
+	}
+	// The new process holds the only write end, so its exit closes the pipe
+	defer func() { _ = readyW.Close() }()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		return strings.HasPrefix(kv, handoverEnv+"=")
+	}), handoverEnv+"="+strconv.Itoa(len(listeners)))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append([]*os.File{listeners[0], readyW}, listeners[1:]...)
+	if err := cmd.Start(); err != nil {
+		_ = readyR.Close()
+		return nil, fmt.Errorf("failed to start new process: %w", err)
+	}
+
+	// Reap the process should it exit while the current one still runs
+	go func() { _ = cmd.Wait() }()
+	return &Process{cmd: cmd, ready: readyR}, nil
+}
+
+// Inherited returns the listeners passed by Start in their original order, or nil
+// if this process was not started for a handover. Call it once, early.
+func Inherited() ([]net.Listener, error) {
+	value, ok := os.LookupEnv(handoverEnv)
+	if !ok {
+		return nil, nil
+	}
+	// Processes started by this one must not assume a handover
+	_ = os.Unsetenv(handoverEnv)
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid %s value %q", handoverEnv, value)
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for i := range n {
+		fd := uintptr(listenerFD)
+		if i > 0 {
+			fd = uintptr(readyFD + i)
+		}
+		listener, err := fileListener(fd)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("failed to use inherited listener %d: %w", i+1, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// fileListener returns a listener for the inherited descriptor fd.
+func fileListener(fd uintptr) (net.Listener, error) {
+	f := os.NewFile(fd, "listener")
+	defer func() { _ = f.Close() }()
+	return net.FileListener(f)
+}
+
+// Ready tells the previous process that this one serves the inherited listeners,
+// so it may stop. Only call it after Inherited returned a listener.
+func Ready() error {
+	f := os.NewFile(readyFD, "ready")
+	defer func() { _ = f.Close() }()
+	if _, err := f.Write([]byte{1}); err != nil {
+		return fmt.Errorf("failed to report readiness: %w", err)
+	}
+	return nil
+}
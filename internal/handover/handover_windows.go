@@ -0,0 +1,28 @@
+//go:build windows
+
+package handover
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// errUnsupported is returned by Start on Windows, which can't pass sockets to
+// child processes as file descriptors.
+var errUnsupported = errors.New("listener handover is not supported on Windows")
+
+// Start is not supported on Windows.
+func Start(string, []string, []*os.File) (*Process, error) {
+	return nil, errUnsupported
+}
+
+// Inherited always returns nil on Windows.
+func Inherited() ([]net.Listener, error) {
+	return nil, nil
+}
+
+// Ready is a no-op on Windows.
+func Ready() error {
+	return nil
+}
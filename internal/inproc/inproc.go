@@ -0,0 +1,102 @@
+// Package inproc calls HTTP handlers in-process, for APIs that serve the proxy's
+// routes over other transports. Buffered responses are collected; server-sent
+// events are passed on as the handler flushes them.
+package inproc
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// ResponseWriter records the response of an in-process handler call.
+type ResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+
+	onEvent func(event, data string) error // nil for buffered responses
+	event   string                         // type of the SSE event being read
+	err     error                          // first error returned by onEvent
+}
+
+// NewResponseWriter returns a writer collecting a buffered response.
+func NewResponseWriter() *ResponseWriter {
+	return &ResponseWriter{header: http.Header{}}
+}
+
+// NewEventWriter returns a writer calling onEvent for each SSE data line of a
+// successful response as it is flushed, with the type of its event ("" if unset).
+// Once onEvent fails, writes fail with its error, which ends the handler's stream.
+func NewEventWriter(onEvent func(event, data string) error) *ResponseWriter {
+	return &ResponseWriter{header: http.Header{}, onEvent: onEvent}
+}
+
+// Header implements http.ResponseWriter.
+func (w *ResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (w *ResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.body.Write(p)
+}
+
+// Flush implements http.Flusher, passing on the complete events written so far.
+func (w *ResponseWriter) Flush() {
+	_ = w.Finish()
+}
+
+// Status returns the response status code.
+func (w *ResponseWriter) Status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+// Body returns the buffered response body. For event writers, it holds the
+// body of an error response, or the part of a stream not yet passed on.
+func (w *ResponseWriter) Body() []byte {
+	return w.body.Bytes()
+}
+
+// Finish passes on the remaining complete events after the handler returned and
+// reports the first error of onEvent.
+func (w *ResponseWriter) Finish() error {
+	if w.onEvent == nil || w.Status() != http.StatusOK {
+		return w.err
+	}
+
+	for w.err == nil {
+		i := bytes.IndexByte(w.body.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSuffix(string(w.body.Next(i+1)), "\n")
+
+		switch {
+		case line == "":
+			w.event = ""
+		case strings.HasPrefix(line, "event: "):
+			w.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			w.err = w.onEvent(w.event, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	return w.err
+}
+
+// Compile-time check that ResponseWriter supports streaming
+var _ http.Flusher = (*ResponseWriter)(nil)
@@ -0,0 +1,184 @@
+// Package logfile provides an append-only log file writer that rotates the file
+// by size and prunes rotated files by age and count.
+//
+// Rotated files are kept next to the log file, named after it with the rotation
+// time inserted before the extension (claudine.log → claudine-2006-01-02T15-04-05.000.log).
+package logfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp in rotated file names; sortable and free of
+// characters that are invalid in Windows file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Options configures rotation. Zero values disable the respective limit.
+type Options struct {
+	// MaxSize is the size in bytes at which the file is rotated.
+	MaxSize int64
+
+	// MaxAge removes rotated files older than this.
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+}
+
+// Writer appends to a log file, rotating it before a write would exceed MaxSize.
+// It is safe for concurrent use.
+type Writer struct {
+	path string
+	opts Options
+	now  func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Compile-time check that Writer implements io.WriteCloser.
+var _ io.WriteCloser = (*Writer)(nil)
+
+// Open opens path for appending, creating it and its parent directories as needed.
+func Open(path string, opts Options) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	w := &Writer{path: path, opts: opts, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p, rotating first if the file would grow beyond MaxSize. A single
+// write larger than MaxSize goes to a fresh file.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize {
+		// A failed rotation keeps appending to the current file rather than losing logs
+		if err := w.rotate(); err != nil && w.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotate renames the current file to a backup, opens a new one and prunes backups.
+// The log file is open afterwards unless reopening it failed.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	w.file = nil
+
+	// Renaming fails while another process holds the file open on Windows
+	renameErr := os.Rename(w.path, w.backupName(w.now()))
+	if err := w.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to rotate log file: %w", renameErr)
+	}
+	// Pruning failures must not stop logging
+	_ = w.prune()
+	return nil
+}
+
+func (w *Writer) backupName(t time.Time) string {
+	ext := filepath.Ext(w.path)
+	return strings.TrimSuffix(w.path, ext) + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// prune removes backups beyond MaxBackups and older than MaxAge.
+func (w *Writer) prune() error {
+	if w.opts.MaxBackups <= 0 && w.opts.MaxAge <= 0 {
+		return nil
+	}
+
+	type backup struct {
+		path string
+		time time.Time
+	}
+	ext := filepath.Ext(w.path)
+	prefix := filepath.Base(strings.TrimSuffix(w.path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return err
+	}
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue // not a backup of this file
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(w.path), name), time: t})
+	}
+
+	// Newest first
+	slices.SortFunc(backups, func(a, b backup) int { return b.time.Compare(a.time) })
+
+	var errs []error
+	for i, b := range backups {
+		expired := w.opts.MaxAge > 0 && w.now().Sub(b.time) > w.opts.MaxAge
+		excess := w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups
+		if expired || excess {
+			if err := os.Remove(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to remove old log files: %v", errs)
+	}
+	return nil
+}
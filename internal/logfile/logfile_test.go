@@ -0,0 +1,86 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "claudine.log")
+
+	w, err := Open(path, Options{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "fourth\n" {
+		t.Errorf("current file = %q, want last line only", current)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "claudine-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2 (oldest pruned)", backups)
+	}
+	for _, b := range backups {
+		content, _ := os.ReadFile(b)
+		if strings.Contains(string(content), "first") {
+			t.Errorf("oldest backup %s should have been pruned", b)
+		}
+	}
+}
+
+func TestWriterMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "claudine.log")
+
+	stale := filepath.Join(dir, "claudine-2020-01-01T00-00-00.000.log")
+	unrelated := filepath.Join(dir, "claudine-notes.log")
+	for _, p := range []string{stale, unrelated} {
+		if err := os.WriteFile(p, []byte("old\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := Open(path, Options{MaxSize: 4, MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+
+	for range 2 {
+		if _, err := w.Write([]byte("log\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale backup not removed: %v", err)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
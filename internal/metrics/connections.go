@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// ConnectionBuckets are latency buckets in seconds suited for connection setup (1ms to 10s).
+var ConnectionBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Connection phases reported to ConnectionCollector.Phase.
+const (
+	PhaseDNS     = "dns"     // resolving the upstream host
+	PhaseConnect = "connect" // establishing the TCP connection
+	PhaseTLS     = "tls"     // TLS handshake
+	PhaseWait    = "wait"    // from requesting a connection until one is available
+)
+
+// maxAttemptLabel caps the attempt label; later attempts are counted together.
+const maxAttemptLabel = 4
+
+// ConnectionCollector records how upstream requests obtain their connections,
+// separating network and connection setup latency from the time Anthropic takes to
+// respond. Methods on a nil collector are no-ops.
+type ConnectionCollector struct {
+	connections *CounterVec
+	phases      *HistogramVec
+	headers     *HistogramVec
+	attempts    *CounterVec
+}
+
+// NewConnectionCollector registers upstream connection metrics in reg.
+func NewConnectionCollector(reg *Registry) *ConnectionCollector {
+	return &ConnectionCollector{
+		connections: reg.NewCounterVec("claudine_upstream_connections_total",
+			"Connections used for upstream requests, by whether they were reused.", "reused"),
+		phases: reg.NewHistogramVec("claudine_upstream_connection_phase_seconds",
+			"Time spent obtaining upstream connections, by phase.", ConnectionBuckets, "phase"),
+		headers: reg.NewHistogramVec("claudine_upstream_response_header_seconds",
+			"Time from sending an upstream request until its response headers arrived.", DefaultBuckets),
+		attempts: reg.NewCounterVec("claudine_upstream_attempts_total",
+			"Upstream request attempts by attempt number; attempts above 1 are retries.", "attempt"),
+	}
+}
+
+// ConnectionAcquired records a connection obtained for an upstream request.
+func (c *ConnectionCollector) ConnectionAcquired(reused bool) {
+	if c == nil {
+		return
+	}
+	c.connections.Inc(strconv.FormatBool(reused))
+}
+
+// Phase records the duration of a connection phase (PhaseDNS, PhaseConnect,
+// PhaseTLS or PhaseWait).
+func (c *ConnectionCollector) Phase(phase string, d time.Duration) {
+	if c == nil {
+		return
+	}
+	c.phases.Observe(d.Seconds(), phase)
+}
+
+// ResponseHeaders records the time from writing a request until the first response byte.
+func (c *ConnectionCollector) ResponseHeaders(d time.Duration) {
+	if c == nil {
+		return
+	}
+	c.headers.Observe(d.Seconds())
+}
+
+// Attempt records the n-th attempt (1-based) of an upstream request.
+func (c *ConnectionCollector) Attempt(n int) {
+	if c == nil || n < 1 {
+		return
+	}
+	label := strconv.Itoa(n)
+	if n >= maxAttemptLabel {
+		label = strconv.Itoa(maxAttemptLabel) + "+"
+	}
+	c.attempts.Inc(label)
+}
@@ -0,0 +1,48 @@
+package metrics
+
+// ErrorCollector counts failures that don't surface as upstream responses: token
+// refreshes, token revocations and OpenAI adapter transformations. Methods on a nil collector are no-ops.
+type ErrorCollector struct {
+	tokenRefresh *CounterVec
+	tokenRevoked *CounterVec
+	adapter      *CounterVec
+}
+
+// NewErrorCollector registers error metrics in reg.
+func NewErrorCollector(reg *Registry) *ErrorCollector {
+	c := &ErrorCollector{
+		tokenRefresh: reg.NewCounterVec("claudine_token_refresh_failures_total",
+			"Failed attempts to obtain an access token."),
+		tokenRevoked: reg.NewCounterVec("claudine_token_revocations_total",
+			"Refresh tokens rejected as revoked, by account.", "account"),
+		adapter: reg.NewCounterVec("claudine_adapter_errors_total",
+			"Failed OpenAI adapter transformations.", "stage"),
+	}
+	// Expose zero values so alerts can rely on the series existing
+	c.tokenRefresh.Add(0)
+	return c
+}
+
+// TokenRefreshFailed records a failed attempt to obtain an access token.
+func (c *ErrorCollector) TokenRefreshFailed() {
+	if c == nil {
+		return
+	}
+	c.tokenRefresh.Inc()
+}
+
+// TokenRevoked records that account's refresh token was revoked.
+func (c *ErrorCollector) TokenRevoked(account string) {
+	if c == nil {
+		return
+	}
+	c.tokenRevoked.Inc(account)
+}
+
+// AdapterFailed records a failed transformation in stage (request or response).
+func (c *ErrorCollector) AdapterFailed(stage string) {
+	if c == nil {
+		return
+	}
+	c.adapter.Inc(stage)
+}
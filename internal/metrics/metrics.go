@@ -0,0 +1,239 @@
+// Package metrics provides a minimal metrics registry exposed in the
+// Prometheus text exposition format.
+//
+// Only counters and histograms are supported; that covers request, token and
+// latency accounting without pulling in a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds suited for LLM requests (100ms to 5min).
+var DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Registry holds metric families and serves them over HTTP.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+}
+
+// Compile-time check that Registry implements http.Handler
+var _ http.Handler = (*Registry)(nil)
+
+// family is a named metric with a fixed set of label names.
+type family interface {
+	write(w io.Writer)
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounterVec registers a counter family partitioned by labels.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, labels: labels}, values: map[string]*counter{}}
+	r.register(c)
+	return c
+}
+
+// NewHistogramVec registers a histogram family partitioned by labels.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{desc: desc{name: name, help: help, labels: labels}, buckets: buckets, values: map[string]*histogram{}}
+	r.register(h)
+	return h
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families = append(r.families, f)
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	r.mu.Lock()
+	families := slices.Clone(r.families)
+	r.mu.Unlock()
+
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+// desc describes a metric family.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// key joins label values into a map key.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// header writes HELP and TYPE lines.
+func (d *desc) header(w io.Writer, typ string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, typ)
+}
+
+// labelPairs renders {k="v",...} including optional extra pairs.
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(d.labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escape(v))
+		b.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i])
+		b.WriteString(`="`)
+		b.WriteString(extra[i+1])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counter
+}
+
+type counter struct {
+	labels []string
+	value  float64
+}
+
+// Add increases the counter for the given label values by v (must be >= 0).
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.values[key]
+	if !ok {
+		entry = &counter{labels: slices.Clone(labelValues)}
+		c.values[key] = entry
+	}
+	entry.value += v
+}
+
+// Inc increases the counter for the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		entry := c.values[key]
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(entry.labels), formatFloat(entry.value))
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records v for the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.values[key]
+	if !ok {
+		entry = &histogram{labels: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = entry
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			entry.counts[i]++
+		}
+	}
+	entry.count++
+	entry.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		entry := h.values[key]
+		for i, upper := range h.buckets {
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(entry.labels, "le", formatFloat(upper)), entry.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(entry.labels, "le", "+Inf"), entry.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(entry.labels), formatFloat(entry.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(entry.labels), entry.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("test_requests_total", "Requests.", "arm")
+	latency := reg.NewHistogramVec("test_duration_seconds", "Latency.", []float64{1, 5}, "arm")
+
+	requests.Inc("a")
+	requests.Add(2, `b"x`)
+	latency.Observe(0.5, "a")
+	latency.Observe(3, "a")
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	want := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{arm="a"} 1
+test_requests_total{arm="b\"x"} 2
+# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{arm="a",le="1"} 1
+test_duration_seconds_bucket{arm="a",le="5"} 2
+test_duration_seconds_bucket{arm="a",le="+Inf"} 2
+test_duration_seconds_sum{arm="a"} 3.5
+test_duration_seconds_count{arm="a"} 2
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("exposition mismatch:\n%s\nwant:\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestErrorMetrics(t *testing.T) {
+	reg := NewRegistry()
+	usageCollector := NewUsageCollector(reg, nil)
+	errorCollector := NewErrorCollector(reg)
+
+	usageCollector.Consume(t.Context(), usage.Event{Path: "/v1/messages", Route: "/v1/messages", Status: 529, UpstreamStatus: 529, ErrorType: "overloaded_error"})
+	usageCollector.Consume(t.Context(), usage.Event{Path: "/v1/messages", Route: "/v1/messages", Status: 200, UpstreamStatus: 200})
+	errorCollector.AdapterFailed("response")
+	(*ErrorCollector)(nil).TokenRefreshFailed()
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`claudine_upstream_responses_total{path="/v1/messages",status="529"} 1`,
+		`claudine_upstream_responses_total{path="/v1/messages",status="200"} 1`,
+		`claudine_upstream_errors_total{path="/v1/messages",type="overloaded_error"} 1`,
+		"claudine_token_refresh_failures_total 0",
+		`claudine_adapter_errors_total{stage="response"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("exposition missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+}
+
+func TestUsageTagAllowlist(t *testing.T) {
+	reg := NewRegistry()
+	c := NewUsageCollector(reg, []string{"search"})
+
+	for _, tag := range []string{"search", "random-1", "random-2", ""} {
+		c.Consume(t.Context(), usage.Event{Route: "/v1/messages", Status: 200, Tag: tag})
+	}
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`claudine_requests_total{path="/v1/messages",model="",status="200",experiment="",arm="",tag="search"} 1`,
+		`claudine_requests_total{path="/v1/messages",model="",status="200",experiment="",arm="",tag="other"} 2`,
+		`claudine_requests_total{path="/v1/messages",model="",status="200",experiment="",arm="",tag=""} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "random") {
+		t.Errorf("exposition contains tag outside the allowlist:\n%s", body)
+	}
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import "time"
+
+// QueueBuckets are queue delay buckets in seconds (1ms to 2min).
+var QueueBuckets = []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// PacingCollector records how long upstream pacing holds requests back, by
+// priority. Methods on a nil collector are no-ops.
+type PacingCollector struct {
+	delay    *HistogramVec
+	rejected *CounterVec
+}
+
+// NewPacingCollector registers pacing metrics in reg.
+func NewPacingCollector(reg *Registry) *PacingCollector {
+	return &PacingCollector{
+		delay: reg.NewHistogramVec("claudine_pacing_queue_delay_seconds",
+			"Time requests waited for upstream pacing before being sent, by priority.", QueueBuckets, "priority"),
+		rejected: reg.NewCounterVec("claudine_pacing_rejections_total",
+			"Requests answered with 429 because pacing would have delayed them beyond max_wait, by priority.", "priority"),
+	}
+}
+
+// QueueDelay records the time a request of priority waited before being sent.
+func (c *PacingCollector) QueueDelay(priority string, d time.Duration) {
+	if c == nil {
+		return
+	}
+	c.delay.Observe(d.Seconds(), priority)
+}
+
+// Rejected records a request of priority rejected by pacing.
+func (c *PacingCollector) Rejected(priority string) {
+	if c == nil {
+		return
+	}
+	c.rejected.Inc(priority)
+}
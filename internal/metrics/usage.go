@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// TTFTBuckets are time to first token buckets in seconds (50ms to 1min).
+var TTFTBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60}
+
+// ThroughputBuckets are output token rate buckets in tokens per second.
+var ThroughputBuckets = []float64{5, 10, 20, 30, 50, 75, 100, 150, 200, 300}
+
+// UsageCollector turns request completion events into request, token, latency and
+// upstream error metrics. The path label is the matched route pattern, never the
+// request path, so clients cannot create series at will. Requests routed through
+// an A/B experiment carry experiment and arm labels; tagged requests carry a tag
+// label, which is "other" for tags outside the configured allowlist.
+type UsageCollector struct {
+	requests       *CounterVec
+	tokens         *CounterVec
+	duration       *HistogramVec
+	upstreamStatus *CounterVec
+	upstreamErrors *CounterVec
+	ttft           *HistogramVec
+	upstreamTTFT   *HistogramVec
+	throughput     *HistogramVec
+
+	tags map[string]bool
+}
+
+// otherTag labels requests whose tag is not in the allowlist.
+const otherTag = "other"
+
+// Compile-time check that UsageCollector implements usage.Sink
+var _ usage.Sink = (*UsageCollector)(nil)
+
+// NewUsageCollector registers usage metrics in reg. Only tags listed in tags
+// become label values, since clients choose them freely.
+func NewUsageCollector(reg *Registry, tags []string) *UsageCollector {
+	allowed := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		allowed[tag] = true
+	}
+	return &UsageCollector{
+		tags: allowed,
+		requests: reg.NewCounterVec("claudine_requests_total",
+			"Completed requests.", "path", "model", "status", "experiment", "arm", "tag"),
+		tokens: reg.NewCounterVec("claudine_tokens_total",
+			"Tokens reported by the upstream.", "path", "model", "type", "experiment", "arm", "tag"),
+		duration: reg.NewHistogramVec("claudine_request_duration_seconds",
+			"Request latency until the response or stream completed.", DefaultBuckets, "path", "experiment", "arm", "tag"),
+		upstreamStatus: reg.NewCounterVec("claudine_upstream_responses_total",
+			"Upstream responses by HTTP status.", "path", "status"),
+		upstreamErrors: reg.NewCounterVec("claudine_upstream_errors_total",
+			"Errors reported by the upstream by Anthropic error type.", "path", "type"),
+		ttft: reg.NewHistogramVec("claudine_time_to_first_token_seconds",
+			"Time from receiving a streaming request to its first content token.", TTFTBuckets, "path", "model"),
+		upstreamTTFT: reg.NewHistogramVec("claudine_upstream_time_to_first_token_seconds",
+			"Time from sending a streaming request upstream to its first content token.", TTFTBuckets, "path", "model"),
+		throughput: reg.NewHistogramVec("claudine_output_tokens_per_second",
+			"Output token rate of streams after the first token.", ThroughputBuckets, "path", "model"),
+	}
+}
+
+// Consume implements usage.Sink.
+func (c *UsageCollector) Consume(_ context.Context, e usage.Event) {
+	path, tag := e.Route, e.Tag
+	if tag != "" && !c.tags[tag] {
+		tag = otherTag
+	}
+	c.requests.Inc(path, e.Model, strconv.Itoa(e.Status), e.Experiment, e.Arm, tag)
+
+	for typ, n := range map[string]int64{
+		"input":          e.Usage.InputTokens,
+		"output":         e.Usage.OutputTokens,
+		"cache_read":     e.Usage.CacheReadInputTokens,
+		"cache_creation": e.Usage.CacheCreationInputTokens,
+	} {
+		if n > 0 {
+			c.tokens.Add(float64(n), path, e.Model, typ, e.Experiment, e.Arm, tag)
+		}
+	}
+
+	c.duration.Observe(float64(e.LatencyMS)/1000, path, e.Experiment, e.Arm, tag)
+
+	if e.UpstreamStatus != 0 {
+		c.upstreamStatus.Inc(path, strconv.Itoa(e.UpstreamStatus))
+	}
+	if e.ErrorType != "" {
+		c.upstreamErrors.Inc(path, e.ErrorType)
+	}
+
+	if e.TTFTMS > 0 {
+		c.ttft.Observe(float64(e.TTFTMS)/1000, path, e.Model)
+		c.upstreamTTFT.Observe(float64(e.UpstreamTTFTMS)/1000, path, e.Model)
+	}
+	if e.TokensPerSecond > 0 {
+		c.throughput.Observe(e.TokensPerSecond, path, e.Model)
+	}
+}
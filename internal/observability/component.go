@@ -0,0 +1,125 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Components whose log level can be set independently of the global level.
+const (
+	ComponentProxy       = "proxy"
+	ComponentAdapter     = "adapter"
+	ComponentTokenSource = "tokensource"
+	ComponentTokenStore  = "tokenstore"
+)
+
+// componentPackages maps package paths to components. Request logs belong to the
+// proxy so they can be silenced while debugging another component; they are written
+// by httplog, whose closures may be inlined into the logging middleware.
+var componentPackages = map[string]string{
+	ScopeName + "/internal/proxy":                    ComponentProxy,
+	ScopeName + "/internal/observability/middleware": ComponentProxy,
+	"github.com/go-chi/httplog/v3":                   ComponentProxy,
+	ScopeName + "/pkg/openaiadapter":                 ComponentAdapter,
+	ScopeName + "/pkg/tokensource":                   ComponentTokenSource,
+	ScopeName + "/internal/tokenstore":               ComponentTokenStore,
+}
+
+// componentLevelHandler filters records by the level of the component that logged
+// them, identified by the package of the calling function. Records outside any
+// component use the global level.
+type componentLevelHandler struct {
+	handler slog.Handler
+	levels  *levels
+
+	// components caches the component per caller PC ("" for none)
+	components *sync.Map
+}
+
+// newComponentLevelHandler wraps handler, which must accept records down to levels.Level().
+func newComponentLevelHandler(handler slog.Handler, levels *levels) *componentLevelHandler {
+	return &componentLevelHandler{
+		handler:    handler,
+		levels:     levels,
+		components: &sync.Map{},
+	}
+}
+
+// minComponentLevel returns the lowest of the default and component levels.
+func minComponentLevel(level slog.Level, levels map[string]slog.Level) slog.Level {
+	for _, l := range levels {
+		level = min(level, l)
+	}
+	return level
+}
+
+// Enabled reports whether any component handles records at the given level; the
+// caller is unknown until Handle.
+func (h *componentLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level() && h.handler.Enabled(ctx, level)
+}
+
+// Handle drops records below the level of the logging component.
+func (h *componentLevelHandler) Handle(ctx context.Context, record slog.Record) error {
+	threshold := h.levels.global.Level()
+	if l, ok := h.levels.components[h.component(record.PC)]; ok {
+		threshold = l
+	}
+	if record.Level < threshold {
+		return nil
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a new handler with additional attributes.
+func (h *componentLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithAttrs(attrs)
+	return &clone
+}
+
+// WithGroup returns a new handler with the given group name.
+func (h *componentLevelHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithGroup(name)
+	return &clone
+}
+
+func (h *componentLevelHandler) component(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	if c, ok := h.components.Load(pc); ok {
+		return c.(string)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	c := componentOf(frame.Function)
+	h.components.Store(pc, c)
+	return c
+}
+
+// componentOf returns the component of a fully qualified function name such as
+// "github.com/.../pkg/openaiadapter.(*Adapter).Do". Subpackages belong to
+// their parent's component.
+func componentOf(function string) string {
+	pkg := function
+	if slash := strings.LastIndexByte(pkg, '/'); slash >= 0 {
+		if dot := strings.IndexByte(pkg[slash:], '.'); dot >= 0 {
+			pkg = pkg[:slash+dot]
+		}
+	}
+	for pkg != "" {
+		if c, ok := componentPackages[pkg]; ok {
+			return c
+		}
+		slash := strings.LastIndexByte(pkg, '/')
+		if slash < 0 {
+			break
+		}
+		pkg = pkg[:slash]
+	}
+	return ""
+}
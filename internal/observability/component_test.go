@@ -0,0 +1,58 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestComponentOf(t *testing.T) {
+	tests := []struct {
+		function string
+		want     string
+	}{
+		{ScopeName + "/internal/proxy.(*Proxy).ServeHTTP", ComponentProxy},
+		{"github.com/go-chi/httplog/v3.RequestLogger.func1.1", ComponentProxy},
+		{ScopeName + "/internal/observability/middleware.Logging.RequestLogger.func1.1.1", ComponentProxy},
+		{ScopeName + "/pkg/openaiadapter.(*CreateChatCompletionsHandler).ServeHTTP", ComponentAdapter},
+		{ScopeName + "/pkg/openaiadapter/types.init", ComponentAdapter},
+		{ScopeName + "/pkg/tokensource.(*PersistentTokenSource).Token", ComponentTokenSource},
+		{ScopeName + "/internal/tokenstore.(*FileStore).Read", ComponentTokenStore},
+		{ScopeName + "/internal/app.(*App).Start", ""},
+		{ScopeName + "/internal/proxyextra.Do", ""},
+		{"main.main", ""},
+	}
+	for _, tt := range tests {
+		if got := componentOf(tt.function); got != tt.want {
+			t.Errorf("componentOf(%q) = %q, want %q", tt.function, got, tt.want)
+		}
+	}
+}
+
+func TestComponentLevelHandler(t *testing.T) {
+	var buf bytes.Buffer
+	levels := newLevels(slog.LevelWarn, map[string]slog.Level{ComponentAdapter: slog.LevelDebug})
+	inner := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: levels})
+	h := newComponentLevelHandler(inner, levels)
+
+	if !h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug must pass Enabled while a component logs at debug")
+	}
+
+	// This package is no component, so the default level applies
+	logger := slog.New(h)
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	if bytes.Contains(buf.Bytes(), []byte("dropped")) || !bytes.Contains(buf.Bytes(), []byte("kept")) {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+
+	// Raising verbosity at runtime applies to records outside components
+	levels.global.Set(slog.LevelInfo)
+	logger.Info("now kept")
+	if !bytes.Contains(buf.Bytes(), []byte("now kept")) {
+		t.Errorf("runtime level change not applied:\n%s", buf.String())
+	}
+}
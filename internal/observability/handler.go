@@ -5,11 +5,13 @@ import (
 	"log/slog"
 
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
 )
 
 // traceContextHandler enriches log records with OpenTelemetry trace correlation
 // attributes (trace_id and span_id) to enable log-trace correlation in distributed
-// systems.
+// systems, and with the request ID of the inbound request being served.
 type traceContextHandler struct {
 	handler slog.Handler
 }
@@ -26,7 +28,8 @@ func (h *traceContextHandler) Enabled(ctx context.Context, level slog.Level) boo
 
 // Handle enriches the log record with trace correlation attributes (trace_id and
 // span_id) when trace context is available, enabling correlation between logs and
-// distributed traces.
+// distributed traces. Records logged while serving a request also get its request_id
+// unless they already carry one (the request log sets it itself).
 func (h *traceContextHandler) Handle(ctx context.Context, record slog.Record) error {
 	spanCtx := trace.SpanContextFromContext(ctx)
 	if spanCtx.IsValid() {
@@ -36,9 +39,23 @@ func (h *traceContextHandler) Handle(ctx context.Context, record slog.Record) er
 		)
 	}
 
+	if requestID := middleware.RequestIDFromContext(ctx); requestID != "" && !hasAttr(record, "request_id") {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+
 	return h.handler.Handle(ctx, record)
 }
 
+// hasAttr reports whether the record has a top-level attribute with the given key.
+func hasAttr(record slog.Record, key string) bool {
+	found := false
+	record.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
+
 // WithAttrs returns a new handler with additional attributes.
 func (h *traceContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &traceContextHandler{handler: h.handler.WithAttrs(attrs)}
@@ -0,0 +1,35 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+func TestTraceContextHandlerRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newTraceContextHandler(slog.NewTextHandler(&buf, nil)))
+	ctx := context.WithValue(context.Background(), middleware.RequestIDContextKey{}, "req-1")
+
+	logger.InfoContext(ctx, "inside request")
+	logger.InfoContext(ctx, "request log", "request_id", "req-1")
+	logger.InfoContext(context.Background(), "outside request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	if got := strings.Count(lines[0], "request_id=req-1"); got != 1 {
+		t.Errorf("inside request: request_id count = %d, want 1: %s", got, lines[0])
+	}
+	if got := strings.Count(lines[1], "request_id=req-1"); got != 1 {
+		t.Errorf("request log: request_id count = %d, want 1: %s", got, lines[1])
+	}
+	if strings.Contains(lines[2], "request_id") {
+		t.Errorf("outside request: unexpected request_id: %s", lines[2])
+	}
+}
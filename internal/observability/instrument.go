@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -25,7 +26,28 @@ const (
 	ScopeName = "github.com/florianilch/claudine-proxy"
 )
 
-func Instrument(ctx context.Context, level slog.Level, logFormat string) (func(shutdownCtx context.Context) error, error) {
+// LogConfig configures log output.
+type LogConfig struct {
+	// Level is the minimum level logged.
+	Level slog.Level
+
+	// ComponentLevels overrides Level for the named components (see ComponentProxy and friends).
+	ComponentLevels map[string]slog.Level
+
+	// Format is the record format: text or json.
+	Format string
+
+	// Output selects where logs go: OutputStdout (written to Writer), OutputSyslog
+	// or OutputJournald. Defaults to OutputStdout.
+	Output string
+
+	// Writer receives logs of OutputStdout.
+	Writer io.Writer
+}
+
+// Instrument sets up logging and trace propagation. Logs are written to the
+// configured output unless OTEL_LOGS_EXPORTER selects an OpenTelemetry exporter.
+func Instrument(ctx context.Context, cfg LogConfig) (func(shutdownCtx context.Context) error, error) {
 	var shutdownFuncs []func(context.Context) error
 	var err error
 
@@ -42,7 +64,10 @@ func Instrument(ctx context.Context, level slog.Level, logFormat string) (func(s
 	propagator := newPropagator()
 	otel.SetTextMapPropagator(propagator)
 
-	loggerProvider, err := newLoggerProvider(ctx, level)
+	// Sinks accept the most verbose component level; componentLevelHandler filters per component
+	levels := newLevels(cfg.Level, cfg.ComponentLevels)
+
+	loggerProvider, err := newLoggerProvider(ctx, levels)
 	if err != nil {
 		shutdownErr := shutdown(ctx)
 		return shutdown, errors.Join(err, shutdownErr)
@@ -51,36 +76,57 @@ func Instrument(ctx context.Context, level slog.Level, logFormat string) (func(s
 	otelGlobal.SetLoggerProvider(loggerProvider)
 
 	// Severity filtering happens at different layers:
-	// stdout → slog.HandlerOptions.Level
+	// stdout, syslog, journald → slog.HandlerOptions.Level
 	// OTel → minsev.Processor (implements FilterProcessor)
 	logsExporter := os.Getenv("OTEL_LOGS_EXPORTER")
 	var handler slog.Handler
-	if logsExporter == "" || logsExporter == "none" {
-		handler, err = newStdoutHandler(level, logFormat)
-		if err != nil {
-			shutdownErr := shutdown(ctx)
-			return shutdown, errors.Join(err, shutdownErr)
-		}
-	} else {
+	switch {
+	case logsExporter != "" && logsExporter != "none":
 		handler = otelslog.NewHandler(ScopeName)
+	case cfg.Output == "" || cfg.Output == OutputStdout:
+		handler, err = newStdoutHandler(cfg.Writer, levels, cfg.Format)
+	default:
+		handler, err = newSystemHandler(cfg.Output, levels, cfg.Format, &shutdownFuncs)
+	}
+	if err != nil {
+		shutdownErr := shutdown(ctx)
+		return shutdown, errors.Join(err, shutdownErr)
+	}
+	handler = newScrubHandler(handler)
+	if len(cfg.ComponentLevels) > 0 {
+		handler = newComponentLevelHandler(handler, levels)
 	}
 	slog.SetDefault(slog.New(handler))
+	current.Store(levels)
 
 	return shutdown, nil
 }
 
 // newStdoutHandler creates a handler for human-readable logs with trace correlation.
-func newStdoutHandler(level slog.Level, logFormat string) (slog.Handler, error) {
-	opts := &slog.HandlerOptions{
-		Level: level,
+func newStdoutHandler(out io.Writer, level slog.Leveler, logFormat string) (slog.Handler, error) {
+	return newFormatHandler(out, &slog.HandlerOptions{Level: level}, logFormat)
+}
+
+// newSystemHandler creates a handler for syslog or journald output, registering
+// the sink's Close with shutdownFuncs.
+func newSystemHandler(output string, level slog.Leveler, logFormat string, shutdownFuncs *[]func(context.Context) error) (slog.Handler, error) {
+	sink, err := openSink(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s log output: %w", output, err)
 	}
+	*shutdownFuncs = append(*shutdownFuncs, func(context.Context) error { return sink.Close() })
+
+	return newSinkHandler(sink, level, logFormat)
+}
 
+// newFormatHandler creates a text or JSON handler with trace correlation.
+func newFormatHandler(out io.Writer, opts *slog.HandlerOptions, logFormat string) (slog.Handler, error) {
 	var handler slog.Handler
 	switch strings.ToLower(logFormat) {
 	case "json":
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(out, opts)
 	case "text":
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(out, opts)
 	default:
 		return nil, fmt.Errorf("unsupported log format %q (expected: json, text)", logFormat)
 	}
@@ -91,7 +137,7 @@ func newStdoutHandler(level slog.Level, logFormat string) (slog.Handler, error)
 
 // newLoggerProvider creates a LoggerProvider configured by OTEL_LOGS_EXPORTER env var.
 // Returns a no-op provider if unset or "none".
-func newLoggerProvider(ctx context.Context, level slog.Level) (*otelSdkLog.LoggerProvider, error) {
+func newLoggerProvider(ctx context.Context, severity minsev.Severitier) (*otelSdkLog.LoggerProvider, error) {
 	exporterType := os.Getenv("OTEL_LOGS_EXPORTER")
 
 	if exporterType == "" || strings.ToLower(exporterType) == "none" {
@@ -135,9 +181,8 @@ func newLoggerProvider(ctx context.Context, level slog.Level) (*otelSdkLog.Logge
 		processor = otelSdkLog.NewBatchProcessor(exporter)
 	}
 
-	// minsev implements FilterProcessor for SDK-level severity filtering.
-	// Direct cast works because slog.Level and minsev.Severity are numerically identical.
-	processor = minsev.NewLogProcessor(processor, minsev.Severity(level))
+	// minsev implements FilterProcessor for SDK-level severity filtering
+	processor = minsev.NewLogProcessor(processor, severity)
 
 	return otelSdkLog.NewLoggerProvider(
 		otelSdkLog.WithProcessor(processor),
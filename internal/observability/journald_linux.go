@@ -0,0 +1,31 @@
+package observability
+
+import (
+	"log/slog"
+	"net"
+)
+
+// journaldSocket is where systemd-journald receives native protocol datagrams.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldSink writes to systemd-journald.
+type journaldSink struct {
+	conn *net.UnixConn
+}
+
+func openJournald() (logSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn}, nil
+}
+
+func (j *journaldSink) WriteLevel(level slog.Level, line []byte) error {
+	_, err := j.conn.Write(journaldEntry(level, line))
+	return err
+}
+
+func (j *journaldSink) Close() error {
+	return j.conn.Close()
+}
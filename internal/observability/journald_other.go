@@ -0,0 +1,12 @@
+//go:build !linux
+
+package observability
+
+import (
+	"errors"
+	"runtime"
+)
+
+func openJournald() (logSink, error) {
+	return nil, errors.New("journald output is not supported on " + runtime.GOOS)
+}
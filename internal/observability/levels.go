@@ -0,0 +1,53 @@
+package observability
+
+import (
+	"log/slog"
+	"sync/atomic"
+
+	"go.opentelemetry.io/contrib/processors/minsev"
+	otelLog "go.opentelemetry.io/otel/log"
+)
+
+// levels holds the global log level, adjustable at runtime, and the static
+// per-component overrides.
+type levels struct {
+	global     slog.LevelVar
+	components map[string]slog.Level
+}
+
+// current is the levels set up by Instrument, nil before.
+var current atomic.Pointer[levels]
+
+func newLevels(level slog.Level, components map[string]slog.Level) *levels {
+	l := &levels{components: components}
+	l.global.Set(level)
+	return l
+}
+
+// Level returns the lowest level any component logs at, implementing slog.Leveler
+// for the output handlers.
+func (l *levels) Level() slog.Level {
+	return minComponentLevel(l.global.Level(), l.components)
+}
+
+// Severity implements minsev.Severitier for OTel export.
+// Direct cast works because slog.Level and minsev.Severity are numerically identical.
+func (l *levels) Severity() otelLog.Severity {
+	return minsev.Severity(l.Level()).Severity()
+}
+
+// SetLevel changes the global log level of the running process. Components with
+// their own level are unaffected. No-op before Instrument.
+func SetLevel(level slog.Level) {
+	if l := current.Load(); l != nil {
+		l.global.Set(level)
+	}
+}
+
+// Level returns the global log level.
+func Level() slog.Level {
+	if l := current.Load(); l != nil {
+		return l.global.Level()
+	}
+	return slog.LevelInfo
+}
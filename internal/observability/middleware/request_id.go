@@ -11,12 +11,18 @@ import (
 // RequestIDContextKey is a context key for storing request IDs.
 type RequestIDContextKey struct{}
 
+// RequestIDFromContext returns the request ID stored by RequestIDGeneration, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDContextKey{}).(string)
+	return id
+}
+
 // getRequestID reads request ID from X-Request-ID header or context, generates if missing.
 func getRequestID(r *http.Request) string {
 	if id := r.Header.Get("X-Request-ID"); id != "" {
 		return id
 	}
-	if id, ok := r.Context().Value(RequestIDContextKey{}).(string); ok && id != "" {
+	if id := RequestIDFromContext(r.Context()); id != "" {
 		return id
 	}
 	return uuid.New().String()
@@ -40,7 +46,7 @@ func RequestIDGeneration(next http.Handler) http.Handler {
 // to log attributes.
 func RequestIDPropagation(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if requestID, ok := r.Context().Value(RequestIDContextKey{}).(string); ok && requestID != "" {
+		if requestID := RequestIDFromContext(r.Context()); requestID != "" {
 			// Propagate to client via response header
 			// Set early to ensure it's present during recovery scenarios
 			w.Header().Set("X-Request-ID", requestID)
@@ -0,0 +1,92 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+)
+
+// scrubbedValue replaces credentials found in log output.
+const scrubbedValue = "[REDACTED]"
+
+// credentialPatterns match credentials that must never reach log output. Each
+// pattern keeps its first group so logs still show what kind of value was removed.
+var credentialPatterns = []*regexp.Regexp{
+	// Authorization header values
+	regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/=-]+`),
+	// Anthropic API keys, OAuth access and refresh tokens (sk-ant-api…, sk-ant-oat…, sk-ant-ort…)
+	regexp.MustCompile(`(sk-ant-)[A-Za-z0-9_-]+`),
+	// OAuth token fields in JSON, form and query encodings
+	regexp.MustCompile(`(?i)((?:access|refresh|id)_token["']?\s*[:=]\s*["']?)[^"'&\s,;}]+`),
+}
+
+// scrub removes credentials from s.
+func Scrub(s string) string {
+	for _, p := range credentialPatterns {
+		s = p.ReplaceAllString(s, "${1}"+scrubbedValue)
+	}
+	return s
+}
+
+// scrubHandler removes credentials from log messages and attribute values before
+// they reach the underlying handler, as a safeguard against accidentally logged
+// tokens. Error values are replaced by their scrubbed message when it contains one.
+type scrubHandler struct {
+	handler slog.Handler
+}
+
+// newScrubHandler wraps handler with credential scrubbing.
+func newScrubHandler(handler slog.Handler) *scrubHandler {
+	return &scrubHandler{handler: handler}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *scrubHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle scrubs the record's message and attributes.
+func (h *scrubHandler) Handle(ctx context.Context, record slog.Record) error {
+	scrubbed := slog.NewRecord(record.Time, record.Level, Scrub(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		scrubbed.AddAttrs(scrubAttr(attr))
+		return true
+	})
+	return h.handler.Handle(ctx, scrubbed)
+}
+
+// WithAttrs returns a new handler with additional, scrubbed attributes.
+func (h *scrubHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		scrubbed[i] = scrubAttr(attr)
+	}
+	return &scrubHandler{handler: h.handler.WithAttrs(scrubbed)}
+}
+
+// WithGroup returns a new handler with the given group name.
+func (h *scrubHandler) WithGroup(name string) slog.Handler {
+	return &scrubHandler{handler: h.handler.WithGroup(name)}
+}
+
+func scrubAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Scrub(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		scrubbed := make([]slog.Attr, len(group))
+		for i, a := range group {
+			scrubbed[i] = scrubAttr(a)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(scrubbed...)}
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			if msg := Scrub(err.Error()); msg != err.Error() {
+				return slog.String(attr.Key, msg)
+			}
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
@@ -0,0 +1,51 @@
+package observability
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestScrub(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bearer", "Authorization: Bearer eyJhbGciOi.abc-123", "Authorization: Bearer [REDACTED]"},
+		{"api key", "invalid key sk-ant-api03-AbC_d-9 rejected", "invalid key sk-ant-[REDACTED] rejected"},
+		{"refresh token", "token refresh: sk-ant-ort01-xyz failed", "token refresh: sk-ant-[REDACTED] failed"},
+		{"json field", `{"refresh_token":"r1.abc","expires_in":3600}`, `{"refresh_token":"[REDACTED]","expires_in":3600}`},
+		{"form field", "grant_type=refresh_token&refresh_token=abc123&client_id=x", "grant_type=refresh_token&refresh_token=[REDACTED]&client_id=x"},
+		{"plain", "POST /v1/messages => HTTP 200", "POST /v1/messages => HTTP 200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Scrub(tt.in); got != tt.want {
+				t.Errorf("Scrub(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newScrubHandler(slog.NewJSONHandler(&buf, nil))).
+		With("auth", "Bearer secret-1")
+
+	logger.Info("request with sk-ant-oat01-secret-2",
+		"error", errors.New("oauth: refresh_token=secret-3 expired"),
+		slog.Group("headers", "authorization", "Bearer secret-4"),
+		"status", 401,
+	)
+
+	out := buf.String()
+	if strings.Contains(out, "secret") {
+		t.Errorf("credential leaked:\n%s", out)
+	}
+	if strings.Count(out, scrubbedValue) != 4 || !strings.Contains(out, `"status":401`) {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
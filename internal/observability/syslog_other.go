@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package observability
+
+import (
+	"errors"
+	"runtime"
+)
+
+func openSyslog() (logSink, error) {
+	return nil, errors.New("syslog output is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,38 @@
+//go:build !windows && !plan9
+
+package observability
+
+import (
+	"log/slog"
+	"log/syslog"
+)
+
+// syslogSink writes to the local syslog daemon.
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func openSyslog() (logSink, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, systemIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) WriteLevel(level slog.Level, line []byte) error {
+	switch syslogPriority(level) {
+	case 3:
+		return s.writer.Err(string(line))
+	case 4:
+		return s.writer.Warning(string(line))
+	case 6:
+		return s.writer.Info(string(line))
+	default:
+		return s.writer.Debug(string(line))
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
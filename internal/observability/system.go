@@ -0,0 +1,123 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Log outputs selectable in LogConfig.
+const (
+	OutputStdout   = "stdout"
+	OutputSyslog   = "syslog"
+	OutputJournald = "journald"
+)
+
+// systemIdentifier tags records in syslog and the journal.
+const systemIdentifier = "claudine"
+
+// logSink receives formatted log lines with their level, for outputs that carry
+// severity out of band (syslog, journald).
+type logSink interface {
+	WriteLevel(level slog.Level, line []byte) error
+	Close() error
+}
+
+// openSink opens the sink of a system log output.
+func openSink(output string) (logSink, error) {
+	switch output {
+	case OutputSyslog:
+		return openSyslog()
+	case OutputJournald:
+		return openJournald()
+	default:
+		return nil, fmt.Errorf("unsupported log output %q (expected: stdout, syslog, journald)", output)
+	}
+}
+
+// sinkHandler formats records with handler into a buffer and passes each line to
+// the sink. Timestamps are omitted; syslog and journald record their own.
+type sinkHandler struct {
+	handler slog.Handler
+	state   *sinkState
+}
+
+// sinkState is shared between a sinkHandler and its derived handlers.
+type sinkState struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	sink logSink
+}
+
+// newSinkHandler creates a handler writing to sink in the given format.
+func newSinkHandler(sink logSink, level slog.Leveler, logFormat string) (*sinkHandler, error) {
+	state := &sinkState{sink: sink}
+	handler, err := newFormatHandler(&state.buf, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}, logFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &sinkHandler{handler: handler, state: state}, nil
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *sinkHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle formats the record and writes it to the sink.
+func (h *sinkHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	h.state.buf.Reset()
+	if err := h.handler.Handle(ctx, record); err != nil {
+		return err
+	}
+	return h.state.sink.WriteLevel(record.Level, bytes.TrimSuffix(h.state.buf.Bytes(), []byte("\n")))
+}
+
+// WithAttrs returns a new handler with additional attributes.
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sinkHandler{handler: h.handler.WithAttrs(attrs), state: h.state}
+}
+
+// WithGroup returns a new handler with the given group name.
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	return &sinkHandler{handler: h.handler.WithGroup(name), state: h.state}
+}
+
+// syslogPriority maps a level to a syslog severity (RFC 5424).
+func syslogPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// journaldEntry encodes a record in the journal's native protocol. MESSAGE uses
+// the length-prefixed form, which allows newlines in the message.
+func journaldEntry(level slog.Level, line []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PRIORITY=%d\nSYSLOG_IDENTIFIER=%s\nMESSAGE\n", syslogPriority(level), systemIdentifier)
+	_ = binary.Write(&b, binary.LittleEndian, uint64(len(line)))
+	b.Write(line)
+	b.WriteByte('\n')
+	return b.Bytes()
+}
@@ -0,0 +1,67 @@
+package observability
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/slog"
+	"testing"
+)
+
+type recordingSink struct {
+	levels []slog.Level
+	lines  []string
+}
+
+func (s *recordingSink) WriteLevel(level slog.Level, line []byte) error {
+	s.levels = append(s.levels, level)
+	s.lines = append(s.lines, string(line))
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestSinkHandler(t *testing.T) {
+	sink := &recordingSink{}
+	h, err := newSinkHandler(sink, slog.LevelInfo, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(h).With("component", "test")
+
+	logger.Debug("dropped")
+	logger.Info("hello", "n", 1)
+	logger.Error("failed")
+
+	want := []string{
+		`level=INFO msg=hello component=test n=1`,
+		`level=ERROR msg=failed component=test`,
+	}
+	if len(sink.lines) != len(want) {
+		t.Fatalf("got lines %q, want %q", sink.lines, want)
+	}
+	for i := range want {
+		if sink.lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, sink.lines[i], want[i])
+		}
+	}
+	if sink.levels[1] != slog.LevelError {
+		t.Errorf("level = %v, want ERROR", sink.levels[1])
+	}
+}
+
+func TestJournaldEntry(t *testing.T) {
+	line := []byte("multi\nline")
+	entry := journaldEntry(slog.LevelWarn, line)
+
+	prefix := []byte("PRIORITY=4\nSYSLOG_IDENTIFIER=claudine\nMESSAGE\n")
+	if !bytes.HasPrefix(entry, prefix) {
+		t.Fatalf("entry = %q", entry)
+	}
+	rest := entry[len(prefix):]
+	if n := binary.LittleEndian.Uint64(rest[:8]); n != uint64(len(line)) {
+		t.Errorf("length = %d, want %d", n, len(line))
+	}
+	if got := string(rest[8:]); got != "multi\nline\n" {
+		t.Errorf("message = %q", got)
+	}
+}
@@ -0,0 +1,388 @@
+// Package pacing smooths upstream traffic to stay below Anthropic's per-minute
+// request and input token limits.
+//
+// Instead of sending bursts and reacting to 429s, requests are delayed just long
+// enough to keep a steady rate (leaky bucket, implemented as GCRA). Requests that
+// would have to wait longer than MaxWait are answered locally with a 429, a
+// retry-after header, which SDK clients honor automatically, and OpenAI-style
+// x-ratelimit-* headers describing the bucket state.
+//
+// Delayed requests queue by Priority: a request is sent once the buckets have
+// room for it and no request of higher priority is waiting, so interactive
+// requests overtake batch requests while the pacer is saturated.
+package pacing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bytesPerToken is a conservative estimate for input token accounting before the request is sent.
+const bytesPerToken = 4
+
+// Config describes the upstream limits to pace for.
+type Config struct {
+	// RequestsPerMinute limits upstream requests (0 = unlimited).
+	RequestsPerMinute int
+
+	// InputTokensPerMinute limits estimated input tokens (0 = unlimited).
+	InputTokensPerMinute int
+
+	// Burst allows this many requests (and Burst × average request tokens) to pass without delay.
+	Burst int
+
+	// MaxWait bounds how long a request is delayed before it is rejected locally.
+	MaxWait time.Duration
+}
+
+// Recorder records queue delays and rejections, such as metrics.PacingCollector.
+type Recorder interface {
+	QueueDelay(priority string, d time.Duration)
+	Rejected(priority string)
+}
+
+// Transport delays upstream requests according to the configured rates.
+type Transport struct {
+	Base http.RoundTripper
+
+	// Metrics, if set, records queue delays and rejections by priority.
+	Metrics Recorder
+
+	requests *bucket
+	tokens   *bucket
+	maxWait  time.Duration
+
+	mu      sync.Mutex
+	waiting [numPriorities][]*waiter // In arrival order
+	timer   *time.Timer              // Grants the next waiter once the buckets have room
+}
+
+// waiter is a request queued for the buckets to have room.
+type waiter struct {
+	tokens float64
+	ready  chan struct{} // Closed when the request's units are booked
+}
+
+// Compile-time check that Transport implements http.RoundTripper
+var _ http.RoundTripper = (*Transport)(nil)
+
+// New creates a pacing Transport wrapping base.
+func New(base http.RoundTripper, cfg Config) *Transport {
+	t := &Transport{Base: base, maxWait: cfg.MaxWait}
+	burst := max(cfg.Burst, 1)
+	if cfg.RequestsPerMinute > 0 {
+		t.requests = newBucket(float64(cfg.RequestsPerMinute), float64(burst))
+		t.requests.kind = "requests"
+	}
+	if cfg.InputTokensPerMinute > 0 {
+		// Token burst scales with the request burst relative to the per-minute budget
+		tokenBurst := float64(cfg.InputTokensPerMinute) * float64(burst) / float64(max(cfg.RequestsPerMinute, 60))
+		t.tokens = newBucket(float64(cfg.InputTokensPerMinute), max(tokenBurst, 1))
+		t.tokens.kind = "tokens"
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only the tokens bucket needs an estimate; without it bodies stream untouched
+	cost := 1
+	if t.tokens != nil {
+		var err error
+		if cost, err = estimateTokens(req); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := req.Context()
+	priority := PriorityFrom(ctx)
+	start := time.Now()
+	tokens := t.tokens.capped(float64(cost))
+	w, wait, exhausted := t.admit(start, priority, tokens)
+	if exhausted != nil {
+		slog.WarnContext(ctx, "upstream pacing backlog full, rejecting request", "retry_after", wait, "priority", priority)
+		t.rejected(priority)
+		return rateLimited(req, wait, t.limitHeaders(start, exhausted)), nil
+	}
+
+	if w != nil {
+		slog.DebugContext(ctx, "pacing upstream request", "delay", wait, "priority", priority)
+		// Waiters of higher priority may overtake this one beyond the estimate
+		var deadline <-chan time.Time
+		if t.maxWait > 0 {
+			timer := time.NewTimer(t.maxWait)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		select {
+		case <-w.ready:
+		case <-ctx.Done():
+			if !t.withdraw(priority, w) {
+				// Granted concurrently: the request is never sent, so its units are returned
+				t.release(tokens)
+			}
+			return nil, ctx.Err()
+		case <-deadline:
+			if t.withdraw(priority, w) {
+				slog.WarnContext(ctx, "upstream pacing delayed request beyond max wait, rejecting it", "priority", priority)
+				t.rejected(priority)
+				return rateLimited(req, t.maxWait, t.limitHeaders(time.Now(), nil)), nil
+			}
+			// Granted concurrently
+		}
+	}
+	if t.Metrics != nil {
+		t.Metrics.QueueDelay(priority.String(), time.Since(start))
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// admit books a request of priority and cost tokens at now if the buckets have room
+// and nothing of equal or higher priority waits. Otherwise it queues the request and
+// returns its waiter and estimated wait, unless the wait would exceed maxWait; then
+// it returns the wait and the bucket that is exhausted.
+func (t *Transport) admit(now time.Time, priority Priority, tokens float64) (*waiter, time.Duration, *bucket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Units booked before this request can be sent
+	requestsAhead, tokensAhead := 1.0, tokens
+	for p := range priority + 1 {
+		for _, w := range t.waiting[p] {
+			requestsAhead++
+			tokensAhead += w.tokens
+		}
+	}
+
+	requestWait := t.requests.delay(now, requestsAhead)
+	tokenWait := t.tokens.delay(now, tokensAhead)
+	wait := max(requestWait, tokenWait)
+	if wait == 0 {
+		t.requests.book(now, 1)
+		t.tokens.book(now, tokens)
+		return nil, 0, nil
+	}
+	if t.maxWait > 0 && wait > t.maxWait {
+		if requestWait >= tokenWait {
+			return nil, wait, t.requests
+		}
+		return nil, wait, t.tokens
+	}
+
+	w := &waiter{tokens: tokens, ready: make(chan struct{})}
+	t.waiting[priority] = append(t.waiting[priority], w)
+	t.grant(now)
+	return w, wait, nil
+}
+
+// grant books and releases waiters, highest priority first, while the buckets have
+// room, and schedules itself for when the next waiter fits. Caller must hold t.mu.
+func (t *Transport) grant(now time.Time) {
+	for p := range t.waiting {
+		for len(t.waiting[p]) > 0 {
+			w := t.waiting[p][0]
+			if wait := max(t.requests.delay(now, 1), t.tokens.delay(now, w.tokens)); wait > 0 {
+				if t.timer == nil {
+					t.timer = time.AfterFunc(wait, t.dispatch)
+				} else {
+					t.timer.Reset(wait)
+				}
+				return
+			}
+			t.requests.book(now, 1)
+			t.tokens.book(now, w.tokens)
+			t.waiting[p] = t.waiting[p][1:]
+			close(w.ready)
+		}
+	}
+}
+
+// dispatch grants waiters whose time has come.
+func (t *Transport) dispatch() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.grant(time.Now())
+}
+
+// withdraw removes a waiter that gave up. It returns false if the waiter was
+// granted in the meantime; its units stay booked.
+func (t *Transport) withdraw(priority Priority, w *waiter) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := slices.Index(t.waiting[priority], w)
+	if i < 0 {
+		return false
+	}
+	t.waiting[priority] = slices.Delete(t.waiting[priority], i, i+1)
+	t.grant(time.Now())
+	return true
+}
+
+// release returns the units of a granted request that was not sent and lets
+// waiters use them.
+func (t *Transport) release(tokens float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests.unbook(1)
+	t.tokens.unbook(tokens)
+	t.grant(time.Now())
+}
+
+// rejected records a request rejected by pacing.
+func (t *Transport) rejected(priority Priority) {
+	if t.Metrics != nil {
+		t.Metrics.Rejected(priority.String())
+	}
+}
+
+// estimateTokens approximates input tokens from the request body size. The
+// Content-Length is used when known; otherwise the body is buffered and restored
+// for the next transport.
+func estimateTokens(req *http.Request) (int, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 1, nil
+	}
+	if req.ContentLength > 0 {
+		return max(int(req.ContentLength/bytesPerToken), 1), nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return 0, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return max(len(body)/bytesPerToken, 1), nil
+}
+
+// limitHeaders describes the state of the configured buckets in OpenAI's
+// x-ratelimit-{limit,remaining,reset}-{requests,tokens} format. The exhausted
+// bucket reports nothing remaining, since it can't take the rejected request.
+func (t *Transport) limitHeaders(now time.Time, exhausted *bucket) http.Header {
+	header := make(http.Header)
+	for _, b := range []*bucket{t.requests, t.tokens} {
+		if b == nil {
+			continue
+		}
+		remaining, reset := b.state(now)
+		if b == exhausted {
+			remaining = 0
+		}
+		header.Set("X-Ratelimit-Limit-"+b.kind, strconv.Itoa(int(b.perMinute)))
+		header.Set("X-Ratelimit-Remaining-"+b.kind, strconv.Itoa(remaining))
+		header.Set("X-Ratelimit-Reset-"+b.kind, ceilSeconds(reset).String())
+	}
+	return header
+}
+
+// ceilSeconds rounds d up to whole seconds, matching retry-after granularity.
+func ceilSeconds(d time.Duration) time.Duration {
+	return time.Duration(math.Ceil(d.Seconds())) * time.Second
+}
+
+// rateLimited builds a local 429 in Anthropic's error format. header may carry
+// additional rate limit headers.
+func rateLimited(req *http.Request, retryAfter time.Duration, header http.Header) *http.Response {
+	seconds := int(ceilSeconds(retryAfter).Seconds())
+	body := fmt.Sprintf(`{"type":"error","error":{"type":"rate_limit_error","message":"proxy pacing limit reached, retry in %ds"}}`, seconds)
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Type", "application/json")
+	header.Set("Retry-After", strconv.Itoa(seconds))
+	return &http.Response{
+		Status:        "429 Too Many Requests",
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// bucket is a leaky bucket implemented with the generic cell rate algorithm:
+// tat is the theoretical arrival time at which the bucket is empty again.
+type bucket struct {
+	kind      string // limit name in rate limit headers
+	mu        sync.Mutex
+	perMinute float64
+	interval  time.Duration // time to drain one unit
+	burst     time.Duration // tolerated backlog
+	tat       time.Time
+}
+
+func newBucket(perMinute, burst float64) *bucket {
+	interval := time.Duration(float64(time.Minute) / perMinute)
+	return &bucket{perMinute: perMinute, interval: interval, burst: time.Duration(burst * float64(interval))}
+}
+
+// capped limits a single request's cost to the per-minute budget, so oversized
+// requests still pass eventually. A nil bucket leaves n unchanged.
+func (b *bucket) capped(n float64) float64 {
+	if b == nil {
+		return n
+	}
+	return min(n, b.perMinute)
+}
+
+// delay returns how long n more units have to wait at now. A nil bucket is unlimited.
+func (b *bucket) delay(now time.Time, n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tat := b.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	return max(tat.Add(time.Duration(n*float64(b.interval))).Sub(now)-b.burst, 0)
+}
+
+// book adds n units to the bucket at now.
+func (b *bucket) book(now time.Time, n float64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tat.Before(now) {
+		b.tat = now
+	}
+	b.tat = b.tat.Add(time.Duration(n * float64(b.interval)))
+}
+
+// unbook removes n previously booked units from the bucket.
+func (b *bucket) unbook(n float64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tat = b.tat.Add(-time.Duration(n * float64(b.interval)))
+}
+
+// state returns how many units pass without delay at now and how long until the
+// bucket is fully drained.
+func (b *bucket) state(now time.Time) (remaining int, reset time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	backlog := max(b.tat.Sub(now), 0)
+	return int(max(b.burst-backlog, 0) / b.interval), backlog
+}
@@ -0,0 +1,252 @@
+package pacing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransportAdmit(t *testing.T) {
+	now := time.Now()
+	tr := New(nil, Config{RequestsPerMinute: 60, Burst: 2, MaxWait: 1500 * time.Millisecond}) // one per second, burst of two
+
+	tests := []struct {
+		name          string
+		at            time.Duration
+		wantWait      time.Duration
+		wantQueued    bool
+		wantExhausted bool
+	}{
+		{name: "first within burst", at: 0, wantWait: 0},
+		{name: "second within burst", at: 0, wantWait: 0},
+		{name: "third is queued", at: 0, wantWait: time.Second, wantQueued: true},
+		{name: "fourth exceeds max wait", at: 0, wantWait: 2 * time.Second, wantExhausted: true},
+		{name: "drained after idle", at: 10 * time.Second, wantWait: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, wait, exhausted := tr.admit(now.Add(tt.at), Interactive, 1)
+			if wait != tt.wantWait || (w != nil) != tt.wantQueued || (exhausted != nil) != tt.wantExhausted {
+				t.Errorf("admit() = (queued %v, %v, exhausted %v), want (queued %v, %v, exhausted %v)",
+					w != nil, wait, exhausted != nil, tt.wantQueued, tt.wantWait, tt.wantExhausted)
+			}
+		})
+	}
+}
+
+func TestBucketState(t *testing.T) {
+	now := time.Now()
+	b := newBucket(60, 3) // one per second, burst of three
+
+	if remaining, reset := b.state(now); remaining != 3 || reset != 0 {
+		t.Errorf("idle state() = (%d, %v), want (3, 0s)", remaining, reset)
+	}
+	b.book(now, 2)
+	if remaining, reset := b.state(now); remaining != 1 || reset != 2*time.Second {
+		t.Errorf("state() = (%d, %v), want (1, 2s)", remaining, reset)
+	}
+	if remaining, reset := b.state(now.Add(5 * time.Second)); remaining != 3 || reset != 0 {
+		t.Errorf("drained state() = (%d, %v), want (3, 0s)", remaining, reset)
+	}
+}
+
+func TestTransportRejectsBeyondMaxWait(t *testing.T) {
+	var calls int
+	base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	tr := New(base, Config{RequestsPerMinute: 1, MaxWait: time.Millisecond})
+
+	for i, wantStatus := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", strings.NewReader(`{}`))
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if resp.StatusCode != wantStatus {
+			t.Errorf("request %d: status = %d, want %d", i, resp.StatusCode, wantStatus)
+		}
+		if wantStatus == http.StatusTooManyRequests {
+			body, _ := io.ReadAll(resp.Body)
+			if resp.Header.Get("Retry-After") == "" || !strings.Contains(string(body), "rate_limit_error") {
+				t.Errorf("unexpected 429 response: headers=%v body=%s", resp.Header, body)
+			}
+			if resp.Header.Get("X-Ratelimit-Limit-Requests") != "1" || resp.Header.Get("X-Ratelimit-Remaining-Requests") != "0" ||
+				resp.Header.Get("X-Ratelimit-Reset-Requests") != "1m0s" {
+				t.Errorf("unexpected rate limit headers: %v", resp.Header)
+			}
+		}
+	}
+	if calls != 1 {
+		t.Errorf("upstream calls = %d, want 1", calls)
+	}
+}
+
+func TestTransportBuffersOnlyForTokens(t *testing.T) {
+	var got io.Reader
+	base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.Body
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		length     int64
+		wantSame   bool
+		wantTokens int
+	}{
+		{name: "requests only", cfg: Config{RequestsPerMinute: 60}, length: -1, wantSame: true},
+		{name: "tokens with content length", cfg: Config{InputTokensPerMinute: 6000}, length: 400, wantSame: true, wantTokens: 100},
+		{name: "tokens without content length", cfg: Config{InputTokensPerMinute: 6000}, length: -1, wantSame: false, wantTokens: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := io.NopCloser(strings.NewReader(strings.Repeat("x", 400)))
+			req, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", body)
+			req.ContentLength = tt.length
+
+			tr := New(base, tt.cfg)
+			if _, err := tr.RoundTrip(req); err != nil {
+				t.Fatal(err)
+			}
+			if (got == body) != tt.wantSame {
+				t.Errorf("body forwarded unchanged = %v, want %v", got == body, tt.wantSame)
+			}
+			if tt.wantTokens > 0 {
+				remaining, _ := tr.tokens.state(time.Now())
+				burst := int(tr.tokens.burst / tr.tokens.interval)
+				if used := burst - remaining; used < tt.wantTokens-1 || used > tt.wantTokens {
+					t.Errorf("tokens booked = %d, want %d", used, tt.wantTokens)
+				}
+			}
+		})
+	}
+}
+
+func TestTransportReleasesCancelledReservation(t *testing.T) {
+	tr := New(nil, Config{RequestsPerMinute: 60, InputTokensPerMinute: 600})
+	now := time.Now()
+	if w, _, _ := tr.admit(now, Interactive, 5); w != nil {
+		t.Fatal("first request queued, want booked")
+	}
+	tr.release(5)
+
+	if remaining, _ := tr.requests.state(now); remaining != 1 {
+		t.Errorf("requests remaining = %d, want 1", remaining)
+	}
+	if remaining, _ := tr.tokens.state(now); remaining != 10 {
+		t.Errorf("tokens remaining = %d, want 10", remaining)
+	}
+}
+
+type delayRecorder struct {
+	mu     sync.Mutex
+	delays map[string]int
+}
+
+func (r *delayRecorder) QueueDelay(priority string, _ time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delays[priority]++
+}
+func (r *delayRecorder) Rejected(string) {}
+
+func TestTransportPrioritizesInteractive(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, r.Header.Get("X-Test"))
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	recorder := &delayRecorder{delays: make(map[string]int)}
+	tr := New(base, Config{RequestsPerMinute: 600, MaxWait: time.Second}) // One per 100ms
+	tr.Metrics = recorder
+
+	send := func(name string, priority Priority) {
+		req, _ := http.NewRequestWithContext(WithPriority(context.Background(), priority), http.MethodPost, "https://api.anthropic.com/v1/messages", strings.NewReader(`{}`))
+		req.Header.Set("X-Test", name)
+		if _, err := tr.RoundTrip(req); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	send("first", Interactive) // Takes the burst
+	var wg sync.WaitGroup
+	for i, r := range []struct {
+		name     string
+		priority Priority
+	}{{"batch 1", Batch}, {"batch 2", Batch}, {"interactive", Interactive}} {
+		wg.Go(func() { send(r.name, r.priority) })
+		time.Sleep(time.Duration(i+1) * 10 * time.Millisecond) // Queue in order
+	}
+	wg.Wait()
+
+	want := []string{"first", "interactive", "batch 1", "batch 2"}
+	if strings.Join(sent, ",") != strings.Join(want, ",") {
+		t.Errorf("sent %v, want %v", sent, want)
+	}
+	if recorder.delays["interactive"] != 2 || recorder.delays["batch"] != 2 {
+		t.Errorf("queue delays recorded = %v, want 2 per priority", recorder.delays)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestQueueTransportRetriesAfterReset(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCalls  int
+	}{
+		{name: "non-streaming waits and retries", body: `{"model":"m"}`, wantStatus: http.StatusOK, wantCalls: 2},
+		{name: "streaming passes 429 through", body: `{"model":"m","stream":true}`, wantStatus: http.StatusTooManyRequests, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				calls++
+				if calls == 1 {
+					reset := time.Now().Add(50 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+					return &http.Response{
+						StatusCode: http.StatusTooManyRequests,
+						Header: http.Header{
+							"Anthropic-Ratelimit-Requests-Reset":     {reset},
+							"Anthropic-Ratelimit-Requests-Remaining": {"0"},
+						},
+						Body: http.NoBody,
+					}, nil
+				}
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != tt.body {
+					t.Errorf("retried body = %s, want %s", body, tt.body)
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			})
+
+			tr := NewQueue(base, QueueConfig{MaxWait: time.Second})
+			req, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", strings.NewReader(tt.body))
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || calls != tt.wantCalls {
+				t.Errorf("status = %d, calls = %d, want %d, %d", resp.StatusCode, calls, tt.wantStatus, tt.wantCalls)
+			}
+		})
+	}
+}
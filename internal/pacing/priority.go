@@ -0,0 +1,58 @@
+package pacing
+
+import (
+	"context"
+	"fmt"
+)
+
+// Priority orders requests waiting for the pacer. Higher priorities are sent
+// first; requests of the same priority in arrival order.
+type Priority int
+
+const (
+	// Interactive requests have a user waiting for them. This is the default.
+	Interactive Priority = iota
+	// Batch requests tolerate delays and yield to interactive ones.
+	Batch
+
+	numPriorities = iota
+)
+
+// String returns the priority's name, as used in configs and metric labels.
+func (p Priority) String() string {
+	switch p {
+	case Interactive:
+		return "interactive"
+	case Batch:
+		return "batch"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+// ParsePriority parses a priority name. Empty means Interactive.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "", "interactive":
+		return Interactive, nil
+	case "batch":
+		return Batch, nil
+	default:
+		return 0, fmt.Errorf("unknown priority %q (want interactive or batch)", s)
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose upstream requests are paced at priority p.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom returns the priority set by WithPriority, or Interactive.
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= 0 && p < numPriorities {
+		return p
+	}
+	return Interactive
+}
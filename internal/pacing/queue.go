@@ -0,0 +1,191 @@
+package pacing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitBackoff applies when a 429 carries no reset information.
+const defaultRateLimitBackoff = 5 * time.Second
+
+// QueueConfig describes how requests are held back while the upstream is rate limited.
+type QueueConfig struct {
+	// MaxSize bounds the number of waiting requests; further requests get 429 immediately.
+	MaxSize int
+
+	// MaxWait bounds how long a single request waits for the limit window to reset.
+	MaxWait time.Duration
+}
+
+// QueueTransport holds non-streaming requests while the upstream is rate limited.
+//
+// When Anthropic answers 429, the transport remembers the reset time and parks
+// non-streaming requests (including the one that was limited) until then, retrying
+// them afterwards. Streaming requests pass through unchanged since clients expect
+// an immediate response.
+type QueueTransport struct {
+	Base http.RoundTripper
+	cfg  QueueConfig
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+	queued       int
+}
+
+// Compile-time check that QueueTransport implements http.RoundTripper
+var _ http.RoundTripper = (*QueueTransport)(nil)
+
+// NewQueue creates a QueueTransport wrapping base.
+func NewQueue(base http.RoundTripper, cfg QueueConfig) *QueueTransport {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 100
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = time.Minute
+	}
+	return &QueueTransport{Base: base, cfg: cfg}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *QueueTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	body, streaming, err := inspectBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if streaming {
+		return base.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	deadline := time.Now().Add(t.cfg.MaxWait)
+
+	for {
+		if until := t.blocked(); time.Now().Before(until) {
+			if until.After(deadline) {
+				return rateLimited(req, time.Until(until), nil), nil
+			}
+			if !t.enqueue() {
+				slog.WarnContext(ctx, "rate limit queue full, rejecting request")
+				return rateLimited(req, time.Until(until), nil), nil
+			}
+			slog.DebugContext(ctx, "upstream rate limited, queueing request", "until", until)
+
+			timer := time.NewTimer(time.Until(until))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				t.dequeue()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			t.dequeue()
+		}
+
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := base.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		reset := resetTime(resp, time.Now())
+		t.block(reset)
+		if reset.After(deadline) {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
+
+func (t *QueueTransport) blocked() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.blockedUntil
+}
+
+func (t *QueueTransport) block(until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(t.blockedUntil) {
+		t.blockedUntil = until
+	}
+}
+
+func (t *QueueTransport) enqueue() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.queued >= t.cfg.MaxSize {
+		return false
+	}
+	t.queued++
+	return true
+}
+
+func (t *QueueTransport) dequeue() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queued--
+}
+
+// inspectBody buffers the request body for retries and reports whether it requests a stream.
+func inspectBody(req *http.Request) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, false, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Stream bool `json:"stream"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	return body, payload.Stream, nil
+}
+
+// resetTime derives when the rate limit window resets from retry-after or
+// Anthropic's anthropic-ratelimit-*-reset headers (RFC 3339). Exhausted limits
+// (remaining 0) take precedence over limits that still have capacity.
+func resetTime(resp *http.Response, now time.Time) time.Time {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+
+	var exhausted, latest time.Time
+	for _, limit := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+		reset, err := time.Parse(time.RFC3339, resp.Header.Get("Anthropic-Ratelimit-"+limit+"-Reset"))
+		if err != nil {
+			continue
+		}
+		if reset.After(latest) {
+			latest = reset
+		}
+		if resp.Header.Get("Anthropic-Ratelimit-"+limit+"-Remaining") == "0" && reset.After(exhausted) {
+			exhausted = reset
+		}
+	}
+	switch {
+	case exhausted.After(now):
+		return exhausted
+	case latest.After(now):
+		return latest
+	default:
+		return now.Add(defaultRateLimitBackoff)
+	}
+}
@@ -0,0 +1,33 @@
+// Package plugin runs external executables as request/response filters.
+//
+// Each plugin is a long-running child process that exchanges newline-delimited
+// JSON messages over stdin/stdout. The proxy writes one Message per line and
+// expects exactly one Verdict line in reply. Calls are serialized per process,
+// so plugins can be written as a simple read-eval-print loop in any language.
+//
+// WebAssembly modules (see WasmModule) exchange the same messages through an
+// exported memory ABI instead of stdin/stdout and are hot-swapped when the
+// module file changes.
+//
+// # Protocol
+//
+// Request phase (before the request reaches the upstream):
+//
+//	→ {"id":"…","phase":"request","method":"POST","path":"/v1/messages","headers":{…},"body":{…}}
+//	← {"id":"…","action":"continue","headers":{…},"body":{…}}
+//
+// Response phase (buffered JSON responses only; SSE streams are never buffered):
+//
+//	→ {"id":"…","phase":"response","method":"POST","path":"/v1/messages","status":200,"headers":{…},"body":{…}}
+//	← {"id":"…","action":"continue"}
+//
+// Verdict fields are optional: omitted headers/body leave the original untouched.
+// Returning "action":"reject" stops processing and answers the client with the
+// given status (default 403) and message.
+//
+// # Failure Handling
+//
+// A plugin that crashes, writes malformed output or exceeds its timeout is killed
+// and restarted on the next call. By default such failures reject the request
+// with 502; set FailOpen to pass traffic through unmodified instead.
+package plugin
@@ -0,0 +1,217 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+)
+
+// RejectFunc writes a route-specific error response (e.g., OpenAI or Anthropic error shape).
+type RejectFunc func(w http.ResponseWriter, r *http.Request, status int, message string)
+
+// Middleware runs the request phase of all filters before the handler and the
+// response phase on buffered JSON responses after it. Filters execute in order;
+// each sees the modifications of the previous one.
+//
+// Subscribed phases are evaluated per request since hot-swapped filters may change them.
+func Middleware(filters []Filter, reject RejectFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(filters) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, body, readErr := reqbody.Read(r)
+			if readErr != nil {
+				// Let the handler report the read error (e.g., *http.MaxBytesError)
+				// exactly as without plugins.
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+
+			var requestPlugins, responsePlugins []Filter
+			for _, f := range filters {
+				if f.Handles(PhaseRequest) {
+					requestPlugins = append(requestPlugins, f)
+				}
+				if f.Handles(PhaseResponse) {
+					responsePlugins = append(responsePlugins, f)
+				}
+			}
+
+			requestID, _ := ctx.Value(middleware.RequestIDContextKey{}).(string)
+
+			// Non-JSON bodies are left for the handler to reject
+			if len(body) > 0 && !json.Valid(body) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, p := range requestPlugins {
+				verdict, err := p.Call(ctx, &Message{
+					ID:      requestID,
+					Phase:   PhaseRequest,
+					Method:  r.Method,
+					Path:    r.URL.Path,
+					Headers: r.Header,
+					Body:    rawOrNil(body),
+				})
+				if err != nil {
+					if p.FailOpen() {
+						slog.WarnContext(ctx, "plugin failed, continuing", "plugin", p.Name(), "error", err)
+						continue
+					}
+					slog.ErrorContext(ctx, "plugin failed", "plugin", p.Name(), "error", err)
+					reject(w, r, http.StatusBadGateway, "request plugin failed")
+					return
+				}
+				if verdict.Action == ActionReject {
+					slog.InfoContext(ctx, "request rejected by plugin", "plugin", p.Name())
+					reject(w, r, rejectStatus(verdict), rejectMessage(verdict, "request rejected by plugin"))
+					return
+				}
+				if verdict.Headers != nil {
+					r.Header = verdict.Headers
+				}
+				if verdict.Body != nil {
+					body = verdict.Body
+				}
+			}
+
+			reqbody.Replace(r, body)
+
+			if len(responsePlugins) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			buf := &responseBuffer{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+			if buf.passthrough || !buf.wroteHeader {
+				return
+			}
+
+			status, respBody := buf.status, buf.buf.Bytes()
+			if json.Valid(respBody) {
+				for _, p := range responsePlugins {
+					verdict, err := p.Call(ctx, &Message{
+						ID:      requestID,
+						Phase:   PhaseResponse,
+						Method:  r.Method,
+						Path:    r.URL.Path,
+						Status:  status,
+						Headers: w.Header(),
+						Body:    rawOrNil(respBody),
+					})
+					if err != nil {
+						if p.FailOpen() {
+							slog.WarnContext(ctx, "plugin failed, continuing", "plugin", p.Name(), "error", err)
+							continue
+						}
+						slog.ErrorContext(ctx, "plugin failed", "plugin", p.Name(), "error", err)
+						reject(w, r, http.StatusBadGateway, "response plugin failed")
+						return
+					}
+					if verdict.Action == ActionReject {
+						slog.InfoContext(ctx, "response rejected by plugin", "plugin", p.Name())
+						reject(w, r, rejectStatus(verdict), rejectMessage(verdict, "response rejected by plugin"))
+						return
+					}
+					if verdict.Headers != nil {
+						clear(w.Header())
+						for k, v := range verdict.Headers {
+							w.Header()[k] = v
+						}
+					}
+					if verdict.Body != nil {
+						respBody = verdict.Body
+					}
+					if verdict.Status != 0 {
+						status = verdict.Status
+					}
+				}
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(len(respBody)))
+			w.WriteHeader(status)
+			if _, err := w.Write(respBody); err != nil {
+				slog.ErrorContext(ctx, "failed to write response", "error", err)
+			}
+		})
+	}
+}
+
+// rawOrNil returns nil for empty bodies so they are omitted from plugin messages.
+func rawOrNil(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
+func rejectStatus(v *Verdict) int {
+	if v.Status >= 400 && v.Status <= 599 {
+		return v.Status
+	}
+	return http.StatusForbidden
+}
+
+func rejectMessage(v *Verdict, fallback string) string {
+	if v.Message != "" {
+		return v.Message
+	}
+	return fallback
+}
+
+// responseBuffer holds back JSON responses for the response phase.
+// Any other content type (notably text/event-stream) is passed through unbuffered.
+type responseBuffer struct {
+	http.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	wroteHeader bool
+	passthrough bool
+}
+
+func (b *responseBuffer) WriteHeader(code int) {
+	if b.wroteHeader {
+		return
+	}
+	b.wroteHeader = true
+	b.status = code
+
+	mediaType, _, _ := mime.ParseMediaType(b.Header().Get("Content-Type"))
+	if mediaType != "application/json" {
+		b.passthrough = true
+		b.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if !b.wroteHeader {
+		b.WriteHeader(http.StatusOK)
+	}
+	if b.passthrough {
+		return b.ResponseWriter.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+// Flush implements http.Flusher for streaming handlers; buffered responses are not flushed.
+func (b *responseBuffer) Flush() {
+	if b.passthrough {
+		_ = http.NewResponseController(b.ResponseWriter).Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (b *responseBuffer) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
@@ -0,0 +1,260 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Phase identifies the point in the request lifecycle a plugin is invoked at.
+type Phase string
+
+const (
+	PhaseRequest  Phase = "request"
+	PhaseResponse Phase = "response"
+)
+
+// Action is the decision a plugin returns for a message.
+type Action string
+
+const (
+	ActionContinue Action = "continue"
+	ActionReject   Action = "reject"
+)
+
+// maxLineSize bounds a single protocol line (request bodies are capped upstream at 32MB).
+const maxLineSize = 64 << 20
+
+// Message is sent to a plugin for every request or response it subscribed to.
+type Message struct {
+	ID      string          `json:"id"`
+	Phase   Phase           `json:"phase"`
+	Method  string          `json:"method"`
+	Path    string          `json:"path"`
+	Status  int             `json:"status,omitempty"`
+	Headers http.Header     `json:"headers"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+// Verdict is a plugin's reply to a Message.
+type Verdict struct {
+	ID      string          `json:"id"`
+	Action  Action          `json:"action"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+	Status  int             `json:"status,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// Filter is a request/response filter speaking the plugin protocol.
+// Implemented by Process (subprocess) and WasmModule (WebAssembly).
+type Filter interface {
+	// Name identifies the filter in logs.
+	Name() string
+
+	// Handles reports whether the filter subscribed to the given phase.
+	Handles(phase Phase) bool
+
+	// FailOpen reports whether traffic passes through unmodified when the filter fails.
+	FailOpen() bool
+
+	// Call sends msg to the filter and returns its verdict.
+	Call(ctx context.Context, msg *Message) (*Verdict, error)
+
+	// Close releases all resources held by the filter.
+	Close() error
+}
+
+// Config describes how to launch a plugin executable.
+type Config struct {
+	Name     string
+	Command  string
+	Args     []string
+	Phases   []Phase
+	Timeout  time.Duration
+	FailOpen bool
+}
+
+// Process manages a single plugin child process.
+// Calls are serialized; the process is started lazily and restarted after failures.
+type Process struct {
+	cfg Config
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+}
+
+// Compile-time check that Process implements Filter
+var _ Filter = (*Process)(nil)
+
+// New creates a Process for the given configuration. No process is started
+// until the first call.
+func New(cfg Config) (*Process, error) {
+	if cfg.Command == "" {
+		return nil, errors.New("plugin command cannot be empty")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Command
+	}
+	if len(cfg.Phases) == 0 {
+		cfg.Phases = []Phase{PhaseRequest}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &Process{cfg: cfg}, nil
+}
+
+// Name returns the configured plugin name.
+func (p *Process) Name() string {
+	return p.cfg.Name
+}
+
+// Handles reports whether the plugin subscribed to the given phase.
+func (p *Process) Handles(phase Phase) bool {
+	return slices.Contains(p.cfg.Phases, phase)
+}
+
+// FailOpen reports whether failures of this plugin let traffic pass through.
+func (p *Process) FailOpen() bool {
+	return p.cfg.FailOpen
+}
+
+// Call sends msg to the plugin and waits for its verdict.
+// On any protocol failure the process is killed so the next call starts fresh.
+func (p *Process) Call(ctx context.Context, msg *Message) (*Verdict, error) {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal plugin message: %w", err)
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	type result struct {
+		verdict *Verdict
+		err     error
+	}
+	resultCh := make(chan result, 1)
+
+	// The goroutine only touches these locals; stopLocked clears the fields
+	stdin, stdout := p.stdin, p.stdout
+	go func() {
+		if _, err := stdin.Write(line); err != nil {
+			resultCh <- result{err: fmt.Errorf("write to plugin: %w", err)}
+			return
+		}
+		if !stdout.Scan() {
+			err := stdout.Err()
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			resultCh <- result{err: fmt.Errorf("read from plugin: %w", err)}
+			return
+		}
+		var v Verdict
+		if err := json.Unmarshal(stdout.Bytes(), &v); err != nil {
+			resultCh <- result{err: fmt.Errorf("decode plugin verdict: %w", err)}
+			return
+		}
+		resultCh <- result{verdict: &v}
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Killing the process unblocks the pending read/write goroutine;
+		// fields are cleared only once it has returned
+		p.killLocked()
+		<-resultCh
+		p.stopLocked()
+		return nil, fmt.Errorf("plugin %s: %w", p.cfg.Name, ctx.Err())
+	case res := <-resultCh:
+		if res.err != nil {
+			p.stopLocked()
+			return nil, fmt.Errorf("plugin %s: %w", p.cfg.Name, res.err)
+		}
+		if res.verdict.ID != msg.ID {
+			p.stopLocked()
+			return nil, fmt.Errorf("plugin %s: verdict id %q does not match message id %q", p.cfg.Name, res.verdict.ID, msg.ID)
+		}
+		return res.verdict, nil
+	}
+}
+
+// Close terminates the plugin process if it is running.
+func (p *Process) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopLocked()
+	return nil
+}
+
+// start launches the child process. Caller must hold p.mu.
+func (p *Process) start() error {
+	// Not bound to a request context: the process outlives individual requests
+	cmd := exec.Command(p.cfg.Command, p.cfg.Args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("plugin %s: stdin pipe: %w", p.cfg.Name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("plugin %s: stdout pipe: %w", p.cfg.Name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("plugin %s: start: %w", p.cfg.Name, err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	p.cmd = cmd
+	p.stdin = stdin
+	p.stdout = scanner
+	return nil
+}
+
+// killLocked closes stdin and kills the child process without reaping it or
+// clearing its fields. Caller must hold p.mu.
+func (p *Process) killLocked() {
+	if p.cmd == nil {
+		return
+	}
+	_ = p.stdin.Close()
+	_ = p.cmd.Process.Kill()
+}
+
+// stopLocked kills the child process and reaps it. Caller must hold p.mu.
+func (p *Process) stopLocked() {
+	if p.cmd == nil {
+		return
+	}
+	p.killLocked()
+	_ = p.cmd.Wait()
+	p.cmd = nil
+	p.stdin = nil
+	p.stdout = nil
+}
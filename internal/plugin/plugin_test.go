@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary act as a plugin when PLUGIN_HELPER is set.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("PLUGIN_HELPER"); mode != "" {
+		runHelper(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runHelper implements a plugin that tags, rejects or hangs depending on mode.
+func runHelper(mode string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			os.Exit(1)
+		}
+		v := Verdict{ID: msg.ID, Action: ActionContinue}
+		switch mode {
+		case "tag":
+			v.Body = json.RawMessage(fmt.Sprintf(`{"phase":%q,"original":%s}`, msg.Phase, msg.Body))
+		case "reject":
+			v.Action = ActionReject
+			v.Status = http.StatusTeapot
+			v.Message = "nope"
+		case "hang":
+			time.Sleep(time.Minute)
+		}
+		out, _ := json.Marshal(v)
+		fmt.Println(string(out))
+	}
+}
+
+func newHelper(t *testing.T, mode string, cfg Config) *Process {
+	t.Helper()
+	t.Setenv("PLUGIN_HELPER", mode)
+	cfg.Command = os.Args[0]
+	cfg.Args = []string{"-test.run=^$"}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func rejectWriter(w http.ResponseWriter, _ *http.Request, status int, message string) {
+	http.Error(w, message, status)
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		cfg        Config
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "request phase rewrites body",
+			mode:       "tag",
+			cfg:        Config{Phases: []Phase{PhaseRequest}},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"phase":"request","original":{"model":"x"}}`,
+		},
+		{
+			name:       "response phase rewrites body",
+			mode:       "tag",
+			cfg:        Config{Phases: []Phase{PhaseResponse}},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"phase":"response","original":{"model":"x"}}`,
+		},
+		{
+			name:       "reject uses plugin status",
+			mode:       "reject",
+			cfg:        Config{},
+			wantStatus: http.StatusTeapot,
+			wantBody:   "nope\n",
+		},
+		{
+			name:       "timeout fails closed",
+			mode:       "hang",
+			cfg:        Config{Timeout: 100 * time.Millisecond},
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "request plugin failed\n",
+		},
+		{
+			name:       "timeout fails open",
+			mode:       "hang",
+			cfg:        Config{Timeout: 100 * time.Millisecond, FailOpen: true},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"model":"x"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newHelper(t, tt.mode, tt.cfg)
+
+			// Echo handler returns the (possibly rewritten) request body as JSON
+			echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write(body)
+			})
+
+			handler := Middleware([]Filter{p}, rejectWriter)(echo)
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"x"}`))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestMiddlewareStreamingPassthrough(t *testing.T) {
+	p := newHelper(t, "tag", Config{Phases: []Phase{PhaseResponse}})
+
+	sse := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {}\n\n"))
+		w.(http.Flusher).Flush()
+	})
+
+	handler := Middleware([]Filter{p}, rejectWriter)(sse)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{}`)))
+
+	if got := rec.Body.String(); got != "data: {}\n\n" {
+		t.Errorf("body = %q, want unmodified stream", got)
+	}
+	if !rec.Flushed {
+		t.Error("expected stream to be flushed")
+	}
+}
@@ -0,0 +1,269 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WebAssembly ABI exported by filter modules.
+//
+// Modules exchange the same JSON Message/Verdict documents as subprocess plugins:
+//   - memory:                    linear memory shared with the host
+//   - alloc(len i32) i32:        allocates len bytes and returns the pointer
+//   - on_request(ptr, len) i64:  request phase, optional
+//   - on_response(ptr, len) i64: response phase, optional
+//
+// Phase functions receive the Message JSON at ptr and return the Verdict JSON
+// location packed as (ptr << 32 | len). WASI preview1 imports are available so
+// common toolchains (TinyGo, Rust wasm32-wasip1, Go wasip1) work out of the box.
+const (
+	wasmExportAlloc    = "alloc"
+	wasmExportRequest  = "on_request"
+	wasmExportResponse = "on_response"
+)
+
+// wasmReloadInterval throttles modification checks of the module file.
+const wasmReloadInterval = time.Second
+
+// WasmConfig describes a WebAssembly filter module.
+type WasmConfig struct {
+	Name     string
+	Path     string
+	Timeout  time.Duration
+	FailOpen bool
+
+	// MaxMemoryPages caps linear memory in 64KiB pages (0 uses the runtime default of 4GiB).
+	MaxMemoryPages uint32
+}
+
+// WasmModule runs a WebAssembly module as request/response filter.
+//
+// Each call executes in a fresh module instance, isolating requests from each
+// other. The module file is recompiled when it changes on disk, allowing filters
+// to be hot-swapped without restarting the proxy.
+type WasmModule struct {
+	cfg     WasmConfig
+	runtime wazero.Runtime
+
+	// mu guards the compiled module: calls hold a read lock, reloads a write lock.
+	mu       sync.RWMutex
+	compiled wazero.CompiledModule
+	phases   map[Phase]string
+	modTime  time.Time
+
+	checkMu   sync.Mutex
+	lastCheck time.Time
+}
+
+// Compile-time check that WasmModule implements Filter
+var _ Filter = (*WasmModule)(nil)
+
+// NewWasm compiles the module at cfg.Path. Compilation errors are returned immediately.
+func NewWasm(ctx context.Context, cfg WasmConfig) (*WasmModule, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("wasm module path cannot be empty")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Path
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	runtimeCfg := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if cfg.MaxMemoryPages > 0 {
+		runtimeCfg = runtimeCfg.WithMemoryLimitPages(cfg.MaxMemoryPages)
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, runtimeCfg)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("wasm filter %s: instantiate WASI: %w", cfg.Name, err)
+	}
+
+	m := &WasmModule{cfg: cfg, runtime: r}
+	if err := m.load(ctx); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+// Name returns the configured filter name.
+func (m *WasmModule) Name() string {
+	return m.cfg.Name
+}
+
+// Handles reports whether the module exports a function for the given phase.
+func (m *WasmModule) Handles(phase Phase) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.phases[phase]
+	return ok
+}
+
+// FailOpen reports whether failures of this filter let traffic pass through.
+func (m *WasmModule) FailOpen() bool {
+	return m.cfg.FailOpen
+}
+
+// Call runs the phase function of a fresh module instance with msg as input.
+func (m *WasmModule) Call(ctx context.Context, msg *Message) (*Verdict, error) {
+	m.reloadIfChanged(ctx)
+
+	input, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal plugin message: %w", err)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	export, ok := m.phases[msg.Phase]
+	if !ok {
+		return &Verdict{ID: msg.ID, Action: ActionContinue}, nil
+	}
+
+	// WithCloseOnContextDone terminates runaway modules when the deadline passes
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().
+		WithName(""). // anonymous: allows concurrent instances
+		WithStartFunctions("_initialize").
+		WithStderr(os.Stderr))
+	if err != nil {
+		return nil, fmt.Errorf("wasm filter %s: instantiate: %w", m.cfg.Name, err)
+	}
+	defer func() { _ = mod.Close(context.Background()) }()
+
+	output, err := callWasm(ctx, mod, export, input)
+	if err != nil {
+		return nil, fmt.Errorf("wasm filter %s: %w", m.cfg.Name, err)
+	}
+
+	var v Verdict
+	if err := json.Unmarshal(output, &v); err != nil {
+		return nil, fmt.Errorf("wasm filter %s: decode verdict: %w", m.cfg.Name, err)
+	}
+	if v.ID == "" {
+		v.ID = msg.ID
+	}
+	return &v, nil
+}
+
+// Close releases the runtime and all compiled modules.
+func (m *WasmModule) Close() error {
+	return m.runtime.Close(context.Background())
+}
+
+// callWasm copies input into module memory, invokes export and returns a copy of the output.
+func callWasm(ctx context.Context, mod api.Module, export string, input []byte) ([]byte, error) {
+	alloc := mod.ExportedFunction(wasmExportAlloc)
+	fn := mod.ExportedFunction(export)
+	if alloc == nil || fn == nil {
+		return nil, fmt.Errorf("missing export %s or %s", wasmExportAlloc, export)
+	}
+
+	res, err := alloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("alloc: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !mod.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("write input: out of bounds")
+	}
+
+	res, err = fn.Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", export, err)
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+
+	out, ok := mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("read output: out of bounds")
+	}
+	// Read returns a view into module memory which is released on Close
+	return append([]byte(nil), out...), nil
+}
+
+// load compiles the module file and swaps it in. Old compiled modules are released.
+func (m *WasmModule) load(ctx context.Context) error {
+	info, err := os.Stat(m.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("wasm filter %s: %w", m.cfg.Name, err)
+	}
+	code, err := os.ReadFile(m.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("wasm filter %s: %w", m.cfg.Name, err)
+	}
+
+	compiled, err := m.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return fmt.Errorf("wasm filter %s: compile: %w", m.cfg.Name, err)
+	}
+
+	exports := compiled.ExportedFunctions()
+	if _, ok := exports[wasmExportAlloc]; !ok {
+		_ = compiled.Close(ctx)
+		return fmt.Errorf("wasm filter %s: module does not export %q", m.cfg.Name, wasmExportAlloc)
+	}
+	phases := make(map[Phase]string, 2)
+	if _, ok := exports[wasmExportRequest]; ok {
+		phases[PhaseRequest] = wasmExportRequest
+	}
+	if _, ok := exports[wasmExportResponse]; ok {
+		phases[PhaseResponse] = wasmExportResponse
+	}
+
+	m.mu.Lock()
+	old := m.compiled
+	m.compiled = compiled
+	m.phases = phases
+	m.modTime = info.ModTime()
+	m.mu.Unlock()
+
+	if old != nil {
+		_ = old.Close(ctx)
+	}
+	return nil
+}
+
+// reloadIfChanged recompiles the module when its file was modified.
+// Reload failures keep the previous module active.
+func (m *WasmModule) reloadIfChanged(ctx context.Context) {
+	m.checkMu.Lock()
+	if time.Since(m.lastCheck) < wasmReloadInterval {
+		m.checkMu.Unlock()
+		return
+	}
+	m.lastCheck = time.Now()
+	m.checkMu.Unlock()
+
+	info, err := os.Stat(m.cfg.Path)
+	if err != nil {
+		return
+	}
+	m.mu.RLock()
+	changed := !info.ModTime().Equal(m.modTime)
+	m.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := m.load(ctx); err != nil {
+		slog.ErrorContext(ctx, "wasm filter reload failed, keeping previous module", "plugin", m.cfg.Name, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "wasm filter reloaded", "plugin", m.cfg.Name)
+}
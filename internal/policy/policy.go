@@ -0,0 +1,277 @@
+// Package policy restricts what each client key may request: which models,
+// how many output tokens and how large a reasoning budget. It also sets the
+// priority the key's requests are paced at.
+//
+// Keys are the credentials clients send to the proxy (x-api-key, api-key or
+// Authorization bearer). A policy matches a key either verbatim or by its
+// usage key ID ("key_…"), so configs can avoid storing raw secrets. The key
+// "*" applies to every key without a policy of its own; keys matching no
+// policy are unrestricted.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/florianilch/claudine-proxy/internal/pacing"
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// Wildcard is the key matching clients without a policy of their own.
+const Wildcard = "*"
+
+// Dialect selects the request body shape the middleware enforces against.
+type Dialect int
+
+const (
+	Anthropic Dialect = iota // Messages API
+	OpenAI                   // chat completions
+)
+
+// Policy limits the requests of its keys.
+type Policy struct {
+	Name string
+	Keys []string
+
+	// Models are path.Match patterns (e.g. "claude-sonnet-*"). Empty allows all models.
+	Models []string
+
+	// MaxTokens caps max_tokens / max_completion_tokens. Zero means no cap.
+	MaxTokens int
+
+	// MaxReasoningBudget caps the thinking budget. Zero means no cap.
+	MaxReasoningBudget int
+
+	// Priority orders the key's requests while upstream pacing delays requests.
+	Priority pacing.Priority
+}
+
+// Enforcer holds policies indexed by key.
+type Enforcer struct {
+	policies map[string]*Policy
+
+	// ReasoningBudget maps an OpenAI reasoning_effort to the thinking budget the
+	// adapter requests for model. Nil uses the adapter's default mapping.
+	ReasoningBudget func(model, effort string) int
+}
+
+// New validates policies and creates an Enforcer.
+func New(policies []Policy) (*Enforcer, error) {
+	e := &Enforcer{policies: make(map[string]*Policy)}
+	for i := range policies {
+		p := policies[i]
+		if len(p.Keys) == 0 {
+			return nil, fmt.Errorf("policy %s: at least one key required", p.Name)
+		}
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if p.MaxTokens < 0 || p.MaxReasoningBudget < 0 {
+			return nil, fmt.Errorf("policy %s: limits cannot be negative", p.Name)
+		}
+		for _, pattern := range p.Models {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("policy %s: invalid model pattern %q: %w", p.Name, pattern, err)
+			}
+		}
+		for _, key := range p.Keys {
+			if key == "" {
+				return nil, fmt.Errorf("policy %s: key cannot be empty", p.Name)
+			}
+			if other, exists := e.policies[key]; exists {
+				return nil, fmt.Errorf("policy %s: key already covered by policy %s", p.Name, other.Name)
+			}
+			e.policies[key] = &p
+		}
+	}
+	return e, nil
+}
+
+// For returns the policy of the request's client key, or nil if unrestricted.
+func (e *Enforcer) For(r *http.Request) *Policy {
+	if key := usage.Credential(r); key != "" {
+		if p, ok := e.policies[key]; ok {
+			return p
+		}
+		if p, ok := e.policies[usage.KeyID(r)]; ok {
+			return p
+		}
+	}
+	return e.policies[Wildcard]
+}
+
+// AllowsModel reports whether model matches one of the policy's patterns.
+func (p *Policy) AllowsModel(model string) bool {
+	if len(p.Models) == 0 {
+		return true
+	}
+	for _, pattern := range p.Models {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects requests violating the client key's policy via reject.
+// OpenAI requests without a token limit are capped at the policy's MaxTokens
+// instead of falling back to the adapter default.
+func Middleware(enforcer *Enforcer, dialect Dialect, reject func(w http.ResponseWriter, r *http.Request, status int, message string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if enforcer == nil || len(enforcer.policies) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := enforcer.For(r)
+			if p == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if p.Priority != pacing.Interactive {
+				r = r.WithContext(pacing.WithPriority(r.Context(), p.Priority))
+			}
+
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(body, &fields) != nil {
+				// Malformed bodies are rejected by the handler
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if msg := p.check(dialect, fields, enforcer.effortBudget); msg != "" {
+				reject(w, r, http.StatusForbidden, msg)
+				return
+			}
+
+			if capped, ok := p.capTokens(dialect, fields); ok {
+				reqbody.Replace(r, capped)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check returns a description of the first violation, or "" if the request is allowed.
+func (p *Policy) check(dialect Dialect, fields map[string]json.RawMessage, effortBudget func(model, effort string) int) string {
+	var model string
+	_ = json.Unmarshal(fields["model"], &model)
+	if !p.AllowsModel(model) {
+		return fmt.Sprintf("model %q is not allowed for this API key (allowed: %s)", model, strings.Join(p.Models, ", "))
+	}
+
+	if p.MaxTokens > 0 {
+		for _, field := range tokenFields(dialect) {
+			var n int
+			if json.Unmarshal(fields[field], &n) == nil && n > p.MaxTokens {
+				return fmt.Sprintf("%s of %d exceeds the limit of %d for this API key", field, n, p.MaxTokens)
+			}
+		}
+	}
+
+	if p.MaxReasoningBudget > 0 {
+		if budget, field := reasoningBudget(dialect, fields, model, effortBudget); budget > p.MaxReasoningBudget {
+			return fmt.Sprintf("reasoning budget of %d tokens (%s) exceeds the limit of %d for this API key", budget, field, p.MaxReasoningBudget)
+		}
+	}
+	return ""
+}
+
+// capTokens sets max_completion_tokens on OpenAI requests that carry no token limit.
+func (p *Policy) capTokens(dialect Dialect, fields map[string]json.RawMessage) ([]byte, bool) {
+	if dialect != OpenAI || p.MaxTokens == 0 {
+		return nil, false
+	}
+	for _, field := range tokenFields(dialect) {
+		if raw, ok := fields[field]; ok && string(raw) != "null" {
+			return nil, false
+		}
+	}
+	fields["max_completion_tokens"] = json.RawMessage(strconv.Itoa(p.MaxTokens))
+	capped, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return capped, true
+}
+
+// tokenFields lists the output token limit fields of a dialect.
+func tokenFields(dialect Dialect) []string {
+	if dialect == OpenAI {
+		return []string{"max_completion_tokens", "max_tokens"}
+	}
+	return []string{"max_tokens"}
+}
+
+// reasoningBudgets mirrors the adapter's default reasoning_effort mapping.
+var reasoningBudgets = map[string]int{
+	"low":    1024,
+	"medium": 8192,
+	"high":   24576,
+}
+
+// effortBudget returns the thinking budget of a reasoning_effort for model.
+func (e *Enforcer) effortBudget(model, effort string) int {
+	if e.ReasoningBudget != nil {
+		return e.ReasoningBudget(model, effort)
+	}
+	return reasoningBudgets[effort]
+}
+
+// reasoningBudget returns the requested thinking budget and the field it came from.
+// For OpenAI, extra_body.thinking.budget_tokens overrides reasoning_effort as in the adapter.
+func reasoningBudget(dialect Dialect, fields map[string]json.RawMessage, model string, effortBudget func(model, effort string) int) (int, string) {
+	type thinking struct {
+		Type         string          `json:"type"`
+		BudgetTokens json.RawMessage `json:"budget_tokens"`
+	}
+
+	if dialect == Anthropic {
+		var t thinking
+		if json.Unmarshal(fields["thinking"], &t) == nil && t.Type == "enabled" {
+			return parseBudget(t.BudgetTokens), "thinking.budget_tokens"
+		}
+		return 0, ""
+	}
+
+	var extra struct {
+		Thinking thinking `json:"thinking"`
+	}
+	if json.Unmarshal(fields["extra_body"], &extra) == nil && extra.Thinking.Type == "enabled" {
+		if budget := parseBudget(extra.Thinking.BudgetTokens); budget > 0 {
+			return budget, "extra_body.thinking.budget_tokens"
+		}
+	}
+	var effort string
+	if json.Unmarshal(fields["reasoning_effort"], &effort) == nil {
+		return effortBudget(model, effort), "reasoning_effort " + effort
+	}
+	return 0, ""
+}
+
+// parseBudget accepts budget_tokens as number or numeric string, like the adapter.
+func parseBudget(raw json.RawMessage) int {
+	var n float64
+	if json.Unmarshal(raw, &n) == nil {
+		return int(n)
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if v, err := strconv.Atoi(s); err == nil {
+			return v
+		}
+	}
+	return 0
+}
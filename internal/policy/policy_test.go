@@ -0,0 +1,141 @@
+package policy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/pacing"
+)
+
+func TestNewValidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []Policy
+		wantErr  string
+	}{
+		{"no keys", []Policy{{Name: "p"}}, "at least one key"},
+		{"bad pattern", []Policy{{Name: "p", Keys: []string{"k"}, Models: []string{"["}}}, "invalid model pattern"},
+		{"duplicate key", []Policy{{Name: "a", Keys: []string{"k"}}, {Name: "b", Keys: []string{"k"}}}, "already covered by policy a"},
+		{"negative limit", []Policy{{Name: "p", Keys: []string{"k"}, MaxTokens: -1}}, "cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.policies)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	enforcer, err := New([]Policy{
+		{
+			Name:               "team",
+			Keys:               []string{"sk-team", "key_c291001835042e21"}, // key ID of "sk-hashed"
+			Models:             []string{"claude-haiku-*", "claude-sonnet-4-5"},
+			MaxTokens:          4096,
+			MaxReasoningBudget: 8192,
+		},
+		{Name: "default", Keys: []string{Wildcard}, Models: []string{"claude-haiku-*"}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	enforcer.ReasoningBudget = func(model, effort string) int {
+		if model == "claude-sonnet-4-5" && effort == "low" {
+			return 10000
+		}
+		return reasoningBudgets[effort]
+	}
+
+	tests := []struct {
+		name       string
+		dialect    Dialect
+		key        string
+		body       string
+		wantStatus int
+		wantMsg    string
+		wantMax    int
+	}{
+		{"allowed model", OpenAI, "sk-team", `{"model":"claude-sonnet-4-5","max_tokens":100}`, http.StatusOK, "", 0},
+		{"glob model", Anthropic, "sk-team", `{"model":"claude-haiku-4-5","max_tokens":100}`, http.StatusOK, "", 0},
+		{"denied model", OpenAI, "sk-team", `{"model":"claude-opus-4-1"}`, http.StatusForbidden, `model "claude-opus-4-1" is not allowed`, 0},
+		{"key id match", Anthropic, "sk-hashed", `{"model":"claude-sonnet-4-5","max_tokens":100}`, http.StatusOK, "", 0},
+		{"wildcard policy", OpenAI, "sk-other", `{"model":"claude-sonnet-4-5"}`, http.StatusForbidden, "not allowed", 0},
+		{"no key uses wildcard", Anthropic, "", `{"model":"claude-haiku-4-5","max_tokens":100000}`, http.StatusOK, "", 0},
+		{"max tokens exceeded", Anthropic, "sk-team", `{"model":"claude-haiku-4-5","max_tokens":8192}`, http.StatusForbidden, "max_tokens of 8192 exceeds the limit of 4096", 0},
+		{"max completion tokens exceeded", OpenAI, "sk-team", `{"model":"claude-haiku-4-5","max_completion_tokens":5000}`, http.StatusForbidden, "max_completion_tokens of 5000", 0},
+		{"unset max tokens capped", OpenAI, "sk-team", `{"model":"claude-haiku-4-5"}`, http.StatusOK, "", 4096},
+		{"reasoning effort exceeded", OpenAI, "sk-team", `{"model":"claude-haiku-4-5","reasoning_effort":"high"}`, http.StatusForbidden, "reasoning budget of 24576 tokens (reasoning_effort high)", 0},
+		{"reasoning effort allowed", OpenAI, "sk-team", `{"model":"claude-haiku-4-5","reasoning_effort":"medium","max_tokens":100}`, http.StatusOK, "", 0},
+		{"configured reasoning budget exceeded", OpenAI, "sk-team", `{"model":"claude-sonnet-4-5","reasoning_effort":"low"}`, http.StatusForbidden, "reasoning budget of 10000 tokens (reasoning_effort low)", 0},
+		{"minimal reasoning effort", OpenAI, "sk-team", `{"model":"claude-haiku-4-5","reasoning_effort":"minimal","max_tokens":100}`, http.StatusOK, "", 0},
+		{"extra body budget exceeded", OpenAI, "sk-team", `{"model":"claude-haiku-4-5","reasoning_effort":"low","extra_body":{"thinking":{"type":"enabled","budget_tokens":"16000"}}}`, http.StatusForbidden, "extra_body.thinking.budget_tokens", 0},
+		{"native thinking exceeded", Anthropic, "sk-team", `{"model":"claude-haiku-4-5","max_tokens":100,"thinking":{"type":"enabled","budget_tokens":10000}}`, http.StatusForbidden, "thinking.budget_tokens", 0},
+		{"native thinking disabled", Anthropic, "sk-team", `{"model":"claude-haiku-4-5","max_tokens":100,"thinking":{"type":"disabled"}}`, http.StatusOK, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			handler := Middleware(enforcer, tt.dialect, func(w http.ResponseWriter, _ *http.Request, status int, message string) {
+				http.Error(w, message, status)
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.ContentLength != int64(len(body)) {
+					t.Errorf("ContentLength = %d, body is %d bytes", r.ContentLength, len(body))
+				}
+				if err := json.Unmarshal(body, &got); err != nil {
+					t.Errorf("handler got invalid body: %v", err)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantMsg) {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantMsg)
+			}
+			if tt.wantMax > 0 {
+				if n, _ := got["max_completion_tokens"].(float64); int(n) != tt.wantMax {
+					t.Errorf("max_completion_tokens = %v, want %d", got["max_completion_tokens"], tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewarePriority(t *testing.T) {
+	enforcer, err := New([]Policy{
+		{Name: "batch", Keys: []string{"sk-batch"}, Priority: pacing.Batch},
+		{Name: "default", Keys: []string{Wildcard}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]pacing.Priority{"sk-batch": pacing.Batch, "sk-other": pacing.Interactive} {
+		var got pacing.Priority
+		handler := Middleware(enforcer, Anthropic, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = pacing.PriorityFrom(r.Context())
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-haiku-4-5"}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != want {
+			t.Errorf("%s: priority = %v, want %v", key, got, want)
+		}
+	}
+}
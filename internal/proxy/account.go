@@ -0,0 +1,219 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// profilePath is the OAuth profile endpoint, relative to the upstream host.
+	profilePath = "/api/oauth/profile"
+
+	// profileTTL bounds how long account details are reused before they are fetched again.
+	profileTTL = 5 * time.Minute
+
+	// rateLimitHeaderPrefix matches Anthropic's anthropic-ratelimit-* response headers (canonicalized).
+	rateLimitHeaderPrefix = "Anthropic-Ratelimit-"
+)
+
+// Account describes the subscription the proxy spends, as returned by GET /v1/me.
+type Account struct {
+	Account      AccountUser         `json:"account"`
+	Organization AccountOrganization `json:"organization"`
+	Plan         string              `json:"plan,omitempty"`
+	RateLimits   *RateLimits         `json:"rate_limits"`
+}
+
+// AccountUser identifies the OAuth user.
+type AccountUser struct {
+	UUID        string `json:"uuid,omitempty"`
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// AccountOrganization identifies the organization the subscription belongs to.
+type AccountOrganization struct {
+	UUID          string `json:"uuid,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Type          string `json:"type,omitempty"`
+	RateLimitTier string `json:"rate_limit_tier,omitempty"`
+}
+
+// RateLimits is the most recent rate limit state reported by the upstream.
+// Headers holds anthropic-ratelimit-* values keyed by their lowercase suffix
+// (e.g., "requests-remaining", "unified-status").
+type RateLimits struct {
+	UpdatedAt time.Time         `json:"updated_at"`
+	Headers   map[string]string `json:"headers"`
+}
+
+// rateLimitRecorder is an http.RoundTripper that remembers the rate limit
+// headers of the latest upstream response.
+type rateLimitRecorder struct {
+	Base http.RoundTripper
+
+	mu     sync.Mutex
+	latest *RateLimits
+}
+
+// Compile-time check that rateLimitRecorder implements http.RoundTripper.
+var _ http.RoundTripper = (*rateLimitRecorder)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *rateLimitRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	headers := make(map[string]string)
+	for key, values := range resp.Header {
+		if suffix, ok := strings.CutPrefix(key, rateLimitHeaderPrefix); ok && len(values) > 0 {
+			headers[strings.ToLower(suffix)] = values[0]
+		}
+	}
+	if len(headers) > 0 {
+		t.mu.Lock()
+		t.latest = &RateLimits{UpdatedAt: time.Now().UTC(), Headers: headers}
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// snapshot returns the latest rate limit state, or nil before the first upstream response.
+func (t *rateLimitRecorder) snapshot() *RateLimits {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.latest
+}
+
+// profileResponse is the subset of Anthropic's OAuth profile used for /v1/me.
+type profileResponse struct {
+	Account struct {
+		UUID         string `json:"uuid"`
+		EmailAddress string `json:"email_address"`
+		DisplayName  string `json:"display_name"`
+		HasClaudeMax bool   `json:"has_claude_max"`
+		HasClaudePro bool   `json:"has_claude_pro"`
+	} `json:"account"`
+	Organization struct {
+		UUID             string `json:"uuid"`
+		Name             string `json:"name"`
+		OrganizationType string `json:"organization_type"`
+		RateLimitTier    string `json:"rate_limit_tier"`
+	} `json:"organization"`
+}
+
+// plan condenses the subscription flags into a single plan name.
+func (p *profileResponse) plan() string {
+	switch {
+	case p.Account.HasClaudeMax:
+		return "max"
+	case p.Account.HasClaudePro:
+		return "pro"
+	default:
+		return strings.TrimPrefix(p.Organization.OrganizationType, "claude_")
+	}
+}
+
+// accountInfo fetches the OAuth profile and caches it for profileTTL.
+type accountInfo struct {
+	client     *http.Client
+	profileURL string
+	limits     *rateLimitRecorder
+
+	mu        sync.Mutex
+	profile   *profileResponse
+	fetchedAt time.Time
+}
+
+// get returns the account details combined with the latest rate limit state.
+func (a *accountInfo) get(ctx context.Context) (*Account, error) {
+	profile, err := a.cachedProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		Account: AccountUser{
+			UUID:        profile.Account.UUID,
+			Email:       profile.Account.EmailAddress,
+			DisplayName: profile.Account.DisplayName,
+		},
+		Organization: AccountOrganization{
+			UUID:          profile.Organization.UUID,
+			Name:          profile.Organization.Name,
+			Type:          profile.Organization.OrganizationType,
+			RateLimitTier: profile.Organization.RateLimitTier,
+		},
+		Plan:       profile.plan(),
+		RateLimits: a.limits.snapshot(),
+	}, nil
+}
+
+func (a *accountInfo) cachedProfile(ctx context.Context) (*profileResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.profile != nil && time.Since(a.fetchedAt) < profileTTL {
+		return a.profile, nil
+	}
+
+	profile, err := a.fetchProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.profile = profile
+	a.fetchedAt = time.Now()
+	return profile, nil
+}
+
+func (a *accountInfo) fetchProfile(ctx context.Context) (*profileResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.profileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("profile request failed with status %d", resp.StatusCode)
+	}
+
+	var profile profileResponse
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return nil, fmt.Errorf("decoding profile: %w", err)
+	}
+	return &profile, nil
+}
+
+// accountHandler serves the OAuth account's e-mail, plan and current rate limit state.
+func accountHandler(info *accountInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		account, err := info.get(r.Context())
+		if err != nil {
+			slog.WarnContext(r.Context(), "failed to fetch account profile", "error", err)
+			writeAnthropicErrorStatus(w, r, http.StatusBadGateway, "failed to fetch account profile from upstream")
+			return
+		}
+		writeJSON(r.Context(), w, account, http.StatusOK)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+type readyChecker struct{}
+
+func (readyChecker) IsReady() bool { return true }
+
+func TestAccountEndpoint(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case profilePath:
+			_, _ = w.Write([]byte(`{"account":{"uuid":"u1","email_address":"alice@example.com","has_claude_max":true},"organization":{"uuid":"o1","organization_type":"claude_max"}}`))
+		case "/v1/messages":
+			w.Header().Set("Anthropic-Ratelimit-Unified-Status", "allowed")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer upstream.Close()
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	me := func() Account {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/me", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var account Account
+		if err := json.Unmarshal(rec.Body.Bytes(), &account); err != nil {
+			t.Fatal(err)
+		}
+		return account
+	}
+
+	account := me()
+	if account.Account.Email != "alice@example.com" || account.Plan != "max" {
+		t.Errorf("account = %+v, plan = %q", account.Account, account.Plan)
+	}
+	if account.RateLimits != nil {
+		t.Errorf("rate limits before first request = %+v, want nil", account.RateLimits)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`)))
+
+	account = me()
+	if account.RateLimits == nil || account.RateLimits.Headers["unified-status"] != "allowed" {
+		t.Errorf("rate limits = %+v, want unified-status allowed", account.RateLimits)
+	}
+}
@@ -0,0 +1,67 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/florianilch/claudine-proxy/internal/dashboard"
+)
+
+// WithAdminToken sets the token protecting administrative endpoints such as the
+// dashboard. Clients send it as bearer token or as Basic auth password (any user),
+// so browsers can log in with their built-in prompt.
+func WithAdminToken(token string) Option {
+	return func(c *config) {
+		c.adminToken = token
+	}
+}
+
+// WithDashboard serves the read-only dashboard at /dashboard. Requires WithAdminToken.
+func WithDashboard(d *dashboard.Dashboard) Option {
+	return func(c *config) {
+		c.dashboard = d
+	}
+}
+
+// adminAuth rejects requests without the admin token.
+func adminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !validAdminToken(r, token) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="claudine-proxy admin", charset="UTF-8"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validAdminToken reports whether r carries token as bearer token or Basic auth password.
+func validAdminToken(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	var got string
+	if _, password, ok := r.BasicAuth(); ok {
+		got = password
+	} else if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		got = strings.TrimSpace(auth[7:])
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// dashboardStatus reports live proxy state for the dashboard.
+func dashboardStatus(streams *streamCounter, limits *rateLimitRecorder) func() dashboard.Status {
+	return func() dashboard.Status {
+		status := dashboard.Status{ActiveStreams: streams.active.Load()}
+		if rl := limits.snapshot(); rl != nil {
+			status.RateLimits = rl.Headers
+			status.RateLimitsUpdatedAt = rl.UpdatedAt
+		}
+		return status
+	}
+}
@@ -0,0 +1,127 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/dashboard"
+)
+
+func TestDashboardAdminAuth(t *testing.T) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	p, err := New(ts, readyChecker{}, WithAdminToken("admin-secret"), WithDashboard(dashboard.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		auth       func(r *http.Request)
+		wantStatus int
+	}{
+		{"no credentials", "/dashboard", func(*http.Request) {}, http.StatusUnauthorized},
+		{"wrong bearer", "/dashboard/data.json", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"bearer", "/dashboard/data.json", func(r *http.Request) { r.Header.Set("Authorization", "Bearer admin-secret") }, http.StatusOK},
+		{"basic password", "/dashboard", func(r *http.Request) { r.SetBasicAuth("anyone", "admin-secret") }, http.StatusOK},
+		{"wrong basic password", "/dashboard", func(r *http.Request) { r.SetBasicAuth("admin-secret", "nope") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			tt.auth(req)
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestDashboardRequiresAdminToken(t *testing.T) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	if _, err := New(ts, readyChecker{}, WithDashboard(dashboard.New())); err == nil {
+		t.Error("New() accepted dashboard without admin token")
+	}
+}
+
+func TestUsageReports(t *testing.T) {
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[],"has_more":false}`))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		path       string
+		auth       string
+		wantStatus int
+		wantHeader string // credential header sent upstream
+		wantValue  string
+	}{
+		{"no admin token", "", "/v1/organizations/cost_report", "", http.StatusUnauthorized, "", ""},
+		{"oauth", "", "/v1/organizations/usage_report/messages?bucket_width=1d", "Bearer admin-secret", http.StatusOK, "Authorization", "Bearer oauth-token"},
+		{"admin api key", "sk-ant-admin01-key", "/v1/organizations/cost_report?starting_at=2025-01-01T00:00:00Z", "Bearer admin-secret", http.StatusOK, "X-Api-Key", "sk-ant-admin01-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+			p, err := New(ts, readyChecker{},
+				WithBaseURL(upstream.URL+"/v1"),
+				WithAdminToken("admin-secret"),
+				WithUsageReports(true, tt.apiKey),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantHeader == "" {
+				if got != nil {
+					t.Error("unauthorized request was forwarded")
+				}
+				return
+			}
+			if got.URL.RequestURI() != tt.path {
+				t.Errorf("upstream URI = %q, want %q", got.URL.RequestURI(), tt.path)
+			}
+			if v := got.Header.Get(tt.wantHeader); v != tt.wantValue {
+				t.Errorf("upstream %s = %q, want %q", tt.wantHeader, v, tt.wantValue)
+			}
+			if strings.Contains(got.Header.Get("Authorization"), "admin-secret") {
+				t.Error("admin token was forwarded upstream")
+			}
+		})
+	}
+}
+
+func TestUsageReportsRequireAdminToken(t *testing.T) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	if _, err := New(ts, readyChecker{}, WithUsageReports(true, "")); err == nil {
+		t.Error("New() accepted usage reports without admin token")
+	}
+}
@@ -0,0 +1,64 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+)
+
+// WithAzureDeployments maps Azure OpenAI deployment names to Anthropic models for
+// requests to /openai/deployments/{deployment}/chat/completions. Deployments without
+// an entry use their name as the model.
+func WithAzureDeployments(deployments map[string]string) Option {
+	return func(c *config) {
+		c.azureDeployments = deployments
+	}
+}
+
+// azureDeployment sets the request model from the {deployment} path segment, as
+// Azure OpenAI clients address models by URL instead of the "model" field.
+// The api-version query parameter is accepted and ignored.
+func azureDeployment(deployments map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deployment := r.PathValue("deployment")
+			model, ok := deployments[deployment]
+			if !ok {
+				model = deployment
+			}
+
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if rewritten, ok := setModel(body, model); ok {
+				reqbody.Replace(r, rewritten)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setModel replaces the top-level "model" field of a JSON object.
+func setModel(body []byte, model string) ([]byte, bool) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil || fields == nil {
+		return nil, false
+	}
+	encoded, err := json.Marshal(model)
+	if err != nil {
+		return nil, false
+	}
+	fields["model"] = encoded
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return rewritten, true
+}
@@ -0,0 +1,66 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestAzureDeployment(t *testing.T) {
+	var gotModel string
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel = body.Model
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"id":"msg_01","type":"message","role":"assistant","model":"` + body.Model +
+				`","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)),
+			Request: r,
+		}, nil
+	})
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithTransport(upstream), WithAzureDeployments(map[string]string{"gpt-4o": "claude-sonnet-4-5"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		deployment string
+		wantModel  string
+	}{
+		{deployment: "gpt-4o", wantModel: "claude-sonnet-4-5"},
+		{deployment: "claude-haiku-4-5", wantModel: "claude-haiku-4-5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.deployment, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/openai/deployments/"+tt.deployment+"/chat/completions?api-version=2024-10-21",
+				strings.NewReader(`{"messages":[{"role":"user","content":"Hello"}]}`))
+			req.Header.Set("Api-Key", "azure-key")
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+			}
+			if gotModel != tt.wantModel {
+				t.Errorf("upstream model = %q, want %q", gotModel, tt.wantModel)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+const (
+	// maxBudgetConversations bounds the conversations tracked; the least recently
+	// used are forgotten first, starting over with an empty budget.
+	maxBudgetConversations = 10000
+
+	// headerConversationTokens reports the tokens a conversation used before the
+	// request and its budget, as "used/budget".
+	headerConversationTokens = "X-Claudine-Conversation-Tokens"
+)
+
+// WithConversationBudget limits the tokens, input including cache reads and
+// writes plus output, that a single conversation may use across its requests.
+// Conversations are named by prompt_cache_key or metadata.conversation_id, and
+// budgeted per client key and tenant; requests naming none are not limited. Requests of a conversation over budget
+// are rejected, or only logged if warnOnly is set. Zero maxTokens disables it.
+func WithConversationBudget(maxTokens int64, warnOnly bool) Option {
+	return func(c *config) {
+		if maxTokens <= 0 {
+			c.budget = nil
+			return
+		}
+		c.budget = newConversationBudget(maxTokens, warnOnly)
+	}
+}
+
+// ConversationBudget tracks the cumulative tokens of conversations, per client
+// key and tenant. It is a usage.Sink adding up completed requests; its middleware
+// checks the total before a request is forwarded, so the request crossing the
+// budget completes.
+type ConversationBudget struct {
+	max      int64
+	warnOnly bool
+
+	mu            sync.Mutex
+	ll            *list.List // Of *budgetConversation, most recently used first
+	conversations map[string]*list.Element
+}
+
+// budgetConversation holds the tokens a conversation used.
+type budgetConversation struct {
+	key    string
+	tokens int64
+	warned bool
+}
+
+func newConversationBudget(maxTokens int64, warnOnly bool) *ConversationBudget {
+	return &ConversationBudget{
+		max:           maxTokens,
+		warnOnly:      warnOnly,
+		ll:            list.New(),
+		conversations: make(map[string]*list.Element),
+	}
+}
+
+// Compile-time check that ConversationBudget implements usage.Sink.
+var _ usage.Sink = (*ConversationBudget)(nil)
+
+// Consume implements usage.Sink, adding the tokens of a completed request to
+// its conversation.
+func (b *ConversationBudget) Consume(ctx context.Context, event usage.Event) {
+	conversation := namedConversationFromContext(ctx)
+	if conversation == "" {
+		return
+	}
+	var tenant string
+	if t := tenantFromContext(ctx); t != nil {
+		tenant = t.Name
+	}
+	tokens := event.Usage.InputTokens + event.Usage.OutputTokens +
+		event.Usage.CacheReadInputTokens + event.Usage.CacheCreationInputTokens
+	if tokens == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.conversation(conversationState(tenant, event.Key, conversation))
+	c.tokens += tokens
+}
+
+// used returns the tokens key used so far, and whether a warning about it is
+// still due.
+func (b *ConversationBudget) used(key string) (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	el, ok := b.conversations[key]
+	if !ok {
+		return 0, false
+	}
+	b.ll.MoveToFront(el)
+	c := el.Value.(*budgetConversation)
+	warn := c.tokens >= b.max && !c.warned
+	if warn {
+		c.warned = true
+	}
+	return c.tokens, warn
+}
+
+// conversation returns the entry of key, creating it and evicting the least
+// recently used one if needed. Must be called with b.mu held.
+func (b *ConversationBudget) conversation(key string) *budgetConversation {
+	if el, ok := b.conversations[key]; ok {
+		b.ll.MoveToFront(el)
+		return el.Value.(*budgetConversation)
+	}
+	c := &budgetConversation{key: key}
+	b.conversations[key] = b.ll.PushFront(c)
+	if b.ll.Len() > maxBudgetConversations {
+		oldest := b.ll.Back()
+		b.ll.Remove(oldest)
+		delete(b.conversations, oldest.Value.(*budgetConversation).key)
+	}
+	return c
+}
+
+// middleware rejects requests of conversations that used up their budget, unless
+// only warning. Requires identifyConversation. No-op for a nil budget.
+func (b *ConversationBudget) middleware(reject func(w http.ResponseWriter, r *http.Request, status int, message string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if b == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := namedConversationFromContext(r.Context())
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			used, warn := b.used(conversationState(tenantName(r), usage.KeyID(r), key))
+			w.Header().Set(headerConversationTokens, strconv.FormatInt(used, 10)+"/"+strconv.FormatInt(b.max, 10))
+			if used < b.max {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if b.warnOnly {
+				if warn {
+					slog.WarnContext(r.Context(), "conversation exceeded token budget", "conversation", key, "tokens", used, "budget", b.max)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			slog.WarnContext(r.Context(), "rejected request of conversation over token budget", "conversation", key, "tokens", used, "budget", b.max)
+			reject(w, r, http.StatusForbidden, fmt.Sprintf(
+				"conversation token budget exceeded: %s used %d of %d tokens; start a new conversation to continue",
+				key, used, b.max))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestConversationBudget(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":4,"output_tokens":4}}`))
+	}))
+	defer upstream.Close()
+
+	const (
+		named       = `{"model":"claude-sonnet-4-5","max_tokens":1,"metadata":{"conversation_id":"c1"},"messages":[{"role":"user","content":"hi"}]}`
+		unnamed     = `{"model":"claude-sonnet-4-5","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}`
+		cacheKey    = `{"model":"claude-sonnet-4-5","prompt_cache_key":"c2","messages":[{"role":"user","content":"hi"}]}`
+		messages    = "/v1/messages"
+		completions = "/v1/chat/completions"
+	)
+
+	tests := []struct {
+		name       string
+		warnOnly   bool
+		requests   [][2]string // path, body
+		wantStatus []int
+		wantTokens string // X-Claudine-Conversation-Tokens of the last request
+	}{
+		{
+			name:       "reject",
+			requests:   [][2]string{{messages, named}, {messages, named}, {messages, named}},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusForbidden},
+			wantTokens: "16/10",
+		},
+		{
+			name:       "warn",
+			warnOnly:   true,
+			requests:   [][2]string{{messages, named}, {messages, named}, {messages, named}},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+			wantTokens: "16/10",
+		},
+		{
+			name:       "unnamed conversations are not limited",
+			requests:   [][2]string{{messages, unnamed}, {messages, unnamed}, {messages, unnamed}},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:       "conversations are budgeted separately",
+			requests:   [][2]string{{messages, named}, {messages, named}, {completions, cacheKey}},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+			wantTokens: "0/10",
+		},
+		{
+			name:       "prompt cache key",
+			requests:   [][2]string{{completions, cacheKey}, {completions, cacheKey}, {completions, cacheKey}},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusForbidden},
+			wantTokens: "16/10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+			p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"), WithConversationBudget(10, tt.warnOnly))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rec *httptest.ResponseRecorder
+			for i, req := range tt.requests {
+				rec = httptest.NewRecorder()
+				p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, req[0], strings.NewReader(req[1])))
+				if rec.Code != tt.wantStatus[i] {
+					t.Errorf("request %d: status = %d, want %d: %s", i, rec.Code, tt.wantStatus[i], rec.Body)
+				}
+			}
+			if got := rec.Header().Get(headerConversationTokens); got != tt.wantTokens {
+				t.Errorf("%s = %q, want %q", headerConversationTokens, got, tt.wantTokens)
+			}
+			if tt.wantStatus[len(tt.wantStatus)-1] == http.StatusForbidden && !strings.Contains(rec.Body.String(), "conversation token budget exceeded") {
+				t.Errorf("body = %s, want budget error", rec.Body)
+			}
+		})
+	}
+}
+
+func TestConversationBudgetPerKey(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":4,"output_tokens":4}}`))
+	}))
+	defer upstream.Close()
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"), WithConversationBudget(10, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const body = `{"model":"claude-sonnet-4-5","max_tokens":1,"metadata":{"conversation_id":"c1"},"messages":[{"role":"user","content":"hi"}]}`
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+		req.Header.Set("X-Api-Key", key)
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Key a uses up the budget of conversation c1
+	for range 2 {
+		if rec := send("key-a"); rec.Code != http.StatusOK {
+			t.Fatalf("key a: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+	}
+	if rec := send("key-a"); rec.Code != http.StatusForbidden {
+		t.Errorf("key a over budget: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	// Key b naming its conversation c1 too starts with its own budget
+	rec := send("key-b")
+	if rec.Code != http.StatusOK {
+		t.Errorf("key b: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if got := rec.Header().Get(headerConversationTokens); got != "0/10" {
+		t.Errorf("key b: %s = %q, want %q", headerConversationTokens, got, "0/10")
+	}
+}
@@ -4,17 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter"
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/anthropicclaude"
+	"github.com/florianilch/claudine-proxy/internal/metrics"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/anthropicclaude"
 )
 
+// HeaderDryRun set to true makes a chat completion request return the Anthropic
+// request it would be sent as instead of calling the upstream.
+const HeaderDryRun = "X-Claudine-Dry-Run"
+
 // CreateChatCompletionsHandler handles OpenAI-compatible chat completion requests.
 type CreateChatCompletionsHandler struct {
 	Adapter   *anthropicclaude.CreateChatCompletionAdapter
 	Transport http.RoundTripper
+	Errors    *metrics.ErrorCollector
+
+	// DryRun answers every request as if it set HeaderDryRun.
+	DryRun bool
 }
 
 // Compile-time check to ensure CreateChatCompletionsHandler implements http.Handler
@@ -24,6 +36,107 @@ var _ http.Handler = (*CreateChatCompletionsHandler)(nil)
 func (h *CreateChatCompletionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	req, ok := decodeChatCompletionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if req.Metadata != nil {
+		usage.FromContext(ctx).SetTag((*req.Metadata)[usage.MetadataTag])
+	}
+
+	if req.Stream != nil && *req.Stream {
+		h.streamResponse(ctx, w, req)
+	} else {
+		h.writeResponse(ctx, w, req)
+	}
+}
+
+// ServeDiff answers with the Anthropic request a chat completion request is
+// translated to next to the original, both redacted, without sending either.
+func (h *CreateChatCompletionsHandler) ServeDiff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, ok := decodeChatCompletionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	diff, err := h.Adapter.Diff(req)
+	if err != nil {
+		slog.WarnContext(ctx, "request translation failed", "error", err)
+		h.recordTransformError(err)
+
+		var errResp *openaiadapter.ErrorResponse
+		if errors.As(err, &errResp) {
+			writeJSONOpenAIError(ctx, w, errResp)
+			return
+		}
+		writeJSONOpenAIError(ctx, w, &openaiadapter.ErrorResponse{
+			Err: openaiadapter.Error{
+				Message: http.StatusText(http.StatusInternalServerError),
+				Type:    "api_error",
+			},
+		})
+		return
+	}
+
+	writeJSON(ctx, w, diff, http.StatusOK)
+}
+
+// dryRun answers requests asking for a dry run with the Anthropic request they
+// would be sent as, and passes other requests to next. It must run after the
+// checks a dry run is meant to exercise and before the request is cached.
+func (h *CreateChatCompletionsHandler) dryRun(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		enabled := h.DryRun
+		if v := r.Header.Get(HeaderDryRun); v != "" {
+			var err error
+			if enabled, err = strconv.ParseBool(v); err != nil {
+				writeOpenAIErrorStatus(w, r, http.StatusBadRequest, fmt.Sprintf("invalid %s header: %q is not a boolean", HeaderDryRun, v))
+				return
+			}
+		}
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		req, ok := decodeChatCompletionRequest(w, r)
+		if !ok {
+			return
+		}
+		result, err := h.Adapter.DryRun(req)
+		if err != nil {
+			slog.WarnContext(ctx, "dry run failed", "error", err)
+			h.recordTransformError(err)
+
+			var errResp *openaiadapter.ErrorResponse
+			if errors.As(err, &errResp) {
+				writeJSONOpenAIError(ctx, w, errResp)
+				return
+			}
+			writeJSONOpenAIError(ctx, w, &openaiadapter.ErrorResponse{
+				Err: openaiadapter.Error{
+					Message: http.StatusText(http.StatusInternalServerError),
+					Type:    "api_error",
+				},
+			})
+			return
+		}
+
+		w.Header().Set(HeaderDryRun, "true")
+		writeJSON(ctx, w, result, http.StatusOK)
+	})
+}
+
+// decodeChatCompletionRequest decodes the request body, answering with an OpenAI
+// error if it is too large or invalid.
+func decodeChatCompletionRequest(w http.ResponseWriter, r *http.Request) (openaiadapter.CreateChatCompletionRequest, bool) {
+	ctx := r.Context()
+
 	var req openaiadapter.CreateChatCompletionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		var maxBytesErr *http.MaxBytesError
@@ -35,7 +148,7 @@ func (h *CreateChatCompletionsHandler) ServeHTTP(w http.ResponseWriter, r *http.
 					Type:    "invalid_request_error",
 				},
 			})
-			return
+			return req, false
 		}
 		slog.ErrorContext(ctx, "failed to decode request", "error", err)
 		writeJSONOpenAIError(ctx, w, &openaiadapter.ErrorResponse{
@@ -44,14 +157,9 @@ func (h *CreateChatCompletionsHandler) ServeHTTP(w http.ResponseWriter, r *http.
 				Type:    "invalid_request_error",
 			},
 		})
-		return
-	}
-
-	if req.Stream != nil && *req.Stream {
-		h.streamResponse(ctx, w, req)
-	} else {
-		h.writeResponse(ctx, w, req)
+		return req, false
 	}
+	return req, true
 }
 
 // writeResponse handles non-streaming chat completion requests.
@@ -66,7 +174,13 @@ func (h *CreateChatCompletionsHandler) writeResponse(
 	response, err := h.Adapter.ProcessRequest(ctx, req, h.Transport)
 	if err != nil {
 		slog.ErrorContext(ctx, "request failed", "error", err)
+		h.recordTransformError(err)
 
+		var rateLimitErr *openaiadapter.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			writeJSONOpenAIRateLimitError(ctx, w, rateLimitErr)
+			return
+		}
 		var errResp *openaiadapter.ErrorResponse
 		if errors.As(err, &errResp) {
 			writeJSONOpenAIError(ctx, w, errResp)
@@ -97,7 +211,13 @@ func (h *CreateChatCompletionsHandler) streamResponse(
 	stream, err := h.Adapter.ProcessStreamingRequest(ctx, req, h.Transport)
 	if err != nil {
 		slog.ErrorContext(ctx, "streaming request failed", "error", err)
+		h.recordTransformError(err)
 
+		var rateLimitErr *openaiadapter.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			writeJSONOpenAIRateLimitError(ctx, w, rateLimitErr)
+			return
+		}
 		var errResp *openaiadapter.ErrorResponse
 		if errors.As(err, &errResp) {
 			writeJSONOpenAIError(ctx, w, errResp)
@@ -134,16 +254,28 @@ func (h *CreateChatCompletionsHandler) streamResponse(
 
 		if err != nil {
 			slog.ErrorContext(ctx, "stream error", "error", err)
+			h.recordTransformError(err)
 
 			var errorResponse *openaiadapter.ErrorResponse
 			if errors.As(err, &errorResponse) {
+				// Rate limit details marshal into the same {"error": {...}} object,
+				// usage until an interruption next to it
+				var payload any = errorResponse
+				var rateLimitErr *openaiadapter.RateLimitError
+				var interruptedErr *openaiadapter.StreamInterruptedError
+				if errors.As(err, &rateLimitErr) {
+					payload = rateLimitErr
+				} else if errors.As(err, &interruptedErr) {
+					payload = interruptedErr
+				}
+
 				// OpenAI SDK recognizes {"error": {...}} format and stops reading immediately
 				// https://github.com/openai/openai-go/blob/ae042a437e4ebef4dffe088bf01d087ac94feaf2/packages/ssestream/ssestream.go#L169-L173
 				if writeErr := sse.WriteEvent("error"); writeErr != nil {
 					slog.ErrorContext(ctx, "failed to write error event type", "error", writeErr)
 					return
 				}
-				if writeErr := sse.WriteData(errorResponse); writeErr != nil {
+				if writeErr := sse.WriteData(payload); writeErr != nil {
 					slog.ErrorContext(ctx, "failed to write error", "error", writeErr)
 				}
 				return
@@ -178,3 +310,12 @@ func (h *CreateChatCompletionsHandler) streamResponse(
 		slog.ErrorContext(ctx, "failed to write stream termination marker", "error", err)
 	}
 }
+
+// recordTransformError counts adapter transformation failures, leaving upstream
+// errors to the usage metrics.
+func (h *CreateChatCompletionsHandler) recordTransformError(err error) {
+	var transformErr *openaiadapter.TransformError
+	if errors.As(err, &transformErr) {
+		h.Errors.AdapterFailed(transformErr.Stage)
+	}
+}
@@ -0,0 +1,179 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestDryRun(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	const body = `{"model":"claude-sonnet-4-5","max_completion_tokens":64,"stream":true,"messages":[{"role":"system","content":"Be brief"},{"role":"user","content":"hi"}]}`
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		header       string
+		body         string
+		wantStatus   int
+		wantUpstream bool
+		wantRequest  string // Encoded Anthropic request, if a dry run
+	}{
+		{
+			name:        "header",
+			header:      "true",
+			body:        body,
+			wantStatus:  http.StatusOK,
+			wantRequest: `{"max_tokens":64,"messages":[{"content":[{"text":"hi","type":"text"}],"role":"user"}],"model":"claude-sonnet-4-5","stream":true,"system":[{"text":"Be brief","type":"text"}]}`,
+		},
+		{
+			name:         "header false",
+			header:       "false",
+			body:         `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`,
+			wantStatus:   http.StatusOK,
+			wantUpstream: true,
+		},
+		{
+			name:        "enabled for all requests",
+			opts:        []Option{WithDryRun(true)},
+			body:        `{"model":"claude-sonnet-4-5","max_completion_tokens":64,"messages":[{"role":"user","content":"hi"}]}`,
+			wantStatus:  http.StatusOK,
+			wantRequest: `{"max_tokens":64,"messages":[{"content":[{"text":"hi","type":"text"}],"role":"user"}],"model":"claude-sonnet-4-5"}`,
+		},
+		{
+			name:       "validation error",
+			header:     "true",
+			body:       `{"model":"claude-sonnet-4-5","messages":[]}`,
+			wantStatus: http.StatusInternalServerError, // As without dry run
+		},
+		{
+			name:       "invalid header",
+			header:     "maybe",
+			body:       body,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamCalls.Store(0)
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+			p, err := New(ts, readyChecker{}, append([]Option{WithBaseURL(upstream.URL + "/v1")}, tt.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set(HeaderDryRun, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := upstreamCalls.Load() > 0; got != tt.wantUpstream {
+				t.Errorf("upstream called = %v, want %v", got, tt.wantUpstream)
+			}
+			if tt.wantRequest == "" {
+				return
+			}
+			var result struct {
+				Request json.RawMessage `json:"request"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if string(result.Request) != tt.wantRequest {
+				t.Errorf("request = %s, want %s", result.Request, tt.wantRequest)
+			}
+			if rec.Header().Get(HeaderDryRun) != "true" {
+				t.Errorf("%s response header missing", HeaderDryRun)
+			}
+		})
+	}
+}
+
+func TestStreamInterrupted(t *testing.T) {
+	const events = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_01\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"claude-sonnet-4-5\",\"content\":[],\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n"
+
+	tests := []struct {
+		name  string
+		reset bool // Drop the connection instead of ending the response
+	}{
+		{name: "ended early"},
+		{name: "connection reset", reset: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write([]byte(events))
+				w.(http.Flusher).Flush()
+				if tt.reset {
+					conn, _, err := http.NewResponseController(w).Hijack()
+					if err == nil {
+						_ = conn.Close()
+					}
+				}
+			}))
+			defer upstream.Close()
+
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+			p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+				strings.NewReader(`{"model":"claude-sonnet-4-5","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			body := rec.Body.String()
+			if !strings.Contains(body, `"content":"Hel"`) {
+				t.Errorf("body = %s, want partial content", body)
+			}
+			_, data, ok := strings.Cut(body, "event: error\ndata: ")
+			if !ok {
+				t.Fatalf("body = %s, want error event", body)
+			}
+			var payload struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+				Usage struct {
+					PromptTokens int `json:"prompt_tokens"`
+				} `json:"usage"`
+			}
+			if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &payload); err != nil {
+				t.Fatalf("decode error event %q: %v", data, err)
+			}
+			if payload.Error.Code != "upstream_stream_interrupted" {
+				t.Errorf("code = %q, want upstream_stream_interrupted", payload.Error.Code)
+			}
+			if payload.Usage.PromptTokens != 12 {
+				t.Errorf("prompt_tokens = %d, want 12", payload.Usage.PromptTokens)
+			}
+			if strings.Contains(body, "[DONE]") {
+				t.Error("interrupted stream ended with [DONE]")
+			}
+		})
+	}
+}
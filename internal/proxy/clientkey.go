@@ -0,0 +1,96 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// anthropicAPIKeyPrefix identifies Anthropic API keys; other client credentials
+// (placeholders like "claudine", proxy virtual keys) are ignored.
+const anthropicAPIKeyPrefix = "sk-ant-api"
+
+type clientKeyContextKey struct{}
+
+// WithClientKeys forwards Anthropic API keys sent by clients (x-api-key or
+// Authorization bearer) instead of the proxy's OAuth token. Such requests skip
+// impersonation, allowing mixed OAuth and API key usage through one endpoint.
+func WithClientKeys(enabled bool) Option {
+	return func(c *config) {
+		c.clientKeys = enabled
+	}
+}
+
+// captureClientKey stores an Anthropic API key sent by the client in the request
+// context, where ClientKeyTransport picks it up. No-op unless enabled.
+func captureClientKey(enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := clientAPIKey(r); key != "" {
+				r = r.WithContext(context.WithValue(r.Context(), clientKeyContextKey{}, key))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientAPIKey returns the client's Anthropic API key, or "" if it sent none.
+func clientAPIKey(r *http.Request) string {
+	key := r.Header.Get("X-Api-Key")
+	if key == "" {
+		auth := r.Header.Get("Authorization")
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			key = strings.TrimSpace(auth[7:])
+		}
+	}
+	if !strings.HasPrefix(key, anthropicAPIKeyPrefix) {
+		return ""
+	}
+	return key
+}
+
+// ClientKeyTransport is an http.RoundTripper that sends requests carrying a client
+// API key (see captureClientKey) through Direct with that key, and all other
+// requests through OAuth.
+type ClientKeyTransport struct {
+	OAuth  http.RoundTripper
+	Direct http.RoundTripper
+
+	// Versions selects the Anthropic-Version header for clients that send none;
+	// nil sends DefaultAnthropicVersion.
+	Versions *AnthropicVersions
+}
+
+// Compile-time check that ClientKeyTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*ClientKeyTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *ClientKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, ok := req.Context().Value(clientKeyContextKey{}).(string)
+	if !ok {
+		setCredential(req.Context(), CredentialSubscription)
+		return t.OAuth.RoundTrip(req)
+	}
+
+	slog.DebugContext(req.Context(), "forwarding client API key")
+	setCredential(req.Context(), CredentialClientKey)
+	newReq := req.Clone(req.Context())
+	newReq.Header.Del("Authorization")
+	newReq.Header.Set("X-Api-Key", key)
+	if newReq.Header.Get("Anthropic-Version") == "" {
+		newReq.Header.Set("Anthropic-Version", t.Versions.For(newReq.URL.Path))
+	}
+
+	direct := t.Direct
+	if direct == nil {
+		direct = http.DefaultTransport
+	}
+	return direct.RoundTrip(newReq)
+}
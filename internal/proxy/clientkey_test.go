@@ -0,0 +1,65 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestClientKeys(t *testing.T) {
+	var gotHeader http.Header
+	var gotBody string
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		gotHeader = r.Header
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+			Request:    r,
+		}, nil
+	})
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	p, err := New(ts, readyChecker{}, WithTransport(upstream), WithClientKeys(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		wantAPIKey  string
+		wantAuth    string
+		wantInjects bool
+	}{
+		{name: "x-api-key is forwarded", header: "X-Api-Key", value: "sk-ant-api03-client", wantAPIKey: "sk-ant-api03-client"},
+		{name: "bearer API key is forwarded", header: "Authorization", value: "Bearer sk-ant-api03-client", wantAPIKey: "sk-ant-api03-client"},
+		{name: "placeholder uses OAuth", header: "X-Api-Key", value: "claudine", wantAuth: "Bearer oauth-token", wantInjects: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`))
+			req.Header.Set(tt.header, tt.value)
+			p.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got := gotHeader.Get("X-Api-Key"); got != tt.wantAPIKey {
+				t.Errorf("X-Api-Key = %q, want %q", got, tt.wantAPIKey)
+			}
+			if got := gotHeader.Get("Authorization"); got != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantAuth)
+			}
+			if injected := strings.Contains(gotBody, claudeCodeSystemPrompt); injected != tt.wantInjects {
+				t.Errorf("system prompt injected = %v, want %v", injected, tt.wantInjects)
+			}
+		})
+	}
+}
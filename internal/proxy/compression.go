@@ -0,0 +1,152 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMaxWindow bounds the window a zstd frame may request and thereby the
+// decoder's memory; encoders default to at most 8MB for regular levels.
+const zstdMaxWindow = 8 << 20
+
+// WithUpstreamCompression gzip-compresses request bodies sent upstream.
+func WithUpstreamCompression(enabled bool) Option {
+	return func(c *config) {
+		c.compressUpstream = enabled
+	}
+}
+
+// DecodeRequest decompresses request bodies sent with Content-Encoding gzip, zstd
+// or deflate while they stream, so handlers and the system prompt injector see plain
+// JSON. Size limits applied afterwards bound the decompressed size. Other encodings
+// are rejected with 415 through writeError.
+func DecodeRequest(writeError func(w http.ResponseWriter, r *http.Request, status int, message string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := r.Header.Get("Content-Encoding")
+			if encoding == "" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := r.Body
+			codings := strings.Split(encoding, ",")
+			// Codings are listed in the order they were applied
+			for i := len(codings) - 1; i >= 0; i-- {
+				coding := strings.ToLower(strings.TrimSpace(codings[i]))
+				decoded, err := decodeBody(body, coding)
+				if errors.Is(err, errUnsupportedEncoding) {
+					_ = body.Close()
+					writeError(w, r, http.StatusUnsupportedMediaType, "unsupported Content-Encoding: "+coding)
+					return
+				}
+				if err != nil {
+					_ = body.Close()
+					writeError(w, r, http.StatusBadRequest, "invalid "+coding+" request body: "+err.Error())
+					return
+				}
+				body = decoded
+			}
+
+			r.Body = body
+			r.ContentLength = -1
+			r.Header.Del("Content-Length")
+			r.Header.Del("Content-Encoding")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// errUnsupportedEncoding reports a content coding DecodeRequest cannot decode.
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// decodeBody wraps body with a reader decoding coding. Closing the result closes body.
+func decodeBody(body io.ReadCloser, coding string) (io.ReadCloser, error) {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch coding {
+	case "identity", "":
+		return body, nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(body)
+	case "zstd":
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(zstdMaxWindow),
+			zstd.WithDecoderMaxMemory(2*zstdMaxWindow))
+		if err == nil {
+			r = d.IOReadCloser()
+		}
+	case "deflate":
+		// HTTP "deflate" is the zlib format (RFC 9110)
+		r, err = zlib.NewReader(body)
+	default:
+		return nil, errUnsupportedEncoding
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &decodedBody{Reader: r, decoder: r, body: body}, nil
+}
+
+// decodedBody reads decompressed data and closes both decoder and underlying body.
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	_ = b.decoder.Close()
+	return b.body.Close()
+}
+
+// CompressionTransport is an http.RoundTripper that gzip-compresses request bodies
+// while they stream upstream. Requests already carrying a Content-Encoding are sent
+// unchanged.
+type CompressionTransport struct {
+	Base http.RoundTripper
+}
+
+// Compile-time check that CompressionTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*CompressionTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *CompressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return t.Base.RoundTrip(req)
+	}
+
+	// Create pipe for streaming compression, like ImpersonationTransport's injection
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, req.Body)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+		_ = req.Body.Close()
+	}()
+
+	// RoundTrippers must not modify the caller's request
+	newReq := req.Clone(req.Context())
+	newReq.Body = pr
+	newReq.GetBody = nil
+	newReq.ContentLength = -1
+	newReq.Header.Del("Content-Length")
+	newReq.Header.Set("Content-Encoding", "gzip")
+
+	return t.Base.RoundTrip(newReq)
+}
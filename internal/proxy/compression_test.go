@@ -0,0 +1,158 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeRequest(t *testing.T) {
+	const body = `{"model": "claude-sonnet-4-0"}`
+
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	_, _ = zw.Write([]byte(body))
+	_ = zw.Close()
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zstded := enc.EncodeAll([]byte(body), nil)
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       []byte
+		wantStatus int
+		wantBody   string
+	}{
+		{"plain", "", []byte(body), http.StatusOK, body},
+		{"gzip", "gzip", gzipped(t, body), http.StatusOK, body},
+		{"deflate", "deflate", deflated.Bytes(), http.StatusOK, body},
+		{"stacked", "gzip, gzip", gzipped(t, string(gzipped(t, body))), http.StatusOK, body},
+		{"identity", "identity", []byte(body), http.StatusOK, body},
+		{"zstd", "zstd", zstded, http.StatusOK, body},
+		{"zstd over gzip", "gzip, zstd", enc.EncodeAll(gzipped(t, body), nil), http.StatusOK, body},
+		{"unsupported", "br", []byte(body), http.StatusUnsupportedMediaType, ""},
+		{"corrupt gzip", "gzip", []byte(body), http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var gotEncoding string
+			handler := DecodeRequest(writeAnthropicErrorStatus)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+				gotEncoding = r.Header.Get("Content-Encoding")
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if gotEncoding != "" {
+				t.Errorf("Content-Encoding = %q, want removed", gotEncoding)
+			}
+		})
+	}
+}
+
+func TestCompressionTransport(t *testing.T) {
+	const body = `{"model": "claude-sonnet-4-0", "messages": []}`
+
+	var gotEncoding, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("body is not gzip: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		gotBody = string(b)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &CompressionTransport{Base: http.DefaultTransport}}
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if gotEncoding != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", gotEncoding)
+	}
+	if gotBody != body {
+		t.Errorf("body = %q, want %q", gotBody, body)
+	}
+}
+
+func TestDecodeRequestBeforeInjection(t *testing.T) {
+	// Compressed client bodies reach the injector decoded and upstream recompressed
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("body is not gzip: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		received = string(b)
+	}))
+	defer server.Close()
+
+	transport := &ImpersonationTransport{Base: &CompressionTransport{Base: http.DefaultTransport}}
+	handler := DecodeRequest(writeAnthropicErrorStatus)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, err := http.NewRequestWithContext(r.Context(), http.MethodPost, server.URL, r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(gzipped(t, `{"model": "m"}`)))
+	req.Header.Set("Content-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(received, claudeCodeSystemPrompt) {
+		t.Errorf("upstream body = %q, want injected system prompt", received)
+	}
+}
@@ -0,0 +1,174 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/metrics"
+)
+
+// WithConnectionMetrics records connection reuse, DNS, connect and TLS handshake
+// timings and retries of upstream requests in collector.
+func WithConnectionMetrics(collector *metrics.ConnectionCollector) Option {
+	return func(c *config) {
+		c.connMetrics = collector
+	}
+}
+
+// ConnectionTraceTransport is an http.RoundTripper that reports how upstream requests
+// obtain their connections to Metrics using httptrace. Every round trip counts as an
+// attempt, as does every connection the base transport retries a request on. Within an
+// attemptScope, attempts are numbered across all round trips of a proxied request, so
+// retries by outer transports (rate limit queue, quota fallback) show up as well.
+type ConnectionTraceTransport struct {
+	Base    http.RoundTripper
+	Metrics *metrics.ConnectionCollector
+}
+
+// Compile-time check that ConnectionTraceTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*ConnectionTraceTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *ConnectionTraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attempts, _ := ctx.Value(attemptsKey{}).(*atomic.Int32)
+	if attempts == nil {
+		attempts = new(atomic.Int32)
+	}
+
+	ct := &connectionTrace{metrics: t.Metrics, attempts: attempts}
+	ct.attempt()
+
+	// RoundTrippers must not modify the caller's request
+	return t.Base.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, ct.clientTrace())))
+}
+
+// connectionTrace collects the timings of one round trip. Hooks run on the transport's
+// dial, read and write goroutines, so state is guarded by mu.
+type connectionTrace struct {
+	metrics  *metrics.ConnectionCollector
+	attempts *atomic.Int32
+
+	mu        sync.Mutex
+	getConns  int
+	getConn   time.Time
+	dnsStart  time.Time
+	connects  map[string]time.Time
+	tlsStart  time.Time
+	wroteTime time.Time
+}
+
+func (ct *connectionTrace) attempt() {
+	ct.metrics.Attempt(int(ct.attempts.Add(1)))
+}
+
+func (ct *connectionTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			ct.mu.Lock()
+			ct.getConns++
+			retry := ct.getConns > 1
+			ct.getConn = time.Now()
+			ct.mu.Unlock()
+			if retry {
+				// The transport retries the request on a new connection
+				ct.attempt()
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.mu.Lock()
+			wait := time.Since(ct.getConn)
+			ct.mu.Unlock()
+			ct.metrics.ConnectionAcquired(info.Reused)
+			ct.metrics.Phase(metrics.PhaseWait, wait)
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			ct.mu.Lock()
+			ct.dnsStart = time.Now()
+			ct.mu.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			ct.mu.Lock()
+			d := time.Since(ct.dnsStart)
+			ct.mu.Unlock()
+			if info.Err == nil {
+				ct.metrics.Phase(metrics.PhaseDNS, d)
+			}
+		},
+		ConnectStart: func(network, addr string) {
+			// Dials to several addresses may race (RFC 6555)
+			ct.mu.Lock()
+			if ct.connects == nil {
+				ct.connects = map[string]time.Time{}
+			}
+			ct.connects[network+" "+addr] = time.Now()
+			ct.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			ct.mu.Lock()
+			start, ok := ct.connects[network+" "+addr]
+			ct.mu.Unlock()
+			if ok && err == nil {
+				ct.metrics.Phase(metrics.PhaseConnect, time.Since(start))
+			}
+		},
+		TLSHandshakeStart: func() {
+			ct.mu.Lock()
+			ct.tlsStart = time.Now()
+			ct.mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			ct.mu.Lock()
+			d := time.Since(ct.tlsStart)
+			ct.mu.Unlock()
+			if err == nil {
+				ct.metrics.Phase(metrics.PhaseTLS, d)
+			}
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			ct.mu.Lock()
+			if info.Err == nil {
+				ct.wroteTime = time.Now()
+			}
+			ct.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			ct.mu.Lock()
+			wrote := ct.wroteTime
+			ct.mu.Unlock()
+			if !wrote.IsZero() {
+				// Model processing and queueing at Anthropic, without connection setup
+				ct.metrics.ResponseHeaders(time.Since(wrote))
+			}
+		},
+	}
+}
+
+// attemptsKey is the context key of the attempt counter installed by attemptScope.
+type attemptsKey struct{}
+
+// attemptScope is an http.RoundTripper that numbers the attempts ConnectionTraceTransport
+// records across all round trips of the request, so retries of outer transports count
+// as further attempts.
+type attemptScope struct {
+	Base http.RoundTripper
+}
+
+// Compile-time check that attemptScope implements http.RoundTripper.
+var _ http.RoundTripper = (*attemptScope)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *attemptScope) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Value(attemptsKey{}).(*atomic.Int32); ok {
+		return t.Base.RoundTrip(req)
+	}
+	ctx := context.WithValue(req.Context(), attemptsKey{}, new(atomic.Int32))
+	return t.Base.RoundTrip(req.WithContext(ctx))
+}
@@ -0,0 +1,98 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/metrics"
+)
+
+func TestConnectionTraceTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	reg := metrics.NewRegistry()
+	trace := &ConnectionTraceTransport{
+		Base:    server.Client().Transport,
+		Metrics: metrics.NewConnectionCollector(reg),
+	}
+
+	// Sent through the same scope, the second round trip is a retry
+	retrying := &attemptScope{Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		for range 2 {
+			resp, err := trace.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			_ = resp.Body.Close()
+		}
+		return trace.RoundTrip(req)
+	})}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: retrying}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	// Without a scope, every round trip is a first attempt
+	resp, err = (&http.Client{Transport: trace}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	exposition := rec.Body.String()
+
+	for _, want := range []string{
+		`claudine_upstream_connections_total{reused="false"} 1`,
+		`claudine_upstream_connections_total{reused="true"} 3`,
+		`claudine_upstream_connection_phase_seconds_count{phase="connect"} 1`,
+		`claudine_upstream_connection_phase_seconds_count{phase="tls"} 1`,
+		`claudine_upstream_connection_phase_seconds_count{phase="wait"} 4`,
+		`claudine_upstream_response_header_seconds_count 4`,
+		`claudine_upstream_attempts_total{attempt="1"} 2`,
+		`claudine_upstream_attempts_total{attempt="2"} 1`,
+		`claudine_upstream_attempts_total{attempt="3"} 1`,
+	} {
+		if !strings.Contains(exposition, want) {
+			t.Errorf("exposition missing %q:\n%s", want, exposition)
+		}
+	}
+}
+
+func TestConnectionCollectorAttemptLabels(t *testing.T) {
+	reg := metrics.NewRegistry()
+	collector := metrics.NewConnectionCollector(reg)
+	for n := range 6 {
+		collector.Attempt(n)
+	}
+	(*metrics.ConnectionCollector)(nil).Attempt(1)
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`claudine_upstream_attempts_total{attempt="1"} 1`,
+		`claudine_upstream_attempts_total{attempt="4+"} 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("exposition missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+	if strings.Contains(rec.Body.String(), `attempt="0"`) {
+		t.Error("attempt 0 recorded")
+	}
+}
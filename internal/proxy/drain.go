@@ -0,0 +1,61 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// drainGrace bounds how long Shutdown waits for accepted connections to send
+// their first request.
+const drainGrace = time.Second
+
+// pendingConns tracks accepted connections that have not sent a request yet.
+// http.Server drops those when their request arrives after Shutdown began, so
+// Shutdown stops accepting first and gives them a moment. Otherwise connections
+// accepted just before a process takes over the listener would fail.
+type pendingConns struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newPendingConns() *pendingConns {
+	return &pendingConns{conns: make(map[net.Conn]struct{})}
+}
+
+// track is an http.Server ConnState hook.
+func (p *pendingConns) track(c net.Conn, state http.ConnState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if state == http.StateNew {
+		p.conns[c] = struct{}{}
+	} else {
+		delete(p.conns, c)
+	}
+}
+
+// wait blocks until no connection is pending, grace elapsed or ctx is done.
+func (p *pendingConns) wait(ctx context.Context, grace time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		p.mu.Lock()
+		n := len(p.conns)
+		p.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
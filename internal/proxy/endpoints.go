@@ -0,0 +1,238 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultEndpointProbeInterval applies if WithEndpoints is given no interval.
+	defaultEndpointProbeInterval = 30 * time.Second
+
+	// endpointSwitchMargin is how much faster another healthy endpoint must be for
+	// traffic to leave the current one, so similar endpoints don't flap.
+	endpointSwitchMargin = 0.8
+)
+
+// WithEndpoints adds base URLs serving the same API as the one set with
+// WithBaseURL, such as regional gateways. They are probed every interval and
+// requests go to the healthy endpoint with the lowest latency. Endpoints must
+// share the base URL's path.
+func WithEndpoints(urls []string, interval time.Duration) Option {
+	return func(c *config) {
+		c.endpoints = urls
+		c.endpointProbeInterval = interval
+	}
+}
+
+// endpoint is an upstream base URL and its probed state.
+type endpoint struct {
+	url     *url.URL
+	healthy bool
+	latency time.Duration // Smoothed probe latency, 0 before the first probe
+}
+
+// Endpoints selects the upstream endpoint requests are sent to.
+//
+// Each endpoint is probed with an unauthenticated GET of its base URL; any
+// response below 500 counts as healthy. The healthy endpoint with the lowest
+// latency is used, staying on the current one unless another is clearly faster.
+// Failed requests mark their endpoint unhealthy until it passes a probe again;
+// once the primary endpoint recovers and is fastest again, traffic fails back.
+type Endpoints struct {
+	client   *http.Client
+	interval time.Duration
+
+	mu        sync.Mutex
+	endpoints []*endpoint // Primary first
+	current   *endpoint
+}
+
+// newEndpoints creates Endpoints for the primary base URL and alternates, probing
+// with transport. Returns nil without alternates.
+func newEndpoints(primary *url.URL, alternates []string, interval time.Duration, transport http.RoundTripper) (*Endpoints, error) {
+	if len(alternates) == 0 {
+		return nil, nil
+	}
+	if interval <= 0 {
+		interval = defaultEndpointProbeInterval
+	}
+
+	e := &Endpoints{
+		client:    &http.Client{Transport: transport, Timeout: interval / 2},
+		interval:  interval,
+		endpoints: []*endpoint{{url: primary, healthy: true}},
+	}
+	for _, raw := range alternates {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream endpoint: %w", err)
+		}
+		if u.Path != primary.Path {
+			return nil, fmt.Errorf("upstream endpoint %s: path %q differs from base URL path %q", raw, u.Path, primary.Path)
+		}
+		e.endpoints = append(e.endpoints, &endpoint{url: u, healthy: true})
+	}
+	e.current = e.endpoints[0]
+	return e, nil
+}
+
+// run probes all endpoints every interval until ctx is done.
+func (e *Endpoints) run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		e.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeAll probes the endpoints concurrently and reselects the current one.
+func (e *Endpoints) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, ep := range e.endpoints {
+		wg.Go(func() {
+			latency, err := e.probe(ctx, ep.url)
+			if ctx.Err() != nil {
+				return
+			}
+
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if err != nil {
+				if ep.healthy {
+					slog.WarnContext(ctx, "upstream endpoint unhealthy", "endpoint", ep.url.Host, "error", err)
+				}
+				ep.healthy = false
+				return
+			}
+			ep.healthy = true
+			if ep.latency == 0 {
+				ep.latency = latency
+			} else {
+				ep.latency = (ep.latency + latency) / 2
+			}
+		})
+	}
+	wg.Wait()
+
+	e.mu.Lock()
+	e.reselect(ctx)
+	e.mu.Unlock()
+}
+
+// probe returns how long ep took to answer a GET of its base URL.
+func (e *Endpoints) probe(ctx context.Context, u *url.URL) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return 0, fmt.Errorf("probe returned status %d", resp.StatusCode)
+	}
+	return latency, nil
+}
+
+// reselect switches to the fastest healthy endpoint if the current one is
+// unhealthy or clearly slower. Falls back to the primary if none is healthy.
+// e.mu must be held.
+func (e *Endpoints) reselect(ctx context.Context) {
+	var best *endpoint
+	for _, ep := range e.endpoints {
+		if ep.healthy && (best == nil || ep.latency < best.latency) {
+			best = ep
+		}
+	}
+
+	next := e.current
+	switch {
+	case best == nil:
+		next = e.endpoints[0]
+	case !e.current.healthy:
+		next = best
+	case float64(best.latency) < endpointSwitchMargin*float64(e.current.latency):
+		next = best
+	}
+	if next != e.current {
+		slog.InfoContext(ctx, "upstream endpoint switched",
+			"from", e.current.url.Host,
+			"to", next.url.Host,
+			"latency", next.latency,
+		)
+		e.current = next
+	}
+}
+
+// selected returns the current endpoint.
+func (e *Endpoints) selected() *endpoint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.current
+}
+
+// failed marks ep unhealthy after a request to it failed and moves on.
+func (e *Endpoints) failed(ctx context.Context, ep *endpoint, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !ep.healthy {
+		return
+	}
+	slog.WarnContext(ctx, "upstream endpoint unhealthy", "endpoint", ep.url.Host, "error", err)
+	ep.healthy = false
+	e.reselect(ctx)
+}
+
+// EndpointTransport is an http.RoundTripper that sends requests for the primary
+// endpoint's host to the endpoint Endpoints selected. Requests failing without a
+// response are not retried, as they may have reached the upstream.
+type EndpointTransport struct {
+	Base      http.RoundTripper
+	Endpoints *Endpoints
+}
+
+// Compile-time check that EndpointTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*EndpointTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *EndpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	primary := t.Endpoints.endpoints[0].url
+	if req.URL.Host != primary.Host {
+		return t.Base.RoundTrip(req)
+	}
+
+	ep := t.Endpoints.selected()
+	if ep != t.Endpoints.endpoints[0] {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.URL.Scheme = ep.url.Scheme
+		req.URL.Host = ep.url.Host
+		req.Host = ep.url.Host
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil && req.Context().Err() == nil && !errors.Is(err, context.Canceled) {
+		t.Endpoints.failed(req.Context(), ep, err)
+	}
+	return resp, err
+}
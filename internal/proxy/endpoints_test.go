@@ -0,0 +1,81 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEndpoints(t *testing.T) {
+	newUpstream := func(name string, down *atomic.Bool) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.URL.Path == "/v1" {
+				w.WriteHeader(http.StatusNotFound) // Probes only need an answer
+				return
+			}
+			_, _ = io.WriteString(w, name)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	var primaryDown, alternateDown atomic.Bool
+	primary := newUpstream("primary", &primaryDown)
+	alternate := newUpstream("alternate", &alternateDown)
+
+	primaryURL, _ := url.Parse(primary.URL + "/v1")
+	endpoints, err := newEndpoints(primaryURL, []string{alternate.URL + "/v1"}, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newEndpoints(primaryURL, []string{alternate.URL + "/v2"}, 0, http.DefaultTransport); err == nil {
+		t.Error("newEndpoints() accepted an endpoint with another path")
+	}
+	client := &http.Client{Transport: &EndpointTransport{Base: http.DefaultTransport, Endpoints: endpoints}}
+
+	served := func() string {
+		t.Helper()
+		resp, err := client.Get(primary.URL + "/v1/messages")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	ctx := context.Background()
+	if got := served(); got != "primary" {
+		t.Errorf("served by %q before probing, want primary", got)
+	}
+
+	primaryDown.Store(true)
+	endpoints.probeAll(ctx)
+	if got := served(); got != "alternate" {
+		t.Errorf("served by %q while primary is down, want alternate", got)
+	}
+
+	// Fails back once the primary is healthy and the alternate isn't
+	primaryDown.Store(false)
+	alternateDown.Store(true)
+	endpoints.probeAll(ctx)
+	if got := served(); got != "primary" {
+		t.Errorf("served by %q after primary recovered, want primary", got)
+	}
+
+	// Without a healthy endpoint requests go to the primary
+	primaryDown.Store(true)
+	endpoints.probeAll(ctx)
+	if ep := endpoints.selected(); ep != endpoints.endpoints[0] {
+		t.Errorf("selected %s without healthy endpoints, want primary", ep.url)
+	}
+}
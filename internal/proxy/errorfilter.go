@@ -0,0 +1,130 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/florianilch/claudine-proxy/internal/observability"
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+// maxRewrittenErrorBody bounds the error bodies rewritten; larger ones pass unchanged.
+const maxRewrittenErrorBody = 64 << 10
+
+// overloadedMessage replaces upstream's terse message of overloaded_error.
+const overloadedMessage = "Anthropic's API is temporarily overloaded. Please retry in a few moments."
+
+// accountPatterns match details in upstream error messages identifying the
+// subscription account behind the proxy.
+var accountPatterns = []*regexp.Regexp{
+	// Organization and account UUIDs
+	regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+	// Email addresses
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	// Prefixed organization and user IDs (org-…, org_…, user_…)
+	regexp.MustCompile(`\b(?:org|user)[-_][A-Za-z0-9]{8,}\b`),
+}
+
+// accountHeaders identify the subscription account and are removed from error responses.
+var accountHeaders = []string{
+	"Anthropic-Organization-Id",
+}
+
+// WithNativeErrorRewrite normalizes JSON error responses of POST /v1/messages
+// before they reach clients: bodies get Anthropic's documented shape and the
+// proxy's request ID, overloaded_error gets a friendlier message, and details
+// identifying the subscription account are removed from messages and headers.
+func WithNativeErrorRewrite(enabled bool) Option {
+	return func(c *config) {
+		c.errorRewrite = enabled
+	}
+}
+
+// rewriteErrors is a ReverseProxy.ModifyResponse hook normalizing error bodies.
+func rewriteErrors(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	for _, h := range accountHeaders {
+		resp.Header.Del(h)
+	}
+	// Compressed bodies, requested by the client, pass unchanged
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRewrittenErrorBody+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxRewrittenErrorBody {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		return nil
+	}
+	_ = resp.Body.Close()
+
+	body := rewriteErrorBody(data, resp.StatusCode, middleware.RequestIDFromContext(resp.Request.Context()))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// rewriteErrorBody formats data as an Anthropic error response. requestID
+// replaces upstream's request ID unless empty.
+func rewriteErrorBody(data []byte, status int, requestID string) []byte {
+	var payload struct {
+		Error     json.RawMessage `json:"error"`
+		Message   string          `json:"message"`
+		RequestID string          `json:"request_id"`
+	}
+	var detail anthropicErrorField
+	if err := json.Unmarshal(data, &payload); err != nil {
+		detail.Message = string(data)
+	} else if err := json.Unmarshal(payload.Error, &detail); err != nil {
+		// "error" may be a plain string; otherwise fall back to a top-level message
+		if json.Unmarshal(payload.Error, &detail.Message) != nil {
+			detail.Message = payload.Message
+		}
+	}
+	if !anthropicErrorTypes[detail.Type] {
+		detail.Type = anthropicErrorType(status)
+	}
+	switch {
+	case detail.Type == "overloaded_error":
+		detail.Message = overloadedMessage
+	case detail.Message == "":
+		detail.Message = http.StatusText(status)
+	default:
+		detail.Message = scrubAccount(detail.Message)
+	}
+	if requestID == "" {
+		requestID = payload.RequestID
+	}
+
+	body, _ := json.Marshal(struct {
+		Type      string              `json:"type"`
+		Error     anthropicErrorField `json:"error"`
+		RequestID string              `json:"request_id,omitempty"`
+	}{"error", detail, requestID})
+	return body
+}
+
+// scrubAccount removes account-identifying details and credentials from s.
+func scrubAccount(s string) string {
+	for _, p := range accountPatterns {
+		s = p.ReplaceAllString(s, "[REDACTED]")
+	}
+	return observability.Scrub(s)
+}
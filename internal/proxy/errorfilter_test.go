@@ -0,0 +1,138 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+func TestRewriteErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		encoding    string
+		requestID   string
+		body        string
+		want        string
+	}{
+		{
+			name:        "request ID added",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			requestID:   "req-1",
+			body:        `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: Field required"},"request_id":"req_011upstream"}`,
+			want:        `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: Field required"},"request_id":"req-1"}`,
+		},
+		{
+			name:        "upstream request ID kept without own",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			body:        `{"type":"error","error":{"type":"invalid_request_error","message":"bad"},"request_id":"req_011upstream"}`,
+			want:        `{"type":"error","error":{"type":"invalid_request_error","message":"bad"},"request_id":"req_011upstream"}`,
+		},
+		{
+			name:        "overloaded",
+			status:      529,
+			contentType: "application/json",
+			requestID:   "req-1",
+			body:        `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			want:        `{"type":"error","error":{"type":"overloaded_error","message":"` + overloadedMessage + `"},"request_id":"req-1"}`,
+		},
+		{
+			name:        "account details stripped",
+			status:      http.StatusForbidden,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"type":"error","error":{"type":"permission_error","message":"Organization 3f2b8c1e-9a4d-4e7f-b6c5-0d1e2f3a4b5c (jane@example.com, org-AbCdEf123456) has no access; key sk-ant-oat01-secret"}}`,
+			want:        `{"type":"error","error":{"type":"permission_error","message":"Organization [REDACTED] ([REDACTED], [REDACTED]) has no access; key sk-ant-[REDACTED]"}}`,
+		},
+		{
+			name:        "unknown error type",
+			status:      http.StatusBadGateway,
+			contentType: "application/json",
+			body:        `{"error":"upstream connect error"}`,
+			want:        `{"type":"error","error":{"type":"api_error","message":"upstream connect error"}}`,
+		},
+		{
+			name:        "empty message",
+			status:      http.StatusTooManyRequests,
+			contentType: "application/json",
+			body:        `{}`,
+			want:        `{"type":"error","error":{"type":"rate_limit_error","message":"Too Many Requests"}}`,
+		},
+		{
+			name:        "success unchanged",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			requestID:   "req-1",
+			body:        `{"type":"message"}`,
+			want:        `{"type":"message"}`,
+		},
+		{
+			name:        "non-JSON unchanged",
+			status:      http.StatusBadGateway,
+			contentType: "text/html",
+			body:        `<html>Bad Gateway</html>`,
+			want:        `<html>Bad Gateway</html>`,
+		},
+		{
+			name:        "compressed unchanged",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			encoding:    "gzip",
+			body:        "\x1f\x8b",
+			want:        "\x1f\x8b",
+		},
+		{
+			name:        "oversized unchanged",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			body:        `{"message":"` + strings.Repeat("a", maxRewrittenErrorBody) + `"}`,
+			want:        `{"message":"` + strings.Repeat("a", maxRewrittenErrorBody) + `"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/v1/messages", nil)
+			if tt.requestID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDContextKey{}, tt.requestID))
+			}
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header: http.Header{
+					"Content-Type":              {tt.contentType},
+					"Content-Length":            {strconv.Itoa(len(tt.body))},
+					"Anthropic-Organization-Id": {"3f2b8c1e-9a4d-4e7f-b6c5-0d1e2f3a4b5c"},
+				},
+				Body:    io.NopCloser(strings.NewReader(tt.body)),
+				Request: req,
+			}
+			if tt.encoding != "" {
+				resp.Header.Set("Content-Encoding", tt.encoding)
+			}
+			if err := rewriteErrors(resp); err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body =\n%s\nwant\n%s", got, tt.want)
+			}
+			if cl := resp.Header.Get("Content-Length"); cl != strconv.Itoa(len(got)) {
+				t.Errorf("Content-Length = %s, want %d", cl, len(got))
+			}
+			if org := resp.Header.Get("Anthropic-Organization-Id"); (org == "") != (tt.status >= http.StatusBadRequest) {
+				t.Errorf("Anthropic-Organization-Id = %q for status %d", org, tt.status)
+			}
+		})
+	}
+}
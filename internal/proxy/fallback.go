@@ -0,0 +1,205 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HeaderCredential reports which credential served the request.
+const HeaderCredential = "X-Claudine-Credential"
+
+// Credential values reported in HeaderCredential.
+const (
+	CredentialSubscription = "subscription"
+	CredentialAPIKey       = "api_key"
+	CredentialClientKey    = "client_key"
+)
+
+// defaultQuotaBackoff applies when an exhausted subscription reports no reset time.
+const defaultQuotaBackoff = time.Minute
+
+// WithFallbackAPIKey sends requests with apiKey while the subscription's usage
+// limits (5-hour or weekly) are exhausted, instead of returning 429.
+func WithFallbackAPIKey(apiKey string) Option {
+	return func(c *config) {
+		c.fallbackAPIKey = apiKey
+	}
+}
+
+// QuotaFallbackTransport is an http.RoundTripper that switches from the
+// subscription to an API key once Anthropic rejects requests for exhausted usage
+// limits, and back after the limit resets. The rejected request is retried with
+// the API key.
+type QuotaFallbackTransport struct {
+	Primary  http.RoundTripper
+	Fallback http.RoundTripper
+	APIKey   string
+
+	// Versions selects the Anthropic-Version header for clients that send none;
+	// nil sends DefaultAnthropicVersion.
+	Versions *AnthropicVersions
+
+	// Spool buffers request bodies for the retry; nil buffers in memory.
+	Spool *BodySpool
+
+	mu             sync.Mutex
+	exhaustedUntil time.Time
+}
+
+// Compile-time check that QuotaFallbackTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*QuotaFallbackTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *QuotaFallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.exhausted() {
+		return t.fallback(req)
+	}
+
+	// Buffer the body so a rejected request can be replayed with the API key
+	body, err := t.Spool.rewind(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Primary.RoundTrip(req)
+	if err != nil || !quotaExhausted(resp) {
+		return closeAfter(resp, err, body)
+	}
+
+	until := quotaReset(resp, time.Now())
+	t.markExhausted(until)
+	slog.WarnContext(req.Context(), "subscription usage limit reached, falling back to API key",
+		"until", until,
+		"limit", resp.Header.Get("Anthropic-Ratelimit-Unified-Representative-Claim"),
+	)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if body != nil {
+		req.Body = body.Reader()
+	}
+	resp, err = t.fallback(req)
+	return closeAfter(resp, err, body)
+}
+
+func (t *QuotaFallbackTransport) fallback(req *http.Request) (*http.Response, error) {
+	setCredential(req.Context(), CredentialAPIKey)
+	newReq := req.Clone(req.Context())
+	newReq.Header.Del("Authorization")
+	newReq.Header.Set("X-Api-Key", t.APIKey)
+	if newReq.Header.Get("Anthropic-Version") == "" {
+		newReq.Header.Set("Anthropic-Version", t.Versions.For(newReq.URL.Path))
+	}
+	return t.Fallback.RoundTrip(newReq)
+}
+
+func (t *QuotaFallbackTransport) exhausted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Now().Before(t.exhaustedUntil)
+}
+
+func (t *QuotaFallbackTransport) markExhausted(until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(t.exhaustedUntil) {
+		t.exhaustedUntil = until
+	}
+}
+
+// quotaExhausted reports whether resp rejects the request for exhausted subscription
+// usage limits, as opposed to short-term rate limiting.
+func quotaExhausted(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests &&
+		resp.Header.Get("Anthropic-Ratelimit-Unified-Status") == "rejected"
+}
+
+// quotaReset derives when the subscription limit resets from the unified reset
+// header (Unix seconds) or retry-after.
+func quotaReset(resp *http.Response, now time.Time) time.Time {
+	if unix, err := strconv.ParseInt(resp.Header.Get("Anthropic-Ratelimit-Unified-Reset"), 10, 64); err == nil {
+		if reset := time.Unix(unix, 0); reset.After(now) {
+			return reset
+		}
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	return now.Add(defaultQuotaBackoff)
+}
+
+type credentialContextKey struct{}
+
+// credentialNote carries the serving credential from the transport back to the handler.
+type credentialNote struct {
+	mu    sync.Mutex
+	value string
+}
+
+// setCredential records the credential used for the request, if the request
+// passed through reportCredential.
+func setCredential(ctx context.Context, credential string) {
+	if note, ok := ctx.Value(credentialContextKey{}).(*credentialNote); ok {
+		note.mu.Lock()
+		note.value = credential
+		note.mu.Unlock()
+	}
+}
+
+// reportCredential adds HeaderCredential to responses. No-op unless enabled.
+func reportCredential(enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			note := &credentialNote{}
+			ctx := context.WithValue(r.Context(), credentialContextKey{}, note)
+			next.ServeHTTP(&credentialWriter{ResponseWriter: w, note: note}, r.WithContext(ctx))
+		})
+	}
+}
+
+// credentialWriter sets HeaderCredential before the response header is written.
+type credentialWriter struct {
+	http.ResponseWriter
+	note        *credentialNote
+	wroteHeader bool
+}
+
+func (cw *credentialWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		cw.note.mu.Lock()
+		if cw.note.value != "" {
+			cw.Header().Set(HeaderCredential, cw.note.value)
+		}
+		cw.note.mu.Unlock()
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *credentialWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher for streaming handlers.
+func (cw *credentialWriter) Flush() {
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (cw *credentialWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
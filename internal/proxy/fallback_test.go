@@ -0,0 +1,67 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestQuotaFallback(t *testing.T) {
+	var subscriptionCalls, apiKeyCalls int
+	var apiKeyBody string
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		header := http.Header{"Content-Type": {"application/json"}}
+		status := http.StatusOK
+		if r.Header.Get("X-Api-Key") == "sk-ant-api03-fallback" {
+			apiKeyCalls++
+			body, _ := io.ReadAll(r.Body)
+			apiKeyBody = string(body)
+		} else {
+			subscriptionCalls++
+			status = http.StatusTooManyRequests
+			header.Set("Anthropic-Ratelimit-Unified-Status", "rejected")
+			header.Set("Anthropic-Ratelimit-Unified-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+			Request:    r,
+		}, nil
+	})
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	p, err := New(ts, readyChecker{}, WithTransport(upstream), WithFallbackAPIKey("sk-ant-api03-fallback"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, wantSubscriptionCalls := range []int{1, 1} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m"}`)))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i, rec.Code)
+		}
+		if got := rec.Header().Get(HeaderCredential); got != CredentialAPIKey {
+			t.Errorf("request %d: %s = %q, want %q", i, HeaderCredential, got, CredentialAPIKey)
+		}
+		if subscriptionCalls != wantSubscriptionCalls {
+			t.Errorf("request %d: subscription calls = %d, want %d", i, subscriptionCalls, wantSubscriptionCalls)
+		}
+		if apiKeyCalls != i+1 {
+			t.Errorf("request %d: API key calls = %d, want %d", i, apiKeyCalls, i+1)
+		}
+		if !strings.Contains(apiKeyBody, `"model":"m"`) {
+			t.Errorf("request %d: replayed body = %s", i, apiKeyBody)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fastPathWindow is how far into a body the fast path looks for the system prompt.
+// Clients put "system" before the messages, so it is usually within the first bytes.
+const fastPathWindow = 4 << 10
+
+var (
+	// bodyReaders buffer request bodies for the fast path check.
+	bodyReaders = sync.Pool{New: func() any { return bufio.NewReaderSize(nil, fastPathWindow) }}
+
+	// copyBuffers hold the chunks passthrough copies.
+	copyBuffers = sync.Pool{New: func() any { return new([32 << 10]byte) }}
+)
+
+// hasSystemPrompt reports whether head, the beginning of a request body, is an object
+// whose first top-level "system" array starts with the prompt block.
+func (in *injector) hasSystemPrompt(head []byte) bool {
+	if trimmed := skipSpace(head); len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+	var s keyScanner
+	_, end := s.scan(head)
+	return end >= 0 && in.startsWithPrompt(head[end:])
+}
+
+// startsWithPrompt reports whether b, following a "system" key, holds an array whose
+// first element is the prompt text block, with its fields in either order.
+func (in *injector) startsWithPrompt(b []byte) bool {
+	b, ok := expect(b, ":", "[", "{")
+	if !ok {
+		return false
+	}
+	rest, ok := expect(b, `"type"`, ":", `"text"`, ",", `"text"`, ":", in.quoted)
+	if !ok {
+		rest, ok = expect(b, `"text"`, ":", in.quoted, ",", `"type"`, ":", `"text"`)
+	}
+	if !ok {
+		return false
+	}
+	rest = skipSpace(rest)
+	return len(rest) > 0 && (rest[0] == '}' || rest[0] == ',')
+}
+
+// expect consumes tokens from b, each optionally preceded by whitespace.
+func expect(b []byte, tokens ...string) ([]byte, bool) {
+	for _, tok := range tokens {
+		b = skipSpace(b)
+		if len(b) < len(tok) || string(b[:len(tok)]) != tok {
+			return nil, false
+		}
+		b = b[len(tok):]
+	}
+	return b, true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func skipSpace(b []byte) []byte {
+	return bytes.TrimLeft(b, " \t\r\n")
+}
+
+// passthrough copies a body whose system prompt is already in place from br to w
+// unchanged. Duplicate keys resolve to the last occurrence upstream, so should a
+// later top-level "system" key follow, the rest of the object is handed to the
+// full injection instead. Bodies are not validated beyond their nesting and
+// trailing data, which is rejected like the full injection does; other syntax
+// errors reach upstream unchanged for it to reject.
+func (in *injector) passthrough(br *bufio.Reader, w io.Writer) error {
+	var (
+		s       keyScanner
+		seen    bool
+		pending []byte // unfinished top-level key held back from the previous read
+	)
+	buf := copyBuffers.Get().(*[32 << 10]byte)
+	defer copyBuffers.Put(buf)
+	for {
+		n, readErr := br.Read(buf[:])
+		data := buf[:n]
+		if len(pending) > 0 {
+			data = append(pending, data...)
+			pending = nil
+		}
+
+		for {
+			start, end := s.scan(data)
+			switch {
+			case end >= 0 && !seen:
+				// The system key hasSystemPrompt checked
+				seen = true
+				if _, err := w.Write(data[:end]); err != nil {
+					return err
+				}
+				data = data[end:]
+				continue
+			case end >= 0:
+				if _, err := w.Write(data[:start]); err != nil {
+					return err
+				}
+				// Resume as an object of the remaining members; its opening brace
+				// was written already
+				rest := io.MultiReader(strings.NewReader("{"), bytes.NewReader(data[start:]), br)
+				return in.inject(rest, &skipByteWriter{w: w})
+			case start >= 0:
+				pending = bytes.Clone(data[start:])
+				data = data[:start]
+			}
+			break
+		}
+		if s.trailing {
+			return errTrailingData
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+
+		if readErr == io.EOF {
+			if len(pending) > 0 || s.depth != 0 || s.inString {
+				// Don't let a truncated body pass as complete
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// skipByteWriter drops the first byte written to it.
+type skipByteWriter struct {
+	w       io.Writer
+	skipped bool
+}
+
+func (sw *skipByteWriter) Write(p []byte) (int, error) {
+	if sw.skipped || len(p) == 0 {
+		return sw.w.Write(p)
+	}
+	sw.skipped = true
+	n, err := sw.w.Write(p[1:])
+	return n + 1, err
+}
+
+// keyScanner finds top-level keys of a JSON object that are, or may decode to,
+// "system" in a stream of bytes, tracking only nesting and string state instead of
+// decoding. Keys with escape sequences are reported as they may spell "system".
+type keyScanner struct {
+	depth     int
+	inString  bool
+	escaped   bool
+	expectKey bool // the next string at depth 1 is a key
+
+	inKey    bool
+	keyStart int
+	key      []byte // up to len("system")+1 bytes of the current key
+	keyBuf   [len("system") + 1]byte
+	keyEsc   bool
+
+	closed   bool // the top-level value ended
+	trailing bool // non-whitespace followed the top-level value
+}
+
+// scan consumes p and returns the position of the first top-level "system" key,
+// p[start:end]. If p ends within a top-level key, scan returns its start and end -1,
+// and expects the key again at the start of the next call. Otherwise start is -1.
+func (s *keyScanner) scan(p []byte) (start, end int) {
+	for i := 0; i < len(p); i++ {
+		if s.inString {
+			if s.escaped {
+				s.escaped = false
+				continue
+			}
+			// Skip ahead to the next quote or escape
+			j := bytes.IndexAny(p[i:], `"\`)
+			if j < 0 {
+				s.appendKey(p[i:])
+				break
+			}
+			s.appendKey(p[i : i+j])
+			i += j
+			if p[i] == '\\' {
+				s.escaped = true
+				s.keyEsc = true
+				continue
+			}
+			s.inString = false
+			if s.inKey {
+				s.inKey = false
+				if s.keyEsc || string(s.key) == "system" {
+					return s.keyStart, i + 1
+				}
+			}
+			continue
+		}
+
+		if s.closed {
+			if !isSpace(p[i]) {
+				s.trailing = true
+				return -1, -1
+			}
+			continue
+		}
+
+		switch p[i] {
+		case '"':
+			s.inString = true
+			if s.expectKey {
+				s.expectKey = false
+				s.inKey = true
+				s.keyStart = i
+				s.key = s.keyBuf[:0]
+				s.keyEsc = false
+			}
+		case '{':
+			s.depth++
+			s.expectKey = s.depth == 1
+		case '[':
+			s.depth++
+			s.expectKey = false
+		case '}', ']':
+			s.depth--
+			s.closed = s.depth == 0
+		case ',':
+			s.expectKey = s.depth == 1
+		}
+	}
+
+	if s.inKey {
+		// Rewind to before the key; the caller passes it again
+		s.inKey, s.inString, s.escaped, s.expectKey = false, false, false, true
+		return s.keyStart, -1
+	}
+	return -1, -1
+}
+
+// appendKey records b if it is part of the current key, keeping only enough
+// bytes to tell whether it is "system".
+func (s *keyScanner) appendKey(b []byte) {
+	if !s.inKey {
+		return
+	}
+	if room := len("system") + 1 - len(s.key); len(b) > room {
+		b = b[:room]
+	}
+	s.key = append(s.key, b...)
+}
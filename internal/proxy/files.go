@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/anthropicclaude"
+)
+
+// maxUploadMemory is the part of a multipart upload kept in memory; the rest is spooled to disk.
+const maxUploadMemory = 32 << 20
+
+// FilesHandler handles OpenAI-compatible Files API requests.
+//
+// Anthropic and OpenAI share the /files paths. Requests carrying an
+// Anthropic-Version header come from Anthropic SDKs and are forwarded to
+// Native unchanged; all others are translated by Adapter.
+type FilesHandler struct {
+	Adapter   *anthropicclaude.FilesAdapter
+	Transport http.RoundTripper
+	Native    http.Handler
+}
+
+// Upload handles POST /files with a multipart "file" and "purpose".
+func (h *FilesHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	if h.serveNative(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			slog.WarnContext(ctx, "request exceeds size limit", "limit_bytes", maxBytesErr.Limit)
+			writeOpenAIErrorStatus(w, r, http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+			return
+		}
+		writeOpenAIErrorStatus(w, r, http.StatusBadRequest, "invalid multipart form: "+err.Error())
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeOpenAIErrorStatus(w, r, http.StatusBadRequest, "missing file")
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	result, err := h.Adapter.Upload(ctx, file, header.Filename, header.Header.Get("Content-Type"), r.FormValue("purpose"), h.Transport)
+	if err != nil {
+		h.writeError(ctx, w, "file upload failed", err)
+		return
+	}
+	writeJSON(ctx, w, result, http.StatusOK)
+}
+
+// List handles GET /files with optional "after" and "limit" query parameters.
+func (h *FilesHandler) List(w http.ResponseWriter, r *http.Request) {
+	if h.serveNative(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	query := r.URL.Query()
+	var limit int64
+	if v := query.Get("limit"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			writeOpenAIErrorStatus(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	result, err := h.Adapter.List(ctx, query.Get("after"), limit, h.Transport)
+	if err != nil {
+		h.writeError(ctx, w, "file listing failed", err)
+		return
+	}
+	writeJSON(ctx, w, result, http.StatusOK)
+}
+
+// Retrieve handles GET /files/{file_id}.
+func (h *FilesHandler) Retrieve(w http.ResponseWriter, r *http.Request) {
+	if h.serveNative(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	result, err := h.Adapter.Retrieve(ctx, r.PathValue("file_id"), h.Transport)
+	if err != nil {
+		h.writeError(ctx, w, "file retrieval failed", err)
+		return
+	}
+	writeJSON(ctx, w, result, http.StatusOK)
+}
+
+// Content handles GET /files/{file_id}/content by streaming the raw file.
+func (h *FilesHandler) Content(w http.ResponseWriter, r *http.Request) {
+	if h.serveNative(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	resp, err := h.Adapter.Content(ctx, r.PathValue("file_id"), h.Transport)
+	if err != nil {
+		h.writeError(ctx, w, "file download failed", err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	for _, key := range []string{"Content-Type", "Content-Length", "Content-Disposition"} {
+		if v := resp.Header.Get(key); v != "" {
+			w.Header().Set(key, v)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		slog.ErrorContext(ctx, "failed to write file content", "error", err)
+	}
+}
+
+// Delete handles DELETE /files/{file_id}.
+func (h *FilesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if h.serveNative(w, r) {
+		return
+	}
+	ctx := r.Context()
+
+	result, err := h.Adapter.Delete(ctx, r.PathValue("file_id"), h.Transport)
+	if err != nil {
+		h.writeError(ctx, w, "file deletion failed", err)
+		return
+	}
+	writeJSON(ctx, w, result, http.StatusOK)
+}
+
+// serveNative forwards requests from Anthropic SDKs unchanged. Returns true if handled.
+func (h *FilesHandler) serveNative(w http.ResponseWriter, r *http.Request) bool {
+	if h.Native == nil || r.Header.Get("Anthropic-Version") == "" {
+		return false
+	}
+	h.Native.ServeHTTP(w, r)
+	return true
+}
+
+// writeError writes adapter errors in OpenAI format.
+func (h *FilesHandler) writeError(ctx context.Context, w http.ResponseWriter, msg string, err error) {
+	slog.ErrorContext(ctx, msg, "error", err)
+
+	var errResp *openaiadapter.ErrorResponse
+	if errors.As(err, &errResp) {
+		writeJSONOpenAIError(ctx, w, errResp)
+		return
+	}
+	writeJSONOpenAIError(ctx, w, &openaiadapter.ErrorResponse{
+		Err: openaiadapter.Error{
+			Message: http.StatusText(http.StatusInternalServerError),
+			Type:    "api_error",
+		},
+	})
+}
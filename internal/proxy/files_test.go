@@ -0,0 +1,101 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestFilesHandler(t *testing.T) {
+	var uploaded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Anthropic-Beta"), "oauth-2025-04-20") {
+			t.Errorf("Anthropic-Beta = %q, want OAuth beta", r.Header.Get("Anthropic-Beta"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/files":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("upstream FormFile: %v", err)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			uploaded = string(content)
+			_, _ = w.Write([]byte(`{"id":"file_01","type":"file","filename":"notes.txt","mime_type":"text/plain","size_bytes":5,"created_at":"2025-01-01T00:00:00Z"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/files":
+			_, _ = w.Write([]byte(`{"data":[{"id":"file_01","type":"file","filename":"notes.txt","mime_type":"text/plain","size_bytes":5,"created_at":"2025-01-01T00:00:00Z"}],"has_more":false,"first_id":"file_01","last_id":"file_01"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("OpenAI upload is translated", func(t *testing.T) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		_ = form.WriteField("purpose", "assistants")
+		part, _ := form.CreateFormFile("file", "notes.txt")
+		_, _ = part.Write([]byte("hello"))
+		_ = form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/files", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var file struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Bytes   int64  `json:"bytes"`
+			Purpose string `json:"purpose"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &file); err != nil {
+			t.Fatal(err)
+		}
+		if file.ID != "file_01" || file.Object != "file" || file.Bytes != 5 || file.Purpose != "assistants" {
+			t.Errorf("file = %+v", file)
+		}
+		if uploaded != "hello" {
+			t.Errorf("uploaded = %q, want hello", uploaded)
+		}
+	})
+
+	t.Run("Anthropic list is forwarded", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/files", nil)
+		req.Header.Set("Anthropic-Version", "2023-06-01")
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		if !strings.Contains(rec.Body.String(), `"mime_type":"text/plain"`) {
+			t.Errorf("body = %s, want native Anthropic response", rec.Body)
+		}
+	})
+
+	t.Run("OpenAI list is translated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/files", nil))
+
+		if !strings.Contains(rec.Body.String(), `"object":"list"`) || !strings.Contains(rec.Body.String(), `"status":"processed"`) {
+			t.Errorf("body = %s, want OpenAI list", rec.Body)
+		}
+	})
+}
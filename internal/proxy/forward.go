@@ -0,0 +1,108 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+// unsupportedOpenAIEndpoints are OpenAI API paths (relative to the upstream path)
+// without an Anthropic equivalent. They answer 501 unless forwarded.
+var unsupportedOpenAIEndpoints = []string{
+	"/completions",
+	"/embeddings",
+	"/moderations",
+	"/images/*",
+	"/audio/*",
+}
+
+// forwardRoute sends matching requests to an alternate upstream (e.g., OpenAI).
+type forwardRoute struct {
+	pattern string
+	baseURL string
+	apiKey  string
+}
+
+// WithForward sends requests matching pattern to baseURL instead of answering 501,
+// e.g. to serve /v1/embeddings from OpenAI. The request path below the proxy's API
+// prefix is appended to baseURL; a trailing "/*" in pattern matches everything below
+// it. If apiKey is set it replaces the client's Authorization header.
+func WithForward(pattern, baseURL, apiKey string) Option {
+	return func(c *config) {
+		c.forwards = append(c.forwards, forwardRoute{pattern: pattern, baseURL: baseURL, apiKey: apiKey})
+	}
+}
+
+// forwardHandler builds a reverse proxy for route. prefix is stripped from request
+// paths before they are appended to the route's base URL.
+func forwardHandler(route forwardRoute, prefix string) (http.Handler, error) {
+	target, err := url.Parse(route.baseURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid forward URL %q for %s", route.baseURL, route.pattern)
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Path = strings.TrimPrefix(pr.In.URL.Path, prefix)
+			pr.Out.URL.RawPath = ""
+			pr.SetURL(target)
+			if route.apiKey != "" {
+				pr.Out.Header.Set("Authorization", "Bearer "+route.apiKey)
+			}
+		},
+		FlushInterval: -1,
+	}, nil
+}
+
+// unsupportedHandler answers OpenAI endpoints the proxy does not implement.
+func unsupportedHandler(w http.ResponseWriter, r *http.Request) {
+	code := "unsupported_endpoint"
+	writeJSON(r.Context(), w, &openaiadapter.ErrorResponse{
+		Err: openaiadapter.Error{
+			Message: fmt.Sprintf("%s %s is not supported by this proxy", r.Method, r.URL.Path),
+			Type:    "invalid_request_error",
+			Code:    &code,
+		},
+	}, http.StatusNotImplemented)
+}
+
+// openAIFallbackRoutes maps mux patterns to forwarding handlers or 501 stubs.
+// Forwards take precedence over stubs for the same path.
+func openAIFallbackRoutes(prefix string, forwards []forwardRoute) (map[string]http.Handler, error) {
+	routes := make(map[string]http.Handler)
+
+	for _, endpoint := range unsupportedOpenAIEndpoints {
+		for _, pattern := range muxPatterns(prefix + endpoint) {
+			routes[pattern] = http.HandlerFunc(unsupportedHandler)
+		}
+	}
+
+	// The first configured forward for a path wins
+	for _, route := range forwards {
+		handler, err := forwardHandler(route, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, pattern := range muxPatterns(route.pattern) {
+			if _, forwarded := routes[pattern].(*httputil.ReverseProxy); !forwarded {
+				routes[pattern] = handler
+			}
+		}
+	}
+
+	return routes, nil
+}
+
+// muxPatterns converts a path pattern with optional trailing "/*" to ServeMux patterns.
+func muxPatterns(pattern string) []string {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return []string{prefix, prefix + "/{rest...}"}
+	}
+	return []string{pattern}
+}
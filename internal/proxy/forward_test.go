@@ -0,0 +1,54 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestOpenAIFallbackRoutes(t *testing.T) {
+	alternate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path + " " + r.Header.Get("Authorization")))
+	}))
+	defer alternate.Close()
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{},
+		WithForward("/v1/embeddings", alternate.URL+"/openai/v1", "sk-alternate"),
+		WithForward("/v1/audio/*", alternate.URL+"/v1", ""),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{path: "/v1/embeddings", wantStatus: http.StatusOK, wantBody: "/openai/v1/embeddings Bearer sk-alternate"},
+		{path: "/v1/audio/transcriptions", wantStatus: http.StatusOK, wantBody: "/v1/audio/transcriptions Bearer client"},
+		{path: "/v1/images/generations", wantStatus: http.StatusNotImplemented, wantBody: `"type":"invalid_request_error"`},
+		{path: "/v1/moderations", wantStatus: http.StatusNotImplemented, wantBody: `"code":"unsupported_endpoint"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer client")
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
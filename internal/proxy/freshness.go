@@ -0,0 +1,90 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimestampHeader is checked by WithRequestFreshness if no header is given.
+const defaultTimestampHeader = "Date"
+
+// WithRequestFreshness rejects API requests whose timestamp header differs from
+// the proxy's clock by more than maxAge, in either direction, for deployments
+// signing requests at the edge: a captured request replayed later is refused.
+// header defaults to Date and may hold an HTTP date, an RFC 3339 timestamp or
+// Unix seconds. Zero maxAge disables the check.
+func WithRequestFreshness(maxAge time.Duration, header string) Option {
+	return func(c *config) {
+		c.maxRequestAge = maxAge
+		c.timestampHeader = header
+	}
+}
+
+// freshContextKey marks requests whose timestamp was already checked, such as
+// chat completions sent over an accepted WebSocket.
+type freshContextKey struct{}
+
+// requireFreshness rejects requests whose timestamp header is missing, invalid or
+// outside maxAge of the current time. No-op if maxAge is zero.
+func requireFreshness(maxAge time.Duration, header string, writeError func(w http.ResponseWriter, r *http.Request, status int, message string)) func(http.Handler) http.Handler {
+	if header == "" {
+		header = defaultTimestampHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		if maxAge <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Context().Value(freshContextKey{}) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			value := r.Header.Get(header)
+			if value == "" {
+				slog.InfoContext(r.Context(), "rejected request without timestamp", "header", header)
+				writeError(w, r, http.StatusUnauthorized, fmt.Sprintf("missing %s header: requests must be timestamped", header))
+				return
+			}
+			ts, err := parseTimestamp(value)
+			if err != nil {
+				slog.InfoContext(r.Context(), "rejected request with invalid timestamp", "header", header, "error", err)
+				writeError(w, r, http.StatusUnauthorized, fmt.Sprintf("invalid %s header: %v", header, err))
+				return
+			}
+			if skew := time.Since(ts); skew > maxAge || skew < -maxAge {
+				slog.WarnContext(r.Context(), "rejected stale request", "header", header, "skew", skew.Round(time.Second))
+				writeError(w, r, http.StatusUnauthorized, fmt.Sprintf(
+					"request expired: %s header is %s off the proxy clock, at most %s is accepted; check for clock skew or a replayed request",
+					header, skew.Abs().Round(time.Second), maxAge))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), freshContextKey{}, true)))
+		})
+	}
+}
+
+// parseTimestamp parses an HTTP date, an RFC 3339 timestamp or Unix seconds.
+func parseTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if ts, err := time.Parse(time.RFC3339, value); err == nil {
+		return ts, nil
+	}
+	if ts, err := http.ParseTime(value); err == nil {
+		return ts, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither an HTTP date, an RFC 3339 timestamp nor Unix seconds", value)
+}
@@ -0,0 +1,105 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequireFreshness(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		maxAge     time.Duration
+		header     string
+		set        map[string]string
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "disabled",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "fresh date",
+			maxAge:     time.Minute,
+			set:        map[string]string{"Date": now.UTC().Format(http.TimeFormat)},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "stale date",
+			maxAge:     time.Minute,
+			set:        map[string]string{"Date": now.Add(-5 * time.Minute).UTC().Format(http.TimeFormat)},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "request expired: Date header is 5m",
+		},
+		{
+			name:       "date from the future",
+			maxAge:     time.Minute,
+			set:        map[string]string{"Date": now.Add(5 * time.Minute).UTC().Format(http.TimeFormat)},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "request expired",
+		},
+		{
+			name:       "missing",
+			maxAge:     time.Minute,
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "missing Date header",
+		},
+		{
+			name:       "unix seconds",
+			maxAge:     time.Minute,
+			header:     "X-Timestamp",
+			set:        map[string]string{"X-Timestamp": strconv.FormatInt(now.Unix(), 10)},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "rfc 3339",
+			maxAge:     time.Minute,
+			header:     "X-Timestamp",
+			set:        map[string]string{"X-Timestamp": now.Format(time.RFC3339)},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "configured header only",
+			maxAge:     time.Minute,
+			header:     "X-Timestamp",
+			set:        map[string]string{"Date": now.UTC().Format(http.TimeFormat)},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "missing X-Timestamp header",
+		},
+		{
+			name:       "invalid",
+			maxAge:     time.Minute,
+			header:     "X-Timestamp",
+			set:        map[string]string{"X-Timestamp": "yesterday"},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "invalid X-Timestamp header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := requireFreshness(tt.maxAge, tt.header, writeAnthropicErrorStatus)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			for k, v := range tt.set {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want error containing %q", rec.Body.String(), tt.wantError)
+			}
+		})
+	}
+}
@@ -1,6 +1,16 @@
 package proxy
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DegradationReporter is optionally implemented by a ReadinessChecker to explain
+// why the application is not ready, e.g. a revoked token.
+type DegradationReporter interface {
+	// Degraded returns the degraded components and their reasons.
+	Degraded() map[string]string
+}
 
 // livenessHandler handles liveness probe requests.
 // Always returns 200 OK to indicate the process is alive.
@@ -13,13 +23,22 @@ func livenessHandler() http.HandlerFunc {
 
 // readinessHandler handles readiness probe requests.
 // Returns 200 OK if the application is ready to serve traffic, 503 otherwise.
+// Degraded components are listed in a JSON body.
 func readinessHandler(checker ReadinessChecker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "no-cache")
 		if checker.IsReady() {
 			w.WriteHeader(http.StatusOK)
-		} else {
-			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if reporter, ok := checker.(DegradationReporter); ok {
+			if degraded := reporter.Degraded(); len(degraded) > 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_ = json.NewEncoder(w).Encode(map[string]any{"status": "degraded", "degraded": degraded})
+				return
+			}
 		}
+		w.WriteHeader(http.StatusServiceUnavailable)
 	}
 }
@@ -0,0 +1,126 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithStreamIdleTimeout terminates streaming responses that receive no upstream
+// data for d, ending them with an Anthropic error event (OpenAI clients receive an
+// error chunk) instead of leaving clients hanging until the server's write timeout.
+// Zero or negative disables the watchdog.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.streamIdleTimeout = d
+	}
+}
+
+// errStreamIdle marks a stream closed by the idle watchdog.
+var errStreamIdle = errors.New("stream idle timeout")
+
+// StreamIdleTransport is an http.RoundTripper that watches SSE responses and closes
+// those stalled for longer than Timeout. Non-streaming responses pass through.
+type StreamIdleTransport struct {
+	Base    http.RoundTripper
+	Timeout time.Duration
+}
+
+// Compile-time check that StreamIdleTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*StreamIdleTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *StreamIdleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil || t.Timeout <= 0 {
+		return resp, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		return resp, nil
+	}
+
+	body := &idleBody{body: resp.Body, timeout: t.Timeout}
+	body.timer = time.AfterFunc(t.Timeout, func() {
+		body.idle.Store(true)
+		slog.WarnContext(req.Context(), "upstream stream idle, closing", "timeout", t.Timeout)
+		_ = body.body.Close()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+// idleBody closes the upstream body when no data arrives within timeout and
+// replaces the read error with a terminating SSE error event.
+type idleBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	idle    atomic.Bool
+
+	// pending holds the unread part of the error event after a timeout
+	pending   []byte
+	closeOnce sync.Once
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	if b.pending != nil {
+		return b.readPending(p)
+	}
+
+	n, err := b.body.Read(p)
+	if b.idle.Load() {
+		// Deliver data read around the timeout first; the event starts after a blank line
+		b.pending = idleErrorEvent(b.timeout)
+		if n > 0 {
+			b.pending = append([]byte("\n\n"), b.pending...)
+			return n, nil
+		}
+		return b.readPending(p)
+	}
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil {
+		b.timer.Stop()
+	}
+	return n, err
+}
+
+func (b *idleBody) readPending(p []byte) (int, error) {
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	if len(b.pending) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (b *idleBody) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.timer.Stop()
+		if !b.idle.Load() {
+			err = b.body.Close()
+		}
+	})
+	return err
+}
+
+// idleErrorEvent formats the Anthropic SSE error event sent when a stream stalls.
+// The Anthropic SDK surfaces it as a stream error, which the OpenAI adapter maps
+// to an error chunk.
+func idleErrorEvent(timeout time.Duration) []byte {
+	return fmt.Appendf(nil,
+		"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"timeout_error\",\"message\":\"%v: no data from upstream for %s\"}}\n\n",
+		errStreamIdle, timeout)
+}
@@ -0,0 +1,79 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestStreamIdleTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		want []string
+	}{
+		{
+			name: "messages",
+			path: "/v1/messages",
+			body: `{"model":"claude-sonnet-4-5","max_tokens":10,"stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{"event: message_start", "event: error", `"type":"timeout_error"`},
+		},
+		{
+			name: "chat completions",
+			path: "/v1/chat/completions",
+			body: `{"model":"claude-sonnet-4-5","stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{"event: error", `"type":"server_error"`, "stream idle timeout"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				pr, pw := io.Pipe()
+				go func() {
+					// Send the first event, then stall without closing the stream
+					_, _ = io.WriteString(pw, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"claude-sonnet-4-5\",\"content\":[],\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n")
+				}()
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/event-stream"}},
+					Body:       pr,
+					Request:    r,
+				}, nil
+			})
+
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+			p, err := New(ts, readyChecker{}, WithTransport(upstream), WithStreamIdleTimeout(50*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			done := make(chan struct{})
+			rec := httptest.NewRecorder()
+			go func() {
+				defer close(done)
+				p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream was not terminated")
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body missing %q:\n%s", want, rec.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -3,9 +3,14 @@
 package proxy
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"encoding/json/jsontext"
+	"errors"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
 	"slices"
 	"strings"
@@ -13,10 +18,105 @@ import (
 
 const claudeCodeSystemPrompt = "You are Claude Code, Anthropic's official CLI for Claude."
 
+// claudeCodeVersion is the Claude Code release the claude-code profile matches.
+const claudeCodeVersion = "2.0.14"
+
+// ImpersonationProfile describes the client fingerprint presented to Anthropic.
+// Besides the system prompt and beta features OAuth requires, a profile can
+// mimic a specific Claude Code release down to its headers and metadata.
+type ImpersonationProfile struct {
+	Name string
+
+	// SystemPrompt is ensured as the first system block of Messages requests.
+	SystemPrompt string
+
+	// BetaFeatures are sent in addition to the features OAuth requires.
+	BetaFeatures []string
+
+	// UserAgent replaces the client's User-Agent; empty sends Go's default.
+	UserAgent string
+
+	// App is sent as x-app header; empty omits it.
+	App string
+
+	// Headers are set on every request, e.g. the X-Stainless-* headers of the SDK
+	// Claude Code is built with.
+	Headers map[string]string
+
+	// UserID is sent as metadata.user_id of Messages requests without one.
+	UserID string
+}
+
+// DefaultImpersonationProfile is the profile used unless configured otherwise.
+const DefaultImpersonationProfile = "minimal"
+
+// impersonationProfiles are the built-in profiles by name.
+var impersonationProfiles = map[string]ImpersonationProfile{
+	// minimal sends only what OAuth requires
+	"minimal": {
+		Name:         "minimal",
+		SystemPrompt: claudeCodeSystemPrompt,
+	},
+	// claude-code matches the requests of the Claude Code CLI
+	"claude-code": {
+		Name:         "claude-code",
+		SystemPrompt: claudeCodeSystemPrompt,
+		BetaFeatures: []string{"interleaved-thinking-2025-05-14", "fine-grained-tool-streaming-2025-05-14"},
+		UserAgent:    "claude-cli/" + claudeCodeVersion + " (external, cli)",
+		App:          "cli",
+		Headers: map[string]string{
+			"Anthropic-Dangerous-Direct-Browser-Access": "true",
+			"X-Stainless-Arch":                          "x64",
+			"X-Stainless-Lang":                          "js",
+			"X-Stainless-Os":                            "Linux",
+			"X-Stainless-Package-Version":               "0.60.0",
+			"X-Stainless-Retry-Count":                   "0",
+			"X-Stainless-Runtime":                       "node",
+			"X-Stainless-Runtime-Version":               "v22.19.0",
+			"X-Stainless-Timeout":                       "600",
+		},
+	},
+}
+
+// LookupImpersonationProfile returns a copy of the built-in profile name.
+func LookupImpersonationProfile(name string) (ImpersonationProfile, bool) {
+	p, ok := impersonationProfiles[name]
+	if !ok {
+		return ImpersonationProfile{}, false
+	}
+	p.BetaFeatures = slices.Clone(p.BetaFeatures)
+	p.Headers = maps.Clone(p.Headers)
+	return p, true
+}
+
+// WithImpersonationProfile sets the client fingerprint presented to Anthropic.
+func WithImpersonationProfile(p ImpersonationProfile) Option {
+	return func(c *config) {
+		c.impersonation = &p
+	}
+}
+
+// WithTolerantInjection forwards request bodies unchanged with a warning when the
+// system prompt cannot be injected, instead of failing the request. Bodies are
+// buffered in memory rather than streamed.
+func WithTolerantInjection(enabled bool) Option {
+	return func(c *config) {
+		c.tolerantInjection = enabled
+	}
+}
+
 var (
 	systemPromptElement = mustMarshal(map[string]string{"type": "text", "text": claudeCodeSystemPrompt})
 	systemPromptArray   = mustMarshal([]json.RawMessage{systemPromptElement})
 
+	// defaultInjector injects the Claude Code system prompt only.
+	defaultInjector = &injector{
+		prompt:  claudeCodeSystemPrompt,
+		quoted:  string(mustMarshal(claudeCodeSystemPrompt)),
+		element: systemPromptElement,
+		array:   systemPromptArray,
+	}
+
 	// requiredBetaFeatures are beta features required for OAuth to work
 	requiredBetaFeatures = map[string]struct{}{
 		"oauth-2025-04-20":     {},
@@ -49,8 +149,32 @@ var (
 // ImpersonationTransport is an http.RoundTripper that impersonates Claude Code.
 type ImpersonationTransport struct {
 	Base http.RoundTripper
+
+	// HeadersOnly skips system prompt injection, for endpoints whose bodies
+	// are not Messages requests (e.g., file uploads). Token counting requests
+	// are still transformed so counts match what message creation sends.
+	HeadersOnly bool
+
+	// Profile is the client fingerprint to present; nil sends only what OAuth requires.
+	Profile *ImpersonationProfile
+
+	// Tolerant buffers request bodies and forwards them unchanged with a warning
+	// if the system prompt cannot be injected, instead of failing the request.
+	Tolerant bool
+
+	// Spool buffers request bodies in Tolerant mode; nil buffers in memory.
+	Spool *BodySpool
+
+	// Versions selects the Anthropic-Version header; nil sends DefaultAnthropicVersion.
+	Versions *AnthropicVersions
 }
 
+// countTokensPathSuffix identifies Messages token counting requests (/v1/messages/count_tokens).
+const countTokensPathSuffix = "/messages/count_tokens"
+
+// batchesPathSuffix identifies Message Batches creation requests (/v1/messages/batches).
+const batchesPathSuffix = "/messages/batches"
+
 // Compile-time check that ImpersonationTransport implements http.RoundTripper.
 var _ http.RoundTripper = (*ImpersonationTransport)(nil)
 
@@ -76,14 +200,45 @@ func (t *ImpersonationTransport) RoundTrip(req *http.Request) (*http.Response, e
 	}
 
 	// Set required Anthropic API version and merge beta features
-	newReq.Header.Set("Anthropic-Version", "2023-06-01")
-	incomingBetaHeaderValue := newReq.Header.Get("Anthropic-Beta")
-	newReq.Header.Set("Anthropic-Beta", buildBetaHeader(incomingBetaHeaderValue))
+	newReq.Header.Set("Anthropic-Version", t.Versions.For(newReq.URL.Path))
+	// Clients may send beta features as repeated headers, e.g. the SDKs' betas parameter
+	incomingBetaHeaderValue := strings.Join(newReq.Header.Values("Anthropic-Beta"), ",")
+	inject := defaultInjector
+	if p := t.Profile; p != nil {
+		newReq.Header.Set("Anthropic-Beta", buildBetaHeader(incomingBetaHeaderValue, p.BetaFeatures...))
+		for key, value := range p.Headers {
+			newReq.Header.Set(key, value)
+		}
+		if p.UserAgent != "" {
+			newReq.Header.Set("User-Agent", p.UserAgent)
+		}
+		if p.App != "" {
+			newReq.Header.Set("X-App", p.App)
+		}
+		inject = newInjector(p.SystemPrompt, p.UserID)
+	} else {
+		newReq.Header.Set("Anthropic-Beta", buildBetaHeader(incomingBetaHeaderValue))
+	}
 
-	// Skip body transformation for non-POST requests or requests without bodies
-	if req.Method != http.MethodPost || req.Body == nil {
+	// Skip body transformation for passthrough endpoints other than token counting
+	// and batches, non-POST requests or requests without bodies
+	countTokens := strings.HasSuffix(req.URL.Path, countTokensPathSuffix)
+	batch := strings.HasSuffix(req.URL.Path, batchesPathSuffix)
+	if (t.HeadersOnly && !countTokens && !batch) || req.Method != http.MethodPost || req.Body == nil {
 		return base.RoundTrip(newReq)
 	}
+	if countTokens && inject.userID != "" {
+		// count_tokens rejects metadata
+		inject = &injector{prompt: inject.prompt, quoted: inject.quoted, element: inject.element, array: inject.array}
+	}
+	transform := inject.inject
+	if batch {
+		transform = inject.injectBatch
+	}
+
+	if t.Tolerant {
+		return t.roundTripBuffered(base, req, newReq, transform)
+	}
 
 	// Create pipe for streaming body transformation
 	pr, pw := io.Pipe()
@@ -92,7 +247,7 @@ func (t *ImpersonationTransport) RoundTrip(req *http.Request) (*http.Response, e
 	// Note: No goroutine leak on context cancellation. When http.Transport cancels
 	// the request, it closes pr, which unblocks all writes to pw with ErrClosedPipe.
 	go func() {
-		err := injectSystemPrompt(req.Body, pw)
+		err := transform(req.Body, pw)
 		// Propagate transformation error (if any) or signal success to reader
 		pw.CloseWithError(err)
 		_ = req.Body.Close()
@@ -108,7 +263,72 @@ func (t *ImpersonationTransport) RoundTrip(req *http.Request) (*http.Response, e
 	return base.RoundTrip(newReq)
 }
 
-// injectSystemPrompt uses encoding/json/jsontext for streaming JSON transformation.
+// roundTripBuffered transforms the whole body before sending newReq, falling back
+// to the original body if it cannot be transformed. Both are buffered in the
+// spool until the response body is closed.
+func (t *ImpersonationTransport) roundTripBuffered(base http.RoundTripper, req, newReq *http.Request, transform func(io.Reader, io.Writer) error) (*http.Response, error) {
+	original, err := t.Spool.buffer(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	transformed := &spooledBody{spool: t.Spool}
+	if err := transform(original.Reader(), transformed); err != nil {
+		slog.WarnContext(req.Context(), "system prompt injection failed, forwarding body unchanged", "error", err)
+		_ = transformed.Close()
+		transformed = original
+	} else {
+		_ = original.Close()
+	}
+
+	newReq.Body = transformed.Reader()
+	newReq.GetBody = func() (io.ReadCloser, error) {
+		return transformed.Reader(), nil
+	}
+	newReq.ContentLength = transformed.Len()
+	newReq.Header.Del("Content-Length")
+
+	resp, err := base.RoundTrip(newReq)
+	if err != nil {
+		_ = transformed.Close()
+		return nil, err
+	}
+	resp.Body = &countedBody{ReadCloser: resp.Body, done: func() { _ = transformed.Close() }}
+	return resp, nil
+}
+
+// injector ensures a system prompt and, if userID is set, a default metadata.user_id
+// in Messages request bodies.
+type injector struct {
+	prompt  string
+	quoted  string // prompt as JSON string, for the fast path
+	element []byte // pre-marshaled system text block
+	array   []byte // pre-marshaled system array holding only element
+	userID  string
+}
+
+// newInjector creates an injector for prompt and userID.
+func newInjector(prompt, userID string) *injector {
+	if prompt == claudeCodeSystemPrompt && userID == "" {
+		return defaultInjector
+	}
+	element := mustMarshal(map[string]string{"type": "text", "text": prompt})
+	return &injector{
+		prompt:  prompt,
+		quoted:  string(mustMarshal(prompt)),
+		element: element,
+		array:   mustMarshal([]json.RawMessage{element}),
+		userID:  userID,
+	}
+}
+
+// injectSystemPrompt ensures the Claude Code system prompt in a Messages request body.
+func injectSystemPrompt(r io.Reader, w io.Writer) error {
+	return defaultInjector.inject(r, w)
+}
+
+// inject uses encoding/json/jsontext for streaming JSON transformation.
 //
 // We need to inject a system prompt into API requests without buffering the entire
 // request in memory. The standard library's json.Decoder.Token() strips formatting
@@ -123,65 +343,123 @@ func (t *ImpersonationTransport) RoundTrip(req *http.Request) (*http.Response, e
 // - Token-level streaming: Efficient for fields we don't modify (most of the request)
 // - Value-level handling: Only for "system" field which needs inspection/modification
 //
-// If "system" not found during object traversal, inject before closing brace.
-func injectSystemPrompt(r io.Reader, w io.Writer) error {
-	dec := jsontext.NewDecoder(r)
-	enc := jsontext.NewEncoder(w)
+// Unmodified fields are streamed down to individual content blocks (see streamDepth),
+// so long conversations are never held in memory at once.
+//
+// Clients may send a top-level key more than once. Like most JSON parsers upstream,
+// the last occurrence wins, so "system" (and "metadata" with a user ID to set) are
+// held back and written once before the closing brace.
+//
+// Arrays are taken as batches of requests, each object element getting the
+// prompt; other bodies are passed through unchanged for upstream to reject.
+//
+// Most clients resend the prompt they were given, so bodies whose system array
+// already starts with it skip decoding and are copied as raw bytes (see passthrough).
+func (in *injector) inject(r io.Reader, w io.Writer) error {
+	br := bodyReaders.Get().(*bufio.Reader)
+	br.Reset(r)
+	defer func() {
+		br.Reset(nil)
+		bodyReaders.Put(br)
+	}()
 
-	tok, err := dec.ReadToken()
+	if head, _ := br.Peek(fastPathWindow); in.userID == "" && in.hasSystemPrompt(head) {
+		return in.passthrough(br, w)
+	}
+
+	dec := jsontext.NewDecoder(br, jsontext.AllowDuplicateNames(true))
+	enc := jsontext.NewEncoder(w, jsontext.AllowDuplicateNames(true))
+
+	var err error
+	switch dec.PeekKind() {
+	case '{':
+		err = in.injectObject(dec, enc)
+	case '[':
+		err = eachObject(dec, enc, in.injectObject)
+	default:
+		err = copyValue(dec, enc, streamDepth)
+	}
+	if err != nil {
+		return err
+	}
+	return expectEOF(dec)
+}
+
+// injectBatch ensures the system prompt in the params of each request of a
+// Message Batches body ({"requests": [{"custom_id": …, "params": {…}}]}).
+// Other members and bodies of another shape are passed through unchanged.
+func (in *injector) injectBatch(r io.Reader, w io.Writer) error {
+	dec := jsontext.NewDecoder(r, jsontext.AllowDuplicateNames(true))
+	enc := jsontext.NewEncoder(w, jsontext.AllowDuplicateNames(true))
+
+	injectRequest := func(dec *jsontext.Decoder, enc *jsontext.Encoder) error {
+		return editMember(dec, enc, "params", '{', in.injectObject)
+	}
+	var err error
+	if dec.PeekKind() == '{' {
+		err = editMember(dec, enc, "requests", '[', func(dec *jsontext.Decoder, enc *jsontext.Encoder) error {
+			return eachObject(dec, enc, injectRequest)
+		})
+	} else {
+		err = copyValue(dec, enc, streamDepth)
+	}
 	if err != nil {
 		return err
 	}
-	if tok.Kind() != '{' {
-		return enc.WriteToken(tok) // Not an object, pass through
+	return expectEOF(dec)
+}
+
+// injectObject ensures the system prompt in the object dec is positioned at.
+func (in *injector) injectObject(dec *jsontext.Decoder, enc *jsontext.Encoder) error {
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return err
 	}
 	if err := enc.WriteToken(tok); err != nil {
 		return err
 	}
 
-	foundSystem := false
-
+	var system, metadata jsontext.Value
 	for dec.PeekKind() != '}' {
 		key, err := dec.ReadToken()
 		if err != nil {
 			return err
 		}
 
-		// Write key (encoder automatically adds : after it)
-		if err := enc.WriteToken(key); err != nil {
-			return err
-		}
-
-		// String tokens have Kind() == '"'
-		if key.Kind() == '"' && key.String() == "system" {
-			foundSystem = true
-
-			systemVal, err := dec.ReadValue()
-			if err != nil {
+		switch name := key.String(); {
+		case name == "system":
+			if system, err = dec.ReadValue(); err != nil {
 				return err
 			}
-
-			if err := ensureSystemPrompt(enc, systemVal); err != nil {
+			// ReadValue's result is only valid until the next read
+			system = bytes.Clone(system)
+		case name == "metadata" && in.userID != "":
+			if metadata, err = dec.ReadValue(); err != nil {
 				return err
 			}
-		} else {
-			// Stream other fields unchanged
-			val, err := dec.ReadValue()
-			if err != nil {
+			metadata = bytes.Clone(metadata)
+		default:
+			// Write key (encoder automatically adds : after it)
+			if err := enc.WriteToken(key); err != nil {
 				return err
 			}
-			if err := enc.WriteValue(val); err != nil {
+			if err := copyValue(dec, enc, streamDepth); err != nil {
 				return err
 			}
 		}
 	}
 
-	// Streaming constraint: inject system at end if not found during traversal
-	if !foundSystem {
-		if err := enc.WriteToken(jsontext.String("system")); err != nil {
+	if err := enc.WriteToken(jsontext.String("system")); err != nil {
+		return err
+	}
+	if err := in.ensureSystemPrompt(enc, system); err != nil {
+		return err
+	}
+	if in.userID != "" {
+		if err := enc.WriteToken(jsontext.String("metadata")); err != nil {
 			return err
 		}
-		if err := enc.WriteValue(jsontext.Value(systemPromptArray)); err != nil {
+		if err := enc.WriteValue(in.ensureUserID(metadata)); err != nil {
 			return err
 		}
 	}
@@ -193,25 +471,157 @@ func injectSystemPrompt(r io.Reader, w io.Writer) error {
 	return enc.WriteToken(tok)
 }
 
+// eachObject copies the array dec is positioned at, passing object elements to
+// edit and copying others.
+func eachObject(dec *jsontext.Decoder, enc *jsontext.Encoder, edit func(*jsontext.Decoder, *jsontext.Encoder) error) error {
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	if err := enc.WriteToken(tok); err != nil {
+		return err
+	}
+	for dec.PeekKind() != ']' {
+		if dec.PeekKind() == '{' {
+			err = edit(dec, enc)
+		} else {
+			err = copyValue(dec, enc, streamDepth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	tok, err = dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	return enc.WriteToken(tok)
+}
+
+// editMember copies the object dec is positioned at, passing the values of
+// members named name and of the given kind to edit.
+func editMember(dec *jsontext.Decoder, enc *jsontext.Encoder, name string, kind jsontext.Kind, edit func(*jsontext.Decoder, *jsontext.Encoder) error) error {
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	if err := enc.WriteToken(tok); err != nil {
+		return err
+	}
+	for dec.PeekKind() != '}' {
+		key, err := dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		if err := enc.WriteToken(key); err != nil {
+			return err
+		}
+		if key.String() == name && dec.PeekKind() == kind {
+			err = edit(dec, enc)
+		} else {
+			err = copyValue(dec, enc, streamDepth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	tok, err = dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	return enc.WriteToken(tok)
+}
+
+// streamDepth is how deep copyValue streams nested arrays and objects token by token
+// before copying values whole: request fields, messages and their content blocks.
+// Memory use is bounded by the largest content block rather than the request.
+const streamDepth = 3
+
+// copyValue copies the next value from dec to enc, streaming arrays and objects
+// up to depth levels deep.
+func copyValue(dec *jsontext.Decoder, enc *jsontext.Encoder, depth int) error {
+	kind := dec.PeekKind()
+	if depth == 0 || (kind != '{' && kind != '[') {
+		val, err := dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		return enc.WriteValue(val)
+	}
+
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	if err := enc.WriteToken(tok); err != nil {
+		return err
+	}
+	end := kind + 2 // '{' -> '}', '[' -> ']'
+	for dec.PeekKind() != end {
+		if kind == '{' {
+			key, err := dec.ReadToken()
+			if err != nil {
+				return err
+			}
+			if err := enc.WriteToken(key); err != nil {
+				return err
+			}
+		}
+		if err := copyValue(dec, enc, depth-1); err != nil {
+			return err
+		}
+	}
+	if tok, err = dec.ReadToken(); err != nil {
+		return err
+	}
+	return enc.WriteToken(tok)
+}
+
+// errTrailingData reports data after the request body's top-level value.
+var errTrailingData = errors.New("invalid request body: unexpected data after top-level value")
+
+// expectEOF verifies that dec holds nothing but whitespace after the top-level value,
+// so concatenated values never reach upstream truncated.
+func expectEOF(dec *jsontext.Decoder) error {
+	_, err := dec.ReadToken()
+	switch err {
+	case io.EOF:
+		return nil
+	case nil:
+		return errTrailingData
+	default:
+		return err
+	}
+}
+
 // ensureSystemPrompt checks if prompt is the first element and adds it if not.
 // Writes directly to the encoder to avoid intermediate allocations.
-func ensureSystemPrompt(enc *jsontext.Encoder, systemVal jsontext.Value) error {
+// A nil systemVal means the request has no system field.
+func (in *injector) ensureSystemPrompt(enc *jsontext.Encoder, systemVal jsontext.Value) error {
+	if systemVal.Kind() == '"' {
+		// Plain string system prompt: keep it as text block after prompt
+		var text string
+		if err := json.Unmarshal(systemVal, &text); err == nil && text != "" && text != in.prompt {
+			systemVal = mustMarshal([]map[string]string{{"type": "text", "text": text}})
+		}
+	}
+
 	// Try to parse as array
 	var systemArray []json.RawMessage
 	if err := json.Unmarshal([]byte(systemVal), &systemArray); err != nil {
-		// Not a valid array, replace with pre-marshaled array
-		return enc.WriteValue(jsontext.Value(systemPromptArray))
+		// Absent or not a valid array, replace with pre-marshaled array
+		return enc.WriteValue(jsontext.Value(in.array))
 	}
 
 	// Check if empty
 	if len(systemArray) == 0 {
-		return enc.WriteValue(jsontext.Value(systemPromptArray))
+		return enc.WriteValue(jsontext.Value(in.array))
 	}
 
 	// Check first element
 	var firstElem map[string]string
 	if err := json.Unmarshal(systemArray[0], &firstElem); err == nil {
-		if firstElem["type"] == "text" && firstElem["text"] == claudeCodeSystemPrompt {
+		if firstElem["type"] == "text" && firstElem["text"] == in.prompt {
 			// Already has prompt, return unchanged
 			return enc.WriteValue(systemVal)
 		}
@@ -221,7 +631,7 @@ func ensureSystemPrompt(enc *jsontext.Encoder, systemVal jsontext.Value) error {
 	if err := enc.WriteToken(jsontext.BeginArray); err != nil {
 		return err
 	}
-	if err := enc.WriteValue(jsontext.Value(systemPromptElement)); err != nil {
+	if err := enc.WriteValue(jsontext.Value(in.element)); err != nil {
 		return err
 	}
 	for _, elem := range systemArray {
@@ -232,10 +642,42 @@ func ensureSystemPrompt(enc *jsontext.Encoder, systemVal jsontext.Value) error {
 	return enc.WriteToken(jsontext.EndArray)
 }
 
+// ensureUserID returns metadata with user_id set to the injector's user ID unless
+// it carries one. Values other than objects are replaced.
+func (in *injector) ensureUserID(metadata jsontext.Value) jsontext.Value {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(metadata, &fields); err != nil || fields == nil {
+		fields = make(map[string]json.RawMessage, 1)
+	}
+	var userID string
+	if err := json.Unmarshal(fields["user_id"], &userID); err == nil && userID != "" {
+		return metadata
+	}
+	fields["user_id"] = mustMarshal(in.userID)
+	return mustMarshal(fields)
+}
+
 // buildBetaHeader constructs the Anthropic-Beta header by ensuring required features
-// are always present, then appending any additional client-specified features.
+// are always present, followed by extra features of the impersonation profile and
+// any additional client-specified features.
 // Uses package globals requiredBetaHeader and requiredBetaFeatures.
-func buildBetaHeader(headerValue string) string {
+func buildBetaHeader(headerValue string, extra ...string) string {
+	if len(extra) > 0 {
+		// Rare path: deduplicate profile and client features
+		features := slices.Clone(extra)
+		for feature := range strings.SplitSeq(headerValue, ",") {
+			features = append(features, strings.TrimSpace(feature))
+		}
+		seen := make(map[string]bool, len(features))
+		unique := features[:0]
+		for _, feature := range features {
+			if feature != "" && !seen[feature] {
+				seen[feature] = true
+				unique = append(unique, feature)
+			}
+		}
+		headerValue = strings.Join(unique, ",")
+	}
 	if headerValue == "" {
 		return requiredBetaHeader
 	}
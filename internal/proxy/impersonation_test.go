@@ -5,12 +5,15 @@ package proxy
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/json/jsontext"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 const (
@@ -213,6 +216,62 @@ func TestSystemInjector(t *testing.T) {
 				]
 			}`,
 		},
+		{
+			name: "duplicate system keys - last wins",
+			input: `{
+				"system": [{"type": "text", "text": "First"}],
+				"model": "claude-3-sonnet",
+				"system": [{"type": "text", "text": "Second"}]
+			}`,
+			expected: `{
+				"model": "claude-3-sonnet",
+				"system": [
+					{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."},
+					{"type": "text", "text": "Second"}
+				]
+			}`,
+		},
+		{
+			name:  "string system - kept after prompt",
+			input: `{"model": "claude-3-sonnet", "system": "Be brief."}`,
+			expected: `{
+				"model": "claude-3-sonnet",
+				"system": [
+					{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."},
+					{"type": "text", "text": "Be brief."}
+				]
+			}`,
+		},
+		{
+			name:  "null system - replaced",
+			input: `{"model": "claude-3-sonnet", "system": null}`,
+			expected: `{
+				"model": "claude-3-sonnet",
+				"system": [
+					{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."}
+				]
+			}`,
+		},
+		{
+			name:     "array body - each object injected",
+			input:    `[{"model": "claude-3-sonnet"}, 1, "two", {"system": "Be brief."}]`,
+			expected: `[{"model": "claude-3-sonnet", "system": [{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."}]}, 1, "two", {"system": [{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."}, {"type": "text", "text": "Be brief."}]}]`,
+		},
+		{
+			name:     "scalar body - passed through",
+			input:    `"hello"`,
+			expected: `"hello"`,
+		},
+		{
+			name:  "duplicate nested keys - passed through",
+			input: `{"messages": [{"role": "user", "role": "user", "content": "Hi"}]}`,
+			expected: `{
+				"messages": [{"role": "user", "content": "Hi"}],
+				"system": [
+					{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."}
+				]
+			}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -302,6 +361,31 @@ func TestSystemInjectorEdgeCases(t *testing.T) {
 			input:     `{"model": "claude-3", "config": {"system": []}}`,
 			expectErr: false,
 		},
+		{
+			name:      "empty body",
+			input:     ``,
+			expectErr: true,
+		},
+		{
+			name:      "trailing data after object",
+			input:     `{"model": "claude-3"} {"system": []}`,
+			expectErr: true,
+		},
+		{
+			name:      "trailing whitespace",
+			input:     "{\"model\": \"claude-3\"}\n",
+			expectErr: false,
+		},
+		{
+			name:      "invalid JSON inside messages",
+			input:     `{"messages": [{"role": "user", "content": [1, }]}`,
+			expectErr: true,
+		},
+		{
+			name:      "deeply nested value",
+			input:     `{"metadata": ` + strings.Repeat("[", 5000) + strings.Repeat("]", 5000) + `}`,
+			expectErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -321,6 +405,375 @@ func TestSystemInjectorEdgeCases(t *testing.T) {
 	}
 }
 
+func TestSystemInjectorFastPath(t *testing.T) {
+	const prompt = `{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."}`
+
+	tests := []struct {
+		name      string
+		input     string
+		expected  string
+		unchanged bool // passed through byte for byte
+	}{
+		{
+			name:      "prompt first",
+			input:     `{"model": "m", "system": [` + prompt + `, {"type": "text", "text": "Custom"}], "messages": []}`,
+			unchanged: true,
+		},
+		{
+			name:      "fields in other order with cache control",
+			input:     `{ "system" : [ {"text": "You are Claude Code, Anthropic's official CLI for Claude.", "type": "text", "cache_control": {"type": "ephemeral"}} ] }`,
+			unchanged: true,
+		},
+		{
+			name:      "system mentioned in values",
+			input:     `{"system": [` + prompt + `], "messages": [{"role": "user", "content": "\"system\": [] and {\"system\": 1}"}], "meta": {"system": "x"}}`,
+			unchanged: true,
+		},
+		{
+			name:     "duplicate system after prompt",
+			input:    `{"system": [` + prompt + `], "model": "m", "system": [{"type": "text", "text": "Later"}], "max_tokens": 1}`,
+			expected: `{"model": "m", "max_tokens": 1, "system": [` + prompt + `, {"type": "text", "text": "Later"}]}`,
+		},
+		{
+			name:     "escaped duplicate system after prompt",
+			input:    `{"system": [` + prompt + `], "sys\u0074em": "Later"}`,
+			expected: `{"system": [` + prompt + `, {"type": "text", "text": "Later"}]}`,
+		},
+		{
+			name:     "prompt not first",
+			input:    `{"system": [{"type": "text", "text": "Custom"}, ` + prompt + `]}`,
+			expected: `{"system": [` + prompt + `, {"type": "text", "text": "Custom"}, ` + prompt + `]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, chunked := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/chunked=%v", tt.name, chunked), func(t *testing.T) {
+				var input io.Reader = strings.NewReader(tt.input)
+				if chunked {
+					// Keys split across reads
+					input = iotest.OneByteReader(input)
+				}
+				var output bytes.Buffer
+				if err := injectSystemPrompt(input, &output); err != nil {
+					t.Fatalf("injectSystemPrompt() error = %v", err)
+				}
+
+				if tt.unchanged {
+					if output.String() != tt.input {
+						t.Errorf("output = %s, want input unchanged", output.String())
+					}
+					return
+				}
+				if got, want := normalizeJSON(t, output.String()), normalizeJSON(t, tt.expected); got != want {
+					t.Errorf("Transformation mismatch:\ngot:  %s\nwant: %s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestSystemInjectorTrailingData(t *testing.T) {
+	const prompt = `{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."}`
+	paths := map[string]string{
+		"fast": `{"system": [` + prompt + `], "model": "m"}`,
+		"slow": `{"system": [{"type": "text", "text": "Custom"}], "model": "m"}`,
+	}
+
+	tests := []struct {
+		name    string
+		suffix  string
+		wantErr error
+	}{
+		{name: "trailing whitespace", suffix: " \n\t"},
+		{name: "concatenated object", suffix: `{"x":1}`, wantErr: errTrailingData},
+		{name: "concatenated after whitespace", suffix: "\n" + `{"system": []}`, wantErr: errTrailingData},
+		{name: "garbage", suffix: "garbage", wantErr: errTrailingData},
+	}
+
+	for _, tt := range tests {
+		for path, body := range paths {
+			for _, chunked := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%s/%s/chunked=%v", tt.name, path, chunked), func(t *testing.T) {
+					var input io.Reader = strings.NewReader(body + tt.suffix)
+					if chunked {
+						input = iotest.OneByteReader(input)
+					}
+					err := injectSystemPrompt(input, io.Discard)
+					if tt.wantErr == nil && err != nil {
+						t.Fatalf("injectSystemPrompt() error = %v", err)
+					}
+					if tt.wantErr != nil && err == nil {
+						t.Fatalf("injectSystemPrompt() error = nil, want %v", tt.wantErr)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestSystemInjectorDuplicateSystemKeys(t *testing.T) {
+	input := `{"system": "a", "system": [], "system": [{"type": "text", "text": "b"}]}`
+	var output bytes.Buffer
+	if err := injectSystemPrompt(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("injectSystemPrompt() error = %v", err)
+	}
+	if n := strings.Count(output.String(), `"system"`); n != 1 {
+		t.Errorf("output has %d system keys, want 1: %s", n, output.String())
+	}
+}
+
+func TestSystemInjectorLargeValue(t *testing.T) {
+	// A single multi-megabyte string (e.g. a base64 image) must survive unchanged
+	huge := strings.Repeat("A", 8<<20)
+	input := `{"messages": [{"role": "user", "content": [{"type": "image", "source": {"data": "` + huge + `"}}]}]}`
+
+	var output bytes.Buffer
+	if err := injectSystemPrompt(strings.NewReader(input), &output); err != nil {
+		t.Fatalf("injectSystemPrompt() error = %v", err)
+	}
+	if !strings.Contains(output.String(), huge) {
+		t.Error("large value was not preserved")
+	}
+	if !strings.Contains(output.String(), systemPrompt) {
+		t.Error("system prompt was not injected")
+	}
+}
+
+func TestSystemInjectorBatch(t *testing.T) {
+	const prompt = `{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude."}`
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "each request",
+			input:    `{"requests": [{"custom_id": "a", "params": {"model": "claude-3"}}, {"custom_id": "b", "params": {"system": "Be brief."}}]}`,
+			expected: `{"requests": [{"custom_id": "a", "params": {"model": "claude-3", "system": [` + prompt + `]}}, {"custom_id": "b", "params": {"system": [` + prompt + `, {"type": "text", "text": "Be brief."}]}}]}`,
+		},
+		{
+			name:     "other members unchanged",
+			input:    `{"requests": [{"custom_id": "a", "params": {}, "system": []}, 1], "system": []}`,
+			expected: `{"requests": [{"custom_id": "a", "params": {"system": [` + prompt + `]}, "system": []}, 1], "system": []}`,
+		},
+		{
+			name:     "no requests array",
+			input:    `{"requests": {"params": {}}}`,
+			expected: `{"requests": {"params": {}}}`,
+		},
+		{
+			name:     "not an object",
+			input:    `[{"params": {}}]`,
+			expected: `[{"params": {}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := defaultInjector.injectBatch(strings.NewReader(tt.input), &output); err != nil {
+				t.Fatalf("injectBatch() error = %v", err)
+			}
+			if got, want := normalizeJSON(t, output.String()), normalizeJSON(t, tt.expected); got != want {
+				t.Errorf("Transformation mismatch:\ngot:  %s\nwant: %s", got, want)
+			}
+		})
+	}
+}
+
+// FuzzInjectSystemPrompt checks that injection never corrupts bodies: JSON objects,
+// also as array elements, come out as valid JSON starting their system array with
+// the prompt, other JSON values come out unchanged, and invalid input fails or
+// passes through unchanged.
+func FuzzInjectSystemPrompt(f *testing.F) {
+	for _, seed := range []string{
+		`{}`,
+		`{"model": "claude-3", "messages": [{"role": "user", "content": "Hi"}]}`,
+		`{"system": "Be brief."}`,
+		`{"system": [{"type": "text", "text": "Custom"}], "system": null}`,
+		`{"system": {"type": "text"}, "messages": [[[]]]}`,
+		`{"metadata": {"user_id": "u"}, "metadata": 1}`,
+		`[1, {"system": []}]`,
+		`"system"`,
+		`null`,
+		`{"model": "claude-3"`,
+		`{"a": 1} {"b": 2}`,
+		"{\"system\": \"\xff\"}",
+		`{"system": [{"type": "text", "text": "` + systemPrompt + `"}], "messages": []}`,
+		`{"system": [{"type": "text", "text": "` + systemPrompt + `"}], "system": "Later"}`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		var output bytes.Buffer
+		err := injectSystemPrompt(strings.NewReader(input), &output)
+
+		// Validity as Anthropic's API sees it: strict UTF-8, duplicate names allowed
+		if !jsontext.Value(input).IsValid(jsontext.AllowDuplicateNames(true)) {
+			// The fast path leaves syntax errors to upstream, forwarding the body unchanged
+			if err == nil && output.String() != input {
+				t.Fatalf("invalid input %q passed as %q", input, output.String())
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("valid input %q failed: %v", input, err)
+		}
+		if !jsontext.Value(output.Bytes()).IsValid(jsontext.AllowDuplicateNames(true)) {
+			t.Fatalf("output is not valid JSON: %q", output.String())
+		}
+
+		// Map keys match exactly, unlike struct fields
+		checkObject := func(object []byte) {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(object, &fields); err != nil {
+				t.Fatalf("output is not an object: %v\n%s", err, output.String())
+			}
+			var system []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(fields["system"], &system); err != nil {
+				t.Fatalf("output system is not an array of blocks: %v\n%s", err, output.String())
+			}
+			if len(system) == 0 || system[0].Type != "text" || system[0].Text != systemPrompt {
+				t.Fatalf("system prompt not first: %s", output.String())
+			}
+		}
+		unchanged := func(got, want jsontext.Value) {
+			got, want = bytes.Clone(got), bytes.Clone(want)
+			_ = want.Canonicalize(jsontext.AllowDuplicateNames(true))
+			_ = got.Canonicalize(jsontext.AllowDuplicateNames(true))
+			if !bytes.Equal(got, want) {
+				t.Fatalf("non-object body changed: %q -> %q", input, output.String())
+			}
+		}
+
+		switch want := jsontext.Value(input); want.Kind() {
+		case '{':
+			checkObject(output.Bytes())
+		case '[':
+			// A batch: each object element gets the prompt
+			var in, out []jsontext.Value
+			if err := json.Unmarshal([]byte(input), &in); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(output.Bytes(), &out); err != nil || len(out) != len(in) {
+				t.Fatalf("array body changed shape: %q -> %q", input, output.String())
+			}
+			for i := range in {
+				if in[i].Kind() == '{' {
+					checkObject(out[i])
+				} else {
+					unchanged(out[i], in[i])
+				}
+			}
+		default:
+			unchanged(output.Bytes(), want)
+		}
+	})
+}
+
+func TestImpersonationTransportTolerant(t *testing.T) {
+	var receivedBody string
+	var receivedLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		receivedLength = r.ContentLength
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		tolerant   bool
+		spool      bool
+		body       string
+		wantErr    bool
+		wantSystem bool
+	}{
+		{"valid body", true, false, `{"model": "claude-3"}`, false, true},
+		{"malformed body forwarded unchanged", true, false, `{"model": invalid}`, false, false},
+		{"malformed body fails when strict", false, false, `{"model": invalid}`, true, false},
+		{"valid body spooled", true, true, `{"model": "claude-3"}`, false, true},
+		{"malformed body spooled", true, true, `{"model": invalid}`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receivedBody = ""
+			var spool *BodySpool
+			if tt.spool {
+				spool = &BodySpool{Dir: t.TempDir(), MemoryLimit: 4}
+			}
+			client := &http.Client{Transport: &ImpersonationTransport{Base: http.DefaultTransport, Tolerant: tt.tolerant, Spool: spool}}
+
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			_ = resp.Body.Close()
+
+			if got := strings.Contains(receivedBody, claudeCodeSystemPrompt); got != tt.wantSystem {
+				t.Errorf("system prompt injected = %v, want %v (body %q)", got, tt.wantSystem, receivedBody)
+			}
+			if !tt.wantSystem && receivedBody != tt.body {
+				t.Errorf("body = %q, want unchanged %q", receivedBody, tt.body)
+			}
+			if receivedLength != int64(len(receivedBody)) {
+				t.Errorf("Content-Length = %d, want %d", receivedLength, len(receivedBody))
+			}
+			if spool != nil {
+				if files, _ := os.ReadDir(spool.Dir); len(files) != 0 {
+					t.Errorf("%d spooled files left after the response", len(files))
+				}
+			}
+		})
+	}
+}
+
+func TestImpersonationTransportBatches(t *testing.T) {
+	var receivedBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	const batch = `{"requests": [{"custom_id": "a", "params": {"model": "claude-3"}}]}`
+	tests := []struct {
+		name       string
+		path       string
+		wantSystem bool
+	}{
+		{"batch creation", "/v1/messages/batches", true},
+		{"other passthrough endpoint", "/v1/files", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: &ImpersonationTransport{Base: http.DefaultTransport, HeadersOnly: true}}
+			resp, err := client.Post(server.URL+tt.path, "application/json", strings.NewReader(batch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			if got := strings.Contains(receivedBody, claudeCodeSystemPrompt); got != tt.wantSystem {
+				t.Errorf("system prompt injected = %v, want %v (body %q)", got, tt.wantSystem, receivedBody)
+			}
+		})
+	}
+}
+
 func TestImpersonationTransport(t *testing.T) {
 	// Create test server that captures request headers and body
 	var receivedBody string
@@ -390,6 +843,145 @@ func TestImpersonationTransport(t *testing.T) {
 	}
 }
 
+func TestImpersonationTransportBetaHeaders(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Anthropic-Beta")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/skills", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Repeated headers, as the Anthropic SDKs send their betas parameter
+	req.Header.Add("Anthropic-Beta", "skills-2025-10-02")
+	req.Header.Add("Anthropic-Beta", "code-execution-2025-08-25, oauth-2025-04-20")
+
+	resp, err := (&http.Client{Transport: &ImpersonationTransport{Base: http.DefaultTransport}}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	want := requiredBetaHeader + ",skills-2025-10-02,code-execution-2025-08-25"
+	if received != want {
+		t.Errorf("Anthropic-Beta = %q, want %q", received, want)
+	}
+}
+
+func TestImpersonationTransportHeadersOnly(t *testing.T) {
+	var receivedBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &ImpersonationTransport{Base: http.DefaultTransport, HeadersOnly: true}}
+	reqBody := `{"model":"claude-3","messages":[{"role":"user","content":"Hi"}]}`
+
+	tests := []struct {
+		path       string
+		wantSystem bool
+	}{
+		{"/v1/messages/batches", false},
+		{"/v1/messages/count_tokens", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := client.Post(server.URL+tt.path, "application/json", strings.NewReader(reqBody))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if got := strings.Contains(receivedBody, claudeCodeSystemPrompt); got != tt.wantSystem {
+				t.Errorf("system prompt injected = %v, want %v (body %s)", got, tt.wantSystem, receivedBody)
+			}
+		})
+	}
+}
+
+func TestImpersonationTransportProfile(t *testing.T) {
+	var receivedBody string
+	var receivedHeaders http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeaders = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		receivedBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	profile, ok := LookupImpersonationProfile("claude-code")
+	if !ok {
+		t.Fatal("claude-code profile missing")
+	}
+	profile.UserID = "user_abc"
+	client := &http.Client{Transport: &ImpersonationTransport{Base: http.DefaultTransport, Profile: &profile}}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantUserID string
+	}{
+		{"default user ID", "/v1/messages", `{"model":"m","messages":[]}`, "user_abc"},
+		{"client user ID kept", "/v1/messages", `{"metadata":{"user_id":"client"},"model":"m","messages":[]}`, "client"},
+		{"user ID added to metadata", "/v1/messages", `{"metadata":{},"model":"m","messages":[]}`, "user_abc"},
+		{"no metadata for count_tokens", "/v1/messages/count_tokens", `{"model":"m","messages":[]}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, server.URL+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("User-Agent", "my-client/1.0")
+			req.Header.Set("Anthropic-Beta", "custom-beta,interleaved-thinking-2025-05-14")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if got := receivedHeaders.Get("User-Agent"); got != "claude-cli/"+claudeCodeVersion+" (external, cli)" {
+				t.Errorf("User-Agent = %q", got)
+			}
+			if got := receivedHeaders.Get("X-App"); got != "cli" {
+				t.Errorf("X-App = %q", got)
+			}
+			if got := receivedHeaders.Get("X-Stainless-Lang"); got != "js" {
+				t.Errorf("X-Stainless-Lang = %q", got)
+			}
+			wantBeta := requiredBetaHeader + ",interleaved-thinking-2025-05-14,fine-grained-tool-streaming-2025-05-14,custom-beta"
+			if got := receivedHeaders.Get("Anthropic-Beta"); got != wantBeta {
+				t.Errorf("Anthropic-Beta = %q, want %q", got, wantBeta)
+			}
+
+			var body struct {
+				System   []map[string]string `json:"system"`
+				Metadata *struct {
+					UserID string `json:"user_id"`
+				} `json:"metadata"`
+			}
+			if err := json.Unmarshal([]byte(receivedBody), &body); err != nil {
+				t.Fatalf("invalid body %s: %v", receivedBody, err)
+			}
+			if len(body.System) == 0 || body.System[0]["text"] != claudeCodeSystemPrompt {
+				t.Errorf("system = %v", body.System)
+			}
+			var gotUserID string
+			if body.Metadata != nil {
+				gotUserID = body.Metadata.UserID
+			}
+			if gotUserID != tt.wantUserID {
+				t.Errorf("metadata.user_id = %q, want %q (body %s)", gotUserID, tt.wantUserID, receivedBody)
+			}
+		})
+	}
+}
+
 func TestImpersonationTransportHeaderFiltering(t *testing.T) {
 	// Create test server that captures request headers
 	var receivedHeaders http.Header
@@ -0,0 +1,107 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+// IntegrityTransport is an http.RoundTripper that records a signed digest of every
+// request sent upstream to the audit log, keyed by request ID. It runs after the
+// request ID is set and before upstream compression, so the digest covers the
+// headers and uncompressed body the proxy produced.
+//
+// The body is hashed while the base transport sends it. The digest is recorded
+// once the body was read to the end or closed, covering the bytes sent so far.
+type IntegrityTransport struct {
+	Base  http.RoundTripper
+	Key   []byte
+	Audit *audit.Log
+}
+
+// Compile-time check that IntegrityTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*IntegrityTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *IntegrityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		t.record(req, sha256.New(), 0)
+		return t.Base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	out := req.Clone(req.Context())
+	out.Body = &digestBody{
+		ReadCloser: req.Body,
+		hash:       sha256.New(),
+		done:       func(h hash.Hash, size int64) { t.record(req, h, size) },
+	}
+	return t.Base.RoundTrip(out)
+}
+
+// record signs the digest of req with body hash h over size bytes and records it.
+func (t *IntegrityTransport) record(req *http.Request, h hash.Hash, size int64) {
+	digest := audit.NewRequestDigest(req, h.Sum(nil), size)
+	digest.Sign(t.Key)
+	detail, err := json.Marshal(digest)
+	if err != nil {
+		return
+	}
+	t.Audit.Record(req.Context(), audit.Event{
+		Action: audit.ActionUpstreamRequest,
+		Actor:  "proxy",
+		Target: middleware.RequestIDFromContext(req.Context()),
+		Detail: string(detail),
+	})
+}
+
+// digestBody hashes a request body as it is read and calls done once, at EOF or
+// on Close, whichever comes first. The transport may close the body while
+// another goroutine reads it.
+type digestBody struct {
+	io.ReadCloser
+	done func(h hash.Hash, size int64)
+
+	mu       sync.Mutex
+	hash     hash.Hash
+	size     int64
+	finished bool
+}
+
+func (b *digestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.finished {
+		b.hash.Write(p[:n])
+		b.size += int64(n)
+		if err == io.EOF {
+			b.finish()
+		}
+	}
+	return n, err
+}
+
+func (b *digestBody) Close() error {
+	b.mu.Lock()
+	if !b.finished {
+		b.finish()
+	}
+	b.mu.Unlock()
+	return b.ReadCloser.Close()
+}
+
+// finish calls done. b.mu must be held.
+func (b *digestBody) finish() {
+	b.finished = true
+	b.done(b.hash, b.size)
+}
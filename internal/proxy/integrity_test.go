@@ -0,0 +1,91 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/audit"
+)
+
+func TestRequestSigning(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []byte
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent, _ = io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5",` +
+				`"content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)),
+			Request: r,
+		}, nil
+	})
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	p, err := New(ts, readyChecker{}, WithTransport(upstream), WithAuditLog(auditLog), WithRequestSigning(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"model":"claude-sonnet-4-5","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	req.Header.Set("X-Request-ID", "req-signed")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if err := auditLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := audit.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != audit.ActionUpstreamRequest || events[0].Target != "req-signed" {
+		t.Fatalf("events = %+v, want one upstream request for req-signed", events)
+	}
+
+	var digest audit.RequestDigest
+	if err := json.Unmarshal([]byte(events[0].Detail), &digest); err != nil {
+		t.Fatal(err)
+	}
+	if !digest.Verify(key) {
+		t.Error("Verify() = false for the recorded digest")
+	}
+	if !digest.MatchesBody(sent) || digest.MatchesBody([]byte(body+" ")) {
+		t.Error("MatchesBody() does not identify the body sent upstream")
+	}
+	if _, ok := digest.Headers["authorization"]; ok {
+		t.Error("digest records the Authorization header")
+	}
+	if digest.Headers["x-request-id"] != "req-signed" {
+		t.Errorf("digest x-request-id = %q, want req-signed", digest.Headers["x-request-id"])
+	}
+
+	tampered := digest
+	tampered.URL += "?beta=true"
+	if tampered.Verify(key) {
+		t.Error("Verify() = true for a tampered digest")
+	}
+	if digest.Verify([]byte("another key of thirty-two bytes!")) {
+		t.Error("Verify() = true with another key")
+	}
+}
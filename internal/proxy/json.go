@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
 )
 
 // writeJSON writes a JSON response with the given status code.
@@ -47,3 +49,87 @@ func writeJSONOpenAIError(ctx context.Context, w http.ResponseWriter, errResp *o
 
 	writeJSON(ctx, w, errResp, status)
 }
+
+// writeJSONOpenAIRateLimitError writes a rate limit error with its details,
+// announcing when to retry in the Retry-After header if known, and in OpenAI's
+// x-ratelimit-reset-{requests,tokens} header if the exhausted limit is known too.
+func writeJSONOpenAIRateLimitError(ctx context.Context, w http.ResponseWriter, errResp *openaiadapter.RateLimitError) {
+	if errResp.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(errResp.RetryAfter))
+		if errResp.Limit == "requests" || errResp.Limit == "tokens" {
+			w.Header().Set("X-Ratelimit-Reset-"+errResp.Limit, (time.Duration(errResp.RetryAfter) * time.Second).String())
+		}
+	}
+	writeJSON(ctx, w, errResp, http.StatusTooManyRequests)
+}
+
+// openAIErrorType maps an HTTP status code to the closest OpenAI error type.
+// Inverse of the mapping in writeJSONOpenAIError for responses generated by the proxy itself.
+func openAIErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_denied"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	default:
+		return "api_error"
+	}
+}
+
+// writeOpenAIErrorStatus writes an OpenAI-compatible error with an explicit HTTP status code.
+func writeOpenAIErrorStatus(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeJSON(r.Context(), w, &openaiadapter.ErrorResponse{
+		Err: openaiadapter.Error{
+			Message: message,
+			Type:    openAIErrorType(status),
+		},
+	}, status)
+}
+
+// anthropicErrorResponse mirrors Anthropic's error envelope for errors generated by the proxy.
+type anthropicErrorResponse struct {
+	Type  string              `json:"type"`
+	Error anthropicErrorField `json:"error"`
+}
+
+type anthropicErrorField struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// anthropicErrorType maps an HTTP status code to Anthropic's error taxonomy.
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// writeAnthropicErrorStatus writes an Anthropic-compatible error for the native Messages API route.
+func writeAnthropicErrorStatus(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeJSON(r.Context(), w, &anthropicErrorResponse{
+		Type: "error",
+		Error: anthropicErrorField{
+			Type:    anthropicErrorType(status),
+			Message: message,
+		},
+	}, status)
+}
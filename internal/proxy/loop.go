@@ -0,0 +1,273 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+const (
+	// loopWindow is the period LoopDetection.RequestsPerMinute counts requests in.
+	loopWindow = time.Minute
+
+	// maxLoopConversations bounds the conversations whose request rate is tracked;
+	// the least recently used are forgotten first.
+	maxLoopConversations = 10000
+)
+
+// LoopDetection configures WithLoopDetection. Zero values disable a check.
+type LoopDetection struct {
+	// RepeatedToolCalls rejects requests whose message history ends with the same
+	// tool call, same name and input, this many times in a row.
+	RepeatedToolCalls int
+
+	// RequestsPerMinute rejects requests of a conversation, named by
+	// prompt_cache_key or metadata.conversation_id, beyond this many per minute.
+	RequestsPerMinute int
+
+	// Notify, if set, is called when a conversation starts looping, e.g. to alert
+	// an admin. It must not block.
+	Notify func(ctx context.Context, loop AgentLoop)
+}
+
+// AgentLoop describes a detected agent loop.
+type AgentLoop struct {
+	Time         time.Time `json:"time"`
+	Key          string    `json:"key,omitempty"`          // Client key ID
+	Conversation string    `json:"conversation,omitempty"` // Conversation key, if named
+	Reason       string    `json:"reason"`
+}
+
+// WithLoopDetection rejects requests of runaway agents: conversations repeating
+// one tool call or sending requests faster than any human-driven agent would.
+// Detections are recorded to the audit log set by WithAuditLog.
+func WithLoopDetection(d LoopDetection) Option {
+	return func(c *config) {
+		c.loopDetection = d
+	}
+}
+
+// loopDetector tracks conversations for LoopDetection, per client key and
+// tenant. Requests naming no conversation share the state of their key.
+type loopDetector struct {
+	LoopDetection
+	audit *audit.Log
+
+	mu            sync.Mutex
+	ll            *list.List // Of *loopConversation, most recently used first
+	conversations map[string]*list.Element
+}
+
+// loopConversation holds the recent requests of a conversation.
+type loopConversation struct {
+	key      string
+	requests []time.Time // Within loopWindow, oldest first
+	looping  bool        // Reported, until a request passes again
+}
+
+// newLoopDetector returns a loopDetector, or nil if d enables no check.
+func newLoopDetector(d LoopDetection, l *audit.Log) *loopDetector {
+	if d.RepeatedToolCalls <= 0 && d.RequestsPerMinute <= 0 {
+		return nil
+	}
+	return &loopDetector{
+		LoopDetection: d,
+		audit:         l,
+		ll:            list.New(),
+		conversations: make(map[string]*list.Element),
+	}
+}
+
+// middleware rejects requests detected as part of an agent loop. Requires
+// identifyConversation. No-op for a nil detector.
+func (d *loopDetector) middleware(reject func(w http.ResponseWriter, r *http.Request, status int, message string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conversation := namedConversationFromContext(r.Context())
+			state := conversationState(tenantName(r), usage.KeyID(r), conversation)
+			if reason := d.tooFrequent(state, conversation, time.Now()); reason != "" {
+				d.report(r, state, conversation, reason)
+				reject(w, r, http.StatusTooManyRequests, "agent loop detected: "+reason)
+				return
+			}
+
+			if d.RepeatedToolCalls > 0 {
+				var body []byte
+				var err error
+				r, body, err = reqbody.Read(r)
+				if err != nil {
+					// Let the handler surface the read error
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				if name, n := repeatedToolCall(body); n >= d.RepeatedToolCalls {
+					reason := fmt.Sprintf("tool call %q repeated %d times in a row with identical input", name, n)
+					d.report(r, state, conversation, reason)
+					reject(w, r, http.StatusBadRequest, "agent loop detected: "+reason)
+					return
+				}
+			}
+
+			d.passed(state)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tooFrequent counts a request of conversation, tracked by state, at now and
+// describes the violation if it exceeds RequestsPerMinute, or returns "".
+// Rejected requests count too, so an agent retrying right away stays blocked.
+func (d *loopDetector) tooFrequent(state, conversation string, now time.Time) string {
+	if d.RequestsPerMinute <= 0 || conversation == "" {
+		return ""
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := d.conversation(state)
+	i := 0
+	for i < len(c.requests) && now.Sub(c.requests[i]) >= loopWindow {
+		i++
+	}
+	c.requests = append(c.requests[i:], now)
+	if len(c.requests) <= d.RequestsPerMinute {
+		return ""
+	}
+	c.requests = c.requests[len(c.requests)-d.RequestsPerMinute-1:]
+	return fmt.Sprintf("more than %d requests per minute in conversation %s", d.RequestsPerMinute, conversation)
+}
+
+// passed clears the looping state after a request passed.
+func (d *loopDetector) passed(state string) {
+	d.mu.Lock()
+	if el, ok := d.conversations[state]; ok {
+		el.Value.(*loopConversation).looping = false
+	}
+	d.mu.Unlock()
+}
+
+// report logs a rejected request and, the first time a conversation, tracked by
+// state, is found looping, records and notifies the detection.
+func (d *loopDetector) report(r *http.Request, state, conversation, reason string) {
+	ctx := r.Context()
+	slog.WarnContext(ctx, "rejected request of looping agent", "conversation", conversation, "reason", reason)
+
+	key := usage.KeyID(r)
+	d.mu.Lock()
+	c := d.conversation(state)
+	first := !c.looping
+	c.looping = true
+	d.mu.Unlock()
+	if !first {
+		return
+	}
+
+	loop := AgentLoop{Time: time.Now().UTC(), Key: key, Conversation: conversation, Reason: reason}
+	d.audit.Record(ctx, audit.Event{
+		Action: audit.ActionAgentLoop,
+		Actor:  key,
+		Target: conversation,
+		Detail: reason,
+	})
+	if d.Notify != nil {
+		d.Notify(ctx, loop)
+	}
+}
+
+// conversation returns the state of key, creating it and evicting the least
+// recently used one if needed. Must be called with d.mu held.
+func (d *loopDetector) conversation(key string) *loopConversation {
+	if el, ok := d.conversations[key]; ok {
+		d.ll.MoveToFront(el)
+		return el.Value.(*loopConversation)
+	}
+	c := &loopConversation{key: key}
+	d.conversations[key] = d.ll.PushFront(c)
+	if d.ll.Len() > maxLoopConversations {
+		oldest := d.ll.Back()
+		d.ll.Remove(oldest)
+		delete(d.conversations, oldest.Value.(*loopConversation).key)
+	}
+	return c
+}
+
+// repeatedToolCall returns the name of the last tool call in the messages of an
+// Anthropic Messages or OpenAI chat completions body and how many tool calls
+// in a row, counting back from the end, are identical to it.
+func repeatedToolCall(body []byte) (string, int) {
+	var req struct {
+		Messages []struct {
+			Role      string          `json:"role"`
+			Content   json.RawMessage `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"messages"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return "", 0
+	}
+
+	// Tool calls as name and compacted input, in conversation order
+	var calls [][2]string
+	for _, m := range req.Messages {
+		if m.Role != "assistant" {
+			continue
+		}
+		for _, tc := range m.ToolCalls {
+			calls = append(calls, [2]string{tc.Function.Name, compactJSON([]byte(tc.Function.Arguments))})
+		}
+		var blocks []struct {
+			Type  string          `json:"type"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		}
+		if json.Unmarshal(m.Content, &blocks) != nil {
+			continue // String or absent content
+		}
+		for _, b := range blocks {
+			if b.Type == "tool_use" {
+				calls = append(calls, [2]string{b.Name, compactJSON(b.Input)})
+			}
+		}
+	}
+	if len(calls) == 0 {
+		return "", 0
+	}
+
+	last := calls[len(calls)-1]
+	n := 0
+	for i := len(calls) - 1; i >= 0 && calls[i] == last; i-- {
+		n++
+	}
+	return last[0], n
+}
+
+// compactJSON returns data without insignificant whitespace, or as is if invalid.
+func compactJSON(data []byte) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) != nil {
+		return string(data)
+	}
+	return buf.String()
+}
@@ -0,0 +1,170 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRepeatedToolCall(t *testing.T) {
+	const (
+		anthropicCall   = `{"role":"assistant","content":[{"type":"text","text":"Searching"},{"type":"tool_use","id":"toolu_1","name":"search","input":{"query":"go"}}]}`
+		anthropicOther  = `{"role":"assistant","content":[{"type":"tool_use","id":"toolu_9","name":"search","input":{"query":"rust"}}]}`
+		anthropicResult = `{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"nothing"}]}`
+		openAICall      = `{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"read","arguments":"{\"path\": \"a.go\"}"}}]}`
+		openAICompact   = `{"role":"assistant","tool_calls":[{"id":"call_2","type":"function","function":{"name":"read","arguments":"{\"path\":\"a.go\"}"}}]}`
+		openAIResult    = `{"role":"tool","tool_call_id":"call_1","content":"package a"}`
+	)
+	messages := func(msgs ...string) []byte {
+		return []byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"},` + strings.Join(msgs, ",") + `]}`)
+	}
+
+	tests := []struct {
+		name     string
+		body     []byte
+		wantName string
+		wantN    int
+	}{
+		{
+			name:     "anthropic repeated",
+			body:     messages(anthropicCall, anthropicResult, anthropicCall, anthropicResult, anthropicCall, anthropicResult),
+			wantName: "search",
+			wantN:    3,
+		},
+		{
+			name:     "anthropic interrupted",
+			body:     messages(anthropicCall, anthropicResult, anthropicOther, anthropicResult, anthropicCall, anthropicResult),
+			wantName: "search",
+			wantN:    1,
+		},
+		{
+			name:     "openai with differently formatted arguments",
+			body:     messages(openAICall, openAIResult, openAICompact, openAIResult),
+			wantName: "read",
+			wantN:    2,
+		},
+		{
+			name: "no tool calls",
+			body: messages(`{"role":"assistant","content":"hello"}`),
+		},
+		{
+			name: "invalid body",
+			body: []byte(`{"messages":`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, n := repeatedToolCall(tt.body)
+			if name != tt.wantName || n != tt.wantN {
+				t.Errorf("repeatedToolCall() = %q, %d, want %q, %d", name, n, tt.wantName, tt.wantN)
+			}
+		})
+	}
+}
+
+func TestLoopDetection(t *testing.T) {
+	const (
+		toolCall = `{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"search","input":{"query":"go"}}]},` +
+			`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"nothing"}]}`
+		looping = `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"},` + toolCall + `,` + toolCall + `]}`
+		named   = `{"model":"claude-sonnet-4-5","metadata":{"conversation_id":"c1"},"messages":[{"role":"user","content":"hi"}]}`
+		unnamed = `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`
+	)
+
+	tests := []struct {
+		name       string
+		detection  LoopDetection
+		bodies     []string
+		wantStatus []int
+		wantNotify int
+	}{
+		{
+			name:       "repeated tool call",
+			detection:  LoopDetection{RepeatedToolCalls: 2},
+			bodies:     []string{unnamed, looping, looping},
+			wantStatus: []int{http.StatusOK, http.StatusBadRequest, http.StatusBadRequest},
+			wantNotify: 1,
+		},
+		{
+			name:       "detected again after a request passed",
+			detection:  LoopDetection{RepeatedToolCalls: 2},
+			bodies:     []string{looping, unnamed, looping},
+			wantStatus: []int{http.StatusBadRequest, http.StatusOK, http.StatusBadRequest},
+			wantNotify: 2,
+		},
+		{
+			name:       "tool call below threshold",
+			detection:  LoopDetection{RepeatedToolCalls: 3},
+			bodies:     []string{looping},
+			wantStatus: []int{http.StatusOK},
+		},
+		{
+			name:       "requests per minute",
+			detection:  LoopDetection{RequestsPerMinute: 2},
+			bodies:     []string{named, named, named, named},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			wantNotify: 1,
+		},
+		{
+			name:       "unnamed conversations are not rate limited",
+			detection:  LoopDetection{RequestsPerMinute: 2},
+			bodies:     []string{unnamed, unnamed, unnamed},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notified []AgentLoop
+			tt.detection.Notify = func(_ context.Context, loop AgentLoop) {
+				notified = append(notified, loop)
+			}
+			handler := applyMiddlewares(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+				identifyConversation(false, true),
+				newLoopDetector(tt.detection, nil).middleware(writeAnthropicErrorStatus),
+			)
+
+			for i, body := range tt.bodies {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+				if rec.Code != tt.wantStatus[i] {
+					t.Errorf("request %d: status = %d, want %d", i, rec.Code, tt.wantStatus[i])
+				}
+				if rec.Code != http.StatusOK && !strings.Contains(rec.Body.String(), "agent loop detected") {
+					t.Errorf("request %d: body = %s, want loop error", i, rec.Body)
+				}
+			}
+			if len(notified) != tt.wantNotify {
+				t.Errorf("notified %d times, want %d: %+v", len(notified), tt.wantNotify, notified)
+			}
+		})
+	}
+}
+
+func TestLoopDetectionPerKey(t *testing.T) {
+	const named = `{"model":"claude-sonnet-4-5","metadata":{"conversation_id":"c1"},"messages":[{"role":"user","content":"hi"}]}`
+	handler := applyMiddlewares(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		identifyConversation(false, true),
+		newLoopDetector(LoopDetection{RequestsPerMinute: 1}, nil).middleware(writeAnthropicErrorStatus),
+	)
+
+	for i, tt := range []struct {
+		key        string
+		wantStatus int
+	}{
+		{"key-a", http.StatusOK},
+		{"key-a", http.StatusTooManyRequests},
+		{"key-b", http.StatusOK}, // Same conversation name, another client
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(named))
+		req.Header.Set("X-Api-Key", tt.key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("request %d: status = %d, want %d", i, rec.Code, tt.wantStatus)
+		}
+	}
+}
@@ -35,3 +35,10 @@ func applyMiddlewares(h http.Handler, middlewares ...func(http.Handler) http.Han
 	}
 	return h
 }
+
+// chain combines middlewares into one, applied in the order they appear.
+func chain(middlewares []func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return applyMiddlewares(h, middlewares...)
+	}
+}
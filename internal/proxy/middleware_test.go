@@ -0,0 +1,84 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	var calls []string
+	var requestID string
+	named := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				requestID = middleware.RequestIDFromContext(r.Context())
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Team") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"), WithMiddleware(named("first"), named("second")), WithMiddleware(auth))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		team       string
+		wantStatus int
+		wantCalls  []string
+	}{
+		{name: "messages", method: http.MethodPost, path: "/v1/messages", team: "a", wantStatus: http.StatusOK, wantCalls: []string{"first", "second"}},
+		{name: "rejected", method: http.MethodPost, path: "/v1/messages", wantStatus: http.StatusUnauthorized, wantCalls: []string{"first", "second"}},
+		{name: "models", method: http.MethodGet, path: "/v1/models", wantStatus: http.StatusUnauthorized, wantCalls: []string{"first", "second"}},
+		{name: "health not wrapped", method: http.MethodGet, path: "/health/liveness", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, requestID = nil, ""
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"model":"claude-sonnet-4-5","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}`))
+			if tt.team != "" {
+				req.Header.Set("X-Team", tt.team)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if len(tt.wantCalls) > 0 && requestID == "" {
+				t.Error("middleware ran without request ID")
+			}
+		})
+	}
+}
@@ -1,26 +1,177 @@
+//go:build goexperiment.jsonv2
+
 package proxy
 
 import (
+	"context"
 	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 )
 
 //go:embed models.json
 var modelsJSON []byte
 
-// modelsHandler returns a static list of available Anthropic models.
+const (
+	// modelsRefreshTimeout bounds a background refresh of the model list.
+	modelsRefreshTimeout = 30 * time.Second
+
+	// maxModelPages bounds the pages of the upstream model list fetched per refresh.
+	maxModelPages = 10
+)
+
+// modelsHandler returns the list of available Anthropic models.
 // The upstream /v1/models endpoint doesn't support OAuth authentication,
-// so we serve a cached response to enable model selection in clients.
+// so we serve a cached response to enable model selection in clients,
+// optionally refreshed with an API key (see WithModelRefresh).
 //
 // The response uses a merged format compatible with both Anthropic and OpenAI
 // clients, combining fields from both API specifications. This approach assumes
 // that most clients ignore unknown fields.
-func modelsHandler() http.HandlerFunc {
+func modelsHandler(catalog *modelCatalog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		if _, err := w.Write(modelsJSON); err != nil {
+		if _, err := w.Write(catalog.get()); err != nil {
 			slog.ErrorContext(r.Context(), "failed to write response", "error", err)
 		}
 	}
 }
+
+// WithModelRefresh fetches the model list from the upstream /v1/models endpoint
+// once it is older than interval, in the background and with the fallback API key
+// if set. Until the first successful refresh, and whenever the upstream can't be
+// reached, the last known list is served, starting with the built-in one.
+func WithModelRefresh(interval time.Duration) Option {
+	return func(c *config) {
+		c.modelRefresh = interval
+	}
+}
+
+// modelCatalog serves the model list, revalidating it against the upstream in the
+// background once it is stale. A nil client disables revalidation.
+type modelCatalog struct {
+	client   *http.Client
+	url      string
+	apiKey   string
+	interval time.Duration
+
+	mu         sync.Mutex
+	data       []byte
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// newModelCatalog creates a catalog serving the built-in model list.
+func newModelCatalog(client *http.Client, modelsURL, apiKey string, interval time.Duration) *modelCatalog {
+	return &modelCatalog{client: client, url: modelsURL, apiKey: apiKey, interval: interval, data: modelsJSON}
+}
+
+// get returns the current model list, starting a background refresh if it is stale.
+func (c *modelCatalog) get() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil && !c.refreshing && time.Since(c.fetchedAt) >= c.interval {
+		c.refreshing = true
+		go c.refresh()
+	}
+	return c.data
+}
+
+// refresh replaces the model list with the upstream one, keeping the stale list on failure.
+func (c *modelCatalog) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), modelsRefreshTimeout)
+	defer cancel()
+
+	data, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshing = false
+	// Failed refreshes are retried after another interval, not on every request
+	c.fetchedAt = time.Now()
+	if err != nil {
+		slog.WarnContext(ctx, "failed to refresh model list, serving last known list", "error", err)
+		return
+	}
+	c.data = data
+}
+
+// upstreamModel is an entry of Anthropic's model list.
+type upstreamModel struct {
+	Type        string    `json:"type"`
+	ID          string    `json:"id"`
+	DisplayName string    `json:"display_name"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// listedModel is a model in the merged Anthropic and OpenAI format of models.json.
+type listedModel struct {
+	upstreamModel
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// fetch retrieves all pages of the upstream model list in the merged format.
+func (c *modelCatalog) fetch(ctx context.Context) ([]byte, error) {
+	if c.apiKey != "" {
+		ctx = context.WithValue(ctx, clientKeyContextKey{}, c.apiKey)
+	}
+
+	var models []listedModel
+	afterID := ""
+	for range maxModelPages {
+		query := url.Values{"limit": {"1000"}}
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		// Anthropic-Version is set by ClientKeyTransport
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Data    []upstreamModel `json:"data"`
+			HasMore bool            `json:"has_more"`
+			LastID  string          `json:"last_id"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("model list request failed with status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding model list: %w", err)
+		}
+
+		for _, m := range page.Data {
+			models = append(models, listedModel{upstreamModel: m, Object: "model", Created: m.CreatedAt.Unix(), OwnedBy: "anthropic"})
+		}
+		if !page.HasMore || page.LastID == "" {
+			break
+		}
+		afterID = page.LastID
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("upstream listed no models")
+	}
+
+	return json.MarshalIndent(struct {
+		Object string        `json:"object"`
+		Data   []listedModel `json:"data"`
+	}{Object: "list", Data: models}, "", "  ")
+}
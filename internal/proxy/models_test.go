@@ -0,0 +1,82 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestModelCatalog(t *testing.T) {
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "sk-ant-api03-test" {
+			t.Errorf("X-Api-Key = %q, want the fallback API key", r.Header.Get("X-Api-Key"))
+		}
+		if failing.Load() {
+			http.Error(w, "overloaded", 529)
+			return
+		}
+		if r.URL.Query().Get("after_id") == "" {
+			_, _ = w.Write([]byte(`{"data":[{"type":"model","id":"claude-new","display_name":"Claude New","created_at":"2026-01-02T00:00:00Z"}],"has_more":true,"last_id":"claude-new"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"type":"model","id":"claude-old","display_name":"Claude Old","created_at":"2025-01-02T00:00:00Z"}],"has_more":false}`))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &ClientKeyTransport{Direct: http.DefaultTransport}}
+	catalog := newModelCatalog(client, upstream.URL+"/v1/models", "sk-ant-api03-test", time.Hour)
+
+	// Serves the built-in list while revalidating
+	if got := catalog.get(); string(got) != string(modelsJSON) {
+		t.Fatalf("first response = %s, want the built-in list", got)
+	}
+	waitForRefresh(t, catalog)
+
+	var list struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(catalog.get(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 2 || list.Data[0].ID != "claude-new" || list.Data[1].ID != "claude-old" ||
+		list.Data[0].Object != "model" || list.Data[0].Created != 1767312000 {
+		t.Errorf("refreshed list = %+v, want both upstream pages in the merged format", list.Data)
+	}
+
+	// Stale data is served while the upstream fails
+	refreshed := catalog.get()
+	failing.Store(true)
+	catalog.mu.Lock()
+	catalog.fetchedAt = time.Time{}
+	catalog.mu.Unlock()
+	catalog.get()
+	waitForRefresh(t, catalog)
+	if got := catalog.get(); string(got) != string(refreshed) {
+		t.Errorf("response after failed refresh = %s, want the last known list", got)
+	}
+}
+
+// waitForRefresh waits for a background refresh of catalog to finish.
+func waitForRefresh(t *testing.T, catalog *modelCatalog) {
+	t.Helper()
+	for range 100 {
+		catalog.mu.Lock()
+		refreshing := catalog.refreshing
+		catalog.mu.Unlock()
+		if !refreshing {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("refresh did not finish")
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ndjsonMediaType is the content type of newline-delimited JSON streams.
+const ndjsonMediaType = "application/x-ndjson"
+
+// NDJSON streams chat completion chunks as newline-delimited JSON instead of SSE
+// when requested with "?stream_format=ndjson" or "Accept: application/x-ndjson",
+// for clients and scripts without an SSE parser. Each line holds the data of one
+// event (chunks, or an error object); the [DONE] marker is dropped, the end of
+// the response ends the stream. Other responses pass unchanged.
+func NDJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wantsNDJSON(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&ndjsonWriter{ResponseWriter: w}, r)
+	})
+}
+
+// wantsNDJSON reports whether the client asked for an NDJSON stream.
+func wantsNDJSON(r *http.Request) bool {
+	if r.URL.Query().Get("stream_format") == "ndjson" {
+		return true
+	}
+	for accept := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == ndjsonMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// ndjsonWriter converts an SSE response into NDJSON as it is written.
+type ndjsonWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	convert     bool         // response is an event stream
+	pending     bytes.Buffer // incomplete SSE line
+}
+
+// WriteHeader switches event stream responses to NDJSON.
+func (nw *ndjsonWriter) WriteHeader(code int) {
+	if nw.wroteHeader {
+		return
+	}
+	nw.wroteHeader = true
+
+	mediaType, _, _ := mime.ParseMediaType(nw.Header().Get("Content-Type"))
+	if code == http.StatusOK && mediaType == "text/event-stream" {
+		nw.convert = true
+		nw.Header().Set("Content-Type", ndjsonMediaType)
+		nw.Header().Del("Content-Length")
+	}
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+// Write converts complete SSE lines, holding back the rest for the next write.
+func (nw *ndjsonWriter) Write(p []byte) (int, error) {
+	nw.WriteHeader(http.StatusOK)
+	if !nw.convert {
+		return nw.ResponseWriter.Write(p)
+	}
+
+	nw.pending.Write(p)
+	for {
+		i := bytes.IndexByte(nw.pending.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := bytes.TrimSuffix(nw.pending.Next(i+1), []byte("\n"))
+		data, ok := bytes.CutPrefix(bytes.TrimSuffix(line, []byte("\r")), sseDataPrefix)
+		if !ok || string(data) == "[DONE]" {
+			continue
+		}
+		if _, err := nw.ResponseWriter.Write(data); err != nil {
+			return 0, err
+		}
+		if _, err := nw.ResponseWriter.Write(sseNewline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush implements http.Flusher for streaming handlers.
+func (nw *ndjsonWriter) Flush() {
+	_ = http.NewResponseController(nw.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (nw *ndjsonWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
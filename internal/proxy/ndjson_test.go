@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNDJSON(t *testing.T) {
+	stream := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream;charset=utf-8")
+		// Split writes to cover lines spanning them
+		_, _ = io.WriteString(w, "data: {\"id\":\"1\"}\n\nda")
+		_, _ = io.WriteString(w, "ta: {\"id\":\"2\"}\n\nevent: error\ndata: {\"error\":{}}\n\n")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}
+	buffered := func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{}}`)
+	}
+
+	tests := []struct {
+		name            string
+		handler         http.HandlerFunc
+		target          string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "query",
+			handler:         stream,
+			target:          "/v1/chat/completions?stream_format=ndjson",
+			wantContentType: "application/x-ndjson",
+			wantBody:        "{\"id\":\"1\"}\n{\"id\":\"2\"}\n{\"error\":{}}\n",
+		},
+		{
+			name:            "accept",
+			handler:         stream,
+			target:          "/v1/chat/completions",
+			accept:          "application/json, application/x-ndjson",
+			wantContentType: "application/x-ndjson",
+			wantBody:        "{\"id\":\"1\"}\n{\"id\":\"2\"}\n{\"error\":{}}\n",
+		},
+		{
+			name:            "not requested",
+			handler:         stream,
+			target:          "/v1/chat/completions",
+			wantContentType: "text/event-stream;charset=utf-8",
+			wantBody:        "data: {\"id\":\"1\"}\n\ndata: {\"id\":\"2\"}\n\nevent: error\ndata: {\"error\":{}}\n\ndata: [DONE]\n\n",
+		},
+		{
+			name:            "error response",
+			handler:         buffered,
+			target:          "/v1/chat/completions?stream_format=ndjson",
+			wantContentType: "application/json",
+			wantBody:        `{"error":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			NDJSON(tt.handler).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
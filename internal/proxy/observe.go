@@ -0,0 +1,220 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	// maxObservedHistory bounds the events of a stream kept for observers attaching
+	// late; the oldest are dropped first.
+	maxObservedHistory = 1 << 20
+
+	// observerBuffer is the number of events buffered per observer. Observers
+	// falling further behind are disconnected rather than slowing down the client.
+	observerBuffer = 256
+)
+
+// WithStreamObservers lets admins watch upstream SSE streams in progress at
+// GET /admin/streams/{request_id}, e.g. to debug what a client is receiving.
+// Observers get the raw Anthropic events from the start of the stream, as far
+// as kept, then live until it ends. Requires WithAdminToken.
+func WithStreamObservers(enabled bool) Option {
+	return func(c *config) {
+		c.streamObservers = enabled
+	}
+}
+
+// streamHub relays upstream SSE streams in progress to observers, by request ID.
+type streamHub struct {
+	mu      sync.Mutex
+	streams map[string]*observedStream
+}
+
+func newStreamHub() *streamHub {
+	return &streamHub{streams: make(map[string]*observedStream)}
+}
+
+// observe wraps body, the SSE stream of the request with id, so that what is read
+// from it reaches observers. A retried request replaces the stream of its
+// previous attempt.
+func (h *streamHub) observe(id string, body io.ReadCloser) io.ReadCloser {
+	s := &observedStream{observers: make(map[chan []byte]struct{})}
+	h.mu.Lock()
+	if prev, ok := h.streams[id]; ok {
+		prev.end()
+	}
+	h.streams[id] = s
+	h.mu.Unlock()
+
+	return &observedBody{ReadCloser: body, stream: s, done: func() {
+		h.mu.Lock()
+		if h.streams[id] == s {
+			delete(h.streams, id)
+		}
+		h.mu.Unlock()
+		s.end()
+	}}
+}
+
+// subscribe returns the stream with id, its events so far and a channel of the
+// following ones, closed when the stream ends. ok is false if no such stream is
+// in progress.
+func (h *streamHub) subscribe(id string) (s *observedStream, history [][]byte, events chan []byte, ok bool) {
+	h.mu.Lock()
+	s, ok = h.streams[id]
+	h.mu.Unlock()
+	if !ok {
+		return nil, nil, nil, false
+	}
+	history, events = s.subscribe()
+	return s, history, events, events != nil
+}
+
+// observedStream holds the events of a stream and the channels of its observers.
+type observedStream struct {
+	mu        sync.Mutex
+	history   [][]byte // Complete events, oldest first
+	size      int      // Of history in bytes
+	partial   []byte   // Incomplete event read so far
+	observers map[chan []byte]struct{}
+	ended     bool
+}
+
+// write splits data into events and relays the complete ones to observers.
+func (s *observedStream) write(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+
+	s.partial = append(s.partial, data...)
+	for {
+		i := bytes.Index(s.partial, []byte("\n\n"))
+		if i < 0 {
+			return
+		}
+		event := bytes.Clone(s.partial[:i+2])
+		s.partial = s.partial[i+2:]
+
+		s.history = append(s.history, event)
+		s.size += len(event)
+		for s.size > maxObservedHistory && len(s.history) > 1 {
+			s.size -= len(s.history[0])
+			s.history = s.history[1:]
+		}
+		for ch := range s.observers {
+			select {
+			case ch <- event:
+			default:
+				delete(s.observers, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+// subscribe registers an observer. Returns a nil channel if the stream ended.
+func (s *observedStream) subscribe() ([][]byte, chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return nil, nil
+	}
+	ch := make(chan []byte, observerBuffer)
+	s.observers[ch] = struct{}{}
+	return append([][]byte(nil), s.history...), ch
+}
+
+// unsubscribe removes an observer that stopped reading, if still registered.
+func (s *observedStream) unsubscribe(ch chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.observers[ch]; ok {
+		delete(s.observers, ch)
+		close(ch)
+	}
+}
+
+// end disconnects all observers.
+func (s *observedStream) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+	for ch := range s.observers {
+		close(ch)
+	}
+	s.observers = nil
+}
+
+// observedBody copies what is read to its stream and calls done once at the end
+// of the body or when closed.
+type observedBody struct {
+	io.ReadCloser
+	stream *observedStream
+	done   func()
+	once   sync.Once
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.stream.write(p[:n])
+	}
+	if err != nil {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// observeStreamHandler serves the stream of the request ID in the path as SSE.
+func observeStreamHandler(hub *streamHub) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream, history, events, ok := hub.subscribe(r.PathValue("request_id"))
+		if !ok {
+			http.Error(w, "no stream in progress for this request ID", http.StatusNotFound)
+			return
+		}
+		defer stream.unsubscribe(events)
+
+		sse, err := NewSSEWriter(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, event := range history {
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+		}
+		sse.flusher.Flush()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if _, err := w.Write(event); err != nil {
+					return
+				}
+				sse.flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
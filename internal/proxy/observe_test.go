@@ -0,0 +1,129 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestStreamObservers(t *testing.T) {
+	const (
+		first = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_01\",\"type\":\"message\",\"role\":\"assistant\",\"model\":\"claude-sonnet-4-5\",\"content\":[],\"usage\":{\"input_tokens\":1,\"output_tokens\":0}}}\n\n"
+		rest  = "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, first)
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, rest)
+	}))
+	defer upstream.Close()
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"), WithAdminToken("admin-secret"), WithStreamObservers(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	observe := func(id, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/streams/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := observe("req-1", "nope"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if resp := observe("req-1", "admin-secret"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("before stream: status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	// Start the client stream and wait until it is observable
+	clientDone := make(chan string)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages",
+			strings.NewReader(`{"model":"claude-sonnet-4-5","max_tokens":16,"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("X-Request-ID", "req-1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			clientDone <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		clientDone <- string(body)
+	}()
+
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		resp = observe("req-1", "admin-secret")
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("stream never became observable")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// The event sent before attaching is replayed
+	r := bufio.NewReader(resp.Body)
+	var got strings.Builder
+	for !strings.HasSuffix(got.String(), "\n\n") {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading first event: %v", err)
+		}
+		got.WriteString(line)
+	}
+	if got.String() != first {
+		t.Errorf("first event = %q, want %q", got.String(), first)
+	}
+
+	// Live events follow until the stream ends
+	close(release)
+	remaining, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(remaining) != rest {
+		t.Errorf("live events = %q, want %q", remaining, rest)
+	}
+	if body := <-clientDone; body != first+rest {
+		t.Errorf("client body = %q, want %q", body, first+rest)
+	}
+
+	if resp := observe("req-1", "admin-secret"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("after stream: status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestStreamObserversRequireAdminToken(t *testing.T) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	if _, err := New(ts, readyChecker{}, WithStreamObservers(true)); err == nil {
+		t.Error("New() accepted stream observers without admin token")
+	}
+}
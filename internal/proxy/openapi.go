@@ -0,0 +1,339 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+)
+
+// openAPIField is a chat completion request field the adapter honors.
+type openAPIField struct {
+	name   string
+	schema map[string]any
+}
+
+// chatCompletionFields lists the request fields translated to Anthropic. Fields of
+// the OpenAI API missing here (n, seed, logprobs, response_format, audio, ...) are
+// accepted but ignored, so they are left out of the description.
+var chatCompletionFields = []openAPIField{
+	{"model", map[string]any{"type": "string", "description": "Anthropic model ID or alias."}},
+	{"messages", map[string]any{"type": "array", "minItems": 1, "items": ref("ChatCompletionMessage")}},
+	{"max_completion_tokens", map[string]any{"type": []string{"integer", "null"}, "description": "Defaults to 8192."}},
+	{"max_tokens", map[string]any{"type": []string{"integer", "null"}, "deprecated": true, "description": "Used if max_completion_tokens is unset."}},
+	{"temperature", map[string]any{"type": []string{"number", "null"}, "minimum": 0, "maximum": 1}},
+	{"top_p", map[string]any{"type": []string{"number", "null"}, "minimum": 0, "maximum": 1}},
+	{"stop", map[string]any{
+		"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			map[string]any{"type": "null"},
+		},
+	}},
+	{"stream", map[string]any{"type": []string{"boolean", "null"}}},
+	{"tools", map[string]any{"type": "array", "items": ref("ChatCompletionTool")}},
+	{"tool_choice", map[string]any{
+		"oneOf": []any{
+			map[string]any{"type": "string", "enum": []string{"none", "auto", "required"}},
+			map[string]any{
+				"type":     "object",
+				"required": []string{"type", "function"},
+				"properties": map[string]any{
+					"type":     map[string]any{"type": "string", "enum": []string{"function"}},
+					"function": map[string]any{"type": "object", "required": []string{"name"}, "properties": map[string]any{"name": map[string]any{"type": "string"}}},
+				},
+			},
+		},
+	}},
+	{"parallel_tool_calls", map[string]any{"type": "boolean"}},
+	{"reasoning_effort", map[string]any{
+		"type":        []string{"string", "null"},
+		"enum":        []any{"none", "minimal", "low", "medium", "high", nil},
+		"description": "Enables extended thinking with a budget of 1024, 8192 or 24576 tokens unless configured otherwise; none and minimal disable it.",
+	}},
+	{"extra_body", map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"thinking": map[string]any{
+				"type":        "object",
+				"description": "Anthropic thinking configuration, overrides reasoning_effort.",
+				"properties": map[string]any{
+					"type":          map[string]any{"type": "string", "enum": []string{"enabled", "disabled"}},
+					"budget_tokens": map[string]any{"type": []string{"integer", "string"}},
+				},
+			},
+			"container": map[string]any{
+				"type":        []string{"string", "object"},
+				"description": "Code execution container (beta), by ID or with the skills to load.",
+			},
+			"betas": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Additional Anthropic beta features.",
+			},
+		},
+	}},
+	{"service_tier", map[string]any{
+		"type":        []string{"string", "null"},
+		"enum":        []any{"auto", "default", nil},
+		"description": "Both map to Anthropic's auto tier.",
+	}},
+	{"safety_identifier", map[string]any{"type": "string", "description": "Sent as metadata.user_id."}},
+	{"user", map[string]any{"type": "string", "deprecated": true, "description": "Sent as metadata.user_id if safety_identifier is unset."}},
+	{"metadata", map[string]any{
+		"type":                 []string{"object", "null"},
+		"additionalProperties": map[string]any{"type": "string"},
+		"properties": map[string]any{
+			"claudine_tag": map[string]any{"type": "string", "pattern": "^[A-Za-z0-9._:/-]{1,64}$", "description": "Attributes usage to a tag."},
+		},
+	}},
+}
+
+// openAPIComponents are the schemas referenced by chatCompletionFields and responses.
+var openAPIComponents = map[string]any{
+	"ChatCompletionMessage": map[string]any{
+		"type":     "object",
+		"required": []string{"role"},
+		"properties": map[string]any{
+			"role":         map[string]any{"type": "string", "enum": []string{"system", "developer", "user", "assistant", "tool", "function"}},
+			"content":      map[string]any{"oneOf": []any{map[string]any{"type": "string"}, map[string]any{"type": "array", "items": ref("ChatCompletionContentPart")}, map[string]any{"type": "null"}}},
+			"tool_calls":   map[string]any{"type": "array", "items": ref("ChatCompletionMessageToolCall")},
+			"tool_call_id": map[string]any{"type": "string", "description": "Required for tool messages."},
+		},
+	},
+	"ChatCompletionContentPart": map[string]any{
+		"type":        "object",
+		"required":    []string{"type"},
+		"description": "Images and files only in user messages; input_audio parts are dropped.",
+		"properties": map[string]any{
+			"type":      map[string]any{"type": "string", "enum": []string{"text", "image_url", "file", "refusal"}},
+			"text":      map[string]any{"type": "string"},
+			"refusal":   map[string]any{"type": "string"},
+			"image_url": map[string]any{"type": "object", "required": []string{"url"}, "properties": map[string]any{"url": map[string]any{"type": "string", "description": "HTTPS URL or data URL."}}},
+			"file": map[string]any{
+				"type":        "object",
+				"description": "PDF or text files.",
+				"properties": map[string]any{
+					"file_id":   map[string]any{"type": "string"},
+					"file_data": map[string]any{"type": "string", "description": "Data URL."},
+					"filename":  map[string]any{"type": "string"},
+				},
+			},
+		},
+	},
+	"ChatCompletionMessageToolCall": map[string]any{
+		"type":     "object",
+		"required": []string{"id", "type", "function"},
+		"properties": map[string]any{
+			"id":   map[string]any{"type": "string"},
+			"type": map[string]any{"type": "string", "enum": []string{"function"}},
+			"function": map[string]any{
+				"type":     "object",
+				"required": []string{"name", "arguments"},
+				"properties": map[string]any{
+					"name":      map[string]any{"type": "string"},
+					"arguments": map[string]any{"type": "string", "description": "JSON encoded arguments."},
+				},
+			},
+		},
+	},
+	"ChatCompletionTool": map[string]any{
+		"type":        "object",
+		"required":    []string{"type", "function"},
+		"description": "Only function tools; custom tools are rejected.",
+		"properties": map[string]any{
+			"type": map[string]any{"type": "string", "enum": []string{"function"}},
+			"function": map[string]any{
+				"type":     "object",
+				"required": []string{"name"},
+				"properties": map[string]any{
+					"name":        map[string]any{"type": "string"},
+					"description": map[string]any{"type": "string"},
+					"parameters":  map[string]any{"type": "object", "description": "JSON Schema, normalized for Anthropic."},
+					"strict":      map[string]any{"type": "boolean"},
+				},
+			},
+		},
+	},
+	"CreateChatCompletionResponse": map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":      map[string]any{"type": "string"},
+			"object":  map[string]any{"type": "string", "enum": []string{"chat.completion"}},
+			"created": map[string]any{"type": "integer"},
+			"model":   map[string]any{"type": "string"},
+			"choices": map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+			"usage":   map[string]any{"type": "object"},
+		},
+	},
+	"ErrorResponse": map[string]any{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": map[string]any{
+			"error": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"message": map[string]any{"type": "string"},
+					"type":    map[string]any{"type": "string"},
+					"code":    map[string]any{"type": []string{"string", "null"}},
+				},
+			},
+		},
+	},
+}
+
+// ref returns a reference to a schema in openAPIComponents.
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// openAPIDocument describes the OpenAI-compatible routes served with cfg.
+// prefix is the API path prefix (e.g., "/v1").
+func openAPIDocument(prefix string, cfg *config) map[string]any {
+	properties := make(map[string]any, len(chatCompletionFields))
+	for _, f := range chatCompletionFields {
+		properties[f.name] = f.schema
+	}
+	schemas := map[string]any{
+		"CreateChatCompletionRequest": map[string]any{
+			"type":       "object",
+			"required":   []string{"model", "messages"},
+			"properties": properties,
+		},
+	}
+	maps.Copy(schemas, openAPIComponents)
+
+	errorResponse := map[string]any{
+		"description": "OpenAI-style error",
+		"content":     map[string]any{"application/json": map[string]any{"schema": ref("ErrorResponse")}},
+	}
+	chatCompletion := map[string]any{
+		"summary":     "Create a chat completion",
+		"description": "Translated to the Anthropic Messages API. Streams as SSE, or as NDJSON with ?stream_format=ndjson or Accept: application/x-ndjson.",
+		"requestBody": map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": ref("CreateChatCompletionRequest")}},
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Completion, or chunks if stream is true",
+				"content": map[string]any{
+					"application/json":     map[string]any{"schema": ref("CreateChatCompletionResponse")},
+					"text/event-stream":    map[string]any{"schema": map[string]any{"type": "string"}},
+					"application/x-ndjson": map[string]any{"schema": map[string]any{"type": "string"}},
+				},
+			},
+			"default": errorResponse,
+		},
+	}
+	jsonResponse := func(description string) map[string]any {
+		return map[string]any{
+			"responses": map[string]any{
+				"200":     map[string]any{"description": description, "content": map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}}},
+				"default": errorResponse,
+			},
+		}
+	}
+	fileID := []any{map[string]any{"name": "file_id", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}}
+
+	paths := map[string]any{
+		prefix + "/chat/completions": map[string]any{"post": chatCompletion},
+		prefix + "/models":           map[string]any{"get": jsonResponse("Available models")},
+		prefix + "/files": map[string]any{
+			"get": merge(jsonResponse("Uploaded files"), map[string]any{
+				"parameters": []any{
+					map[string]any{"name": "after", "in": "query", "schema": map[string]any{"type": "string"}},
+					map[string]any{"name": "limit", "in": "query", "schema": map[string]any{"type": "integer"}},
+				},
+			}),
+			"post": merge(jsonResponse("Uploaded file"), map[string]any{
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{"multipart/form-data": map[string]any{"schema": map[string]any{
+						"type":     "object",
+						"required": []string{"file"},
+						"properties": map[string]any{
+							"file":    map[string]any{"type": "string", "format": "binary"},
+							"purpose": map[string]any{"type": "string"},
+						},
+					}}},
+				},
+			}),
+		},
+		prefix + "/files/{file_id}": map[string]any{
+			"get":    merge(jsonResponse("File metadata"), map[string]any{"parameters": fileID}),
+			"delete": merge(jsonResponse("Deletion status"), map[string]any{"parameters": fileID}),
+		},
+		prefix + "/files/{file_id}/content": map[string]any{
+			"get": map[string]any{
+				"parameters": fileID,
+				"responses": map[string]any{
+					"200":     map[string]any{"description": "File content", "content": map[string]any{"application/octet-stream": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}},
+					"default": errorResponse,
+				},
+			},
+		},
+		"/openai/deployments/{deployment}/chat/completions": map[string]any{
+			"post": merge(chatCompletion, map[string]any{
+				"summary": "Create a chat completion (Azure OpenAI URL scheme)",
+				"parameters": []any{
+					map[string]any{"name": "deployment", "in": "path", "required": true, "schema": map[string]any{"type": "string"}, "description": "Deployment name, mapped to a model."},
+					map[string]any{"name": "api-version", "in": "query", "schema": map[string]any{"type": "string"}, "description": "Accepted and ignored."},
+				},
+			}),
+		},
+	}
+
+	// Forwarded endpoints are served by another upstream; their fields are not ours to describe
+	for _, route := range cfg.forwards {
+		path, wildcard := strings.CutSuffix(route.pattern, "/*")
+		if wildcard {
+			path += "/{path}"
+		}
+		paths[path] = map[string]any{
+			"description": "Forwarded unchanged to a configured upstream.",
+		}
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Claudine Proxy OpenAI-compatible API",
+			"version":     "1",
+			"description": "Endpoints and request fields this proxy honors. Other OpenAI fields are accepted but ignored.",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+	if cfg.clientKeys {
+		doc["components"].(map[string]any)["securitySchemes"] = map[string]any{
+			"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+		}
+		doc["security"] = []any{map[string]any{"bearer": []string{}}}
+	}
+	return doc
+}
+
+// merge returns a copy of base with the keys of extra set.
+func merge(base, extra map[string]any) map[string]any {
+	merged := maps.Clone(base)
+	maps.Copy(merged, extra)
+	return merged
+}
+
+// openAPIHandler serves the OpenAPI description as JSON.
+func openAPIHandler(doc map[string]any) (http.HandlerFunc, error) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(body); err != nil {
+			slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+		}
+	}, nil
+}
@@ -0,0 +1,80 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestChatCompletionFieldsExist(t *testing.T) {
+	tags := make(map[string]bool)
+	typ := reflect.TypeFor[openaiadapter.CreateChatCompletionRequest]()
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		tags[name] = true
+	}
+	for _, f := range chatCompletionFields {
+		if !tags[f.name] {
+			t.Errorf("described field %q is not part of the request type", f.name)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	p, err := New(ts, readyChecker{},
+		WithClientKeys(true),
+		WithForward("/v1/embeddings", "https://api.openai.com/v1", ""),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Security   []map[string]any          `json:"security"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	for _, path := range []string{"/v1/chat/completions", "/v1/models", "/v1/files/{file_id}", "/openai/deployments/{deployment}/chat/completions", "/v1/embeddings"} {
+		if doc.Paths[path] == nil {
+			t.Errorf("missing path %s", path)
+		}
+	}
+	if doc.Paths["/v1/completions"] != nil {
+		t.Error("unsupported endpoint described")
+	}
+	if len(doc.Security) != 1 {
+		t.Errorf("security = %v, want bearer with client keys", doc.Security)
+	}
+
+	request := doc.Components.Schemas["CreateChatCompletionRequest"].Properties
+	if request["reasoning_effort"] == nil || request["seed"] != nil {
+		t.Errorf("request properties = %v", request)
+	}
+}
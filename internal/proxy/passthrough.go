@@ -0,0 +1,33 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+)
+
+// passthroughHandler forwards requests whose path matches one of patterns to
+// the upstream unchanged (apart from authentication and required headers).
+// Other paths receive a 404 in Anthropic's error format.
+//
+// A pattern matches its exact path; a trailing "/*" additionally matches every
+// path below it, e.g. "/v1/files/*" matches "/v1/files" and "/v1/files/file_01/content".
+func passthroughHandler(patterns []string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !matchPassthrough(patterns, r.URL.Path) {
+			writeAnthropicErrorStatus(w, r, http.StatusNotFound, "path not allowed: "+r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// matchPassthrough reports whether path matches any of patterns.
+func matchPassthrough(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestPassthrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(append([]byte(r.URL.Path+" "), body...))
+	}))
+	defer upstream.Close()
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"), WithPassthrough("/v1/skills/*", "/v1/organizations/usage_report/messages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{method: http.MethodPost, path: "/v1/skills", wantStatus: http.StatusOK, wantBody: "/v1/skills raw-bytes"},
+		{method: http.MethodGet, path: "/v1/skills/skill_01/versions", wantStatus: http.StatusOK, wantBody: "/v1/skills/skill_01/versions raw-bytes"},
+		{method: http.MethodGet, path: "/v1/organizations/usage_report/messages", wantStatus: http.StatusOK, wantBody: "/v1/organizations/usage_report/messages raw-bytes"},
+		{method: http.MethodGet, path: "/v1/organizations/usage_report/claude_code", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/v1/skillset", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader("raw-bytes")))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
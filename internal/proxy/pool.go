@@ -0,0 +1,309 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+const (
+	// poolUsageHalfLife is how quickly past token usage stops counting when
+	// assigning conversations to the least used account.
+	poolUsageHalfLife = time.Hour
+
+	// maxPoolConversations bounds the conversation assignments remembered; the
+	// least recently used are forgotten first.
+	maxPoolConversations = 10000
+)
+
+type conversationContextKey struct{}
+
+// conversation identifies the conversation a request belongs to.
+type conversation struct {
+	key   string
+	named bool // Named by the client rather than derived from its credential
+}
+
+// conversationFromContext returns the conversation key of the request, or "".
+func conversationFromContext(ctx context.Context) string {
+	c, _ := ctx.Value(conversationContextKey{}).(conversation)
+	return c.key
+}
+
+// namedConversationFromContext returns the conversation key of the request if the
+// client named the conversation, or "".
+func namedConversationFromContext(ctx context.Context) string {
+	if c, _ := ctx.Value(conversationContextKey{}).(conversation); c.named {
+		return c.key
+	}
+	return ""
+}
+
+// conversationState returns the key that per-conversation state of the client
+// keyID, served by tenant, is tracked by. Clients name conversations freely, so
+// the same name sent with another key or to another tenant is another conversation.
+func conversationState(tenant, keyID, conversation string) string {
+	return tenant + "\x00" + keyID + "\x00" + conversation
+}
+
+// identifyConversation stores the conversation key of the request: the body's
+// prompt_cache_key or metadata.conversation_id. If pooled, requests not naming
+// their conversation fall back to the client key ID, which PoolTransport keeps
+// requests on one account by. No-op unless pooled or named is set.
+func identifyConversation(pooled, named bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !pooled && !named {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Tenant requests are served by their own account
+			tenant := tenantFromContext(r.Context()) != nil
+			if tenant && !named {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var fields struct {
+				PromptCacheKey string `json:"prompt_cache_key"`
+				Metadata       struct {
+					ConversationID string `json:"conversation_id"`
+				} `json:"metadata"`
+			}
+			var c conversation
+			if json.Unmarshal(body, &fields) == nil {
+				if fields.PromptCacheKey != "" {
+					c = conversation{key: "prompt_cache_key:" + fields.PromptCacheKey, named: true}
+				} else if fields.Metadata.ConversationID != "" {
+					c = conversation{key: "conversation_id:" + fields.Metadata.ConversationID, named: true}
+				}
+			}
+			if c.key == "" && pooled && !tenant {
+				c.key = usage.KeyID(r)
+			}
+			if c.key != "" {
+				r = r.WithContext(context.WithValue(r.Context(), conversationContextKey{}, c))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PoolAccount is an account of a PoolTransport.
+type PoolAccount struct {
+	Name      string
+	Transport http.RoundTripper
+
+	// Guarded by the PoolTransport's mutex
+	limitedUntil time.Time
+	usage        float64 // Tokens, decayed by poolUsageHalfLife
+	usageAt      time.Time
+}
+
+// decayedUsage returns a's token usage decayed to now.
+func (a *PoolAccount) decayedUsage(now time.Time) float64 {
+	return a.usage * math.Exp2(-now.Sub(a.usageAt).Seconds()/poolUsageHalfLife.Seconds())
+}
+
+// PoolTransport is an http.RoundTripper spreading requests over pooled accounts
+// while keeping each conversation (see identifyConversation) on one account, so
+// its turns hit the prompt cache of that account.
+//
+// A new conversation is assigned to the account with the least recent token
+// usage. It moves only once its account is rate limited: the rejected request is
+// retried on the least used account not rate limited, which the conversation then
+// sticks to. Requests without conversation key go to the least used account.
+type PoolTransport struct {
+	Accounts []*PoolAccount
+
+	// Spool buffers request bodies for retries; nil buffers in memory.
+	Spool *BodySpool
+
+	mu            sync.Mutex
+	ll            *list.List // Of *poolConversation, most recently used first
+	conversations map[string]*list.Element
+}
+
+// poolConversation is the account a conversation is assigned to.
+type poolConversation struct {
+	key     string
+	account *PoolAccount
+}
+
+// Compile-time check that PoolTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*PoolTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *PoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := conversationFromContext(req.Context())
+	account := t.pick(key, nil)
+
+	// Buffer the body so a rate limited request can be retried on another account
+	var body *spooledBody
+	if len(t.Accounts) > 1 {
+		var err error
+		if body, err = t.Spool.rewind(req); err != nil {
+			return nil, err
+		}
+	}
+
+	tried := make(map[*PoolAccount]bool, len(t.Accounts))
+	for {
+		tried[account] = true
+		middleware.SetLogAttrs(req.Context(), slog.String("account", account.Name))
+
+		// Virtual keys authenticate against the proxy only
+		newReq := req.Clone(req.Context())
+		newReq.Header.Del("X-Api-Key")
+		newReq.Header.Del("Api-Key")
+		if body != nil {
+			newReq.Body = body.Reader()
+		}
+
+		resp, err := account.Transport.RoundTrip(newReq)
+		if err != nil {
+			return closeAfter(nil, err, body)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			resp.Body = &poolBody{ReadCloser: resp.Body, done: func() {
+				t.recordUsage(account, usage.FromContext(req.Context()).Tokens())
+			}}
+			return closeAfter(resp, nil, body)
+		}
+
+		until := quotaReset(resp, time.Now())
+		t.limit(account, until)
+		next := t.pick(key, tried)
+		if next == nil {
+			return closeAfter(resp, nil, body)
+		}
+		slog.WarnContext(req.Context(), "pooled account rate limited, moving conversation",
+			"account", account.Name,
+			"until", until,
+			"next", next.Name,
+		)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		account = next
+	}
+}
+
+// pick returns the account to send a request of conversation key to, excluding
+// tried accounts. On first try (tried empty) it keeps the conversation's account
+// unless rate limited and falls back to the account whose limit resets first if
+// all are; retries only go to accounts not rate limited and return nil if none is
+// left. The account picked is remembered for the conversation.
+func (t *PoolTransport) pick(key string, tried map[*PoolAccount]bool) *PoolAccount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+
+	if t.conversations == nil {
+		t.ll = list.New()
+		t.conversations = make(map[string]*list.Element)
+	}
+	elem, known := t.conversations[key]
+	if known && len(tried) == 0 {
+		if c := elem.Value.(*poolConversation); now.After(c.account.limitedUntil) {
+			t.ll.MoveToFront(elem)
+			return c.account
+		}
+	}
+
+	var best *PoolAccount
+	for _, a := range t.Accounts {
+		limited := now.Before(a.limitedUntil)
+		if tried[a] || limited && len(tried) > 0 {
+			continue
+		}
+		if best == nil {
+			best = a
+			continue
+		}
+		bestLimited := now.Before(best.limitedUntil)
+		switch {
+		case limited != bestLimited:
+			if !limited {
+				best = a
+			}
+		case limited:
+			if a.limitedUntil.Before(best.limitedUntil) {
+				best = a
+			}
+		case a.decayedUsage(now) < best.decayedUsage(now):
+			best = a
+		}
+	}
+	if best == nil || key == "" {
+		return best
+	}
+
+	if known {
+		elem.Value.(*poolConversation).account = best
+		t.ll.MoveToFront(elem)
+		return best
+	}
+	t.conversations[key] = t.ll.PushFront(&poolConversation{key: key, account: best})
+	if t.ll.Len() > maxPoolConversations {
+		oldest := t.ll.Back()
+		t.ll.Remove(oldest)
+		delete(t.conversations, oldest.Value.(*poolConversation).key)
+	}
+	return best
+}
+
+// limit marks a rate limited until the given time.
+func (t *PoolTransport) limit(a *PoolAccount, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(a.limitedUntil) {
+		a.limitedUntil = until
+	}
+}
+
+// recordUsage adds the tokens a request spent on a. Cache reads are left out as
+// they barely count against usage limits.
+func (t *PoolTransport) recordUsage(a *PoolAccount, tokens usage.Tokens) {
+	spent := tokens.InputTokens + tokens.CacheCreationInputTokens + tokens.OutputTokens
+	if spent == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	a.usage = a.decayedUsage(now) + float64(spent)
+	a.usageAt = now
+}
+
+// poolBody calls done once when the response body is closed, after the usage
+// transport parsed the tokens spent.
+type poolBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *poolBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
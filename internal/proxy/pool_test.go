@@ -0,0 +1,96 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// poolUpstream answers with the account name, spending tokens on each request,
+// or 429 while limited.
+func poolUpstream(name string, tokens int, limited *bool, served *[]string) http.RoundTripper {
+	return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"model":"claude-sonnet-4-5"}` {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if limited != nil && *limited {
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"60"}},
+				Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"rate_limit_error"}}`)),
+				Request:    r,
+			}, nil
+		}
+		*served = append(*served, name)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"type":"message","usage":{"input_tokens":` +
+				strings.Repeat("1", tokens) + `,"output_tokens":1}}`)),
+			Request: r,
+		}, nil
+	})
+}
+
+func TestPoolTransport(t *testing.T) {
+	var served []string
+	var limitedA bool
+	pool := &PoolTransport{Accounts: []*PoolAccount{
+		{Name: "a", Transport: poolUpstream("a", 4, &limitedA, &served)},
+		{Name: "b", Transport: poolUpstream("b", 3, nil, &served)},
+	}}
+	transport := &usage.Transport{Base: pool}
+
+	send := func(key string) string {
+		t.Helper()
+		ctx, _ := usage.WithRecord(context.Background())
+		if key != "" {
+			ctx = context.WithValue(ctx, conversationContextKey{}, conversation{key: key, named: true})
+		}
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages",
+			strings.NewReader(`{"model":"claude-sonnet-4-5"}`))
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d", resp.StatusCode)
+		}
+		return served[len(served)-1]
+	}
+
+	// New conversations go to the least used account and stay there
+	first := send("one")
+	second := send("two")
+	if first == second {
+		t.Fatalf("conversations one and two both assigned to %s, want the less used account", first)
+	}
+	for range 3 {
+		if got := send("one"); got != first {
+			t.Errorf("conversation one served by %s, want %s", got, first)
+		}
+	}
+
+	// A rate limited account hands its conversations over, which then stay moved
+	if first != "a" {
+		t.Fatalf("conversation one assigned to %s, want a", first)
+	}
+	limitedA = true
+	if got := send("one"); got != "b" {
+		t.Errorf("conversation one served by %s while a is rate limited, want b", got)
+	}
+	limitedA = false
+	if got := send("one"); got != "b" {
+		t.Errorf("conversation one served by %s after moving, want b", got)
+	}
+}
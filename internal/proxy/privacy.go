@@ -0,0 +1,157 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/json/jsontext"
+	"io"
+	"net/http"
+)
+
+// UserIDMode controls how end-user identifiers are forwarded to Anthropic.
+type UserIDMode string
+
+const (
+	// UserIDPassthrough forwards metadata.user_id unchanged.
+	UserIDPassthrough UserIDMode = "passthrough"
+
+	// UserIDHash replaces metadata.user_id with a keyed hash (stable, not reversible).
+	UserIDHash UserIDMode = "hash"
+
+	// UserIDDrop removes metadata.user_id entirely.
+	UserIDDrop UserIDMode = "drop"
+)
+
+// UserIDTransport is an http.RoundTripper that hashes or drops metadata.user_id
+// in Messages request bodies. Applies to native requests and to OpenAI
+// user/safety_identifier fields, which the adapter maps to metadata.user_id.
+type UserIDTransport struct {
+	Base http.RoundTripper
+	Mode UserIDMode
+
+	// Salt keys the hash, preventing dictionary attacks on guessable IDs (e.g., e-mail addresses).
+	Salt string
+}
+
+// Compile-time check that UserIDTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*UserIDTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *UserIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Mode == UserIDPassthrough || t.Mode == "" || req.Method != http.MethodPost || req.Body == nil {
+		return base.RoundTrip(req)
+	}
+
+	newReq := req.Clone(req.Context())
+	pr, pw := io.Pipe()
+	go func() {
+		err := rewriteUserID(req.Body, pw, t.rewrite)
+		pw.CloseWithError(err)
+		_ = req.Body.Close()
+	}()
+	newReq.Body = pr
+
+	// Rewriting changes body length, requiring chunked transfer encoding
+	newReq.ContentLength = -1
+	newReq.Header.Del("Content-Length")
+
+	return base.RoundTrip(newReq)
+}
+
+// rewrite returns the replacement user ID; false drops the field.
+func (t *UserIDTransport) rewrite(userID string) (string, bool) {
+	if t.Mode == UserIDDrop || userID == "" {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, []byte(t.Salt))
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil)[:16]), true
+}
+
+// rewriteUserID streams a Messages request and applies fn to metadata.user_id.
+// All other fields are copied unchanged.
+func rewriteUserID(r io.Reader, w io.Writer, fn func(string) (string, bool)) error {
+	dec := jsontext.NewDecoder(r)
+	enc := jsontext.NewEncoder(w)
+
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	if err := enc.WriteToken(tok); err != nil {
+		return err
+	}
+	if tok.Kind() != '{' {
+		return nil // Not an object, pass through
+	}
+
+	for dec.PeekKind() != '}' {
+		key, err := dec.ReadToken()
+		if err != nil {
+			return err
+		}
+		// Tokens are invalidated by the next decoder call
+		isMetadata := key.Kind() == '"' && key.String() == "metadata"
+		if err := enc.WriteToken(key); err != nil {
+			return err
+		}
+
+		val, err := dec.ReadValue()
+		if err != nil {
+			return err
+		}
+		if isMetadata {
+			val = rewriteMetadata(val, fn)
+		}
+		if err := enc.WriteValue(val); err != nil {
+			return err
+		}
+	}
+
+	tok, err = dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	return enc.WriteToken(tok)
+}
+
+// rewriteMetadata applies fn to the user_id of a metadata object.
+// Values that are not objects or lack a user_id are returned unchanged.
+func rewriteMetadata(val jsontext.Value, fn func(string) (string, bool)) jsontext.Value {
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal(val, &metadata); err != nil {
+		return val
+	}
+	raw, ok := metadata["user_id"]
+	if !ok {
+		return val
+	}
+
+	var userID string
+	if err := json.Unmarshal(raw, &userID); err != nil {
+		return val
+	}
+	if replaced, keep := fn(userID); keep {
+		encoded, err := json.Marshal(replaced)
+		if err != nil {
+			return val
+		}
+		metadata["user_id"] = encoded
+	} else {
+		delete(metadata, "user_id")
+	}
+
+	rewritten, err := json.Marshal(metadata)
+	if err != nil {
+		return val
+	}
+	return jsontext.Value(rewritten)
+}
@@ -0,0 +1,66 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRewriteUserID(t *testing.T) {
+	hash := (&UserIDTransport{Mode: UserIDHash, Salt: "pepper"}).rewrite
+	drop := (&UserIDTransport{Mode: UserIDDrop}).rewrite
+	hashed, _ := hash("alice@example.com")
+
+	tests := []struct {
+		name     string
+		input    string
+		fn       func(string) (string, bool)
+		expected string
+	}{
+		{
+			name:     "hash user_id",
+			input:    `{"model":"claude","metadata":{"user_id":"alice@example.com"},"messages":[]}`,
+			fn:       hash,
+			expected: `{"model":"claude","metadata":{"user_id":"` + hashed + `"},"messages":[]}`,
+		},
+		{
+			name:     "drop user_id",
+			input:    `{"model":"claude","metadata":{"user_id":"alice@example.com"}}`,
+			fn:       drop,
+			expected: `{"model":"claude","metadata":{}}`,
+		},
+		{
+			name:     "no metadata unchanged",
+			input:    `{"model":"claude","messages":[{"role":"user","content":"hi"}]}`,
+			fn:       hash,
+			expected: `{"model":"claude","messages":[{"role":"user","content":"hi"}]}`,
+		},
+		{
+			name:     "metadata without user_id unchanged",
+			input:    `{"metadata":{"other":1}}`,
+			fn:       drop,
+			expected: `{"metadata":{"other":1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := rewriteUserID(strings.NewReader(tt.input), &out, tt.fn); err != nil {
+				t.Fatalf("rewriteUserID() error = %v", err)
+			}
+			if got, want := normalizeJSON(t, out.String()), normalizeJSON(t, tt.expected); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+			if strings.Contains(out.String(), "alice@example.com") {
+				t.Error("raw user ID leaked")
+			}
+		})
+	}
+
+	if again, _ := hash("alice@example.com"); again != hashed {
+		t.Error("hash is not stable")
+	}
+}
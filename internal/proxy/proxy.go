@@ -10,13 +10,31 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/netip"
 	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/net/netutil"
 	"golang.org/x/oauth2"
 
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/cache"
+	"github.com/florianilch/claudine-proxy/internal/capability"
+	"github.com/florianilch/claudine-proxy/internal/capture"
+	"github.com/florianilch/claudine-proxy/internal/dashboard"
+	"github.com/florianilch/claudine-proxy/internal/metrics"
 	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/anthropicclaude"
+	"github.com/florianilch/claudine-proxy/internal/pacing"
+	"github.com/florianilch/claudine-proxy/internal/plugin"
+	"github.com/florianilch/claudine-proxy/internal/policy"
+	"github.com/florianilch/claudine-proxy/internal/record"
+	"github.com/florianilch/claudine-proxy/internal/routing"
+	"github.com/florianilch/claudine-proxy/internal/shadow"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/anthropicclaude"
 )
 
 const (
@@ -26,8 +44,15 @@ const (
 
 // Proxy represents the forward proxy server
 type Proxy struct {
-	mux    *http.ServeMux
-	server *http.Server
+	mux       *http.ServeMux
+	surfaces  []*surfaceServer
+	servers   []*http.Server
+	listeners []net.Listener // unwrapped listeners, kept for ListenerFiles
+	pending   *pendingConns
+	closing   atomic.Bool
+	limits    ServerLimits
+	streams   *streamCounter
+	endpoints *Endpoints
 }
 
 // Compile-time check that Proxy implements http.Handler
@@ -40,8 +65,108 @@ type ReadinessChecker interface {
 
 // config holds internal proxy configuration applied via Options.
 type config struct {
-	baseURL   string
-	transport http.RoundTripper
+	baseURL      string
+	transport    http.RoundTripper
+	plugins      []plugin.Filter
+	sinks        []usage.Sink
+	shadow       *shadow.Mirror
+	router       *routing.Router
+	policies     *policy.Enforcer
+	audit        *audit.Log
+	signingKey   []byte
+	capabilities *capability.Registry
+	tenants      []Tenant
+
+	validateMessages  bool
+	compressUpstream  bool
+	streamFilter      StreamFilter
+	errorRewrite      bool
+	impersonation     *ImpersonationProfile
+	tolerantInjection bool
+	spool             *BodySpool
+
+	adminToken      string
+	adminAPIKey     string
+	usageReports    bool
+	streamObservers bool
+	dashboard       *dashboard.Dashboard
+	metrics         *metrics.Registry
+	errors          *metrics.ErrorCollector
+	connMetrics     *metrics.ConnectionCollector
+	pacingMetrics   *metrics.PacingCollector
+	cache           cache.Store
+	cacheTTL        time.Duration
+	pacing          *pacing.Config
+	queue           *pacing.QueueConfig
+	userID          UserIDMode
+	userSalt        string
+
+	passthrough       []string
+	anthropicVersions *AnthropicVersions
+	forwards          []forwardRoute
+	azureDeployments  map[string]string
+	clientKeys        bool
+	fallbackAPIKey    string
+
+	endpoints             []string
+	endpointProbeInterval time.Duration
+
+	maxRequestAge   time.Duration
+	timestampHeader string
+
+	budget        *ConversationBudget
+	loopDetection LoopDetection
+
+	streamIdleTimeout    time.Duration
+	serverLimits         ServerLimits
+	adapterDebug         bool
+	adapterDiff          bool
+	dryRun               bool
+	repairToolArguments  bool
+	strictToolRetries    int
+	developerPlacement   string
+	inlineSystemMessages bool
+	promptCacheSessions  int
+	reasoningBudgets     anthropicclaude.ReasoningBudgets
+	reasoningContent     *anthropicclaude.ReasoningContent
+	ttftTrailer          bool
+	modelRefresh         time.Duration
+	recorder             *record.Recorder
+	capture              *capture.Store
+	middlewares          []func(http.Handler) http.Handler
+	disableOpenAI        bool
+	disableNative        bool
+	surfaces             []surfaceListener
+	websocketOrigins     []string
+}
+
+// ServerLimits holds timeouts and limits of the inbound HTTP server.
+// Zero or negative timeouts disable the respective timeout, except IdleTimeout.
+type ServerLimits struct {
+	// ReadTimeout bounds reading an entire client request (protects against slow clients).
+	ReadTimeout time.Duration
+
+	// WriteTimeout bounds writing an entire response, including long SSE streams.
+	WriteTimeout time.Duration
+
+	// IdleTimeout is the keep-alive wait for the next request from a client.
+	// Zero falls back to ReadTimeout, as in net/http; negative disables it.
+	IdleTimeout time.Duration
+
+	// MaxHeaderBytes limits request header size (0 = http.DefaultMaxHeaderBytes).
+	MaxHeaderBytes int
+
+	// MaxConnections limits concurrently accepted connections (0 = unlimited).
+	MaxConnections int
+}
+
+// DefaultServerLimits returns the server limits used unless WithServerLimits is given.
+func DefaultServerLimits() ServerLimits {
+	return ServerLimits{
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 15 * time.Minute,
+		IdleTimeout:  90 * time.Second,
+	}
 }
 
 // Option configures the proxy
@@ -61,6 +186,297 @@ func WithBaseURL(baseURL string) Option {
 	}
 }
 
+// WithPlugins adds external plugins (processes or WebAssembly modules) as request/response filters
+// on the Messages and chat completions routes. Plugins run in the given order.
+func WithPlugins(plugins ...plugin.Filter) Option {
+	return func(c *config) {
+		c.plugins = append(c.plugins, plugins...)
+	}
+}
+
+// WithUsageSinks registers consumers of request completion events (model, token usage,
+// status, latency) for the Messages and chat completions routes.
+func WithUsageSinks(sinks ...usage.Sink) Option {
+	return func(c *config) {
+		c.sinks = append(c.sinks, sinks...)
+	}
+}
+
+// WithShadow mirrors a sample of Messages requests (including translated chat completions)
+// to a second model or upstream. Shadow responses are never returned to clients.
+func WithShadow(m *shadow.Mirror) Option {
+	return func(c *config) {
+		c.shadow = m
+	}
+}
+
+// WithRouter enables A/B model routing on the Messages and chat completions routes.
+func WithRouter(r *routing.Router) Option {
+	return func(c *config) {
+		c.router = r
+	}
+}
+
+// WithPolicies enforces per-key model and token policies on the Messages and chat completions routes.
+func WithPolicies(e *policy.Enforcer) Option {
+	return func(c *config) {
+		c.policies = e
+	}
+}
+
+// WithAuditLog records requests rejected by policies to the audit log.
+func WithAuditLog(l *audit.Log) Option {
+	return func(c *config) {
+		c.audit = l
+	}
+}
+
+// WithRequestSigning records an HMAC-SHA256 signed digest of every request sent
+// upstream to the audit log set by WithAuditLog, keyed by request ID. A nil key
+// disables signing.
+func WithRequestSigning(key []byte) Option {
+	return func(c *config) {
+		c.signingKey = key
+	}
+}
+
+// auditedReject wraps reject to record rejections to l.
+func auditedReject(l *audit.Log, reject func(w http.ResponseWriter, r *http.Request, status int, message string)) func(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if l == nil {
+		return reject
+	}
+	return func(w http.ResponseWriter, r *http.Request, status int, message string) {
+		l.Record(r.Context(), audit.Event{
+			Action: audit.ActionPolicyReject,
+			Actor:  usage.KeyID(r),
+			Target: r.URL.Path,
+			Detail: message,
+		})
+		reject(w, r, status, message)
+	}
+}
+
+// WithCapabilities rejects Messages and chat completions requests asking a model for
+// capabilities it lacks, such as thinking or more output tokens than it supports.
+func WithCapabilities(r *capability.Registry) Option {
+	return func(c *config) {
+		c.capabilities = r
+	}
+}
+
+// WithMetrics exposes the registry at GET /metrics in the Prometheus text format.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(c *config) {
+		c.metrics = reg
+	}
+}
+
+// WithErrorMetrics counts OpenAI adapter transformation failures in collector.
+func WithErrorMetrics(collector *metrics.ErrorCollector) Option {
+	return func(c *config) {
+		c.errors = collector
+	}
+}
+
+// WithCache serves identical non-streaming Messages and chat completion requests
+// from store for ttl instead of calling the upstream.
+func WithCache(store cache.Store, ttl time.Duration) Option {
+	return func(c *config) {
+		c.cache = store
+		c.cacheTTL = ttl
+	}
+}
+
+// WithPacing smooths upstream traffic to stay below the account's per-minute limits.
+func WithPacing(cfg pacing.Config) Option {
+	return func(c *config) {
+		c.pacing = &cfg
+	}
+}
+
+// WithPacingMetrics records how long pacing delays upstream requests, by priority.
+func WithPacingMetrics(collector *metrics.PacingCollector) Option {
+	return func(c *config) {
+		c.pacingMetrics = collector
+	}
+}
+
+// WithRateLimitQueue holds non-streaming requests while the upstream is rate limited
+// and retries them once the limit window resets.
+func WithRateLimitQueue(cfg pacing.QueueConfig) Option {
+	return func(c *config) {
+		c.queue = &cfg
+	}
+}
+
+// WithUserIDPrivacy hashes or drops end-user identifiers (metadata.user_id, OpenAI
+// user/safety_identifier) before requests leave the proxy. salt keys the hash.
+func WithUserIDPrivacy(mode UserIDMode, salt string) Option {
+	return func(c *config) {
+		c.userID = mode
+		c.userSalt = salt
+	}
+}
+
+// WithAnthropicVersions sets the Anthropic-Version header sent upstream, by
+// upstream path. Clients' own versions are kept for client and fallback API keys.
+func WithAnthropicVersions(versions AnthropicVersions) Option {
+	return func(c *config) {
+		c.anthropicVersions = &versions
+	}
+}
+
+// WithPassthrough forwards additional Anthropic API paths (e.g., files, skills, admin
+// usage) through the OAuth transport. Patterns are request paths; a trailing "/*"
+// matches the path and everything below it. Bodies are forwarded without system
+// prompt injection.
+func WithPassthrough(patterns ...string) Option {
+	return func(c *config) {
+		c.passthrough = append(c.passthrough, patterns...)
+	}
+}
+
+// WithAdapterDebug logs the Anthropic requests translated from OpenAI chat
+// completions, with conversation content hashed, and summaries of their responses.
+func WithAdapterDebug(enabled bool) Option {
+	return func(c *config) {
+		c.adapterDebug = enabled
+	}
+}
+
+// WithAdapterDiff serves POST /v1/chat/completions/diff, which answers with the
+// Anthropic request a chat completion request is translated to next to the
+// original, conversation content hashed, without sending it upstream.
+func WithAdapterDiff(enabled bool) Option {
+	return func(c *config) {
+		c.adapterDiff = enabled
+	}
+}
+
+// WithDryRun answers every chat completion request with the Anthropic request it
+// would be sent as, as requests setting the X-Claudine-Dry-Run header are, e.g.
+// for CI suites validating an integration without spending tokens.
+func WithDryRun(enabled bool) Option {
+	return func(c *config) {
+		c.dryRun = enabled
+	}
+}
+
+// WithToolArgumentRepair completes streamed tool call arguments that were cut off
+// mid-JSON, and reports arguments that can't be repaired with finish_reason "length".
+func WithToolArgumentRepair(enabled bool) Option {
+	return func(c *config) {
+		c.repairToolArguments = enabled
+	}
+}
+
+// WithStrictToolRetries repeats non-streaming chat completions up to retries times
+// when the model's tool call arguments don't match the schema of a strict tool.
+func WithStrictToolRetries(retries int) Option {
+	return func(c *config) {
+		c.strictToolRetries = retries
+	}
+}
+
+// WithDeveloperPlacement orders developer messages relative to system messages in the
+// Anthropic system prompt: "before", "after", or "" to keep their order.
+func WithDeveloperPlacement(placement string) Option {
+	return func(c *config) {
+		c.developerPlacement = placement
+	}
+}
+
+// WithInlineSystemMessages keeps system and developer messages sent after the first
+// user or assistant turn in place, as tagged user text, instead of hoisting them
+// into the system prompt.
+func WithInlineSystemMessages(enabled bool) Option {
+	return func(c *config) {
+		c.inlineSystemMessages = enabled
+	}
+}
+
+// WithPromptCaching shapes chat completions carrying a prompt_cache_key for
+// Anthropic's prompt cache, remembering the block order of up to sessions
+// conversations. Zero disables it.
+func WithPromptCaching(sessions int) Option {
+	return func(c *config) {
+		c.promptCacheSessions = sessions
+	}
+}
+
+// WithReasoningBudgets overrides the thinking budgets chat completions request for
+// reasoning_effort, per model. The first budget matching a model applies.
+func WithReasoningBudgets(budgets ...anthropicclaude.ReasoningBudget) Option {
+	return func(c *config) {
+		c.reasoningBudgets = budgets
+	}
+}
+
+// WithReasoningContent streams extended thinking of chat completions as
+// delta.reasoning_content, raw or summarized. Nil drops thinking.
+func WithReasoningContent(rc *anthropicclaude.ReasoningContent) Option {
+	return func(c *config) {
+		c.reasoningContent = rc
+	}
+}
+
+// WithTTFTTrailer ends streamed responses with an X-Claudine-Ttft trailer holding
+// the time to first token in milliseconds. Requires usage sinks.
+func WithTTFTTrailer(enabled bool) Option {
+	return func(c *config) {
+		c.ttftTrailer = enabled
+	}
+}
+
+// WithRecorder records OpenAI chat completions as adapter test fixtures.
+func WithRecorder(r *record.Recorder) Option {
+	return func(c *config) {
+		c.recorder = r
+	}
+}
+
+// WithCapture stores Messages and chat completions requests by request ID for
+// replaying them later. A nil Store disables capturing.
+func WithCapture(s *capture.Store) Option {
+	return func(c *config) {
+		c.capture = s
+	}
+}
+
+// WithMiddleware adds middlewares to every API route, e.g. for custom
+// authentication or metrics. They run in order after logging, panic recovery and
+// request ID handling, so they see the request ID and their panics are recovered,
+// and before the route's own processing. Health and metrics endpoints are not wrapped.
+func WithMiddleware(middlewares ...func(http.Handler) http.Handler) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithOpenAIRoutes enables the OpenAI compatibility layer: chat completions
+// (including the Azure URL scheme) and the unsupported or forwarded OpenAI
+// endpoints. Enabled by default.
+func WithOpenAIRoutes(enabled bool) Option {
+	return func(c *config) {
+		c.disableOpenAI = !enabled
+	}
+}
+
+// WithNativeRoutes enables the Anthropic Messages API route. Enabled by default.
+// Files and models are shared by both surfaces and stay available.
+func WithNativeRoutes(enabled bool) Option {
+	return func(c *config) {
+		c.disableNative = !enabled
+	}
+}
+
+// WithServerLimits overrides the inbound server's timeouts and connection limits.
+func WithServerLimits(limits ServerLimits) Option {
+	return func(c *config) {
+		c.serverLimits = limits
+	}
+}
+
 // DefaultTransport returns a new http.Transport configured for API requirements.
 // Clones http.DefaultTransport and adds ResponseHeaderTimeout to prevent indefinite hangs.
 // Returns a fresh instance on each call to prevent accidental mutation.
@@ -76,11 +492,72 @@ func DefaultTransport() *http.Transport {
 	return t
 }
 
+// TransportSettings tunes connection pooling and timeouts of upstream requests,
+// e.g. for high-concurrency deployments. Zero values keep DefaultTransport's
+// settings; negative timeouts disable the respective timeout.
+type TransportSettings struct {
+	// MaxIdleConnsPerHost keeps this many idle connections for reuse (Go's default is 2).
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits connections including those in use (0 = unlimited).
+	MaxConnsPerHost int
+
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// DisableHTTP2 restricts upstream connections to HTTP/1.1.
+	DisableHTTP2 bool
+
+	// DNSCacheTTL caches resolved upstream addresses for this long (0 = resolve every
+	// new connection). Stale addresses are used while resolving fails.
+	DNSCacheTTL time.Duration
+
+	// PinnedAddresses dials hosts at fixed addresses instead of resolving them,
+	// trying them in order, e.g. for egress policies allowing only known IPs.
+	PinnedAddresses map[string][]netip.Addr
+}
+
+// NewTransport returns DefaultTransport tuned with s.
+func NewTransport(s TransportSettings) *http.Transport {
+	t := DefaultTransport()
+	if s.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+		t.MaxIdleConns = max(t.MaxIdleConns, s.MaxIdleConnsPerHost)
+	}
+	if s.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = s.MaxConnsPerHost
+	}
+	if s.TLSHandshakeTimeout != 0 {
+		t.TLSHandshakeTimeout = max(s.TLSHandshakeTimeout, 0)
+	}
+	if s.ResponseHeaderTimeout != 0 {
+		t.ResponseHeaderTimeout = max(s.ResponseHeaderTimeout, 0)
+	}
+	if s.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+	}
+	if s.DNSCacheTTL > 0 || len(s.PinnedAddresses) > 0 {
+		d := &resolvingDialer{
+			// Same as http.DefaultTransport's dialer
+			dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+			pinned: s.PinnedAddresses,
+		}
+		if s.DNSCacheTTL > 0 {
+			d.cache = newDNSCache(s.DNSCacheTTL)
+		}
+		t.DialContext = d.DialContext
+	}
+	return t
+}
+
 // New creates a forward proxy configured for Anthropic API.
 func New(ts oauth2.TokenSource, health ReadinessChecker, opts ...Option) (*Proxy, error) {
 	cfg := &config{
-		baseURL:   defaultBaseURL,
-		transport: DefaultTransport(),
+		baseURL:      defaultBaseURL,
+		transport:    DefaultTransport(),
+		serverLimits: DefaultServerLimits(),
 	}
 
 	for _, opt := range opts {
@@ -92,14 +569,146 @@ func New(ts oauth2.TokenSource, health ReadinessChecker, opts ...Option) (*Proxy
 		return nil, fmt.Errorf("invalid upstream URL: %w", err)
 	}
 
+	// Innermost transport of all upstream requests
+	upstreamBase := cfg.transport
+	if cfg.connMetrics != nil {
+		upstreamBase = &ConnectionTraceTransport{Base: upstreamBase, Metrics: cfg.connMetrics}
+	}
+	if cfg.compressUpstream {
+		upstreamBase = &CompressionTransport{Base: upstreamBase}
+	}
+	if cfg.signingKey != nil && cfg.audit != nil {
+		upstreamBase = &IntegrityTransport{Base: upstreamBase, Key: cfg.signingKey, Audit: cfg.audit}
+	}
+	endpoints, err := newEndpoints(upstream, cfg.endpoints, cfg.endpointProbeInterval, cfg.transport)
+	if err != nil {
+		return nil, err
+	}
+	if endpoints != nil {
+		upstreamBase = &EndpointTransport{Base: upstreamBase, Endpoints: endpoints}
+	}
+	base := &RequestIDTransport{Base: upstreamBase}
+
 	// Compose transport chain (request execution order):
-	// oauth2.Transport → ImpersonationTransport → cfg.transport
-	transport := &oauth2.Transport{
-		Source: ts,
-		Base: &ImpersonationTransport{
-			Base: cfg.transport,
+	// usage.Transport → streamCounter → [attemptScope] → [StreamIdleTransport] → [shadow] → [pacing] → [queue] → ClientKeyTransport
+	//   → [TenantTransport → per-tenant oauth2.Transport with [pacing]]
+	//   → [PoolTransport → default account or pooled tenant's transport]
+	//   → [QuotaFallbackTransport] → rateLimitRecorder → oauth2.Transport → UserIDTransport → ImpersonationTransport → RequestIDTransport → [EndpointTransport] → [IntegrityTransport] → [CompressionTransport] → [ConnectionTraceTransport] → cfg.transport
+	//   → UserIDTransport → RequestIDTransport → … (client or fallback API keys)
+	rateLimits := &rateLimitRecorder{
+		Base: &oauth2.Transport{
+			Source: ts,
+			Base: &UserIDTransport{
+				Mode: cfg.userID,
+				Salt: cfg.userSalt,
+				Base: &ImpersonationTransport{
+					Base:     base,
+					Profile:  cfg.impersonation,
+					Tolerant: cfg.tolerantInjection,
+					Spool:    cfg.spool,
+					Versions: cfg.anthropicVersions,
+				},
+			},
 		},
 	}
+	direct := &UserIDTransport{
+		Mode: cfg.userID,
+		Salt: cfg.userSalt,
+		Base: base,
+	}
+	// Failover replays request bodies, large ones from temporary files
+	rewind := cfg.spool
+	if rewind == nil {
+		rewind = &BodySpool{MemoryLimit: defaultRewindMemory}
+	}
+	var subscription http.RoundTripper = rateLimits
+	if cfg.fallbackAPIKey != "" {
+		subscription = &QuotaFallbackTransport{
+			Primary:  rateLimits,
+			Fallback: direct,
+			APIKey:   cfg.fallbackAPIKey,
+			Versions: cfg.anthropicVersions,
+			Spool:    rewind,
+		}
+	}
+	tenants, err := newTenantIndex(cfg.tenants)
+	if err != nil {
+		return nil, err
+	}
+	pooled := tenants != nil && len(tenants.pooled) > 0
+	if cfg.budget != nil {
+		// Conversations are charged once their requests complete
+		cfg.sinks = append(cfg.sinks, cfg.budget)
+	}
+	loops := newLoopDetector(cfg.loopDetection, cfg.audit)
+	namedConversations := cfg.budget != nil || loops != nil
+	if tenants != nil {
+		tenantTransport := &TenantTransport{
+			Default: subscription,
+			Tenants: tenantTransports(tenants, func(t *Tenant) http.RoundTripper {
+				var rt http.RoundTripper = &oauth2.Transport{
+					Source: t.TokenSource,
+					Base: &UserIDTransport{
+						Mode: cfg.userID,
+						Salt: cfg.userSalt,
+						Base: &ImpersonationTransport{
+							Base:     base,
+							Profile:  cfg.impersonation,
+							Tolerant: cfg.tolerantInjection,
+							Spool:    cfg.spool,
+							Versions: cfg.anthropicVersions,
+						},
+					},
+				}
+				if t.Pacing != nil {
+					paced := pacing.New(rt, *t.Pacing)
+					paced.Metrics = cfg.pacingMetrics
+					rt = paced
+				}
+				return rt
+			}),
+		}
+		if len(tenants.pooled) > 0 {
+			pool := &PoolTransport{Accounts: []*PoolAccount{{Name: "default", Transport: subscription}}, Spool: rewind}
+			for _, t := range tenants.pooled {
+				pool.Accounts = append(pool.Accounts, &PoolAccount{Name: t.Name, Transport: tenantTransport.Tenants[t]})
+			}
+			tenantTransport.Default = pool
+		}
+		subscription = tenantTransport
+	}
+	var upstreamTransport http.RoundTripper = &ClientKeyTransport{
+		OAuth:    subscription,
+		Direct:   direct,
+		Versions: cfg.anthropicVersions,
+	}
+	if cfg.queue != nil {
+		upstreamTransport = pacing.NewQueue(upstreamTransport, *cfg.queue)
+	}
+	if cfg.pacing != nil {
+		paced := pacing.New(upstreamTransport, *cfg.pacing)
+		paced.Metrics = cfg.pacingMetrics
+		upstreamTransport = paced
+	}
+	if cfg.shadow != nil {
+		upstreamTransport = cfg.shadow.Transport(upstreamTransport, upstream)
+	}
+	if cfg.streamIdleTimeout > 0 {
+		upstreamTransport = &StreamIdleTransport{Base: upstreamTransport, Timeout: cfg.streamIdleTimeout}
+	}
+	if cfg.connMetrics != nil {
+		upstreamTransport = &attemptScope{Base: upstreamTransport}
+	}
+	streams := &streamCounter{Base: upstreamTransport}
+	if cfg.streamObservers {
+		if cfg.adminToken == "" {
+			return nil, errors.New("stream observers require an admin token")
+		}
+		streams.Hub = newStreamHub()
+	}
+	transport := &usage.Transport{
+		Base: streams,
+	}
 
 	// Build reverse proxy for Anthropic API
 	reverseProxyHandler := &httputil.ReverseProxy{
@@ -107,6 +716,7 @@ func New(ts oauth2.TokenSource, health ReadinessChecker, opts ...Option) (*Proxy
 			pr.Out.URL.Scheme = upstream.Scheme
 			pr.Out.URL.Host = upstream.Host
 			pr.Out.Host = upstream.Host
+			pr.Out.Header.Del(usage.HeaderTag)
 		},
 		// FlushInterval: -1 disables automatic periodic flushing, flushing only when the backend flushes.
 		// This eliminates buffering delays, critical for streaming responses (SSE) where clients
@@ -114,51 +724,398 @@ func New(ts oauth2.TokenSource, health ReadinessChecker, opts ...Option) (*Proxy
 		FlushInterval: -1,
 		Transport:     transport,
 	}
+	switch filter := filterStream(cfg.streamFilter); {
+	case cfg.streamFilter.enabled() && cfg.errorRewrite:
+		reverseProxyHandler.ModifyResponse = func(resp *http.Response) error {
+			if err := rewriteErrors(resp); err != nil {
+				return err
+			}
+			return filter(resp)
+		}
+	case cfg.streamFilter.enabled():
+		reverseProxyHandler.ModifyResponse = filter
+	case cfg.errorRewrite:
+		reverseProxyHandler.ModifyResponse = rewriteErrors
+	}
+
+	// Endpoints other than Messages (files, passthrough) get authentication and required
+	// headers only; their bodies are not Messages requests
+	var nativeSubscription http.RoundTripper = &oauth2.Transport{
+		Source: ts,
+		Base: &ImpersonationTransport{
+			Base:        base,
+			HeadersOnly: true,
+			Profile:     cfg.impersonation,
+			Tolerant:    cfg.tolerantInjection,
+			Versions:    cfg.anthropicVersions,
+		},
+	}
+	if tenants != nil {
+		nativeSubscription = &TenantTransport{
+			Default: nativeSubscription,
+			Tenants: tenantTransports(tenants, func(t *Tenant) http.RoundTripper {
+				return &oauth2.Transport{
+					Source: t.TokenSource,
+					Base: &ImpersonationTransport{
+						Base:        base,
+						HeadersOnly: true,
+						Profile:     cfg.impersonation,
+						Tolerant:    cfg.tolerantInjection,
+						Versions:    cfg.anthropicVersions,
+					},
+				}
+			}),
+		}
+	}
+	var nativeTransport http.RoundTripper = &ClientKeyTransport{
+		OAuth:    nativeSubscription,
+		Direct:   base,
+		Versions: cfg.anthropicVersions,
+	}
+	if cfg.connMetrics != nil {
+		nativeTransport = &attemptScope{Base: nativeTransport}
+	}
+	nativeProxy := &httputil.ReverseProxy{
+		Rewrite:       reverseProxyHandler.Rewrite,
+		FlushInterval: -1,
+		Transport:     nativeTransport,
+	}
 
 	// OpenAI SDK compatibility handler
+	chatCompletionAdapter := anthropicclaude.NewCreateChatCompletionAdapter()
+	chatCompletionAdapter.Debug = cfg.adapterDebug
+	chatCompletionAdapter.RepairToolArguments = cfg.repairToolArguments
+	chatCompletionAdapter.StrictRetries = cfg.strictToolRetries
+	chatCompletionAdapter.DeveloperPlacement = anthropicclaude.DeveloperPlacement(cfg.developerPlacement)
+	chatCompletionAdapter.InlineSystemMessages = cfg.inlineSystemMessages
+	chatCompletionAdapter.ReasoningBudgets = cfg.reasoningBudgets
+	chatCompletionAdapter.ReasoningContent = cfg.reasoningContent
+	if cfg.promptCacheSessions > 0 {
+		chatCompletionAdapter.PromptCache = anthropicclaude.NewPromptCache(cfg.promptCacheSessions)
+	}
+	var chatCompletionTransport http.RoundTripper = &upstreamHostTransport{Base: transport, Upstream: upstream}
+	if cfg.recorder != nil {
+		chatCompletionTransport = &record.Transport{Base: chatCompletionTransport}
+	}
 	createChatCompletionsHandler := &CreateChatCompletionsHandler{
-		Adapter:   anthropicclaude.NewCreateChatCompletionAdapter(),
-		Transport: transport,
+		Adapter:   chatCompletionAdapter,
+		Transport: chatCompletionTransport,
+		Errors:    cfg.errors,
+		DryRun:    cfg.dryRun,
 	}
 
 	logger := slog.Default()
+	custom := chain(cfg.middlewares)
 
+	// Surfaces served on their own listener get their own mux; the others share the main one
 	mux := http.NewServeMux()
+	nativeMux, openaiMux := mux, mux
+	surfaces := make([]*surfaceServer, 0, len(cfg.surfaces))
+	for _, sl := range cfg.surfaces {
+		surfaceMux := http.NewServeMux()
+		switch {
+		case sl.surface == SurfaceNative && nativeMux == mux && !cfg.disableNative:
+			nativeMux = surfaceMux
+		case sl.surface == SurfaceOpenAI && openaiMux == mux && !cfg.disableOpenAI:
+			openaiMux = surfaceMux
+		default:
+			return nil, fmt.Errorf("invalid listener for surface %q: unknown, disabled or configured twice", sl.surface)
+		}
+		surfaces = append(surfaces, &surfaceServer{
+			surface: sl.surface,
+			address: sl.address,
+			mux:     surfaceMux,
+			handler: applyMiddlewares(surfaceMux, sl.middlewares...),
+		})
+	}
 
-	// Forward proxy to Anthropic Messages API
-	mux.Handle("POST "+upstream.Path+"/messages", applyMiddlewares(reverseProxyHandler,
-		middleware.Logging(logger),
-		Recovery,
-		middleware.TraceContextExtraction,
-		middleware.RequestIDGeneration,
-		RequestSizeLimit(33<<20), // Anthropic enforces 32MB
-		middleware.RequestIDPropagation,
-	))
+	if !cfg.disableNative {
+		// Forward proxy to Anthropic Messages API
+		nativeMux.Handle("POST "+upstream.Path+"/messages", applyMiddlewares(reverseProxyHandler,
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			DecodeRequest(writeAnthropicErrorStatus),
+			RequestSizeLimit(33<<20), // Anthropic enforces 32MB
+			middleware.RequestIDPropagation,
+			requireFreshness(cfg.maxRequestAge, cfg.timestampHeader, writeAnthropicErrorStatus),
+			capture.Middleware(cfg.capture),
+			validateMessages(cfg.validateMessages),
+			custom,
+			selectTenant(tenants),
+			identifyConversation(pooled, namedConversations),
+			captureClientKey(cfg.clientKeys),
+			reportCredential(cfg.clientKeys || cfg.fallbackAPIKey != ""),
+			usage.Track(cfg.sinks),
+			usage.TTFTTrailer(cfg.ttftTrailer),
+			policy.Middleware(cfg.policies, policy.Anthropic, auditedReject(cfg.audit, writeAnthropicErrorStatus)),
+			cfg.budget.middleware(auditedReject(cfg.audit, writeAnthropicErrorStatus)),
+			loops.middleware(writeAnthropicErrorStatus),
+			routing.Middleware(cfg.router),
+			capability.Middleware(cfg.capabilities, capability.Anthropic, writeAnthropicErrorStatus),
+			plugin.Middleware(cfg.plugins, writeAnthropicErrorStatus),
+			cache.Middleware(cfg.cache, cfg.cacheTTL, tenantName),
+		))
+	}
 
-	// OpenAI SDK compatibility layer
-	mux.Handle("POST "+upstream.Path+"/chat/completions", applyMiddlewares(createChatCompletionsHandler,
-		middleware.Logging(logger),
-		Recovery,
-		middleware.TraceContextExtraction,
-		middleware.RequestIDGeneration,
-		RequestSizeLimit(31<<20), // proxy handles error
-		middleware.RequestIDPropagation,
-	))
+	if !cfg.disableOpenAI {
+		// OpenAI SDK compatibility layer
+		chatCompletions := applyMiddlewares(createChatCompletionsHandler,
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			DecodeRequest(writeOpenAIErrorStatus),
+			RequestSizeLimit(31<<20), // proxy handles error
+			middleware.RequestIDPropagation,
+			requireFreshness(cfg.maxRequestAge, cfg.timestampHeader, writeOpenAIErrorStatus),
+			capture.Middleware(cfg.capture),
+			NDJSON,
+			custom,
+			selectTenant(tenants),
+			identifyConversation(pooled, namedConversations),
+			captureClientKey(cfg.clientKeys),
+			reportCredential(cfg.clientKeys || cfg.fallbackAPIKey != ""),
+			usage.Track(cfg.sinks),
+			usage.TTFTTrailer(cfg.ttftTrailer),
+			policy.Middleware(cfg.policies, policy.OpenAI, auditedReject(cfg.audit, writeOpenAIErrorStatus)),
+			cfg.budget.middleware(auditedReject(cfg.audit, writeOpenAIErrorStatus)),
+			loops.middleware(writeOpenAIErrorStatus),
+			routing.Middleware(cfg.router),
+			capability.Middleware(cfg.capabilities, capability.OpenAI, writeOpenAIErrorStatus),
+			plugin.Middleware(cfg.plugins, writeOpenAIErrorStatus),
+			createChatCompletionsHandler.dryRun,
+			cache.Middleware(cfg.cache, cfg.cacheTTL, tenantName),
+			record.Middleware(cfg.recorder),
+		)
+		openaiMux.Handle("POST "+upstream.Path+"/chat/completions", chatCompletions)
+
+		if cfg.adapterDiff {
+			// Translation preview for integrators; never reaches the upstream
+			openaiMux.Handle("POST "+upstream.Path+"/chat/completions/diff", applyMiddlewares(http.HandlerFunc(createChatCompletionsHandler.ServeDiff),
+				middleware.Logging(logger),
+				Recovery,
+				middleware.TraceContextExtraction,
+				middleware.RequestIDGeneration,
+				DecodeRequest(writeOpenAIErrorStatus),
+				RequestSizeLimit(31<<20), // proxy handles error
+				middleware.RequestIDPropagation,
+				requireFreshness(cfg.maxRequestAge, cfg.timestampHeader, writeOpenAIErrorStatus),
+				NDJSON,
+				custom,
+			))
+		}
+
+		// WebSocket alternative to SSE; each message goes through the POST route
+		openaiMux.Handle("GET "+upstream.Path+"/chat/completions", applyMiddlewares(websocketHandler(chatCompletions, upstream.Path+"/chat/completions", cfg.websocketOrigins),
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			middleware.RequestIDPropagation,
+			requireFreshness(cfg.maxRequestAge, cfg.timestampHeader, writeOpenAIErrorStatus),
+			custom,
+		))
+
+		// Azure OpenAI URL scheme: model taken from the deployment name
+		openaiMux.Handle("POST /openai/deployments/{deployment}/chat/completions", applyMiddlewares(createChatCompletionsHandler,
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			DecodeRequest(writeOpenAIErrorStatus),
+			RequestSizeLimit(31<<20), // proxy handles error
+			middleware.RequestIDPropagation,
+			requireFreshness(cfg.maxRequestAge, cfg.timestampHeader, writeOpenAIErrorStatus),
+			capture.Middleware(cfg.capture),
+			NDJSON,
+			custom,
+			captureClientKey(cfg.clientKeys),
+			reportCredential(cfg.clientKeys || cfg.fallbackAPIKey != ""),
+			azureDeployment(cfg.azureDeployments),
+			selectTenant(tenants),
+			identifyConversation(pooled, namedConversations),
+			usage.Track(cfg.sinks),
+			usage.TTFTTrailer(cfg.ttftTrailer),
+			policy.Middleware(cfg.policies, policy.OpenAI, auditedReject(cfg.audit, writeOpenAIErrorStatus)),
+			cfg.budget.middleware(auditedReject(cfg.audit, writeOpenAIErrorStatus)),
+			loops.middleware(writeOpenAIErrorStatus),
+			routing.Middleware(cfg.router),
+			capability.Middleware(cfg.capabilities, capability.OpenAI, writeOpenAIErrorStatus),
+			plugin.Middleware(cfg.plugins, writeOpenAIErrorStatus),
+			createChatCompletionsHandler.dryRun,
+			cache.Middleware(cfg.cache, cfg.cacheTTL, tenantName),
+			record.Middleware(cfg.recorder),
+		))
+
+		// Description of the compatibility surface for client generators
+		openAPI, err := openAPIHandler(openAPIDocument(upstream.Path, cfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build OpenAPI description: %w", err)
+		}
+		openaiMux.Handle("GET /openapi.json", applyMiddlewares(openAPI,
+			middleware.Logging(logger),
+			Recovery,
+		))
+	}
+
+	// OpenAI endpoints without Anthropic equivalent: forwarded if configured, 501 otherwise
+	fallbackRoutes, err := openAIFallbackRoutes(upstream.Path, cfg.forwards)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.disableOpenAI {
+		fallbackRoutes = nil
+	}
+	for pattern, handler := range fallbackRoutes {
+		openaiMux.Handle(pattern, applyMiddlewares(handler,
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			middleware.RequestIDPropagation,
+			custom,
+		))
+	}
 
-	// Shared static Models API endpoint for OpenAI and Anthropic
-	mux.Handle("GET "+upstream.Path+"/models", applyMiddlewares(modelsHandler(),
+	// Files API shared by OpenAI (translated) and Anthropic (forwarded) clients
+	filesHandler := &FilesHandler{
+		Adapter:   anthropicclaude.NewFilesAdapter(),
+		Transport: &upstreamHostTransport{Base: nativeTransport, Upstream: upstream},
+		Native:    nativeProxy,
+	}
+	filesMiddlewares := []func(http.Handler) http.Handler{
 		middleware.Logging(logger),
 		Recovery,
 		middleware.TraceContextExtraction,
 		middleware.RequestIDGeneration,
+		RequestSizeLimit(500 << 20), // Anthropic enforces 500MB per file
 		middleware.RequestIDPropagation,
-	))
+		custom,
+		selectTenant(tenants),
+		captureClientKey(cfg.clientKeys),
+	}
+
+	// Account info of the OAuth subscription, including the latest upstream rate limit state
+	account := &accountInfo{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &oauth2.Transport{
+				Source: ts,
+				Base:   &ImpersonationTransport{Base: base, Profile: cfg.impersonation, Versions: cfg.anthropicVersions},
+			},
+		},
+		profileURL: (&url.URL{Scheme: upstream.Scheme, Host: upstream.Host, Path: profilePath}).String(),
+		limits:     rateLimits,
+	}
+
+	// Model list, refreshed from the upstream if enabled
+	var modelsClient *http.Client
+	if cfg.modelRefresh > 0 {
+		modelsClient = &http.Client{Timeout: modelsRefreshTimeout, Transport: nativeTransport}
+	}
+	models := newModelCatalog(modelsClient, upstream.String()+"/models", cfg.fallbackAPIKey, cfg.modelRefresh)
+
+	// Routes of both surfaces, served on every listener
+	sharedMuxes := []*http.ServeMux{mux}
+	for _, s := range surfaces {
+		sharedMuxes = append(sharedMuxes, s.mux)
+	}
+	for _, mux := range sharedMuxes {
+		mux.Handle("POST "+upstream.Path+"/files", applyMiddlewares(http.HandlerFunc(filesHandler.Upload), filesMiddlewares...))
+		mux.Handle("GET "+upstream.Path+"/files", applyMiddlewares(http.HandlerFunc(filesHandler.List), filesMiddlewares...))
+		mux.Handle("GET "+upstream.Path+"/files/{file_id}", applyMiddlewares(http.HandlerFunc(filesHandler.Retrieve), filesMiddlewares...))
+		mux.Handle("GET "+upstream.Path+"/files/{file_id}/content", applyMiddlewares(http.HandlerFunc(filesHandler.Content), filesMiddlewares...))
+		mux.Handle("DELETE "+upstream.Path+"/files/{file_id}", applyMiddlewares(http.HandlerFunc(filesHandler.Delete), filesMiddlewares...))
+
+		// Shared Models API endpoint for OpenAI and Anthropic
+		mux.Handle("GET "+upstream.Path+"/models", applyMiddlewares(modelsHandler(models),
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			middleware.RequestIDPropagation,
+			custom,
+		))
+
+		mux.Handle("GET "+upstream.Path+"/me", applyMiddlewares(accountHandler(account),
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			middleware.RequestIDPropagation,
+			custom,
+		))
+
+		// Health check endpoints
+		mux.HandleFunc("GET /health/liveness", livenessHandler())
+		mux.HandleFunc("GET /health/readiness", readinessHandler(health))
+	}
+
+	// Opt-in passthrough for other Anthropic endpoints; more specific routes above take precedence
+	if len(cfg.passthrough) > 0 {
+		nativeMux.Handle("/", applyMiddlewares(passthroughHandler(cfg.passthrough, nativeProxy),
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			DecodeRequest(writeAnthropicErrorStatus),
+			middleware.RequestIDPropagation,
+			custom,
+			selectTenant(tenants),
+			captureClientKey(cfg.clientKeys),
+		))
+	}
+
+	// Prometheus metrics
+	if cfg.metrics != nil {
+		mux.Handle("GET /metrics", cfg.metrics)
+	}
+
+	// Read-only dashboard for admins
+	if cfg.dashboard != nil {
+		if cfg.adminToken == "" {
+			return nil, errors.New("dashboard requires an admin token")
+		}
+		dashboardHandler := applyMiddlewares(dashboard.Handler(cfg.dashboard, dashboardStatus(streams, rateLimits)),
+			middleware.Logging(logger),
+			Recovery,
+			adminAuth(cfg.adminToken),
+		)
+		mux.Handle("GET /dashboard", dashboardHandler)
+		mux.Handle("GET /dashboard/", dashboardHandler)
+	}
 
-	// Health check endpoints
-	mux.HandleFunc("GET /health/liveness", livenessHandler())
-	mux.HandleFunc("GET /health/readiness", readinessHandler(health))
+	// Live view of streams in progress for admins
+	if streams.Hub != nil {
+		mux.Handle("GET /admin/streams/{request_id}", applyMiddlewares(observeStreamHandler(streams.Hub),
+			middleware.Logging(logger),
+			Recovery,
+			adminAuth(cfg.adminToken),
+		))
+	}
+
+	// Anthropic usage and cost reports for admins
+	if cfg.usageReports {
+		if cfg.adminToken == "" {
+			return nil, errors.New("usage reports require an admin token")
+		}
+		reportsHandler := applyMiddlewares(nativeProxy,
+			middleware.Logging(logger),
+			Recovery,
+			middleware.TraceContextExtraction,
+			middleware.RequestIDGeneration,
+			middleware.RequestIDPropagation,
+			adminAuth(cfg.adminToken),
+			usageReportCredentials(cfg.adminAPIKey),
+		)
+		for _, path := range usageReportPaths {
+			mux.Handle("GET "+upstream.Path+path, reportsHandler)
+		}
+	}
 
-	return &Proxy{mux: mux}, nil
+	return &Proxy{mux: mux, surfaces: surfaces, pending: newPendingConns(), limits: cfg.serverLimits, streams: streams, endpoints: endpoints}, nil
 }
 
 // ServeHTTP implements http.Handler interface
@@ -166,7 +1123,8 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.mux.ServeHTTP(w, r)
 }
 
-// Start starts the HTTP server in the background and returns immediately.
+// Start starts the HTTP servers in the background and returns immediately: one on
+// address and one for each surface with its own listener.
 // Returns a channel for runtime errors and a startup error if any.
 //
 // Startup errors (port in use, permission denied) are returned immediately.
@@ -174,48 +1132,153 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 //
 // The caller is responsible for calling Shutdown() to stop the server.
 func (p *Proxy) Start(ctx context.Context, address string) (<-chan error, error) {
-	// Startup phase: Create listener synchronously to catch port-in-use errors immediately
-	listener, err := net.Listen("tcp", address)
-	if err != nil {
-		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	addresses := []string{address}
+	for _, s := range p.surfaces {
+		addresses = append(addresses, s.address)
 	}
 
-	p.server = &http.Server{
-		Handler:      p,
-		ReadTimeout:  30 * time.Second, // Inbound: Read entire client request (DoS protection against slow clients)
-		WriteTimeout: 15 * time.Minute, // Inbound: Write entire response to client (allows long SSE streams, still bounded)
-		IdleTimeout:  90 * time.Second, // Inbound: Keep-alive wait for next request from client
-		BaseContext: func(net.Listener) context.Context {
-			return ctx
-		},
+	// Startup phase: Create listeners synchronously to catch port-in-use errors immediately
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, addr := range addresses {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, listener)
 	}
+	return p.Serve(ctx, listeners...)
+}
 
-	errCh := make(chan error, 1)
+// Serve starts the HTTP servers on existing listeners, such as ones inherited from
+// a previous process, in the background. The first listener serves the main routes,
+// the others the surfaces with their own listener in the order they were
+// configured. See Start.
+func (p *Proxy) Serve(ctx context.Context, listeners ...net.Listener) (<-chan error, error) {
+	if len(listeners) != 1+len(p.surfaces) {
+		return nil, fmt.Errorf("got %d listeners, need %d (main and one per surface listener)", len(listeners), 1+len(p.surfaces))
+	}
+	p.listeners = listeners
 
-	go func() {
-		err := p.server.Serve(listener)
-		// Only report error if not from graceful shutdown
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			errCh <- err
+	handlers := []http.Handler{p}
+	for _, s := range p.surfaces {
+		handlers = append(handlers, s.handler)
+	}
+
+	errCh := make(chan error, len(listeners))
+	var wg sync.WaitGroup
+	for i, handler := range handlers {
+		listener := listeners[i]
+		if p.limits.MaxConnections > 0 {
+			listener = netutil.LimitListener(listener, p.limits.MaxConnections)
 		}
+
+		server := &http.Server{
+			Handler:        handler,
+			ReadTimeout:    p.limits.ReadTimeout,
+			WriteTimeout:   p.limits.WriteTimeout,
+			IdleTimeout:    p.limits.IdleTimeout,
+			MaxHeaderBytes: p.limits.MaxHeaderBytes,
+			ConnState:      p.pending.track,
+			BaseContext: func(net.Listener) context.Context {
+				// In-flight requests drain during Shutdown instead of ending with ctx
+				return context.WithoutCancel(ctx)
+			},
+		}
+		p.servers = append(p.servers, server)
+
+		wg.Go(func() {
+			err := server.Serve(listener)
+			// Only report error if not from graceful shutdown
+			if err != nil && !errors.Is(err, http.ErrServerClosed) && !(p.closing.Load() && errors.Is(err, net.ErrClosed)) {
+				errCh <- err
+			}
+		})
+	}
+
+	go func() {
+		wg.Wait()
 		close(errCh)
 	}()
 
+	if p.endpoints != nil {
+		go p.endpoints.run(ctx)
+	}
+
 	return errCh, nil
 }
 
-// Shutdown performs graceful shutdown of the HTTP server.
+// ListenerFiles returns duplicates of the listening sockets, in the order Serve
+// expects them, for passing to another process. The caller must close them.
+func (p *Proxy) ListenerFiles() ([]*os.File, error) {
+	files := make([]*os.File, 0, len(p.listeners))
+	for _, listener := range p.listeners {
+		filer, ok := listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			closeFiles(files)
+			return nil, fmt.Errorf("listener does not support file handover")
+		}
+		f, err := filer.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// Shutdown performs graceful shutdown of the HTTP servers.
 // Returns error if shutdown fails or times out.
 func (p *Proxy) Shutdown(ctx context.Context) error {
-	if p.server == nil {
+	if len(p.servers) == 0 {
 		return nil
 	}
 
-	if err := p.server.Shutdown(ctx); err != nil {
-		// Graceful shutdown failed - force close
-		_ = p.server.Close()
-		return fmt.Errorf("graceful shutdown failed: %w", err)
+	// Stop accepting before Shutdown, see pendingConns
+	p.closing.Store(true)
+	for _, listener := range p.listeners {
+		_ = listener.Close()
+	}
+	p.pending.wait(ctx, drainGrace)
+
+	errs := make([]error, len(p.servers))
+	var wg sync.WaitGroup
+	for i, server := range p.servers {
+		wg.Go(func() {
+			// Shutdown may close the listener again and report it
+			if err := server.Shutdown(ctx); err != nil && !errors.Is(err, net.ErrClosed) {
+				// Graceful shutdown failed - force close
+				_ = server.Close()
+				errs[i] = fmt.Errorf("graceful shutdown failed: %w", err)
+			}
+		})
 	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// upstreamHostTransport points requests built by the Anthropic SDK at the configured
+// upstream. The SDK otherwise targets its default host (or ANTHROPIC_BASE_URL).
+type upstreamHostTransport struct {
+	Base     http.RoundTripper
+	Upstream *url.URL
+}
 
-	return nil
+// RoundTrip implements http.RoundTripper interface.
+func (t *upstreamHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	newReq := req.Clone(req.Context())
+	newReq.URL.Scheme = t.Upstream.Scheme
+	newReq.URL.Host = t.Upstream.Host
+	newReq.Host = t.Upstream.Host
+	return t.Base.RoundTrip(newReq)
 }
@@ -0,0 +1,159 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestNewTransport(t *testing.T) {
+	defaults := DefaultTransport()
+
+	t.Run("zero settings keep defaults", func(t *testing.T) {
+		got := NewTransport(TransportSettings{})
+		if got.MaxIdleConnsPerHost != defaults.MaxIdleConnsPerHost || got.MaxConnsPerHost != defaults.MaxConnsPerHost ||
+			got.TLSHandshakeTimeout != defaults.TLSHandshakeTimeout || got.ResponseHeaderTimeout != defaults.ResponseHeaderTimeout ||
+			got.ForceAttemptHTTP2 != defaults.ForceAttemptHTTP2 || got.Protocols != nil {
+			t.Errorf("NewTransport({}) differs from DefaultTransport()")
+		}
+	})
+
+	t.Run("tuned", func(t *testing.T) {
+		got := NewTransport(TransportSettings{
+			MaxIdleConnsPerHost:   256,
+			MaxConnsPerHost:       512,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: -1,
+			DisableHTTP2:          true,
+		})
+		if got.MaxIdleConnsPerHost != 256 || got.MaxIdleConns < 256 {
+			t.Errorf("MaxIdleConnsPerHost = %d, MaxIdleConns = %d, want 256 and >= 256", got.MaxIdleConnsPerHost, got.MaxIdleConns)
+		}
+		if got.MaxConnsPerHost != 512 {
+			t.Errorf("MaxConnsPerHost = %d, want 512", got.MaxConnsPerHost)
+		}
+		if got.TLSHandshakeTimeout != 5*time.Second {
+			t.Errorf("TLSHandshakeTimeout = %v, want 5s", got.TLSHandshakeTimeout)
+		}
+		if got.ResponseHeaderTimeout != 0 {
+			t.Errorf("ResponseHeaderTimeout = %v, want disabled", got.ResponseHeaderTimeout)
+		}
+		if got.Protocols == nil || got.Protocols.HTTP2() || !got.Protocols.HTTP1() {
+			t.Errorf("Protocols = %v, want HTTP/1 only", got.Protocols)
+		}
+	})
+}
+
+// serveLimits serves a proxy with limits on a local listener and returns its address.
+func serveLimits(t *testing.T, limits ServerLimits) string {
+	t.Helper()
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithServerLimits(limits))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Serve(t.Context(), l); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Shutdown(t.Context()) })
+	return l.Addr().String()
+}
+
+// dial connects to addr, failing reads after a generous deadline.
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestServerLimits(t *testing.T) {
+	const liveness = "GET /health/liveness HTTP/1.1\r\nHost: localhost\r\n\r\n"
+
+	t.Run("slow header is cut off", func(t *testing.T) {
+		conn := dial(t, serveLimits(t, ServerLimits{ReadTimeout: 100 * time.Millisecond}))
+
+		// Never finish the header
+		if _, err := io.WriteString(conn, "GET /health/liveness HTTP/1.1\r\nHost: localhost\r\n"); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		_, err := io.ReadAll(conn)
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			t.Fatalf("connection still open after %v, want it closed by ReadTimeout", time.Since(start))
+		}
+	})
+
+	t.Run("oversized header is rejected", func(t *testing.T) {
+		conn := dial(t, serveLimits(t, ServerLimits{MaxHeaderBytes: 1 << 10}))
+
+		// net/http allows 4096 bytes on top of MaxHeaderBytes
+		req := "GET /health/liveness HTTP/1.1\r\nHost: localhost\r\nX-Padding: " + strings.Repeat("a", 8<<10) + "\r\n\r\n"
+		if _, err := io.WriteString(conn, req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusRequestHeaderFieldsTooLarge)
+		}
+	})
+
+	t.Run("connections are capped", func(t *testing.T) {
+		addr := serveLimits(t, ServerLimits{ReadTimeout: time.Minute, MaxConnections: 1})
+
+		first := dial(t, addr)
+		if _, err := io.WriteString(first, liveness); err != nil {
+			t.Fatal(err)
+		}
+		firstReader := bufio.NewReader(first)
+		resp, err := http.ReadResponse(firstReader, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		// The first connection, kept alive, takes the only slot
+		second := dial(t, addr)
+		if _, err := io.WriteString(second, liveness); err != nil {
+			t.Fatal(err)
+		}
+		secondReader := bufio.NewReader(second)
+		_ = second.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if _, err := secondReader.Peek(1); err == nil {
+			t.Fatal("second connection served while the first is open")
+		}
+
+		_ = first.Close()
+		_ = second.SetReadDeadline(time.Now().Add(5 * time.Second))
+		resp, err = http.ReadResponse(secondReader, nil)
+		if err != nil {
+			t.Fatalf("second connection after the first closed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+}
@@ -4,8 +4,29 @@ package proxy
 
 import (
 	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"time"
 
 	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/audit"
+	"github.com/florianilch/claudine-proxy/internal/cache"
+	"github.com/florianilch/claudine-proxy/internal/capability"
+	"github.com/florianilch/claudine-proxy/internal/capture"
+	"github.com/florianilch/claudine-proxy/internal/dashboard"
+	"github.com/florianilch/claudine-proxy/internal/metrics"
+	"github.com/florianilch/claudine-proxy/internal/pacing"
+	"github.com/florianilch/claudine-proxy/internal/plugin"
+	"github.com/florianilch/claudine-proxy/internal/policy"
+	"github.com/florianilch/claudine-proxy/internal/record"
+	"github.com/florianilch/claudine-proxy/internal/routing"
+	"github.com/florianilch/claudine-proxy/internal/shadow"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/anthropicclaude"
 )
 
 func init() {
@@ -21,18 +42,357 @@ type ReadinessChecker interface {
 type Option func(*config)
 type config struct{}
 
+func WithTransport(http.RoundTripper) Option {
+	return func(c *config) {}
+}
+
 func WithBaseURL(baseURL string) Option {
 	return func(c *config) {}
 }
 
+func WithEndpoints([]string, time.Duration) Option {
+	return func(c *config) {}
+}
+
+func WithPlugins(...plugin.Filter) Option {
+	return func(c *config) {}
+}
+
+func WithUsageSinks(...usage.Sink) Option {
+	return func(c *config) {}
+}
+
+func WithShadow(*shadow.Mirror) Option {
+	return func(c *config) {}
+}
+
+func WithRouter(*routing.Router) Option {
+	return func(c *config) {}
+}
+
+func WithPolicies(*policy.Enforcer) Option {
+	return func(c *config) {}
+}
+
+func WithAuditLog(*audit.Log) Option {
+	return func(c *config) {}
+}
+
+func WithRequestSigning([]byte) Option {
+	return func(c *config) {}
+}
+
+func WithCapabilities(*capability.Registry) Option {
+	return func(c *config) {}
+}
+
+type Tenant struct {
+	Name         string
+	Keys         []string
+	Hosts        []string
+	TokenSource  oauth2.TokenSource
+	ModelAliases map[string]string
+	Pacing       *pacing.Config
+	Pool         bool
+}
+
+func WithTenants(...Tenant) Option {
+	return func(c *config) {}
+}
+
+func WithAdminToken(string) Option {
+	return func(c *config) {}
+}
+
+func WithDashboard(*dashboard.Dashboard) Option {
+	return func(c *config) {}
+}
+
+type ImpersonationProfile struct {
+	Name         string
+	SystemPrompt string
+	BetaFeatures []string
+	UserAgent    string
+	App          string
+	Headers      map[string]string
+	UserID       string
+}
+
+const DefaultImpersonationProfile = "minimal"
+
+func LookupImpersonationProfile(string) (ImpersonationProfile, bool) {
+	return ImpersonationProfile{}, false
+}
+
+func WithImpersonationProfile(ImpersonationProfile) Option {
+	return func(c *config) {}
+}
+
+func WithTolerantInjection(bool) Option {
+	return func(c *config) {}
+}
+
+type StreamFilter struct {
+	DropPings       bool
+	NormalizeErrors bool
+	LogEventCounts  bool
+}
+
+func WithNativeStreamFilter(StreamFilter) Option {
+	return func(c *config) {}
+}
+
+func WithNativeErrorRewrite(bool) Option {
+	return func(c *config) {}
+}
+
+func WithMessagesValidation(bool) Option {
+	return func(c *config) {}
+}
+
+func WithUpstreamCompression(bool) Option {
+	return func(c *config) {}
+}
+
+func WithMetrics(*metrics.Registry) Option {
+	return func(c *config) {}
+}
+
+func WithErrorMetrics(*metrics.ErrorCollector) Option {
+	return func(c *config) {}
+}
+
+func WithUsageReports(bool, string) Option {
+	return func(c *config) {}
+}
+
+func WithBodySpool(string, int64, int64) Option {
+	return func(c *config) {}
+}
+
+func WithStreamObservers(bool) Option {
+	return func(c *config) {}
+}
+
+func WithConnectionMetrics(*metrics.ConnectionCollector) Option {
+	return func(c *config) {}
+}
+
+func WithPacingMetrics(*metrics.PacingCollector) Option {
+	return func(c *config) {}
+}
+
+func WithCache(cache.Store, time.Duration) Option {
+	return func(c *config) {}
+}
+
+func WithPacing(pacing.Config) Option {
+	return func(c *config) {}
+}
+
+func WithRateLimitQueue(pacing.QueueConfig) Option {
+	return func(c *config) {}
+}
+
+type UserIDMode string
+
+func WithUserIDPrivacy(UserIDMode, string) Option {
+	return func(c *config) {}
+}
+
+func WithAnthropicVersions(AnthropicVersions) Option {
+	return func(c *config) {}
+}
+
+func WithPassthrough(...string) Option {
+	return func(c *config) {}
+}
+
+func WithForward(string, string, string) Option {
+	return func(c *config) {}
+}
+
+func WithAzureDeployments(map[string]string) Option {
+	return func(c *config) {}
+}
+
+func WithClientKeys(bool) Option {
+	return func(c *config) {}
+}
+
+func WithFallbackAPIKey(string) Option {
+	return func(c *config) {}
+}
+
+func WithStreamIdleTimeout(time.Duration) Option {
+	return func(c *config) {}
+}
+
+type ServerLimits struct {
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	MaxHeaderBytes int
+	MaxConnections int
+}
+
+func WithAdapterDebug(bool) Option {
+	return func(c *config) {}
+}
+
+func WithRequestFreshness(time.Duration, string) Option {
+	return func(c *config) {}
+}
+
+type LoopDetection struct {
+	RepeatedToolCalls int
+	RequestsPerMinute int
+	Notify            func(ctx context.Context, loop AgentLoop)
+}
+
+type AgentLoop struct {
+	Time         time.Time `json:"time"`
+	Key          string    `json:"key,omitempty"`
+	Conversation string    `json:"conversation,omitempty"`
+	Reason       string    `json:"reason"`
+}
+
+func WithLoopDetection(LoopDetection) Option {
+	return func(c *config) {}
+}
+
+func WithConversationBudget(int64, bool) Option {
+	return func(c *config) {}
+}
+
+func WithDryRun(bool) Option {
+	return func(c *config) {}
+}
+
+func WithAdapterDiff(bool) Option {
+	return func(c *config) {}
+}
+
+func WithToolArgumentRepair(bool) Option {
+	return func(c *config) {}
+}
+
+func WithStrictToolRetries(int) Option {
+	return func(c *config) {}
+}
+
+func WithDeveloperPlacement(string) Option {
+	return func(c *config) {}
+}
+
+func WithInlineSystemMessages(bool) Option {
+	return func(c *config) {}
+}
+
+func WithPromptCaching(int) Option {
+	return func(c *config) {}
+}
+
+func WithReasoningBudgets(...anthropicclaude.ReasoningBudget) Option {
+	return func(c *config) {}
+}
+
+func WithReasoningContent(*anthropicclaude.ReasoningContent) Option {
+	return func(c *config) {}
+}
+
+func WithTTFTTrailer(bool) Option {
+	return func(c *config) {}
+}
+
+func WithModelRefresh(time.Duration) Option {
+	return func(c *config) {}
+}
+
+func WithRecorder(*record.Recorder) Option {
+	return func(c *config) {}
+}
+
+func WithCapture(*capture.Store) Option {
+	return func(c *config) {}
+}
+
+func WithMiddleware(...func(http.Handler) http.Handler) Option {
+	return func(c *config) {}
+}
+
+func WithOpenAIRoutes(bool) Option {
+	return func(c *config) {}
+}
+
+func WithNativeRoutes(bool) Option {
+	return func(c *config) {}
+}
+
+type Surface string
+
+const (
+	SurfaceNative Surface = "native"
+	SurfaceOpenAI Surface = "openai"
+)
+
+func WithSurfaceListener(Surface, string, ...func(http.Handler) http.Handler) Option {
+	return func(c *config) {}
+}
+
+func WithWebSocketOrigins(...string) Option {
+	return func(c *config) {}
+}
+
+func WithServerLimits(ServerLimits) Option {
+	return func(c *config) {}
+}
+
+// New fails: the proxy's streaming JSON transformations need encoding/json/jsontext.
 func New(oauth2.TokenSource, ReadinessChecker, ...Option) (*Proxy, error) {
-	return nil, nil
+	return nil, errors.New("proxy requires building with GOEXPERIMENT=jsonv2")
 }
 
+func DefaultTransport() *http.Transport {
+	return nil
+}
+
+type TransportSettings struct {
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	DisableHTTP2          bool
+	DNSCacheTTL           time.Duration
+	PinnedAddresses       map[string][]netip.Addr
+}
+
+func NewTransport(TransportSettings) *http.Transport {
+	return nil
+}
+
+func (p *Proxy) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
 func (p *Proxy) Start(context.Context, string) (<-chan error, error) {
 	return nil, nil
 }
 
+func (p *Proxy) Serve(context.Context, ...net.Listener) (<-chan error, error) {
+	return nil, nil
+}
+
+func (p *Proxy) ListenerFiles() ([]*os.File, error) {
+	return nil, nil
+}
+
+func (p *Proxy) SurfaceHandler(Surface) http.Handler {
+	return nil
+}
+
+func (p *Proxy) ActiveStreams() int64 {
+	return 0
+}
+
 func (p *Proxy) Shutdown(context.Context) error {
 	return nil
 }
@@ -0,0 +1,187 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/record"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/anthropicclaude"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+const recordSSE = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":3,"output_tokens":0}}}` + "\n\n" +
+	"event: content_block_start\n" +
+	`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+	"event: content_block_delta\n" +
+	`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hello"}}` + "\n\n" +
+	"event: content_block_stop\n" +
+	`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+	"event: message_delta\n" +
+	`data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":1}}` + "\n\n" +
+	"event: message_stop\n" +
+	`data: {"type":"message_stop"}` + "\n\n"
+
+func TestRecorder(t *testing.T) {
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte(`"stream":true`)) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"text/event-stream"}},
+				Body:       io.NopCloser(strings.NewReader(recordSSE)),
+				Request:    r,
+			}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5",` +
+				`"content":[{"type":"text","text":"key sk-ant-api03-secret"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`)),
+			Request: r,
+		}, nil
+	})
+
+	recorder := record.New()
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+	p, err := New(ts, readyChecker{}, WithTransport(upstream), WithRecorder(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{
+		`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`,
+		`{"model":"claude-sonnet-4-5","stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+	} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	dir := t.TempDir()
+	paths, err := recorder.WriteFixtures(dir, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("paths = %v, want buffered and streaming fixture", paths)
+	}
+	if _, err := recorder.WriteFixtures(dir, "session"); err == nil {
+		t.Error("WriteFixtures() overwrote existing fixtures")
+	}
+
+	// Replay the fixtures through the adapter like its golden tests do
+	adapter := anthropicclaude.NewCreateChatCompletionAdapter()
+
+	var buffered []record.Turn
+	readFixture(t, filepath.Join(dir, "buffered", "session.json"), &buffered)
+	if len(buffered) != 1 {
+		t.Fatalf("buffered turns = %d, want 1", len(buffered))
+	}
+	turn := buffered[0]
+	if bytes.Contains(turn.AnthropicResponse, []byte("secret")) || bytes.Contains(turn.OpenAIResponse, []byte("secret")) {
+		t.Errorf("credentials not scrubbed: %s", turn.OpenAIResponse)
+	}
+	replay := &replayTransport{response: string(turn.AnthropicResponse), contentType: "application/json"}
+	resp, err := adapter.ProcessRequest(context.Background(), decodeRequest(t, turn.OpenAIRequest), replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameJSON(t, replay.request, turn.AnthropicRequest)
+	got, _ := json.Marshal(resp)
+	assertSameJSON(t, got, turn.OpenAIResponse)
+
+	var streaming []record.StreamingTurn
+	readFixture(t, filepath.Join(dir, "streaming", "session_stream.json"), &streaming)
+	if len(streaming) != 1 {
+		t.Fatalf("streaming turns = %d, want 1", len(streaming))
+	}
+	sturn := streaming[0]
+	replay = &replayTransport{response: strings.Join(sturn.AnthropicSSE, "\n"), contentType: "text/event-stream"}
+	stream, err := adapter.ProcessStreamingRequest(context.Background(), decodeRequest(t, sturn.OpenAIRequest), replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks [][]byte
+	for chunk, err := range stream {
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(chunk)
+		chunks = append(chunks, data)
+	}
+	assertSameJSON(t, replay.request, sturn.AnthropicRequest)
+	if len(chunks) != len(sturn.OpenAIChunks) {
+		t.Fatalf("chunks = %d, recorded %d", len(chunks), len(sturn.OpenAIChunks))
+	}
+	for i := range chunks {
+		assertSameJSON(t, chunks[i], sturn.OpenAIChunks[i])
+	}
+}
+
+// replayTransport answers with a recorded response and keeps the request body.
+type replayTransport struct {
+	response    string
+	contentType string
+	request     []byte
+}
+
+func (r *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.request, _ = io.ReadAll(req.Body)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {r.contentType}},
+		Body:       io.NopCloser(strings.NewReader(r.response)),
+		Request:    req,
+	}, nil
+}
+
+func readFixture(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
+
+func decodeRequest(t *testing.T, data []byte) types.CreateChatCompletionRequest {
+	t.Helper()
+	var req types.CreateChatCompletionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func assertSameJSON(t *testing.T, got, want []byte) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	gotNorm, _ := json.Marshal(g)
+	wantNorm, _ := json.Marshal(w)
+	if !bytes.Equal(gotNorm, wantNorm) {
+		t.Errorf("JSON mismatch:\ngot:  %s\nwant: %s", gotNorm, wantNorm)
+	}
+}
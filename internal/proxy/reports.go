@@ -0,0 +1,42 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+)
+
+// usageReportPaths are Anthropic's usage and cost reporting endpoints, relative to the
+// upstream base path. A trailing slash matches everything below it.
+var usageReportPaths = []string{
+	"/organizations/usage_report/",
+	"/organizations/cost_report",
+}
+
+// WithUsageReports forwards GET requests for Anthropic's usage and cost reports
+// (/v1/organizations/usage_report/*, /v1/organizations/cost_report) from holders of
+// the admin token. Anthropic serves these reports to Admin API keys: requests are
+// sent with adminAPIKey if set, with the proxy's OAuth credentials otherwise.
+// Requires WithAdminToken.
+func WithUsageReports(enabled bool, adminAPIKey string) Option {
+	return func(c *config) {
+		c.usageReports = enabled
+		c.adminAPIKey = adminAPIKey
+	}
+}
+
+// usageReportCredentials keeps the admin token from reaching upstream and stores the
+// Admin API key, if any, in the request context, where ClientKeyTransport picks it up.
+func usageReportCredentials(apiKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Del("Authorization")
+			r.Header.Del("X-Api-Key")
+			if apiKey != "" {
+				r = r.WithContext(context.WithValue(r.Context(), clientKeyContextKey{}, apiKey))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
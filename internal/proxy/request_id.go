@@ -0,0 +1,36 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+// requestIDHeader carries the proxy's request ID upstream, correlating upstream
+// requests with proxy logs and the X-Request-ID returned to clients.
+const requestIDHeader = "X-Request-ID"
+
+// RequestIDTransport is an http.RoundTripper that forwards the request ID from the
+// request context upstream. It runs after header filtering, so the ID also reaches
+// upstream on impersonated requests and for requests the adapter creates.
+type RequestIDTransport struct {
+	Base http.RoundTripper
+}
+
+// Compile-time check that RequestIDTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*RequestIDTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := middleware.RequestIDFromContext(req.Context())
+	if requestID == "" || req.Header.Get(requestIDHeader) == requestID {
+		return t.Base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set(requestIDHeader, requestID)
+	return t.Base.RoundTrip(req)
+}
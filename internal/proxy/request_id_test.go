@@ -0,0 +1,83 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRequestIDForwarded(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		body      string
+		requestID string
+	}{
+		{
+			name: "messages generated",
+			path: "/v1/messages",
+			body: `{"model":"claude-sonnet-4-5","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`,
+		},
+		{
+			name:      "messages from client",
+			path:      "/v1/messages",
+			body:      `{"model":"claude-sonnet-4-5","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`,
+			requestID: "client-id-1",
+		},
+		{
+			name:      "chat completions",
+			path:      "/v1/chat/completions",
+			body:      `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`,
+			requestID: "client-id-2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upstreamID string
+			upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				upstreamID = r.Header.Get("X-Request-ID")
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body: io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5",` +
+						`"content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)),
+					Request: r,
+				}, nil
+			})
+
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth-token"})
+			p, err := New(ts, readyChecker{}, WithTransport(upstream))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-ID", tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+			}
+			responseID := rec.Header().Get("X-Request-ID")
+			if responseID == "" {
+				t.Fatal("response missing X-Request-ID")
+			}
+			if tt.requestID != "" && responseID != tt.requestID {
+				t.Errorf("response X-Request-ID = %q, want %q", responseID, tt.requestID)
+			}
+			if upstreamID != responseID {
+				t.Errorf("upstream X-Request-ID = %q, want %q", upstreamID, responseID)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// lookuper resolves host names, like net.Resolver.
+type lookuper interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// resolvingDialer dials upstream hosts at pinned addresses or at addresses from its
+// DNS cache, trying them in order. Other hosts and IP literals are dialed as usual.
+// TLS still verifies the certificate against the requested host name.
+type resolvingDialer struct {
+	dialer *net.Dialer
+	pinned map[string][]netip.Addr
+	cache  *dnsCache // nil disables caching
+}
+
+// DialContext implements http.Transport.DialContext.
+func (d *resolvingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, ok := d.pinned[host]
+	if !ok {
+		if d.cache == nil {
+			return d.dialer.DialContext(ctx, network, address)
+		}
+		if _, err := netip.ParseAddr(host); err == nil {
+			return d.dialer.DialContext(ctx, network, address)
+		}
+		if addrs, err = d.cache.lookup(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dnsCache keeps resolved addresses for ttl. Should resolving fail after an entry
+// expired, the stale addresses are used until a lookup succeeds again, so flaky DNS
+// doesn't fail requests to a host that was reachable before.
+type dnsCache struct {
+	ttl      time.Duration
+	resolver lookuper
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+func newDNSCache(ttl time.Duration) *dnsCache {
+	return &dnsCache{ttl: ttl, resolver: net.DefaultResolver, now: time.Now, entries: map[string]dnsEntry{}}
+}
+
+// lookup returns the addresses of host, resolving it if not cached.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.resolver.LookupNetIP(ctx, "ip", host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		if ok {
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
@@ -0,0 +1,88 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	addrs   []netip.Addr
+	err     error
+	lookups int
+}
+
+func (r *fakeResolver) LookupNetIP(context.Context, string, string) ([]netip.Addr, error) {
+	r.lookups++
+	return r.addrs, r.err
+}
+
+func TestDNSCache(t *testing.T) {
+	addr := netip.MustParseAddr("192.0.2.1")
+	resolver := &fakeResolver{addrs: []netip.Addr{addr}}
+	now := time.Unix(0, 0)
+	cache := &dnsCache{ttl: time.Minute, resolver: resolver, now: func() time.Time { return now }, entries: map[string]dnsEntry{}}
+
+	lookup := func() ([]netip.Addr, error) {
+		t.Helper()
+		return cache.lookup(t.Context(), "api.anthropic.com")
+	}
+
+	if _, err := lookup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lookup(); err != nil {
+		t.Fatal(err)
+	}
+	if resolver.lookups != 1 {
+		t.Errorf("lookups = %d, want 1 while cached", resolver.lookups)
+	}
+
+	// Expired entries are resolved again and served stale while DNS fails
+	now = now.Add(2 * time.Minute)
+	resolver.err = errors.New("server misbehaving")
+	got, err := lookup()
+	if err != nil {
+		t.Fatalf("lookup with stale entry: %v", err)
+	}
+	if resolver.lookups != 2 || len(got) != 1 || got[0] != addr {
+		t.Errorf("got %v after %d lookups, want stale %v after 2", got, resolver.lookups, addr)
+	}
+
+	if _, err := cache.lookup(t.Context(), "unknown.example"); err == nil {
+		t.Error("lookup of uncached host succeeded despite failing DNS")
+	}
+}
+
+func TestNewTransportPinnedAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	transport := NewTransport(TransportSettings{
+		PinnedAddresses: map[string][]netip.Addr{
+			// The server only listens on IPv4, so the first address is skipped
+			"api.anthropic.test": {netip.MustParseAddr("::1"), netip.MustParseAddr("127.0.0.1")},
+		},
+	})
+
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort("api.anthropic.test", port)}
+	resp, err := (&http.Client{Transport: transport}).Get(target.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRouteSwitches(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	const (
+		messages    = `{"model":"claude-sonnet-4-5","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}`
+		completions = `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`
+	)
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/v1/messages", messages},
+		{http.MethodPost, "/v1/chat/completions", completions},
+		{http.MethodPost, "/openai/deployments/claude-sonnet-4-5/chat/completions", completions},
+		{http.MethodPost, "/v1/embeddings", `{}`},
+		{http.MethodGet, "/v1/models", ""},
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantStatus []int // per request above
+	}{
+		{
+			name:       "default",
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusNotImplemented, http.StatusOK},
+		},
+		{
+			name:       "openai disabled",
+			opts:       []Option{WithOpenAIRoutes(false)},
+			wantStatus: []int{http.StatusOK, http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, http.StatusOK},
+		},
+		{
+			name:       "native disabled",
+			opts:       []Option{WithNativeRoutes(false)},
+			wantStatus: []int{http.StatusNotFound, http.StatusOK, http.StatusOK, http.StatusNotImplemented, http.StatusOK},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+			p, err := New(ts, readyChecker{}, append([]Option{WithBaseURL(upstream.URL + "/v1")}, tt.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, req := range requests {
+				rec := httptest.NewRecorder()
+				p.ServeHTTP(rec, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+				if rec.Code != tt.wantStatus[i] {
+					t.Errorf("%s %s: status = %d, want %d", req.method, req.path, rec.Code, tt.wantStatus[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+)
+
+// defaultRewindMemory is the MemoryLimit of the spool buffering bodies for
+// failover if WithBodySpool configured none.
+const defaultRewindMemory = 1 << 20
+
+// WithBodySpool writes request bodies the proxy must read more than once, such as
+// for tolerant system prompt injection, to temporary files in dir once larger
+// than memoryLimit bytes, instead of holding them in memory. maxSize bounds the
+// bytes spooled at once; bodies not fitting stay in memory. Zero maxSize leaves
+// the spool unbounded. An empty dir keeps such bodies in memory, except for
+// bodies replayed on failover, which go to the system's temporary directory
+// beyond 1 MiB.
+func WithBodySpool(dir string, memoryLimit, maxSize int64) Option {
+	return func(c *config) {
+		if dir == "" {
+			c.spool = nil
+			return
+		}
+		c.spool = &BodySpool{Dir: dir, MemoryLimit: memoryLimit, MaxSize: maxSize}
+	}
+}
+
+// BodySpool buffers request bodies in memory up to MemoryLimit and in temporary
+// files beyond. A nil *BodySpool buffers in memory only.
+type BodySpool struct {
+	// Dir holds the temporary files; empty uses the system's temporary directory.
+	Dir string
+
+	// MemoryLimit is the size up to which bodies stay in memory.
+	MemoryLimit int64
+
+	// MaxSize bounds the bytes in temporary files at once, zero for no bound.
+	MaxSize int64
+
+	used atomic.Int64 // Bytes in temporary files
+}
+
+// reserve accounts for n more bytes on disk, reporting false if they would
+// exceed MaxSize.
+func (s *BodySpool) reserve(n int64) bool {
+	if s.used.Add(n) > s.MaxSize && s.MaxSize > 0 {
+		s.used.Add(-n)
+		return false
+	}
+	return true
+}
+
+// buffer reads r into a spooledBody. The caller must close it.
+func (s *BodySpool) buffer(r io.Reader) (*spooledBody, error) {
+	b := &spooledBody{spool: s}
+	if _, err := io.Copy(b, r); err != nil {
+		_ = b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// rewind buffers the body of req so it can be sent more than once, e.g. on
+// failover, setting req.Body and req.GetBody. It returns the buffer to close once
+// the request is done, nil if req has no body.
+func (s *BodySpool) rewind(req *http.Request) (*spooledBody, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := s.buffer(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = b.Reader()
+	req.GetBody = func() (io.ReadCloser, error) {
+		return b.Reader(), nil
+	}
+	return b, nil
+}
+
+// closeAfter closes b, if any, once the body of resp is closed, or right away
+// if the request failed.
+func closeAfter(resp *http.Response, err error, b *spooledBody) (*http.Response, error) {
+	if b == nil {
+		return resp, err
+	}
+	if err != nil {
+		_ = b.Close()
+		return nil, err
+	}
+	resp.Body = &countedBody{ReadCloser: resp.Body, done: func() { _ = b.Close() }}
+	return resp, nil
+}
+
+// spooledBody is a request body written once and read any number of times. It
+// keeps its content in memory until exceeding its spool's MemoryLimit, then moves
+// it to a temporary file, or back to memory if the spool is full.
+type spooledBody struct {
+	spool  *BodySpool
+	data   []byte   // Content unless in file
+	file   *os.File // Holds the content once spooled
+	size   int64
+	pinned bool // Stays in memory, the spool being full
+}
+
+// Write implements io.Writer, appending p.
+func (b *spooledBody) Write(p []byte) (int, error) {
+	if b.file == nil && (b.pinned || b.spool == nil || b.size+int64(len(p)) <= b.spool.MemoryLimit) {
+		b.data = append(b.data, p...)
+		b.size += int64(len(p))
+		return len(p), nil
+	}
+	if b.file == nil {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+		if b.file == nil {
+			return b.Write(p)
+		}
+	}
+	if !b.spool.reserve(int64(len(p))) {
+		if err := b.unspill(); err != nil {
+			return 0, err
+		}
+		return b.Write(p)
+	}
+	n, err := b.file.Write(p)
+	b.size += int64(n)
+	if n < len(p) {
+		b.spool.used.Add(int64(n - len(p)))
+	}
+	return n, err
+}
+
+// spill moves the content to a temporary file, or pins it in memory if the spool
+// is full.
+func (b *spooledBody) spill() error {
+	if !b.spool.reserve(b.size) {
+		b.pinned = true
+		return nil
+	}
+	f, err := os.CreateTemp(b.spool.Dir, "claudine-body-*")
+	if err != nil {
+		b.spool.used.Add(-b.size)
+		return err
+	}
+	if _, err := f.Write(b.data); err != nil {
+		b.spool.used.Add(-b.size)
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	b.file = f
+	b.data = nil
+	return nil
+}
+
+// unspill moves the content back to memory and pins it there.
+func (b *spooledBody) unspill() error {
+	data := make([]byte, b.size)
+	if _, err := b.file.ReadAt(data, 0); err != nil {
+		return err
+	}
+	b.release()
+	b.data = data
+	b.pinned = true
+	return nil
+}
+
+// Len returns the size of the content.
+func (b *spooledBody) Len() int64 {
+	return b.size
+}
+
+// Reader returns a reader of the content from the start. Readers are independent
+// of each other but must not be used after Close.
+func (b *spooledBody) Reader() io.ReadCloser {
+	if b.file != nil {
+		return io.NopCloser(io.NewSectionReader(b.file, 0, b.size))
+	}
+	return io.NopCloser(bytes.NewReader(b.data))
+}
+
+// Close removes the temporary file, if any.
+func (b *spooledBody) Close() error {
+	b.release()
+	b.data = nil
+	return nil
+}
+
+// release removes the temporary file and returns its space to the spool.
+func (b *spooledBody) release() {
+	if b.file == nil {
+		return
+	}
+	_ = b.file.Close()
+	_ = os.Remove(b.file.Name())
+	b.spool.used.Add(-b.size)
+	b.file = nil
+}
@@ -0,0 +1,119 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBodySpool(t *testing.T) {
+	tests := []struct {
+		name      string
+		spool     *BodySpool // Dir is set by the test
+		reserved  int64      // Bytes already spooled by other bodies
+		body      string
+		wantFiles int
+	}{
+		{name: "memory only", body: strings.Repeat("a", 100)},
+		{name: "below memory limit", spool: &BodySpool{MemoryLimit: 100}, body: strings.Repeat("a", 100)},
+		{name: "above memory limit", spool: &BodySpool{MemoryLimit: 10}, body: strings.Repeat("a", 100), wantFiles: 1},
+		{name: "within max size", spool: &BodySpool{MemoryLimit: 10, MaxSize: 100}, body: strings.Repeat("a", 100), wantFiles: 1},
+		{name: "spool full", spool: &BodySpool{MemoryLimit: 10, MaxSize: 100}, reserved: 95, body: strings.Repeat("a", 100)},
+		{name: "spool full while writing", spool: &BodySpool{MemoryLimit: 10, MaxSize: 50}, body: strings.Repeat("a", 100_000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.spool != nil {
+				tt.spool.Dir = dir
+				tt.spool.used.Store(tt.reserved)
+			}
+
+			b, err := tt.spool.buffer(strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if files, _ := os.ReadDir(dir); len(files) != tt.wantFiles {
+				t.Errorf("%d temporary files, want %d", len(files), tt.wantFiles)
+			}
+			if b.Len() != int64(len(tt.body)) {
+				t.Errorf("Len() = %d, want %d", b.Len(), len(tt.body))
+			}
+			for i := range 2 {
+				got, err := io.ReadAll(b.Reader())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.body {
+					t.Errorf("read %d: got %d bytes, want body of %d", i, len(got), len(tt.body))
+				}
+			}
+
+			if err := b.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if files, _ := os.ReadDir(dir); len(files) != 0 {
+				t.Errorf("%d temporary files left after Close", len(files))
+			}
+			if tt.spool != nil && tt.spool.used.Load() != tt.reserved {
+				t.Errorf("spool used = %d after Close, want %d", tt.spool.used.Load(), tt.reserved)
+			}
+		})
+	}
+}
+
+func TestQuotaFallbackSpool(t *testing.T) {
+	const body = `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`
+	spool := &BodySpool{Dir: t.TempDir(), MemoryLimit: 4}
+	files := func() int {
+		entries, _ := os.ReadDir(spool.Dir)
+		return len(entries)
+	}
+
+	var primaryBody, fallbackBody, getBody string
+	transport := &QuotaFallbackTransport{
+		Primary: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			b, _ := io.ReadAll(r.Body)
+			primaryBody = string(b)
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Anthropic-Ratelimit-Unified-Status": {"rejected"}},
+				Body:       io.NopCloser(strings.NewReader(`{}`)),
+			}, nil
+		}),
+		Fallback: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			b, _ := io.ReadAll(r.Body)
+			fallbackBody = string(b)
+			rc, err := r.GetBody()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ = io.ReadAll(rc)
+			getBody = string(b)
+			if files() != 1 {
+				t.Errorf("%d spooled files while sending, want 1", files())
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}),
+		APIKey: "sk-ant-api03-fallback",
+		Spool:  spool,
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", strings.NewReader(body))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primaryBody != body || fallbackBody != body || getBody != body {
+		t.Errorf("bodies = %q, %q, GetBody %q, want %q", primaryBody, fallbackBody, getBody, body)
+	}
+
+	_ = resp.Body.Close()
+	if files() != 0 {
+		t.Errorf("%d spooled files left after the response", files())
+	}
+}
@@ -0,0 +1,212 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// StreamFilter normalizes Server-Sent Events of native /v1/messages streams.
+// The zero value passes streams unchanged.
+type StreamFilter struct {
+	// DropPings removes ping events, which carry no data for clients.
+	DropPings bool
+
+	// NormalizeErrors rewrites error events to Anthropic's documented shape
+	// {"type":"error","error":{"type":...,"message":...}}, whatever produced them
+	// (upstream, intermediaries or the proxy's idle watchdog). Unknown error types
+	// become api_error; unparsable payloads become the message.
+	NormalizeErrors bool
+
+	// LogEventCounts logs the number of events per type when a stream ends.
+	LogEventCounts bool
+}
+
+// enabled reports whether the filter changes or inspects streams.
+func (f StreamFilter) enabled() bool {
+	return f.DropPings || f.NormalizeErrors || f.LogEventCounts
+}
+
+// WithNativeStreamFilter applies f to streaming responses of POST /v1/messages.
+func WithNativeStreamFilter(f StreamFilter) Option {
+	return func(c *config) {
+		c.streamFilter = f
+	}
+}
+
+// anthropicErrorTypes are the error types Anthropic documents for its API.
+var anthropicErrorTypes = map[string]bool{
+	"invalid_request_error": true,
+	"authentication_error":  true,
+	"billing_error":         true,
+	"permission_error":      true,
+	"not_found_error":       true,
+	"request_too_large":     true,
+	"rate_limit_error":      true,
+	"api_error":             true,
+	"timeout_error":         true,
+	"overloaded_error":      true,
+}
+
+// filterStream returns a ReverseProxy.ModifyResponse hook wrapping SSE bodies with f.
+func filterStream(f StreamFilter) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if mediaType != "text/event-stream" {
+			return nil
+		}
+		resp.Body = &sseFilterBody{
+			ctx:    resp.Request.Context(),
+			body:   resp.Body,
+			reader: bufio.NewReader(resp.Body),
+			filter: f,
+			counts: make(map[string]int),
+		}
+		return nil
+	}
+}
+
+// sseFilterBody reads whole events from body and emits them filtered.
+type sseFilterBody struct {
+	ctx    context.Context
+	body   io.ReadCloser
+	reader *bufio.Reader
+	filter StreamFilter
+
+	event   []byte // lines of the event being read
+	pending []byte // filtered output not yet returned
+	err     error  // read error, returned once pending is drained
+
+	counts  map[string]int
+	logOnce sync.Once
+}
+
+func (b *sseFilterBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 && b.err == nil {
+		line, err := b.reader.ReadBytes('\n')
+		b.event = append(b.event, line...)
+		if err != nil {
+			// Incomplete trailing event: pass it on as is
+			b.pending = append(b.pending, b.event...)
+			b.event = nil
+			b.err = err
+			break
+		}
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			b.pending = append(b.pending, b.process(b.event)...)
+			b.event = b.event[:0]
+		}
+	}
+
+	if len(b.pending) > 0 {
+		n := copy(p, b.pending)
+		b.pending = b.pending[n:]
+		return n, nil
+	}
+	if b.err == io.EOF {
+		b.logCounts()
+	}
+	return 0, b.err
+}
+
+// process filters one event including its terminating blank line.
+func (b *sseFilterBody) process(event []byte) []byte {
+	name, data := parseEvent(event)
+	if name == "" && data == nil {
+		return event // comments or stray blank lines
+	}
+	if name == "" {
+		name = "message"
+	}
+	b.counts[name]++
+
+	switch {
+	case b.filter.DropPings && name == "ping":
+		return nil
+	case b.filter.NormalizeErrors && name == "error":
+		return normalizeErrorEvent(data)
+	default:
+		return event
+	}
+}
+
+// parseEvent returns the event name and the joined data lines of an SSE event.
+func parseEvent(event []byte) (name string, data []byte) {
+	for line := range bytes.Lines(event) {
+		line = bytes.TrimRight(line, "\r\n")
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			name = string(value)
+		case "data":
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+		}
+	}
+	return name, data
+}
+
+// normalizeErrorEvent formats data as an Anthropic error event.
+func normalizeErrorEvent(data []byte) []byte {
+	var payload struct {
+		Error     json.RawMessage `json:"error"`
+		Message   string          `json:"message"`
+		RequestID string          `json:"request_id"`
+	}
+	var detail struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		detail.Message = string(data)
+	} else if err := json.Unmarshal(payload.Error, &detail); err != nil {
+		// "error" may be a plain string; otherwise fall back to a top-level message
+		if json.Unmarshal(payload.Error, &detail.Message) != nil {
+			detail.Message = payload.Message
+		}
+	}
+	if !anthropicErrorTypes[detail.Type] {
+		detail.Type = "api_error"
+	}
+	if detail.Message == "" {
+		detail.Message = "stream error"
+	}
+
+	normalized, _ := json.Marshal(struct {
+		Type      string `json:"type"`
+		Error     any    `json:"error"`
+		RequestID string `json:"request_id,omitempty"`
+	}{"error", detail, payload.RequestID})
+
+	out := append([]byte("event: error\ndata: "), normalized...)
+	return append(out, "\n\n"...)
+}
+
+func (b *sseFilterBody) logCounts() {
+	if !b.filter.LogEventCounts {
+		return
+	}
+	b.logOnce.Do(func() {
+		attrs := make([]any, 0, len(b.counts))
+		for name, n := range b.counts {
+			attrs = append(attrs, slog.Int(name, n))
+		}
+		slog.InfoContext(b.ctx, "stream events", slog.Group("events", attrs...))
+	})
+}
+
+func (b *sseFilterBody) Close() error {
+	b.logCounts()
+	return b.body.Close()
+}
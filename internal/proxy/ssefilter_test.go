@@ -0,0 +1,101 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStreamFilter(t *testing.T) {
+	const stream = "event: message_start\ndata: {\"type\":\"message_start\"}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+		": keep-alive\n\n" +
+		"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"},\"request_id\":\"req_1\"}\n\n"
+
+	tests := []struct {
+		name   string
+		filter StreamFilter
+		body   string
+		want   string
+	}{
+		{
+			name:   "drop pings",
+			filter: StreamFilter{DropPings: true},
+			body:   stream,
+			want: "event: message_start\ndata: {\"type\":\"message_start\"}\n\n" +
+				": keep-alive\n\n" +
+				"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"},\"request_id\":\"req_1\"}\n\n",
+		},
+		{
+			name:   "well-formed error kept",
+			filter: StreamFilter{NormalizeErrors: true},
+			body:   stream,
+			want:   stream,
+		},
+		{
+			name:   "unknown error type",
+			filter: StreamFilter{NormalizeErrors: true},
+			body:   "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"gateway_error\",\"message\":\"bad gateway\"}}\n\n",
+			want:   "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\",\"message\":\"bad gateway\"}}\n\n",
+		},
+		{
+			name:   "string error",
+			filter: StreamFilter{NormalizeErrors: true},
+			body:   "event: error\r\ndata: {\"error\":\"upstream reset\"}\r\n\r\n",
+			want:   "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\",\"message\":\"upstream reset\"}}\n\n",
+		},
+		{
+			name:   "plain text error",
+			filter: StreamFilter{NormalizeErrors: true},
+			body:   "event: error\ndata: connection lost\n\n",
+			want:   "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"api_error\",\"message\":\"connection lost\"}}\n\n",
+		},
+		{
+			name:   "incomplete trailing event",
+			filter: StreamFilter{DropPings: true},
+			body:   "event: ping\ndata: {}\n\nevent: message_stop\ndata: {",
+			want:   "event: message_stop\ndata: {",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/v1/messages", nil)
+			resp := &http.Response{
+				Header:  http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}},
+				Body:    io.NopCloser(strings.NewReader(tt.body)),
+				Request: req,
+			}
+			if err := filterStream(tt.filter)(resp); err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamFilterCountsEvents(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/v1/messages", nil)
+	resp := &http.Response{
+		Header:  http.Header{"Content-Type": {"text/event-stream"}},
+		Body:    io.NopCloser(strings.NewReader("event: ping\ndata: {}\n\ndata: {}\n\nevent: ping\ndata: {}\n\n")),
+		Request: req,
+	}
+	if err := filterStream(StreamFilter{LogEventCounts: true})(resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	if counts := resp.Body.(*sseFilterBody).counts; counts["ping"] != 2 || counts["message"] != 1 {
+		t.Errorf("counts = %v", counts)
+	}
+}
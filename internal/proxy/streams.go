@@ -0,0 +1,59 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+// streamCounter is an http.RoundTripper that counts upstream SSE responses whose
+// body is still open and, if Hub is set, relays them to observers.
+type streamCounter struct {
+	Base   http.RoundTripper
+	Hub    *streamHub
+	active atomic.Int64
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *streamCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		t.active.Add(1)
+		resp.Body = &countedBody{ReadCloser: resp.Body, done: func() { t.active.Add(-1) }}
+		if id := middleware.RequestIDFromContext(req.Context()); t.Hub != nil && id != "" {
+			resp.Body = t.Hub.observe(id, resp.Body)
+		}
+	}
+	return resp, nil
+}
+
+// countedBody calls done once when closed.
+type countedBody struct {
+	io.ReadCloser
+	done func()
+	once sync.Once
+}
+
+func (b *countedBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+// ActiveStreams returns the number of streaming responses currently being relayed.
+func (p *Proxy) ActiveStreams() int64 {
+	if p.streams == nil {
+		return 0
+	}
+	return p.streams.active.Load()
+}
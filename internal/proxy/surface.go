@@ -0,0 +1,53 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import "net/http"
+
+// Surface is a group of API routes that can be served on a listener of its own.
+type Surface string
+
+const (
+	// SurfaceNative is the Anthropic Messages API, including passthrough endpoints.
+	SurfaceNative Surface = "native"
+
+	// SurfaceOpenAI is the OpenAI compatibility layer.
+	SurfaceOpenAI Surface = "openai"
+)
+
+// surfaceListener is a surface configured via WithSurfaceListener.
+type surfaceListener struct {
+	surface     Surface
+	address     string
+	middlewares []func(http.Handler) http.Handler
+}
+
+// surfaceServer serves a surface on its own listener.
+type surfaceServer struct {
+	surface Surface
+	address string
+	mux     *http.ServeMux
+	handler http.Handler // mux wrapped in the surface's middlewares
+}
+
+// WithSurfaceListener serves the routes of surface on address instead of the main
+// listener, e.g. the native API on localhost only and the OpenAI layer on the LAN.
+// Files, models, account and health routes are served on both. middlewares wrap
+// this listener's handler only, the first being the outermost. Server limits apply
+// per listener.
+func WithSurfaceListener(surface Surface, address string, middlewares ...func(http.Handler) http.Handler) Option {
+	return func(c *config) {
+		c.surfaces = append(c.surfaces, surfaceListener{surface: surface, address: address, middlewares: middlewares})
+	}
+}
+
+// SurfaceHandler returns the handler serving the routes of surface: the handler of
+// its own listener, or the proxy itself.
+func (p *Proxy) SurfaceHandler(surface Surface) http.Handler {
+	for _, s := range p.surfaces {
+		if s.surface == surface {
+			return s.handler
+		}
+	}
+	return p
+}
@@ -0,0 +1,107 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestSurfaceListener(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	tagged := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Surface", "openai")
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithBaseURL(upstream.URL+"/v1"), WithSurfaceListener(SurfaceOpenAI, "127.0.0.1:0", tagged))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listeners []net.Listener
+	for range 2 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners = append(listeners, l)
+	}
+	if _, err := p.Serve(t.Context(), listeners[0]); err == nil {
+		t.Fatal("Serve() with a missing surface listener succeeded")
+	}
+	if _, err := p.Serve(t.Context(), listeners...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Shutdown(t.Context()) })
+	mainURL := "http://" + listeners[0].Addr().String()
+	openaiURL := "http://" + listeners[1].Addr().String()
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		body        string
+		wantStatus  int
+		wantSurface string
+	}{
+		{name: "native on main", method: http.MethodPost, url: mainURL + "/v1/messages", body: `{"model":"claude-sonnet-4-5","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}`, wantStatus: http.StatusOK},
+		{name: "openai not on main", method: http.MethodPost, url: mainURL + "/v1/chat/completions", body: `{}`, wantStatus: http.StatusNotFound},
+		{name: "native not on openai", method: http.MethodPost, url: openaiURL + "/v1/messages", body: `{}`, wantStatus: http.StatusNotFound, wantSurface: "openai"},
+		{name: "openai on openai", method: http.MethodPost, url: openaiURL + "/v1/chat/completions", body: `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`, wantStatus: http.StatusOK, wantSurface: "openai"},
+		{name: "models on main", method: http.MethodGet, url: mainURL + "/v1/models", wantStatus: http.StatusOK},
+		{name: "models on openai", method: http.MethodGet, url: openaiURL + "/v1/models", wantStatus: http.StatusOK, wantSurface: "openai"},
+		{name: "health on openai", method: http.MethodGet, url: openaiURL + "/health/readiness", wantStatus: http.StatusOK, wantSurface: "openai"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(t.Context(), tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("X-Surface"); got != tt.wantSurface {
+				t.Errorf("X-Surface = %q, want %q", got, tt.wantSurface)
+			}
+		})
+	}
+}
+
+func TestSurfaceListenerInvalid(t *testing.T) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "unknown", opts: []Option{WithSurfaceListener("grpc", ":0")}},
+		{name: "twice", opts: []Option{WithSurfaceListener(SurfaceNative, ":0"), WithSurfaceListener(SurfaceNative, ":0")}},
+		{name: "disabled", opts: []Option{WithOpenAIRoutes(false), WithSurfaceListener(SurfaceOpenAI, ":0")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(ts, readyChecker{}, tt.opts...); err == nil {
+				t.Error("New() succeeded")
+			}
+		})
+	}
+}
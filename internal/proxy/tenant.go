@@ -0,0 +1,220 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+	"github.com/florianilch/claudine-proxy/internal/pacing"
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// Tenant is an account served by the proxy next to the default one, selected by
+// the client's virtual key or the request's Host header.
+type Tenant struct {
+	Name string
+
+	// Keys are virtual keys (raw or as usage key IDs, "key_…") selecting the tenant.
+	Keys []string
+
+	// Hosts are Host header values (without port) selecting the tenant.
+	Hosts []string
+
+	// TokenSource authenticates the tenant's upstream requests.
+	TokenSource oauth2.TokenSource
+
+	// ModelAliases rewrites requested models (alias → model).
+	ModelAliases map[string]string
+
+	// Pacing limits the tenant's upstream traffic. Nil disables pacing.
+	Pacing *pacing.Config
+
+	// Pool additionally shares requests matching no tenant between the default
+	// account and all pooled tenants (see PoolTransport). Pooled tenants need no
+	// keys or hosts.
+	Pool bool
+}
+
+// WithTenants serves additional accounts from one process. Requests matching no
+// tenant use the default token source.
+func WithTenants(tenants ...Tenant) Option {
+	return func(c *config) {
+		c.tenants = append(c.tenants, tenants...)
+	}
+}
+
+type tenantContextKey struct{}
+
+// tenantFromContext returns the tenant selected for the request, or nil.
+func tenantFromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(tenantContextKey{}).(*Tenant)
+	return t
+}
+
+// tenantIndex resolves requests to tenants.
+type tenantIndex struct {
+	tenants []*Tenant
+	pooled  []*Tenant
+	keys    map[string]*Tenant
+	hosts   map[string]*Tenant
+}
+
+// newTenantIndex validates tenants and indexes them by key and host. Returns nil without tenants.
+func newTenantIndex(tenants []Tenant) (*tenantIndex, error) {
+	if len(tenants) == 0 {
+		return nil, nil
+	}
+	idx := &tenantIndex{keys: make(map[string]*Tenant), hosts: make(map[string]*Tenant)}
+	names := make(map[string]bool, len(tenants))
+	for i := range tenants {
+		t := &tenants[i]
+		if t.Name == "" {
+			return nil, fmt.Errorf("tenant #%d: name cannot be empty", i+1)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("tenant %s: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		idx.tenants = append(idx.tenants, t)
+		if t.TokenSource == nil {
+			return nil, fmt.Errorf("tenant %s: token source required", t.Name)
+		}
+		if len(t.Keys) == 0 && len(t.Hosts) == 0 && !t.Pool {
+			return nil, fmt.Errorf("tenant %s: at least one key or host required", t.Name)
+		}
+		if t.Pool {
+			idx.pooled = append(idx.pooled, t)
+		}
+		for _, key := range t.Keys {
+			if other, exists := idx.keys[key]; exists {
+				return nil, fmt.Errorf("tenant %s: key already used by tenant %s", t.Name, other.Name)
+			}
+			idx.keys[key] = t
+		}
+		for _, host := range t.Hosts {
+			host = strings.ToLower(host)
+			if other, exists := idx.hosts[host]; exists {
+				return nil, fmt.Errorf("tenant %s: host %q already used by tenant %s", t.Name, host, other.Name)
+			}
+			idx.hosts[host] = t
+		}
+	}
+	return idx, nil
+}
+
+// match returns the tenant for r. Virtual keys take precedence over hosts.
+func (idx *tenantIndex) match(r *http.Request) *Tenant {
+	if key := usage.Credential(r); key != "" {
+		if t, ok := idx.keys[key]; ok {
+			return t
+		}
+		if t, ok := idx.keys[usage.KeyID(r)]; ok {
+			return t
+		}
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return idx.hosts[strings.ToLower(host)]
+}
+
+// tenantName returns the name of the tenant selected for r, or "" for the default account.
+func tenantName(r *http.Request) string {
+	if t := tenantFromContext(r.Context()); t != nil {
+		return t.Name
+	}
+	return ""
+}
+
+// selectTenant stores the request's tenant in the context and rewrites model aliases.
+func selectTenant(idx *tenantIndex) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if idx == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := idx.match(r)
+			if t == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			middleware.SetLogAttrs(r.Context(), slog.String("tenant", t.Name))
+			r = r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, t))
+			// File uploads name no model and may exceed reqbody.MaxSize
+			multipart := strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/")
+			if len(t.ModelAliases) == 0 || r.Method != http.MethodPost || multipart {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var fields struct {
+				Model string `json:"model"`
+			}
+			if json.Unmarshal(body, &fields) == nil {
+				if model, ok := t.ModelAliases[fields.Model]; ok {
+					if rewritten, ok := setModel(body, model); ok {
+						reqbody.Replace(r, rewritten)
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TenantTransport is an http.RoundTripper that sends requests of a tenant (see
+// selectTenant) through that tenant's transport and all other requests through Default.
+type TenantTransport struct {
+	Default http.RoundTripper
+	Tenants map[*Tenant]http.RoundTripper
+}
+
+// Compile-time check that TenantTransport implements http.RoundTripper.
+var _ http.RoundTripper = (*TenantTransport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *TenantTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tenant := tenantFromContext(req.Context())
+	base, ok := t.Tenants[tenant]
+	if tenant == nil || !ok {
+		return t.Default.RoundTrip(req)
+	}
+
+	// Virtual keys authenticate against the proxy only
+	newReq := req.Clone(req.Context())
+	newReq.Header.Del("X-Api-Key")
+	newReq.Header.Del("Api-Key")
+	return base.RoundTrip(newReq)
+}
+
+// tenantTransports builds one transport per tenant using its token source.
+func tenantTransports(idx *tenantIndex, build func(t *Tenant) http.RoundTripper) map[*Tenant]http.RoundTripper {
+	if idx == nil {
+		return nil
+	}
+	transports := make(map[*Tenant]http.RoundTripper, len(idx.tenants))
+	for _, t := range idx.tenants {
+		transports[t] = build(t)
+	}
+	return transports
+}
@@ -0,0 +1,152 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/cache"
+)
+
+func TestTenants(t *testing.T) {
+	var gotAuth, gotModel string
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		gotAuth = r.Header.Get("Authorization")
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel = body.Model
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{}`)),
+			Request:    r,
+		}, nil
+	})
+
+	p, err := New(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "default-token"}), readyChecker{},
+		WithTransport(upstream),
+		WithTenants(
+			Tenant{
+				Name:         "acme",
+				Keys:         []string{"vk-acme"},
+				TokenSource:  oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "acme-token"}),
+				ModelAliases: map[string]string{"fast": "claude-haiku-4-5"},
+			},
+			Tenant{
+				Name:        "globex",
+				Hosts:       []string{"globex.example.com"},
+				TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "globex-token"}),
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		host      string
+		key       string
+		model     string
+		wantAuth  string
+		wantModel string
+	}{
+		{"virtual key", "localhost:4000", "vk-acme", "claude-sonnet-4-5", "Bearer acme-token", "claude-sonnet-4-5"},
+		{"model alias", "localhost:4000", "vk-acme", "fast", "Bearer acme-token", "claude-haiku-4-5"},
+		{"host", "globex.example.com:4000", "claudine", "fast", "Bearer globex-token", "fast"},
+		{"key wins over host", "globex.example.com", "vk-acme", "fast", "Bearer acme-token", "claude-haiku-4-5"},
+		{"default account", "localhost:4000", "claudine", "fast", "Bearer default-token", "fast"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"`+tt.model+`","max_tokens":1}`))
+			req.Host = tt.host
+			req.Header.Set("X-Api-Key", tt.key)
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", gotAuth, tt.wantAuth)
+			}
+			if gotModel != tt.wantModel {
+				t.Errorf("model = %q, want %q", gotModel, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestTenantsValidation(t *testing.T) {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+	tests := []struct {
+		name    string
+		tenants []Tenant
+		wantErr string
+	}{
+		{"no selector", []Tenant{{Name: "a", TokenSource: ts}}, "at least one key or host"},
+		{"no token source", []Tenant{{Name: "a", Keys: []string{"k"}}}, "token source required"},
+		{"duplicate key", []Tenant{{Name: "a", Keys: []string{"k"}, TokenSource: ts}, {Name: "b", Keys: []string{"k"}, TokenSource: ts}}, "already used by tenant a"},
+		{"duplicate host", []Tenant{{Name: "a", Hosts: []string{"X.example"}, TokenSource: ts}, {Name: "b", Hosts: []string{"x.example"}, TokenSource: ts}}, "already used by tenant a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(ts, readyChecker{}, WithTenants(tt.tenants...))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTenantsDoNotShareCache(t *testing.T) {
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"model":"m","content":"` + r.Header.Get("Authorization") + `"}`)),
+			Request:    r,
+		}, nil
+	})
+
+	p, err := New(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "default-token"}), readyChecker{},
+		WithTransport(upstream),
+		WithCache(cache.NewMemory(10), time.Minute),
+		WithTenants(
+			Tenant{Name: "acme", Hosts: []string{"acme.example.com"}, TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "acme-token"})},
+			Tenant{Name: "globex", Hosts: []string{"globex.example.com"}, TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "globex-token"})},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ host, wantCache, wantToken string }{
+		{"acme.example.com", "MISS", "acme-token"},
+		{"globex.example.com", "MISS", "globex-token"},
+		{"acme.example.com", "HIT", "acme-token"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m","max_tokens":1}`))
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get(cache.HeaderCache); got != tt.wantCache {
+			t.Errorf("%s: %s = %q, want %q", tt.host, cache.HeaderCache, got, tt.wantCache)
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantToken) {
+			t.Errorf("%s: body = %s, want response of %s", tt.host, rec.Body, tt.wantToken)
+		}
+	}
+}
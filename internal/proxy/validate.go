@@ -0,0 +1,385 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/json/jsontext"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+)
+
+// WithMessagesValidation checks POST /v1/messages bodies against the Messages request
+// schema before forwarding and rejects malformed requests with Anthropic-shaped 400s,
+// so they don't consume upstream rate limits.
+func WithMessagesValidation(enabled bool) Option {
+	return func(c *config) {
+		c.validateMessages = enabled
+	}
+}
+
+// validateMessages rejects Messages requests violating the request schema.
+func validateMessages(enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := validateMessagesRequest(body); err != nil {
+				slog.InfoContext(r.Context(), "rejected invalid messages request", "error", err)
+				writeAnthropicErrorStatus(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// errInvalidBody reports a body that is not a JSON object.
+var errInvalidBody = errors.New("invalid request body: expected a JSON object")
+
+// schemaError is a violation of the Messages request schema, worded like
+// Anthropic's own validation errors: "messages.0.role: Field required".
+type schemaError struct {
+	path    string
+	message string
+}
+
+func (e *schemaError) Error() string {
+	return e.path + ": " + e.message
+}
+
+// schemaCheck validates the value at path.
+type schemaCheck func(path string, v jsontext.Value) error
+
+// validateMessagesRequest checks body against the Anthropic Messages request schema.
+// Unknown fields are accepted, as beta features keep adding new ones.
+func validateMessagesRequest(body []byte) error {
+	if jsontext.Value(body).Kind() != '{' {
+		return errInvalidBody
+	}
+	req, err := decodeObject(body)
+	if err != nil {
+		return errInvalidBody
+	}
+
+	return checkFields(req, "", map[string]fieldSchema{
+		"model":          {required: true, check: isString},
+		"max_tokens":     {required: true, check: isInteger(1)},
+		"messages":       {required: true, check: isNonEmptyArray(isMessage)},
+		"system":         {check: anyOf(isString, isArray(isContentBlock))},
+		"temperature":    {check: isNumber(0, 1)},
+		"top_p":          {check: isNumber(0, 1)},
+		"top_k":          {check: isInteger(0)},
+		"stop_sequences": {check: isArray(isString)},
+		"stream":         {check: isBool},
+		"metadata":       {check: isObject},
+		"tools":          {check: isArray(isTool)},
+		"tool_choice":    {check: isToolChoice},
+		"thinking":       {check: isThinking},
+		"container":      {check: anyOf(isString, isObject)}, // ID or container with skills (beta)
+	})
+}
+
+// fieldSchema describes one field of an object.
+type fieldSchema struct {
+	required bool
+	check    schemaCheck
+}
+
+// checkFields validates the fields of an object at path against schema.
+// Fields are checked in name order so errors are deterministic.
+func checkFields(obj map[string]json.RawMessage, path string, schema map[string]fieldSchema) error {
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		field := schema[name]
+		fieldPath := joinPath(path, name)
+		v, ok := obj[name]
+		if !ok {
+			if field.required {
+				return &schemaError{fieldPath, "Field required"}
+			}
+			continue
+		}
+		if err := field.check(fieldPath, jsontext.Value(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// decodeObject decodes the fields of a JSON object.
+func decodeObject(v jsontext.Value) (map[string]json.RawMessage, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(v, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func isString(path string, v jsontext.Value) error {
+	if v.Kind() != '"' {
+		return &schemaError{path, "Input should be a valid string"}
+	}
+	return nil
+}
+
+func isBool(path string, v jsontext.Value) error {
+	if k := v.Kind(); k != 't' && k != 'f' {
+		return &schemaError{path, "Input should be a valid boolean"}
+	}
+	return nil
+}
+
+func isObject(path string, v jsontext.Value) error {
+	if v.Kind() != '{' {
+		return &schemaError{path, "Input should be a valid dictionary"}
+	}
+	return nil
+}
+
+// isNumber checks for a number within [min, max].
+func isNumber(min, max float64) schemaCheck {
+	return func(path string, v jsontext.Value) error {
+		n, err := number(path, v)
+		if err != nil {
+			return err
+		}
+		if n < min {
+			return &schemaError{path, "Input should be greater than or equal to " + formatNumber(min)}
+		}
+		if n > max {
+			return &schemaError{path, "Input should be less than or equal to " + formatNumber(max)}
+		}
+		return nil
+	}
+}
+
+// isInteger checks for a whole number of at least min.
+func isInteger(min float64) schemaCheck {
+	return func(path string, v jsontext.Value) error {
+		n, err := number(path, v)
+		if err != nil || n != math.Trunc(n) {
+			return &schemaError{path, "Input should be a valid integer"}
+		}
+		if n < min {
+			return &schemaError{path, "Input should be greater than or equal to " + formatNumber(min)}
+		}
+		return nil
+	}
+}
+
+func number(path string, v jsontext.Value) (float64, error) {
+	if v.Kind() != '0' {
+		return 0, &schemaError{path, "Input should be a valid number"}
+	}
+	n, err := strconv.ParseFloat(string(bytes.TrimSpace(v)), 64)
+	if err != nil {
+		return 0, &schemaError{path, "Input should be a valid number"}
+	}
+	return n, nil
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// isArray checks for an array whose elements pass elem.
+func isArray(elem schemaCheck) schemaCheck {
+	return isArrayMin(elem, 0)
+}
+
+// isNonEmptyArray checks for an array with at least one element, all passing elem.
+func isNonEmptyArray(elem schemaCheck) schemaCheck {
+	return isArrayMin(elem, 1)
+}
+
+func isArrayMin(elem schemaCheck, minItems int) schemaCheck {
+	return func(path string, v jsontext.Value) error {
+		var elems []json.RawMessage
+		if v.Kind() != '[' || json.Unmarshal(v, &elems) != nil {
+			return &schemaError{path, "Input should be a valid list"}
+		}
+		if len(elems) < minItems {
+			return &schemaError{path, "List should have at least " + strconv.Itoa(minItems) + " item"}
+		}
+		for i, e := range elems {
+			if err := elem(path+"."+strconv.Itoa(i), jsontext.Value(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// anyOf passes values passing one of checks, reporting the last failure otherwise.
+func anyOf(checks ...schemaCheck) schemaCheck {
+	return func(path string, v jsontext.Value) error {
+		var err error
+		for _, check := range checks {
+			if err = check(path, v); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// isEnum checks for one of the string values.
+func isEnum(values ...string) schemaCheck {
+	return func(path string, v jsontext.Value) error {
+		var s string
+		if v.Kind() == '"' && json.Unmarshal(v, &s) == nil && slices.Contains(values, s) {
+			return nil
+		}
+		quoted := make([]string, len(values))
+		for i, value := range values {
+			quoted[i] = "'" + value + "'"
+		}
+		last := len(quoted) - 1
+		expected := quoted[last]
+		if last > 0 {
+			expected = strings.Join(quoted[:last], ", ") + " or " + quoted[last]
+		}
+		return &schemaError{path, "Input should be " + expected}
+	}
+}
+
+// isTypedObject checks for an object, then its fields against the schema selected
+// by its "type" field. Types without an entry only need a string "type".
+func isTypedObject(schemas map[string]map[string]fieldSchema) schemaCheck {
+	return func(path string, v jsontext.Value) error {
+		if err := isObject(path, v); err != nil {
+			return err
+		}
+		obj, err := decodeObject(v)
+		if err != nil {
+			return &schemaError{path, "Input should be a valid dictionary"}
+		}
+		raw, ok := obj["type"]
+		if !ok {
+			return &schemaError{joinPath(path, "type"), "Field required"}
+		}
+		var typ string
+		if jsontext.Value(raw).Kind() != '"' || json.Unmarshal(raw, &typ) != nil {
+			return &schemaError{joinPath(path, "type"), "Input should be a valid string"}
+		}
+		return checkFields(obj, path, schemas[typ])
+	}
+}
+
+func isMessage(path string, v jsontext.Value) error {
+	if err := isObject(path, v); err != nil {
+		return err
+	}
+	msg, err := decodeObject(v)
+	if err != nil {
+		return &schemaError{path, "Input should be a valid dictionary"}
+	}
+	return checkFields(msg, path, map[string]fieldSchema{
+		"role":    {required: true, check: isEnum("user", "assistant")},
+		"content": {required: true, check: anyOf(isString, isArray(isContentBlock))},
+	})
+}
+
+// isContentBlock checks the fields of the content block types agents commonly get
+// wrong. Other block types are passed to upstream as is.
+func isContentBlock(path string, v jsontext.Value) error {
+	return isTypedObject(map[string]map[string]fieldSchema{
+		"text": {
+			"text": {required: true, check: isString},
+		},
+		"image": {
+			"source": {required: true, check: isObject},
+		},
+		"document": {
+			"source": {required: true, check: isObject},
+		},
+		"tool_use": {
+			"id":    {required: true, check: isString},
+			"name":  {required: true, check: isString},
+			"input": {required: true, check: isObject},
+		},
+		"tool_result": {
+			"tool_use_id": {required: true, check: isString},
+			"content":     {check: anyOf(isString, isArray(isContentBlock))},
+			"is_error":    {check: isBool},
+		},
+		"thinking": {
+			"thinking":  {required: true, check: isString},
+			"signature": {required: true, check: isString},
+		},
+	})(path, v)
+}
+
+// isTool checks custom tool definitions. Server tools carry a type other than
+// "custom" and define their own fields.
+func isTool(path string, v jsontext.Value) error {
+	if err := isObject(path, v); err != nil {
+		return err
+	}
+	tool, err := decodeObject(v)
+	if err != nil {
+		return &schemaError{path, "Input should be a valid dictionary"}
+	}
+	schema := map[string]fieldSchema{
+		"name": {required: true, check: isString},
+	}
+	var typ string
+	if raw, ok := tool["type"]; !ok || (json.Unmarshal(raw, &typ) == nil && typ == "custom") {
+		schema["input_schema"] = fieldSchema{required: true, check: isObject}
+	}
+	return checkFields(tool, path, schema)
+}
+
+func isToolChoice(path string, v jsontext.Value) error {
+	if err := isTypedObject(map[string]map[string]fieldSchema{
+		"tool": {
+			"name": {required: true, check: isString},
+		},
+	})(path, v); err != nil {
+		return err
+	}
+	obj, _ := decodeObject(v)
+	return isEnum("auto", "any", "tool", "none")(joinPath(path, "type"), jsontext.Value(obj["type"]))
+}
+
+func isThinking(path string, v jsontext.Value) error {
+	if err := isTypedObject(map[string]map[string]fieldSchema{
+		"enabled": {
+			"budget_tokens": {required: true, check: isInteger(1024)},
+		},
+	})(path, v); err != nil {
+		return err
+	}
+	obj, _ := decodeObject(v)
+	return isEnum("enabled", "disabled")(joinPath(path, "type"), jsontext.Value(obj["type"]))
+}
@@ -0,0 +1,120 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateMessagesRequest(t *testing.T) {
+	const msgs = `"messages": [{"role": "user", "content": "Hi"}]`
+
+	tests := []struct {
+		name string
+		body string
+		want string // error message, empty for valid requests
+	}{
+		{"minimal", `{"model": "claude-sonnet-4-0", "max_tokens": 1024, ` + msgs + `}`, ""},
+		{
+			name: "full",
+			body: `{
+				"model": "claude-sonnet-4-0", "max_tokens": 4096, "stream": true,
+				"system": [{"type": "text", "text": "Be brief.", "cache_control": {"type": "ephemeral"}}],
+				"messages": [
+					{"role": "user", "content": [{"type": "text", "text": "Weather?"}, {"type": "image", "source": {"type": "base64"}}]},
+					{"role": "assistant", "content": [{"type": "tool_use", "id": "tu_1", "name": "weather", "input": {}}]},
+					{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "tu_1", "content": [{"type": "text", "text": "Sunny"}]}]}
+				],
+				"tools": [
+					{"name": "weather", "input_schema": {"type": "object"}},
+					{"type": "web_search_20250305", "name": "web_search", "max_uses": 5},
+					{"type": "code_execution_20250825", "name": "code_execution"}
+				],
+				"container": {"skills": [{"type": "anthropic", "skill_id": "pptx", "version": "latest"}]},
+				"tool_choice": {"type": "tool", "name": "weather"},
+				"thinking": {"type": "enabled", "budget_tokens": 2048},
+				"temperature": 1, "top_k": 5, "stop_sequences": ["END"], "metadata": {"user_id": "u"},
+				"context_management": {"edits": []}
+			}`,
+			want: "",
+		},
+		{"not an object", `[1, 2]`, "invalid request body: expected a JSON object"},
+		{"malformed", `{"model": `, "invalid request body: expected a JSON object"},
+		{"missing model", `{"max_tokens": 1024, ` + msgs + `}`, "model: Field required"},
+		{"missing max_tokens", `{"model": "m", ` + msgs + `}`, "max_tokens: Field required"},
+		{"fractional max_tokens", `{"model": "m", "max_tokens": 1.5, ` + msgs + `}`, "max_tokens: Input should be a valid integer"},
+		{"zero max_tokens", `{"model": "m", "max_tokens": 0, ` + msgs + `}`, "max_tokens: Input should be greater than or equal to 1"},
+		{"empty messages", `{"model": "m", "max_tokens": 1, "messages": []}`, "messages: List should have at least 1 item"},
+		{"invalid role", `{"model": "m", "max_tokens": 1, "messages": [{"role": "system", "content": "Hi"}]}`, "messages.0.role: Input should be 'user' or 'assistant'"},
+		{"missing content", `{"model": "m", "max_tokens": 1, "messages": [{"role": "user"}]}`, "messages.0.content: Field required"},
+		{"content block without type", `{"model": "m", "max_tokens": 1, "messages": [{"role": "user", "content": [{"text": "Hi"}]}]}`, "messages.0.content.0.type: Field required"},
+		{"text block without text", `{"model": "m", "max_tokens": 1, "messages": [{"role": "user", "content": [{"type": "text"}]}]}`, "messages.0.content.0.text: Field required"},
+		{"tool_use input not object", `{"model": "m", "max_tokens": 1, "messages": [{"role": "assistant", "content": [{"type": "tool_use", "id": "a", "name": "b", "input": "{}"}]}]}`, "messages.0.content.0.input: Input should be a valid dictionary"},
+		{"temperature out of range", `{"model": "m", "max_tokens": 1, "temperature": 1.5, ` + msgs + `}`, "temperature: Input should be less than or equal to 1"},
+		{"stream not bool", `{"model": "m", "max_tokens": 1, "stream": "true", ` + msgs + `}`, "stream: Input should be a valid boolean"},
+		{"custom tool without schema", `{"model": "m", "max_tokens": 1, "tools": [{"name": "t"}], ` + msgs + `}`, "tools.0.input_schema: Field required"},
+		{"unknown tool_choice", `{"model": "m", "max_tokens": 1, "tool_choice": {"type": "required"}, ` + msgs + `}`, "tool_choice.type: Input should be 'auto', 'any', 'tool' or 'none'"},
+		{"container not an object", `{"model": "m", "max_tokens": 1, "container": 1, ` + msgs + `}`, "container: Input should be a valid dictionary"},
+		{"small thinking budget", `{"model": "m", "max_tokens": 1, "thinking": {"type": "enabled", "budget_tokens": 100}, ` + msgs + `}`, "thinking.budget_tokens: Input should be greater than or equal to 1024"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMessagesRequest([]byte(tt.body))
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("validateMessagesRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	var forwarded string
+	handler := validateMessages(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwarded = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("valid request forwarded", func(t *testing.T) {
+		body := `{"model": "m", "max_tokens": 1, "messages": [{"role": "user", "content": "Hi"}]}`
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if forwarded != body {
+			t.Errorf("forwarded body = %q, want %q", forwarded, body)
+		}
+	})
+
+	t.Run("invalid request rejected", func(t *testing.T) {
+		forwarded = ""
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model": "m"}`)))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+		if forwarded != "" {
+			t.Error("invalid request was forwarded")
+		}
+		var resp anthropicErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid error body: %v", err)
+		}
+		if resp.Type != "error" || resp.Error.Type != "invalid_request_error" || resp.Error.Message != "max_tokens: Field required" {
+			t.Errorf("error = %+v", resp)
+		}
+	})
+}
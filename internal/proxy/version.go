@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultAnthropicVersion is the Anthropic-Version header sent upstream unless configured otherwise.
+const DefaultAnthropicVersion = "2023-06-01"
+
+// KnownAnthropicVersions are the Anthropic API versions this release was tested with.
+var KnownAnthropicVersions = []string{"2023-06-01"}
+
+// AnthropicVersions selects the Anthropic-Version header sent upstream by path.
+type AnthropicVersions struct {
+	// Default applies to paths no route matches. Empty means DefaultAnthropicVersion.
+	Default string
+
+	// Routes maps upstream path patterns to versions. Patterns match like
+	// WithPassthrough patterns; the longest matching pattern wins.
+	Routes map[string]string
+}
+
+// For returns the version to send for a request to path. A nil receiver returns
+// DefaultAnthropicVersion.
+func (v *AnthropicVersions) For(path string) string {
+	if v == nil {
+		return DefaultAnthropicVersion
+	}
+	version, matched := v.Default, ""
+	for pattern, routeVersion := range v.Routes {
+		if len(pattern) > len(matched) && matchPattern(pattern, path) {
+			version, matched = routeVersion, pattern
+		}
+	}
+	if version == "" {
+		return DefaultAnthropicVersion
+	}
+	return version
+}
+
+// ValidateAnthropicVersion checks that version is a date-formatted API version the
+// proxy can translate: DefaultAnthropicVersion or later, as earlier versions use
+// another streaming format. Versions not in KnownAnthropicVersions pass, so new
+// versions can be adopted without an update.
+func ValidateAnthropicVersion(version string) error {
+	date, err := time.Parse(time.DateOnly, version)
+	if err != nil {
+		return fmt.Errorf("invalid Anthropic API version %q: want a date like %s", version, DefaultAnthropicVersion)
+	}
+	if minimum, _ := time.Parse(time.DateOnly, DefaultAnthropicVersion); date.Before(minimum) {
+		return fmt.Errorf("unsupported Anthropic API version %q: %s or later required", version, DefaultAnthropicVersion)
+	}
+	return nil
+}
+
+// KnownAnthropicVersion reports whether version is in KnownAnthropicVersions.
+func KnownAnthropicVersion(version string) bool {
+	return slices.Contains(KnownAnthropicVersions, version)
+}
+
+// matchPattern reports whether path matches pattern: its exact path, or with a
+// trailing "/*" additionally every path below it.
+func matchPattern(pattern, path string) bool {
+	prefix, wildcard := strings.CutSuffix(pattern, "/*")
+	return path == pattern || wildcard && (path == prefix || strings.HasPrefix(path, prefix+"/"))
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import "testing"
+
+func TestAnthropicVersionsFor(t *testing.T) {
+	versions := &AnthropicVersions{
+		Default: "2025-01-01",
+		Routes: map[string]string{
+			"/v1/messages/*":            "2025-02-01",
+			"/v1/messages/count_tokens": "2025-03-01",
+		},
+	}
+
+	tests := []struct {
+		versions *AnthropicVersions
+		path     string
+		want     string
+	}{
+		{nil, "/v1/messages", DefaultAnthropicVersion},
+		{&AnthropicVersions{}, "/v1/messages", DefaultAnthropicVersion},
+		{versions, "/v1/files", "2025-01-01"},
+		{versions, "/v1/messages", "2025-02-01"},
+		{versions, "/v1/messages/batches", "2025-02-01"},
+		{versions, "/v1/messages/count_tokens", "2025-03-01"},
+	}
+	for _, tt := range tests {
+		if got := tt.versions.For(tt.path); got != tt.want {
+			t.Errorf("For(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAnthropicVersion(t *testing.T) {
+	for version, valid := range map[string]bool{
+		"2023-06-01": true,
+		"2026-01-15": true,
+		"2023-01-01": false,
+		"2023-6-1":   false,
+		"latest":     false,
+	} {
+		if err := ValidateAnthropicVersion(version); (err == nil) != valid {
+			t.Errorf("ValidateAnthropicVersion(%q) = %v, want valid %v", version, err, valid)
+		}
+	}
+}
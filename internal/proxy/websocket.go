@@ -0,0 +1,116 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/florianilch/claudine-proxy/internal/inproc"
+)
+
+// websocketMaxPayload matches the request size limit of the chat completions route.
+const websocketMaxPayload = 31 << 20
+
+// websocketHeaders belong to the upgrade and are not passed to chat completion requests.
+var websocketHeaders = []string{
+	"Connection",
+	"Upgrade",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+	"Sec-Websocket-Protocol",
+}
+
+// WithWebSocketOrigins allows browser pages from origins (e.g.,
+// "https://app.example.com") to stream chat completions over WebSocket. Browsers
+// don't apply CORS to WebSockets, so without this, upgrades with an Origin header
+// are rejected to keep other sites from using the subscription.
+func WithWebSocketOrigins(origins ...string) Option {
+	return func(c *config) {
+		c.websocketOrigins = append(c.websocketOrigins, origins...)
+	}
+}
+
+// websocketHandler streams chat completions over WebSocket, for environments that
+// handle it better than SSE through intermediaries. Each text message is a chat
+// completion request, handled by chat, the handler of the POST route, with the
+// headers of the upgrade request. Its streamed response is sent as one text message
+// per SSE data payload: chunks, then "[DONE]", or an OpenAI error object on failure.
+// Requests on a connection are handled in order.
+func websocketHandler(chat http.Handler, path string, origins []string) http.Handler {
+	return websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			if origin := r.Header.Get("Origin"); origin != "" && !slices.Contains(origins, origin) {
+				return fmt.Errorf("origin %q not allowed", origin)
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			conn.MaxPayloadBytes = websocketMaxPayload
+			ctx := conn.Request().Context()
+			for {
+				var message []byte
+				if err := websocket.Message.Receive(conn, &message); err != nil {
+					if !errors.Is(err, io.EOF) {
+						slog.DebugContext(ctx, "websocket closed", "error", err)
+					}
+					return
+				}
+				if err := serveWebSocketRequest(conn, chat, path, message); err != nil {
+					slog.DebugContext(ctx, "websocket send failed", "error", err)
+					return
+				}
+			}
+		},
+	}
+}
+
+// serveWebSocketRequest answers a chat completion request received on conn.
+func serveWebSocketRequest(conn *websocket.Conn, chat http.Handler, path string, message []byte) error {
+	upgrade := conn.Request()
+	req, err := http.NewRequestWithContext(upgrade.Context(), http.MethodPost, path, bytes.NewReader(streamingBody(message)))
+	if err != nil {
+		return err
+	}
+	req.Header = upgrade.Header.Clone()
+	for _, h := range websocketHeaders {
+		req.Header.Del(h)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Del("Accept") // streams are read as SSE
+	req.RemoteAddr = upgrade.RemoteAddr
+
+	w := inproc.NewEventWriter(func(_, data string) error {
+		return websocket.Message.Send(conn, data)
+	})
+	chat.ServeHTTP(w, req)
+	if w.Status() != http.StatusOK {
+		// Error responses carry an OpenAI error object, like error events
+		return websocket.Message.Send(conn, string(bytes.TrimSpace(w.Body())))
+	}
+	return w.Finish()
+}
+
+// streamingBody sets "stream" in a chat completion request. Bodies that are not a
+// JSON object are returned as-is for the chat completions handler to reject.
+func streamingBody(message []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil || fields == nil {
+		return message
+	}
+	fields["stream"] = json.RawMessage("true")
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return message
+	}
+	return body
+}
@@ -0,0 +1,109 @@
+//go:build goexperiment.jsonv2
+
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+	"golang.org/x/oauth2"
+)
+
+func TestWebSocketChatCompletions(t *testing.T) {
+	upstream := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		if !bytes.Contains(body, []byte(`"stream":true`)) {
+			t.Errorf("upstream request not streaming: %s", body)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(recordSSE)),
+			Request:    r,
+		}, nil
+	})
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+	p, err := New(ts, readyChecker{}, WithTransport(upstream), WithWebSocketOrigins("https://app.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(p)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/chat/completions"
+
+	tests := []struct {
+		name    string
+		origin  string
+		request string
+		want    []string // substrings of the frames, in order
+		wantErr bool
+	}{
+		{
+			name:    "chunks",
+			origin:  "https://app.example.com",
+			request: `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`,
+			want:    []string{`"role":"assistant"`, `"content":"hello"`, `"finish_reason":"stop"`, "[DONE]"},
+		},
+		{
+			name:    "stream forced",
+			origin:  "https://app.example.com",
+			request: `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}],"stream":false}`,
+			want:    []string{`"role":"assistant"`, `"content":"hello"`, `"finish_reason":"stop"`, "[DONE]"},
+		},
+		{
+			name:    "invalid request",
+			origin:  "https://app.example.com",
+			request: `not json`,
+			want:    []string{`"error":`},
+		},
+		{
+			name:    "foreign origin",
+			origin:  "https://evil.example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := websocket.Dial(url, "", tt.origin)
+			if tt.wantErr {
+				if err == nil {
+					_ = conn.Close()
+					t.Fatal("expected handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = conn.Close() }()
+
+			if err := websocket.Message.Send(conn, tt.request); err != nil {
+				t.Fatal(err)
+			}
+			var frames []string
+			for {
+				var frame string
+				if err := websocket.Message.Receive(conn, &frame); err != nil {
+					t.Fatalf("receive after %q: %v", frames, err)
+				}
+				frames = append(frames, frame)
+				if frame == "[DONE]" || strings.Contains(frame, `"error":`) {
+					break
+				}
+			}
+			all := strings.Join(frames, "\n")
+			for _, want := range tt.want {
+				i := strings.Index(all, want)
+				if i < 0 {
+					t.Fatalf("frames %q missing %q", frames, want)
+				}
+				all = all[i+len(want):]
+			}
+		})
+	}
+}
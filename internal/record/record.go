@@ -0,0 +1,307 @@
+// Package record captures OpenAI chat completion turns passing through the proxy
+// and writes them in the golden fixture format of the adapter tests
+// (pkg/openaiadapter/anthropicclaude/testdata), so a reproduced bug becomes
+// a regression test by copying a file.
+//
+// Middleware captures what the adapter receives and returns, Transport what it
+// exchanges with Anthropic. Credentials are scrubbed like log output.
+package record
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/florianilch/claudine-proxy/internal/observability"
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+)
+
+// Turn is a non-streaming request-response cycle (testdata/buffered).
+type Turn struct {
+	OpenAIRequest           json.RawMessage `json:"openaiRequest"`
+	AnthropicRequest        json.RawMessage `json:"anthropicRequest"`
+	AnthropicResponse       json.RawMessage `json:"anthropicResponse"`
+	AnthropicResponseStatus int             `json:"anthropicResponseStatus,omitempty"`
+	OpenAIResponse          json.RawMessage `json:"openaiResponse"`
+}
+
+// StreamingTurn is a streaming request-response cycle (testdata/streaming).
+type StreamingTurn struct {
+	OpenAIRequest    json.RawMessage   `json:"openaiRequest"`
+	AnthropicRequest json.RawMessage   `json:"anthropicRequest"`
+	AnthropicSSE     []string          `json:"anthropicSSE"`
+	OpenAIChunks     []json.RawMessage `json:"openaiChunks"`
+}
+
+// Recorder collects the turns of a session in order.
+type Recorder struct {
+	mu        sync.Mutex
+	buffered  []Turn
+	streaming []StreamingTurn
+}
+
+// New creates an empty Recorder.
+func New() *Recorder {
+	return &Recorder{}
+}
+
+// Len returns the number of recorded turns.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.buffered) + len(r.streaming)
+}
+
+// WriteFixtures writes buffered turns to dir/buffered/name.json and streaming turns
+// to dir/streaming/name_stream.json, skipping empty ones. Existing files are not
+// overwritten. Returns the paths written.
+func (r *Recorder) WriteFixtures(dir, name string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var paths []string
+	if len(r.buffered) > 0 {
+		path := filepath.Join(dir, "buffered", name+".json")
+		if err := writeFixture(path, r.buffered); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	if len(r.streaming) > 0 {
+		path := filepath.Join(dir, "streaming", name+"_stream.json")
+		if err := writeFixture(path, r.streaming); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func writeFixture(path string, turns any) error {
+	data, err := json.MarshalIndent(turns, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create fixture: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	return errors.Join(err, f.Close())
+}
+
+// exchange is the upstream side of a turn, filled in by Transport.
+type exchange struct {
+	mu          sync.Mutex
+	request     []byte
+	status      int
+	contentType string
+	response    bytes.Buffer
+}
+
+type exchangeKey struct{}
+
+// Middleware records each request handled by next as a turn of r.
+// A nil Recorder disables recording.
+func Middleware(r *Recorder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if r == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req, body, err := reqbody.Read(req)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			ex := &exchange{}
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, req.WithContext(context.WithValue(req.Context(), exchangeKey{}, ex)))
+			r.add(body, ex, rec)
+		})
+	}
+}
+
+// add converts a captured exchange into a turn.
+func (r *Recorder) add(openaiRequest []byte, ex *exchange, rec *responseRecorder) {
+	var payload struct {
+		Stream bool `json:"stream"`
+	}
+	_ = json.Unmarshal(openaiRequest, &payload)
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	anthropicRequest := rawJSON(ex.request)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !payload.Stream {
+		turn := Turn{
+			OpenAIRequest:     rawJSON(openaiRequest),
+			AnthropicRequest:  anthropicRequest,
+			AnthropicResponse: rawJSON(ex.response.Bytes()),
+			OpenAIResponse:    rawJSON(rec.buf.Bytes()),
+		}
+		if ex.status != http.StatusOK {
+			turn.AnthropicResponseStatus = ex.status
+		}
+		r.buffered = append(r.buffered, turn)
+		return
+	}
+
+	r.streaming = append(r.streaming, StreamingTurn{
+		OpenAIRequest:    rawJSON(openaiRequest),
+		AnthropicRequest: anthropicRequest,
+		AnthropicSSE:     anthropicSSE(ex),
+		OpenAIChunks:     openAIChunks(rec),
+	})
+}
+
+// anthropicSSE splits the upstream stream into lines. Streaming fixtures replay
+// with status 200, so an error response becomes an SSE error event, which the
+// adapter maps the same way.
+func anthropicSSE(ex *exchange) []string {
+	body := observability.Scrub(ex.response.String())
+	if ex.status == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(ex.contentType)
+	if ex.status != http.StatusOK || mediaType != "text/event-stream" {
+		var compact bytes.Buffer
+		if json.Compact(&compact, []byte(body)) == nil {
+			body = compact.String()
+		}
+		return []string{"event: error", "data: " + body, "", ""}
+	}
+	return strings.Split(body, "\n")
+}
+
+// openAIChunks extracts the data of each SSE event sent to the client. Errors
+// returned before the stream started are a single JSON body.
+func openAIChunks(rec *responseRecorder) []json.RawMessage {
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		return []json.RawMessage{rawJSON(rec.buf.Bytes())}
+	}
+
+	var chunks []json.RawMessage
+	for line := range strings.Lines(rec.buf.String()) {
+		data, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		chunks = append(chunks, rawJSON([]byte(data)))
+	}
+	return chunks
+}
+
+// rawJSON scrubs credentials from data and returns it as JSON, or nil (null) if
+// there is none. Invalid JSON is kept as a string so the turn can be inspected.
+func rawJSON(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	scrubbed := []byte(observability.Scrub(string(data)))
+	if json.Valid(scrubbed) {
+		return scrubbed
+	}
+	quoted, _ := json.Marshal(string(scrubbed))
+	return quoted
+}
+
+// Transport records the upstream request and response of the turn in the request
+// context. Place it directly under the adapter so requests are recorded as the
+// adapter built them, before authentication and impersonation. When the adapter
+// retries, the last attempt is kept.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// Compile-time check that Transport implements http.RoundTripper.
+var _ http.RoundTripper = (*Transport)(nil)
+
+// RoundTrip implements http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ex, ok := req.Context().Value(exchangeKey{}).(*exchange)
+	if !ok {
+		return t.Base.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.Base.RoundTrip(req)
+
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+	ex.request = body
+	ex.status = 0
+	ex.response.Reset()
+	if err != nil {
+		return nil, err
+	}
+	ex.status = resp.StatusCode
+	ex.contentType = resp.Header.Get("Content-Type")
+	resp.Body = &teeBody{ReadCloser: resp.Body, ex: ex}
+	return resp, nil
+}
+
+// teeBody copies the upstream response into the exchange as it is read.
+type teeBody struct {
+	io.ReadCloser
+	ex *exchange
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.ex.mu.Lock()
+	b.ex.response.Write(p[:n])
+	b.ex.mu.Unlock()
+	return n, err
+}
+
+// responseRecorder tees the response to the client.
+type responseRecorder struct {
+	http.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.buf.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher for streaming handlers.
+func (rec *responseRecorder) Flush() {
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,84 @@
+// Package reqbody buffers request bodies for middlewares inspecting or rewriting
+// them. The body is read from the client once and kept in the request context,
+// so each further middleware gets it without copying it again.
+package reqbody
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// MaxSize bounds buffered bodies; larger bodies fail with *http.MaxBytesError.
+// Routes limit their bodies below it with http.MaxBytesReader.
+const MaxSize = 64 << 20
+
+type contextKey struct{}
+
+// buffer holds the body of a request.
+type buffer struct {
+	data []byte
+	body *reader // Request body serving data; the body was replaced if it differs
+}
+
+// reader serves a buffered body.
+type reader struct{ *bytes.Reader }
+
+func (*reader) Close() error { return nil }
+
+// Read returns the body of r, reading it only if no earlier middleware did, and
+// resets r.Body to serve it again. The returned request carries the buffer and
+// replaces r. If reading fails, r.Body replays what was read followed by the
+// error, so the handler surfaces it (e.g., *http.MaxBytesError) as without
+// the middleware; callers pass the request on unchanged.
+func Read(r *http.Request) (*http.Request, []byte, error) {
+	if buf, ok := r.Context().Value(contextKey{}).(*buffer); ok && r.Body == io.ReadCloser(buf.body) {
+		buf.body = newReader(buf.data)
+		r.Body = buf.body
+		return r, buf.data, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxSize+1))
+	_ = r.Body.Close()
+	if err == nil && len(data) > MaxSize {
+		data, err = data[:MaxSize], &http.MaxBytesError{Limit: MaxSize}
+	}
+	if err != nil {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), errReader{err}))
+		return r, data, err
+	}
+
+	buf, ok := r.Context().Value(contextKey{}).(*buffer)
+	if !ok {
+		buf = &buffer{}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, buf))
+	}
+	buf.data, buf.body = data, newReader(data)
+	r.Body = buf.body
+	return r, data, nil
+}
+
+// Replace sets the body of r to data, updating its length and the buffer
+// later middlewares read.
+func Replace(r *http.Request, data []byte) {
+	r.ContentLength = int64(len(data))
+	if r.Header.Get("Content-Length") != "" {
+		r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	body := newReader(data)
+	if buf, ok := r.Context().Value(contextKey{}).(*buffer); ok {
+		buf.data, buf.body = data, body
+	}
+	r.Body = body
+}
+
+func newReader(data []byte) *reader {
+	return &reader{bytes.NewReader(data)}
+}
+
+// errReader returns err on every read.
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
@@ -0,0 +1,113 @@
+package reqbody
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingReader counts the reads of a client body.
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Reader.Read(p)
+}
+
+func TestRead(t *testing.T) {
+	client := &countingReader{Reader: strings.NewReader(`{"model":"a"}`)}
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", client)
+
+	r, first, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := client.reads
+	r, second, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != `{"model":"a"}` || string(second) != string(first) {
+		t.Errorf("bodies = %q, %q, want %q twice", first, second, `{"model":"a"}`)
+	}
+	if client.reads != reads {
+		t.Errorf("client body read again: %d reads, want %d", client.reads, reads)
+	}
+	if got, _ := io.ReadAll(r.Body); string(got) != `{"model":"a"}` {
+		t.Errorf("r.Body = %q, want the body again", got)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"a"}`))
+	r.Header.Set("Content-Length", "13")
+
+	r, _, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Replace(r, []byte(`{"model":"bb"}`))
+	if r.ContentLength != 14 || r.Header.Get("Content-Length") != "14" {
+		t.Errorf("length = %d, header %q, want 14", r.ContentLength, r.Header.Get("Content-Length"))
+	}
+
+	_, body, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"model":"bb"}` {
+		t.Errorf("body = %q, want replaced body", body)
+	}
+}
+
+func TestReadReplacedBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader("a"))
+	r, _, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A body set without Replace, e.g. by decompression, is read anew
+	r.Body = io.NopCloser(strings.NewReader("b"))
+	_, body, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "b" {
+		t.Errorf("body = %q, want %q", body, "b")
+	}
+}
+
+func TestReadError(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int64
+		size  int
+	}{
+		{"request limit", 4, 10},
+		{"max size", 1 << 62, MaxSize + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(strings.Repeat("a", tt.size)))
+			r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, tt.limit)
+
+			r, _, err := Read(r)
+			var maxBytesErr *http.MaxBytesError
+			if !errors.As(err, &maxBytesErr) {
+				t.Fatalf("err = %v, want *http.MaxBytesError", err)
+			}
+
+			// The handler sees the same error
+			if _, err := io.ReadAll(r.Body); !errors.As(err, &maxBytesErr) {
+				t.Errorf("reading r.Body: err = %v, want *http.MaxBytesError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Package routing splits traffic for a requested model across weighted arms
+// (A/B experiments), e.g. "claude-sonnet: 90% sonnet-4-5, 10% opus-4-1".
+//
+// Assignment is sticky: the same client key or end user always lands in the
+// same arm, so conversations are not split across models mid-experiment.
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/florianilch/claudine-proxy/internal/reqbody"
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// Sticky selects the identity used for stable arm assignment.
+type Sticky string
+
+const (
+	StickyKey  Sticky = "key"  // client API key (hashed)
+	StickyUser Sticky = "user" // end user (OpenAI safety_identifier or user / Anthropic metadata.user_id)
+	StickyNone Sticky = "none" // random per request
+)
+
+// Arm is a target model with a relative weight.
+type Arm struct {
+	Name   string
+	Model  string
+	Weight uint
+}
+
+// Experiment routes requests for Model to one of its arms.
+type Experiment struct {
+	Name   string
+	Model  string
+	Sticky Sticky
+	Arms   []Arm
+
+	totalWeight uint
+}
+
+// Router holds experiments indexed by requested model.
+type Router struct {
+	experiments map[string]*Experiment
+}
+
+// New validates experiments and creates a Router.
+func New(experiments []Experiment) (*Router, error) {
+	r := &Router{experiments: make(map[string]*Experiment, len(experiments))}
+	for i := range experiments {
+		e := experiments[i]
+		if e.Model == "" {
+			return nil, fmt.Errorf("experiment %s: model cannot be empty", e.Name)
+		}
+		if e.Name == "" {
+			e.Name = e.Model
+		}
+		if _, exists := r.experiments[e.Model]; exists {
+			return nil, fmt.Errorf("experiment %s: model %q already routed by another experiment", e.Name, e.Model)
+		}
+		if len(e.Arms) == 0 {
+			return nil, fmt.Errorf("experiment %s: at least one arm required", e.Name)
+		}
+		if e.Sticky == "" {
+			e.Sticky = StickyKey
+		}
+		e.Arms = append([]Arm(nil), e.Arms...)
+		for j := range e.Arms {
+			if e.Arms[j].Weight == 0 {
+				return nil, fmt.Errorf("experiment %s: arm %s needs a positive weight", e.Name, e.Arms[j].Model)
+			}
+			if e.Arms[j].Name == "" {
+				e.Arms[j].Name = e.Arms[j].Model
+			}
+			e.totalWeight += e.Arms[j].Weight
+		}
+		r.experiments[e.Model] = &e
+	}
+	return r, nil
+}
+
+// Assign picks the arm for identity. An empty identity assigns randomly.
+func (e *Experiment) Assign(identity string) Arm {
+	var n uint64
+	if identity == "" || e.Sticky == StickyNone {
+		n = rand.Uint64()
+	} else {
+		h := fnv.New64a()
+		_, _ = io.WriteString(h, e.Name)
+		_, _ = h.Write([]byte{0})
+		_, _ = io.WriteString(h, identity)
+		n = h.Sum64()
+	}
+
+	point := uint(n % uint64(e.totalWeight))
+	for _, arm := range e.Arms {
+		if point < arm.Weight {
+			return arm
+		}
+		point -= arm.Weight
+	}
+	return e.Arms[len(e.Arms)-1]
+}
+
+// Middleware rewrites the model of requests matching an experiment and records
+// the assignment for usage metrics. Works on both Anthropic Messages and OpenAI
+// chat completion bodies since both carry a top-level "model".
+func Middleware(router *Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if router == nil || len(router.experiments) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, body, err := reqbody.Read(r)
+			if err != nil {
+				// Let the handler surface the read error
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if routed, ok := router.route(r, body); ok {
+				reqbody.Replace(r, routed)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// route returns the rewritten body if the requested model is part of an experiment.
+func (rt *Router) route(r *http.Request, body []byte) ([]byte, bool) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return nil, false
+	}
+	var model string
+	if json.Unmarshal(fields["model"], &model) != nil {
+		return nil, false
+	}
+	e, ok := rt.experiments[model]
+	if !ok {
+		return nil, false
+	}
+
+	arm := e.Assign(identity(e.Sticky, r, fields))
+	encoded, err := json.Marshal(arm.Model)
+	if err != nil {
+		return nil, false
+	}
+	fields["model"] = encoded
+	routed, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+
+	usage.FromContext(r.Context()).SetExperiment(e.Name, arm.Name)
+	return routed, true
+}
+
+// identity extracts the sticky identity from the request.
+func identity(sticky Sticky, r *http.Request, fields map[string]json.RawMessage) string {
+	switch sticky {
+	case StickyKey:
+		return usage.KeyID(r)
+	case StickyUser:
+		for _, field := range []string{"safety_identifier", "user"} {
+			var user string
+			if raw, ok := fields[field]; ok && json.Unmarshal(raw, &user) == nil && user != "" {
+				return user
+			}
+		}
+		var metadata struct {
+			UserID string `json:"user_id"`
+		}
+		if raw, ok := fields["metadata"]; ok && json.Unmarshal(raw, &metadata) == nil {
+			return metadata.UserID
+		}
+	}
+	return ""
+}
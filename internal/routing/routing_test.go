@@ -0,0 +1,90 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+type sinkFunc func(usage.Event)
+
+func (f sinkFunc) Consume(_ context.Context, e usage.Event) { f(e) }
+
+func TestAssignIsStickyAndWeighted(t *testing.T) {
+	r, err := New([]Experiment{{
+		Name:  "sonnet-vs-opus",
+		Model: "claude-sonnet",
+		Arms:  []Arm{{Model: "claude-sonnet-4-5", Weight: 90}, {Model: "claude-opus-4-1", Weight: 10}},
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	e := r.experiments["claude-sonnet"]
+
+	if a, b := e.Assign("key_1"), e.Assign("key_1"); a != b {
+		t.Errorf("assignment not sticky: %v != %v", a, b)
+	}
+
+	const n = 10000
+	counts := map[string]int{}
+	for i := range n {
+		counts[e.Assign(fmt.Sprintf("key_%d", i)).Model]++
+	}
+	if share := float64(counts["claude-opus-4-1"]) / n; share < 0.08 || share > 0.12 {
+		t.Errorf("opus share = %.3f, want ~0.10", share)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r, err := New([]Experiment{{
+		Name:   "exp",
+		Model:  "claude-sonnet",
+		Sticky: StickyUser,
+		Arms:   []Arm{{Name: "b", Model: "claude-opus-4-1", Weight: 1}},
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantModel string
+		wantArm   string
+	}{
+		{name: "routed", body: `{"model":"claude-sonnet","user":"u1","messages":[]}`, wantModel: "claude-opus-4-1", wantArm: "b"},
+		{name: "other model untouched", body: `{"model":"claude-haiku-4-5"}`, wantModel: "claude-haiku-4-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotModel string
+			handler := Middleware(r)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body, _ := io.ReadAll(req.Body)
+				var fields struct {
+					Model string `json:"model"`
+				}
+				_ = json.Unmarshal(body, &fields)
+				gotModel = fields.Model
+			}))
+
+			var got usage.Event
+			tracked := usage.Track([]usage.Sink{sinkFunc(func(e usage.Event) { got = e })})(handler)
+			tracked.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(tt.body)))
+
+			if gotModel != tt.wantModel {
+				t.Errorf("model = %q, want %q", gotModel, tt.wantModel)
+			}
+			if got.Arm != tt.wantArm {
+				t.Errorf("arm = %q, want %q", got.Arm, tt.wantArm)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package secret
+
+import "golang.org/x/sys/unix"
+
+// excludeFromDump keeps mem out of core dumps. Failure is not fatal: the memory is
+// still locked and outside the Go heap.
+func excludeFromDump(mem []byte) {
+	_ = unix.Madvise(mem, unix.MADV_DONTDUMP)
+}
@@ -0,0 +1,6 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package secret
+
+// excludeFromDump is a no-op: these platforms have no per-mapping dump exclusion.
+func excludeFromDump([]byte) {}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package secret
+
+import "errors"
+
+const supported = false
+
+func alloc(int) ([]byte, error) {
+	return nil, errors.New("locked memory is not supported on this platform")
+}
+
+func free([]byte) error { return nil }
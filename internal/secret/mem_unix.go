@@ -0,0 +1,37 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package secret
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const supported = true
+
+// alloc maps and locks at least size bytes of anonymous memory.
+func alloc(size int) ([]byte, error) {
+	pageSize := os.Getpagesize()
+	length := max(pageSize, (size+pageSize-1)/pageSize*pageSize)
+
+	mem, err := unix.Mmap(-1, 0, length, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, fmt.Errorf("mapping secret memory: %w", err)
+	}
+	if err := unix.Mlock(mem); err != nil {
+		_ = unix.Munmap(mem)
+		return nil, fmt.Errorf("locking secret memory (check RLIMIT_MEMLOCK): %w", err)
+	}
+	excludeFromDump(mem)
+	return mem, nil
+}
+
+// free unlocks and unmaps memory returned by alloc.
+func free(mem []byte) error {
+	if err := unix.Munlock(mem); err != nil {
+		return err
+	}
+	return unix.Munmap(mem)
+}
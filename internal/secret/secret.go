@@ -0,0 +1,109 @@
+// Package secret keeps small secrets, such as refresh tokens, outside the Go heap.
+//
+// A Buffer is backed by anonymous memory that is locked against swapping,
+// excluded from core dumps where the OS supports it, and zeroed when destroyed.
+// It is never part of Go heap dumps and prints as [REDACTED], so it doesn't leak
+// through panics or logs.
+//
+// Go strings are immutable and cannot be zeroed: a secret that is converted to a
+// string to be used, e.g. for an HTTP request, leaves a copy on the heap until it
+// is garbage collected. Buffers limit the secret's lifetime on the heap to such
+// uses; they don't remove it.
+package secret
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// Supported reports whether locked memory is available on this platform.
+const Supported = supported
+
+// ErrDestroyed is returned when accessing a destroyed Buffer.
+var ErrDestroyed = errors.New("secret buffer destroyed")
+
+const redacted = "[REDACTED]"
+
+// Buffer holds a secret in locked memory. All methods are safe for concurrent use.
+type Buffer struct {
+	mu   sync.RWMutex
+	mem  *region
+	size int
+}
+
+// region is a locked, page-aligned memory region. nil data means released.
+type region struct {
+	data []byte
+}
+
+// New copies s into a new locked Buffer. The caller should Destroy it; an
+// unreachable Buffer is destroyed by the garbage collector eventually.
+func New(s string) (*Buffer, error) {
+	data, err := alloc(len(s))
+	if err != nil {
+		return nil, err
+	}
+	copy(data, s)
+	b := &Buffer{mem: &region{data: data}, size: len(s)}
+	runtime.AddCleanup(b, (*region).release, b.mem)
+	return b, nil
+}
+
+// release zeroes and releases the region.
+func (r *region) release() {
+	if r.data == nil {
+		return
+	}
+	clear(r.data)
+	if err := free(r.data); err != nil {
+		slog.Warn("failed to release secret buffer", "error", err)
+	}
+	r.data = nil
+}
+
+// Use calls fn with the secret. fn must not retain the slice or modify it.
+func (b *Buffer) Use(fn func(secret []byte)) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.mem.data == nil {
+		return ErrDestroyed
+	}
+	fn(b.mem.data[:b.size])
+	return nil
+}
+
+// Equal reports in constant time whether the secret equals s.
+func (b *Buffer) Equal(s string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.mem.data != nil && subtle.ConstantTimeCompare(b.mem.data[:b.size], []byte(s)) == 1
+}
+
+// Destroy zeroes and releases the memory. It is safe to call more than once.
+func (b *Buffer) Destroy() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.mem.release()
+}
+
+// String implements fmt.Stringer without revealing the secret.
+func (b *Buffer) String() string { return redacted }
+
+// GoString implements fmt.GoStringer without revealing the secret.
+func (b *Buffer) GoString() string { return redacted }
+
+// Format implements fmt.Formatter, so no verb reveals the secret.
+func (b *Buffer) Format(f fmt.State, _ rune) { _, _ = f.Write([]byte(redacted)) }
+
+// LogValue implements slog.LogValuer without revealing the secret.
+func (b *Buffer) LogValue() slog.Value { return slog.StringValue(redacted) }
+
+// MarshalText implements encoding.TextMarshaler without revealing the secret.
+func (b *Buffer) MarshalText() ([]byte, error) { return []byte(redacted), nil }
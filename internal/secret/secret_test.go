@@ -0,0 +1,43 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	if !Supported {
+		t.Skip("locked memory not supported")
+	}
+
+	const token = "sk-ant-ort01-secret"
+	b, err := New(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	if err := b.Use(func(secret []byte) { got = string(secret) }); err != nil || got != token {
+		t.Fatalf("Use() = %q, %v; want %q", got, err, token)
+	}
+	if !b.Equal(token) || b.Equal(token+"x") || b.Equal("") {
+		t.Error("Equal() mismatch")
+	}
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x"} {
+		if out := fmt.Sprintf(verb, b); strings.Contains(out, "secret") {
+			t.Errorf("%s reveals the secret: %s", verb, out)
+		}
+	}
+
+	b.Destroy()
+	b.Destroy()
+	if b.Equal(token) {
+		t.Error("Equal() true after Destroy")
+	}
+	if err := b.Use(func([]byte) {}); !errors.Is(err, ErrDestroyed) {
+		t.Errorf("Use() after Destroy = %v, want ErrDestroyed", err)
+	}
+}
@@ -0,0 +1,355 @@
+// Package shadow mirrors a sample of upstream requests to a second model or
+// upstream for evaluation. Shadow responses never reach the client; they are
+// discarded or written next to the primary response for offline comparison.
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+const (
+	// maxCaptureSize bounds response bodies kept for comparison records.
+	maxCaptureSize = 8 << 20
+
+	// maxInFlight bounds concurrent shadow requests; excess samples are skipped.
+	maxInFlight = 32
+)
+
+// Config describes how requests are mirrored.
+type Config struct {
+	// Percent of Messages requests to mirror (0-100).
+	Percent float64
+
+	// BaseURL of the shadow upstream. Empty uses the primary upstream.
+	BaseURL string
+
+	// Model replaces the model of mirrored requests. Empty keeps the original.
+	Model string
+
+	// Timeout for a single shadow request including its response body.
+	Timeout time.Duration
+
+	// Store is a file path receiving one JSON comparison record per mirrored request.
+	// Empty discards shadow responses.
+	Store string
+}
+
+// Mirror samples requests and replays them against the shadow target.
+type Mirror struct {
+	cfg     Config
+	baseURL *url.URL
+	sem     chan struct{}
+	wg      sync.WaitGroup
+
+	storeMu sync.Mutex
+	store   *os.File
+}
+
+// New creates a Mirror and opens the comparison store if configured.
+func New(cfg Config) (*Mirror, error) {
+	if cfg.Percent <= 0 || cfg.Percent > 100 {
+		return nil, fmt.Errorf("shadow percent must be in (0, 100], got %v", cfg.Percent)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+
+	m := &Mirror{cfg: cfg, sem: make(chan struct{}, maxInFlight)}
+
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow base URL: %w", err)
+		}
+		m.baseURL = u
+	}
+
+	if cfg.Store != "" {
+		f, err := os.OpenFile(cfg.Store, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open shadow store: %w", err)
+		}
+		m.store = f
+	}
+	return m, nil
+}
+
+// Transport wraps next so sampled Messages requests are mirrored through next as well
+// (sharing authentication and impersonation). primary is the upstream base URL used
+// to map request paths onto the shadow base URL.
+func (m *Mirror) Transport(next http.RoundTripper, primary *url.URL) http.RoundTripper {
+	return &transport{mirror: m, next: next, primary: primary}
+}
+
+// Close waits for in-flight shadow requests (bounded by ctx) and closes the store.
+func (m *Mirror) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("shadow requests still in flight: %w", ctx.Err())
+	}
+
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+	if m.store != nil {
+		err = errors.Join(err, m.store.Close())
+		m.store = nil
+	}
+	return err
+}
+
+type transport struct {
+	mirror  *Mirror
+	next    http.RoundTripper
+	primary *url.URL
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m := t.mirror
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/messages") || req.Body == nil ||
+		rand.Float64()*100 >= m.cfg.Percent {
+		return t.next.RoundTrip(req)
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		slog.DebugContext(req.Context(), "shadow capacity exhausted, skipping mirror")
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		<-m.sem
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	shadowReq, err := t.shadowRequest(req, body)
+	if err != nil {
+		<-m.sem
+		slog.WarnContext(req.Context(), "failed to build shadow request", "error", err)
+		return t.next.RoundTrip(req)
+	}
+
+	requestID, _ := req.Context().Value(middleware.RequestIDContextKey{}).(string)
+	rec := &record{RequestID: requestID, Timestamp: time.Now().UTC()}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	// Primary outcome is captured only when comparison records are stored
+	var primaryDone chan struct{}
+	if m.store != nil {
+		primaryDone = make(chan struct{})
+		rec.Primary.Model = modelOf(body)
+		if err != nil {
+			rec.Primary.Error = err.Error()
+			close(primaryDone)
+		} else {
+			rec.Primary.Status = resp.StatusCode
+			resp.Body = &captureBody{ReadCloser: resp.Body, start: start, result: &rec.Primary, done: primaryDone}
+		}
+	}
+
+	m.wg.Go(func() {
+		defer func() { <-m.sem }()
+		m.run(shadowReq, t.next, rec, primaryDone)
+	})
+	return resp, err
+}
+
+// shadowRequest clones req onto the shadow target. The clone is detached from the
+// client request so it neither inherits cancellation nor usage tracking.
+func (t *transport) shadowRequest(req *http.Request, body []byte) (*http.Request, error) {
+	m := t.mirror
+	if m.cfg.Model != "" {
+		var err error
+		if body, err = replaceModel(body, m.cfg.Model); err != nil {
+			return nil, err
+		}
+	}
+
+	u := *req.URL
+	if m.baseURL != nil {
+		u.Scheme = m.baseURL.Scheme
+		u.Host = m.baseURL.Host
+		u.Path = strings.TrimSuffix(m.baseURL.Path, "/") + strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(t.primary.Path, "/"))
+		u.RawPath = ""
+	}
+
+	shadowReq, err := http.NewRequest(req.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	shadowReq.Header = req.Header.Clone()
+	shadowReq.Header.Del("Content-Length")
+	return shadowReq, nil
+}
+
+// run executes the shadow request and writes the comparison record.
+func (m *Mirror) run(req *http.Request, next http.RoundTripper, rec *record, primaryDone <-chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.Timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+	rec.Shadow.Model = m.cfg.Model
+
+	start := time.Now()
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		rec.Shadow.Error = err.Error()
+	} else {
+		rec.Shadow.Status = resp.StatusCode
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxCaptureSize))
+		_ = resp.Body.Close()
+		if readErr != nil {
+			rec.Shadow.Error = readErr.Error()
+		}
+		rec.Shadow.setBody(body)
+	}
+	rec.Shadow.LatencyMS = time.Since(start).Milliseconds()
+
+	if err != nil || rec.Shadow.Status >= 400 {
+		slog.DebugContext(ctx, "shadow request failed", "request_id", rec.RequestID, "status", rec.Shadow.Status, "error", err)
+	}
+
+	if primaryDone == nil {
+		return
+	}
+	select {
+	case <-primaryDone:
+		m.write(rec)
+	case <-ctx.Done():
+		// The primary body is still being captured; only fields set before the mirror started are safe to read
+		out := *rec
+		out.Primary = result{Model: rec.Primary.Model, Status: rec.Primary.Status, Error: "primary response incomplete"}
+		m.write(&out)
+	}
+}
+
+func (m *Mirror) write(rec *record) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("failed to encode shadow record", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+	if m.store == nil {
+		return
+	}
+	if _, err := m.store.Write(line); err != nil {
+		slog.Error("failed to write shadow record", "error", err)
+	}
+}
+
+// record is a single comparison entry in the shadow store.
+type record struct {
+	RequestID string    `json:"request_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Primary   result    `json:"primary"`
+	Shadow    result    `json:"shadow"`
+}
+
+type result struct {
+	Model     string `json:"model,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	Body      any    `json:"body,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// setBody stores JSON bodies verbatim and anything else (SSE streams) as string.
+func (r *result) setBody(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	if json.Valid(body) {
+		r.Body = json.RawMessage(body)
+		return
+	}
+	r.Body = string(body)
+}
+
+// captureBody records the primary response as the client consumes it.
+type captureBody struct {
+	io.ReadCloser
+	start  time.Time
+	result *result
+	done   chan struct{}
+	buf    bytes.Buffer
+	once   sync.Once
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.buf.Len() < maxCaptureSize {
+		b.buf.Write(p[:min(n, maxCaptureSize-b.buf.Len())])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *captureBody) finish() {
+	b.once.Do(func() {
+		b.result.LatencyMS = time.Since(b.start).Milliseconds()
+		b.result.setBody(b.buf.Bytes())
+		close(b.done)
+	})
+}
+
+// replaceModel sets the top-level model field of a Messages request body.
+func replaceModel(body []byte, model string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("decode request body: %w", err)
+	}
+	encoded, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	fields["model"] = encoded
+	return json.Marshal(fields)
+}
+
+// modelOf returns the model field of a Messages request body.
+func modelOf(body []byte) string {
+	var req struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &req)
+	return req.Model
+}
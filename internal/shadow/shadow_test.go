@@ -0,0 +1,78 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestMirror(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "shadow.jsonl")
+	m, err := New(Config{Percent: 100, BaseURL: "https://shadow.example.com/v2", Model: "claude-opus-4-1", Store: store})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	var mu sync.Mutex
+	seen := map[string]string{} // URL → request body
+	next := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		seen[r.URL.String()] = string(body)
+		mu.Unlock()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"model":"` + r.Host + `"}`)),
+		}, nil
+	})
+
+	primary, _ := url.Parse("https://api.anthropic.com/v1")
+	rt := m.Transport(next, primary)
+
+	req, _ := http.NewRequest(http.MethodPost, "https://api.anthropic.com/v1/messages", strings.NewReader(`{"model":"claude-sonnet-4-5","max_tokens":1}`))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := seen["https://api.anthropic.com/v1/messages"]; got != `{"model":"claude-sonnet-4-5","max_tokens":1}` {
+		t.Errorf("primary body = %s, want original", got)
+	}
+	if got := seen["https://shadow.example.com/v2/messages"]; !strings.Contains(got, `"model":"claude-opus-4-1"`) {
+		t.Errorf("shadow body = %s, want replaced model", got)
+	}
+
+	data, err := os.ReadFile(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("decode record %s: %v", data, err)
+	}
+	if rec.Primary.Model != "claude-sonnet-4-5" || rec.Shadow.Model != "claude-opus-4-1" ||
+		rec.Primary.Status != http.StatusOK || rec.Shadow.Status != http.StatusOK {
+		t.Errorf("record = %s", data)
+	}
+}
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/stdlib"
+
+	// Registers the pure Go SQLite driver as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	// pgx registers itself as "pgx"; Open uses the dialect name as driver name
+	sql.Register(string(Postgres), stdlib.GetDefaultDriver())
+}
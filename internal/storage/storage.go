@@ -0,0 +1,432 @@
+// Package storage persists accounting data — virtual keys, usage records and
+// audit events — in SQLite (single instance) or Postgres (shared by replicas).
+//
+// Storage speaks database/sql. The package links modernc.org/sqlite and pgx,
+// registered under the dialect names "sqlite" and "postgres".
+//
+// Usage events are written asynchronously: Store implements usage.Sink and
+// queues events like webhook dispatchers do, so a slow database never delays
+// responses.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+// Dialect selects the SQL flavor and the database/sql driver name.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// defaultQueueSize bounds usage events waiting to be written.
+const defaultQueueSize = 4096
+
+// Store persists accounting data in a SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan usage.Event
+	done   chan struct{}
+}
+
+// Compile-time check that Store implements usage.Sink
+var _ usage.Sink = (*Store)(nil)
+
+// Open creates a Store for dsn. No connection is made until Migrate or first use.
+func Open(dialect Dialect, dsn string) (*Store, error) {
+	if dialect != SQLite && dialect != Postgres {
+		return nil, fmt.Errorf("unsupported storage dialect %q", dialect)
+	}
+	if dialect == SQLite && dsn != ":memory:" && !strings.HasPrefix(dsn, "file:") {
+		if err := os.MkdirAll(filepath.Dir(dsn), 0o700); err != nil {
+			return nil, fmt.Errorf("create storage directory: %w", err)
+		}
+	}
+	db, err := sql.Open(string(dialect), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s storage: %w", dialect, err)
+	}
+	if dialect == SQLite {
+		// SQLite serializes writers; a single connection avoids "database is locked"
+		db.SetMaxOpenConns(1)
+	}
+	return NewWithDB(db, dialect), nil
+}
+
+// NewWithDB creates a Store on an existing database handle.
+func NewWithDB(db *sql.DB, dialect Dialect) *Store {
+	return &Store{
+		db:      db,
+		dialect: dialect,
+		queue:   make(chan usage.Event, defaultQueueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// migrations are applied in order; their index is the schema version.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS usage_events (
+		id TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		latency_ms BIGINT NOT NULL,
+		stream BOOLEAN NOT NULL,
+		model TEXT NOT NULL,
+		key_id TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		experiment TEXT NOT NULL,
+		arm TEXT NOT NULL,
+		cached BOOLEAN NOT NULL,
+		upstream_status INTEGER NOT NULL,
+		error_type TEXT NOT NULL,
+		input_tokens BIGINT NOT NULL,
+		output_tokens BIGINT NOT NULL,
+		cache_read_input_tokens BIGINT NOT NULL,
+		cache_creation_input_tokens BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS usage_events_timestamp ON usage_events (timestamp)`,
+	`CREATE INDEX IF NOT EXISTS usage_events_key ON usage_events (key_id, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS virtual_keys (
+		key_id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		tenant TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_events (
+		id TEXT NOT NULL,
+		timestamp TIMESTAMP NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL,
+		detail TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS audit_events_timestamp ON audit_events (timestamp)`,
+}
+
+// Migrate creates or upgrades the schema. Safe to run concurrently from several
+// replicas: every statement is idempotent, schema_version holds a single row
+// whatever replica creates it, and the version only moves forward.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_version: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO schema_version (id, version) VALUES (1, 0) ON CONFLICT DO NOTHING`); err != nil {
+		return fmt.Errorf("init schema_version: %w", err)
+	}
+
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT version FROM schema_version WHERE id = 1`).Scan(&version); err != nil {
+		return fmt.Errorf("read schema_version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := s.db.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := s.exec(ctx, `UPDATE schema_version SET version = ? WHERE id = 1 AND version < ?`, i+1, i+1); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Consume enqueues a usage event without blocking. Events are dropped when the queue is full.
+func (s *Store) Consume(ctx context.Context, event usage.Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.queue <- event:
+	default:
+		slog.WarnContext(ctx, "storage queue full, dropping usage event", "request_id", event.ID)
+	}
+}
+
+// Start writes queued usage events in the background until Shutdown is called.
+func (s *Store) Start(ctx context.Context) {
+	// Detached from cancellation so queued events can still be written on shutdown
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		defer close(s.done)
+		for event := range s.queue {
+			if err := s.RecordUsage(ctx, event); err != nil {
+				slog.ErrorContext(ctx, "failed to persist usage event", "request_id", event.ID, "error", err)
+			}
+		}
+	}()
+}
+
+// Shutdown stops accepting events, waits until the queue is written or ctx
+// expires and closes the database.
+func (s *Store) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return s.db.Close()
+	case <-ctx.Done():
+		return fmt.Errorf("storage: %d usage events not persisted: %w", len(s.queue), ctx.Err())
+	}
+}
+
+// Close closes the database of a store that was never started. Started stores
+// are closed by Shutdown.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RecordUsage persists a usage event.
+func (s *Store) RecordUsage(ctx context.Context, e usage.Event) error {
+	_, err := s.exec(ctx, `INSERT INTO usage_events (
+		id, timestamp, method, path, status, latency_ms, stream, model, key_id, tag, experiment, arm, cached,
+		upstream_status, error_type, input_tokens, output_tokens, cache_read_input_tokens, cache_creation_input_tokens
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.Timestamp.UTC(), e.Method, e.Path, e.Status, e.LatencyMS, e.Stream, e.Model, e.Key, e.Tag,
+		e.Experiment, e.Arm, e.Cached, e.UpstreamStatus, e.ErrorType,
+		e.Usage.InputTokens, e.Usage.OutputTokens, e.Usage.CacheReadInputTokens, e.Usage.CacheCreationInputTokens)
+	if err != nil {
+		return fmt.Errorf("insert usage event: %w", err)
+	}
+	return nil
+}
+
+// UsageFilter selects usage events. Zero fields do not filter.
+type UsageFilter struct {
+	Since time.Time
+	Key   string
+	Tag   string
+	Limit int
+}
+
+// Usage returns matching usage events, newest first.
+func (s *Store) Usage(ctx context.Context, f UsageFilter) ([]usage.Event, error) {
+	query := `SELECT id, timestamp, method, path, status, latency_ms, stream, model, key_id, tag, experiment, arm, cached,
+		upstream_status, error_type, input_tokens, output_tokens, cache_read_input_tokens, cache_creation_input_tokens
+		FROM usage_events WHERE timestamp >= ?`
+	args := []any{f.Since.UTC()}
+	if f.Key != "" {
+		query += ` AND key_id = ?`
+		args = append(args, f.Key)
+	}
+	if f.Tag != "" {
+		query += ` AND tag = ?`
+		args = append(args, f.Tag)
+	}
+	query += ` ORDER BY timestamp DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(f.Limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query usage events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []usage.Event
+	for rows.Next() {
+		var e usage.Event
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.Method, &e.Path, &e.Status, &e.LatencyMS, &e.Stream, &e.Model,
+			&e.Key, &e.Tag, &e.Experiment, &e.Arm, &e.Cached, &e.UpstreamStatus, &e.ErrorType,
+			&e.Usage.InputTokens, &e.Usage.OutputTokens, &e.Usage.CacheReadInputTokens, &e.Usage.CacheCreationInputTokens); err != nil {
+			return nil, fmt.Errorf("scan usage event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// VirtualKey is a client key known to the proxy. Only its key ID ("key_…", see
+// usage.KeyID) is stored, never the key itself.
+type VirtualKey struct {
+	ID        string
+	Name      string
+	Tenant    string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// PutKey creates, renames or reinstates a virtual key.
+func (s *Store) PutKey(ctx context.Context, k VirtualKey) error {
+	if k.CreatedAt.IsZero() {
+		k.CreatedAt = time.Now()
+	}
+	_, err := s.exec(ctx, `INSERT INTO virtual_keys (key_id, name, tenant, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key_id) DO UPDATE SET name = excluded.name, tenant = excluded.tenant, revoked_at = NULL`,
+		k.ID, k.Name, k.Tenant, k.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("put virtual key: %w", err)
+	}
+	return nil
+}
+
+// RevokeKey marks a virtual key as revoked.
+func (s *Store) RevokeKey(ctx context.Context, id string) error {
+	res, err := s.exec(ctx, `UPDATE virtual_keys SET revoked_at = ? WHERE key_id = ? AND revoked_at IS NULL`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("revoke virtual key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("virtual key %s not found or already revoked", id)
+	}
+	return nil
+}
+
+// SyncKeys records the configured virtual keys and revokes stored keys that are
+// no longer configured, so the table follows the configuration across restarts.
+func (s *Store) SyncKeys(ctx context.Context, keys []VirtualKey) error {
+	configured := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if err := s.PutKey(ctx, k); err != nil {
+			return err
+		}
+		configured[k.ID] = true
+	}
+
+	stored, err := s.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range stored {
+		if k.RevokedAt == nil && !configured[k.ID] {
+			if err := s.RevokeKey(ctx, k.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Keys returns all virtual keys, including revoked ones.
+func (s *Store) Keys(ctx context.Context) ([]VirtualKey, error) {
+	rows, err := s.query(ctx, `SELECT key_id, name, tenant, created_at, revoked_at FROM virtual_keys ORDER BY created_at, key_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query virtual keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys []VirtualKey
+	for rows.Next() {
+		var k VirtualKey
+		var revoked sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Name, &k.Tenant, &k.CreatedAt, &revoked); err != nil {
+			return nil, fmt.Errorf("scan virtual key: %w", err)
+		}
+		if revoked.Valid {
+			k.RevokedAt = &revoked.Time
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// AuditEvent records an administrative or security-relevant action.
+type AuditEvent struct {
+	ID        string
+	Timestamp time.Time
+	Actor     string
+	Action    string
+	Target    string
+	Detail    string
+}
+
+// RecordAudit persists an audit event.
+func (s *Store) RecordAudit(ctx context.Context, e AuditEvent) error {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	_, err := s.exec(ctx, `INSERT INTO audit_events (id, timestamp, actor, action, target, detail) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.ID, e.Timestamp.UTC(), e.Actor, e.Action, e.Target, e.Detail)
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+// Audit returns audit events since the given time, newest first.
+func (s *Store) Audit(ctx context.Context, since time.Time, limit int) ([]AuditEvent, error) {
+	query := `SELECT id, timestamp, actor, action, target, detail FROM audit_events WHERE timestamp >= ? ORDER BY timestamp DESC`
+	if limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(limit)
+	}
+	rows, err := s.query(ctx, query, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("query audit events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.Actor, &e.Action, &e.Target, &e.Detail); err != nil {
+			return nil, fmt.Errorf("scan audit event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.rebind(query), args...)
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.rebind(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.rebind(query), args...)
+}
+
+// rebind rewrites "?" placeholders to Postgres' "$1", "$2", … Queries must not
+// contain literal question marks.
+func (s *Store) rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -5,6 +5,9 @@
 //   - Env: Read-only environment variable access (requires external secret management)
 //   - Keyring: OS-native credential storage (macOS Keychain, Windows Credential Manager, etc.)
 //
+// File and keyring stores implement BackupStore: each write keeps the previous
+// token in a backup slot.
+//
 // OAuth authentication requires writable storage (file or keyring), while static
 // token authentication can use any backend including read-only env storage.
 package tokenstore
@@ -2,20 +2,23 @@ package tokenstore
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
 // FileStore provides atomic file-based token storage with secure permissions.
-// Writes use temp file + rename for crash safety.
+// Writes use temp file + rename for crash safety. The previous token is kept in
+// a ".bak" file next to the token file.
 type FileStore struct {
 	filePath string
 }
 
-// Compile-time check to ensure FileStore implements TokenStore
-var _ TokenStore = (*FileStore)(nil)
+// Compile-time check to ensure FileStore implements BackupStore
+var _ BackupStore = (*FileStore)(nil)
 
 // NewFileStore creates a FileStore for the given path, creating parent directories
 // with 0700 permissions if they don't exist.
@@ -37,40 +40,80 @@ func NewFileStore(filePath string) (*FileStore, error) {
 // Read returns the stored token after trimming whitespace. Returns error if file
 // doesn't exist, is empty, or has insecure permissions.
 func (f *FileStore) Read(ctx context.Context) (string, error) {
+	return readTokenFile(ctx, f.filePath)
+}
+
+// Write atomically saves the token using temp file + rename for crash safety.
+// Sets file permissions to 0600 (owner read/write only). A different previously
+// stored token is moved to the backup file first.
+func (f *FileStore) Write(ctx context.Context, token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		if err := os.Remove(f.backupPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("clearing backup token: %w", err)
+		}
+	} else if previous, err := readTokenFile(ctx, f.filePath); err == nil && previous != token {
+		if err := writeTokenFile(ctx, f.backupPath(), previous); err != nil {
+			return fmt.Errorf("backing up previous token: %w", err)
+		}
+	}
+
+	return writeTokenFile(ctx, f.filePath, token)
+}
+
+// ReadBackup returns the previously stored token from the backup file.
+func (f *FileStore) ReadBackup(ctx context.Context) (string, error) {
+	return readTokenFile(ctx, f.backupPath())
+}
+
+// Restore replaces the token file with the backup file's token.
+func (f *FileStore) Restore(ctx context.Context) error {
+	token, err := f.ReadBackup(ctx)
+	if err != nil {
+		return err
+	}
+	return writeTokenFile(ctx, f.filePath, token)
+}
+
+func (f *FileStore) backupPath() string {
+	return f.filePath + ".bak"
+}
+
+// readTokenFile reads and trims the token in path, which must have 0600 permissions.
+func readTokenFile(ctx context.Context, path string) (string, error) {
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
 
 	// Check file permissions before reading
-	info, err := os.Stat(f.filePath)
+	info, err := os.Stat(path)
 	if err != nil {
 		return "", err
 	}
 	if info.Mode().Perm() != 0600 {
-		return "", fmt.Errorf("insecure permissions on %s: %04o (expected 0600)", f.filePath, info.Mode().Perm())
+		return "", fmt.Errorf("insecure permissions on %s: %04o (expected 0600)", path, info.Mode().Perm())
 	}
 
-	data, err := os.ReadFile(f.filePath)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
 
 	token := strings.TrimSpace(string(data))
 	if token == "" {
-		return "", fmt.Errorf("empty token file %s", f.filePath)
+		return "", fmt.Errorf("empty token file %s", path)
 	}
 	return token, nil
 }
 
-// Write atomically saves the token using temp file + rename for crash safety.
-// Sets file permissions to 0600 (owner read/write only).
-func (f *FileStore) Write(ctx context.Context, token string) error {
+// writeTokenFile atomically writes token to path with 0600 permissions.
+func writeTokenFile(ctx context.Context, path, token string) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 
 	// Create secure temp file in same directory for atomic rename
-	dir := filepath.Dir(f.filePath)
+	dir := filepath.Dir(path)
 	tempFile, err := os.CreateTemp(dir, "*.tmp")
 	if err != nil {
 		return err
@@ -92,12 +135,12 @@ func (f *FileStore) Write(ctx context.Context, token string) error {
 	}
 
 	// Atomic rename to final location
-	if err := os.Rename(tempName, f.filePath); err != nil {
+	if err := os.Rename(tempName, path); err != nil {
 		return err
 	}
 
 	// Set secure file permissions (0600 = rw-------)
-	if err := os.Chmod(f.filePath, 0600); err != nil {
+	if err := os.Chmod(path, 0600); err != nil {
 		return err
 	}
 
@@ -13,3 +13,16 @@ type TokenStore interface {
 	// is read-only (e.g., environment variables) or if write operation fails.
 	Write(ctx context.Context, token string) error
 }
+
+// BackupStore is a TokenStore that keeps the previously stored token in a single
+// backup slot, so a corrupted write or a rotation race can be recovered from
+// without logging in again. Writing an empty token (logout) clears the backup too.
+type BackupStore interface {
+	TokenStore
+
+	// ReadBackup returns the previously stored token. Returns error if there is none.
+	ReadBackup(ctx context.Context) (string, error)
+
+	// Restore replaces the stored token with the backup. The backup is kept.
+	Restore(ctx context.Context) error
+}
@@ -2,6 +2,7 @@ package tokenstore
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/zalando/go-keyring"
@@ -9,13 +10,14 @@ import (
 
 // KeyringStore provides OS-native secure credential storage for tokens.
 // Uses macOS Keychain, Windows Credential Manager, or Linux Secret Service.
+// The previous token is kept in a secondary entry for the user with a ".bak" suffix.
 type KeyringStore struct {
 	service string
 	user    string
 }
 
-// Compile-time check to ensure KeyringStore implements TokenStore
-var _ TokenStore = (*KeyringStore)(nil)
+// Compile-time check to ensure KeyringStore implements BackupStore
+var _ BackupStore = (*KeyringStore)(nil)
 
 // NewKeyringStore creates a KeyringStore for the OS-native credential storage
 // (macOS Keychain, Windows Credential Manager, etc.) using the given service and user identifiers.
@@ -35,27 +37,61 @@ func NewKeyringStore(service, user string) (*KeyringStore, error) {
 
 // Read returns the token from the system keyring. Returns error if not found or empty.
 func (k *KeyringStore) Read(ctx context.Context) (string, error) {
+	return k.get(ctx, k.user)
+}
+
+// Write persists the token to the system keyring, overwriting any existing value.
+// A different previously stored token is copied to the backup entry first.
+func (k *KeyringStore) Write(ctx context.Context, token string) error {
 	if err := ctx.Err(); err != nil {
-		return "", err
+		return err
 	}
 
-	token, err := keyring.Get(k.service, k.user)
-	if err != nil {
-		return "", err
+	if token == "" {
+		if err := keyring.Delete(k.service, k.backupUser()); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("clearing backup token: %w", err)
+		}
+	} else if previous, err := k.get(ctx, k.user); err == nil && previous != token {
+		if err := keyring.Set(k.service, k.backupUser(), previous); err != nil {
+			return fmt.Errorf("backing up previous token: %w", err)
+		}
 	}
 
-	if token == "" {
-		return "", fmt.Errorf("empty token in keyring for service %s, user %s", k.service, k.user)
+	return keyring.Set(k.service, k.user, token)
+}
+
+// ReadBackup returns the previously stored token from the backup entry.
+func (k *KeyringStore) ReadBackup(ctx context.Context) (string, error) {
+	return k.get(ctx, k.backupUser())
+}
+
+// Restore replaces the stored token with the backup entry's token.
+func (k *KeyringStore) Restore(ctx context.Context) error {
+	token, err := k.ReadBackup(ctx)
+	if err != nil {
+		return err
 	}
+	return keyring.Set(k.service, k.user, token)
+}
 
-	return token, nil
+func (k *KeyringStore) backupUser() string {
+	return k.user + ".bak"
 }
 
-// Write persists the token to the system keyring, overwriting any existing value.
-func (k *KeyringStore) Write(ctx context.Context, token string) error {
+// get returns the non-empty token stored for user.
+func (k *KeyringStore) get(ctx context.Context, user string) (string, error) {
 	if err := ctx.Err(); err != nil {
-		return err
+		return "", err
 	}
 
-	return keyring.Set(k.service, k.user, token)
+	token, err := keyring.Get(k.service, user)
+	if err != nil {
+		return "", err
+	}
+
+	if token == "" {
+		return "", fmt.Errorf("empty token in keyring for service %s, user %s", k.service, user)
+	}
+
+	return token, nil
 }
@@ -0,0 +1,189 @@
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/observability/middleware"
+)
+
+// Track attaches a Record to the request context and hands a completed Event
+// to all sinks once the handler returns. Streaming requests complete when the
+// stream ends.
+func Track(sinks []Sink) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(sinks) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, rec := WithRecord(r.Context())
+			start := rec.start
+			rec.SetTag(r.Header.Get(HeaderTag))
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			end := time.Now()
+			requestID, _ := ctx.Value(middleware.RequestIDContextKey{}).(string)
+			event := Event{
+				ID:        requestID,
+				Timestamp: start.UTC(),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Route:     route(r),
+				Status:    sw.statusCode(),
+				LatencyMS: end.Sub(start).Milliseconds(),
+				Key:       KeyID(r),
+			}
+			rec.fill(&event, end)
+			if event.Tag != "" {
+				middleware.SetLogAttrs(ctx, slog.String("tag", event.Tag))
+			}
+			if event.TTFTMS > 0 {
+				middleware.SetLogAttrs(ctx,
+					slog.Int64("ttft_ms", event.TTFTMS),
+					slog.Int64("upstream_ttft_ms", event.UpstreamTTFTMS),
+					slog.Float64("tokens_per_second", math.Round(event.TokensPerSecond*10)/10))
+			}
+
+			for _, sink := range sinks {
+				sink.Consume(ctx, event)
+			}
+		})
+	}
+}
+
+// route returns the path of the ServeMux pattern r matched, without its method
+// and host, bounding the values it takes unlike the request path.
+func route(r *http.Request) string {
+	pattern := r.Pattern
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i+1:], " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// HeaderTTFT is the response trailer carrying the time to first token in
+// milliseconds, set by TTFTTrailer.
+const HeaderTTFT = "X-Claudine-Ttft"
+
+// TTFTTrailer sends the time to first token of streamed responses in the
+// HeaderTTFT trailer. Must run inside Track.
+func TTFTTrailer(enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			if ttft := FromContext(r.Context()).TTFT(); ttft > 0 {
+				w.Header().Set(http.TrailerPrefix+HeaderTTFT, strconv.FormatInt(ttft.Milliseconds(), 10))
+			}
+		})
+	}
+}
+
+// HeaderTag carries the client's cost attribution tag. It is not forwarded upstream.
+const HeaderTag = "X-Claudine-Tag"
+
+// MetadataTag is the OpenAI metadata key accepted as an alternative to HeaderTag.
+const MetadataTag = "claudine_tag"
+
+// maxTagLength bounds tags, which become metrics labels.
+const maxTagLength = 64
+
+// ValidTag reports whether tag is 1-64 characters of letters, digits and ._:/-
+// so client-supplied tags stay safe as log fields and metrics labels.
+func ValidTag(tag string) bool {
+	if tag == "" || len(tag) > maxTagLength {
+		return false
+	}
+	for _, c := range tag {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("._:/-", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// KeyID returns a stable, non-secret identifier for the client credential
+// (Authorization bearer token, x-api-key or Azure's api-key). Returns "" if none was sent.
+func KeyID(r *http.Request) string {
+	key := Credential(r)
+	if key == "" {
+		return ""
+	}
+	return KeyIDOf(key)
+}
+
+// KeyIDOf returns the identifier KeyID reports for the credential key.
+func KeyIDOf(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key_" + hex.EncodeToString(sum[:8])
+}
+
+// Credential returns the raw client credential, or "" if none was sent.
+func Credential(r *http.Request) string {
+	key := r.Header.Get("X-Api-Key")
+	if key == "" {
+		key = r.Header.Get("Api-Key")
+	}
+	if key == "" {
+		auth := r.Header.Get("Authorization")
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			key = strings.TrimSpace(auth[7:])
+		}
+	}
+	return key
+}
+
+// statusWriter captures the status code sent to the client.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher for streaming handlers.
+func (w *statusWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,194 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// maxBufferedSize bounds how much of a buffered JSON response is retained for usage parsing.
+const maxBufferedSize = 8 << 20
+
+// Transport records model, usage and error details of upstream responses into
+// the Record of the request context. Requests without a Record pass through untouched.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	rec := FromContext(req.Context())
+	if rec != nil {
+		rec.markUpstreamStart(time.Now())
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || rec == nil {
+		return resp, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	stream := mediaType == "text/event-stream"
+	rec.setUpstream(resp.StatusCode, stream)
+
+	switch {
+	case stream:
+		resp.Body = &sseBody{ReadCloser: resp.Body, rec: rec}
+	case mediaType == "application/json":
+		resp.Body = &jsonBody{ReadCloser: resp.Body, rec: rec}
+	}
+	return resp, nil
+}
+
+// anthropicPayload matches the usage-relevant fields of Anthropic messages,
+// stream events and error responses.
+type anthropicPayload struct {
+	Type    string  `json:"type"`
+	Model   string  `json:"model"`
+	Usage   *Tokens `json:"usage"`
+	Message *struct {
+		Model string  `json:"model"`
+		Usage *Tokens `json:"usage"`
+	} `json:"message"`
+	Error *struct {
+		Type string `json:"type"`
+	} `json:"error"`
+}
+
+// apply merges the payload into rec.
+func (p *anthropicPayload) apply(rec *Record) {
+	if p.Error != nil && p.Error.Type != "" {
+		rec.setErrorType(p.Error.Type)
+	}
+	rec.SetModel(p.Model)
+	if p.Usage != nil {
+		rec.mergeTokens(*p.Usage)
+	}
+	if p.Message != nil {
+		rec.SetModel(p.Message.Model)
+		if p.Message.Usage != nil {
+			rec.mergeTokens(*p.Message.Usage)
+		}
+	}
+}
+
+// jsonBody buffers a JSON response while it is read and parses it once at EOF or Close.
+type jsonBody struct {
+	io.ReadCloser
+	rec      *Record
+	buf      bytes.Buffer
+	overflow bool
+	done     bool
+}
+
+func (b *jsonBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.overflow {
+		if b.buf.Len()+n > maxBufferedSize {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.parse()
+	}
+	return n, err
+}
+
+func (b *jsonBody) Close() error {
+	b.parse()
+	return b.ReadCloser.Close()
+}
+
+func (b *jsonBody) parse() {
+	if b.done || b.overflow {
+		return
+	}
+	b.done = true
+	var payload anthropicPayload
+	if json.Unmarshal(b.buf.Bytes(), &payload) == nil {
+		payload.apply(b.rec)
+	}
+	b.buf = bytes.Buffer{}
+}
+
+// sseBody scans an SSE stream as it is read and parses the events carrying usage.
+type sseBody struct {
+	io.ReadCloser
+	rec        *Record
+	partial    []byte
+	firstToken bool
+}
+
+// Only these events carry model, usage or error details; content deltas are skipped.
+var usageEventMarkers = [][]byte{
+	[]byte(`"message_start"`),
+	[]byte(`"message_delta"`),
+	[]byte(`"error"`),
+}
+
+// contentDeltaMarker identifies events streaming content, the first of which marks
+// the time to first token.
+var contentDeltaMarker = []byte(`"content_block_delta"`)
+
+func (b *sseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.scan(p[:n])
+	}
+	return n, err
+}
+
+func (b *sseBody) scan(chunk []byte) {
+	for len(chunk) > 0 {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			if len(b.partial)+len(chunk) <= maxBufferedSize {
+				b.partial = append(b.partial, chunk...)
+			}
+			return
+		}
+		line := chunk[:i]
+		if len(b.partial) > 0 {
+			line = append(b.partial, line...)
+			b.partial = b.partial[:0]
+		}
+		b.line(line)
+		chunk = chunk[i+1:]
+	}
+}
+
+func (b *sseBody) line(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:"))
+	if !ok {
+		return
+	}
+	if !b.firstToken && bytes.Contains(data, contentDeltaMarker) {
+		b.firstToken = true
+		b.rec.markFirstToken(time.Now())
+		return
+	}
+	relevant := false
+	for _, marker := range usageEventMarkers {
+		if bytes.Contains(data, marker) {
+			relevant = true
+			break
+		}
+	}
+	if !relevant {
+		return
+	}
+	var payload anthropicPayload
+	if json.Unmarshal(bytes.TrimSpace(data), &payload) == nil {
+		payload.apply(b.rec)
+	}
+}
@@ -0,0 +1,257 @@
+// Package usage captures per-request token usage and emits completion events.
+//
+// Transport inspects upstream Anthropic responses (buffered JSON and SSE streams)
+// and fills the Record stored in the request context. Track creates that Record,
+// measures the request and hands a completed Event to all configured sinks
+// (webhooks, metrics, …) once the handler returns.
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tokens holds Anthropic token counters for a single request.
+type Tokens struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+}
+
+// Event describes a completed request.
+type Event struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Route     string    `json:"route,omitempty"` // Matched route pattern, e.g. /openai/deployments/{deployment}/chat/completions
+	Status    int       `json:"status"`
+	LatencyMS int64     `json:"latency_ms"`
+	Stream    bool      `json:"stream"`
+	Model     string    `json:"model,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Usage     Tokens    `json:"usage"`
+
+	// Tag attributes the request to a project or tool (X-Claudine-Tag header or
+	// claudine_tag in OpenAI metadata).
+	Tag string `json:"tag,omitempty"`
+
+	// Experiment and Arm identify the A/B routing assignment, if any.
+	Experiment string `json:"experiment,omitempty"`
+	Arm        string `json:"arm,omitempty"`
+
+	// Cached is set when the response was served from the response cache.
+	Cached bool `json:"cached,omitempty"`
+
+	// UpstreamStatus is the HTTP status returned by Anthropic (0 if the upstream was not reached).
+	UpstreamStatus int `json:"upstream_status,omitempty"`
+
+	// ErrorType is the Anthropic error type if the upstream reported an error.
+	ErrorType string `json:"error_type,omitempty"`
+
+	// TTFTMS is the time from receiving the request to the first streamed content
+	// token; UpstreamTTFTMS counts from handing the request to the upstream transport
+	// (including pacing, queueing and retries), so the difference is spent handling
+	// the request in the proxy. Both are 0 for buffered responses.
+	TTFTMS         int64 `json:"ttft_ms,omitempty"`
+	UpstreamTTFTMS int64 `json:"upstream_ttft_ms,omitempty"`
+
+	// TokensPerSecond is the output token rate after the first token of a stream.
+	TokensPerSecond float64 `json:"tokens_per_second,omitempty"`
+}
+
+// Sink consumes completion events. Implementations must not block the caller.
+type Sink interface {
+	Consume(ctx context.Context, event Event)
+}
+
+// Record accumulates usage data for a single in-flight request.
+// Populated concurrently by the upstream transport; all methods are thread-safe.
+type Record struct {
+	mu             sync.Mutex
+	model          string
+	tokens         Tokens
+	stream         bool
+	upstreamStatus int
+	errorType      string
+	experiment     string
+	arm            string
+	tag            string
+	cached         bool
+
+	start         time.Time // Request received
+	upstreamStart time.Time // First upstream attempt sent
+	firstToken    time.Time // First content delta streamed
+}
+
+type recordContextKey struct{}
+
+// WithRecord returns a context carrying a new, empty Record.
+func WithRecord(ctx context.Context) (context.Context, *Record) {
+	rec := &Record{start: time.Now()}
+	return context.WithValue(ctx, recordContextKey{}, rec), rec
+}
+
+// FromContext returns the Record stored in ctx, or nil.
+func FromContext(ctx context.Context) *Record {
+	rec, _ := ctx.Value(recordContextKey{}).(*Record)
+	return rec
+}
+
+// SetModel records the model that served the request.
+func (r *Record) SetModel(model string) {
+	if r == nil || model == "" {
+		return
+	}
+	r.mu.Lock()
+	r.model = model
+	r.mu.Unlock()
+}
+
+// SetExperiment records the A/B routing arm the request was assigned to.
+func (r *Record) SetExperiment(experiment, arm string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.experiment = experiment
+	r.arm = arm
+	r.mu.Unlock()
+}
+
+// SetTag records the cost attribution tag unless one was already set.
+// Invalid tags (see ValidTag) are ignored.
+func (r *Record) SetTag(tag string) {
+	if r == nil || !ValidTag(tag) {
+		return
+	}
+	r.mu.Lock()
+	if r.tag == "" {
+		r.tag = tag
+	}
+	r.mu.Unlock()
+}
+
+// Tag returns the cost attribution tag, or "".
+func (r *Record) Tag() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tag
+}
+
+// Tokens returns the token counters recorded so far.
+func (r *Record) Tokens() Tokens {
+	if r == nil {
+		return Tokens{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tokens
+}
+
+// SetCacheHit marks the request as served from the response cache.
+func (r *Record) SetCacheHit() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.cached = true
+	r.mu.Unlock()
+}
+
+// TTFT returns the time from receiving the request to the first streamed content
+// token, or 0 if none was streamed.
+func (r *Record) TTFT() time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.firstToken.IsZero() {
+		return 0
+	}
+	return r.firstToken.Sub(r.start)
+}
+
+// markUpstreamStart records when the first upstream attempt was sent.
+func (r *Record) markUpstreamStart(t time.Time) {
+	r.mu.Lock()
+	if r.upstreamStart.IsZero() {
+		r.upstreamStart = t
+	}
+	r.mu.Unlock()
+}
+
+// markFirstToken records when the first content delta was streamed.
+func (r *Record) markFirstToken(t time.Time) {
+	r.mu.Lock()
+	if r.firstToken.IsZero() {
+		r.firstToken = t
+	}
+	r.mu.Unlock()
+}
+
+// setUpstream records the upstream response status and whether it is a stream.
+func (r *Record) setUpstream(status int, stream bool) {
+	r.mu.Lock()
+	r.upstreamStatus = status
+	r.stream = stream
+	r.mu.Unlock()
+}
+
+// setErrorType records the Anthropic error type reported by the upstream.
+func (r *Record) setErrorType(errorType string) {
+	r.mu.Lock()
+	r.errorType = errorType
+	r.mu.Unlock()
+}
+
+// mergeTokens applies non-zero counters. Streaming responses report input tokens
+// in message_start and cumulative output tokens in message_delta.
+func (r *Record) mergeTokens(t Tokens) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.InputTokens > 0 {
+		r.tokens.InputTokens = t.InputTokens
+	}
+	if t.OutputTokens > 0 {
+		r.tokens.OutputTokens = t.OutputTokens
+	}
+	if t.CacheReadInputTokens > 0 {
+		r.tokens.CacheReadInputTokens = t.CacheReadInputTokens
+	}
+	if t.CacheCreationInputTokens > 0 {
+		r.tokens.CacheCreationInputTokens = t.CacheCreationInputTokens
+	}
+}
+
+// fill copies the accumulated data into e, a request completed at end.
+func (r *Record) fill(e *Event, end time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.Model = r.model
+	e.Usage = r.tokens
+	e.Stream = r.stream
+	e.UpstreamStatus = r.upstreamStatus
+	e.ErrorType = r.errorType
+	e.Experiment = r.experiment
+	e.Arm = r.arm
+	e.Tag = r.tag
+	e.Cached = r.cached
+
+	if !r.firstToken.IsZero() {
+		e.TTFTMS = r.firstToken.Sub(r.start).Milliseconds()
+		if !r.upstreamStart.IsZero() {
+			e.UpstreamTTFTMS = r.firstToken.Sub(r.upstreamStart).Milliseconds()
+		}
+		// The first token arrived with the first delta, so it doesn't count toward the rate
+		if elapsed := end.Sub(r.firstToken).Seconds(); elapsed > 0 && r.tokens.OutputTokens > 1 {
+			e.TokensPerSecond = float64(r.tokens.OutputTokens-1) / elapsed
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package usage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+type sinkFunc func(context.Context, Event)
+
+func (f sinkFunc) Consume(ctx context.Context, e Event) { f(ctx, e) }
+
+func TestTrack(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+		body        string
+		want        Event
+	}{
+		{
+			name:        "buffered message",
+			contentType: "application/json",
+			status:      http.StatusOK,
+			body:        `{"type":"message","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":12,"output_tokens":34,"cache_read_input_tokens":5}}`,
+			want: Event{
+				Status: http.StatusOK, UpstreamStatus: http.StatusOK, Model: "claude-sonnet-4-5",
+				Usage: Tokens{InputTokens: 12, OutputTokens: 34, CacheReadInputTokens: 5},
+			},
+		},
+		{
+			name:        "stream",
+			contentType: "text/event-stream",
+			status:      http.StatusOK,
+			body: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-haiku-4-5\",\"usage\":{\"input_tokens\":7,\"output_tokens\":1}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"\\\"error\\\"\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":42}}\n\n",
+			want: Event{
+				Status: http.StatusOK, UpstreamStatus: http.StatusOK, Stream: true, Model: "claude-haiku-4-5",
+				Usage: Tokens{InputTokens: 7, OutputTokens: 42},
+			},
+		},
+		{
+			name:        "upstream error",
+			contentType: "application/json",
+			status:      529,
+			body:        `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			want:        Event{Status: 529, UpstreamStatus: 529, ErrorType: "overloaded_error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &Transport{Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: tt.status,
+					Header:     http.Header{"Content-Type": {tt.contentType}},
+					Body:       io.NopCloser(strings.NewReader(tt.body)),
+				}, nil
+			})}
+
+			// Handler forwards the upstream response like the reverse proxy does
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, "http://upstream/v1/messages", nil)
+				resp, err := transport.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = resp.Body.Close() }()
+				w.WriteHeader(resp.StatusCode)
+				_, _ = io.Copy(w, resp.Body)
+			})
+
+			var got Event
+			sink := sinkFunc(func(_ context.Context, e Event) { got = e })
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			req.Header.Set("X-Api-Key", "secret")
+			Track([]Sink{sink})(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got.Key == "" || strings.Contains(got.Key, "secret") {
+				t.Errorf("Key = %q, want non-empty hash", got.Key)
+			}
+			if got.Status != tt.want.Status || got.UpstreamStatus != tt.want.UpstreamStatus ||
+				got.Stream != tt.want.Stream || got.Model != tt.want.Model ||
+				got.Usage != tt.want.Usage || got.ErrorType != tt.want.ErrorType {
+				t.Errorf("event = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		metadata string
+		want     string
+	}{
+		{"header", "team-search", "", "team-search"},
+		{"metadata", "", "cli/v2", "cli/v2"},
+		{"header wins", "team-search", "cli", "team-search"},
+		{"invalid header", "bad tag!", "cli", "cli"},
+		{"too long", strings.Repeat("a", maxTagLength+1), "", ""},
+		{"none", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				FromContext(r.Context()).SetTag(tt.metadata)
+			})
+
+			var got Event
+			sink := sinkFunc(func(_ context.Context, e Event) { got = e })
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			if tt.header != "" {
+				req.Header.Set(HeaderTag, tt.header)
+			}
+			Track([]Sink{sink})(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got.Tag != tt.want {
+				t.Errorf("Tag = %q, want %q", got.Tag, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackRoute(t *testing.T) {
+	var got Event
+	sink := sinkFunc(func(_ context.Context, e Event) { got = e })
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /openai/deployments/{deployment}/chat/completions", Track([]Sink{sink})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+
+	for _, deployment := range []string{"gpt-4o", "random-1234"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/openai/deployments/"+deployment+"/chat/completions", nil))
+		if want := "/openai/deployments/{deployment}/chat/completions"; got.Route != want {
+			t.Errorf("Route = %q, want %q", got.Route, want)
+		}
+		if !strings.Contains(got.Path, deployment) {
+			t.Errorf("Path = %q, want request path", got.Path)
+		}
+	}
+}
+
+// delayedReader returns chunks one per Read, each after delay.
+type delayedReader struct {
+	chunks []string
+	delay  time.Duration
+}
+
+func (r *delayedReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestTrackTTFT(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	transport := &Transport{Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body: io.NopCloser(&delayedReader{delay: delay, chunks: []string{
+				"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"claude-haiku-4-5\",\"usage\":{\"input_tokens\":7,\"output_tokens\":1}}}\n\n",
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n",
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":11}}\n\n",
+			}}),
+		}, nil
+	})}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay) // Time spent in the proxy
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, "http://upstream/v1/messages", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		_, _ = io.Copy(w, resp.Body)
+	})
+
+	var got Event
+	sink := sinkFunc(func(_ context.Context, e Event) { got = e })
+	rec := httptest.NewRecorder()
+	Track([]Sink{sink})(TTFTTrailer(true)(handler)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+
+	if got.UpstreamTTFTMS < (2*delay).Milliseconds() || got.TTFTMS < got.UpstreamTTFTMS+delay.Milliseconds() {
+		t.Errorf("TTFT = %dms, upstream %dms; want >= %dms upstream plus %dms in the proxy",
+			got.TTFTMS, got.UpstreamTTFTMS, (2 * delay).Milliseconds(), delay.Milliseconds())
+	}
+	// 10 tokens after the first within one delay
+	if got.TokensPerSecond <= 0 || got.TokensPerSecond > 10/delay.Seconds() {
+		t.Errorf("TokensPerSecond = %v, want (0, %v]", got.TokensPerSecond, 10/delay.Seconds())
+	}
+	if trailer := rec.Result().Trailer.Get(HeaderTTFT); trailer != strconv.FormatInt(got.TTFTMS, 10) {
+		t.Errorf("trailer = %q, want %d", trailer, got.TTFTMS)
+	}
+}
@@ -0,0 +1,218 @@
+// Package webhook delivers request completion events to external HTTP endpoints.
+//
+// Each event is POSTed as JSON with an HMAC-SHA256 signature so receivers can
+// verify authenticity:
+//
+//	X-Claudine-Timestamp: 1735689600
+//	X-Claudine-Signature: sha256=hex(HMAC(secret, timestamp + "." + body))
+//
+// Delivery is asynchronous and never delays client responses. Events are queued
+// in memory, retried with backoff and dropped (with a warning) when the queue is full.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+const (
+	// HeaderSignature carries the hex-encoded HMAC-SHA256 of timestamp + "." + body.
+	HeaderSignature = "X-Claudine-Signature"
+
+	// HeaderTimestamp carries the Unix timestamp included in the signature (replay protection).
+	HeaderTimestamp = "X-Claudine-Timestamp"
+)
+
+// Config describes a webhook endpoint.
+type Config struct {
+	URL        string
+	Secret     string
+	Timeout    time.Duration
+	MaxRetries int
+	QueueSize  int
+}
+
+// Dispatcher queues completion events and delivers them to a single endpoint.
+type Dispatcher struct {
+	cfg    Config
+	client *http.Client
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan usage.Event
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// Compile-time check that Dispatcher implements usage.Sink
+var _ usage.Sink = (*Dispatcher)(nil)
+
+// New creates a Dispatcher. Call Start to begin delivery.
+func New(cfg Config) (*Dispatcher, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("webhook URL cannot be empty")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+
+	return &Dispatcher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan usage.Event, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Consume enqueues an event without blocking. Events are dropped when the queue is full.
+func (d *Dispatcher) Consume(ctx context.Context, event usage.Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	select {
+	case d.queue <- event:
+	default:
+		slog.WarnContext(ctx, "webhook queue full, dropping event", "url", d.cfg.URL, "request_id", event.ID)
+	}
+}
+
+// Start delivers queued events in the background until Shutdown is called.
+func (d *Dispatcher) Start(ctx context.Context) {
+	// Detached from cancellation so queued events can still be drained on shutdown;
+	// Shutdown cancels it once its own deadline expires
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	d.mu.Lock()
+	d.cancel = cancel
+	d.mu.Unlock()
+
+	go func() {
+		defer close(d.done)
+		for event := range d.queue {
+			d.deliver(ctx, event)
+		}
+	}()
+}
+
+// Shutdown stops accepting events and waits until the queue is drained or ctx expires.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	cancel := d.cancel
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		// Abort pending retries instead of sleeping through their backoff
+		if cancel != nil {
+			cancel()
+		}
+		return fmt.Errorf("webhook %s: %d events not delivered: %w", d.cfg.URL, len(d.queue), ctx.Err())
+	}
+}
+
+// deliver sends a single event, retrying transient failures with exponential backoff.
+func (d *Dispatcher) deliver(ctx context.Context, event usage.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode webhook event", "error", err)
+		return
+	}
+
+	backoff := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := d.send(ctx, body)
+		if err == nil {
+			return
+		}
+		if attempt >= d.cfg.MaxRetries || !retryable(err) {
+			slog.WarnContext(ctx, "webhook delivery failed", "url", d.cfg.URL, "request_id", event.ID, "attempts", attempt+1, "error", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			slog.WarnContext(ctx, "webhook delivery aborted", "url", d.cfg.URL, "request_id", event.ID, "attempts", attempt+1, "error", err)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// statusError reports a non-2xx response from the receiver.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+// retryable reports whether a failed delivery may succeed later: network errors,
+// timeouts, rate limiting and server errors are retried, other rejections are final.
+func retryable(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	return se.code == http.StatusRequestTimeout || se.code == http.StatusTooManyRequests || se.code >= 500
+}
+
+func (d *Dispatcher) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claudine-proxy-webhook")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if d.cfg.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(d.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/internal/usage"
+)
+
+func TestDispatcherDelivers(t *testing.T) {
+	received := make(chan usage.Event, 1)
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(HeaderSignature), Sign("s3cret", r.Header.Get(HeaderTimestamp), body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		var e usage.Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		received <- e
+	}))
+	defer srv.Close()
+
+	d, err := New(Config{URL: srv.URL, Secret: "s3cret", MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	d.Start(context.Background())
+	d.Consume(context.Background(), usage.Event{ID: "req-1", Model: "claude-sonnet-4-5", Usage: usage.Tokens{OutputTokens: 3}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	select {
+	case e := <-received:
+		if e.ID != "req-1" || e.Model != "claude-sonnet-4-5" || e.Usage.OutputTokens != 3 {
+			t.Errorf("event = %+v", e)
+		}
+	default:
+		t.Fatal("event not delivered")
+	}
+}
+
+func TestDispatcherDoesNotRetryClientErrors(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	d, err := New(Config{URL: srv.URL, MaxRetries: 3})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	d.Start(context.Background())
+	d.Consume(context.Background(), usage.Event{ID: "req-1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("attempts = %d, want 1", n)
+	}
+}
+
+func TestDispatcherShutdownAbortsBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	// Backoff alone would take minutes
+	d, err := New(Config{URL: srv.URL, MaxRetries: 10})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	d.Start(context.Background())
+	d.Consume(context.Background(), usage.Event{ID: "req-1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown() error = nil, want deadline exceeded")
+	}
+
+	select {
+	case <-d.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery still retrying after Shutdown")
+	}
+}
@@ -0,0 +1,128 @@
+// Package claudine embeds the proxy as an http.Handler, so Go programs can serve
+// Anthropic's Messages API and the OpenAI compatibility layer with a Claude
+// subscription without running claudine as a separate process.
+//
+//	ts := tokensource.NewTokenSource(refreshToken, tokensource.Endpoint)
+//	handler, err := claudine.New(ts)
+//	if err != nil {
+//	  return err
+//	}
+//	http.Handle("/", handler)
+//
+// The handler serves the same routes as the standalone proxy, under /v1 by default.
+// Building requires GOEXPERIMENT=jsonv2; without it New returns an error.
+package claudine
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/internal/proxy"
+)
+
+// ReadinessChecker reports whether the embedding program is ready to serve
+// traffic, answering GET /health/readiness.
+type ReadinessChecker interface {
+	IsReady() bool
+}
+
+// Option configures the handler.
+type Option func(*config)
+
+type config struct {
+	readiness ReadinessChecker
+	proxy     []proxy.Option
+}
+
+// WithTransport sets the transport for upstream requests (timeouts, TLS, connection
+// pooling). Defaults to DefaultTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *config) {
+		c.proxy = append(c.proxy, proxy.WithTransport(rt))
+	}
+}
+
+// WithBaseURL overrides the upstream URL, https://api.anthropic.com/v1. Its path
+// is also the prefix of the served routes.
+func WithBaseURL(baseURL string) Option {
+	return func(c *config) {
+		c.proxy = append(c.proxy, proxy.WithBaseURL(baseURL))
+	}
+}
+
+// WithPassthrough forwards additional Anthropic API paths through the OAuth
+// transport. A trailing "/*" matches the path and everything below it.
+func WithPassthrough(patterns ...string) Option {
+	return func(c *config) {
+		c.proxy = append(c.proxy, proxy.WithPassthrough(patterns...))
+	}
+}
+
+// WithClientKeys forwards Anthropic API keys sent by clients instead of the
+// subscription's OAuth token.
+func WithClientKeys(enabled bool) Option {
+	return func(c *config) {
+		c.proxy = append(c.proxy, proxy.WithClientKeys(enabled))
+	}
+}
+
+// WithFallbackAPIKey sends requests with apiKey while the subscription's usage
+// limits are exhausted, instead of returning 429.
+func WithFallbackAPIKey(apiKey string) Option {
+	return func(c *config) {
+		c.proxy = append(c.proxy, proxy.WithFallbackAPIKey(apiKey))
+	}
+}
+
+// WithStreamIdleTimeout ends streaming responses that receive no upstream data
+// for d with an error event. Zero or negative disables the watchdog.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.proxy = append(c.proxy, proxy.WithStreamIdleTimeout(d))
+	}
+}
+
+// WithMiddleware adds middlewares to every API route, e.g. for custom
+// authentication or metrics. They run in order after request logging, panic
+// recovery and request ID handling; health endpoints are not wrapped.
+func WithMiddleware(middlewares ...func(http.Handler) http.Handler) Option {
+	return func(c *config) {
+		c.proxy = append(c.proxy, proxy.WithMiddleware(middlewares...))
+	}
+}
+
+// WithReadiness answers readiness probes with checker instead of always ready.
+func WithReadiness(checker ReadinessChecker) Option {
+	return func(c *config) {
+		c.readiness = checker
+	}
+}
+
+// DefaultTransport returns a new http.Transport configured for API requirements.
+// Modify it and pass it to WithTransport for custom timeouts.
+func DefaultTransport() *http.Transport {
+	return proxy.DefaultTransport()
+}
+
+// New returns a handler serving the proxy's routes, authenticating upstream
+// requests with tokens from ts. The handler holds no background resources; the
+// embedding program owns the server and its shutdown.
+func New(ts oauth2.TokenSource, opts ...Option) (http.Handler, error) {
+	cfg := &config{readiness: alwaysReady{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	p, err := proxy.New(ts, cfg.readiness, cfg.proxy...)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// alwaysReady treats the handler as ready as soon as it exists.
+type alwaysReady struct{}
+
+func (alwaysReady) IsReady() bool { return true }
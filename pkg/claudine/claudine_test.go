@@ -0,0 +1,81 @@
+//go:build goexperiment.jsonv2
+
+package claudine_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/florianilch/claudine-proxy/pkg/claudine"
+)
+
+type readiness bool
+
+func (r readiness) IsReady() bool { return bool(r) }
+
+func TestNew(t *testing.T) {
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_01"}`))
+	}))
+	defer upstream.Close()
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "access"})
+
+	tests := []struct {
+		name       string
+		opts       []claudine.Option
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantAuth   string
+	}{
+		{
+			name:       "messages",
+			method:     http.MethodPost,
+			path:       "/v1/messages",
+			body:       `{"model":"claude-sonnet-4-5","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}`,
+			wantStatus: http.StatusOK,
+			wantAuth:   "Bearer access",
+		},
+		{
+			name:       "ready by default",
+			method:     http.MethodGet,
+			path:       "/health/readiness",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "readiness checker",
+			opts:       []claudine.Option{claudine.WithReadiness(readiness(false))},
+			method:     http.MethodGet,
+			path:       "/health/readiness",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotAuth = ""
+			opts := append([]claudine.Option{claudine.WithBaseURL(upstream.URL + "/v1")}, tt.opts...)
+			handler, err := claudine.New(ts, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("upstream Authorization = %q, want %q", gotAuth, tt.wantAuth)
+			}
+		})
+	}
+}
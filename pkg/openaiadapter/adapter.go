@@ -0,0 +1,164 @@
+package openaiadapter
+
+//go:generate go run ./types/generate.go
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+// Adapter defines the contract for transforming client requests to provider API calls.
+//
+// Type parameters allow the interface to express transformation contracts for different
+// request/response shapes while maintaining compile-time type safety.
+//
+// Type parameters:
+//   - TRequest:  Client-specific request structure
+//   - TResponse: Client-specific response structure
+//   - TChunk:    Client-specific streaming chunk protocol
+type Adapter[TRequest, TResponse, TChunk any] interface {
+	// ProcessRequest transforms the client request, calls the provider API, and returns
+	// the transformed response. Implementations should remain stateless.
+	ProcessRequest(ctx context.Context, clientReq TRequest, transport http.RoundTripper) (*TResponse, error)
+
+	// ProcessStreamingRequest transforms the client request, calls the provider streaming API,
+	// and returns an iterator of transformed chunks. Implementations should remain stateless.
+	ProcessStreamingRequest(ctx context.Context, clientReq TRequest, transport http.RoundTripper) (iter.Seq2[*TChunk, error], error)
+}
+
+// Type aliases for OpenAI-compatible chat completion operations.
+// Request/response types are generated from OpenAPI spec (see types package).
+// CreateChatCompletionAdapter is the concrete adapter interface for this operation.
+type (
+	CreateChatCompletionRequest  = types.CreateChatCompletionRequest
+	CreateChatCompletionResponse = types.CreateChatCompletionResponse
+	CreateChatCompletionChunk    = types.CreateChatCompletionStreamResponse
+
+	CreateChatCompletionAdapter = Adapter[
+		CreateChatCompletionRequest,
+		CreateChatCompletionResponse,
+		CreateChatCompletionChunk,
+	]
+)
+
+// Type aliases for OpenAI-compatible Files API operations.
+// Multipart uploads and binary downloads don't fit the Adapter contract, see FilesAdapter.
+type (
+	File               = types.OpenAIFile
+	ListFilesResponse  = types.ListFilesResponse
+	DeleteFileResponse = types.DeleteFileResponse
+)
+
+// FilesAdapter maps OpenAI Files API operations to a provider's file storage.
+type FilesAdapter interface {
+	// Upload stores the file and returns its metadata.
+	Upload(ctx context.Context, file io.Reader, filename, contentType, purpose string, transport http.RoundTripper) (*File, error)
+
+	// List returns a page of files, starting after the given file ID.
+	List(ctx context.Context, after string, limit int64, transport http.RoundTripper) (*ListFilesResponse, error)
+
+	// Retrieve returns the metadata of a single file.
+	Retrieve(ctx context.Context, fileID string, transport http.RoundTripper) (*File, error)
+
+	// Content returns the provider response carrying the raw file content.
+	// Callers must close the response body.
+	Content(ctx context.Context, fileID string, transport http.RoundTripper) (*http.Response, error)
+
+	// Delete removes the file.
+	Delete(ctx context.Context, fileID string, transport http.RoundTripper) (*DeleteFileResponse, error)
+}
+
+// Type aliases for OpenAI-compatible error responses.
+// Error types are generated from OpenAPI spec (see types package).
+type (
+	Error         = types.Error
+	ErrorResponse = types.ErrorResponse
+	ErrorEvent    = types.ErrorEvent
+)
+
+// Transformation stages reported by TransformError.
+const (
+	TransformStageRequest  = "request"
+	TransformStageResponse = "response"
+)
+
+// TransformError marks failures converting between OpenAI and provider formats,
+// as opposed to provider or network errors. It unwraps to the ErrorResponse sent
+// to the client.
+type TransformError struct {
+	Stage string
+	*ErrorResponse
+}
+
+// Unwrap returns the client-facing error response.
+func (e *TransformError) Unwrap() error {
+	return e.ErrorResponse
+}
+
+// RateLimitErrorCode is the OpenAI error code of requests rejected by a rate limit.
+const RateLimitErrorCode = "rate_limit_exceeded"
+
+// RateLimitError marks requests rejected by a provider rate limit, with details
+// for clients to back off. It unwraps to the ErrorResponse sent to the client and
+// marshals to it with the details added to the error object.
+type RateLimitError struct {
+	*ErrorResponse
+
+	// RetryAfter is the number of seconds until the limit resets, 0 if unknown.
+	RetryAfter int
+
+	// Limit names the exhausted limit: "requests" or "tokens" for API rate limits,
+	// the window (e.g., "five_hour", "seven_day") for subscription usage limits,
+	// "" if unknown.
+	Limit string
+}
+
+// Unwrap returns the client-facing error response.
+func (e *RateLimitError) Unwrap() error {
+	return e.ErrorResponse
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e *RateLimitError) MarshalJSON() ([]byte, error) {
+	type details struct {
+		Error
+		RetryAfter int    `json:"retry_after,omitempty"`
+		Limit      string `json:"limit,omitempty"`
+	}
+	return json.Marshal(struct {
+		Err details `json:"error"`
+	}{details{Error: e.Err, RetryAfter: e.RetryAfter, Limit: e.Limit}})
+}
+
+// StreamInterruptedErrorCode is the OpenAI error code of streams the provider
+// stopped sending before the response was complete.
+const StreamInterruptedErrorCode = "upstream_stream_interrupted"
+
+// StreamInterruptedError marks streams cut off mid-response, e.g. by a connection
+// reset or a malformed event. It unwraps to the ErrorResponse sent to the client
+// and marshals to it with the usage reported until the interruption added.
+type StreamInterruptedError struct {
+	*ErrorResponse
+
+	// Usage holds the tokens reported before the interruption, nil if none.
+	// Output tokens are usually incomplete as the provider reports them last.
+	Usage *types.CompletionUsage
+}
+
+// Unwrap returns the client-facing error response.
+func (e *StreamInterruptedError) Unwrap() error {
+	return e.ErrorResponse
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e *StreamInterruptedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Err   Error                  `json:"error"`
+		Usage *types.CompletionUsage `json:"usage,omitempty"`
+	}{e.Err, e.Usage})
+}
@@ -0,0 +1,316 @@
+// Package adaptertest runs golden fixture tests against chat completion adapters.
+//
+// A fixture file holds a JSON array of turns, each capturing one request-response
+// cycle at every stage of the adapter pipeline: the OpenAI request a client sends,
+// the upstream request the adapter builds, the upstream response and the OpenAI
+// response (or chunks) the adapter returns. Turns of a file run in order against
+// one adapter instance, so multi-turn conversations are covered. Upstream fields
+// are named after Anthropic, the first provider; other adapters use them for their
+// own provider's payloads.
+//
+// Fixtures can be recorded from a live session with `claudine record`.
+package adaptertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/internal/record"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+// Turn represents a single request-response cycle for non-streaming tests.
+// Each field captures a stage in the adapter pipeline for assertion.
+type Turn = record.Turn
+
+// StreamingTurn represents a single streaming request-response cycle.
+// Each field captures a stage in the streaming adapter pipeline for assertion.
+type StreamingTurn = record.StreamingTurn
+
+// Fixture represents a test case loaded from a JSON file.
+type Fixture[T any] struct {
+	Name  string
+	Turns []T
+}
+
+// Transport captures HTTP requests and returns canned responses.
+type Transport struct {
+	CapturedRequest *http.Request
+	CapturedBody    []byte
+	ResponseBody    string
+	ResponseStatus  int
+}
+
+// RoundTrip implements http.RoundTripper interface.
+func (m *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.CapturedRequest = req
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.CapturedBody = body
+	if err := req.Body.Close(); err != nil {
+		return nil, err
+	}
+
+	// SSE requests need text/event-stream content type
+	contentType := "application/json"
+	if req.Header.Get("Accept") == "text/event-stream" {
+		contentType = "text/event-stream"
+	}
+
+	return &http.Response{
+		StatusCode: m.ResponseStatus,
+		Body:       io.NopCloser(strings.NewReader(m.ResponseBody)),
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Request:    req,
+	}, nil
+}
+
+// NormalizeJSON unmarshals and remarshals JSON to normalize whitespace and key order.
+func NormalizeJSON(t testing.TB, s string) string {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("Invalid JSON: %v\nJSON: %s", err, s)
+	}
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+	return string(normalized)
+}
+
+// AssertJSONEqual compares two JSON strings for semantic equality.
+func AssertJSONEqual(t testing.TB, got, want string) {
+	t.Helper()
+	gotNorm := NormalizeJSON(t, got)
+	wantNorm := NormalizeJSON(t, want)
+	if gotNorm != wantNorm {
+		t.Errorf("JSON mismatch:\ngot:  %s\nwant: %s", gotNorm, wantNorm)
+	}
+}
+
+// LoadFixtures loads all fixture files matching pattern, sorted by path.
+func LoadFixtures[T any](t testing.TB, pattern string) []Fixture[T] {
+	t.Helper()
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("Failed to glob pattern %s: %v", pattern, err)
+	}
+
+	if len(matches) == 0 {
+		t.Fatalf("No fixture files found for pattern: %s", pattern)
+	}
+
+	sort.Strings(matches)
+
+	var fixtures []Fixture[T]
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read fixture %s: %v", path, err)
+		}
+
+		// Normalize JSON: unmarshal and re-marshal compactly
+		// This ensures json.RawMessage fields contain compact JSON matching real API responses
+		var intermediate any
+		if err := json.Unmarshal(data, &intermediate); err != nil {
+			t.Fatalf("Failed to unmarshal fixture %s for normalization: %v", path, err)
+		}
+		compactData, err := json.Marshal(intermediate)
+		if err != nil {
+			t.Fatalf("Failed to re-marshal fixture %s: %v", path, err)
+		}
+
+		var turns []T
+		if err := json.Unmarshal(compactData, &turns); err != nil {
+			t.Fatalf("Failed to unmarshal fixture %s: %v", path, err)
+		}
+
+		basename := filepath.Base(path)
+		name := strings.TrimSuffix(basename, filepath.Ext(basename))
+
+		fixtures = append(fixtures, Fixture[T]{
+			Name:  name,
+			Turns: turns,
+		})
+	}
+
+	return fixtures
+}
+
+// RunBuffered runs the non-streaming fixtures matching pattern as parallel subtests,
+// each against a fresh adapter from newAdapter. upstreamPath must be contained in
+// the URL path of upstream requests.
+func RunBuffered(t *testing.T, pattern, upstreamPath string, newAdapter func() openaiadapter.CreateChatCompletionAdapter) {
+	t.Helper()
+	fixtures := LoadFixtures[Turn](t, pattern)
+
+	for _, fix := range fixtures {
+		t.Run(fix.Name, func(t *testing.T) {
+			t.Parallel()
+			adapter := newAdapter()
+
+			ctx := context.Background()
+
+			for i, turn := range fix.Turns {
+				t.Logf("Turn %d", i+1)
+
+				status := turn.AnthropicResponseStatus
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				mock := &Transport{
+					ResponseBody:   string(turn.AnthropicResponse),
+					ResponseStatus: status,
+				}
+
+				var openaiReq types.CreateChatCompletionRequest
+				if err := json.Unmarshal(turn.OpenAIRequest, &openaiReq); err != nil {
+					t.Fatalf("Failed to parse openaiRequest: %v", err)
+				}
+
+				response, err := adapter.ProcessRequest(ctx, openaiReq, mock)
+
+				if !isNull(turn.AnthropicRequest) {
+					if mock.CapturedRequest == nil {
+						t.Fatal("Expected upstream request, got none")
+					}
+					if !strings.Contains(mock.CapturedRequest.URL.Path, upstreamPath) {
+						t.Errorf("Expected request to %s endpoint, got: %s", upstreamPath, mock.CapturedRequest.URL.Path)
+					}
+
+					AssertJSONEqual(t, string(mock.CapturedBody), string(turn.AnthropicRequest))
+				}
+
+				// Handle both success and error responses
+				if err != nil {
+					var errorResponse *types.ErrorResponse
+					if !errors.As(err, &errorResponse) {
+						t.Fatalf("Expected types.ErrorResponse, got: %T", err)
+					}
+					gotResponse, marshalErr := json.Marshal(errorResponse)
+					if marshalErr != nil {
+						t.Fatalf("Failed to marshal error response: %v", marshalErr)
+					}
+					AssertJSONEqual(t, string(gotResponse), string(turn.OpenAIResponse))
+				} else {
+					gotResponse, marshalErr := json.Marshal(response)
+					if marshalErr != nil {
+						t.Fatalf("Failed to marshal response: %v", marshalErr)
+					}
+					AssertJSONEqual(t, string(gotResponse), string(turn.OpenAIResponse))
+				}
+			}
+		})
+	}
+}
+
+// RunStreaming runs the streaming fixtures matching pattern as parallel subtests,
+// each against a fresh adapter from newAdapter. Upstream SSE lines are replayed
+// with status 200. upstreamPath must be contained in the URL path of upstream
+// requests.
+func RunStreaming(t *testing.T, pattern, upstreamPath string, newAdapter func() openaiadapter.CreateChatCompletionAdapter) {
+	t.Helper()
+	fixtures := LoadFixtures[StreamingTurn](t, pattern)
+
+	for _, fix := range fixtures {
+		t.Run(fix.Name, func(t *testing.T) {
+			t.Parallel()
+			adapter := newAdapter()
+
+			ctx := context.Background()
+
+			for i, turn := range fix.Turns {
+				t.Logf("Turn %d", i+1)
+
+				// Setup mock transport with SSE response (join array into string)
+				mock := &Transport{
+					ResponseBody:   strings.Join(turn.AnthropicSSE, "\n"),
+					ResponseStatus: http.StatusOK,
+				}
+
+				var openaiReq types.CreateChatCompletionRequest
+				if err := json.Unmarshal(turn.OpenAIRequest, &openaiReq); err != nil {
+					t.Fatalf("Failed to parse openaiRequest: %v", err)
+				}
+
+				stream, err := adapter.ProcessStreamingRequest(ctx, openaiReq, mock)
+
+				// Handle both success and error responses; handle streaming errors during streaming
+				if err != nil {
+					var errorResponse *types.ErrorResponse
+					if !errors.As(err, &errorResponse) {
+						t.Fatalf("Expected types.ErrorResponse, got: %T", err)
+					}
+					gotResponse, marshalErr := json.Marshal(errorResponse)
+					if marshalErr != nil {
+						t.Fatalf("Failed to marshal error response: %v", marshalErr)
+					}
+					AssertJSONEqual(t, string(gotResponse), string(turn.OpenAIChunks[0]))
+					continue
+				}
+
+				if !isNull(turn.AnthropicRequest) {
+					if mock.CapturedRequest == nil {
+						t.Fatal("Expected upstream request, got none")
+					}
+					if !strings.Contains(mock.CapturedRequest.URL.Path, upstreamPath) {
+						t.Errorf("Expected request to %s endpoint, got: %s", upstreamPath, mock.CapturedRequest.URL.Path)
+					}
+
+					AssertJSONEqual(t, string(mock.CapturedBody), string(turn.AnthropicRequest))
+				}
+
+				var chunks []string
+				for chunk, err := range stream {
+					if err != nil {
+						var errorResponse *types.ErrorResponse
+						if !errors.As(err, &errorResponse) {
+							t.Fatalf("Expected types.ErrorResponse, got: %T", err)
+						}
+						chunkJSON, marshalErr := json.Marshal(errorResponse)
+						if marshalErr != nil {
+							t.Fatalf("Failed to marshal error chunk: %v", marshalErr)
+						}
+						chunks = append(chunks, string(chunkJSON))
+						break // Errors terminate stream (no more chunks expected)
+					}
+					chunkJSON, err := json.Marshal(chunk)
+					if err != nil {
+						t.Fatalf("Failed to marshal chunk: %v", err)
+					}
+					chunks = append(chunks, string(chunkJSON))
+				}
+
+				if len(chunks) != len(turn.OpenAIChunks) {
+					t.Errorf("Chunk count mismatch: got %d, want %d", len(chunks), len(turn.OpenAIChunks))
+					t.Fatalf("Got chunks:\n%s", strings.Join(chunks, "\n"))
+				}
+
+				for j, wantChunk := range turn.OpenAIChunks {
+					AssertJSONEqual(t, chunks[j], string(wantChunk))
+				}
+			}
+		})
+	}
+}
+
+// isNull reports whether a fixture field is absent or JSON null, meaning the
+// stage is not reached (e.g., validation fails before the upstream request).
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || bytes.Equal(raw, []byte("null"))
+}
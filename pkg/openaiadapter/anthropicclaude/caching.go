@@ -0,0 +1,159 @@
+package anthropicclaude
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+// PromptCache shapes requests of the same conversation for Anthropic's prompt cache.
+//
+// Anthropic caches prompt prefixes up to cache_control breakpoints, so a hit needs the
+// tools, system prompt and earlier turns to be byte-identical to the previous request.
+// Clients that rebuild system prompts or tool lists in varying order miss the cache on
+// every turn. For requests identifying their conversation, via prompt_cache_key or
+// metadata.conversation_id, PromptCache:
+//
+//   - keeps system blocks and tools in the order first seen for the conversation,
+//     appending new ones behind known ones
+//   - places breakpoints behind the system prompt (or the tools), on the final message
+//     and on the previous user turn, so each turn reads the cache written by the last
+//
+// Requests without a conversation key are left untouched.
+type PromptCache struct {
+	maxSessions int
+
+	mu       sync.Mutex
+	ll       *list.List
+	sessions map[string]*list.Element
+}
+
+// promptSession is the block order remembered for a conversation.
+type promptSession struct {
+	key    string
+	system []string // Digests of system blocks
+	tools  []string // Tool names
+}
+
+// NewPromptCache creates a PromptCache remembering up to maxSessions conversations,
+// evicting the least recently used.
+func NewPromptCache(maxSessions int) *PromptCache {
+	if maxSessions <= 0 {
+		maxSessions = 1024
+	}
+	return &PromptCache{maxSessions: maxSessions, ll: list.New(), sessions: make(map[string]*list.Element)}
+}
+
+// conversationKey identifies the conversation of a request, or returns "".
+func conversationKey(clientReq openaiadapter.CreateChatCompletionRequest) string {
+	if clientReq.PromptCacheKey != nil && *clientReq.PromptCacheKey != "" {
+		return *clientReq.PromptCacheKey
+	}
+	if clientReq.Metadata != nil {
+		return (*clientReq.Metadata)["conversation_id"]
+	}
+	return ""
+}
+
+// apply orders and marks params for the conversation identified by key.
+func (c *PromptCache) apply(key string, params *anthropic.MessageNewParams) {
+	c.mu.Lock()
+	session := c.session(key)
+	session.system, params.System = stableOrder(session.system, params.System, func(b anthropic.TextBlockParam) string {
+		sum := sha256.Sum256([]byte(b.Text))
+		return hex.EncodeToString(sum[:8])
+	})
+	session.tools, params.Tools = stableOrder(session.tools, params.Tools, func(t anthropic.ToolUnionParam) string {
+		if name := t.GetName(); name != nil {
+			return *name
+		}
+		return ""
+	})
+	c.mu.Unlock()
+
+	placeBreakpoints(params)
+}
+
+// session returns the session of key, creating it and evicting the least recently
+// used one if needed. Must be called with c.mu held.
+func (c *PromptCache) session(key string) *promptSession {
+	if el, ok := c.sessions[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*promptSession)
+	}
+	session := &promptSession{key: key}
+	c.sessions[key] = c.ll.PushFront(session)
+	if c.ll.Len() > c.maxSessions {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.sessions, oldest.Value.(*promptSession).key)
+	}
+	return session
+}
+
+// stableOrder sorts items by their position in known, keeping unknown items behind
+// in request order, and returns the updated order with the sorted items.
+func stableOrder[T any](known []string, items []T, id func(T) string) ([]string, []T) {
+	rank := make(map[string]int, len(known))
+	for i, k := range known {
+		rank[k] = i
+	}
+	position := func(item T) int {
+		if i, ok := rank[id(item)]; ok {
+			return i
+		}
+		return len(known)
+	}
+	slices.SortStableFunc(items, func(a, b T) int { return position(a) - position(b) })
+
+	order := slices.Clone(known)
+	for _, item := range items {
+		if _, ok := rank[id(item)]; !ok {
+			order = append(order, id(item))
+		}
+	}
+	return order, items
+}
+
+// placeBreakpoints marks up to three prefixes for caching: tools and system prompt,
+// the conversation up to the previous user turn and the whole conversation.
+// Anthropic allows four breakpoints; one is left for the client.
+func placeBreakpoints(params *anthropic.MessageNewParams) {
+	if last := len(params.System) - 1; last >= 0 {
+		params.System[last].CacheControl = anthropic.NewCacheControlEphemeralParam()
+	} else if last := len(params.Tools) - 1; last >= 0 {
+		if cc := params.Tools[last].GetCacheControl(); cc != nil {
+			*cc = anthropic.NewCacheControlEphemeralParam()
+		}
+	}
+
+	final := len(params.Messages) - 1
+	markMessage(params.Messages, final)
+	for i := final - 1; i >= 0; i-- {
+		if params.Messages[i].Role == anthropic.MessageParamRoleUser {
+			markMessage(params.Messages, i)
+			break
+		}
+	}
+}
+
+// markMessage sets a breakpoint on the last cacheable block of messages[i].
+// Thinking blocks can't carry one.
+func markMessage(messages []anthropic.MessageParam, i int) {
+	if i < 0 {
+		return
+	}
+	content := messages[i].Content
+	for j := len(content) - 1; j >= 0; j-- {
+		if cc := content[j].GetCacheControl(); cc != nil {
+			*cc = anthropic.NewCacheControlEphemeralParam()
+			return
+		}
+	}
+}
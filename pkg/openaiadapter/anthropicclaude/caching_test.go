@@ -0,0 +1,70 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestPromptCache(t *testing.T) {
+	const tools = `"tools":[{"type":"function","function":{"name":"read"}},{"type":"function","function":{"name":"write"}}]`
+	const swappedTools = `"tools":[{"type":"function","function":{"name":"write"}},{"type":"function","function":{"name":"read"}},{"type":"function","function":{"name":"list"}}]`
+
+	build := func(t *testing.T, adapter *CreateChatCompletionAdapter, request string) string {
+		t.Helper()
+		var req openaiadapter.CreateChatCompletionRequest
+		if err := json.Unmarshal([]byte(request), &req); err != nil {
+			t.Fatal(err)
+		}
+		params, err := adapter.buildParams(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	adapter := NewCreateChatCompletionAdapter()
+	adapter.PromptCache = NewPromptCache(1)
+
+	// The first turn fixes the order
+	build(t, adapter, `{"model":"m","prompt_cache_key":"c1",`+tools+`,"messages":[
+		{"role":"system","content":"rules"},{"role":"system","content":"context"},
+		{"role":"user","content":"hi"}]}`)
+
+	got := build(t, adapter, `{"model":"m","prompt_cache_key":"c1",`+swappedTools+`,"messages":[
+		{"role":"system","content":"context"},{"role":"system","content":"rules"},
+		{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"again"}]}`)
+
+	for _, want := range []string{
+		`"system":[{"text":"rules","type":"text"},{"text":"context","cache_control":{"type":"ephemeral"},"type":"text"}]`,
+		`"content":[{"text":"hi","cache_control":{"type":"ephemeral"},"type":"text"}],"role":"user"},{"content":[{"text":"hello","type":"text"}],"role":"assistant"}`,
+		`"content":[{"text":"again","cache_control":{"type":"ephemeral"},"type":"text"}]`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("request = %s\nwant it to contain %s", got, want)
+		}
+	}
+	if read, write, list := strings.Index(got, `"name":"read"`), strings.Index(got, `"name":"write"`), strings.Index(got, `"name":"list"`); read > write || write > list {
+		t.Errorf("tools not in first-seen order: %s", got)
+	}
+
+	// Requests without a conversation key are untouched
+	got = build(t, adapter, `{"model":"m","messages":[{"role":"system","content":"context"},{"role":"user","content":"hi"}]}`)
+	if strings.Contains(got, "cache_control") {
+		t.Errorf("unkeyed request got breakpoints: %s", got)
+	}
+
+	// Evicted conversations start over
+	build(t, adapter, `{"model":"m","metadata":{"conversation_id":"c2"},"messages":[{"role":"user","content":"hi"}]}`)
+	got = build(t, adapter, `{"model":"m","prompt_cache_key":"c1","messages":[
+		{"role":"system","content":"context"},{"role":"system","content":"rules"},{"role":"user","content":"hi"}]}`)
+	if !strings.Contains(got, `"system":[{"text":"context","type":"text"},{"text":"rules","cache_control":{"type":"ephemeral"},"type":"text"}]`) {
+		t.Errorf("request = %s, want request order after eviction", got)
+	}
+}
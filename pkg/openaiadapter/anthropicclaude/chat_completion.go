@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"iter"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/anthropics/anthropic-sdk-go/packages/ssestream"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter"
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/types"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
 )
 
 // CreateChatCompletionAdapter transforms generic OpenAI chat completion requests to Anthropic Messages.
@@ -20,7 +24,52 @@ import (
 //   - Developer messages: Merged with system prompts (no developer role equivalent)
 //   - Tool call IDs: Preserved bidirectionally for proper request/response matching
 //   - Streaming: Anthropic returns delta-based events similar to OpenAI protocol
-type CreateChatCompletionAdapter struct{}
+type CreateChatCompletionAdapter struct {
+	// Debug logs translated requests with conversation content replaced by digests,
+	// and summaries of responses (block types, stop reason, usage, sizes).
+	Debug bool
+
+	// RepairToolArguments validates streamed tool call arguments when their block
+	// ends. Arguments cut off mid-JSON are completed with a final delta; arguments
+	// that can't be repaired end the stream with finish_reason "length".
+	RepairToolArguments bool
+
+	// StrictRetries is how often a non-streaming request is repeated when tool call
+	// arguments don't match the schema of a tool declared with strict: true. Once
+	// retries are exhausted, and in streams, such calls fail with a
+	// strict_schema_violation error.
+	StrictRetries int
+
+	// DeveloperPlacement orders developer messages relative to system messages in
+	// the system prompt. By default, both keep their encounter order.
+	DeveloperPlacement DeveloperPlacement
+
+	// InlineSystemMessages keeps system and developer messages sent after the
+	// conversation started in place, as instruction blocks of a user turn, instead
+	// of hoisting them into the system prompt.
+	InlineSystemMessages bool
+
+	// PromptCache, if set, keeps block order stable and places cache breakpoints for
+	// requests naming their conversation via prompt_cache_key.
+	PromptCache *PromptCache
+
+	// ReasoningBudgets overrides the thinking budgets reasoning_effort maps to, per
+	// model. Models without an entry use DefaultReasoningBudget.
+	ReasoningBudgets ReasoningBudgets
+
+	// ReasoningContent, if set, streams extended thinking as delta.reasoning_content,
+	// raw or summarized. By default thinking is dropped.
+	ReasoningContent *ReasoningContent
+}
+
+// DeveloperPlacement positions developer messages in the system prompt.
+type DeveloperPlacement string
+
+const (
+	DeveloperInOrder      DeveloperPlacement = ""       // Encounter order, mixed with system messages
+	DeveloperBeforeSystem DeveloperPlacement = "before" // Ahead of all system messages
+	DeveloperAfterSystem  DeveloperPlacement = "after"  // Behind all system messages
+)
 
 // Compile-time interface implementation check.
 var _ openaiadapter.CreateChatCompletionAdapter = (*CreateChatCompletionAdapter)(nil)
@@ -48,6 +97,26 @@ type StreamingResponseContext struct {
 	// AnthropicMessage accumulates message metadata via selective Accumulate() calls.
 	// Only MessageStart/MessageDelta events are accumulated to avoid expensive content arrays.
 	AnthropicMessage anthropic.Message
+
+	// ToolArguments accumulates the JSON arguments of tool calls by Anthropic content
+	// block index. Only used when repairing tool arguments.
+	ToolArguments map[int64]*strings.Builder
+
+	// MalformedToolArguments is set when tool arguments could not be repaired.
+	MalformedToolArguments bool
+
+	// StrictSchemas holds the parameter schemas of strict tools by name.
+	StrictSchemas map[string]map[string]any
+
+	// SchemaViolation is set when the arguments of a strict tool call don't match its schema.
+	SchemaViolation error
+
+	// Prefill is the final assistant message being continued, sent along with the
+	// role in the first chunk when echoing it was requested.
+	Prefill string
+
+	// reasoning tracks thinking blocks when streaming reasoning content.
+	reasoning reasoningProgress
 }
 
 // NewCreateChatCompletionAdapter creates a new chat completion adapter.
@@ -66,14 +135,45 @@ func (a *CreateChatCompletionAdapter) ProcessRequest(
 		return nil, toChatCompletionError(err)
 	}
 
-	providerResp, err := a.callProviderAPI(ctx, clientReq, transport)
+	params, err := a.buildParams(clientReq)
 	if err != nil {
-		return nil, toChatCompletionError(err)
+		return nil, toTransformError(openaiadapter.TransformStageRequest, err)
+	}
+	if a.Debug {
+		logRequestSummary(ctx, params)
+	}
+
+	schemas := strictSchemas(clientReq.Tools)
+	var providerResp *anthropic.Message
+	for attempt := 0; ; attempt++ {
+		providerResp, err = a.callProviderAPI(ctx, params, requestOptions(clientReq, params.Messages), transport)
+		if err != nil {
+			return nil, toProviderError(err)
+		}
+		if a.Debug {
+			logResponseSummary(ctx, providerResp)
+		}
+
+		violation := strictSchemaViolation(schemas, providerResp.Content)
+		if violation == nil {
+			break
+		}
+		if attempt >= a.StrictRetries {
+			return nil, toStrictSchemaError(violation)
+		}
+		slog.WarnContext(ctx, "tool call violates strict schema, retrying", "error", violation, "attempt", attempt+1)
 	}
 
 	resp, err := a.transformResponse(providerResp)
 	if err != nil {
-		return nil, toChatCompletionError(err)
+		return nil, toTransformError(openaiadapter.TransformStageResponse, err)
+	}
+	if prefill := prefillText(params.Messages); prefill != "" && continueFinalMessage(clientReq) {
+		message := &resp.Choices[0].Message
+		if message.Content != nil {
+			prefill += *message.Content
+		}
+		message.Content = &prefill
 	}
 	return resp, nil
 }
@@ -89,9 +189,17 @@ func (a *CreateChatCompletionAdapter) ProcessStreamingRequest(
 		return nil, toChatCompletionError(err)
 	}
 
-	stream, err := a.callProviderAPIStreaming(ctx, clientReq, transport)
+	params, err := a.buildParams(clientReq)
 	if err != nil {
-		return nil, toChatCompletionError(err)
+		return nil, toTransformError(openaiadapter.TransformStageRequest, err)
+	}
+	if a.Debug {
+		logRequestSummary(ctx, params)
+	}
+
+	stream, err := a.callProviderAPIStreaming(ctx, params, requestOptions(clientReq, params.Messages), transport)
+	if err != nil {
+		return nil, toProviderError(err)
 	}
 
 	return func(yield func(*openaiadapter.CreateChatCompletionChunk, error) bool) {
@@ -101,28 +209,51 @@ func (a *CreateChatCompletionAdapter) ProcessStreamingRequest(
 			NextToolCallIndex:  0,
 			AnthropicToolIndex: make(map[int64]ToolIndexMapping),
 		}
+		streamingContext.StrictSchemas = strictSchemas(clientReq.Tools)
+		if continueFinalMessage(clientReq) {
+			streamingContext.Prefill = prefillText(params.Messages)
+		}
+		if a.RepairToolArguments || streamingContext.StrictSchemas != nil {
+			streamingContext.ToolArguments = make(map[int64]*strings.Builder)
+		}
+
+		var summary streamSummary
+		if a.Debug {
+			defer func() { summary.log(ctx, streamingContext.AnthropicMessage) }()
+		}
 
 		for stream.Next() {
 			event := stream.Current()
+			if a.Debug {
+				summary.add(event)
+			}
 
 			chunk, err := a.transformStreamEvent(&streamingContext, event)
 			if err != nil {
-				yield(nil, toChatCompletionError(err))
+				yield(nil, toTransformError(openaiadapter.TransformStageResponse, err))
 				return
 			}
 
-			if chunk == nil {
-				continue
+			if chunk != nil && !yield(chunk, nil) {
+				return
 			}
 
-			if !yield(chunk, nil) {
+			if streamingContext.SchemaViolation != nil {
+				yield(nil, toStrictSchemaError(streamingContext.SchemaViolation))
 				return
 			}
 		}
 
-		if err := stream.Err(); err != nil {
-			yield(nil, toChatCompletionError(err))
-			return
+		// The response is complete once its stop reason arrived, message_stop
+		// carries no data
+		err := stream.Err()
+		switch {
+		case err != nil && (ctx.Err() != nil || isProviderError(err)):
+			yield(nil, toProviderError(err))
+		case err != nil || streamingContext.AnthropicMessage.StopReason == "":
+			// Connection lost or malformed event: tell the client instead of
+			// ending as if the response was complete
+			yield(nil, toStreamInterruptedError(err, streamingContext.AnthropicMessage.Usage))
 		}
 	}, nil
 }
@@ -137,36 +268,54 @@ func (a *CreateChatCompletionAdapter) validateRequest(
 	if len(clientReq.Messages) == 0 {
 		return fmt.Errorf("messages array cannot be empty")
 	}
+	if err := validatePrefill(clientReq); err != nil {
+		return err
+	}
+	if err := validateContainer(clientReq); err != nil {
+		return err
+	}
 
 	return nil
 }
 
-// callProviderAPI transforms the request and calls Anthropic's non-streaming API.
-func (a *CreateChatCompletionAdapter) callProviderAPI(
-	ctx context.Context,
+// buildParams transforms the OpenAI request into Anthropic Messages parameters.
+func (a *CreateChatCompletionAdapter) buildParams(
 	clientReq openaiadapter.CreateChatCompletionRequest,
-	transport http.RoundTripper,
-) (*anthropic.Message, error) {
-	client, err := newClient(transport)
-	if err != nil {
-		return nil, fmt.Errorf("initialize Anthropic client for non-streaming request: %w", err)
-	}
-
+) (anthropic.MessageNewParams, error) {
 	// Transform and separate OpenAI messages - preserves order while hoisting system prompts
 	transformed, err := fromChatCompletionRequestMessages(clientReq.Messages)
 	if err != nil {
-		return nil, fmt.Errorf("transform messages: %w", err)
+		return anthropic.MessageNewParams{}, fmt.Errorf("transform messages: %w", err)
 	}
-	systemPrompts, messages := hoistSystemPrompts(transformed)
+	systemPrompts, messages := a.hoistSystemPrompts(transformed)
 
-	params, err := buildGenerationParams(clientReq)
+	params, err := buildGenerationParams(clientReq, a.ReasoningBudgets)
 	if err != nil {
-		return nil, fmt.Errorf("build generation params: %w", err)
+		return anthropic.MessageNewParams{}, fmt.Errorf("build generation params: %w", err)
 	}
-	params.Messages = messages
+	params.Messages = trimPrefill(messages)
 	params.System = systemPrompts
+	if a.PromptCache != nil {
+		if key := conversationKey(clientReq); key != "" {
+			a.PromptCache.apply(key, &params)
+		}
+	}
+	return params, nil
+}
+
+// callProviderAPI calls Anthropic's non-streaming API with transformed parameters.
+func (a *CreateChatCompletionAdapter) callProviderAPI(
+	ctx context.Context,
+	params anthropic.MessageNewParams,
+	opts []option.RequestOption,
+	transport http.RoundTripper,
+) (*anthropic.Message, error) {
+	client, err := newClient(transport)
+	if err != nil {
+		return nil, fmt.Errorf("initialize Anthropic client for non-streaming request: %w", err)
+	}
 
-	message, err := client.Messages.New(ctx, params)
+	message, err := client.Messages.New(ctx, params, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -174,10 +323,11 @@ func (a *CreateChatCompletionAdapter) callProviderAPI(
 	return message, nil
 }
 
-// callProviderAPIStreaming transforms the request and calls Anthropic's streaming API.
+// callProviderAPIStreaming calls Anthropic's streaming API with transformed parameters.
 func (a *CreateChatCompletionAdapter) callProviderAPIStreaming(
 	ctx context.Context,
-	clientReq openaiadapter.CreateChatCompletionRequest,
+	params anthropic.MessageNewParams,
+	opts []option.RequestOption,
 	transport http.RoundTripper,
 ) (*ssestream.Stream[anthropic.MessageStreamEventUnion], error) {
 	client, err := newClient(transport)
@@ -185,21 +335,7 @@ func (a *CreateChatCompletionAdapter) callProviderAPIStreaming(
 		return nil, fmt.Errorf("initialize Anthropic client for streaming request: %w", err)
 	}
 
-	// Transform and separate OpenAI messages - preserves order while hoisting system prompts
-	transformed, err := fromChatCompletionRequestMessages(clientReq.Messages)
-	if err != nil {
-		return nil, fmt.Errorf("transform messages: %w", err)
-	}
-	systemPrompts, messages := hoistSystemPrompts(transformed)
-
-	params, err := buildGenerationParams(clientReq)
-	if err != nil {
-		return nil, fmt.Errorf("build generation params: %w", err)
-	}
-	params.Messages = messages
-	params.System = systemPrompts
-
-	stream := client.Messages.NewStreaming(ctx, params)
+	stream := client.Messages.NewStreaming(ctx, params, opts...)
 	return stream, nil
 }
 
@@ -307,8 +443,9 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 	// Event lifecycle transformation:
 	//   message_start       → emit role
 	//   content_block_start → emit tool metadata (tool_use only), skip text/thinking
-	//   content_block_delta → emit text/tool JSON deltas, skip thinking/citations/signatures
-	//   content_block_stop  → skip (no-op)
+	//   content_block_delta → emit text/tool JSON deltas and thinking as reasoning content
+	//                         if enabled, skip citations/signatures
+	//   content_block_stop  → emit remaining tool arguments or thinking summary, if any
 	//   message_delta       → emit finish_reason + usage (final data arrives here)
 	//   message_stop        → skip (termination signal, no data)
 	switch eventType := event.AsAny().(type) {
@@ -326,8 +463,12 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 
 		// OpenAI protocol: first chunk contains only role, subsequent chunks omit role
 		assistantRole := types.ChatCompletionStreamResponseDeltaRoleAssistant
+		delta := types.ChatCompletionStreamResponseDelta{Role: &assistantRole}
+		if streamingContext.Prefill != "" {
+			delta.Content = &streamingContext.Prefill
+		}
 		return a.newStreamChunk(
-			types.ChatCompletionStreamResponseDelta{Role: &assistantRole},
+			delta,
 			nil, // Finish reason comes in MessageDeltaEvent
 			streamingContext.AnthropicMessage.ID,
 			string(streamingContext.AnthropicMessage.Model),
@@ -340,6 +481,11 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 			return nil, nil // Content comes in delta events
 		}
 
+		if eventType.ContentBlock.Type == "thinking" && a.ReasoningContent != nil {
+			streamingContext.reasoning.start(eventType.Index)
+			return nil, nil // Thinking comes in delta events
+		}
+
 		if eventType.ContentBlock.Type == "tool_use" {
 			// OpenAI requires initial chunk with id/name/args="" before JSON deltas
 			toolID := eventType.ContentBlock.ID
@@ -403,26 +549,29 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 				return nil, fmt.Errorf("received InputJSONDelta for unknown tool at index %d", eventType.Index)
 			}
 
-			toolCall := types.ChatCompletionMessageToolCallChunk{
-				Index: toolMetadata.OpenAIToolCallIdx,
-				Function: &struct {
-					Arguments *string `json:"arguments,omitempty"`
-					Name      *string `json:"name,omitempty"`
-				}{
-					Arguments: &deltaVariant.PartialJSON,
-				},
+			if streamingContext.ToolArguments != nil {
+				args, ok := streamingContext.ToolArguments[eventType.Index]
+				if !ok {
+					args = &strings.Builder{}
+					streamingContext.ToolArguments[eventType.Index] = args
+				}
+				args.WriteString(deltaVariant.PartialJSON)
 			}
 
-			var toolCallItem types.ChatCompletionStreamResponseDelta_ToolCalls_Item
-			if err := toolCallItem.FromChatCompletionMessageToolCallChunk(toolCall); err != nil {
-				return nil, fmt.Errorf("create tool argument delta chunk: %w", err)
+			toolCalls, err := toolArgumentsDelta(toolMetadata.OpenAIToolCallIdx, deltaVariant.PartialJSON)
+			if err != nil {
+				return nil, err
 			}
-
-			toolCalls := []types.ChatCompletionStreamResponseDelta_ToolCalls_Item{toolCallItem}
-			delta.ToolCalls = &toolCalls
+			delta.ToolCalls = toolCalls
 		case anthropic.ThinkingDelta:
-			// Skip: would break round-trips (clients would echo thinking as regular messages)
-			return nil, nil
+			// Only as reasoning content: as regular content, clients would echo thinking
+			// back as assistant messages
+			if a.ReasoningContent == nil {
+				return nil, nil
+			}
+			if reasoning := streamingContext.reasoning.delta(a.ReasoningContent, deltaVariant.Thinking); reasoning != "" {
+				delta.ReasoningContent = &reasoning
+			}
 		case anthropic.CitationsDelta:
 			// Skip: no OpenAI equivalent
 			return nil, nil
@@ -431,7 +580,7 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 			return nil, nil
 		}
 
-		if delta.Content == nil && delta.ToolCalls == nil {
+		if delta.Content == nil && delta.ToolCalls == nil && delta.ReasoningContent == nil {
 			return nil, nil
 		}
 
@@ -445,7 +594,57 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 
 	// Content block finished
 	case anthropic.ContentBlockStopEvent:
-		return nil, nil // Content already streamed via start/delta events
+		if a.ReasoningContent != nil {
+			if reasoning := streamingContext.reasoning.stop(a.ReasoningContent, eventType.Index); reasoning != "" {
+				return a.newStreamChunk(
+					types.ChatCompletionStreamResponseDelta{ReasoningContent: &reasoning},
+					nil, // No finish reason yet
+					streamingContext.AnthropicMessage.ID,
+					string(streamingContext.AnthropicMessage.Model),
+					nil, // No usage yet
+				), nil
+			}
+		}
+
+		toolMetadata, isTool := streamingContext.AnthropicToolIndex[eventType.Index]
+		if streamingContext.ToolArguments == nil || !isTool {
+			return nil, nil // Content already streamed via start/delta events
+		}
+
+		var args string
+		if builder, ok := streamingContext.ToolArguments[eventType.Index]; ok {
+			args = builder.String()
+		}
+
+		// Complete truncated tool arguments before the client parses them
+		var chunk *openaiadapter.CreateChatCompletionChunk
+		if a.RepairToolArguments {
+			suffix, ok := completeToolArguments(args)
+			switch {
+			case !ok:
+				streamingContext.MalformedToolArguments = true
+			case suffix != "":
+				args += suffix
+				toolCalls, err := toolArgumentsDelta(toolMetadata.OpenAIToolCallIdx, suffix)
+				if err != nil {
+					return nil, err
+				}
+				chunk = a.newStreamChunk(
+					types.ChatCompletionStreamResponseDelta{ToolCalls: toolCalls},
+					nil, // No finish reason yet
+					streamingContext.AnthropicMessage.ID,
+					string(streamingContext.AnthropicMessage.Model),
+					nil, // No usage yet
+				)
+			}
+		}
+
+		if schema, ok := streamingContext.StrictSchemas[toolMetadata.Name]; ok {
+			if err := validateToolArguments(schema, args); err != nil {
+				streamingContext.SchemaViolation = fmt.Errorf("tool %s: %w", toolMetadata.Name, err)
+			}
+		}
+		return chunk, nil
 
 	// StopReason and final OutputTokens arrive here (not in MessageStopEvent)
 	case anthropic.MessageDeltaEvent:
@@ -456,6 +655,10 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 
 		// Final chunk with finish_reason and usage (content already streamed in deltas)
 		finishReason := toFinishReasonStreaming(streamingContext.AnthropicMessage.StopReason)
+		if streamingContext.MalformedToolArguments {
+			// Unusable tool arguments: report the response as cut off
+			finishReason = types.CreateChatCompletionStreamResponseChoiceFinishReasonLength
+		}
 		return a.newStreamChunk(
 			types.ChatCompletionStreamResponseDelta{},
 			&finishReason,
@@ -474,16 +677,49 @@ func (a *CreateChatCompletionAdapter) transformStreamEvent(
 	}
 }
 
+// toolArgumentsDelta builds the tool call delta carrying a fragment of the JSON
+// arguments of the tool call at index.
+func toolArgumentsDelta(index int, arguments string) (*[]types.ChatCompletionStreamResponseDelta_ToolCalls_Item, error) {
+	toolCall := types.ChatCompletionMessageToolCallChunk{
+		Index: index,
+		Function: &struct {
+			Arguments *string `json:"arguments,omitempty"`
+			Name      *string `json:"name,omitempty"`
+		}{
+			Arguments: &arguments,
+		},
+	}
+
+	var toolCallItem types.ChatCompletionStreamResponseDelta_ToolCalls_Item
+	if err := toolCallItem.FromChatCompletionMessageToolCallChunk(toolCall); err != nil {
+		return nil, fmt.Errorf("create tool argument delta chunk: %w", err)
+	}
+
+	toolCalls := []types.ChatCompletionStreamResponseDelta_ToolCalls_Item{toolCallItem}
+	return &toolCalls, nil
+}
+
 // hoistSystemPrompts separates system/developer messages from conversation messages.
 // Anthropic requires system prompts in a dedicated System field rather than the Messages array.
-func hoistSystemPrompts(transformed []transformedMessage) ([]anthropic.TextBlockParam, []anthropic.MessageParam) {
-	var systemPrompts []anthropic.TextBlockParam
+// Developer messages are ordered by DeveloperPlacement; with InlineSystemMessages, those
+// following the first conversation message stay in the conversation.
+func (a *CreateChatCompletionAdapter) hoistSystemPrompts(transformed []transformedMessage) ([]anthropic.TextBlockParam, []anthropic.MessageParam) {
+	var systemPrompts, developerPrompts []anthropic.TextBlockParam
 	var messages []anthropic.MessageParam
 
 	for _, msg := range transformed {
 		switch msg.Role {
 		case string(types.System), string(types.ChatCompletionRequestDeveloperMessageRoleDeveloper):
-			if textBlock, ok := msg.Content.(*anthropic.TextBlockParam); ok {
+			textBlock, ok := msg.Content.(*anthropic.TextBlockParam)
+			if !ok {
+				continue
+			}
+			switch {
+			case a.InlineSystemMessages && len(messages) > 0:
+				messages = appendInstruction(messages, msg.Role, textBlock.Text)
+			case msg.Role == string(types.ChatCompletionRequestDeveloperMessageRoleDeveloper) && a.DeveloperPlacement != DeveloperInOrder:
+				developerPrompts = append(developerPrompts, *textBlock)
+			default:
 				systemPrompts = append(systemPrompts, *textBlock)
 			}
 		case string(types.User), string(types.ChatCompletionRequestAssistantMessageRoleAssistant), string(types.Tool):
@@ -493,5 +729,23 @@ func hoistSystemPrompts(transformed []transformedMessage) ([]anthropic.TextBlock
 		}
 	}
 
+	switch a.DeveloperPlacement {
+	case DeveloperBeforeSystem:
+		systemPrompts = append(developerPrompts, systemPrompts...)
+	case DeveloperAfterSystem:
+		systemPrompts = append(systemPrompts, developerPrompts...)
+	}
 	return systemPrompts, messages
 }
+
+// appendInstruction adds a mid-conversation system or developer message as a text
+// block tagged with its role to the conversation, joining a preceding user turn
+// to keep roles alternating.
+func appendInstruction(messages []anthropic.MessageParam, role, text string) []anthropic.MessageParam {
+	block := anthropic.NewTextBlock("<" + role + ">\n" + text + "\n</" + role + ">")
+	if last := len(messages) - 1; messages[last].Role == anthropic.MessageParamRoleUser {
+		messages[last].Content = append(slices.Clip(messages[last].Content), block)
+		return messages
+	}
+	return append(messages, anthropic.NewUserMessage(block))
+}
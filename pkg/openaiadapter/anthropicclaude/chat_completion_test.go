@@ -0,0 +1,115 @@
+package anthropicclaude_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/adaptertest"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/anthropicclaude"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+func newAdapter() openaiadapter.CreateChatCompletionAdapter {
+	return anthropicclaude.NewCreateChatCompletionAdapter()
+}
+
+func TestCreateChatCompletionAdapter_Buffered(t *testing.T) {
+	t.Parallel()
+	adaptertest.RunBuffered(t, "testdata/buffered/*.json", "/messages", newAdapter)
+}
+
+func TestCreateChatCompletionAdapter_Streaming(t *testing.T) {
+	t.Parallel()
+	adaptertest.RunStreaming(t, "testdata/streaming/*.json", "/messages", newAdapter)
+}
+
+func BenchmarkCreateChatCompletion_Buffered(b *testing.B) {
+	data, err := os.ReadFile("testdata/buffered/tool_use.json")
+	if err != nil {
+		b.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var turns []adaptertest.Turn
+	if err := json.Unmarshal(data, &turns); err != nil {
+		b.Fatalf("Failed to unmarshal fixture: %v", err)
+	}
+
+	if len(turns) == 0 {
+		b.Fatal("No turns in fixture")
+	}
+
+	firstTurn := turns[0]
+	var openaiReq types.CreateChatCompletionRequest
+	if err := json.Unmarshal(firstTurn.OpenAIRequest, &openaiReq); err != nil {
+		b.Fatalf("Failed to parse openaiRequest: %v", err)
+	}
+
+	adapter := anthropicclaude.NewCreateChatCompletionAdapter()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		mock := &adaptertest.Transport{
+			ResponseBody:   string(firstTurn.AnthropicResponse),
+			ResponseStatus: http.StatusOK,
+		}
+
+		_, err := adapter.ProcessRequest(ctx, openaiReq, mock)
+		if err != nil {
+			b.Fatalf("ProcessRequest failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkCreateChatCompletion_Streaming(b *testing.B) {
+	data, err := os.ReadFile("testdata/streaming/tool_use_stream.json")
+	if err != nil {
+		b.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var turns []adaptertest.StreamingTurn
+	if err := json.Unmarshal(data, &turns); err != nil {
+		b.Fatalf("Failed to unmarshal fixture: %v", err)
+	}
+
+	if len(turns) == 0 {
+		b.Fatal("No turns in fixture")
+	}
+
+	firstTurn := turns[0]
+	var openaiReq types.CreateChatCompletionRequest
+	if err := json.Unmarshal(firstTurn.OpenAIRequest, &openaiReq); err != nil {
+		b.Fatalf("Failed to parse openaiRequest: %v", err)
+	}
+
+	adapter := anthropicclaude.NewCreateChatCompletionAdapter()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		mock := &adaptertest.Transport{
+			ResponseBody:   strings.Join(firstTurn.AnthropicSSE, "\n"),
+			ResponseStatus: http.StatusOK,
+		}
+
+		stream, err := adapter.ProcessStreamingRequest(ctx, openaiReq, mock)
+		if err != nil {
+			b.Fatalf("ProcessStreamingRequest failed: %v", err)
+		}
+
+		// Streams must be fully consumed to measure realistic performance
+		for chunk, err := range stream {
+			if err != nil {
+				b.Fatalf("Stream error: %v", err)
+			}
+			_ = chunk
+		}
+	}
+}
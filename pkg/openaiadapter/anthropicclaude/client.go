@@ -0,0 +1,67 @@
+package anthropicclaude
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+// newClient creates a new Anthropic client with the provided transport.
+// The transport chain needs to handle authentication.
+func newClient(transport http.RoundTripper) (*anthropic.Client, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("transport cannot be nil")
+	}
+
+	httpClient := &http.Client{
+		Transport: transport,
+		// Client.Timeout = 0 allows long-running SSE streams (bounded by server WriteTimeout)
+	}
+
+	client := anthropic.NewClient(
+		option.WithHTTPClient(httpClient),
+		// Generous RequestTimeout bypasses SDK maxTokens checks - actual limit enforced by server WriteTimeout
+		option.WithRequestTimeout(1*time.Hour),
+	)
+
+	return &client, nil
+}
+
+// filesBeta enables references to files uploaded through the Files API.
+const filesBeta = "files-api-2025-04-14"
+
+// requestOptions returns the per-request options needed for the given messages and
+// the container passed through extra_body (see validateContainer).
+func requestOptions(clientReq openaiadapter.CreateChatCompletionRequest, messages []anthropic.MessageParam) []option.RequestOption {
+	var opts []option.RequestOption
+	fields, beta := requestExtensions(clientReq, messages)
+	for key, value := range fields {
+		opts = append(opts, option.WithJSONSet(key, value))
+	}
+	if beta != "" {
+		opts = append(opts, option.WithHeader("anthropic-beta", beta))
+	}
+	return opts
+}
+
+// requestExtensions returns the body fields, as sjson paths, and the anthropic-beta
+// header added to the request params for the given messages and container.
+func requestExtensions(clientReq openaiadapter.CreateChatCompletionRequest, messages []anthropic.MessageParam) (map[string]any, string) {
+	fields, betas := containerFields(clientReq)
+	if usesFileReferences(messages) {
+		betas = append(betas, filesBeta)
+	}
+	if len(betas) == 0 {
+		return fields, ""
+	}
+	// A single header, as the proxy merges only the first value with its own features
+	slices.Sort(betas)
+	return fields, strings.Join(slices.Compact(betas), ",")
+}
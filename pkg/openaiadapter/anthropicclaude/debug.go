@@ -0,0 +1,173 @@
+package anthropicclaude
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+// structuralKeys hold identifiers and enums rather than conversation content and
+// are logged verbatim in debug summaries.
+var structuralKeys = map[string]bool{
+	"type":        true,
+	"role":        true,
+	"id":          true,
+	"tool_use_id": true,
+	"name":        true,
+	"media_type":  true,
+	"file_id":     true,
+}
+
+// logRequestSummary logs the translated Anthropic request with all conversation
+// content in messages and system replaced by digests (see digest).
+func logRequestSummary(ctx context.Context, params anthropic.MessageNewParams) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		slog.DebugContext(ctx, "failed to encode request for debug log", "error", err)
+		return
+	}
+	body, err := redactedBody(params, "messages", "system")
+	if err != nil {
+		slog.DebugContext(ctx, "failed to decode request for debug log", "error", err)
+		return
+	}
+
+	redacted, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+	slog.InfoContext(ctx, "adapter request", "bytes", len(raw), "body", string(redacted))
+}
+
+// RequestDiff shows an OpenAI chat completion request next to the Anthropic
+// request it is translated to. Conversation content in messages and system is
+// replaced by digests on both sides, so equal content can still be matched up.
+type RequestDiff struct {
+	OpenAI    map[string]any `json:"openai"`
+	Anthropic map[string]any `json:"anthropic"`
+
+	// Headers holds headers the adapter adds to the Anthropic request.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Diff translates clientReq as ProcessRequest would, without sending it, and
+// returns both requests redacted.
+func (a *CreateChatCompletionAdapter) Diff(clientReq openaiadapter.CreateChatCompletionRequest) (*RequestDiff, error) {
+	anthropicBody, headers, err := a.translate(clientReq)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"messages", "system"} {
+		if v, ok := anthropicBody[key]; ok {
+			anthropicBody[key] = redactContent(v)
+		}
+	}
+
+	openAI, err := redactedBody(clientReq, "messages")
+	if err != nil {
+		return nil, toTransformError(openaiadapter.TransformStageRequest, err)
+	}
+	// Unset parameters are encoded as null and would only obscure the mapping
+	maps.DeleteFunc(openAI, func(_ string, v any) bool { return v == nil })
+
+	return &RequestDiff{OpenAI: openAI, Anthropic: anthropicBody, Headers: headers}, nil
+}
+
+// redactedBody returns v as a JSON object with content under keys replaced by
+// digests (see redactContent).
+func redactedBody(v any, keys ...string) (map[string]any, error) {
+	body, err := jsonObject(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if v, ok := body[key]; ok {
+			body[key] = redactContent(v)
+		}
+	}
+	return body, nil
+}
+
+// logResponseSummary logs the shape of a non-streaming Anthropic response.
+func logResponseSummary(ctx context.Context, message *anthropic.Message) {
+	blocks := make([]string, len(message.Content))
+	for i, block := range message.Content {
+		blocks[i] = block.Type
+	}
+	slog.InfoContext(ctx, "adapter response",
+		"id", message.ID,
+		"model", message.Model,
+		"stop_reason", message.StopReason,
+		"blocks", blocks,
+		"input_tokens", message.Usage.InputTokens,
+		"output_tokens", message.Usage.OutputTokens,
+		"bytes", len(message.RawJSON()),
+	)
+}
+
+// streamSummary accumulates the shape of a streaming Anthropic response.
+type streamSummary struct {
+	events int
+	bytes  int
+	blocks []string
+}
+
+func (s *streamSummary) add(event anthropic.MessageStreamEventUnion) {
+	s.events++
+	s.bytes += len(event.RawJSON())
+	if start, ok := event.AsAny().(anthropic.ContentBlockStartEvent); ok {
+		s.blocks = append(s.blocks, start.ContentBlock.Type)
+	}
+}
+
+// log logs the summary with the metadata accumulated from message events.
+func (s *streamSummary) log(ctx context.Context, message anthropic.Message) {
+	slog.InfoContext(ctx, "adapter stream response",
+		"id", message.ID,
+		"model", message.Model,
+		"stop_reason", message.StopReason,
+		"blocks", s.blocks,
+		"input_tokens", message.Usage.InputTokens,
+		"output_tokens", message.Usage.OutputTokens,
+		"events", s.events,
+		"bytes", s.bytes,
+	)
+}
+
+// redactContent replaces all strings in v with digests, except values of structural keys.
+func redactContent(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if s, ok := value.(string); ok && structuralKeys[key] {
+				v[key] = s
+				continue
+			}
+			v[key] = redactContent(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redactContent(value)
+		}
+		return v
+	case string:
+		return digest(v)
+	default:
+		return v
+	}
+}
+
+// digest summarizes content by size and a short hash, so identical content can be
+// recognized across requests without being exposed.
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("[%d bytes sha256:%x]", len(s), sum[:4])
+}
@@ -0,0 +1,128 @@
+package anthropicclaude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestLogRequestSummary(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	params := anthropic.MessageNewParams{
+		Model:     "claude-sonnet-4-5",
+		MaxTokens: 1024,
+		System:    []anthropic.TextBlockParam{{Text: "You are a secret agent"}},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock("my password is hunter2")),
+			anthropic.NewAssistantMessage(anthropic.NewToolUseBlock("toolu_1", map[string]any{"query": "hunter2"}, "search")),
+		},
+	}
+	logRequestSummary(context.Background(), params)
+
+	out := buf.String()
+	for _, leaked := range []string{"hunter2", "secret agent"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("content %q leaked:\n%s", leaked, out)
+		}
+	}
+	for _, want := range []string{
+		`\"model\":\"claude-sonnet-4-5\"`,
+		`\"role\":\"user\"`,
+		`\"type\":\"tool_use\"`,
+		`\"name\":\"search\"`,
+		`\"id\":\"toolu_1\"`,
+		`\"text\":\"` + digest("my password is hunter2") + `\"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		want     []string // Fragments of the encoded diff
+		wantBeta string
+		wantErr  bool
+	}{
+		{
+			name: "messages",
+			body: `{"model":"claude-sonnet-4-5","max_completion_tokens":64,"stream":true,"messages":[` +
+				`{"role":"system","content":"You are a secret agent"},{"role":"user","content":"my password is hunter2"}]}`,
+			want: []string{
+				`"openai":{`,
+				`"max_completion_tokens":64`,
+				`"content":"` + digest("my password is hunter2") + `"`,
+				`"anthropic":{`,
+				`"max_tokens":64`,
+				`"stream":true`,
+				`"system":[{"text":"` + digest("You are a secret agent") + `","type":"text"}]`,
+				`"text":"` + digest("my password is hunter2") + `"`,
+			},
+		},
+		{
+			name: "container",
+			body: `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}],"extra_body":{"container":"container_1"}}`,
+			want: []string{
+				`"container":"container_1"`,
+				`"tools":[{"name":"code_execution","type":"code_execution_20250825"}]`,
+			},
+			wantBeta: "code-execution-2025-08-25",
+		},
+		{
+			name:    "no messages",
+			body:    `{"model":"claude-sonnet-4-5","messages":[]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req openaiadapter.CreateChatCompletionRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatal(err)
+			}
+			diff, err := NewCreateChatCompletionAdapter().Diff(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Diff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			encoded, err := json.Marshal(diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := string(encoded)
+			for _, leaked := range []string{"hunter2", "secret agent"} {
+				if strings.Contains(out, leaked) {
+					t.Errorf("content %q leaked:\n%s", leaked, out)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("diff missing %s:\n%s", want, out)
+				}
+			}
+			if strings.Contains(out, "null") {
+				t.Errorf("diff contains unset parameters:\n%s", out)
+			}
+			if got := diff.Headers["anthropic-beta"]; got != tt.wantBeta {
+				t.Errorf("anthropic-beta = %q, want %q", got, tt.wantBeta)
+			}
+		})
+	}
+}
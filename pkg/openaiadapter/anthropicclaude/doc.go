@@ -4,9 +4,17 @@
 // The adapter handles:
 //
 //   - Message transformation: System/developer messages are hoisted to Anthropic's System field
-//     while preserving conversation order. Tool messages are merged when consecutive (required
+//     while preserving conversation order, or kept in place as instruction blocks if sent
+//     mid-conversation and so configured. Tool messages are merged when consecutive (required
 //     by Anthropic's role alternation rules).
 //
+//   - Prefill: A conversation ending with an assistant message is continued rather than
+//     answered. With extra_body.continue_final_message, the response repeats the prefill
+//     ahead of the continuation.
+//
+//   - Skills: extra_body.container is forwarded with the code execution tool and the beta
+//     features it needs; extra_body.betas adds further beta features.
+//
 //   - Tool calling: Bidirectional tool call ID preservation and index translation. Anthropic uses
 //     mixed content indices (text=0, tool=1, ...) while OpenAI uses tool-only indices
 //     (tool=0, tool=1).
@@ -16,9 +24,12 @@
 //     as they would break conversation round-trips.
 //
 //   - Streaming: Translates Anthropic's SSE events to OpenAI's chunk with proper state management
-//     for tool call indices and metadata accumulation.
+//     for tool call indices and metadata accumulation. Thinking is optionally streamed as
+//     reasoning content, in full or as periodic summaries.
 //
 // # Adapters
 //
 // CreateChatCompletionAdapter: OpenAI CreateChatCompletion → Anthropic Messages
+//
+// FilesAdapter: OpenAI Files → Anthropic Files (beta)
 package anthropicclaude
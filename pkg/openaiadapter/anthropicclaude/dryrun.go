@@ -0,0 +1,77 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+// DryRunResult is the Anthropic request a chat completion request would be sent as.
+type DryRunResult struct {
+	// Request is the Messages request body.
+	Request map[string]any `json:"request"`
+
+	// Headers holds headers the adapter adds to the request.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// DryRun validates and translates clientReq as ProcessRequest would and returns
+// the resulting Anthropic request instead of sending it.
+func (a *CreateChatCompletionAdapter) DryRun(clientReq openaiadapter.CreateChatCompletionRequest) (*DryRunResult, error) {
+	body, headers, err := a.translate(clientReq)
+	if err != nil {
+		return nil, err
+	}
+	return &DryRunResult{Request: body, Headers: headers}, nil
+}
+
+// translate returns the Anthropic request body clientReq is sent as and the
+// headers added to it.
+func (a *CreateChatCompletionAdapter) translate(clientReq openaiadapter.CreateChatCompletionRequest) (map[string]any, map[string]string, error) {
+	if err := a.validateRequest(clientReq); err != nil {
+		return nil, nil, toChatCompletionError(err)
+	}
+	params, err := a.buildParams(clientReq)
+	if err != nil {
+		return nil, nil, toTransformError(openaiadapter.TransformStageRequest, err)
+	}
+
+	body, err := jsonObject(params)
+	if err != nil {
+		return nil, nil, toTransformError(openaiadapter.TransformStageRequest, err)
+	}
+	if clientReq.Stream != nil && *clientReq.Stream {
+		body["stream"] = true
+	}
+
+	fields, beta := requestExtensions(clientReq, params.Messages)
+	for key, value := range fields {
+		// Paths ending in .-1 append to an array, see containerFields
+		if list, ok := strings.CutSuffix(key, ".-1"); ok {
+			items, _ := body[list].([]any)
+			body[list] = append(items, value)
+			continue
+		}
+		body[key] = value
+	}
+
+	var headers map[string]string
+	if beta != "" {
+		headers = map[string]string{"anthropic-beta": beta}
+	}
+	return body, headers, nil
+}
+
+// jsonObject returns v encoded and decoded as a JSON object.
+func jsonObject(v any) (map[string]any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
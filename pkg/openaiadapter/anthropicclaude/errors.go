@@ -0,0 +1,238 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+// streamingErrorPrefix is the prefix used by the Anthropic SDK when wrapping streaming errors.
+const streamingErrorPrefix = "received error while streaming: "
+
+// toChatCompletionError converts any error into OpenAI-compatible error format.
+// Anthropic SDK returns different error shapes for streaming vs non-streaming requests,
+// so we normalize both into a consistent ErrorResponse for SSE/JSON responses.
+// Non-Anthropic errors (network, timeouts) are wrapped as generic server_error.
+func toChatCompletionError(err error) *types.ErrorResponse {
+	if err == nil {
+		return nil
+	}
+
+	// Note: Anthropic error responses don't include 'code' or 'param' fields,
+	// so these are always nil in the OpenAI-compatible response.
+
+	// Non-streaming: *anthropic.Error provides structured error via RawJSON()
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		if errorResp, parseErr := parseErrorResponseJSON(apiErr.RawJSON()); parseErr == nil {
+			return &types.ErrorResponse{
+				Err: types.Error{
+					Message: errorResp.Error.Message,
+					Type:    mapAnthropicErrorType(errorResp.Error.Type),
+				},
+			}
+		}
+		// JSON parse failed, fallback to generic error wrapping
+		return &types.ErrorResponse{
+			Err: types.Error{
+				Message: apiErr.Error(),
+				Type:    "api_error",
+			},
+		}
+	}
+
+	// Streaming: SDK embeds JSON in error string with known prefix
+	if jsonStr, ok := strings.CutPrefix(err.Error(), streamingErrorPrefix); ok {
+		if errorResp, parseErr := parseErrorResponseJSON(jsonStr); parseErr == nil {
+			return &types.ErrorResponse{
+				Err: types.Error{
+					Message: errorResp.Error.Message,
+					Type:    mapAnthropicErrorType(errorResp.Error.Type),
+				},
+			}
+		}
+	}
+
+	// Fallback: wrap non-Anthropic errors (network, timeouts, etc.) as generic server_error
+	return &types.ErrorResponse{
+		Err: types.Error{
+			Message: err.Error(),
+			Type:    "server_error",
+		},
+	}
+}
+
+// toProviderError converts a failed provider call into an OpenAI-compatible error.
+// Rate limit errors carry the rate_limit_exceeded code and, if the provider
+// response is available, which limit was hit and when to retry.
+func toProviderError(err error) error {
+	errResp := toChatCompletionError(err)
+	if errResp == nil || errResp.Err.Type != "rate_limit_error" {
+		return errResp
+	}
+	code := openaiadapter.RateLimitErrorCode
+	errResp.Err.Code = &code
+
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) || apiErr.Response == nil {
+		return errResp
+	}
+	limit, retryAfter := rateLimitDetails(apiErr.Response.Header, time.Now())
+	if retryAfter > 0 {
+		errResp.Err.Message = fmt.Sprintf("%s. Please try again in %s.",
+			strings.TrimSuffix(errResp.Err.Message, "."), time.Duration(retryAfter)*time.Second)
+	}
+	return &openaiadapter.RateLimitError{
+		ErrorResponse: errResp,
+		RetryAfter:    retryAfter,
+		Limit:         limit,
+	}
+}
+
+// toStreamInterruptedError converts a stream that ended before message_stop into
+// an OpenAI-compatible error carrying the usage reported so far. err is the
+// stream's error, nil if it ended cleanly but early.
+func toStreamInterruptedError(err error, usage anthropic.Usage) *openaiadapter.StreamInterruptedError {
+	message := "upstream stream interrupted before the response was complete"
+	if err != nil {
+		message += ": " + err.Error()
+	}
+	code := openaiadapter.StreamInterruptedErrorCode
+	interrupted := &openaiadapter.StreamInterruptedError{
+		ErrorResponse: &types.ErrorResponse{
+			Err: types.Error{
+				Message: message,
+				Type:    "server_error",
+				Code:    &code,
+			},
+		},
+	}
+	if usage.InputTokens > 0 || usage.OutputTokens > 0 {
+		interrupted.Usage = toCompletionUsage(usage)
+	}
+	return interrupted
+}
+
+// isProviderError reports whether err is an error response of the provider, as
+// opposed to a failure reading its response.
+func isProviderError(err error) bool {
+	var apiErr *anthropic.Error
+	return errors.As(err, &apiErr) || strings.HasPrefix(err.Error(), streamingErrorPrefix)
+}
+
+// toStrictSchemaError converts a strict schema violation of the model's tool call
+// into an OpenAI-compatible error.
+func toStrictSchemaError(violation error) *types.ErrorResponse {
+	code := "strict_schema_violation"
+	return &types.ErrorResponse{
+		Err: types.Error{
+			Message: "model returned tool call arguments not matching the strict schema: " + violation.Error(),
+			Type:    "api_error",
+			Code:    &code,
+		},
+	}
+}
+
+// rateLimitDetails derives the exhausted limit and the seconds until it resets
+// from the headers of a 429 response. Subscription usage limits are reported by
+// the unified headers, API rate limits by the requests and tokens headers, local
+// limits by x-ratelimit-* headers.
+func rateLimitDetails(header http.Header, now time.Time) (limit string, retryAfter int) {
+	var reset time.Time
+	if header.Get("Anthropic-Ratelimit-Unified-Status") == "rejected" {
+		limit = header.Get("Anthropic-Ratelimit-Unified-Representative-Claim")
+		if unix, err := strconv.ParseInt(header.Get("Anthropic-Ratelimit-Unified-Reset"), 10, 64); err == nil {
+			reset = time.Unix(unix, 0)
+		}
+	} else {
+		for _, kind := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+			prefix := "Anthropic-Ratelimit-" + kind
+			if header.Get(prefix+"-Remaining") != "0" {
+				continue
+			}
+			limit = "tokens"
+			if kind == "requests" {
+				limit = kind
+			}
+			reset, _ = time.Parse(time.RFC3339, header.Get(prefix+"-Reset"))
+			break
+		}
+	}
+	// OpenAI-style headers, e.g. from a rate limiting proxy in front of Anthropic
+	for _, kind := range []string{"requests", "tokens"} {
+		if limit != "" || header.Get("X-Ratelimit-Remaining-"+kind) != "0" {
+			continue
+		}
+		limit = kind
+		if d, err := time.ParseDuration(header.Get("X-Ratelimit-Reset-" + kind)); err == nil {
+			reset = now.Add(d)
+		}
+	}
+
+	// retry-after is authoritative; the reset time is the fallback
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return limit, seconds
+	}
+	if reset.After(now) {
+		return limit, int(math.Ceil(reset.Sub(now).Seconds()))
+	}
+	return limit, 0
+}
+
+// toTransformError converts a request or response transformation failure into an
+// OpenAI-compatible error, tagged with the stage it occurred in.
+func toTransformError(stage string, err error) *openaiadapter.TransformError {
+	return &openaiadapter.TransformError{
+		Stage:         stage,
+		ErrorResponse: toChatCompletionError(err),
+	}
+}
+
+// parseErrorResponseJSON parses Anthropic error JSON into structured ErrorResponse.
+// Shared by both non-streaming (RawJSON) and streaming (error string) error paths.
+func parseErrorResponseJSON(jsonStr string) (*anthropic.ErrorResponse, error) {
+	var errorResp anthropic.ErrorResponse
+	if err := json.Unmarshal([]byte(jsonStr), &errorResp); err != nil {
+		return nil, fmt.Errorf("failed to parse Anthropic error JSON: %w", err)
+	}
+	return &errorResp, nil
+}
+
+// mapAnthropicErrorType translates Anthropic error taxonomy to OpenAI-compatible error types.
+func mapAnthropicErrorType(anthropicType string) string {
+	switch anthropicType {
+	case "overloaded_error":
+		return "server_error"
+	case "rate_limit_error":
+		return "rate_limit_error"
+	case "invalid_request_error":
+		return "invalid_request_error"
+	case "request_too_large":
+		return "invalid_request_error"
+	case "authentication_error":
+		return "authentication_error"
+	case "permission_error":
+		return "permission_denied"
+	case "not_found_error":
+		return "invalid_request_error"
+	case "timeout_error":
+		return "server_error"
+	case "api_error":
+		return "api_error"
+	case "billing_error":
+		return "insufficient_quota"
+	default:
+		// Unknown error types default to api_error for safe handling
+		return "api_error"
+	}
+}
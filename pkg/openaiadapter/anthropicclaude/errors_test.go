@@ -0,0 +1,138 @@
+package anthropicclaude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestRateLimitDetails(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		header         http.Header
+		wantLimit      string
+		wantRetryAfter int
+	}{
+		{
+			name: "subscription limit",
+			header: http.Header{
+				"Anthropic-Ratelimit-Unified-Status":               {"rejected"},
+				"Anthropic-Ratelimit-Unified-Representative-Claim": {"five_hour"},
+				"Anthropic-Ratelimit-Unified-Reset":                {"1748782800"}, // 13:00
+			},
+			wantLimit:      "five_hour",
+			wantRetryAfter: 3600,
+		},
+		{
+			name: "requests",
+			header: http.Header{
+				"Anthropic-Ratelimit-Requests-Remaining": {"0"},
+				"Anthropic-Ratelimit-Requests-Reset":     {"2025-06-01T12:00:20Z"},
+				"Anthropic-Ratelimit-Tokens-Remaining":   {"1000"},
+			},
+			wantLimit:      "requests",
+			wantRetryAfter: 20,
+		},
+		{
+			name: "output tokens with retry-after",
+			header: http.Header{
+				"Anthropic-Ratelimit-Requests-Remaining":      {"10"},
+				"Anthropic-Ratelimit-Output-Tokens-Remaining": {"0"},
+				"Anthropic-Ratelimit-Output-Tokens-Reset":     {"2025-06-01T12:00:20Z"},
+				"Retry-After": {"7"},
+			},
+			wantLimit:      "tokens",
+			wantRetryAfter: 7,
+		},
+		{
+			name: "local pacing limit",
+			header: http.Header{
+				"X-Ratelimit-Remaining-Requests": {"3"},
+				"X-Ratelimit-Remaining-Tokens":   {"0"},
+				"X-Ratelimit-Reset-Tokens":       {"12s"},
+			},
+			wantLimit:      "tokens",
+			wantRetryAfter: 12,
+		},
+		{
+			name:   "unknown",
+			header: http.Header{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, retryAfter := rateLimitDetails(tt.header, now)
+			if limit != tt.wantLimit || retryAfter != tt.wantRetryAfter {
+				t.Errorf("rateLimitDetails() = (%q, %d), want (%q, %d)", limit, retryAfter, tt.wantLimit, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"Content-Type":                           {"application/json"},
+				"Retry-After":                            {"30"},
+				"X-Should-Retry":                         {"false"},
+				"Anthropic-Ratelimit-Requests-Remaining": {"0"},
+			},
+			Body:    io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit."}}`)),
+			Request: r,
+		}, nil
+	})
+	var req openaiadapter.CreateChatCompletionRequest
+	if err := json.Unmarshal([]byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"error":{"code":"rate_limit_exceeded","message":"Number of requests has exceeded your rate limit. Please try again in 30s.","type":"rate_limit_error","retry_after":30,"limit":"requests"}}`
+
+	t.Run("buffered", func(t *testing.T) {
+		_, err := NewCreateChatCompletionAdapter().ProcessRequest(context.Background(), req, transport)
+		assertRateLimitError(t, err, want)
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		stream, err := NewCreateChatCompletionAdapter().ProcessStreamingRequest(context.Background(), req, transport)
+		if err == nil {
+			for _, err = range stream {
+				if err != nil {
+					break
+				}
+			}
+		}
+		assertRateLimitError(t, err, want)
+	})
+}
+
+func assertRateLimitError(t *testing.T, err error, want string) {
+	t.Helper()
+	var rateLimitErr *openaiadapter.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("error = %v, want *RateLimitError", err)
+	}
+	got, err := json.Marshal(rateLimitErr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,168 @@
+package anthropicclaude
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+// defaultFilePurpose is reported for files listed or retrieved from Anthropic,
+// which has no notion of an upload purpose.
+const defaultFilePurpose = "user_data"
+
+// FilesAdapter maps the OpenAI Files API to Anthropic's Files API (beta).
+//
+// Anthropic-specific differences:
+//   - Purpose: Not stored by Anthropic; echoed on upload, reported as "user_data" otherwise
+//   - Content: Anthropic only allows downloading files created by tools, not uploaded files
+//   - Status: Anthropic files are available immediately and always reported as "processed"
+type FilesAdapter struct{}
+
+// Compile-time interface implementation check.
+var _ openaiadapter.FilesAdapter = (*FilesAdapter)(nil)
+
+// NewFilesAdapter creates a new Files API adapter.
+func NewFilesAdapter() *FilesAdapter {
+	return &FilesAdapter{}
+}
+
+// Upload stores the file with Anthropic and returns it as an OpenAI file object.
+func (a *FilesAdapter) Upload(
+	ctx context.Context,
+	file io.Reader,
+	filename, contentType, purpose string,
+	transport http.RoundTripper,
+) (*openaiadapter.File, error) {
+	client, err := newClient(transport)
+	if err != nil {
+		return nil, toChatCompletionError(fmt.Errorf("initialize Anthropic client for file upload: %w", err))
+	}
+
+	metadata, err := client.Beta.Files.Upload(ctx, anthropic.BetaFileUploadParams{
+		File: anthropic.File(file, filename, contentType),
+	})
+	if err != nil {
+		return nil, toChatCompletionError(err)
+	}
+
+	result := toOpenAIFile(metadata)
+	if purpose != "" {
+		result.Purpose = purpose
+	}
+	return result, nil
+}
+
+// List returns a page of files. An empty after starts at the most recent file; limit 0 uses the provider default.
+func (a *FilesAdapter) List(
+	ctx context.Context,
+	after string,
+	limit int64,
+	transport http.RoundTripper,
+) (*openaiadapter.ListFilesResponse, error) {
+	client, err := newClient(transport)
+	if err != nil {
+		return nil, toChatCompletionError(fmt.Errorf("initialize Anthropic client for file listing: %w", err))
+	}
+
+	params := anthropic.BetaFileListParams{}
+	if after != "" {
+		params.AfterID = anthropic.String(after)
+	}
+	if limit > 0 {
+		params.Limit = anthropic.Int(limit)
+	}
+
+	page, err := client.Beta.Files.List(ctx, params)
+	if err != nil {
+		return nil, toChatCompletionError(err)
+	}
+
+	resp := &openaiadapter.ListFilesResponse{
+		Object:  "list",
+		Data:    make([]types.OpenAIFile, 0, len(page.Data)),
+		FirstId: page.FirstID,
+		LastId:  page.LastID,
+		HasMore: page.HasMore,
+	}
+	for i := range page.Data {
+		resp.Data = append(resp.Data, *toOpenAIFile(&page.Data[i]))
+	}
+	return resp, nil
+}
+
+// Retrieve returns the metadata of a single file.
+func (a *FilesAdapter) Retrieve(
+	ctx context.Context,
+	fileID string,
+	transport http.RoundTripper,
+) (*openaiadapter.File, error) {
+	client, err := newClient(transport)
+	if err != nil {
+		return nil, toChatCompletionError(fmt.Errorf("initialize Anthropic client for file retrieval: %w", err))
+	}
+
+	metadata, err := client.Beta.Files.GetMetadata(ctx, fileID, anthropic.BetaFileGetMetadataParams{})
+	if err != nil {
+		return nil, toChatCompletionError(err)
+	}
+	return toOpenAIFile(metadata), nil
+}
+
+// Content returns Anthropic's download response. The body carries the raw file content.
+func (a *FilesAdapter) Content(
+	ctx context.Context,
+	fileID string,
+	transport http.RoundTripper,
+) (*http.Response, error) {
+	client, err := newClient(transport)
+	if err != nil {
+		return nil, toChatCompletionError(fmt.Errorf("initialize Anthropic client for file download: %w", err))
+	}
+
+	resp, err := client.Beta.Files.Download(ctx, fileID, anthropic.BetaFileDownloadParams{})
+	if err != nil {
+		return nil, toChatCompletionError(err)
+	}
+	return resp, nil
+}
+
+// Delete removes the file from Anthropic.
+func (a *FilesAdapter) Delete(
+	ctx context.Context,
+	fileID string,
+	transport http.RoundTripper,
+) (*openaiadapter.DeleteFileResponse, error) {
+	client, err := newClient(transport)
+	if err != nil {
+		return nil, toChatCompletionError(fmt.Errorf("initialize Anthropic client for file deletion: %w", err))
+	}
+
+	deleted, err := client.Beta.Files.Delete(ctx, fileID, anthropic.BetaFileDeleteParams{})
+	if err != nil {
+		return nil, toChatCompletionError(err)
+	}
+	return &openaiadapter.DeleteFileResponse{
+		Id:      deleted.ID,
+		Object:  types.OpenAIFileObjectFile,
+		Deleted: true,
+	}, nil
+}
+
+// toOpenAIFile converts Anthropic file metadata to an OpenAI file object.
+func toOpenAIFile(metadata *anthropic.FileMetadata) *openaiadapter.File {
+	return &openaiadapter.File{
+		Id:        metadata.ID,
+		Object:    types.OpenAIFileObjectFile,
+		Bytes:     metadata.SizeBytes,
+		CreatedAt: metadata.CreatedAt.Unix(),
+		Filename:  metadata.Filename,
+		Purpose:   defaultFilePurpose,
+		Status:    types.OpenAIFileStatusProcessed,
+	}
+}
@@ -6,13 +6,14 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
 )
 
 // buildGenerationParams builds Anthropic generation configuration from OpenAI request.
 // Handles model, sampling parameters, tools, metadata, and all generation settings.
 func buildGenerationParams(
 	clientReq openaiadapter.CreateChatCompletionRequest,
+	budgets ReasoningBudgets,
 ) (anthropic.MessageNewParams, error) {
 	params := anthropic.MessageNewParams{
 		Model: anthropic.Model(clientReq.Model),
@@ -87,7 +88,7 @@ func buildGenerationParams(
 	}
 
 	// Build thinking configuration from reasoning effort and extra_body overrides
-	thinking, err := buildThinking(clientReq)
+	thinking, err := buildThinking(clientReq, budgets)
 	if err != nil {
 		return params, fmt.Errorf("build thinking config: %w", err)
 	}
@@ -132,7 +133,8 @@ func buildGenerationParams(
 
 	// PromptCacheKey transformation: OpenAI's PromptCacheKey is client-provided cache key.
 	// Anthropic's prompt caching uses automatic cache control breakpoints via CacheControl
-	// on specific content blocks, not client-provided keys.
+	// on specific content blocks, not client-provided keys. With a PromptCache set on the
+	// adapter, the key identifies the conversation whose requests are shaped for caching.
 
 	// Prediction transformation: OpenAI's Prediction for "Predicted Outputs" optimization.
 	// Anthropic has no equivalent predicted outputs mechanism.
@@ -9,8 +9,9 @@ import (
 	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/types"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
 )
 
 // fromContentParts converts OpenAI content formats to Anthropic ContentBlockParamUnion.
@@ -251,13 +252,22 @@ func fromChatCompletionRequestMessageContentPartImage(imagePart types.ChatComple
 }
 
 // fromChatCompletionRequestMessageContentPartFile converts OpenAI file content to Anthropic DocumentBlockParam.
-// Supports inline base64 file data (file_data field). File ID references (file_id) are not supported
-// as they would require a separate file storage/upload system.
+// Supports inline base64 file data (file_data field) and references to files uploaded through
+// the Files API (file_id field), which require the files beta (see usesFileReferences).
 func fromChatCompletionRequestMessageContentPartFile(filePart types.ChatCompletionRequestMessageContentPartFile) (anthropic.ContentBlockParamUnion, error) {
 	file := filePart.File
 
 	if file.FileId != nil && *file.FileId != "" {
-		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("file_id references not supported (requires file upload system), only inline file_data is supported")
+		// The stable Messages params lack file sources, so the block is set verbatim
+		document := map[string]any{
+			"type":   "document",
+			"source": map[string]any{"type": "file", "file_id": *file.FileId},
+		}
+		if file.Filename != nil && *file.Filename != "" {
+			document["title"] = *file.Filename
+		}
+		block := param.Override[anthropic.DocumentBlockParam](document)
+		return anthropic.ContentBlockParamUnion{OfDocument: &block}, nil
 	}
 
 	if file.FileData == nil || *file.FileData == "" {
@@ -321,3 +331,19 @@ func detectMIMEType(data []byte, declaredType, filename, fallbackType string) st
 
 	return fallbackType
 }
+
+// usesFileReferences reports whether any message references an uploaded file by ID.
+// File references are the only document blocks set via param.Override.
+func usesFileReferences(messages []anthropic.MessageParam) bool {
+	for _, message := range messages {
+		for _, block := range message.Content {
+			if block.OfDocument == nil {
+				continue
+			}
+			if _, ok := block.OfDocument.Overrides(); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -7,7 +7,7 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/types"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
 )
 
 // transformedMessage represents a single OpenAI message after transformation to Anthropic format.
@@ -0,0 +1,79 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestSystemMessageOrdering(t *testing.T) {
+	const request = `{"model":"claude-sonnet-4-5","messages":[
+		{"role":"developer","content":"dev 1"},
+		{"role":"system","content":"sys 1"},
+		{"role":"user","content":"hi"},
+		{"role":"system","content":"sys 2"},
+		{"role":"assistant","content":"hello"},
+		{"role":"developer","content":"dev 2"}
+	]}`
+
+	tests := []struct {
+		name         string
+		placement    DeveloperPlacement
+		inline       bool
+		wantSystem   string
+		wantMessages string
+	}{
+		{
+			name:         "encounter order",
+			wantSystem:   `[{"text":"dev 1","type":"text"},{"text":"sys 1","type":"text"},{"text":"sys 2","type":"text"},{"text":"dev 2","type":"text"}]`,
+			wantMessages: `[{"content":[{"text":"hi","type":"text"}],"role":"user"},{"content":[{"text":"hello","type":"text"}],"role":"assistant"}]`,
+		},
+		{
+			name:       "developer before system",
+			placement:  DeveloperBeforeSystem,
+			wantSystem: `[{"text":"dev 1","type":"text"},{"text":"dev 2","type":"text"},{"text":"sys 1","type":"text"},{"text":"sys 2","type":"text"}]`,
+		},
+		{
+			name:       "developer after system",
+			placement:  DeveloperAfterSystem,
+			wantSystem: `[{"text":"sys 1","type":"text"},{"text":"sys 2","type":"text"},{"text":"dev 1","type":"text"},{"text":"dev 2","type":"text"}]`,
+		},
+		{
+			name:       "inline mid-conversation messages",
+			placement:  DeveloperAfterSystem,
+			inline:     true,
+			wantSystem: `[{"text":"sys 1","type":"text"},{"text":"dev 1","type":"text"}]`,
+			wantMessages: `[{"content":[{"text":"hi","type":"text"},{"text":"\u003csystem\u003e\nsys 2\n\u003c/system\u003e","type":"text"}],"role":"user"},` +
+				`{"content":[{"text":"hello","type":"text"}],"role":"assistant"},` +
+				`{"content":[{"text":"\u003cdeveloper\u003e\ndev 2\n\u003c/developer\u003e","type":"text"}],"role":"user"}]`,
+		},
+	}
+
+	var req openaiadapter.CreateChatCompletionRequest
+	if err := json.Unmarshal([]byte(request), &req); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := NewCreateChatCompletionAdapter()
+			adapter.DeveloperPlacement = tt.placement
+			adapter.InlineSystemMessages = tt.inline
+
+			params, err := adapter.buildParams(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			system, _ := json.Marshal(params.System)
+			if string(system) != tt.wantSystem {
+				t.Errorf("system = %s\nwant %s", system, tt.wantSystem)
+			}
+			if tt.wantMessages != "" {
+				messages, _ := json.Marshal(params.Messages)
+				if string(messages) != tt.wantMessages {
+					t.Errorf("messages = %s\nwant %s", messages, tt.wantMessages)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package anthropicclaude
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// normalizeToolSchema rewrites the JSON Schema of tool parameters into a form
+// Anthropic accepts. OpenAI is more lenient, and clients generate schemas from
+// OpenAPI specs or type definitions, so:
+//   - local $ref are inlined and $defs/definitions dropped
+//   - OpenAPI's nullable: true becomes a "null" type
+//   - oneOf becomes anyOf
+//   - top-level anyOf/oneOf/allOf over object schemas are merged into one object,
+//     since Anthropic requires a plain object at the top level
+//
+// Schemas that can't be expressed, e.g. recursive or remote references, return
+// an error naming the construct. The input is not modified.
+func normalizeToolSchema(schema map[string]any) (map[string]any, error) {
+	n := &schemaNormalizer{root: schema}
+	normalized, err := n.normalize(schema, nil)
+	if err != nil {
+		return nil, err
+	}
+	delete(normalized, "$defs")
+	delete(normalized, "definitions")
+
+	normalized, err = flattenTopLevel(normalized)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := normalized["type"]; !ok {
+		normalized["type"] = "object"
+	}
+	if normalized["type"] != "object" {
+		return nil, fmt.Errorf("parameters must be an object schema, got type %v", normalized["type"])
+	}
+	return normalized, nil
+}
+
+type schemaNormalizer struct {
+	root map[string]any
+}
+
+// normalize returns a normalized copy of schema. refs holds the references being
+// inlined, to detect recursion.
+func (n *schemaNormalizer) normalize(schema map[string]any, refs []string) (map[string]any, error) {
+	if ref, ok := schema["$ref"].(string); ok {
+		if !strings.HasPrefix(ref, "#") {
+			return nil, fmt.Errorf("remote $ref %q is not supported", ref)
+		}
+		if slices.Contains(refs, ref) {
+			return nil, fmt.Errorf("recursive $ref %q cannot be inlined", ref)
+		}
+		target, err := (&schemaValidator{root: n.root}).resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		inlined, err := n.normalize(target, append(refs, ref))
+		if err != nil {
+			return nil, err
+		}
+		// Keywords next to $ref (e.g., description) refine the referenced schema
+		for key, value := range schema {
+			if key != "$ref" {
+				normalized, err := n.normalizeValue(value, refs)
+				if err != nil {
+					return nil, err
+				}
+				inlined[key] = normalized
+			}
+		}
+		return inlined, nil
+	}
+
+	out := make(map[string]any, len(schema))
+	for key, value := range schema {
+		normalized, err := n.normalizeValue(value, refs)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = normalized
+	}
+
+	if nullable, ok := out["nullable"].(bool); ok {
+		delete(out, "nullable")
+		if nullable {
+			out["type"] = withNull(out["type"])
+		}
+	}
+
+	if oneOf, ok := out["oneOf"]; ok {
+		delete(out, "oneOf")
+		if anyOf, ok := out["anyOf"]; ok {
+			// Both must hold: keep them as separate constraints
+			delete(out, "anyOf")
+			allOf, _ := out["allOf"].([]any)
+			out["allOf"] = append(allOf, map[string]any{"anyOf": anyOf}, map[string]any{"anyOf": oneOf})
+		} else {
+			out["anyOf"] = oneOf
+		}
+	}
+	return out, nil
+}
+
+// normalizeValue normalizes the schemas nested in a keyword value.
+func (n *schemaNormalizer) normalizeValue(value any, refs []string) (any, error) {
+	switch value := value.(type) {
+	case map[string]any:
+		return n.normalize(value, refs)
+	case []any:
+		out := make([]any, len(value))
+		for i, item := range value {
+			normalized, err := n.normalizeValue(item, refs)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = normalized
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+// withNull adds "null" to a type keyword. Schemas without type already allow null.
+func withNull(typeKeyword any) any {
+	switch t := typeKeyword.(type) {
+	case string:
+		if t == "null" {
+			return t
+		}
+		return []any{t, "null"}
+	case []any:
+		if slices.Contains(t, any("null")) {
+			return t
+		}
+		return append(slices.Clone(t), "null")
+	default:
+		return typeKeyword
+	}
+}
+
+// flattenTopLevel merges top-level anyOf/allOf branches into the schema itself.
+// Properties are combined; a property is required if all anyOf branches or any
+// allOf branch require it.
+func flattenTopLevel(schema map[string]any) (map[string]any, error) {
+	for _, keyword := range []string{"allOf", "anyOf"} {
+		branches, ok := schema[keyword].([]any)
+		if !ok {
+			continue
+		}
+		delete(schema, keyword)
+
+		properties, _ := schema["properties"].(map[string]any)
+		properties = maps.Clone(properties)
+		if properties == nil {
+			properties = make(map[string]any)
+		}
+		required := requiredSet(schema)
+		var branchRequired map[string]int
+		for i, branch := range branches {
+			branch, ok := branch.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("top-level %s branch %d is not a schema", keyword, i)
+			}
+			branch, err := flattenTopLevel(maps.Clone(branch))
+			if err != nil {
+				return nil, err
+			}
+			if t, ok := branch["type"]; ok && t != "object" {
+				return nil, fmt.Errorf("top-level %s branch %d is not an object schema (type %v)", keyword, i, t)
+			}
+			if props, ok := branch["properties"].(map[string]any); ok {
+				maps.Copy(properties, props)
+			}
+			if branchRequired == nil {
+				branchRequired = make(map[string]int)
+			}
+			for name := range requiredSet(branch) {
+				branchRequired[name]++
+			}
+		}
+		for name, count := range branchRequired {
+			if keyword == "allOf" || count == len(branches) {
+				required[name] = true
+			}
+		}
+
+		if len(properties) > 0 {
+			schema["properties"] = properties
+		}
+		if len(required) > 0 {
+			schema["required"] = toAnySlice(slices.Sorted(maps.Keys(required)))
+		} else {
+			delete(schema, "required")
+		}
+		schema["type"] = "object"
+	}
+	return schema, nil
+}
+
+// requiredSet returns the required property names of an object schema.
+func requiredSet(schema map[string]any) map[string]bool {
+	set := make(map[string]bool)
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				set[name] = true
+			}
+		}
+	}
+	return set
+}
+
+func toAnySlice(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeToolSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "unchanged",
+			schema: `{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`,
+			want:   `{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`,
+		},
+		{
+			name:   "missing type",
+			schema: `{"properties":{"city":{"type":"string"}}}`,
+			want:   `{"type":"object","properties":{"city":{"type":"string"}}}`,
+		},
+		{
+			name:   "ref inlined",
+			schema: `{"type":"object","properties":{"home":{"$ref":"#/$defs/address","description":"Home"},"work":{"$ref":"#/definitions/address"}},"$defs":{"address":{"type":"object","properties":{"street":{"type":"string"}}}},"definitions":{"address":{"type":"string"}}}`,
+			want:   `{"type":"object","properties":{"home":{"type":"object","properties":{"street":{"type":"string"}},"description":"Home"},"work":{"type":"string"}}}`,
+		},
+		{
+			name:   "nullable",
+			schema: `{"type":"object","properties":{"unit":{"type":"string","nullable":true},"tags":{"type":["array"],"nullable":true},"note":{"type":"string","nullable":false}}}`,
+			want:   `{"type":"object","properties":{"unit":{"type":["string","null"]},"tags":{"type":["array","null"]},"note":{"type":"string"}}}`,
+		},
+		{
+			name:   "oneOf",
+			schema: `{"type":"object","properties":{"when":{"oneOf":[{"type":"string"},{"type":"integer"}]}}}`,
+			want:   `{"type":"object","properties":{"when":{"anyOf":[{"type":"string"},{"type":"integer"}]}}}`,
+		},
+		{
+			name:   "top-level oneOf",
+			schema: `{"oneOf":[{"type":"object","properties":{"city":{"type":"string"},"unit":{"type":"string"}},"required":["city","unit"]},{"type":"object","properties":{"zip":{"type":"string"},"unit":{"type":"string"}},"required":["zip","unit"]}]}`,
+			want:   `{"type":"object","properties":{"city":{"type":"string"},"zip":{"type":"string"},"unit":{"type":"string"}},"required":["unit"]}`,
+		},
+		{
+			name:   "top-level allOf",
+			schema: `{"type":"object","properties":{"id":{"type":"string"}},"required":["id"],"allOf":[{"$ref":"#/$defs/named"},{"properties":{"age":{"type":"integer"}}}],"$defs":{"named":{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}}}`,
+			want:   `{"type":"object","properties":{"id":{"type":"string"},"name":{"type":"string"},"age":{"type":"integer"}},"required":["id","name"]}`,
+		},
+		{
+			name:    "recursive ref",
+			schema:  `{"type":"object","properties":{"tree":{"$ref":"#/$defs/node"}},"$defs":{"node":{"type":"object","properties":{"children":{"type":"array","items":{"$ref":"#/$defs/node"}}}}}}`,
+			wantErr: `recursive $ref "#/$defs/node"`,
+		},
+		{
+			name:    "remote ref",
+			schema:  `{"type":"object","properties":{"geo":{"$ref":"https://example.com/geo.json"}}}`,
+			wantErr: `remote $ref "https://example.com/geo.json"`,
+		},
+		{
+			name:    "unresolvable ref",
+			schema:  `{"type":"object","properties":{"geo":{"$ref":"#/$defs/geo"}}}`,
+			wantErr: `unresolvable $ref "#/$defs/geo"`,
+		},
+		{
+			name:    "top-level anyOf over scalars",
+			schema:  `{"anyOf":[{"type":"string"},{"type":"object"}]}`,
+			wantErr: `top-level anyOf branch 0 is not an object schema`,
+		},
+		{
+			name:    "not an object",
+			schema:  `{"type":"array","items":{"type":"string"}}`,
+			wantErr: `parameters must be an object schema`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema map[string]any
+			if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+				t.Fatal(err)
+			}
+			original, _ := json.Marshal(schema)
+
+			got, err := normalizeToolSchema(schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want map[string]any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("got  %s\nwant %s", gotJSON, wantJSON)
+			}
+			if after, _ := json.Marshal(schema); string(after) != string(original) {
+				t.Errorf("input modified: %s", after)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package anthropicclaude
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+// Assistant prefill: a conversation ending with an assistant message makes Anthropic
+// continue that message instead of starting a new turn, which lets clients force a
+// format (e.g., start the reply with "{"). The response only carries the continuation,
+// unless the request sets
+//
+//	extra_body: {"continue_final_message": true}
+//
+// in which case the prefill is echoed in front of it, so the returned content is the
+// complete final message.
+
+// continueFinalMessage reports whether extra_body asks to echo the prefill.
+func continueFinalMessage(clientReq openaiadapter.CreateChatCompletionRequest) bool {
+	if clientReq.ExtraBody == nil {
+		return false
+	}
+	enabled, _ := (*clientReq.ExtraBody)["continue_final_message"].(bool)
+	return enabled
+}
+
+// validatePrefill rejects continue_final_message on conversations not ending with an
+// assistant message, as there is nothing to continue.
+func validatePrefill(clientReq openaiadapter.CreateChatCompletionRequest) error {
+	if !continueFinalMessage(clientReq) {
+		return nil
+	}
+	role, err := clientReq.Messages[len(clientReq.Messages)-1].Discriminator()
+	if err != nil || role != string(types.ChatCompletionRequestAssistantMessageRoleAssistant) {
+		return fmt.Errorf("continue_final_message requires the conversation to end with an assistant message")
+	}
+	return nil
+}
+
+// trimPrefill prepares a final assistant message as prefill. Anthropic rejects
+// prefills ending in whitespace, so trailing whitespace is trimmed, dropping blocks
+// and the message if nothing remains.
+func trimPrefill(messages []anthropic.MessageParam) []anthropic.MessageParam {
+	last := len(messages) - 1
+	if last < 0 || messages[last].Role != anthropic.MessageParamRoleAssistant {
+		return messages
+	}
+
+	content := messages[last].Content
+	for len(content) > 0 {
+		text := content[len(content)-1].OfText
+		if text == nil {
+			// Ends with a tool call or other block: nothing to continue
+			messages[last].Content = content
+			return messages
+		}
+		trimmed := strings.TrimRightFunc(text.Text, unicode.IsSpace)
+		if trimmed != "" {
+			block := anthropic.NewTextBlock(trimmed)
+			block.OfText.CacheControl = text.CacheControl
+			messages[last].Content = append(content[:len(content)-1:len(content)-1], block)
+			return messages
+		}
+		content = content[:len(content)-1]
+	}
+	return messages[:last]
+}
+
+// prefillText returns the text the model continues, or "" if the conversation
+// doesn't end with assistant text.
+func prefillText(messages []anthropic.MessageParam) string {
+	last := len(messages) - 1
+	if last < 0 || messages[last].Role != anthropic.MessageParamRoleAssistant || len(messages[last].Content) == 0 {
+		return ""
+	}
+	if text := messages[last].Content[len(messages[last].Content)-1].OfText; text != nil {
+		return text.Text
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package anthropicclaude
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestPrefill(t *testing.T) {
+	const message = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","stop_reason":"end_turn",` +
+		`"content":[{"type":"text","text":"\"ok\": true}"}],"usage":{"input_tokens":1,"output_tokens":1}}`
+	const stream = "event: message_start\n" +
+		`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":1,"output_tokens":0}}}` + "\n\n" +
+		"event: content_block_start\n" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"\"ok\": true}"}}` + "\n\n" +
+		"event: message_delta\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":1}}` + "\n\n" +
+		"event: message_stop\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	tests := []struct {
+		name         string
+		request      string
+		wantMessages string // Sent upstream
+		wantContent  string
+		wantErr      bool
+	}{
+		{
+			name:         "continuation only",
+			request:      `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"json?"},{"role":"assistant","content":"{\n"}]}`,
+			wantMessages: `[{"content":[{"text":"json?","type":"text"}],"role":"user"},{"content":[{"text":"{","type":"text"}],"role":"assistant"}]`,
+			wantContent:  `"ok": true}`,
+		},
+		{
+			name:         "echoed",
+			request:      `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"json?"},{"role":"assistant","content":"{ "}],"extra_body":{"continue_final_message":true}}`,
+			wantMessages: `[{"content":[{"text":"json?","type":"text"}],"role":"user"},{"content":[{"text":"{","type":"text"}],"role":"assistant"}]`,
+			wantContent:  `{"ok": true}`,
+		},
+		{
+			name:         "whitespace-only prefill dropped",
+			request:      `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"json?"},{"role":"assistant","content":"\n"}],"extra_body":{"continue_final_message":true}}`,
+			wantMessages: `[{"content":[{"text":"json?","type":"text"}],"role":"user"}]`,
+			wantContent:  `"ok": true}`,
+		},
+		{
+			name:    "nothing to continue",
+			request: `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"json?"}],"extra_body":{"continue_final_message":true}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		var req openaiadapter.CreateChatCompletionRequest
+		if err := json.Unmarshal([]byte(tt.request), &req); err != nil {
+			t.Fatal(err)
+		}
+
+		var sent string
+		responder := func(contentType, body string) http.RoundTripper {
+			return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var upstream struct {
+					Messages json.RawMessage `json:"messages"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&upstream)
+				sent = string(upstream.Messages)
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {contentType}},
+					Body:       io.NopCloser(strings.NewReader(body)),
+					Request:    r,
+				}, nil
+			})
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := NewCreateChatCompletionAdapter().ProcessRequest(context.Background(), req, responder("application/json", message))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent != tt.wantMessages {
+				t.Errorf("upstream messages = %s\nwant %s", sent, tt.wantMessages)
+			}
+			if content := resp.Choices[0].Message.Content; content == nil || *content != tt.wantContent {
+				t.Errorf("content = %v, want %q", content, tt.wantContent)
+			}
+		})
+
+		if tt.wantErr {
+			continue
+		}
+		t.Run(tt.name+" stream", func(t *testing.T) {
+			chunks, err := NewCreateChatCompletionAdapter().ProcessStreamingRequest(context.Background(), req, responder("text/event-stream", stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var content strings.Builder
+			for chunk, err := range chunks {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if c := chunk.Choices[0].Delta.Content; c != nil {
+					content.WriteString(*c)
+				}
+			}
+			if content.String() != tt.wantContent {
+				t.Errorf("streamed content = %q, want %q", content.String(), tt.wantContent)
+			}
+		})
+	}
+}
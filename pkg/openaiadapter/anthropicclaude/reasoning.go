@@ -1,20 +1,59 @@
 package anthropicclaude
 
 import (
+	"cmp"
 	"fmt"
+	"path"
 	"strconv"
 
 	"github.com/anthropics/anthropic-sdk-go"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
 )
 
+// ReasoningBudget maps reasoning_effort levels to thinking budgets for the models
+// matching Model, a path.Match pattern such as "claude-opus-*". Zero budgets fall
+// back to DefaultReasoningBudget.
+type ReasoningBudget struct {
+	Model  string
+	Low    int64
+	Medium int64
+	High   int64
+}
+
+// DefaultReasoningBudget is the mapping for models without a configured budget:
+// low ≈ 1,024 tokens, medium ≈ 8,192 tokens, high ≈ 24,576 tokens.
+var DefaultReasoningBudget = ReasoningBudget{Model: "*", Low: 1024, Medium: 8192, High: 24576}
+
+// ReasoningBudgets holds per-model budgets. The first entry matching a model applies.
+type ReasoningBudgets []ReasoningBudget
+
+// Budget returns the thinking budget of effort for model, or 0 if effort doesn't
+// enable thinking ("minimal", "none" or unknown efforts).
+func (b ReasoningBudgets) Budget(model, effort string) int64 {
+	budget := DefaultReasoningBudget
+	for _, entry := range b {
+		if ok, _ := path.Match(entry.Model, model); ok {
+			budget = entry
+			break
+		}
+	}
+	switch effort {
+	case "low":
+		return cmp.Or(budget.Low, DefaultReasoningBudget.Low)
+	case "medium":
+		return cmp.Or(budget.Medium, DefaultReasoningBudget.Medium)
+	case "high":
+		return cmp.Or(budget.High, DefaultReasoningBudget.High)
+	}
+	return 0
+}
+
 // buildThinking builds Anthropic's thinking configuration from OpenAI's reasoning effort.
-// Maps OpenAI's effort levels (low/medium/high) to Anthropic's explicit token budgets.
+// Maps OpenAI's effort levels (low/medium/high) to Anthropic's explicit token budgets
+// (see ReasoningBudgets); "minimal" and "none" disable thinking.
 // Also handles extra_body overrides for advanced users who want direct Anthropic config.
 //
-// Mapping: low ≈ 1,024 tokens, medium ≈ 8,192 tokens, high ≈ 24,576 tokens
-//
 // Override mechanism: Users can specify exact config via extra_body:
 //
 //	extra_body: {
@@ -23,17 +62,17 @@ import (
 //	        "budget_tokens": 16000
 //	    }
 //	}
-func buildThinking(clientReq openaiadapter.CreateChatCompletionRequest) (anthropic.ThinkingConfigParamUnion, error) {
+func buildThinking(clientReq openaiadapter.CreateChatCompletionRequest, budgets ReasoningBudgets) (anthropic.ThinkingConfigParamUnion, error) {
 	var thinking anthropic.ThinkingConfigParamUnion
 
 	if clientReq.ReasoningEffort != nil {
-		switch *clientReq.ReasoningEffort {
-		case "low":
-			thinking = anthropic.ThinkingConfigParamOfEnabled(1024)
-		case "medium":
-			thinking = anthropic.ThinkingConfigParamOfEnabled(8192)
-		case "high":
-			thinking = anthropic.ThinkingConfigParamOfEnabled(24576)
+		switch effort := string(*clientReq.ReasoningEffort); effort {
+		case "low", "medium", "high":
+			thinking = anthropic.ThinkingConfigParamOfEnabled(budgets.Budget(clientReq.Model, effort))
+		case "minimal", "none":
+			thinking = anthropic.ThinkingConfigParamUnion{
+				OfDisabled: &anthropic.ThinkingConfigDisabledParam{},
+			}
 		default:
 			// Unknown reasoning_effort values are ignored; thinking remains unset
 		}
@@ -63,7 +102,7 @@ func buildThinking(clientReq openaiadapter.CreateChatCompletionRequest) (anthrop
 						thinking = anthropic.ThinkingConfigParamOfEnabled(budgetTokens)
 					} else {
 						// Require at least one budget source (reasoning_effort or budget_tokens)
-						if thinking.GetType() == nil {
+						if thinking.OfEnabled == nil {
 							return thinking, fmt.Errorf("extra_body.thinking.type is 'enabled' but budget_tokens not specified and no reasoning_effort set")
 						}
 					}
@@ -0,0 +1,119 @@
+package anthropicclaude
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ReasoningContent streams extended thinking to clients as delta.reasoning_content,
+// the field reasoning-aware OpenAI clients show apart from the answer, so it isn't
+// echoed back as assistant content on the next turn.
+//
+// Raw thinking can be many times longer than the answer. With a SummaryInterval,
+// clients instead receive a progress line every SummaryInterval characters of
+// thinking: the latest complete sentence, capped at SummaryLength characters.
+type ReasoningContent struct {
+	// SummaryInterval is the number of thinking characters between progress lines.
+	// Zero streams the thinking as is.
+	SummaryInterval int
+
+	// SummaryLength caps progress lines, in characters. Defaults to 120.
+	SummaryLength int
+}
+
+// defaultSummaryLength caps progress lines if ReasoningContent.SummaryLength is unset.
+const defaultSummaryLength = 120
+
+// reasoningProgress tracks the thinking of a stream between progress lines.
+type reasoningProgress struct {
+	blocks  map[int64]bool // Content block indices holding thinking
+	pending int            // Characters since the last progress line
+	recent  string         // Tail of the thinking, enough for a sentence
+}
+
+// start records a thinking content block.
+func (p *reasoningProgress) start(index int64) {
+	if p.blocks == nil {
+		p.blocks = make(map[int64]bool)
+	}
+	p.blocks[index] = true
+}
+
+// delta returns the reasoning content to send for a thinking delta, or "" if it
+// is summarized later.
+func (p *reasoningProgress) delta(rc *ReasoningContent, thinking string) string {
+	if rc.SummaryInterval <= 0 {
+		return thinking
+	}
+
+	limit := rc.summaryLength()
+	p.pending += utf8.RuneCountInString(thinking)
+	p.recent = lastRunes(p.recent+thinking, 4*limit)
+	if p.pending < rc.SummaryInterval {
+		return ""
+	}
+	return p.flush(limit)
+}
+
+// stop returns the final progress line of a thinking block ending at index, or "".
+func (p *reasoningProgress) stop(rc *ReasoningContent, index int64) string {
+	if !p.blocks[index] {
+		return ""
+	}
+	delete(p.blocks, index)
+	if rc.SummaryInterval <= 0 || p.pending == 0 {
+		return ""
+	}
+	return p.flush(rc.summaryLength())
+}
+
+// flush returns a progress line of the recent thinking and resets the counter.
+func (p *reasoningProgress) flush(limit int) string {
+	p.pending = 0
+	line := latestSentence(p.recent)
+	if line == "" {
+		return ""
+	}
+	if utf8.RuneCountInString(line) > limit {
+		line = strings.TrimSpace(string([]rune(line)[:limit-1])) + "…"
+	}
+	return line + "\n"
+}
+
+func (rc *ReasoningContent) summaryLength() int {
+	if rc.SummaryLength > 1 {
+		return rc.SummaryLength
+	}
+	return defaultSummaryLength
+}
+
+// latestSentence returns the last complete sentence or line of text, or the
+// unfinished one if there is none.
+func latestSentence(text string) string {
+	text = strings.TrimSpace(text)
+	end := len(text)
+	if i := strings.LastIndexAny(text, ".!?\n"); i >= 0 && i < len(text)-1 {
+		end = i + 1 // Skip the unfinished sentence
+	}
+	for end > 0 {
+		body := strings.TrimRight(text[:end], ".!?\n") // Ellipses end a single sentence
+		start := strings.LastIndexAny(body, ".!?\n") + 1
+		if strings.TrimSpace(body[start:]) != "" {
+			return strings.TrimSpace(text[start:end])
+		}
+		end = start
+	}
+	return text
+}
+
+// lastRunes returns the last n runes of s.
+func lastRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := len(s) - n
+	for cut < len(s) && !utf8.RuneStart(s[cut]) {
+		cut++
+	}
+	return s[cut:]
+}
@@ -0,0 +1,143 @@
+package anthropicclaude
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestReasoningContent(t *testing.T) {
+	thinkingDelta := func(thinking string) string {
+		return "event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"` + thinking + `"}}` + "\n\n"
+	}
+	const stream = "event: message_start\n" +
+		`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":1,"output_tokens":0}}}` + "\n\n" +
+		"event: content_block_start\n" +
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":"","signature":""}}` + "\n\n"
+	const end = "event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}` + "\n\n" +
+		"event: content_block_stop\n" +
+		`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+		"event: content_block_start\n" +
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}` + "\n\n" +
+		"event: content_block_delta\n" +
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"42"}}` + "\n\n" +
+		"event: content_block_stop\n" +
+		`data: {"type":"content_block_stop","index":1}` + "\n\n" +
+		"event: message_delta\n" +
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":1}}` + "\n\n" +
+		"event: message_stop\n" +
+		`data: {"type":"message_stop"}` + "\n\n"
+
+	thinking := []string{
+		"The user asks a question. ",
+		"Let me work out the answer step by step. ",
+		"First the basics",
+		", then the details. It is 42.",
+	}
+
+	tests := []struct {
+		name      string
+		reasoning *ReasoningContent
+		want      []string // Reasoning content chunks
+	}{
+		{
+			name: "dropped",
+		},
+		{
+			name:      "full",
+			reasoning: &ReasoningContent{},
+			want:      thinking,
+		},
+		{
+			name:      "summary",
+			reasoning: &ReasoningContent{SummaryInterval: 50, SummaryLength: 30},
+			want: []string{
+				"Let me work out the answer st…\n",
+				"It is 42.\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := stream
+			for _, chunk := range thinking {
+				body += thinkingDelta(chunk)
+			}
+			body += end
+
+			var req openaiadapter.CreateChatCompletionRequest
+			if err := json.Unmarshal([]byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"Answer?"}],"reasoning_effort":"low"}`), &req); err != nil {
+				t.Fatal(err)
+			}
+			transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/event-stream"}},
+					Body:       io.NopCloser(strings.NewReader(body)),
+					Request:    r,
+				}, nil
+			})
+
+			adapter := NewCreateChatCompletionAdapter()
+			adapter.ReasoningContent = tt.reasoning
+			chunks, err := adapter.ProcessStreamingRequest(context.Background(), req, transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var reasoning []string
+			var content strings.Builder
+			for chunk, err := range chunks {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(chunk.Choices) == 0 {
+					continue
+				}
+				delta := chunk.Choices[0].Delta
+				if delta.ReasoningContent != nil {
+					reasoning = append(reasoning, *delta.ReasoningContent)
+				}
+				if delta.Content != nil {
+					content.WriteString(*delta.Content)
+				}
+			}
+
+			if strings.Join(reasoning, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("reasoning content = %q, want %q", reasoning, tt.want)
+			}
+			if content.String() != "42" {
+				t.Errorf("content = %q, want %q", content.String(), "42")
+			}
+		})
+	}
+}
+
+func TestLatestSentence(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"Thinking about it", "Thinking about it"},
+		{"First. Second!", "Second!"},
+		{"First. Second unfinished", "First."},
+		{"A list:\n- one\n- two", "- one"},
+		{"  Padded.  ", "Padded."},
+		{"Wait... what", "Wait..."},
+	}
+
+	for _, tt := range tests {
+		if got := latestSentence(tt.text); got != tt.want {
+			t.Errorf("latestSentence(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestBuildThinking(t *testing.T) {
+	budgets := ReasoningBudgets{
+		{Model: "claude-opus-*", Medium: 16000},
+		{Model: "claude-haiku-4-5", Low: 2048, Medium: 4096, High: 6144},
+	}
+
+	tests := []struct {
+		name    string
+		request string
+		want    string // Marshaled thinking, "null" if unset
+		wantErr bool
+	}{
+		{"default low", `{"model":"claude-sonnet-4-5","reasoning_effort":"low"}`, `{"budget_tokens":1024,"type":"enabled"}`, false},
+		{"default high", `{"model":"claude-sonnet-4-5","reasoning_effort":"high"}`, `{"budget_tokens":24576,"type":"enabled"}`, false},
+		{"configured level", `{"model":"claude-opus-4-1","reasoning_effort":"medium"}`, `{"budget_tokens":16000,"type":"enabled"}`, false},
+		{"unset level keeps default", `{"model":"claude-opus-4-1","reasoning_effort":"high"}`, `{"budget_tokens":24576,"type":"enabled"}`, false},
+		{"first match applies", `{"model":"claude-haiku-4-5","reasoning_effort":"low"}`, `{"budget_tokens":2048,"type":"enabled"}`, false},
+		{"minimal disables", `{"model":"claude-sonnet-4-5","reasoning_effort":"minimal"}`, `{"type":"disabled"}`, false},
+		{"none disables", `{"model":"claude-sonnet-4-5","reasoning_effort":"none"}`, `{"type":"disabled"}`, false},
+		{"unknown ignored", `{"model":"claude-sonnet-4-5","reasoning_effort":"max"}`, `null`, false},
+		{
+			"extra_body budget overrides",
+			`{"model":"claude-opus-4-1","reasoning_effort":"low","extra_body":{"thinking":{"type":"enabled","budget_tokens":3000}}}`,
+			`{"budget_tokens":3000,"type":"enabled"}`, false,
+		},
+		{
+			"extra_body without budget uses effort",
+			`{"model":"claude-opus-4-1","reasoning_effort":"medium","extra_body":{"thinking":{"type":"enabled"}}}`,
+			`{"budget_tokens":16000,"type":"enabled"}`, false,
+		},
+		{
+			"extra_body without budget after disabling effort",
+			`{"model":"claude-sonnet-4-5","reasoning_effort":"none","extra_body":{"thinking":{"type":"enabled"}}}`,
+			``, true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req openaiadapter.CreateChatCompletionRequest
+			if err := json.Unmarshal([]byte(tt.request), &req); err != nil {
+				t.Fatal(err)
+			}
+			thinking, err := buildThinking(req, budgets)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(thinking)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("thinking = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Scanner positions within a JSON value, for completing truncated input.
+const (
+	expectValue = iota // a value, or the end of an empty array
+	expectKey          // an object key, or the end of an empty object
+	expectColon        // the colon after an object key
+	expectEnd          // a comma or the end of the enclosing container
+)
+
+// completeToolArguments returns the suffix that turns args, tool call arguments
+// cut off mid-stream, into a valid JSON object, and whether one exists. Valid
+// arguments need no suffix; empty arguments become "{}".
+//
+// Output was already streamed, so the input can only be extended: open strings,
+// literals, numbers and containers are closed, and dangling keys get a null value.
+// Input that isn't a prefix of valid JSON, or ends in a comma, can't be repaired.
+func completeToolArguments(args string) (string, bool) {
+	if strings.TrimSpace(args) == "" {
+		return "{}", true
+	}
+	if json.Valid([]byte(args)) {
+		return "", true
+	}
+
+	var (
+		stack    []byte // open containers, '{' or '['
+		expect   = expectValue
+		last     byte // last structural character
+		inString bool
+		isKey    bool // open string is an object key
+		escaped  bool // open string ends in a backslash
+		hexLeft  int  // digits missing from a \u escape
+		token    []byte
+	)
+	endToken := func() {
+		if token != nil {
+			token = nil
+			expect = expectEnd
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		c := args[i]
+		if inString {
+			switch {
+			case hexLeft > 0:
+				hexLeft--
+			case escaped:
+				escaped = false
+				if c == 'u' {
+					hexLeft = 4
+				}
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				expect = expectEnd
+				if isKey {
+					expect = expectColon
+				}
+			}
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			endToken()
+		case '"':
+			endToken()
+			inString, isKey = true, expect == expectKey
+		case '{', '[':
+			endToken()
+			stack = append(stack, c)
+			last = c
+			expect = expectValue
+			if c == '{' {
+				expect = expectKey
+			}
+		case '}', ']':
+			endToken()
+			if len(stack) == 0 {
+				return "", false
+			}
+			stack = stack[:len(stack)-1]
+			last = c
+			expect = expectEnd
+		case ':', ',':
+			endToken()
+			last = c
+			expect = expectValue
+			if c == ',' && len(stack) > 0 && stack[len(stack)-1] == '{' {
+				expect = expectKey
+			}
+		default:
+			token = append(token, c)
+		}
+	}
+
+	var suffix strings.Builder
+	switch {
+	case inString:
+		if escaped {
+			suffix.WriteByte('\\')
+		}
+		suffix.WriteString(strings.Repeat("0", hexLeft))
+		suffix.WriteByte('"')
+		expect = expectEnd
+		if isKey {
+			expect = expectColon
+		}
+	case token != nil:
+		suffix.WriteString(completeToken(string(token)))
+		expect = expectEnd
+	}
+
+	switch expect {
+	case expectColon:
+		suffix.WriteString(":null")
+	case expectValue, expectKey:
+		if last == ',' {
+			return "", false
+		}
+		if last == ':' {
+			suffix.WriteString("null")
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			suffix.WriteByte('}')
+		} else {
+			suffix.WriteByte(']')
+		}
+	}
+
+	if !json.Valid([]byte(args + suffix.String())) {
+		return "", false
+	}
+	return suffix.String(), true
+}
+
+// completeToken returns the suffix completing a truncated literal or number.
+func completeToken(token string) string {
+	for _, literal := range []string{"true", "false", "null"} {
+		if rest, ok := strings.CutPrefix(literal, token); ok {
+			return rest
+		}
+	}
+	switch token[len(token)-1] {
+	case '-', '+', '.', 'e', 'E':
+		return "0"
+	}
+	return ""
+}
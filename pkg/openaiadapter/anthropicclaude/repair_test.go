@@ -0,0 +1,150 @@
+package anthropicclaude
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+func TestCompleteToolArguments(t *testing.T) {
+	tests := []struct {
+		args       string
+		wantSuffix string
+		wantOK     bool
+	}{
+		{args: `{"city":"Paris"}`, wantSuffix: "", wantOK: true},
+		{args: ``, wantSuffix: `{}`, wantOK: true},
+		{args: `{`, wantSuffix: `}`, wantOK: true},
+		{args: `{"ci`, wantSuffix: `":null}`, wantOK: true},
+		{args: `{"city"`, wantSuffix: `:null}`, wantOK: true},
+		{args: `{"city": `, wantSuffix: `null}`, wantOK: true},
+		{args: `{"city":"Pa`, wantSuffix: `"}`, wantOK: true},
+		{args: `{"path":"C:\`, wantSuffix: `\"}`, wantOK: true},
+		{args: `{"text":"caf\u00`, wantSuffix: `00"}`, wantOK: true},
+		{args: `{"days":[1,2`, wantSuffix: `]}`, wantOK: true},
+		{args: `{"days":[`, wantSuffix: `]}`, wantOK: true},
+		{args: `{"temp":-`, wantSuffix: `0}`, wantOK: true},
+		{args: `{"temp":1.`, wantSuffix: `0}`, wantOK: true},
+		{args: `{"exact":fa`, wantSuffix: `lse}`, wantOK: true},
+		{args: `{"a":{"b":[{"c":nu`, wantSuffix: `ll}]}}`, wantOK: true},
+		{args: `{"city":"Paris",`, wantOK: false},
+		{args: `{"days":[1,`, wantOK: false},
+		{args: `{"city" "Paris"`, wantOK: false},
+		{args: `{"city":"Paris"}}`, wantOK: false},
+		{args: `{"exact":fx`, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			suffix, ok := completeToolArguments(tt.args)
+			if ok != tt.wantOK || suffix != tt.wantSuffix {
+				t.Errorf("completeToolArguments(%q) = (%q, %v), want (%q, %v)", tt.args, suffix, ok, tt.wantSuffix, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRepairToolArguments(t *testing.T) {
+	toolStream := func(partialJSON string) string {
+		return "event: message_start\n" +
+			`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":3,"output_tokens":0}}}` + "\n\n" +
+			"event: content_block_start\n" +
+			`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}` + "\n\n" +
+			"event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":` + partialJSON + `}}` + "\n\n" +
+			"event: content_block_stop\n" +
+			`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+			"event: message_delta\n" +
+			`data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":1}}` + "\n\n" +
+			"event: message_stop\n" +
+			`data: {"type":"message_stop"}` + "\n\n"
+	}
+
+	tests := []struct {
+		name             string
+		repair           bool
+		partialJSON      string
+		wantArguments    string
+		wantFinishReason types.CreateChatCompletionStreamResponseChoiceFinishReason
+	}{
+		{
+			name:             "disabled",
+			partialJSON:      `"{\"city\":\"Pa"`,
+			wantArguments:    `{"city":"Pa`,
+			wantFinishReason: types.CreateChatCompletionStreamResponseChoiceFinishReasonToolCalls,
+		},
+		{
+			name:             "truncated",
+			repair:           true,
+			partialJSON:      `"{\"city\":\"Pa"`,
+			wantArguments:    `{"city":"Pa"}`,
+			wantFinishReason: types.CreateChatCompletionStreamResponseChoiceFinishReasonToolCalls,
+		},
+		{
+			name:             "unrepairable",
+			repair:           true,
+			partialJSON:      `"{\"city\":\"Paris\","`,
+			wantArguments:    `{"city":"Paris",`,
+			wantFinishReason: types.CreateChatCompletionStreamResponseChoiceFinishReasonLength,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/event-stream"}},
+					Body:       io.NopCloser(strings.NewReader(toolStream(tt.partialJSON))),
+					Request:    r,
+				}, nil
+			})
+			adapter := NewCreateChatCompletionAdapter()
+			adapter.RepairToolArguments = tt.repair
+
+			var req openaiadapter.CreateChatCompletionRequest
+			if err := json.Unmarshal([]byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"weather?"}],"stream":true}`), &req); err != nil {
+				t.Fatal(err)
+			}
+			stream, err := adapter.ProcessStreamingRequest(context.Background(), req, transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var arguments strings.Builder
+			var finishReason types.CreateChatCompletionStreamResponseChoiceFinishReason
+			for chunk, err := range stream {
+				if err != nil {
+					t.Fatal(err)
+				}
+				choice := chunk.Choices[0]
+				if choice.FinishReason != nil {
+					finishReason = *choice.FinishReason
+				}
+				if choice.Delta.ToolCalls == nil {
+					continue
+				}
+				for _, item := range *choice.Delta.ToolCalls {
+					toolCall, err := item.AsChatCompletionMessageToolCallChunk()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if toolCall.Function != nil && toolCall.Function.Arguments != nil {
+						arguments.WriteString(*toolCall.Function.Arguments)
+					}
+				}
+			}
+
+			if got := arguments.String(); got != tt.wantArguments {
+				t.Errorf("arguments = %s, want %s", got, tt.wantArguments)
+			}
+			if finishReason != tt.wantFinishReason {
+				t.Errorf("finish_reason = %q, want %q", finishReason, tt.wantFinishReason)
+			}
+		})
+	}
+}
@@ -6,7 +6,7 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/types"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
 )
 
 // toFinishReason maps Anthropic stop reasons to OpenAI non-streaming finish reasons.
@@ -0,0 +1,322 @@
+package anthropicclaude
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
+)
+
+// strictSchemas returns the normalized parameter schemas of the function tools
+// declared with strict: true, by tool name. Tools without parameters accept an
+// empty object.
+func strictSchemas(tools *[]types.CreateChatCompletionRequest_Tools_Item) map[string]map[string]any {
+	if tools == nil {
+		return nil
+	}
+
+	var schemas map[string]map[string]any
+	for _, toolItem := range *tools {
+		chatTool, err := toolItem.AsChatCompletionTool()
+		if err != nil || chatTool.Type != types.Function ||
+			chatTool.Function.Strict == nil || !*chatTool.Function.Strict {
+			continue
+		}
+		schema := map[string]any{"type": "object", "additionalProperties": false}
+		if chatTool.Function.Parameters != nil {
+			// Validate against the schema the model was given
+			if schema, err = normalizeToolSchema(*chatTool.Function.Parameters); err != nil {
+				continue
+			}
+		}
+		if schemas == nil {
+			schemas = make(map[string]map[string]any)
+		}
+		schemas[chatTool.Function.Name] = schema
+	}
+	return schemas
+}
+
+// strictSchemaViolation returns the first tool call in content whose arguments
+// don't match the schema of its strict tool.
+func strictSchemaViolation(schemas map[string]map[string]any, content []anthropic.ContentBlockUnion) error {
+	if schemas == nil {
+		return nil
+	}
+	for _, block := range content {
+		toolUse, ok := block.AsAny().(anthropic.ToolUseBlock)
+		if !ok {
+			continue
+		}
+		schema, ok := schemas[toolUse.Name]
+		if !ok {
+			continue
+		}
+		if err := validateToolArguments(schema, string(toolUse.Input)); err != nil {
+			return fmt.Errorf("tool %s: %w", toolUse.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateToolArguments checks the JSON arguments of a tool call against the
+// strict schema of the tool.
+func validateToolArguments(schema map[string]any, arguments string) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	var value any
+	if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+		return fmt.Errorf("arguments are not valid JSON: %w", err)
+	}
+	return (&schemaValidator{root: schema}).validate(schema, value, "$")
+}
+
+// schemaValidator validates JSON values against the subset of JSON Schema that
+// OpenAI supports for strict function calling: types, enum and const, object
+// properties, arrays, anyOf/oneOf/allOf, local $ref and basic string, number and
+// array bounds. Unknown keywords are ignored.
+type schemaValidator struct {
+	root map[string]any
+}
+
+// validate returns the first violation of schema by value at path.
+func (v *schemaValidator) validate(schema map[string]any, value any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return v.validate(resolved, value, path)
+	}
+
+	if err := checkType(schema["type"], value, path); err != nil {
+		return err
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return jsonEqual(e, value) }) {
+		return fmt.Errorf("%s: value is not one of the allowed values", path)
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		return fmt.Errorf("%s: value does not match const", path)
+	}
+
+	for _, sub := range subschemas(schema["allOf"]) {
+		if err := v.validate(sub, value, path); err != nil {
+			return err
+		}
+	}
+	if options := subschemas(schema["anyOf"]); options != nil && !v.matchesAny(options, value, path) {
+		return fmt.Errorf("%s: value matches none of anyOf", path)
+	}
+	if options := subschemas(schema["oneOf"]); options != nil && !v.matchesAny(options, value, path) {
+		return fmt.Errorf("%s: value matches none of oneOf", path)
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		return v.validateObject(schema, value, path)
+	case []any:
+		return v.validateArray(schema, value, path)
+	case string:
+		return validateString(schema, value, path)
+	case float64:
+		return validateNumber(schema, value, path)
+	}
+	return nil
+}
+
+func (v *schemaValidator) matchesAny(options []map[string]any, value any, path string) bool {
+	return slices.ContainsFunc(options, func(option map[string]any) bool {
+		return v.validate(option, value, path) == nil
+	})
+}
+
+func (v *schemaValidator) validateObject(schema map[string]any, value map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := value[name]; !present {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	// Sorted for deterministic errors
+	for _, name := range slices.Sorted(maps.Keys(value)) {
+		propertyPath := path + "." + name
+		if property, ok := properties[name].(map[string]any); ok {
+			if err := v.validate(property, value[name], propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: property not allowed", propertyPath)
+			}
+		case map[string]any:
+			if err := v.validate(additional, value[name], propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) validateArray(schema map[string]any, value []any, path string) error {
+	if minItems, ok := schema["minItems"].(float64); ok && float64(len(value)) < minItems {
+		return fmt.Errorf("%s: fewer than %v items", path, minItems)
+	}
+	if maxItems, ok := schema["maxItems"].(float64); ok && float64(len(value)) > maxItems {
+		return fmt.Errorf("%s: more than %v items", path, maxItems)
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range value {
+			if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve looks up a reference within the root schema, e.g. "#/$defs/address".
+func (v *schemaValidator) resolve(ref string) (map[string]any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	var node any = v.root
+	for token := range strings.SplitSeq(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		node = object[strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")]
+	}
+	resolved, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return resolved, nil
+}
+
+// checkType verifies value against a type keyword, a single type or a list.
+func checkType(typeKeyword any, value any, path string) error {
+	var allowed []string
+	switch t := typeKeyword.(type) {
+	case string:
+		allowed = []string{t}
+	case []any:
+		for _, name := range t {
+			if name, ok := name.(string); ok {
+				allowed = append(allowed, name)
+			}
+		}
+	default:
+		return nil
+	}
+
+	actual := jsonType(value)
+	for _, name := range allowed {
+		if name == actual || (name == "number" && actual == "integer") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(allowed, " or "), actual)
+}
+
+// jsonType names the JSON type of a decoded value; whole numbers are integers.
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func validateString(schema map[string]any, value string, path string) error {
+	length := float64(utf8.RuneCountInString(value))
+	if minLength, ok := schema["minLength"].(float64); ok && length < minLength {
+		return fmt.Errorf("%s: shorter than %v characters", path, minLength)
+	}
+	if maxLength, ok := schema["maxLength"].(float64); ok && length > maxLength {
+		return fmt.Errorf("%s: longer than %v characters", path, maxLength)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(value) {
+			return fmt.Errorf("%s: does not match pattern %q", path, pattern)
+		}
+	}
+	return nil
+}
+
+func validateNumber(schema map[string]any, value float64, path string) error {
+	if minimum, ok := schema["minimum"].(float64); ok && value < minimum {
+		return fmt.Errorf("%s: less than minimum %v", path, minimum)
+	}
+	if maximum, ok := schema["maximum"].(float64); ok && value > maximum {
+		return fmt.Errorf("%s: greater than maximum %v", path, maximum)
+	}
+	if minimum, ok := schema["exclusiveMinimum"].(float64); ok && value <= minimum {
+		return fmt.Errorf("%s: not greater than %v", path, minimum)
+	}
+	if maximum, ok := schema["exclusiveMaximum"].(float64); ok && value >= maximum {
+		return fmt.Errorf("%s: not less than %v", path, maximum)
+	}
+	if multipleOf, ok := schema["multipleOf"].(float64); ok && multipleOf > 0 {
+		if quotient := value / multipleOf; quotient != math.Trunc(quotient) {
+			return fmt.Errorf("%s: not a multiple of %v", path, multipleOf)
+		}
+	}
+	return nil
+}
+
+// subschemas returns the schemas of a keyword holding a list of schemas.
+func subschemas(keyword any) []map[string]any {
+	list, ok := keyword.([]any)
+	if !ok {
+		return nil
+	}
+	schemas := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if schema, ok := item.(map[string]any); ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+// jsonEqual compares decoded JSON values.
+func jsonEqual(a, b any) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aJSON) == string(bJSON)
+}
@@ -0,0 +1,179 @@
+package anthropicclaude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestValidateToolArguments(t *testing.T) {
+	schema := map[string]any{}
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string", "minLength": 2},
+			"unit": {"type": ["string", "null"], "enum": ["celsius", "fahrenheit", null]},
+			"days": {"type": "integer", "minimum": 1, "maximum": 14},
+			"stops": {"type": "array", "items": {"$ref": "#/$defs/stop"}, "maxItems": 2},
+			"when": {"anyOf": [{"type": "string", "pattern": "^\\d{4}-\\d{2}-\\d{2}$"}, {"const": "today"}]}
+		},
+		"required": ["city"],
+		"additionalProperties": false,
+		"$defs": {
+			"stop": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"], "additionalProperties": false}
+		}
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arguments string
+		wantErr   string
+	}{
+		{arguments: `{"city":"Paris"}`},
+		{arguments: `{"city":"Paris","unit":null,"days":3,"stops":[{"name":"Lyon"}],"when":"2025-06-01"}`},
+		{arguments: `{"city":"Paris","when":"today"}`},
+		{arguments: `{}`, wantErr: `$: missing required property "city"`},
+		{arguments: `{"city":7}`, wantErr: `$.city: expected string, got integer`},
+		{arguments: `{"city":"P"}`, wantErr: `$.city: shorter than 2 characters`},
+		{arguments: `{"city":"Paris","unit":"kelvin"}`, wantErr: `$.unit: value is not one of the allowed values`},
+		{arguments: `{"city":"Paris","days":1.5}`, wantErr: `$.days: expected integer, got number`},
+		{arguments: `{"city":"Paris","days":20}`, wantErr: `$.days: greater than maximum 14`},
+		{arguments: `{"city":"Paris","stops":[{"name":1}]}`, wantErr: `$.stops[0].name: expected string, got integer`},
+		{arguments: `{"city":"Paris","stops":[{},{},{}]}`, wantErr: `$.stops: more than 2 items`},
+		{arguments: `{"city":"Paris","when":"soon"}`, wantErr: `$.when: value matches none of anyOf`},
+		{arguments: `{"city":"Paris","country":"FR"}`, wantErr: `$.country: property not allowed`},
+		{arguments: `{"city":"Pa`, wantErr: `arguments are not valid JSON`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arguments, func(t *testing.T) {
+			err := validateToolArguments(schema, tt.arguments)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStrictToolCalls(t *testing.T) {
+	const request = `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"weather?"}],
+		"tools":[{"type":"function","function":{"name":"get_weather","strict":true,
+		"parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"],"additionalProperties":false}}}]}`
+	message := func(input string) string {
+		return `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","stop_reason":"tool_use",` +
+			`"content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":` + input + `}],"usage":{"input_tokens":1,"output_tokens":1}}`
+	}
+	stream := func(partialJSON string) string {
+		return "event: message_start\n" +
+			`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-5","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":1,"output_tokens":0}}}` + "\n\n" +
+			"event: content_block_start\n" +
+			`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}` + "\n\n" +
+			"event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":` + partialJSON + `}}` + "\n\n" +
+			"event: content_block_stop\n" +
+			`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+			"event: message_delta\n" +
+			`data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":1}}` + "\n\n" +
+			"event: message_stop\n" +
+			`data: {"type":"message_stop"}` + "\n\n"
+	}
+
+	// responder answers with the given bodies in turn, repeating the last one
+	responder := func(contentType string, bodies ...string) (http.RoundTripper, *int) {
+		calls := 0
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			body := bodies[min(calls, len(bodies)-1)]
+			calls++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {contentType}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    r,
+			}, nil
+		}), &calls
+	}
+
+	var req openaiadapter.CreateChatCompletionRequest
+	if err := json.Unmarshal([]byte(request), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		retries   int
+		bodies    []string
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "valid", bodies: []string{message(`{"city":"Paris"}`)}, wantCalls: 1},
+		{name: "invalid", bodies: []string{message(`{"town":"Paris"}`)}, wantCalls: 1, wantErr: true},
+		{name: "retried", retries: 2, bodies: []string{message(`{"town":"Paris"}`), message(`{"city":"Paris"}`)}, wantCalls: 2},
+		{name: "retries exhausted", retries: 2, bodies: []string{message(`{"town":"Paris"}`)}, wantCalls: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, calls := responder("application/json", tt.bodies...)
+			adapter := NewCreateChatCompletionAdapter()
+			adapter.StrictRetries = tt.retries
+
+			_, err := adapter.ProcessRequest(context.Background(), req, transport)
+			if *calls != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", *calls, tt.wantCalls)
+			}
+			assertStrictSchemaError(t, err, tt.wantErr)
+		})
+	}
+
+	streamTests := []struct {
+		name        string
+		partialJSON string
+		wantErr     bool
+	}{
+		{name: "stream valid", partialJSON: `"{\"city\":\"Paris\"}"`},
+		{name: "stream invalid", partialJSON: `"{\"town\":\"Paris\"}"`, wantErr: true},
+	}
+	for _, tt := range streamTests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, _ := responder("text/event-stream", stream(tt.partialJSON))
+			chunks, err := NewCreateChatCompletionAdapter().ProcessStreamingRequest(context.Background(), req, transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, err = range chunks {
+				if err != nil {
+					break
+				}
+			}
+			assertStrictSchemaError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func assertStrictSchemaError(t *testing.T, err error, want bool) {
+	t.Helper()
+	if !want {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	var errResp *openaiadapter.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Err.Code == nil || *errResp.Err.Code != "strict_schema_violation" {
+		t.Fatalf("error = %v, want strict_schema_violation", err)
+	}
+	if !strings.Contains(errResp.Err.Message, `missing required property "city"`) {
+		t.Errorf("message = %q, want the violation", errResp.Err.Message)
+	}
+}
@@ -0,0 +1,113 @@
+package anthropicclaude
+
+import (
+	"fmt"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+// Skills: Anthropic runs Agent Skills (pptx, xlsx, custom skills, ...) inside a code
+// execution container, a beta the SDK's Messages parameters don't cover. Clients pass
+// the container through extra_body, by ID to reuse one or with the skills to load:
+//
+//	extra_body: {
+//	  "container": {"skills": [{"type": "anthropic", "skill_id": "pptx", "version": "latest"}]},
+//	  "betas": ["context-management-2025-06-27"]
+//	}
+//
+// The container is forwarded as is, together with the code execution tool the skills
+// run in and the beta features they need. extra_body.betas adds further beta features,
+// as OpenAI clients can't set Anthropic's beta header.
+
+// Beta features needed to run code, and skills, in a container.
+const (
+	codeExecutionBeta = "code-execution-2025-08-25"
+	skillsBeta        = "skills-2025-10-02"
+)
+
+// codeExecutionTool is the server tool containers are used by.
+var codeExecutionTool = map[string]any{"type": "code_execution_20250825", "name": "code_execution"}
+
+// validateContainer rejects malformed extra_body.container and extra_body.betas.
+func validateContainer(clientReq openaiadapter.CreateChatCompletionRequest) error {
+	if clientReq.ExtraBody == nil {
+		return nil
+	}
+	extra := *clientReq.ExtraBody
+
+	if betas, ok := extra["betas"]; ok {
+		list, ok := betas.([]any)
+		if !ok {
+			return fmt.Errorf("extra_body.betas must be an array of strings")
+		}
+		for _, beta := range list {
+			if _, ok := beta.(string); !ok {
+				return fmt.Errorf("extra_body.betas must be an array of strings")
+			}
+		}
+	}
+
+	container, ok := extra["container"]
+	if !ok || container == nil {
+		return nil
+	}
+	switch c := container.(type) {
+	case string:
+		return nil
+	case map[string]any:
+		if id, ok := c["id"]; ok {
+			if _, ok := id.(string); !ok {
+				return fmt.Errorf("extra_body.container.id must be a string")
+			}
+		}
+		skills, ok := c["skills"]
+		if !ok {
+			return nil
+		}
+		list, ok := skills.([]any)
+		if !ok {
+			return fmt.Errorf("extra_body.container.skills must be an array")
+		}
+		for i, skill := range list {
+			s, ok := skill.(map[string]any)
+			if !ok {
+				return fmt.Errorf("extra_body.container.skills.%d must be an object", i)
+			}
+			if id, _ := s["skill_id"].(string); id == "" {
+				return fmt.Errorf("extra_body.container.skills.%d.skill_id is required", i)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("extra_body.container must be a container ID or an object")
+	}
+}
+
+// containerFields returns the fields to add to the upstream request body and the
+// beta features they need, from a request validated by validateContainer.
+func containerFields(clientReq openaiadapter.CreateChatCompletionRequest) (map[string]any, []string) {
+	if clientReq.ExtraBody == nil {
+		return nil, nil
+	}
+	extra := *clientReq.ExtraBody
+
+	var betas []string
+	if list, ok := extra["betas"].([]any); ok {
+		for _, beta := range list {
+			betas = append(betas, beta.(string))
+		}
+	}
+
+	container, ok := extra["container"]
+	if !ok || container == nil {
+		return nil, betas
+	}
+	betas = append(betas, codeExecutionBeta)
+	if c, ok := container.(map[string]any); ok && c["skills"] != nil {
+		betas = append(betas, skillsBeta)
+	}
+	return map[string]any{
+		"container": container,
+		"tools.-1":  codeExecutionTool, // Appended to the client's tools
+	}, betas
+}
@@ -0,0 +1,108 @@
+package anthropicclaude
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter"
+)
+
+func TestContainerPassthrough(t *testing.T) {
+	const message = `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","stop_reason":"end_turn",` +
+		`"content":[{"type":"text","text":"Done"}],"usage":{"input_tokens":1,"output_tokens":1}}`
+	const tools = `"tools":[{"type":"function","function":{"name":"read"}}],`
+
+	tests := []struct {
+		name       string
+		tools      string
+		extraBody  string
+		wantBody   []string // Fragments of the upstream request
+		wantBeta   string
+		wantErr    bool
+		wantNoTool bool
+	}{
+		{
+			name:      "skills",
+			tools:     tools,
+			extraBody: `{"container":{"skills":[{"type":"anthropic","skill_id":"pptx","version":"latest"}]}}`,
+			wantBody: []string{
+				`"container":{"skills":[{"skill_id":"pptx","type":"anthropic","version":"latest"}]}`,
+				`"name":"read"`,
+				`{"name":"code_execution","type":"code_execution_20250825"}`,
+			},
+			wantBeta: "code-execution-2025-08-25,skills-2025-10-02",
+		},
+		{
+			name:      "container reuse with extra betas",
+			extraBody: `{"container":"container_1","betas":["context-management-2025-06-27","skills-2025-10-02"]}`,
+			wantBody:  []string{`"container":"container_1"`, `"tools":[{"name":"code_execution","type":"code_execution_20250825"}]`},
+			wantBeta:  "code-execution-2025-08-25,context-management-2025-06-27,skills-2025-10-02",
+		},
+		{
+			name:       "betas only",
+			extraBody:  `{"betas":["context-management-2025-06-27"]}`,
+			wantBeta:   "context-management-2025-06-27",
+			wantNoTool: true,
+		},
+		{
+			name:      "skill without id",
+			extraBody: `{"container":{"skills":[{"type":"anthropic"}]}}`,
+			wantErr:   true,
+		},
+		{
+			name:      "betas not strings",
+			extraBody: `{"betas":[1]}`,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req openaiadapter.CreateChatCompletionRequest
+			request := `{"model":"claude-sonnet-4-5",` + tt.tools + `"messages":[{"role":"user","content":"Slides please"}],"extra_body":` + tt.extraBody + `}`
+			if err := json.Unmarshal([]byte(request), &req); err != nil {
+				t.Fatal(err)
+			}
+
+			var body, beta string
+			transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(r.Body)
+				body = string(b)
+				beta = strings.Join(r.Header.Values("Anthropic-Beta"), ";")
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(message)),
+					Request:    r,
+				}, nil
+			})
+
+			_, err := NewCreateChatCompletionAdapter().ProcessRequest(context.Background(), req, transport)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("upstream body = %s\nwant it to contain %s", body, want)
+				}
+			}
+			if tt.wantNoTool && strings.Contains(body, "code_execution") {
+				t.Errorf("upstream body = %s, want no code execution tool without a container", body)
+			}
+			if beta != tt.wantBeta {
+				t.Errorf("Anthropic-Beta = %q, want %q", beta, tt.wantBeta)
+			}
+		})
+	}
+}
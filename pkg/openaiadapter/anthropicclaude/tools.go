@@ -6,7 +6,7 @@ import (
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/google/uuid"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/types"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
 )
 
 // fromChatCompletionTools transforms OpenAI tools array to Anthropic format.
@@ -43,7 +43,10 @@ func fromChatCompletionTools(
 			// Transform schema format: OpenAI uses flat JSON Schema object, Anthropic separates
 			// properties/required into distinct fields with remaining fields in ExtraFields.
 			if chatTool.Function.Parameters != nil {
-				params := *chatTool.Function.Parameters
+				params, err := normalizeToolSchema(*chatTool.Function.Parameters)
+				if err != nil {
+					return nil, fmt.Errorf("unsupported parameters schema of tool %q: %w", chatTool.Function.Name, err)
+				}
 
 				if props, ok := params["properties"]; ok {
 					toolParam.InputSchema.Properties = props
@@ -3,7 +3,7 @@ package anthropicclaude
 import (
 	"github.com/anthropics/anthropic-sdk-go"
 
-	"github.com/florianilch/claudine-proxy/internal/openaiadapter/types"
+	"github.com/florianilch/claudine-proxy/pkg/openaiadapter/types"
 )
 
 // toCompletionUsage converts Anthropic usage metadata to OpenAI CompletionUsage format.
@@ -757,9 +757,12 @@ type ChatCompletionStreamResponseDelta struct {
 		// Arguments The arguments to call the function with, as generated by the model in JSON format. Note that the model does not always generate valid JSON, and may hallucinate parameters not defined by your function schema. Validate the arguments in your code before calling your function.
 		Arguments *string `json:"arguments,omitempty"`
 	} `json:"function_call,omitempty"`
-	Refusal   *string                                             `json:"refusal,omitempty"`
-	Role      *ChatCompletionStreamResponseDeltaRole              `json:"role,omitempty"`
-	ToolCalls *[]ChatCompletionStreamResponseDelta_ToolCalls_Item `json:"tool_calls,omitempty"`
+
+	// ReasoningContent Reasoning of the model, streamed apart from the content. Not part of OpenAI's API; follows the convention of reasoning-aware OpenAI-compatible clients.
+	ReasoningContent *string                                             `json:"reasoning_content,omitempty"`
+	Refusal          *string                                             `json:"refusal,omitempty"`
+	Role             *ChatCompletionStreamResponseDeltaRole              `json:"role,omitempty"`
+	ToolCalls        *[]ChatCompletionStreamResponseDelta_ToolCalls_Item `json:"tool_calls,omitempty"`
 }
 
 // ChatCompletionStreamResponseDeltaRole defines model for ChatCompletionStreamResponseDelta.Role.
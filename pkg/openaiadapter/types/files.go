@@ -0,0 +1,66 @@
+package types
+
+// The Files API uses multipart uploads and binary downloads, which the generated
+// spec subset (see api.yaml) does not cover. The types below mirror the OpenAIFile,
+// ListFilesResponse and DeleteFileResponse schemas in openai/openai.yaml.
+
+// Defines values for OpenAIFileObject.
+const (
+	OpenAIFileObjectFile OpenAIFileObject = "file"
+)
+
+// Defines values for OpenAIFileStatus.
+const (
+	OpenAIFileStatusError     OpenAIFileStatus = "error"
+	OpenAIFileStatusProcessed OpenAIFileStatus = "processed"
+	OpenAIFileStatusUploaded  OpenAIFileStatus = "uploaded"
+)
+
+// OpenAIFile The `File` object represents a document that has been uploaded to OpenAI.
+type OpenAIFile struct {
+	// Bytes The size of the file, in bytes.
+	Bytes int64 `json:"bytes"`
+
+	// CreatedAt The Unix timestamp (in seconds) for when the file was created.
+	CreatedAt int64 `json:"created_at"`
+
+	// ExpiresAt The Unix timestamp (in seconds) for when the file will expire.
+	ExpiresAt *int64 `json:"expires_at,omitempty"`
+
+	// Filename The name of the file.
+	Filename string `json:"filename"`
+
+	// Id The file identifier, which can be referenced in the API endpoints.
+	Id string `json:"id"`
+
+	// Object The object type, which is always `file`.
+	Object OpenAIFileObject `json:"object"`
+
+	// Purpose The intended purpose of the file.
+	Purpose string `json:"purpose"`
+
+	// Status Deprecated. The current status of the file.
+	Status OpenAIFileStatus `json:"status"`
+}
+
+// OpenAIFileObject The object type, which is always `file`.
+type OpenAIFileObject string
+
+// OpenAIFileStatus Deprecated. The current status of the file.
+type OpenAIFileStatus string
+
+// ListFilesResponse defines model for ListFilesResponse.
+type ListFilesResponse struct {
+	Data    []OpenAIFile `json:"data"`
+	FirstId string       `json:"first_id"`
+	HasMore bool         `json:"has_more"`
+	LastId  string       `json:"last_id"`
+	Object  string       `json:"object"`
+}
+
+// DeleteFileResponse defines model for DeleteFileResponse.
+type DeleteFileResponse struct {
+	Deleted bool             `json:"deleted"`
+	Id      string           `json:"id"`
+	Object  OpenAIFileObject `json:"object"`
+}